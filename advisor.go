@@ -0,0 +1,171 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcpinfo
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	"github.com/mikioh/tcpopt"
+)
+
+// An Advisor recommends socket buffer sizes for connections from
+// samples of connection information, and optionally applies them.
+//
+// The buffer sizes follow the bandwidth-delay products of the
+// connection in both directions, estimated from the delivery rate
+// and minimum round-trip time where available, or from the
+// congestion window and the rates between samples otherwise.
+// A recommendation is changed only when it differs from the previous
+// one by more than the hysteresis, which avoids flapping.
+type Advisor struct {
+	MinBuffer  int     // minimum buffer size in bytes; defaults to 64KiB
+	MaxBuffer  int     // maximum buffer size in bytes; defaults to 16MiB
+	Headroom   float64 // ratio of buffer size to bandwidth-delay product; defaults to 2
+	Hysteresis float64 // minimum relative change of recommendation; defaults to 0.25
+	Apply      bool    // whether to set SO_SNDBUF and SO_RCVBUF on connections
+
+	mu    sync.Mutex
+	conns map[net.Conn]*adviceState
+}
+
+// An Advice represents a recommendation of socket buffer sizes.
+type Advice struct {
+	SendBDP       uint64 // estimated bandwidth-delay product for sending in bytes
+	ReceiveBDP    uint64 // estimated bandwidth-delay product for receiving in bytes
+	SendBuffer    int    // recommended SO_SNDBUF in bytes
+	ReceiveBuffer int    // recommended SO_RCVBUF in bytes
+	Changed       bool   // whether the recommendation differs from the previous one
+}
+
+type adviceState struct {
+	prev   *Sample
+	advice Advice
+}
+
+func (a *Advisor) bounds() (int, int, float64, float64) {
+	min, max, headroom, hysteresis := a.MinBuffer, a.MaxBuffer, a.Headroom, a.Hysteresis
+	if min <= 0 {
+		min = 64 << 10
+	}
+	if max <= 0 {
+		max = 16 << 20
+	}
+	if headroom <= 0 {
+		headroom = 2
+	}
+	if hysteresis <= 0 {
+		hysteresis = 0.25
+	}
+	return min, max, headroom, hysteresis
+}
+
+// Advise returns a recommendation of socket buffer sizes for c from
+// the sample s, and sets the buffer sizes on c when the
+// recommendation is changed and the Apply field is set.
+// It returns nil when s has no connection information.
+//
+// The connection must implement syscall.Conn when the Apply field is
+// set.
+func (a *Advisor) Advise(c net.Conn, s *Sample) (*Advice, error) {
+	if s.Info == nil {
+		return nil, nil
+	}
+	min, max, headroom, hysteresis := a.bounds()
+	a.mu.Lock()
+	if a.conns == nil {
+		a.conns = make(map[net.Conn]*adviceState)
+	}
+	st := a.conns[c]
+	if st == nil {
+		st = new(adviceState)
+		a.conns[c] = st
+	}
+	sbdp, rbdp := estimateBDP(st.prev, s)
+	next := Advice{
+		SendBDP:       sbdp,
+		ReceiveBDP:    rbdp,
+		SendBuffer:    bufferSize(sbdp, headroom, min, max),
+		ReceiveBuffer: bufferSize(rbdp, headroom, min, max),
+	}
+	if cur := st.advice; moved(cur.SendBuffer, next.SendBuffer, hysteresis) || moved(cur.ReceiveBuffer, next.ReceiveBuffer, hysteresis) {
+		next.Changed = true
+	} else {
+		next.SendBuffer, next.ReceiveBuffer = cur.SendBuffer, cur.ReceiveBuffer
+	}
+	st.prev, st.advice = s, next
+	a.mu.Unlock()
+	if next.Changed && a.Apply {
+		if err := set(c, tcpopt.SendBuffer(next.SendBuffer)); err != nil {
+			return &next, err
+		}
+		if err := set(c, tcpopt.ReceiveBuffer(next.ReceiveBuffer)); err != nil {
+			return &next, err
+		}
+	}
+	return &next, nil
+}
+
+// Forget releases the state for c, such as when the connection is
+// closed.
+func (a *Advisor) Forget(c net.Conn) {
+	a.mu.Lock()
+	delete(a.conns, c)
+	a.mu.Unlock()
+}
+
+// estimateBDP returns the estimated bandwidth-delay products for
+// sending and receiving.
+func estimateBDP(prev, cur *Sample) (uint64, uint64) {
+	i := cur.Info
+	ds := i.Stats()
+	rtt := i.RTT
+	if ds.Valid("min_rtt") && ds.MinRTT > 0 {
+		rtt = ds.MinRTT
+	}
+	var sbdp, rbdp uint64
+	switch {
+	case ds.Valid("delivery_rate") && ds.DeliveryRate > 0:
+		sbdp = bdp(float64(ds.DeliveryRate), rtt)
+	case i.CongestionControl != nil && i.CongestionControl.SenderWindowBytes > 0:
+		sbdp = uint64(i.CongestionControl.SenderWindowBytes)
+	case i.CongestionControl != nil:
+		sbdp = uint64(i.CongestionControl.SenderWindowSegs) * uint64(i.SenderMSS)
+	}
+	if d := Diff(prev, cur); d != nil {
+		if r := bdp(d.SendRate(), rtt); r > sbdp {
+			sbdp = r
+		}
+		rbdp = bdp(d.ReceiveRate(), rtt)
+	}
+	return sbdp, rbdp
+}
+
+func bdp(rate float64, rtt time.Duration) uint64 { return uint64(rate * rtt.Seconds()) }
+
+func bufferSize(bdp uint64, headroom float64, min, max int) int {
+	n := float64(bdp) * headroom
+	switch {
+	case n < float64(min):
+		return min
+	case n > float64(max):
+		return max
+	}
+	return int(n)
+}
+
+// moved reports whether next differs from cur by more than the
+// relative change h.
+func moved(cur, next int, h float64) bool {
+	if cur == 0 {
+		return true
+	}
+	d := float64(next - cur)
+	if d < 0 {
+		d = -d
+	}
+	return d > h*float64(cur)
+}
@@ -0,0 +1,75 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcpinfo_test
+
+import (
+	"net"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/mikioh/tcpinfo"
+	"github.com/mikioh/tcpinfo/tcpinfotest"
+)
+
+func TestAdvisor(t *testing.T) {
+	c := tcpinfotest.NewConn("192.0.2.1:443", "192.0.2.2:50000")
+	a := &tcpinfo.Advisor{MinBuffer: 1 << 10, MaxBuffer: 1 << 20}
+	info := func(cwnd uint) *tcpinfo.Info {
+		return tcpinfotest.NewInfo().RTT(100*time.Millisecond, 0).MSS(1000, 1000).CongestionControl(tcpinfo.CongestionControl{SenderWindowSegs: cwnd}).Build()
+	}
+	for _, tt := range []struct {
+		cwnd    uint
+		sndbuf  int
+		changed bool
+	}{
+		{100, 200000, true},
+		{110, 200000, false}, // within hysteresis
+		{200, 400000, true},
+		{10000, 1 << 20, true},
+	} {
+		adv, err := a.Advise(c, &tcpinfo.Sample{Time: time.Now(), Info: info(tt.cwnd)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if adv.SendBDP != uint64(tt.cwnd)*1000 || adv.SendBuffer != tt.sndbuf || adv.Changed != tt.changed || adv.ReceiveBuffer != 1<<10 {
+			t.Fatalf("cwnd %d: got %+v", tt.cwnd, adv)
+		}
+	}
+	a.Forget(c)
+	if adv, _ := a.Advise(c, &tcpinfo.Sample{Info: info(110)}); !adv.Changed {
+		t.Fatalf("got %+v", adv)
+	}
+	if adv, err := a.Advise(c, &tcpinfo.Sample{}); adv != nil || err != nil {
+		t.Fatalf("got %+v, %v", adv, err)
+	}
+}
+
+func TestAdvisorApply(t *testing.T) {
+	switch runtime.GOOS {
+	case "darwin", "freebsd", "linux", "netbsd":
+	default:
+		t.Skipf("%s/%s", runtime.GOOS, runtime.GOARCH)
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	c, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	a := &tcpinfo.Advisor{Apply: true}
+	adv, err := a.Advise(c, &tcpinfo.Sample{Time: time.Now(), Info: tcpinfotest.NewInfo().Build()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !adv.Changed || adv.SendBuffer != 64<<10 {
+		t.Fatalf("got %+v", adv)
+	}
+}
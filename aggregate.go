@@ -0,0 +1,142 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcpinfo
+
+import (
+	"net"
+	"sort"
+	"sync"
+	"time"
+)
+
+// An Aggregate represents histograms of connection information
+// samples sharing the same labels.
+type Aggregate struct {
+	Labels       []Label    // labels of samples
+	Conns        int        // # of distinct connections sampled
+	RTT          *Histogram // round-trip times in microseconds
+	DeliveryRate *Histogram // delivery rates in bytes per second
+	SenderWindow *Histogram // sender congestion windows in segments
+}
+
+// An Aggregator folds samples of connection information on multiple
+// connections into histograms keyed by labels.
+//
+// Samples are fed by Observe, which can be used as a SampleFunc.
+// A connection is released with its final sample.
+type Aggregator struct {
+	l Labeler
+
+	mu     sync.Mutex
+	groups map[string]*aggregateGroup
+}
+
+type aggregateGroup struct {
+	agg   Aggregate
+	conns map[net.Conn]struct{} // connections sampled and not closed yet
+}
+
+// NewAggregator returns a new aggregator that keys samples by their
+// labels, those attached to the Labels field followed by those
+// returned by l, such as the autonomous system of the remote address,
+// the ID of a relay or the egress interface from a RouteResolver.
+// A nil l means samples are keyed by the Labels field only.
+func NewAggregator(l Labeler) *Aggregator {
+	return &Aggregator{l: l, groups: make(map[string]*aggregateGroup)}
+}
+
+// Observe records the sample s on the connection c.
+func (a *Aggregator) Observe(c net.Conn, s *Sample) {
+	if s.Final {
+		defer a.forget(c)
+	}
+	if s.Info == nil {
+		return
+	}
+	lbs := s.Labels
+	if a.l != nil {
+		lbs = append(lbs[:len(lbs):len(lbs)], a.l.Labels(c, s)...)
+	}
+	key := labelsKey(lbs)
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	g := a.groups[key]
+	if g == nil {
+		g = &aggregateGroup{
+			agg:   Aggregate{Labels: append([]Label(nil), lbs...), RTT: new(Histogram), DeliveryRate: new(Histogram), SenderWindow: new(Histogram)},
+			conns: make(map[net.Conn]struct{}),
+		}
+		a.groups[key] = g
+	}
+	if _, ok := g.conns[c]; !ok {
+		g.conns[c] = struct{}{}
+		g.agg.Conns++
+	}
+	if s.Info.RTT > 0 {
+		g.agg.RTT.Record(uint64(s.Info.RTT / time.Microsecond))
+	}
+	if ds := s.Info.Stats(); ds.Valid("delivery_rate") {
+		g.agg.DeliveryRate.Record(ds.DeliveryRate)
+	}
+	if cc := s.Info.CongestionControl; cc != nil {
+		g.agg.SenderWindow.Record(uint64(cc.SenderWindowSegs))
+	}
+}
+
+// forget releases c from the aggregates.
+// The connection stays counted in the aggregates it was sampled in.
+func (a *Aggregator) forget(c net.Conn) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, g := range a.groups {
+		delete(g.conns, c)
+	}
+}
+
+// Snapshot returns copies of the aggregates, sorted by labels.
+func (a *Aggregator) Snapshot() []*Aggregate {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.snapshot()
+}
+
+// Reset returns copies of the aggregates as Snapshot does, and clears
+// them atomically, so that periodic export loses no samples.
+func (a *Aggregator) Reset() []*Aggregate {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	aggs := a.snapshot()
+	a.groups = make(map[string]*aggregateGroup)
+	return aggs
+}
+
+func (a *Aggregator) snapshot() []*Aggregate {
+	aggs := make([]*Aggregate, 0, len(a.groups))
+	for _, g := range a.groups {
+		aggs = append(aggs, &Aggregate{
+			Labels:       append([]Label(nil), g.agg.Labels...),
+			Conns:        g.agg.Conns,
+			RTT:          g.agg.RTT.clone(),
+			DeliveryRate: g.agg.DeliveryRate.clone(),
+			SenderWindow: g.agg.SenderWindow.clone(),
+		})
+	}
+	sort.Slice(aggs, func(i, j int) bool {
+		return labelsKey(aggs[i].Labels) < labelsKey(aggs[j].Labels)
+	})
+	return aggs
+}
+
+// labelsKey returns the key of aggregate for the labels lbs.
+func labelsKey(lbs []Label) string {
+	var b []byte
+	for _, lb := range lbs {
+		b = append(b, lb.Name...)
+		b = append(b, 0)
+		b = append(b, lb.Value...)
+		b = append(b, 0)
+	}
+	return string(b)
+}
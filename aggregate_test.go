@@ -0,0 +1,119 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcpinfo_test
+
+import (
+	"math/rand"
+	"net"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/mikioh/tcpinfo"
+	"github.com/mikioh/tcpinfo/tcpinfotest"
+)
+
+func TestHistogram(t *testing.T) {
+	var h tcpinfo.Histogram
+	if h.Quantile(0.5) != 0 || h.Count() != 0 {
+		t.Fatalf("got %v, %v; want zeros", h.Quantile(0.5), h.Count())
+	}
+	r := rand.New(rand.NewSource(1))
+	vs := make([]uint64, 10000)
+	for j := range vs {
+		vs[j] = uint64(r.ExpFloat64() * 50000)
+		h.Record(vs[j])
+	}
+	sort.Slice(vs, func(i, j int) bool { return vs[i] < vs[j] })
+	if h.Count() != uint64(len(vs)) || h.Min() != vs[0] || h.Max() != vs[len(vs)-1] {
+		t.Fatalf("got %d, %d, %d", h.Count(), h.Min(), h.Max())
+	}
+	for _, q := range []float64{0.5, 0.9, 0.99, 0.999} {
+		want := vs[int(q*float64(len(vs)))-1]
+		got := h.Quantile(q)
+		if d := float64(got) - float64(want); d < -0.016*float64(want) || d > 0.016*float64(want)+1 {
+			t.Fatalf("q=%v: got %d; want %d within 1.6%%", q, got, want)
+		}
+	}
+
+	var g tcpinfo.Histogram
+	for v := uint64(0); v < 100; v++ {
+		g.Record(v)
+	}
+	if g.Quantile(0.5) != 49 || g.Quantile(1) != 99 || g.Mean() != 49.5 {
+		t.Fatalf("got %d, %d, %v", g.Quantile(0.5), g.Quantile(1), g.Mean())
+	}
+	g.Merge(&h)
+	if g.Count() != h.Count()+100 || g.Min() != 0 || g.Max() != h.Max() {
+		t.Fatalf("got %d, %d, %d", g.Count(), g.Min(), g.Max())
+	}
+}
+
+func TestAggregator(t *testing.T) {
+	c1 := tcpinfotest.NewConn("192.0.2.1:443", "192.0.2.11:50000")
+	c2 := tcpinfotest.NewConn("192.0.2.1:443", "192.0.2.12:50000")
+	c3 := tcpinfotest.NewConn("192.0.2.1:443", "198.51.100.1:50000")
+	a := tcpinfo.NewAggregator(tcpinfo.LabelerFunc(func(c net.Conn, _ *tcpinfo.Sample) []tcpinfo.Label {
+		if c.RemoteAddr().(*net.TCPAddr).IP.To4()[0] == 192 {
+			return []tcpinfo.Label{{Name: "relay", Value: "relay-a"}}
+		}
+		return []tcpinfo.Label{{Name: "relay", Value: "relay-b"}}
+	}))
+	for j, c := range []net.Conn{c1, c2, c3, c1} {
+		a.Observe(c, &tcpinfo.Sample{Info: tcpinfotest.NewInfo().RTT(time.Duration(j+1)*time.Millisecond, 0).Build()})
+	}
+	a.Observe(c1, &tcpinfo.Sample{Err: tcpinfo.ErrNotSupported})
+
+	aggs := a.Snapshot()
+	if len(aggs) != 2 || aggs[0].Labels[0].Value != "relay-a" || aggs[1].Labels[0].Value != "relay-b" {
+		t.Fatalf("got %v", aggs)
+	}
+	if agg := aggs[0]; agg.Conns != 2 || agg.RTT.Count() != 3 || agg.RTT.Max() != 4000 || agg.SenderWindow.Quantile(0.5) != 10 {
+		t.Fatalf("got %+v", agg)
+	}
+	if agg := aggs[1]; agg.Conns != 1 || agg.RTT.Count() != 1 || agg.RTT.Min() != 3000 {
+		t.Fatalf("got %+v", agg)
+	}
+
+	a.Observe(c3, &tcpinfo.Sample{Info: tcpinfotest.NewInfo().Build()})
+	if aggs[1].RTT.Count() != 1 {
+		t.Fatal("snapshot modified by later samples")
+	}
+	a.Observe(c3, &tcpinfo.Sample{Err: tcpinfo.ErrConnClosed, Final: true})
+	a.Observe(c2, &tcpinfo.Sample{Info: tcpinfotest.NewInfo().Build(), Final: true})
+	if aggs := a.Snapshot(); aggs[0].Conns != 2 || aggs[0].RTT.Count() != 4 || aggs[1].Conns != 1 {
+		t.Fatalf("got %+v, %+v; want closed connections still counted", aggs[0], aggs[1])
+	}
+	if aggs := a.Reset(); len(aggs) != 2 || aggs[1].RTT.Count() != 2 {
+		t.Fatalf("got %v", aggs)
+	}
+	if aggs := a.Snapshot(); len(aggs) != 0 {
+		t.Fatalf("got %v; want none after reset", aggs)
+	}
+}
+
+func TestAggregatorSampleLabels(t *testing.T) {
+	c1 := tcpinfotest.NewConn("192.0.2.1:443", "192.0.2.11:50000")
+	c2 := tcpinfotest.NewConn("192.0.2.1:443", "192.0.2.12:50000")
+	a := tcpinfo.NewAggregator(nil)
+	for _, tt := range []struct {
+		c   net.Conn
+		pod string
+	}{
+		{c1, "web-0"}, {c2, "web-1"}, {c1, "web-0"},
+	} {
+		a.Observe(tt.c, &tcpinfo.Sample{
+			Info:   tcpinfotest.NewInfo().RTT(time.Millisecond, 0).Build(),
+			Labels: []tcpinfo.Label{{Name: "pod", Value: tt.pod}},
+		})
+	}
+	aggs := a.Snapshot()
+	if len(aggs) != 2 || aggs[0].Labels[0] != (tcpinfo.Label{Name: "pod", Value: "web-0"}) || aggs[1].Labels[0].Value != "web-1" {
+		t.Fatalf("got %v", aggs)
+	}
+	if aggs[0].Conns != 1 || aggs[0].RTT.Count() != 2 || aggs[1].RTT.Count() != 1 {
+		t.Fatalf("got %+v, %+v", aggs[0], aggs[1])
+	}
+}
@@ -0,0 +1,182 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcpinfo
+
+import (
+	"math"
+	"net"
+	"sync"
+	"time"
+)
+
+// An AnomalyKind represents a kind of anomaly.
+type AnomalyKind int
+
+const (
+	RTTSpike           AnomalyKind = iota + 1 // round-trip time far above its baseline
+	ThroughputCollapse                        // sending rate far below its baseline while data waits to be sent
+)
+
+var anomalyKinds = map[AnomalyKind]string{
+	RTTSpike:           "rtt spike",
+	ThroughputCollapse: "throughput collapse",
+}
+
+func (k AnomalyKind) String() string {
+	s, ok := anomalyKinds[k]
+	if !ok {
+		return "<nil>"
+	}
+	return s
+}
+
+// An Anomaly represents an anomaly on a connection.
+type Anomaly struct {
+	Kind      AnomalyKind
+	Start     time.Time     // time of the first anomalous sample
+	Duration  time.Duration // time from Start to the last anomalous sample
+	Value     float64       // most deviating value; milliseconds for RTTSpike, bytes per second for ThroughputCollapse
+	Baseline  float64       // baseline when the anomaly began, in the same unit as Value
+	Magnitude float64       // deviation of Value from Baseline in # of mean absolute deviations
+	End       bool          // whether the anomaly has ended
+}
+
+// An AnomalyFunc receives an anomaly on c.
+type AnomalyFunc func(c net.Conn, a *Anomaly)
+
+// An AnomalyDetector detects anomalies online over streams of
+// samples.
+//
+// The detector keeps exponentially weighted moving averages of the
+// round-trip time and sending rate and of their absolute deviations
+// on each connection, and reports a sample deviating from the average
+// by more than Threshold times the deviation as anomalous.
+// Anomalous samples do not update the averages, so that a lasting
+// anomaly is not absorbed into the baseline.
+//
+// The Observe method is a SampleFunc, which allows the detector to
+// be used as the callback function of samplers and monitors
+// directly.
+type AnomalyDetector struct {
+	Alpha     float64     // weight of the latest sample in averages; zero means 0.1
+	Threshold float64     // # of mean absolute deviations beyond which a sample is anomalous; zero means 4
+	Warmup    int         // # of samples taken to build the baseline before detection; zero means 10
+	Func      AnomalyFunc // callback function invoked when an anomaly begins and ends
+
+	mu    sync.Mutex
+	conns map[net.Conn]*anomalyState
+}
+
+// An anomalyState represents the state of the detector on a
+// connection.
+type anomalyState struct {
+	prev *Sample
+	rtt  anomalyTrack
+	rate anomalyTrack
+}
+
+// An anomalyTrack represents the baseline and ongoing anomaly of a
+// series of values.
+type anomalyTrack struct {
+	n    int
+	mean float64
+	dev  float64
+	cur  *Anomaly
+}
+
+// Observe observes the sample s on c and invokes Func with each
+// anomaly that begins or ends.
+// The state on c is discarded with the final sample, ending ongoing
+// anomalies.
+func (d *AnomalyDetector) Observe(c net.Conn, s *Sample) {
+	var evs []Anomaly
+	d.mu.Lock()
+	if d.conns == nil {
+		d.conns = make(map[net.Conn]*anomalyState)
+	}
+	st := d.conns[c]
+	if st == nil {
+		st = &anomalyState{}
+		d.conns[c] = st
+	}
+	if s.Info != nil {
+		if s.Info.RTT > 0 {
+			evs = d.update(evs, &st.rtt, RTTSpike, s.Time, traceMillis(s.Info.RTT), 1)
+		}
+		if dl := Diff(st.prev, s); dl != nil && s.Info.Stats().Valid("bytes_sent") && !idle(s.Info) {
+			evs = d.update(evs, &st.rate, ThroughputCollapse, s.Time, dl.SendRate(), -1)
+		}
+		st.prev = s
+	}
+	if s.Final {
+		for _, t := range []*anomalyTrack{&st.rtt, &st.rate} {
+			if t.cur != nil {
+				t.cur.End = true
+				evs = append(evs, *t.cur)
+			}
+		}
+		delete(d.conns, c)
+	}
+	d.mu.Unlock()
+	if d.Func == nil {
+		return
+	}
+	for j := range evs {
+		d.Func(c, &evs[j])
+	}
+}
+
+// update updates the track t with the value v observed at time now
+// and appends the anomalies beginning or ending to evs.
+// The sign is 1 when values above the baseline are anomalous, and -1
+// when values below it are.
+func (d *AnomalyDetector) update(evs []Anomaly, t *anomalyTrack, kind AnomalyKind, now time.Time, v, sign float64) []Anomaly {
+	alpha, thresh, warmup := d.Alpha, d.Threshold, d.Warmup
+	if alpha <= 0 {
+		alpha = 0.1
+	}
+	if thresh <= 0 {
+		thresh = 4
+	}
+	if warmup <= 0 {
+		warmup = 10
+	}
+	if t.n == 0 {
+		t.mean = v
+	}
+	// The deviation is kept from falling below a few percent of the
+	// mean, so that steady series do not flag jitter.
+	dev := math.Max(t.dev, 0.05*math.Abs(t.mean))
+	if z := sign * (v - t.mean) / dev; t.n >= warmup && dev > 0 && z > thresh {
+		if t.cur == nil {
+			t.cur = &Anomaly{Kind: kind, Start: now, Baseline: t.mean}
+			t.cur.Value, t.cur.Magnitude = v, z
+			return append(evs, *t.cur)
+		}
+		t.cur.Duration = now.Sub(t.cur.Start)
+		if z > t.cur.Magnitude {
+			t.cur.Value, t.cur.Magnitude = v, z
+		}
+		return evs
+	}
+	if t.cur != nil {
+		t.cur.End = true
+		evs = append(evs, *t.cur)
+		t.cur = nil
+	}
+	t.n++
+	t.dev += alpha * (math.Abs(v-t.mean) - t.dev)
+	t.mean += alpha * (v - t.mean)
+	return evs
+}
+
+// idle reports whether the connection has no data waiting to be sent
+// as far as i tells.
+func idle(i *Info) bool {
+	if n, ok := notSentBytes(i); ok && n > 0 {
+		return false
+	}
+	return i.Queue != nil && i.Queue.Send == 0
+}
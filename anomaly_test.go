@@ -0,0 +1,47 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcpinfo_test
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/mikioh/tcpinfo"
+	"github.com/mikioh/tcpinfo/tcpinfotest"
+)
+
+func TestAnomalyDetector(t *testing.T) {
+	var as []tcpinfo.Anomaly
+	d := &tcpinfo.AnomalyDetector{Func: func(_ net.Conn, a *tcpinfo.Anomaly) { as = append(as, *a) }}
+	c := tcpinfotest.NewConn("192.0.2.1:1", "192.0.2.2:2")
+	start := time.Unix(0, 0)
+	rtts := []time.Duration{20, 21, 19, 20, 22, 20, 19, 21, 20, 20, 20, 200, 300, 250, 20, 21}
+	for j, rtt := range rtts {
+		d.Observe(c, &tcpinfo.Sample{Time: start.Add(time.Duration(j) * time.Second), Info: &tcpinfo.Info{RTT: rtt * time.Millisecond}})
+	}
+	if len(as) != 2 {
+		t.Fatalf("got %+v; want begin and end", as)
+	}
+	if a := as[0]; a.Kind != tcpinfo.RTTSpike || a.End || !a.Start.Equal(start.Add(11*time.Second)) || a.Value != 200 {
+		t.Fatalf("got %+v", a)
+	}
+	if a := as[1]; !a.End || a.Duration != 2*time.Second || a.Value != 300 || a.Baseline < 19 || a.Baseline > 21 || a.Magnitude <= 4 {
+		t.Fatalf("got %+v", a)
+	}
+
+	as = nil
+	for j := 0; j < 12; j++ {
+		rtt := 20 * time.Millisecond
+		if j == 11 {
+			rtt = time.Second
+		}
+		d.Observe(c, &tcpinfo.Sample{Time: start.Add(time.Duration(j) * time.Second), Info: &tcpinfo.Info{RTT: rtt}})
+	}
+	d.Observe(c, &tcpinfo.Sample{Time: start.Add(12 * time.Second), Err: tcpinfo.ErrNotSupported, Final: true})
+	if len(as) != 2 || !as[1].End {
+		t.Fatalf("got %+v; want anomaly ended by final sample", as)
+	}
+}
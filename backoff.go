@@ -0,0 +1,171 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcpinfo
+
+import "time"
+
+// A RetryPolicy represents the policy of the kernel on giving up
+// retransmission on timeout and aborting the connection.
+type RetryPolicy struct {
+	Retries     int           // # of retransmissions on timeout before giving up; net.ipv4.tcp_retries2 on Linux
+	RTOMin      time.Duration // minimum retransmission timeout on which the time to give up is modelled
+	RTOMax      time.Duration // maximum retransmission timeout
+	UserTimeout time.Duration // TCP_USER_TIMEOUT set on the connection; zero means not set
+}
+
+// DefaultRetryPolicy is the retry policy of Linux with the default
+// configuration.
+var DefaultRetryPolicy = RetryPolicy{Retries: 15, RTOMin: 200 * time.Millisecond, RTOMax: 120 * time.Second}
+
+// SystemRetryPolicy returns the retry policy configured on the
+// running system.
+// It returns DefaultRetryPolicy with the configured # of retries on
+// Linux, and DefaultRetryPolicy on the other platforms.
+func SystemRetryPolicy() RetryPolicy {
+	p := DefaultRetryPolicy
+	if n := sysRetries(); n > 0 {
+		p.Retries = n
+	}
+	return p
+}
+
+// timeout returns the time from the first retransmission on timeout
+// to giving up, as modelled by Linux.
+func (p *RetryPolicy) timeout() time.Duration {
+	if p.UserTimeout > 0 {
+		return p.UserTimeout
+	}
+	if p.RTOMin <= 0 || p.RTOMax < p.RTOMin {
+		return 0
+	}
+	thresh := 0
+	for p.RTOMin<<uint(thresh+1) <= p.RTOMax {
+		thresh++
+	}
+	if p.Retries <= thresh {
+		return time.Duration(2<<uint(p.Retries)-1) * p.RTOMin
+	}
+	return time.Duration(2<<uint(thresh)-1)*p.RTOMin + time.Duration(p.Retries-thresh)*p.RTOMax
+}
+
+// A Backoff represents the state of retransmission timeout backoff.
+type Backoff struct {
+	Retransmits uint64        `json:"rexmits"`    // # of consecutive unrecovered retransmissions on timeout
+	Backoffs    uint64        `json:"backoffs"`   // exponent of current retransmission timeout backoff
+	RTO         time.Duration `json:"rto"`        // current retransmission timeout including backoff
+	Elapsed     time.Duration `json:"elapsed"`    // estimated time since the unacknowledged segment was first sent
+	GiveUpIn    time.Duration `json:"give_up_in"` // estimated time until the kernel gives up; zero when not retransmitting
+}
+
+// Backoff returns the state of retransmission timeout backoff and
+// an estimate of time until the kernel gives up retransmission and
+// aborts the connection under the policy p.
+// A nil p means DefaultRetryPolicy.
+// It returns nil when the platform does not report the state.
+//
+// Only supported on Linux.
+func (i *Info) Backoff(p *RetryPolicy) *Backoff {
+	ds := i.Stats()
+	if !ds.Valid("rexmits") || !ds.Valid("backoffs") {
+		return nil
+	}
+	b := &Backoff{Retransmits: ds.Retransmits, Backoffs: ds.Backoffs, RTO: i.RTO}
+	if b.Retransmits == 0 || i.RTO <= 0 {
+		return b
+	}
+	if p == nil {
+		p = &DefaultRetryPolicy
+	}
+	rtoMax := p.RTOMax
+	if rtoMax <= 0 {
+		rtoMax = DefaultRetryPolicy.RTOMax
+	}
+	// Each retransmission on timeout doubles the timeout up to the
+	// maximum; walk back from the current one to the first.
+	rto := i.RTO
+	for k := uint64(0); k < b.Retransmits; k++ {
+		rto /= 2
+		if rto <= 0 {
+			break
+		}
+		b.Elapsed += rto
+	}
+	b.Elapsed += i.LastDataSent
+	// The kernel checks whether to give up when the timer expires,
+	// and the timer never runs past the user timeout.
+	total := p.timeout()
+	next := i.RTO - i.LastDataSent
+	if next < 0 {
+		next = 0
+	}
+	if p.UserTimeout > 0 && b.Elapsed+next > total {
+		next = total - b.Elapsed
+		if next < 0 {
+			next = 0
+		}
+	}
+	b.GiveUpIn = next
+	for rto, elapsed := i.RTO, b.Elapsed+next; elapsed < total; {
+		if rto *= 2; rto > rtoMax {
+			rto = rtoMax
+		}
+		if p.UserTimeout > 0 && elapsed+rto > total {
+			rto = total - elapsed
+		}
+		b.GiveUpIn += rto
+		elapsed += rto
+	}
+	return b
+}
+
+// A Liveness represents the worst-case time the kernel takes to
+// detect a dead peer and abort the connection.
+type Liveness struct {
+	UserTimeout time.Duration `json:"user_timeout"` // TCP_USER_TIMEOUT set on the connection; zero means not set
+	Retransmit  time.Duration `json:"retransmit"`   // time from sending data the peer never acknowledges to giving up
+	Idle        time.Duration `json:"idle"`         // time from the last segment received on an idle connection to giving up on keepalive probes; zero means never
+}
+
+// Liveness returns the worst-case time to detect a dead peer under
+// the policy p, using the user timeout and keepalive options reported
+// in Options, which are reported only when i is retrieved with
+// FieldSockOpts.
+// A nil p means DefaultRetryPolicy.
+// The user timeout reported in Options takes precedence over that of
+// p.
+func (i *Info) Liveness(p *RetryPolicy) *Liveness {
+	if p == nil {
+		p = &DefaultRetryPolicy
+	}
+	q := *p
+	var ka *KeepAlive
+	for _, opt := range i.Options {
+		switch opt := opt.(type) {
+		case UserTimeout:
+			if opt > 0 {
+				q.UserTimeout = time.Duration(opt)
+			}
+		case KeepAlive:
+			ka = &opt
+		}
+	}
+	l := &Liveness{UserTimeout: q.UserTimeout, Retransmit: q.timeout()}
+	if ka == nil {
+		return l
+	}
+	// The keepalive timer fires after the idle time and then at each
+	// interval; Linux gives up once the user timeout has elapsed
+	// since the last segment received and at least one probe is
+	// unanswered, or else once all probes are unanswered.
+	n := time.Duration(ka.Count)
+	if q.UserTimeout > 0 {
+		n = 1
+		if ka.Interval > 0 && q.UserTimeout > ka.Idle+ka.Interval {
+			n = (q.UserTimeout - ka.Idle + ka.Interval - 1) / ka.Interval
+		}
+	}
+	l.Idle = ka.Idle + n*ka.Interval
+	return l
+}
@@ -0,0 +1,66 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcpinfo_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mikioh/tcpinfo"
+)
+
+func TestBackoff(t *testing.T) {
+	i := &tcpinfo.Info{
+		RTO:          1600 * time.Millisecond,
+		LastDataSent: time.Second,
+		Sys:          &tcpinfo.SysInfo{Retransmissions: 3, Backoffs: 3},
+	}
+	for _, tt := range []struct {
+		p    *tcpinfo.RetryPolicy
+		want time.Duration
+	}{
+		{nil, 922200 * time.Millisecond},
+		{&tcpinfo.RetryPolicy{Retries: 3, RTOMin: 200 * time.Millisecond, RTOMax: 120 * time.Second}, 600 * time.Millisecond},
+		{&tcpinfo.RetryPolicy{UserTimeout: 10 * time.Second}, 7600 * time.Millisecond},
+	} {
+		b := i.Backoff(tt.p)
+		if b.Retransmits != 3 || b.Backoffs != 3 || b.Elapsed != 2400*time.Millisecond {
+			t.Fatalf("got %+v", b)
+		}
+		if b.GiveUpIn != tt.want {
+			t.Fatalf("%+v: got %v; want %v", tt.p, b.GiveUpIn, tt.want)
+		}
+	}
+
+	i.Sys = &tcpinfo.SysInfo{}
+	if b := i.Backoff(nil); b.GiveUpIn != 0 {
+		t.Fatalf("got %+v; want no estimate", b)
+	}
+	i.Sys = nil
+	if b := i.Backoff(nil); b != nil {
+		t.Fatalf("got %+v; want nil", b)
+	}
+	if p := tcpinfo.SystemRetryPolicy(); p.Retries <= 0 {
+		t.Fatalf("got %+v", p)
+	}
+}
+
+func TestLiveness(t *testing.T) {
+	ka := tcpinfo.KeepAlive{Idle: 60 * time.Second, Interval: 10 * time.Second, Count: 5}
+	for _, tt := range []struct {
+		opts []tcpinfo.Option
+		want tcpinfo.Liveness
+	}{
+		{nil, tcpinfo.Liveness{Retransmit: 924600 * time.Millisecond}},
+		{[]tcpinfo.Option{ka}, tcpinfo.Liveness{Retransmit: 924600 * time.Millisecond, Idle: 110 * time.Second}},
+		{[]tcpinfo.Option{tcpinfo.UserTimeout(30 * time.Second), ka}, tcpinfo.Liveness{UserTimeout: 30 * time.Second, Retransmit: 30 * time.Second, Idle: 70 * time.Second}},
+		{[]tcpinfo.Option{tcpinfo.UserTimeout(95 * time.Second), ka}, tcpinfo.Liveness{UserTimeout: 95 * time.Second, Retransmit: 95 * time.Second, Idle: 100 * time.Second}},
+	} {
+		i := &tcpinfo.Info{Options: tt.opts}
+		if l := i.Liveness(nil); *l != tt.want {
+			t.Fatalf("%v: got %+v; want %+v", tt.opts, l, tt.want)
+		}
+	}
+}
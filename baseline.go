@@ -0,0 +1,132 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcpinfo
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// A Baseline represents expected connection information, such as in
+// preflight connectivity tests and continuous integration of network
+// configurations.
+// Zero fields are not checked.
+type Baseline struct {
+	MinRTT        time.Duration // minimum round-trip time
+	MaxRTT        time.Duration // maximum round-trip time
+	MaxLoss       float64       // maximum ratio of retransmitted segments to sent segments in percent
+	MinThroughput float64       // minimum sending rate in bytes per second
+}
+
+// A Violation represents a deviation from a baseline.
+type Violation struct {
+	Name  string  // name of check; "rtt", "loss" or "throughput"
+	Value float64 // observed value; milliseconds for rtt, percent for loss, bytes per second for throughput
+	Limit float64 // bound of baseline in the same unit as Value
+}
+
+var violationUnits = map[string]string{
+	"rtt":        "ms",
+	"loss":       "%",
+	"throughput": "B/s",
+}
+
+func (v *Violation) String() string {
+	op := ">"
+	if v.Value < v.Limit {
+		op = "<"
+	}
+	u := violationUnits[v.Name]
+	return fmt.Sprintf("%s %.3g%s %s %.3g%s", v.Name, v.Value, u, op, v.Limit, u)
+}
+
+func (b *Baseline) checkRTT(vs []Violation, rtt time.Duration) []Violation {
+	switch {
+	case b.MinRTT > 0 && rtt < b.MinRTT:
+		return append(vs, Violation{Name: "rtt", Value: traceMillis(rtt), Limit: traceMillis(b.MinRTT)})
+	case b.MaxRTT > 0 && rtt > b.MaxRTT:
+		return append(vs, Violation{Name: "rtt", Value: traceMillis(rtt), Limit: traceMillis(b.MaxRTT)})
+	}
+	return vs
+}
+
+func (b *Baseline) checkLoss(vs []Violation, retrans, sent uint64) []Violation {
+	if b.MaxLoss <= 0 || sent == 0 {
+		return vs
+	}
+	if loss := float64(retrans) * 100 / float64(sent); loss > b.MaxLoss {
+		return append(vs, Violation{Name: "loss", Value: loss, Limit: b.MaxLoss})
+	}
+	return vs
+}
+
+func (b *Baseline) checkThroughput(vs []Violation, rate float64) []Violation {
+	if b.MinThroughput > 0 && rate < b.MinThroughput {
+		return append(vs, Violation{Name: "throughput", Value: rate, Limit: b.MinThroughput})
+	}
+	return vs
+}
+
+// Check returns the deviations of connection information i from the
+// baseline.
+//
+// The loss is the ratio of segments retransmitted to segments sent
+// since the connection was established, and the throughput is the
+// delivery rate.
+// Checks on statistics the platform does not provide are skipped.
+func (b *Baseline) Check(i *Info) []Violation {
+	var vs []Violation
+	vs = b.checkRTT(vs, i.RTT)
+	ds := i.Stats()
+	if ds.Valid("retrans_segs") && ds.Valid("segs_sent") {
+		vs = b.checkLoss(vs, ds.RetransSegs, ds.SegsSent)
+	}
+	if ds.Valid("delivery_rate") {
+		vs = b.checkThroughput(vs, float64(ds.DeliveryRate))
+	}
+	return vs
+}
+
+// CheckHistory returns the deviations of the samples in h from the
+// baseline.
+//
+// The round-trip time is the median of the samples, and the loss and
+// throughput are over the changes between the first and last
+// samples.
+// Checks on statistics the platform does not provide are skipped.
+func (b *Baseline) CheckHistory(h *History) []Violation {
+	var (
+		rtts        []time.Duration
+		first, last *Sample
+	)
+	for _, s := range h.Samples() {
+		if s.Info == nil {
+			continue
+		}
+		rtts = append(rtts, s.Info.RTT)
+		if first == nil {
+			first = s
+		}
+		last = s
+	}
+	if len(rtts) == 0 {
+		return nil
+	}
+	var vs []Violation
+	sort.Slice(rtts, func(i, j int) bool { return rtts[i] < rtts[j] })
+	vs = b.checkRTT(vs, rtts[len(rtts)/2])
+	if first == last {
+		return vs
+	}
+	d, ds := Diff(first, last), last.Info.Stats()
+	if ds.Valid("retrans_segs") && ds.Valid("segs_sent") {
+		vs = b.checkLoss(vs, d.RetransSegs, d.SegsSent)
+	}
+	if ds.Valid("bytes_sent") {
+		vs = b.checkThroughput(vs, d.SendRate())
+	}
+	return vs
+}
@@ -0,0 +1,38 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcpinfo_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mikioh/tcpinfo"
+	"github.com/mikioh/tcpinfo/tcpinfotest"
+)
+
+func TestBaselineCheckLossAndThroughput(t *testing.T) {
+	b := &tcpinfo.Baseline{MaxLoss: 1, MinThroughput: 1 << 20}
+	h := tcpinfo.NewHistory(0)
+	for j, st := range []struct {
+		segs, retrans uint
+		acked         uint64
+	}{
+		{100, 0, 0},
+		{1100, 20, 512 << 10},
+	} {
+		st := st
+		h.Add(&tcpinfo.Sample{Time: time.Unix(int64(j), 0), Info: tcpinfotest.NewInfo().Sys(func(si *tcpinfo.SysInfo) {
+			si.SegsOut, si.TotalRetransSegs, si.ThruBytesAcked = st.segs, st.retrans, st.acked
+			si.DeliveryRate = 2 << 20
+		}).Build()})
+	}
+	vs := b.CheckHistory(h)
+	if len(vs) != 2 || vs[0].Name != "loss" || vs[0].Value != 2 || vs[1].Name != "throughput" || vs[1].Value != 512<<10 {
+		t.Fatalf("got %v", vs)
+	}
+	if vs := b.Check(h.Samples()[1].Info); len(vs) != 1 || vs[0].Name != "loss" {
+		t.Fatalf("got %v", vs)
+	}
+}
@@ -0,0 +1,49 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcpinfo_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mikioh/tcpinfo"
+	"github.com/mikioh/tcpinfo/tcpinfotest"
+)
+
+func TestBaselineCheck(t *testing.T) {
+	b := &tcpinfo.Baseline{MinRTT: time.Millisecond, MaxRTT: 100 * time.Millisecond, MaxLoss: 1}
+	for _, tt := range []struct {
+		rtt  time.Duration
+		want string
+	}{
+		{50 * time.Millisecond, ""},
+		{250 * time.Millisecond, "rtt 250ms > 100ms"},
+		{500 * time.Microsecond, "rtt 0.5ms < 1ms"},
+	} {
+		vs := b.Check(tcpinfotest.NewInfo().RTT(tt.rtt, 0).Build())
+		var got string
+		if len(vs) > 0 {
+			got = vs[0].String()
+		}
+		if len(vs) > 1 || got != tt.want {
+			t.Fatalf("%v: got %v; want %q", tt.rtt, vs, tt.want)
+		}
+	}
+
+	h := tcpinfo.NewHistory(0)
+	for j, rtt := range []time.Duration{10, 300, 20, 30, 400} {
+		h.Add(&tcpinfo.Sample{Time: time.Unix(int64(j), 0), Info: tcpinfotest.NewInfo().RTT(rtt*time.Millisecond, 0).Build()})
+	}
+	if vs := b.CheckHistory(h); len(vs) != 0 {
+		t.Fatalf("got %v; want no violations", vs)
+	}
+	b.MaxRTT = 20 * time.Millisecond
+	if vs := b.CheckHistory(h); len(vs) != 1 || vs[0].Name != "rtt" || vs[0].Value != 30 {
+		t.Fatalf("got %v", vs)
+	}
+	if vs := b.CheckHistory(tcpinfo.NewHistory(0)); vs != nil {
+		t.Fatalf("got %v; want nil", vs)
+	}
+}
@@ -0,0 +1,35 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcpinfo
+
+import "net"
+
+// GetBatch returns connection information on each of cs.
+// The returned slices have the same length as cs; for each
+// connection either the information or the error on retrieval is
+// set.
+//
+// GetBatch shares pooled retrieval buffers among the connections but
+// still issues a system call per connection.
+// On Linux, the sockdiag package provides GetBatch that retrieves
+// information on all the connections with a single sock_diag dump.
+//
+// Only supported on Darwin, FreeBSD, Linux and NetBSD.
+func GetBatch(cs []net.Conn) ([]*Info, []error) {
+	is := make([]*Info, len(cs))
+	errs := make([]error, len(cs))
+	for j, c := range cs {
+		rc, err := rawConn(c)
+		if err != nil {
+			errs[j] = err
+			continue
+		}
+		i := new(Info)
+		if errs[j] = getInto(rc, i, FieldAll); errs[j] == nil {
+			is[j] = i
+		}
+	}
+	return is, errs
+}
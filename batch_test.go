@@ -0,0 +1,54 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcpinfo_test
+
+import (
+	"net"
+	"runtime"
+	"testing"
+
+	"github.com/mikioh/tcpinfo"
+)
+
+func TestGetBatch(t *testing.T) {
+	switch runtime.GOOS {
+	case "darwin", "freebsd", "linux", "netbsd":
+	default:
+		t.Skipf("not supported on %s/%s", runtime.GOOS, runtime.GOARCH)
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	c, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	ac, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ac.Close()
+	pc, _ := net.Pipe()
+	defer pc.Close()
+
+	is, errs := tcpinfo.GetBatch([]net.Conn{c, pc, ac})
+	if len(is) != 3 || len(errs) != 3 {
+		t.Fatalf("got %d, %d; want 3, 3", len(is), len(errs))
+	}
+	for _, j := range []int{0, 2} {
+		if errs[j] != nil {
+			t.Fatal(errs[j])
+		}
+		if is[j].State != tcpinfo.Established {
+			t.Fatalf("got %v; want %v", is[j].State, tcpinfo.Established)
+		}
+	}
+	if is[1] != nil || errs[1] == nil {
+		t.Fatalf("got %v, %v; want nil, an error", is[1], errs[1])
+	}
+}
@@ -0,0 +1,67 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcpinfo
+
+import (
+	"context"
+	"sort"
+	"sync"
+)
+
+// A CampaignResult represents the result of a probe on a target in
+// a campaign.
+type CampaignResult struct {
+	Rank   int          // rank starting at 1; 0 when Err is not nil
+	Target string       // address of target
+	Report *ProbeReport // report; nil when Err is not nil
+	Err    error        // error on probe
+}
+
+// Campaign probes the targets with at most concurrency probes in
+// flight, and returns the results ranked by achieved sending rate in
+// descending order, ties broken by minimum round-trip time.
+// Failed probes come last in the order of targets.
+// A non-positive concurrency means one probe at a time.
+func (p *Prober) Campaign(ctx context.Context, targets []string, concurrency int) []CampaignResult {
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	rs := make([]CampaignResult, len(targets))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for j, target := range targets {
+		rs[j].Target = target
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			rs[j].Err = ctx.Err()
+			continue
+		}
+		wg.Add(1)
+		go func(r *CampaignResult) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			r.Report, r.Err = p.Probe(ctx, r.Target)
+		}(&rs[j])
+	}
+	wg.Wait()
+	sort.SliceStable(rs, func(i, j int) bool {
+		a, b := rs[i].Report, rs[j].Report
+		switch {
+		case a == nil || b == nil:
+			return b == nil && a != nil
+		case a.Rate != b.Rate:
+			return a.Rate > b.Rate
+		default:
+			return a.MinRTT < b.MinRTT
+		}
+	})
+	for j := range rs {
+		if rs[j].Err == nil {
+			rs[j].Rank = j + 1
+		}
+	}
+	return rs
+}
@@ -0,0 +1,67 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcpinfo
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// A Clock represents a source of time for samples.
+type Clock interface {
+	// Now returns the current wall-clock time.
+	Now() time.Time
+
+	// Monotonic returns the current reading of a clock that is
+	// not affected by adjustments of wall-clock time, from an
+	// arbitrary origin.
+	Monotonic() time.Duration
+}
+
+// SystemClock is the clock of the system, which is the default
+// clock for samples.
+var SystemClock Clock = systemClock{}
+
+var monoOrigin = time.Now()
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time           { return time.Now() }
+func (systemClock) Monotonic() time.Duration { return time.Since(monoOrigin) }
+
+type clockHolder struct{ Clock }
+
+var clock atomic.Value
+
+func init() { clock.Store(clockHolder{SystemClock}) }
+
+// SetClock sets the clock used for stamping samples, such as a fake
+// clock for tests or a clock disciplined by PTP.
+// A nil c restores SystemClock.
+func SetClock(c Clock) {
+	if c == nil {
+		c = SystemClock
+	}
+	clock.Store(clockHolder{c})
+}
+
+func currentClock() Clock { return clock.Load().(clockHolder).Clock }
+
+// Stamp sets the wall-clock and monotonic time of s to the current
+// time of the clock set by SetClock.
+// It is for those taking samples on their own.
+func (s *Sample) Stamp() {
+	c := currentClock()
+	s.Time, s.Mono = c.Now(), c.Monotonic()
+}
+
+// elapsed returns the time elapsed from the sample prev to cur,
+// preferring monotonic time when both samples carry it.
+func elapsed(prev, cur *Sample) time.Duration {
+	if prev.Mono != 0 && cur.Mono != 0 {
+		return cur.Mono - prev.Mono
+	}
+	return cur.Time.Sub(prev.Time)
+}
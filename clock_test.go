@@ -0,0 +1,48 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcpinfo_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mikioh/tcpinfo"
+)
+
+type fakeClock struct {
+	wall time.Time
+	mono time.Duration
+}
+
+func (c *fakeClock) Now() time.Time           { return c.wall }
+func (c *fakeClock) Monotonic() time.Duration { return c.mono }
+
+func TestClock(t *testing.T) {
+	clk := &fakeClock{wall: time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC), mono: time.Hour}
+	tcpinfo.SetClock(clk)
+	defer tcpinfo.SetClock(nil)
+
+	prev := &tcpinfo.Sample{Info: &tcpinfo.Info{}}
+	prev.Stamp()
+	if !prev.Time.Equal(clk.wall) || prev.Mono != clk.mono {
+		t.Fatalf("got %v, %v; want %v, %v", prev.Time, prev.Mono, clk.wall, clk.mono)
+	}
+	// A step of wall-clock time backwards must not affect the
+	// duration between samples.
+	clk.wall = clk.wall.Add(-time.Minute)
+	clk.mono += 5 * time.Second
+	cur := &tcpinfo.Sample{Info: &tcpinfo.Info{}}
+	cur.Stamp()
+	if d := tcpinfo.Diff(prev, cur); d.Duration != 5*time.Second {
+		t.Fatalf("got %v; want 5s", d.Duration)
+	}
+
+	tcpinfo.SetClock(nil)
+	var s tcpinfo.Sample
+	s.Stamp()
+	if s.Time.IsZero() || s.Mono <= 0 {
+		t.Fatalf("got %v, %v; want non-zero", s.Time, s.Mono)
+	}
+}
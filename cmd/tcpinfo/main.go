@@ -0,0 +1,195 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Command tcpinfo prints TCP connection information on a socket
+// identified by a process ID and a file descriptor, or by a pair of
+// local and remote addresses.
+//
+// Usage:
+//
+//	tcpinfo -pid 1234 -fd 5 [-json] [-watch 1s] [-format template]
+//	tcpinfo -src 192.0.2.1:443 -dst 192.0.2.2:50000 [-json] [-watch 1s] [-format template]
+//	tcpinfo top [-interval 1s] [-sort retrans|rtt|thru] [-n 20] [-port 443]
+//	tcpinfo record -o file [-binary] [-interval 1s] [-duration 10m] [-port 443] [-rport 0]
+//	tcpinfo replay [-stall 2s] [-format auto|json|binary] file
+//
+// The top subcommand shows a live view of established connections on
+// the host sorted by retransmission rate, round-trip time or
+// throughput.
+//
+// The record subcommand appends samples of established connections
+// to a file in JSON lines, or in length-delimited protocol buffers
+// with -binary, for later analysis.
+// A binary file starts with a header that tells it from JSON lines,
+// and record refuses to append samples of one format to a file of
+// the other.
+// The replay subcommand reads a recorded file and prints round-trip
+// time percentiles, retransmissions and transferred bytes per
+// connection, and periods in which a connection made no progress in
+// sending while data was queued.
+// Its -format flag selects the format of the file, which is told by
+// the header by default; -format binary also reads files written
+// without the header.
+//
+// The information is retrieved via the Linux sock_diag netlink
+// interface, which allows inspection of sockets owned by other
+// processes.
+// The -format flag takes a template of tcpinfo.Formatter, such as
+// '{{.State}} {{ms .RTT}}ms {{rate .Stats.DeliveryRate}}'.
+//
+// Only supported on Linux.
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mikioh/tcpinfo"
+	"github.com/mikioh/tcpinfo/sockdiag"
+)
+
+const defaultFormat = `{{.Time.Format "15:04:05.000"}} {{.State}} rtt={{ms .RTT}}ms rttvar={{ms .RTTVar}}ms rto={{ms .RTO}}ms mss={{.SenderMSS}}` +
+	`{{with .CongestionControl}} cwnd={{.SenderWindowSegs}} ssthresh={{.SenderSSThreshold}}{{end}}` +
+	` retrans={{.Stats.RetransSegs}} delivery_rate={{rate .Stats.DeliveryRate}}` + "\n"
+
+var (
+	pid    = flag.Int("pid", 0, "process ID owning the socket")
+	fd     = flag.Int("fd", -1, "file descriptor of the socket in the process")
+	src    = flag.String("src", "", "local address of the socket, such as 192.0.2.1:443")
+	dst    = flag.String("dst", "", "remote address of the socket, such as 192.0.2.2:50000")
+	asJSON = flag.Bool("json", false, "print connection information in JSON")
+	watch  = flag.Duration("watch", 0, "print connection information every interval until the socket is gone")
+	format = flag.String("format", defaultFormat, "template for printing connection information")
+)
+
+// A record represents connection information printed in JSON.
+type record struct {
+	Version   int                   `json:"v"`
+	Time      time.Time             `json:"time"`
+	Local     string                `json:"laddr"`
+	Remote    string                `json:"raddr"`
+	UID       uint32                `json:"uid"`
+	Inode     uint32                `json:"inode"`
+	CCAlgo    string                `json:"cc_algo,omitempty"`
+	SendQueue uint                  `json:"snd_queue"`
+	Info      *tcpinfo.Info         `json:"info"`
+	Stats     *tcpinfo.DerivedStats `json:"stats"`
+}
+
+func main() {
+	var err error
+	var sub string
+	if len(os.Args) > 1 {
+		sub = os.Args[1]
+	}
+	switch sub {
+	case "top":
+		err = runTop(os.Args[2:])
+	case "record":
+		err = runRecord(os.Args[2:])
+	case "replay":
+		err = runReplay(os.Args[2:])
+	default:
+		flag.Parse()
+		err = run()
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "tcpinfo:", err)
+		os.Exit(1)
+	}
+}
+
+func run() error {
+	lookup, err := lookupFunc()
+	if err != nil {
+		flag.Usage()
+		return err
+	}
+	f, err := tcpinfo.NewFormatter(*format)
+	if err != nil {
+		return err
+	}
+	c, err := sockdiag.Dial()
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	enc := json.NewEncoder(os.Stdout)
+	for {
+		ci, err := lookup(c)
+		if err != nil {
+			return err
+		}
+		if ci.Info == nil {
+			return errors.New("connection information not reported")
+		}
+		now := time.Now()
+		if *asJSON {
+			err = enc.Encode(&record{Version: tcpinfo.SchemaVersion, Time: now, Local: ci.LocalAddr.String(), Remote: ci.RemoteAddr.String(), UID: ci.UID, Inode: ci.Inode, CCAlgo: ci.CCAlgo, SendQueue: ci.SendQueue, Info: ci.Info, Stats: ci.Info.Stats()})
+		} else {
+			err = f.Format(os.Stdout, &tcpinfo.Sample{Time: now, Info: ci.Info})
+		}
+		if err != nil {
+			return err
+		}
+		if *watch <= 0 {
+			return nil
+		}
+		time.Sleep(*watch)
+	}
+}
+
+// lookupFunc returns a function looking up the socket specified by
+// the flags.
+func lookupFunc() (func(*sockdiag.Conn) (*sockdiag.ConnInfo, error), error) {
+	switch {
+	case *pid > 0 && *fd >= 0:
+		ino, err := socketInode(*pid, *fd)
+		if err != nil {
+			return nil, err
+		}
+		return func(c *sockdiag.Conn) (*sockdiag.ConnInfo, error) { return c.LookupInode(ino) }, nil
+	case *src != "" && *dst != "":
+		laddr, err := net.ResolveTCPAddr("tcp", *src)
+		if err != nil {
+			return nil, err
+		}
+		raddr, err := net.ResolveTCPAddr("tcp", *dst)
+		if err != nil {
+			return nil, err
+		}
+		return func(c *sockdiag.Conn) (*sockdiag.ConnInfo, error) { return c.Lookup(laddr, raddr) }, nil
+	}
+	return nil, errors.New("either -pid and -fd, or -src and -dst required")
+}
+
+// socketInode returns the inode number of the socket referred to by
+// the file descriptor fd of the process pid.
+func socketInode(pid, fd int) (uint32, error) {
+	link, err := os.Readlink("/proc/" + strconv.Itoa(pid) + "/fd/" + strconv.Itoa(fd))
+	if err != nil {
+		return 0, err
+	}
+	return parseSocketLink(link)
+}
+
+// parseSocketLink parses the target of a symbolic link to a socket
+// in /proc, such as "socket:[12345]".
+func parseSocketLink(link string) (uint32, error) {
+	if !strings.HasPrefix(link, "socket:[") || !strings.HasSuffix(link, "]") {
+		return 0, fmt.Errorf("not a socket: %s", link)
+	}
+	n, err := strconv.ParseUint(link[len("socket:["):len(link)-1], 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("not a socket: %s", link)
+	}
+	return uint32(n), nil
+}
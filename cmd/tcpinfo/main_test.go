@@ -0,0 +1,69 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"net"
+	"os"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/mikioh/tcpinfo"
+	"github.com/mikioh/tcpinfo/tcpinfotest"
+)
+
+func TestParseSocketLink(t *testing.T) {
+	for _, tt := range []struct {
+		link string
+		ino  uint32
+		ok   bool
+	}{
+		{"socket:[12345]", 12345, true},
+		{"socket:[]", 0, false},
+		{"socket:[4294967296]", 0, false},
+		{"pipe:[12345]", 0, false},
+		{"/dev/null", 0, false},
+	} {
+		ino, err := parseSocketLink(tt.link)
+		if ino != tt.ino || (err == nil) != tt.ok {
+			t.Fatalf("%s: got %d, %v; want %d, %v", tt.link, ino, err, tt.ino, tt.ok)
+		}
+	}
+}
+
+func TestSocketInode(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skipf("%s/%s", runtime.GOOS, runtime.GOARCH)
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	f, err := ln.(*net.TCPListener).File()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if ino, err := socketInode(os.Getpid(), int(f.Fd())); err != nil || ino == 0 {
+		t.Fatalf("got %d, %v", ino, err)
+	}
+}
+
+func TestDefaultFormat(t *testing.T) {
+	f, err := tcpinfo.NewFormatter(defaultFormat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b bytes.Buffer
+	if err := f.Format(&b, &tcpinfo.Sample{Time: time.Now(), Info: tcpinfotest.NewInfo().Build()}); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(b.Bytes(), []byte("established rtt=10.000ms")) {
+		t.Fatalf("got %q", b.String())
+	}
+}
@@ -0,0 +1,168 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/mikioh/tcpinfo"
+	"github.com/mikioh/tcpinfo/sockdiag"
+	"github.com/mikioh/tcpinfo/tcpinfopb"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// An entry represents a recorded sample of connection information.
+type entry struct {
+	Time      time.Time
+	Local     string
+	Remote    string
+	Inode     uint32
+	State     string
+	RTT       time.Duration
+	SendQueue uint
+	Stats     tcpinfo.DerivedStats
+}
+
+func runRecord(args []string) error {
+	fs := flag.NewFlagSet("record", flag.ExitOnError)
+	out := fs.String("o", "", "file to which samples are appended")
+	binary := fs.Bool("binary", false, "record length-delimited protocol buffers instead of JSON lines")
+	interval := fs.Duration("interval", time.Second, "sampling interval")
+	dur := fs.Duration("duration", 0, "recording duration; zero means until interrupted")
+	port := fs.Int("port", 0, "local port of connections recorded; zero means any")
+	rport := fs.Int("rport", 0, "remote port of connections recorded; zero means any")
+	fs.Parse(args)
+	if *out == "" {
+		fs.Usage()
+		return errors.New("-o required")
+	}
+	f, err := os.OpenFile(*out, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := prepareOutput(f, *binary); err != nil {
+		return fmt.Errorf("%s: %v", *out, err)
+	}
+	w := bufio.NewWriter(f)
+	defer w.Flush()
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt)
+	defer signal.Stop(sig)
+	var end <-chan time.Time
+	if *dur > 0 {
+		end = time.After(*dur)
+	}
+	t := time.NewTicker(*interval)
+	defer t.Stop()
+	filter := &sockdiag.Filter{States: []tcpinfo.State{tcpinfo.Established}, LocalPort: *port, RemotePort: *rport}
+	for {
+		cis, err := sockdiag.ListConnections(filter)
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		for j := range cis {
+			if cis[j].Info == nil {
+				continue
+			}
+			if *binary {
+				err = writeFrame(w, now, &cis[j])
+			} else {
+				err = writeJSONLine(w, now, &cis[j])
+			}
+			if err != nil {
+				return err
+			}
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+		select {
+		case <-t.C:
+		case <-end:
+			return nil
+		case <-sig:
+			return nil
+		}
+	}
+}
+
+// frameMagic starts the files of length-delimited protocol buffers
+// written by record, which tells them from the files of JSON lines
+// regardless of the length of the first frame.
+const frameMagic = "tcpinfo frames\n"
+
+// prepareOutput makes f ready for appending samples in the binary
+// format or not.
+// It writes frameMagic to an empty file for the binary format, and
+// refuses to append to a file holding samples of the other format.
+func prepareOutput(f *os.File, binary bool) error {
+	head := make([]byte, len(frameMagic))
+	n, err := f.ReadAt(head, 0)
+	if err != nil && err != io.EOF {
+		return err
+	}
+	if n == 0 {
+		if !binary {
+			return nil
+		}
+		_, err := io.WriteString(f, frameMagic)
+		return err
+	}
+	if framed := string(head[:n]) == frameMagic; framed != binary {
+		if framed {
+			return errors.New("holds binary samples; use -binary")
+		}
+		return errors.New("holds JSON lines or headerless binary samples; use another file")
+	}
+	return nil
+}
+
+func writeJSONLine(w io.Writer, now time.Time, ci *sockdiag.ConnInfo) error {
+	b, err := json.Marshal(&record{Version: tcpinfo.SchemaVersion, Time: now, Local: ci.LocalAddr.String(), Remote: ci.RemoteAddr.String(), UID: ci.UID, Inode: ci.Inode, CCAlgo: ci.CCAlgo, SendQueue: ci.SendQueue, Info: ci.Info, Stats: ci.Info.Stats()})
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(b, '\n'))
+	return err
+}
+
+// writeFrame writes a sample as a length-delimited message of the
+// following fields:
+//
+//	1: sample encoded by package tcpinfopb
+//	2: local address
+//	3: remote address
+//	4: inode number
+//	5: send queue length
+func writeFrame(w io.Writer, now time.Time, ci *sockdiag.ConnInfo) error {
+	smp, err := tcpinfopb.Marshal(&tcpinfo.Sample{Time: now, Info: ci.Info})
+	if err != nil {
+		return err
+	}
+	var m []byte
+	m = protowire.AppendTag(m, 1, protowire.BytesType)
+	m = protowire.AppendBytes(m, smp)
+	m = protowire.AppendTag(m, 2, protowire.BytesType)
+	m = protowire.AppendString(m, ci.LocalAddr.String())
+	m = protowire.AppendTag(m, 3, protowire.BytesType)
+	m = protowire.AppendString(m, ci.RemoteAddr.String())
+	m = protowire.AppendTag(m, 4, protowire.VarintType)
+	m = protowire.AppendVarint(m, uint64(ci.Inode))
+	m = protowire.AppendTag(m, 5, protowire.VarintType)
+	m = protowire.AppendVarint(m, uint64(ci.SendQueue))
+	_, err = w.Write(protowire.AppendBytes(nil, m))
+	return err
+}
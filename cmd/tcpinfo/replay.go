@@ -0,0 +1,286 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/mikioh/tcpinfo"
+	"github.com/mikioh/tcpinfo/tcpinfopb"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// readEntries reads recorded samples from r in format, which is one
+// of "json" for JSON lines, "binary" for length-delimited protocol
+// buffers with or without frameMagic, and "auto" for either,
+// distinguished by frameMagic.
+func readEntries(r io.Reader, format string) ([]*entry, error) {
+	switch format {
+	case "auto", "json", "binary":
+	default:
+		return nil, errors.New("unknown format: " + format)
+	}
+	br := bufio.NewReader(r)
+	b, err := br.Peek(len(frameMagic))
+	if len(b) == 0 && err == io.EOF {
+		return nil, nil
+	}
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	framed := string(b) == frameMagic
+	if format == "json" || format == "auto" && !framed {
+		return readJSONLines(br)
+	}
+	if framed {
+		br.Discard(len(frameMagic))
+	}
+	b, err = ioutil.ReadAll(br)
+	if err != nil {
+		return nil, err
+	}
+	return readFrames(b)
+}
+
+func readJSONLines(r io.Reader) ([]*entry, error) {
+	var es []*entry
+	dec := json.NewDecoder(r)
+	for {
+		var rec struct {
+			Time      time.Time `json:"time"`
+			Local     string    `json:"laddr"`
+			Remote    string    `json:"raddr"`
+			Inode     uint32    `json:"inode"`
+			SendQueue uint      `json:"snd_queue"`
+			Info      struct {
+				State string `json:"state"`
+				RTT   int64  `json:"rtt"`
+			} `json:"info"`
+			Stats tcpinfo.DerivedStats `json:"stats"`
+		}
+		var line json.RawMessage
+		err := dec.Decode(&line)
+		if err == io.EOF {
+			return es, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if line, err = tcpinfo.UpgradeJSON(line); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(line, &rec); err != nil {
+			return nil, err
+		}
+		es = append(es, &entry{Time: rec.Time, Local: rec.Local, Remote: rec.Remote, Inode: rec.Inode, State: rec.Info.State, RTT: time.Duration(rec.Info.RTT), SendQueue: rec.SendQueue, Stats: rec.Stats})
+	}
+}
+
+var errMalformedFrame = errors.New("malformed frame")
+
+func readFrames(b []byte) ([]*entry, error) {
+	var es []*entry
+	for len(b) > 0 {
+		m, n := protowire.ConsumeBytes(b)
+		if n < 0 {
+			return nil, errMalformedFrame
+		}
+		b = b[n:]
+		e := new(entry)
+		for len(m) > 0 {
+			num, typ, n := protowire.ConsumeTag(m)
+			if n < 0 {
+				return nil, errMalformedFrame
+			}
+			m = m[n:]
+			var v uint64
+			var f []byte
+			switch typ {
+			case protowire.VarintType:
+				v, n = protowire.ConsumeVarint(m)
+			case protowire.BytesType:
+				f, n = protowire.ConsumeBytes(m)
+			default:
+				n = protowire.ConsumeFieldValue(num, typ, m)
+			}
+			if n < 0 {
+				return nil, errMalformedFrame
+			}
+			m = m[n:]
+			switch num {
+			case 1:
+				s, err := tcpinfopb.Unmarshal(f)
+				if err != nil {
+					return nil, err
+				}
+				e.Time = s.Time
+				if s.Info != nil {
+					e.State, e.RTT = s.Info.State.String(), s.Info.RTT
+				}
+				if s.Stats != nil {
+					e.Stats = *s.Stats
+				}
+			case 2:
+				e.Local = string(f)
+			case 3:
+				e.Remote = string(f)
+			case 4:
+				e.Inode = uint32(v)
+			case 5:
+				e.SendQueue = uint(v)
+			}
+		}
+		es = append(es, e)
+	}
+	return es, nil
+}
+
+func runReplay(args []string) error {
+	fs := flag.NewFlagSet("replay", flag.ExitOnError)
+	stall := fs.Duration("stall", 2*time.Second, "minimum duration without progress in sending while data is queued reported as a stall")
+	format := fs.String("format", "auto", "format of the recorded file: auto, json or binary; auto tells binary files by their header")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("a recorded file required")
+	}
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	es, err := readEntries(f, *format)
+	if err != nil {
+		return fmt.Errorf("%s: %v", fs.Arg(0), err)
+	}
+	analyze(os.Stdout, es, *stall)
+	return nil
+}
+
+// A connSummary represents the analysis of samples recorded on a
+// connection.
+type connSummary struct {
+	Local    string
+	Remote   string
+	Samples  int
+	Duration time.Duration
+	RTT      [4]time.Duration // 50th, 90th, 99th percentiles and maximum
+	Retrans  uint64
+	Sent     uint64
+	Received uint64
+	Stalls   []stall
+}
+
+// A stall represents a period in which a connection made no progress
+// in sending while data was queued.
+type stall struct {
+	Start    time.Time
+	Duration time.Duration
+	Retrans  uint64 // # of segments retransmitted during the period
+}
+
+// summarize returns the analyses of samples in es per connection, in
+// the order of first appearance.
+// Periods without progress lasting at least min are reported as
+// stalls.
+func summarize(es []*entry, min time.Duration) []*connSummary {
+	var keys []string
+	conns := make(map[string][]*entry)
+	for _, e := range es {
+		k := e.Local + " " + e.Remote
+		if _, ok := conns[k]; !ok {
+			keys = append(keys, k)
+		}
+		conns[k] = append(conns[k], e)
+	}
+	css := make([]*connSummary, 0, len(keys))
+	for _, k := range keys {
+		es := conns[k]
+		sort.SliceStable(es, func(i, j int) bool { return es[i].Time.Before(es[j].Time) })
+		first, last := es[0], es[len(es)-1]
+		cs := &connSummary{
+			Local:    first.Local,
+			Remote:   first.Remote,
+			Samples:  len(es),
+			Duration: last.Time.Sub(first.Time),
+			Retrans:  sub(last.Stats.RetransSegs, first.Stats.RetransSegs),
+			Sent:     sub(last.Stats.BytesSent, first.Stats.BytesSent),
+			Received: sub(last.Stats.BytesReceived, first.Stats.BytesReceived),
+			Stalls:   stalls(es, min),
+		}
+		rtts := make([]time.Duration, len(es))
+		for j, e := range es {
+			rtts[j] = e.RTT
+		}
+		sort.Slice(rtts, func(i, j int) bool { return rtts[i] < rtts[j] })
+		for j, p := range []int{50, 90, 99, 100} {
+			cs.RTT[j] = rtts[(p*len(rtts)+99)/100-1]
+		}
+		css = append(css, cs)
+	}
+	return css
+}
+
+// stalls returns the periods in which the bytes sent did not advance
+// while the send queue was not empty, lasting at least min.
+func stalls(es []*entry, min time.Duration) []stall {
+	var ss []stall
+	var start *entry
+	for j, e := range es {
+		if start != nil && (e.SendQueue == 0 || e.Stats.BytesSent != start.Stats.BytesSent) {
+			if d := es[j-1].Time.Sub(start.Time); d >= min {
+				ss = append(ss, stall{Start: start.Time, Duration: d, Retrans: sub(es[j-1].Stats.RetransSegs, start.Stats.RetransSegs)})
+			}
+			start = nil
+		}
+		if start == nil && e.SendQueue > 0 {
+			start = e
+		}
+	}
+	if start != nil {
+		last := es[len(es)-1]
+		if d := last.Time.Sub(start.Time); d >= min {
+			ss = append(ss, stall{Start: start.Time, Duration: d, Retrans: sub(last.Stats.RetransSegs, start.Stats.RetransSegs)})
+		}
+	}
+	return ss
+}
+
+func sub(c, p uint64) uint64 {
+	if c < p {
+		return 0
+	}
+	return c - p
+}
+
+func analyze(w io.Writer, es []*entry, min time.Duration) {
+	css := summarize(es, min)
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprint(tw, "LOCAL\tREMOTE\tSAMPLES\tDURATION\tRTT P50(ms)\tP90\tP99\tMAX\tRETRANS\tSENT\tRCVD\tSTALLS\t\n")
+	for _, cs := range css {
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%v\t%.3f\t%.3f\t%.3f\t%.3f\t%d\t%d\t%d\t%d\t\n",
+			cs.Local, cs.Remote, cs.Samples, cs.Duration,
+			ms(cs.RTT[0]), ms(cs.RTT[1]), ms(cs.RTT[2]), ms(cs.RTT[3]),
+			cs.Retrans, cs.Sent, cs.Received, len(cs.Stalls))
+	}
+	tw.Flush()
+	for _, cs := range css {
+		for _, s := range cs.Stalls {
+			fmt.Fprintf(w, "stall: %s %s at %s for %v, %d segments retransmitted\n", cs.Local, cs.Remote, s.Start.Format("15:04:05.000"), s.Duration, s.Retrans)
+		}
+	}
+}
+
+func ms(d time.Duration) float64 { return float64(d) / float64(time.Millisecond) }
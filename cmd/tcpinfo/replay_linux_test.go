@@ -0,0 +1,155 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func TestRecordReplay(t *testing.T) {
+	start := time.Date(2016, 1, 2, 3, 4, 5, 0, time.UTC)
+	steps := []struct {
+		rtt     time.Duration
+		retrans uint
+		acked   uint64
+		queued  uint
+	}{
+		{10 * time.Millisecond, 0, 1000, 0},
+		{20 * time.Millisecond, 0, 2000, 100},
+		{30 * time.Millisecond, 1, 2000, 100},
+		{40 * time.Millisecond, 2, 2000, 100},
+		{50 * time.Millisecond, 3, 2000, 100},
+		{60 * time.Millisecond, 3, 5000, 0},
+	}
+	for _, binary := range []bool{false, true} {
+		var b bytes.Buffer
+		if binary {
+			b.WriteString(frameMagic)
+		}
+		for j, st := range steps {
+			ci := topConnInfo(1, st.rtt, st.retrans, st.acked)
+			ci.SendQueue = st.queued
+			now := start.Add(time.Duration(j) * time.Second)
+			var err error
+			if binary {
+				err = writeFrame(&b, now, &ci)
+			} else {
+				err = writeJSONLine(&b, now, &ci)
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		es, err := readEntries(&b, "auto")
+		if err != nil {
+			t.Fatalf("binary=%v: %v", binary, err)
+		}
+		if len(es) != len(steps) {
+			t.Fatalf("binary=%v: got %d entries; want %d", binary, len(es), len(steps))
+		}
+		if e := es[2]; !e.Time.Equal(start.Add(2*time.Second)) || e.Local != "192.0.2.1:443" || e.Remote != "192.0.2.2:1" || e.Inode != 1 || e.State != "established" || e.RTT != 30*time.Millisecond || e.SendQueue != 100 || e.Stats.RetransSegs != 1 {
+			t.Fatalf("binary=%v: got %+v", binary, e)
+		}
+
+		css := summarize(es, 2*time.Second)
+		if len(css) != 1 {
+			t.Fatalf("binary=%v: got %d connections; want 1", binary, len(css))
+		}
+		cs := css[0]
+		if cs.Samples != 6 || cs.Duration != 5*time.Second || cs.Retrans != 3 || cs.Sent != 4000 {
+			t.Fatalf("binary=%v: got %+v", binary, cs)
+		}
+		if want := [4]time.Duration{30 * time.Millisecond, 60 * time.Millisecond, 60 * time.Millisecond, 60 * time.Millisecond}; cs.RTT != want {
+			t.Fatalf("binary=%v: got %v; want %v", binary, cs.RTT, want)
+		}
+		if len(cs.Stalls) != 1 || !cs.Stalls[0].Start.Equal(start.Add(time.Second)) || cs.Stalls[0].Duration != 3*time.Second || cs.Stalls[0].Retrans != 3 {
+			t.Fatalf("binary=%v: got %+v", binary, cs.Stalls)
+		}
+		if css := summarize(es, 4*time.Second); len(css[0].Stalls) != 0 {
+			t.Fatalf("binary=%v: got %+v; want no stalls", binary, css[0].Stalls)
+		}
+	}
+}
+
+func TestReadEntriesMalformed(t *testing.T) {
+	if _, err := readEntries(bytes.NewReader([]byte{0x05, 0x0a}), "binary"); err == nil {
+		t.Fatal("got nil; want an error")
+	}
+	if es, err := readEntries(bytes.NewReader(nil), "auto"); err != nil || len(es) != 0 {
+		t.Fatalf("got %v, %v", es, err)
+	}
+	if _, err := readEntries(bytes.NewReader(nil), "xml"); err == nil {
+		t.Fatal("got nil; want an error for unknown format")
+	}
+}
+
+func TestReadEntriesFormat(t *testing.T) {
+	// A frame of 123 bytes starts with '{' as its length.
+	m := protowire.AppendTag(nil, 2, protowire.BytesType)
+	m = protowire.AppendString(m, strings.Repeat("x", 121))
+	frame := protowire.AppendBytes(nil, m)
+	if frame[0] != '{' {
+		t.Fatalf("got %#x; want '{'", frame[0])
+	}
+	for _, b := range [][]byte{append([]byte(frameMagic), frame...), frame} {
+		format := "auto"
+		if len(b) == len(frame) {
+			format = "binary"
+		}
+		es, err := readEntries(bytes.NewReader(b), format)
+		if err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		if len(es) != 1 || len(es[0].Local) != 121 {
+			t.Fatalf("%s: got %+v", format, es)
+		}
+	}
+}
+
+func TestPrepareOutput(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tcpinfo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, binary := range []bool{false, true} {
+		name := filepath.Join(dir, fmt.Sprintf("binary-%v", binary))
+		f, err := os.OpenFile(name, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		if err := prepareOutput(f, binary); err != nil {
+			t.Fatal(err)
+		}
+		if !binary {
+			if _, err := f.WriteString("{}\n"); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := prepareOutput(f, binary); err != nil {
+			t.Fatalf("binary=%v: %v", binary, err)
+		}
+		if err := prepareOutput(f, !binary); err == nil {
+			t.Fatalf("binary=%v: got nil; want an error for the other format", binary)
+		}
+		b, err := ioutil.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if (string(b) == frameMagic) != binary {
+			t.Fatalf("binary=%v: got %q", binary, b)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/mikioh/tcpinfo"
+	"github.com/mikioh/tcpinfo/sockdiag"
+)
+
+// A topRow represents a connection shown by the top subcommand.
+type topRow struct {
+	ci      *sockdiag.ConnInfo
+	retrans float64 // retransmitted segments per second
+	send    float64 // sending rate in bytes per second
+	recv    float64 // receiving rate in bytes per second
+}
+
+// topKeys holds the functions comparing rows by sort key, in
+// descending order.
+var topKeys = map[string]func(a, b *topRow) bool{
+	"retrans": func(a, b *topRow) bool { return a.retrans > b.retrans },
+	"rtt":     func(a, b *topRow) bool { return a.ci.Info.RTT > b.ci.Info.RTT },
+	"thru":    func(a, b *topRow) bool { return a.send+a.recv > b.send+b.recv },
+}
+
+func runTop(args []string) error {
+	fs := flag.NewFlagSet("top", flag.ExitOnError)
+	interval := fs.Duration("interval", time.Second, "refresh interval")
+	key := fs.String("sort", "retrans", "sort key: retrans, rtt or thru")
+	n := fs.Int("n", 20, "# of connections shown")
+	port := fs.Int("port", 0, "local port of connections shown; zero means any")
+	count := fs.Int("count", 0, "# of refreshes; zero means until interrupted")
+	fs.Parse(args)
+	less, ok := topKeys[*key]
+	if !ok {
+		return fmt.Errorf("unknown sort key: %s", *key)
+	}
+	f := &sockdiag.Filter{States: []tcpinfo.State{tcpinfo.Established}, LocalPort: *port}
+	prev := make(map[uint32]*tcpinfo.Sample)
+	for k := 0; *count == 0 || k < *count; k++ {
+		if k > 0 {
+			time.Sleep(*interval)
+		}
+		cis, err := sockdiag.ListConnections(f)
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		rows := topRows(cis, prev, now)
+		sort.SliceStable(rows, func(i, j int) bool { return less(rows[i], rows[j]) })
+		if len(rows) > *n {
+			rows = rows[:*n]
+		}
+		fmt.Fprint(os.Stdout, "\x1b[H\x1b[2J")
+		renderTop(os.Stdout, now, len(cis), *key, rows)
+	}
+	return nil
+}
+
+// topRows returns the rows of connections in cis with rates since
+// the previous samples in prev, and replaces prev with the samples
+// taken at now.
+func topRows(cis []sockdiag.ConnInfo, prev map[uint32]*tcpinfo.Sample, now time.Time) []*topRow {
+	rows := make([]*topRow, 0, len(cis))
+	cur := make(map[uint32]*tcpinfo.Sample, len(cis))
+	for j := range cis {
+		ci := &cis[j]
+		if ci.Info == nil {
+			continue
+		}
+		s := &tcpinfo.Sample{Time: now, Info: ci.Info}
+		cur[ci.Inode] = s
+		r := &topRow{ci: ci}
+		if d := tcpinfo.Diff(prev[ci.Inode], s); d != nil && d.Duration > 0 {
+			r.retrans = float64(d.RetransSegs) / d.Duration.Seconds()
+			r.send, r.recv = d.SendRate(), d.ReceiveRate()
+		}
+		rows = append(rows, r)
+	}
+	for ino := range prev {
+		delete(prev, ino)
+	}
+	for ino, s := range cur {
+		prev[ino] = s
+	}
+	return rows
+}
+
+func renderTop(w io.Writer, now time.Time, total int, key string, rows []*topRow) {
+	fmt.Fprintf(w, "%s  %d connections, sorted by %s\n\n", now.Format("15:04:05"), total, key)
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprint(tw, "LOCAL\tREMOTE\tRTT(ms)\tCWND\tRETRANS/s\tSEND\tRECV\tCC\t\n")
+	for _, r := range rows {
+		var cwnd uint
+		if cc := r.ci.Info.CongestionControl; cc != nil {
+			cwnd = cc.SenderWindowSegs
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%.3f\t%d\t%.1f\t%s\t%s\t%s\t\n",
+			r.ci.LocalAddr, r.ci.RemoteAddr, float64(r.ci.Info.RTT)/float64(time.Millisecond), cwnd,
+			r.retrans, bitRate(r.send), bitRate(r.recv), r.ci.CCAlgo)
+	}
+	tw.Flush()
+}
+
+// bitRate returns the rate in bytes per second v in bits per second
+// with a unit.
+func bitRate(v float64) string {
+	v *= 8
+	for _, u := range []string{"bps", "Kbps", "Mbps", "Gbps"} {
+		if v < 1000 || u == "Gbps" {
+			return fmt.Sprintf("%.1f%s", v, u)
+		}
+		v /= 1000
+	}
+	return ""
+}
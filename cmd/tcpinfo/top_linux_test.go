@@ -0,0 +1,71 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"net"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mikioh/tcpinfo"
+	"github.com/mikioh/tcpinfo/sockdiag"
+	"github.com/mikioh/tcpinfo/tcpinfotest"
+)
+
+func topConnInfo(ino uint32, rtt time.Duration, retrans uint, acked uint64) sockdiag.ConnInfo {
+	return sockdiag.ConnInfo{
+		LocalAddr:  &net.TCPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 443},
+		RemoteAddr: &net.TCPAddr{IP: net.IPv4(192, 0, 2, 2), Port: int(ino)},
+		Inode:      ino,
+		Info: tcpinfotest.NewInfo().RTT(rtt, 0).Sys(func(si *tcpinfo.SysInfo) {
+			si.TotalRetransSegs = retrans
+			si.ThruBytesAcked = acked
+		}).Build(),
+	}
+}
+
+func TestTopRows(t *testing.T) {
+	prev := make(map[uint32]*tcpinfo.Sample)
+	now := time.Now()
+	topRows([]sockdiag.ConnInfo{
+		topConnInfo(1, 10*time.Millisecond, 0, 0),
+		topConnInfo(2, 50*time.Millisecond, 0, 0),
+		topConnInfo(3, 20*time.Millisecond, 0, 0),
+	}, prev, now)
+	rows := topRows([]sockdiag.ConnInfo{
+		topConnInfo(1, 10*time.Millisecond, 4, 1000000),
+		topConnInfo(2, 50*time.Millisecond, 2, 0),
+		topConnInfo(4, 30*time.Millisecond, 9, 0),
+	}, prev, now.Add(2*time.Second))
+	if len(prev) != 3 || prev[3] != nil {
+		t.Fatalf("got %v", prev)
+	}
+	for key, want := range map[string][]uint32{
+		"retrans": {1, 2, 4},
+		"rtt":     {2, 4, 1},
+		"thru":    {1, 2, 4},
+	} {
+		less := topKeys[key]
+		sort.SliceStable(rows, func(i, j int) bool { return less(rows[i], rows[j]) })
+		for k, r := range rows {
+			if r.ci.Inode != want[k] {
+				t.Fatalf("%s: got %d at %d; want %d", key, r.ci.Inode, k, want[k])
+			}
+		}
+	}
+	sort.SliceStable(rows, func(i, j int) bool { return topKeys["thru"](rows[i], rows[j]) })
+	if rows[0].retrans != 2 || rows[0].send != 500000 {
+		t.Fatalf("got %+v", rows[0])
+	}
+
+	var b bytes.Buffer
+	renderTop(&b, now, 3, "thru", rows)
+	if !strings.Contains(b.String(), "4.0Mbps") {
+		t.Fatalf("got %s", b.String())
+	}
+}
@@ -0,0 +1,116 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcpinfo
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// A Conn represents a connection instrumented with a sampler.
+type Conn struct {
+	net.Conn
+	s  *Sampler
+	fn FinalFunc
+
+	once sync.Once
+	err  error // error on close
+}
+
+// NewConn returns a new instrumented connection that takes a sample
+// of connection information on c every d and invokes fn with it.
+// The final sample is taken when the connection is closed.
+func NewConn(c net.Conn, d time.Duration, fn SampleFunc) *Conn {
+	ic := &Conn{Conn: c}
+	ic.s = NewSampler(ic, d, fn)
+	return ic
+}
+
+// SyscallConn implements the SyscallConn method of syscall.Conn
+// interface.
+func (c *Conn) SyscallConn() (syscall.RawConn, error) {
+	sc, ok := c.Conn.(syscall.Conn)
+	if !ok {
+		return nil, errors.New("not a syscall.Conn")
+	}
+	return sc.SyscallConn()
+}
+
+// FinalStats returns a summary of connection information captured
+// when the connection was closed.
+// It returns nil when the connection is not closed yet.
+func (c *Conn) FinalStats() *FinalStats {
+	c.s.mu.Lock()
+	defer c.s.mu.Unlock()
+	return c.s.fs
+}
+
+// Close takes the final sample and closes the connection.
+// Subsequent calls return the error of the first call.
+func (c *Conn) Close() error {
+	c.once.Do(func() {
+		fs := c.s.Stop()
+		c.err = c.Conn.Close()
+		if c.fn != nil {
+			c.fn(c, fs)
+		}
+	})
+	return c.err
+}
+
+// A Dialer wraps net.Dialer and attaches a sampler to every new
+// connection.
+type Dialer struct {
+	net.Dialer
+	Interval  time.Duration // sampling interval; zero means final sample only
+	Func      SampleFunc    // callback function receiving samples
+	FinalFunc FinalFunc     // callback function receiving final statistics
+}
+
+// Dial connects to the address on the named network.
+func (d *Dialer) Dial(network, address string) (net.Conn, error) {
+	return d.DialContext(context.Background(), network, address)
+}
+
+// DialContext connects to the address on the named network using the
+// provided context.
+func (d *Dialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	c, err := d.Dialer.DialContext(ctx, network, address)
+	if err != nil {
+		return nil, err
+	}
+	return instrument(c, d.Interval, d.Func, d.FinalFunc), nil
+}
+
+// A Listener wraps net.Listener and attaches a sampler to every
+// accepted connection.
+type Listener struct {
+	net.Listener
+	Interval  time.Duration // sampling interval; zero means final sample only
+	Func      SampleFunc    // callback function receiving samples
+	FinalFunc FinalFunc     // callback function receiving final statistics
+}
+
+// Accept waits for and returns the next connection to the listener.
+func (ln *Listener) Accept() (net.Conn, error) {
+	c, err := ln.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return instrument(c, ln.Interval, ln.Func, ln.FinalFunc), nil
+}
+
+func instrument(c net.Conn, d time.Duration, fn SampleFunc, ffn FinalFunc) net.Conn {
+	if _, ok := c.(syscall.Conn); !ok || fn == nil && ffn == nil {
+		return c
+	}
+	ic := NewConn(c, d, fn)
+	ic.fn = ffn
+	return ic
+}
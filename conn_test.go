@@ -0,0 +1,209 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcpinfo_test
+
+import (
+	"net"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mikioh/tcpinfo"
+)
+
+func TestDialerAndListener(t *testing.T) {
+	switch runtime.GOOS {
+	case "darwin", "freebsd", "linux", "netbsd":
+	default:
+		t.Skipf("%s/%s", runtime.GOOS, runtime.GOARCH)
+	}
+
+	var mu sync.Mutex
+	var samples []*tcpinfo.Sample
+	fn := func(c net.Conn, s *tcpinfo.Sample) {
+		mu.Lock()
+		samples = append(samples, s)
+		mu.Unlock()
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln = &tcpinfo.Listener{Listener: ln, Interval: 10 * time.Millisecond, Func: fn}
+	defer ln.Close()
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		c, err := ln.Accept()
+		if err != nil {
+			close(accepted)
+			return
+		}
+		accepted <- c
+	}()
+
+	d := &tcpinfo.Dialer{Interval: 10 * time.Millisecond, Func: fn}
+	c, err := d.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Write([]byte("HELLO-R-U-THERE")); err != nil {
+		t.Fatal(err)
+	}
+	ac, ok := <-accepted
+	if !ok {
+		t.Fatal("accept failed")
+	}
+	time.Sleep(50 * time.Millisecond)
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := ac.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	var nfinal int
+	for _, s := range samples {
+		if s.Err != nil {
+			t.Fatal(s.Err)
+		}
+		if s.Info == nil {
+			t.Fatal("got nil info")
+		}
+		if s.Final {
+			nfinal++
+		}
+	}
+	if nfinal != 2 {
+		t.Fatalf("got %d final samples; want 2", nfinal)
+	}
+	if len(samples) <= nfinal {
+		t.Fatalf("got %d samples; want more than %d", len(samples), nfinal)
+	}
+}
+
+func TestFinalStats(t *testing.T) {
+	switch runtime.GOOS {
+	case "darwin", "freebsd", "linux", "netbsd":
+	default:
+		t.Skipf("%s/%s", runtime.GOOS, runtime.GOARCH)
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	ch := make(chan *tcpinfo.FinalStats, 2)
+	d := &tcpinfo.Dialer{FinalFunc: func(c net.Conn, fs *tcpinfo.FinalStats) { ch <- fs }}
+	c, err := d.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fs := c.(*tcpinfo.Conn).FinalStats(); fs != nil {
+		t.Fatalf("got %v; want nil", fs)
+	}
+	for i := 0; i < 2; i++ {
+		if err := c.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(ch) != 1 {
+		t.Fatalf("got %d final stats; want 1", len(ch))
+	}
+	fs := <-ch
+	if fs.Err != nil {
+		t.Fatal(fs.Err)
+	}
+	if fs.Info == nil || fs.Stats == nil {
+		t.Fatalf("got %+v; want non-nil info and stats", fs)
+	}
+	if fs.Duration <= 0 {
+		t.Fatalf("got %v; want greater than zero", fs.Duration)
+	}
+	if fs != c.(*tcpinfo.Conn).FinalStats() {
+		t.Fatal("final stats mismatch")
+	}
+}
+
+func TestGetSocketOptions(t *testing.T) {
+	switch runtime.GOOS {
+	case "darwin", "freebsd", "linux", "netbsd":
+	default:
+		t.Skipf("%s/%s", runtime.GOOS, runtime.GOARCH)
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	c, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	i, err := tcpinfo.Get(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, opt := range i.Options {
+		switch opt.(type) {
+		case tcpinfo.NoDelay, tcpinfo.CCAlgorithm, tcpinfo.KeepAlive:
+			t.Fatalf("got %v; want no socket options without FieldSockOpts", i.Options)
+		}
+	}
+
+	for _, nodelay := range []bool{true, false} {
+		if err := c.(*net.TCPConn).SetNoDelay(nodelay); err != nil {
+			t.Fatal(err)
+		}
+		var i tcpinfo.Info
+		if err := tcpinfo.GetFields(c, &i, tcpinfo.FieldAll|tcpinfo.FieldSockOpts); err != nil {
+			t.Fatal(err)
+		}
+		opts := make(map[tcpinfo.OptionKind]tcpinfo.Option)
+		for _, opt := range i.Options {
+			opts[opt.Kind()] = opt
+		}
+		if opts[tcpinfo.KindNoDelay] != tcpinfo.NoDelay(nodelay) {
+			t.Fatalf("got %v; want %v", opts[tcpinfo.KindNoDelay], tcpinfo.NoDelay(nodelay))
+		}
+		if _, ok := opts[tcpinfo.KindQuickAck]; ok != (runtime.GOOS == "linux") {
+			t.Fatalf("got %v", i.Options)
+		}
+		if cc, ok := opts[tcpinfo.KindCCAlgorithm].(tcpinfo.CCAlgorithm); ok != (runtime.GOOS == "linux") || ok && cc == "" {
+			t.Fatalf("got %v", i.Options)
+		}
+	}
+
+	tc := c.(*net.TCPConn)
+	if err := tc.SetKeepAlive(true); err != nil {
+		t.Fatal(err)
+	}
+	if err := tc.SetKeepAlivePeriod(30 * time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if err := tcpinfo.GetFields(c, i, tcpinfo.FieldAll|tcpinfo.FieldSockOpts); err != nil {
+		t.Fatal(err)
+	}
+	var ka tcpinfo.KeepAlive
+	for _, opt := range i.Options {
+		if opt, ok := opt.(tcpinfo.KeepAlive); ok {
+			ka = opt
+		}
+	}
+	if ka.Idle != 30*time.Second || ka.Count <= 0 {
+		t.Fatalf("got %+v; want 30s idle time", ka)
+	}
+	if l := i.Liveness(nil); l.Idle != ka.Idle+time.Duration(ka.Count)*ka.Interval {
+		t.Fatalf("got %+v", l)
+	}
+}
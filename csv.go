@@ -0,0 +1,196 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcpinfo
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"strconv"
+	"time"
+)
+
+// CSVVersion is the version of the CSV column set.
+//
+// The column set is stable; new columns are only appended and
+// existing columns are never renamed, reordered or removed within a
+// version.
+//
+// The column set of version 1 is, in order:
+//
+//	time            sample time in RFC 3339 format with nanoseconds
+//	state           connection state
+//	snd_mss         maximum segment size for sender in bytes
+//	rcv_mss         maximum segment size for receiver in bytes
+//	rtt_us          round-trip time in microseconds
+//	rttvar_us       round-trip time variation in microseconds
+//	rto_us          retransmission timeout in microseconds
+//	ato_us          delayed acknowledgement timeout in microseconds
+//	last_data_sent_us, last_data_rcvd_us, last_ack_rcvd_us
+//	                time since last data sent, data received and ack received in microseconds
+//	rcv_wnd         advertised receiver window in bytes
+//	snd_ssthresh, rcv_ssthresh
+//	                slow start thresholds
+//	snd_cwnd_bytes, snd_cwnd_segs
+//	                congestion window for sender
+//	min_rtt_us      minimum round-trip time in microseconds
+//	retrans_segs, retrans_bytes, segs_sent, segs_rcvd, bytes_sent, bytes_rcvd
+//	                cumulative counters as in DerivedStats
+//	delivery_rate   delivery rate in bytes per second
+//	rcv_queue, snd_queue
+//	                queue occupancy in bytes
+const CSVVersion = 1
+
+var csvColumns = []struct {
+	name string
+	fn   func(*Sample) string
+}{
+	{"time", func(s *Sample) string { return s.Time.Format(time.RFC3339Nano) }},
+	{"state", func(s *Sample) string { return s.Info.State.String() }},
+	{"snd_mss", func(s *Sample) string { return csvUint(uint64(s.Info.SenderMSS)) }},
+	{"rcv_mss", func(s *Sample) string { return csvUint(uint64(s.Info.ReceiverMSS)) }},
+	{"rtt_us", func(s *Sample) string { return csvDuration(s.Info.RTT) }},
+	{"rttvar_us", func(s *Sample) string { return csvDuration(s.Info.RTTVar) }},
+	{"rto_us", func(s *Sample) string { return csvDuration(s.Info.RTO) }},
+	{"ato_us", func(s *Sample) string { return csvDuration(s.Info.ATO) }},
+	{"last_data_sent_us", func(s *Sample) string { return csvDuration(s.Info.LastDataSent) }},
+	{"last_data_rcvd_us", func(s *Sample) string { return csvDuration(s.Info.LastDataReceived) }},
+	{"last_ack_rcvd_us", func(s *Sample) string { return csvDuration(s.Info.LastAckReceived) }},
+	{"rcv_wnd", func(s *Sample) string {
+		if s.Info.FlowControl == nil {
+			return ""
+		}
+		return csvUint(uint64(s.Info.FlowControl.ReceiverWindow))
+	}},
+	{"snd_ssthresh", func(s *Sample) string {
+		if s.Info.CongestionControl == nil {
+			return ""
+		}
+		return csvUint(uint64(s.Info.CongestionControl.SenderSSThreshold))
+	}},
+	{"rcv_ssthresh", func(s *Sample) string {
+		if s.Info.CongestionControl == nil {
+			return ""
+		}
+		return csvUint(uint64(s.Info.CongestionControl.ReceiverSSThreshold))
+	}},
+	{"snd_cwnd_bytes", func(s *Sample) string {
+		if s.Info.CongestionControl == nil {
+			return ""
+		}
+		return csvUint(uint64(s.Info.CongestionControl.SenderWindowBytes))
+	}},
+	{"snd_cwnd_segs", func(s *Sample) string {
+		if s.Info.CongestionControl == nil {
+			return ""
+		}
+		return csvUint(uint64(s.Info.CongestionControl.SenderWindowSegs))
+	}},
+	{"min_rtt_us", func(s *Sample) string { return csvDuration(s.Info.Stats().MinRTT) }},
+	{"retrans_segs", func(s *Sample) string { return csvUint(s.Info.Stats().RetransSegs) }},
+	{"retrans_bytes", func(s *Sample) string { return csvUint(s.Info.Stats().RetransBytes) }},
+	{"segs_sent", func(s *Sample) string { return csvUint(s.Info.Stats().SegsSent) }},
+	{"segs_rcvd", func(s *Sample) string { return csvUint(s.Info.Stats().SegsReceived) }},
+	{"bytes_sent", func(s *Sample) string { return csvUint(s.Info.Stats().BytesSent) }},
+	{"bytes_rcvd", func(s *Sample) string { return csvUint(s.Info.Stats().BytesReceived) }},
+	{"delivery_rate", func(s *Sample) string { return csvUint(s.Info.Stats().DeliveryRate) }},
+	{"rcv_queue", func(s *Sample) string {
+		if s.Info.Queue == nil {
+			return ""
+		}
+		return csvUint(uint64(s.Info.Queue.Receive))
+	}},
+	{"snd_queue", func(s *Sample) string {
+		if s.Info.Queue == nil {
+			return ""
+		}
+		return csvUint(uint64(s.Info.Queue.Send))
+	}},
+}
+
+func csvUint(v uint64) string { return strconv.FormatUint(v, 10) }
+
+func csvDuration(d time.Duration) string { return strconv.FormatInt(int64(d/time.Microsecond), 10) }
+
+// CSVColumns returns the names of all CSV columns in order.
+func CSVColumns() []string {
+	names := make([]string, len(csvColumns))
+	for i, col := range csvColumns {
+		names[i] = col.name
+	}
+	return names
+}
+
+// A CSVEncoder writes samples of connection information as CSV
+// records, preceded by a header record.
+type CSVEncoder struct {
+	// Comma is the field delimiter; it defaults to ','.
+	// Set it to '\t' for TSV output.
+	// It must not be modified after the first call to Encode.
+	Comma rune
+
+	w      *csv.Writer
+	cols   []int
+	header bool
+}
+
+// NewCSVEncoder returns a new encoder that writes to w.
+// When columns are given, only those columns are written, in the
+// given order; otherwise all columns are written.
+func NewCSVEncoder(w io.Writer, columns ...string) (*CSVEncoder, error) {
+	e := &CSVEncoder{w: csv.NewWriter(w)}
+	if len(columns) == 0 {
+		for i := range csvColumns {
+			e.cols = append(e.cols, i)
+		}
+		return e, nil
+	}
+	for _, name := range columns {
+		j := -1
+		for i, col := range csvColumns {
+			if col.name == name {
+				j = i
+				break
+			}
+		}
+		if j < 0 {
+			return nil, errors.New("unknown column: " + name)
+		}
+		e.cols = append(e.cols, j)
+	}
+	return e, nil
+}
+
+// Encode writes the sample s as a CSV record.
+// The header record is written before the first sample.
+func (e *CSVEncoder) Encode(s *Sample) error {
+	if s.Info == nil {
+		return errors.New("no connection information")
+	}
+	if !e.header {
+		if e.Comma != 0 {
+			e.w.Comma = e.Comma
+		}
+		rec := make([]string, len(e.cols))
+		for i, j := range e.cols {
+			rec[i] = csvColumns[j].name
+		}
+		if err := e.w.Write(rec); err != nil {
+			return err
+		}
+		e.header = true
+	}
+	rec := make([]string, len(e.cols))
+	for i, j := range e.cols {
+		rec[i] = csvColumns[j].fn(s)
+	}
+	return e.w.Write(rec)
+}
+
+// Flush writes any buffered data to the underlying writer.
+func (e *CSVEncoder) Flush() error {
+	e.w.Flush()
+	return e.w.Error()
+}
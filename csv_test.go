@@ -0,0 +1,43 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcpinfo_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/mikioh/tcpinfo"
+)
+
+func TestCSVEncoder(t *testing.T) {
+	var b bytes.Buffer
+	e, err := tcpinfo.NewCSVEncoder(&b, "state", "rtt_us", "snd_cwnd_segs", "snd_queue")
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.Comma = '\t'
+	for _, s := range []*tcpinfo.Sample{
+		{Info: &tcpinfo.Info{State: tcpinfo.Established, RTT: 1500 * time.Microsecond, CongestionControl: &tcpinfo.CongestionControl{SenderWindowSegs: 10}, Queue: &tcpinfo.Queue{Send: 4096}}},
+		{Info: &tcpinfo.Info{State: tcpinfo.CloseWait}},
+	} {
+		if err := e.Encode(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := e.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	want := "state\trtt_us\tsnd_cwnd_segs\tsnd_queue\nestablished\t1500\t10\t4096\nclose-wait\t0\t\t\n"
+	if b.String() != want {
+		t.Fatalf("got %q; want %q", b.String(), want)
+	}
+	if _, err := tcpinfo.NewCSVEncoder(&b, "nosuchcolumn"); err == nil {
+		t.Fatal("got nil; want an error")
+	}
+	if cols := tcpinfo.CSVColumns(); cols[0] != "time" || len(cols) != 26 {
+		t.Fatalf("got %v", cols)
+	}
+}
@@ -0,0 +1,72 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcpinfo_test
+
+import (
+	"net"
+	"syscall"
+	"testing"
+
+	"github.com/mikioh/tcpinfo"
+)
+
+func TestDatapathOptions(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	c, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	i, err := tcpinfo.Get(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if i.Valid("zerocopy") || i.Valid("timestamping") {
+		t.Fatal("got valid socket options without FieldSockOpts")
+	}
+	if err := tcpinfo.GetFields(c, i, tcpinfo.FieldAll|tcpinfo.FieldSockOpts); err != nil {
+		t.Fatal(err)
+	}
+	if !i.Valid("zerocopy") || !i.Valid("timestamping") {
+		t.Fatal("got invalid socket options with FieldSockOpts")
+	}
+	if i.Sys.ZeroCopy || i.Sys.Timestamping != 0 || i.Sys.TXTimestamping() {
+		t.Fatalf("got %+v", i.Sys)
+	}
+
+	const (
+		soTimestamping          = 0x25
+		soZeroCopy              = 0x3c
+		sofTimestampingTXSched  = 0x100
+		sofTimestampingSoftware = 0x10
+	)
+	rc, err := c.(*net.TCPConn).SyscallConn()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var serr error
+	if err := rc.Control(func(s uintptr) {
+		if serr = syscall.SetsockoptInt(int(s), syscall.SOL_SOCKET, soZeroCopy, 1); serr != nil {
+			return
+		}
+		serr = syscall.SetsockoptInt(int(s), syscall.SOL_SOCKET, soTimestamping, sofTimestampingTXSched|sofTimestampingSoftware)
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if serr != nil {
+		t.Skipf("zerocopy or timestamping not supported: %v", serr)
+	}
+	if err := tcpinfo.GetFields(c, i, tcpinfo.FieldAll|tcpinfo.FieldSockOpts); err != nil {
+		t.Fatal(err)
+	}
+	if !i.Sys.ZeroCopy || i.Sys.Timestamping != sofTimestampingTXSched|sofTimestampingSoftware || !i.Sys.TXTimestamping() {
+		t.Fatalf("got %+v", i.Sys)
+	}
+}
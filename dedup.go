@@ -0,0 +1,126 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcpinfo
+
+import (
+	"math"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// A Deduplicator suppresses samples showing no material change since
+// the last sample emitted on each connection, which reduces the
+// volume of samples on idle long-lived connections.
+//
+// The final sample and a sample whose error differs from the last
+// emitted one are always emitted.
+type Deduplicator struct {
+	// Fields are the names of CSV columns compared between samples.
+	// Unknown names are ignored.
+	// A nil Fields means all the columns except time and those of
+	// the time since the last activity, which grow on idle
+	// connections.
+	Fields []string
+
+	// Epsilon is the relative change of a numeric field below which
+	// the field is regarded as unchanged.
+	// Zero means any change is material.
+	Epsilon float64
+
+	// MaxAge is the maximum time between emitted samples on a
+	// connection, which keeps connections visible as heartbeats.
+	// Zero means no limit.
+	MaxAge time.Duration
+
+	once       sync.Once
+	cols       []int
+	mu         sync.Mutex
+	last       map[net.Conn]*Sample
+	suppressed uint64
+}
+
+// Attach returns a SampleFunc that invokes fn only with samples
+// showing material changes.
+func (d *Deduplicator) Attach(fn SampleFunc) SampleFunc {
+	return func(c net.Conn, s *Sample) {
+		if !d.emit(c, s) || fn == nil {
+			return
+		}
+		fn(c, s)
+	}
+}
+
+// Suppressed returns the number of suppressed samples.
+func (d *Deduplicator) Suppressed() uint64 {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.suppressed
+}
+
+func (d *Deduplicator) emit(c net.Conn, s *Sample) bool {
+	d.once.Do(d.init)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if s.Final {
+		delete(d.last, c)
+		return true
+	}
+	if prev := d.last[c]; prev != nil && !d.changed(prev, s) {
+		d.suppressed++
+		return false
+	}
+	if d.last == nil {
+		d.last = make(map[net.Conn]*Sample)
+	}
+	d.last[c] = s
+	return true
+}
+
+func (d *Deduplicator) init() {
+	if d.Fields == nil {
+		for i, col := range csvColumns {
+			switch col.name {
+			case "time", "last_data_sent_us", "last_data_rcvd_us", "last_ack_rcvd_us":
+			default:
+				d.cols = append(d.cols, i)
+			}
+		}
+		return
+	}
+	for _, name := range d.Fields {
+		for i, col := range csvColumns {
+			if col.name == name {
+				d.cols = append(d.cols, i)
+			}
+		}
+	}
+}
+
+// changed reports whether cur shows a material change since prev.
+func (d *Deduplicator) changed(prev, cur *Sample) bool {
+	if d.MaxAge > 0 && elapsed(prev, cur) >= d.MaxAge {
+		return true
+	}
+	if (prev.Err == nil) != (cur.Err == nil) || prev.Err != nil && prev.Err.Error() != cur.Err.Error() {
+		return true
+	}
+	if prev.Info == nil || cur.Info == nil {
+		return prev.Info != cur.Info
+	}
+	for _, i := range d.cols {
+		p, c := csvColumns[i].fn(prev), csvColumns[i].fn(cur)
+		if p == c {
+			continue
+		}
+		pv, err1 := strconv.ParseFloat(p, 64)
+		cv, err2 := strconv.ParseFloat(c, 64)
+		if err1 != nil || err2 != nil || math.Abs(cv-pv) > d.Epsilon*math.Abs(pv) {
+			return true
+		}
+	}
+	return false
+}
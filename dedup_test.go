@@ -0,0 +1,39 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcpinfo_test
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/mikioh/tcpinfo"
+	"github.com/mikioh/tcpinfo/tcpinfotest"
+)
+
+func TestDeduplicator(t *testing.T) {
+	d := &tcpinfo.Deduplicator{Epsilon: 0.1, MaxAge: time.Minute}
+	var got []*tcpinfo.Sample
+	fn := d.Attach(func(_ net.Conn, s *tcpinfo.Sample) { got = append(got, s) })
+	c := tcpinfotest.NewConn("127.0.0.1:1", "127.0.0.1:2")
+	start := time.Unix(0, 0)
+	for _, s := range []*tcpinfo.Sample{
+		{Time: start, Info: &tcpinfo.Info{State: tcpinfo.Established, RTT: 10 * time.Millisecond}},
+		{Time: start.Add(time.Second), Info: &tcpinfo.Info{State: tcpinfo.Established, RTT: 10500 * time.Microsecond, LastDataSent: time.Second}}, // within epsilon
+		{Time: start.Add(2 * time.Second), Info: &tcpinfo.Info{State: tcpinfo.Established, RTT: 20 * time.Millisecond}},
+		{Time: start.Add(3 * time.Second), Info: &tcpinfo.Info{State: tcpinfo.Established, RTT: 20 * time.Millisecond}},
+		{Time: start.Add(2 * time.Minute), Info: &tcpinfo.Info{State: tcpinfo.Established, RTT: 20 * time.Millisecond}}, // heartbeat
+		{Time: start.Add(3 * time.Minute), Info: &tcpinfo.Info{State: tcpinfo.CloseWait, RTT: 20 * time.Millisecond}},
+		{Time: start.Add(4 * time.Minute), Info: &tcpinfo.Info{State: tcpinfo.CloseWait, RTT: 20 * time.Millisecond}, Final: true},
+	} {
+		fn(c, s)
+	}
+	if len(got) != 5 || d.Suppressed() != 2 {
+		t.Fatalf("got %d emitted, %d suppressed; want 5, 2", len(got), d.Suppressed())
+	}
+	if !got[len(got)-1].Final {
+		t.Fatal("got non-final; want final sample emitted")
+	}
+}
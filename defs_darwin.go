@@ -7,13 +7,25 @@
 package tcpinfo
 
 /*
+#include <sys/filio.h>
+#include <sys/socket.h>
+
 #include <netinet/tcp.h>
 */
 import "C"
 
 const (
+	sysTCP_NODELAY         = C.TCP_NODELAY
+	sysTCP_KEEPALIVE       = C.TCP_KEEPALIVE
+	sysTCP_KEEPINTVL       = C.TCP_KEEPINTVL
+	sysTCP_KEEPCNT         = C.TCP_KEEPCNT
 	sysTCP_CONNECTION_INFO = C.TCP_CONNECTION_INFO
 
+	sysSOL_SOCKET   = C.SOL_SOCKET
+	sysSO_KEEPALIVE = C.SO_KEEPALIVE
+	sysSO_NWRITE    = C.SO_NWRITE
+	sysFIONREAD     = C.FIONREAD
+
 	sysTCPCI_OPT_TIMESTAMPS = C.TCPCI_OPT_TIMESTAMPS
 	sysTCPCI_OPT_SACK       = C.TCPCI_OPT_SACK
 	sysTCPCI_OPT_WSCALE     = C.TCPCI_OPT_WSCALE
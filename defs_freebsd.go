@@ -7,12 +7,26 @@
 package tcpinfo
 
 /*
+#include <sys/filio.h>
+#include <sys/socket.h>
+
 #include <netinet/tcp.h>
 */
 import "C"
 
 const (
-	sysTCP_INFO = C.TCP_INFO
+	sysTCP_NODELAY   = C.TCP_NODELAY
+	sysTCP_INFO      = C.TCP_INFO
+	sysTCP_MD5SIG    = C.TCP_MD5SIG
+	sysTCP_KEEPIDLE  = C.TCP_KEEPIDLE
+	sysTCP_KEEPINTVL = C.TCP_KEEPINTVL
+	sysTCP_KEEPCNT   = C.TCP_KEEPCNT
+
+	sysSOL_SOCKET   = C.SOL_SOCKET
+	sysSO_KEEPALIVE = C.SO_KEEPALIVE
+
+	sysFIONREAD  = C.FIONREAD
+	sysFIONWRITE = C.FIONWRITE
 
 	sysTCPI_OPT_TIMESTAMPS = C.TCPI_OPT_TIMESTAMPS
 	sysTCPI_OPT_SACK       = C.TCPI_OPT_SACK
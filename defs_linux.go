@@ -7,16 +7,58 @@
 package tcpinfo
 
 /*
+#include <linux/ethtool.h>
+#include <linux/if_link.h>
 #include <linux/inet_diag.h>
+#include <linux/mptcp.h>
+#include <linux/rtnetlink.h>
+#include <linux/net_tstamp.h>
 #include <linux/sockios.h>
+#include <linux/sock_diag.h>
+#include <sys/socket.h>
 #include <linux/tcp.h>
 */
 import "C"
 
 const (
-	sysTCP_INFO       = C.TCP_INFO
-	sysTCP_CONGESTION = C.TCP_CONGESTION
-	sysTCP_CC_INFO    = C.TCP_CC_INFO
+	sysTCP_NODELAY      = C.TCP_NODELAY
+	sysTCP_KEEPIDLE     = C.TCP_KEEPIDLE
+	sysTCP_KEEPINTVL    = C.TCP_KEEPINTVL
+	sysTCP_KEEPCNT      = C.TCP_KEEPCNT
+	sysTCP_INFO         = C.TCP_INFO
+	sysTCP_QUICKACK     = C.TCP_QUICKACK
+	sysTCP_CONGESTION   = C.TCP_CONGESTION
+	sysTCP_USER_TIMEOUT = C.TCP_USER_TIMEOUT
+	sysTCP_CC_INFO      = C.TCP_CC_INFO
+	sysTCP_AO_INFO      = C.TCP_AO_INFO
+
+	sysSOL_SOCKET      = C.SOL_SOCKET
+	sysSO_KEEPALIVE    = C.SO_KEEPALIVE
+	sysSO_TIMESTAMPING = C.SO_TIMESTAMPING
+	sysSO_MEMINFO      = C.SO_MEMINFO
+	sysSO_ZEROCOPY     = C.SO_ZEROCOPY
+
+	sysSOF_TIMESTAMPING_TX_HARDWARE = C.SOF_TIMESTAMPING_TX_HARDWARE
+	sysSOF_TIMESTAMPING_TX_SOFTWARE = C.SOF_TIMESTAMPING_TX_SOFTWARE
+	sysSOF_TIMESTAMPING_TX_SCHED    = C.SOF_TIMESTAMPING_TX_SCHED
+	sysSOF_TIMESTAMPING_TX_ACK      = C.SOF_TIMESTAMPING_TX_ACK
+
+	sysSIOCINQ  = C.SIOCINQ
+	sysSIOCOUTQ = C.SIOCOUTQ
+
+	sysSIOCETHTOOL  = C.SIOCETHTOOL
+	sysETHTOOL_GTSO = C.ETHTOOL_GTSO
+	sysETHTOOL_GGSO = C.ETHTOOL_GGSO
+	sysETHTOOL_GGRO = C.ETHTOOL_GGRO
+
+	sysIFLA_GSO_MAX_SEGS = C.IFLA_GSO_MAX_SEGS
+	sysIFLA_GSO_MAX_SIZE = C.IFLA_GSO_MAX_SIZE
+
+	sysRTM_F_LOOKUP_TABLE = C.RTM_F_LOOKUP_TABLE
+
+	sysSOL_MPTCP     = C.SOL_MPTCP
+	sysMPTCP_INFO    = C.MPTCP_INFO
+	sysMPTCP_TCPINFO = C.MPTCP_TCPINFO
 
 	sysTCPI_OPT_TIMESTAMPS = C.TCPI_OPT_TIMESTAMPS
 	sysTCPI_OPT_SACK       = C.TCPI_OPT_SACK
@@ -36,6 +78,11 @@ const (
 	sizeofTCPVegasInfo = C.sizeof_struct_tcpvegas_info
 	sizeofTCPDCTCPInfo = C.sizeof_struct_tcp_dctcp_info
 	sizeofTCPBBRInfo   = C.sizeof_struct_tcp_bbr_info
+
+	sizeofMPTCPInfo        = C.sizeof_struct_mptcp_info
+	sizeofMPTCPSubflowData = C.sizeof_struct_mptcp_subflow_data
+
+	sizeofTCPAOInfoOpt = C.sizeof_struct_tcp_ao_info_opt
 )
 
 type tcpInfo C.struct_tcp_info
@@ -47,3 +94,9 @@ type tcpVegasInfo C.struct_tcpvegas_info
 type tcpDCTCPInfo C.struct_tcp_dctcp_info
 
 type tcpBBRInfo C.struct_tcp_bbr_info
+
+type mptcpInfo C.struct_mptcp_info
+
+type mptcpSubflowData C.struct_mptcp_subflow_data
+
+type ethtoolValue C.struct_ethtool_value
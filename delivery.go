@@ -0,0 +1,171 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcpinfo
+
+import (
+	"net"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// deliveryQueueLen is the number of samples a delivery queue holds.
+// It must be a power of 2.
+const deliveryQueueLen = 1024
+
+// A delivery represents a sample waiting for delivery.
+type delivery struct {
+	e *monitorEntry
+	c net.Conn
+	s *Sample
+}
+
+// A deliveryCell represents a slot of a delivery queue.
+type deliveryCell struct {
+	seq uint64 // position of the slot, plus 1 when filled
+	d   delivery
+}
+
+// A deliveryQueue represents a bounded lock-free queue of samples
+// with multiple producers and a single consumer.
+//
+// The producers are the samplers of connections, which claim slots
+// by compare-and-swap on the head, and the consumer is the delivery
+// goroutine of the shard, which alone moves the tail.
+type deliveryQueue struct {
+	head     uint64 // position of the next slot to fill, shared by producers
+	_        [56]byte
+	tail     uint64 // position of the next slot to drain, owned by the consumer
+	_        [56]byte
+	sleeping int32 // whether the consumer is about to wait for wake
+	cells    [deliveryQueueLen]deliveryCell
+	wake     chan struct{}
+}
+
+func newDeliveryQueue() *deliveryQueue {
+	q := &deliveryQueue{wake: make(chan struct{}, 1)}
+	for j := range q.cells {
+		q.cells[j].seq = uint64(j)
+	}
+	return q
+}
+
+// push appends d to the queue.
+// It reports false when the queue is full.
+func (q *deliveryQueue) push(d delivery) bool {
+	pos := atomic.LoadUint64(&q.head)
+	for {
+		c := &q.cells[pos&(deliveryQueueLen-1)]
+		seq := atomic.LoadUint64(&c.seq)
+		switch dif := int64(seq - pos); {
+		case dif == 0:
+			if atomic.CompareAndSwapUint64(&q.head, pos, pos+1) {
+				c.d = d
+				atomic.StoreUint64(&c.seq, pos+1)
+				return true
+			}
+			pos = atomic.LoadUint64(&q.head)
+		case dif < 0:
+			return false
+		default:
+			pos = atomic.LoadUint64(&q.head)
+		}
+	}
+}
+
+// pop removes the first delivery of the queue.
+// It reports false when the queue is empty.
+// It must be called only by the consumer.
+func (q *deliveryQueue) pop() (delivery, bool) {
+	c := &q.cells[q.tail&(deliveryQueueLen-1)]
+	if atomic.LoadUint64(&c.seq) != q.tail+1 {
+		return delivery{}, false
+	}
+	d := c.d
+	c.d = delivery{}
+	atomic.StoreUint64(&c.seq, q.tail+deliveryQueueLen)
+	q.tail++
+	return d, true
+}
+
+// empty reports whether the queue is empty.
+// It must be called only by the consumer.
+func (q *deliveryQueue) empty() bool {
+	c := &q.cells[q.tail&(deliveryQueueLen-1)]
+	return atomic.LoadUint64(&c.seq) != q.tail+1
+}
+
+// enqueue pushes d and wakes the consumer when it waits.
+// It yields to the consumer while the queue is full, so that a
+// connection delivering faster than the callback function runs slows
+// down rather than loses samples.
+func (q *deliveryQueue) enqueue(d delivery) {
+	for !q.push(d) {
+		q.signal()
+		runtime.Gosched()
+	}
+	q.signal()
+}
+
+func (q *deliveryQueue) signal() {
+	if atomic.LoadInt32(&q.sleeping) == 1 && atomic.CompareAndSwapInt32(&q.sleeping, 1, 0) {
+		select {
+		case q.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// A deliveryShards represents the shards of sample delivery of a
+// monitor, each of which has its own queue and delivery goroutine.
+type deliveryShards struct {
+	qs   []*deliveryQueue
+	next uint32 // shard of the next connection added
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// startDelivery starts n shards of sample delivery.
+func startDelivery(n int) *deliveryShards {
+	ds := &deliveryShards{qs: make([]*deliveryQueue, n), quit: make(chan struct{})}
+	for j := range ds.qs {
+		ds.qs[j] = newDeliveryQueue()
+		ds.wg.Add(1)
+		go ds.run(ds.qs[j])
+	}
+	return ds
+}
+
+// assign returns the queue for a connection being added.
+// The connections are spread over the shards in turn.
+func (ds *deliveryShards) assign() *deliveryQueue {
+	return ds.qs[int(atomic.AddUint32(&ds.next, 1))%len(ds.qs)]
+}
+
+func (ds *deliveryShards) run(q *deliveryQueue) {
+	defer ds.wg.Done()
+	for {
+		for d, ok := q.pop(); ok; d, ok = q.pop() {
+			d.e.deliver(d.c, d.s)
+		}
+		atomic.StoreInt32(&q.sleeping, 1)
+		if !q.empty() {
+			atomic.StoreInt32(&q.sleeping, 0)
+			continue
+		}
+		select {
+		case <-q.wake:
+		case <-ds.quit:
+			return
+		}
+	}
+}
+
+// stop stops the delivery goroutines.
+// The queues must be drained.
+func (ds *deliveryShards) stop() {
+	close(ds.quit)
+	ds.wg.Wait()
+}
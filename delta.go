@@ -0,0 +1,64 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcpinfo
+
+import "time"
+
+// A Delta represents changes of cumulative statistics between two
+// samples of connection information.
+//
+// A counter that went backwards, for example on reuse of a socket, is
+// treated as restarted from zero.
+type Delta struct {
+	Duration      time.Duration `json:"duration"`      // time elapsed between the samples
+	RetransSegs   uint64        `json:"retrans_segs"`  // # of segments retransmitted [FreeBSD, Linux and NetBSD]
+	RetransBytes  uint64        `json:"retrans_bytes"` // # of bytes retransmitted [Darwin only]
+	SegsSent      uint64        `json:"segs_sent"`     // # of segments sent [Darwin and Linux]
+	SegsReceived  uint64        `json:"segs_rcvd"`     // # of segments received [Darwin and Linux]
+	BytesSent     uint64        `json:"bytes_sent"`    // # of bytes sent; # of bytes acked on Linux [Darwin and Linux]
+	BytesReceived uint64        `json:"bytes_rcvd"`    // # of bytes received [Darwin and Linux]
+}
+
+// Diff returns the changes between the previous sample prev and the
+// current sample cur.
+// It returns nil when either sample has no connection information.
+func Diff(prev, cur *Sample) *Delta {
+	if prev == nil || cur == nil || prev.Info == nil || cur.Info == nil {
+		return nil
+	}
+	p, c := prev.Info.Stats(), cur.Info.Stats()
+	return &Delta{
+		Duration:      elapsed(prev, cur),
+		RetransSegs:   sub(c.RetransSegs, p.RetransSegs),
+		RetransBytes:  sub(c.RetransBytes, p.RetransBytes),
+		SegsSent:      sub(c.SegsSent, p.SegsSent),
+		SegsReceived:  sub(c.SegsReceived, p.SegsReceived),
+		BytesSent:     sub(c.BytesSent, p.BytesSent),
+		BytesReceived: sub(c.BytesReceived, p.BytesReceived),
+	}
+}
+
+func sub(cur, prev uint64) uint64 {
+	if cur < prev {
+		return cur
+	}
+	return cur - prev
+}
+
+// SendRate returns the sending rate in bytes per second.
+func (d *Delta) SendRate() float64 {
+	if d.Duration <= 0 {
+		return 0
+	}
+	return float64(d.BytesSent) / d.Duration.Seconds()
+}
+
+// ReceiveRate returns the receiving rate in bytes per second.
+func (d *Delta) ReceiveRate() float64 {
+	if d.Duration <= 0 {
+		return 0
+	}
+	return float64(d.BytesReceived) / d.Duration.Seconds()
+}
@@ -15,6 +15,23 @@
 // Shared Use of Experimental TCP Options is defined in RFC 6994.
 // TCP Extensions for High Performance is defined in RFC 7323.
 //
+// On Linux, connection information is decoded field by field, which
+// works on buffers of any alignment.
+// Building with the tcpinfo_unsafe tag enables a fast path that
+// overlays the kernel structure on the buffer when the buffer is
+// large and aligned enough, and falls back to the decoder otherwise.
+// The fast path makes ParseInto about 2.5 times faster, which
+// matters for users sampling many connections at high frequency.
+//
+// A sample is immutable once published, that is, once the callback
+// functions receiving it return.
+// Samplers, monitors and schedulers never modify published samples,
+// and callback functions attaching information, such as WithLabels,
+// modify a sample only before passing it on.
+// Accessors returning samples shared with other goroutines, such as
+// Monitor.Latest, return copies, which callers may modify.
+// Use Clone to modify a sample received by a callback function.
+//
 // Example:
 //
 //	import (
@@ -0,0 +1,158 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcpinfo
+
+import "time"
+
+// A DurationStats represents the minimum, mean and maximum of
+// durations.
+type DurationStats struct {
+	Min  time.Duration `json:"min"`
+	Mean time.Duration `json:"mean"`
+	Max  time.Duration `json:"max"`
+}
+
+// A ValueStats represents the minimum, mean and maximum of values.
+type ValueStats struct {
+	Min  float64 `json:"min"`
+	Mean float64 `json:"mean"`
+	Max  float64 `json:"max"`
+}
+
+// A Bucket represents samples of connection information downsampled
+// over an interval.
+//
+// Gauges such as round-trip time are summarized by their minimum,
+// mean and maximum, and counters such as retransmitted segments by
+// their changes over the interval.
+type Bucket struct {
+	Start        time.Time     `json:"start"`         // start of the interval, a multiple of the interval since the zero time
+	Interval     time.Duration `json:"interval"`      // length of the interval
+	Samples      int           `json:"samples"`       // # of samples with connection information
+	Errors       int           `json:"errors"`        // # of samples failing retrieval
+	RTT          DurationStats `json:"rtt"`           // round-trip time
+	RTTVar       DurationStats `json:"rttvar"`        // round-trip time variation
+	SenderWindow ValueStats    `json:"snd_cwnd"`      // sender congestion window in segments
+	DeliveryRate ValueStats    `json:"delivery_rate"` // delivery rate in bytes per second [Linux only]
+	Delta        *Delta        `json:"delta"`         // changes of counters since the last sample before the interval; nil when unknown
+}
+
+// A Downsampler downsamples a stream of samples into buckets of a
+// fixed interval, holding a single bucket at a time.
+type Downsampler struct {
+	d  time.Duration
+	fn func(b *Bucket)
+
+	cur  *bucketAcc
+	base *Sample // last sample with connection information before the current bucket
+	last *Sample // last sample with connection information
+}
+
+// A bucketAcc accumulates samples of a bucket.
+type bucketAcc struct {
+	b                  Bucket
+	rtt, rttvar        int64
+	cwnd, rate         float64
+	cwnds, rates       int
+	haveCwnd, haveRate bool
+}
+
+// NewDownsampler returns a new downsampler that invokes fn with each
+// completed bucket of the interval d.
+func NewDownsampler(d time.Duration, fn func(b *Bucket)) *Downsampler {
+	return &Downsampler{d: d, fn: fn}
+}
+
+// Add adds the sample s.
+// Samples must be added in time order; a sample in a later interval
+// than the current bucket completes the bucket.
+func (ds *Downsampler) Add(s *Sample) {
+	start := s.Time.Truncate(ds.d)
+	if ds.cur != nil && !start.Equal(ds.cur.b.Start) {
+		ds.Flush()
+	}
+	if ds.cur == nil {
+		ds.cur = &bucketAcc{b: Bucket{Start: start, Interval: ds.d}}
+		ds.base = ds.last
+	}
+	acc := ds.cur
+	if s.Info == nil {
+		acc.b.Errors++
+		return
+	}
+	if ds.base == nil {
+		ds.base = s
+	}
+	ds.last = s
+	i := s.Info
+	b := &acc.b
+	if b.Samples == 0 || i.RTT < b.RTT.Min {
+		b.RTT.Min = i.RTT
+	}
+	if i.RTT > b.RTT.Max {
+		b.RTT.Max = i.RTT
+	}
+	if b.Samples == 0 || i.RTTVar < b.RTTVar.Min {
+		b.RTTVar.Min = i.RTTVar
+	}
+	if i.RTTVar > b.RTTVar.Max {
+		b.RTTVar.Max = i.RTTVar
+	}
+	acc.rtt += int64(i.RTT)
+	acc.rttvar += int64(i.RTTVar)
+	b.Samples++
+	if cc := i.CongestionControl; cc != nil {
+		observeValue(&b.SenderWindow, &acc.cwnd, &acc.cwnds, float64(cc.SenderWindowSegs))
+	}
+	if st := i.Stats(); st.Valid("delivery_rate") {
+		observeValue(&b.DeliveryRate, &acc.rate, &acc.rates, float64(st.DeliveryRate))
+	}
+}
+
+func observeValue(vs *ValueStats, sum *float64, n *int, v float64) {
+	if *n == 0 || v < vs.Min {
+		vs.Min = v
+	}
+	if *n == 0 || v > vs.Max {
+		vs.Max = v
+	}
+	*sum += v
+	*n++
+}
+
+// Flush completes the current bucket, if any.
+func (ds *Downsampler) Flush() {
+	acc := ds.cur
+	if acc == nil {
+		return
+	}
+	ds.cur = nil
+	b := &acc.b
+	if b.Samples > 0 {
+		b.RTT.Mean = time.Duration(acc.rtt / int64(b.Samples))
+		b.RTTVar.Mean = time.Duration(acc.rttvar / int64(b.Samples))
+		b.Delta = Diff(ds.base, ds.last)
+	}
+	if acc.cwnds > 0 {
+		b.SenderWindow.Mean = acc.cwnd / float64(acc.cwnds)
+	}
+	if acc.rates > 0 {
+		b.DeliveryRate.Mean = acc.rate / float64(acc.rates)
+	}
+	ds.fn(b)
+}
+
+// Downsample returns the samples of the history downsampled into
+// buckets of the interval d.
+// Intervals without samples are omitted.
+func (h *History) Downsample(d time.Duration) []*Bucket {
+	var bs []*Bucket
+	ds := NewDownsampler(d, func(b *Bucket) { bs = append(bs, b) })
+	for _, s := range h.Samples() {
+		ds.Add(s)
+	}
+	ds.Flush()
+	return bs
+}
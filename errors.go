@@ -0,0 +1,93 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcpinfo
+
+import (
+	"errors"
+	"net"
+	"runtime"
+	"strconv"
+	"syscall"
+)
+
+var (
+	// ErrNotSupported is returned when the platform does not
+	// support the operation.
+	ErrNotSupported = errors.New("operation not supported")
+
+	// ErrBufferTooShort is returned when a buffer is shorter than
+	// the information to be parsed requires.
+	ErrBufferTooShort = errors.New("short buffer")
+
+	// ErrConnClosed is returned when the connection is closed or
+	// reset during retrieval.
+	// Use errors.As with ConnClosedError to find the cause.
+	ErrConnClosed = errors.New("connection closed")
+)
+
+// A ConnClosedError reports that the connection is closed or reset.
+// It matches ErrConnClosed with errors.Is.
+type ConnClosedError struct {
+	Err error // underlying error, such as syscall.EBADF, syscall.ENOTCONN or syscall.ECONNRESET
+}
+
+func (e *ConnClosedError) Error() string { return ErrConnClosed.Error() + ": " + e.Err.Error() }
+
+// Unwrap returns the underlying error.
+func (e *ConnClosedError) Unwrap() error { return e.Err }
+
+// Is reports whether target is ErrConnClosed.
+func (e *ConnClosedError) Is(target error) bool { return target == ErrConnClosed }
+
+// Reset reports whether the connection is reset by the peer rather
+// than closed locally.
+func (e *ConnClosedError) Reset() bool { return errors.Is(e.Err, syscall.ECONNRESET) }
+
+// connClosed returns err as a ConnClosedError when it indicates that
+// the connection is closed or reset.
+func connClosed(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, net.ErrClosed), errors.Is(err, syscall.EBADF), errors.Is(err, syscall.ENOTCONN), errors.Is(err, syscall.ECONNRESET):
+		return &ConnClosedError{Err: err}
+	default:
+		return err
+	}
+}
+
+// An Error represents an error on retrieval or parsing of connection
+// information.
+// Use errors.Is to test the underlying error, such as
+// ErrNotSupported or ErrBufferTooShort.
+type Error struct {
+	Op       string // operation, such as "get" or "parse"
+	Kind     string // kind of information, such as "tcp_info"
+	Platform string // platform, such as "linux/amd64"
+	Want     int    // required length of buffer in bytes; zero when not applicable
+	Got      int    // actual length of buffer in bytes
+	Err      error  // underlying error
+}
+
+func (e *Error) Error() string {
+	s := e.Op + " " + e.Kind + " on " + e.Platform + ": " + e.Err.Error()
+	if e.Want > 0 {
+		s += " (want " + strconv.Itoa(e.Want) + " bytes, got " + strconv.Itoa(e.Got) + ")"
+	}
+	return s
+}
+
+// Unwrap returns the underlying error.
+func (e *Error) Unwrap() error { return e.Err }
+
+const platform = runtime.GOOS + "/" + runtime.GOARCH
+
+func errNotSupported(op, kind string) error {
+	return &Error{Op: op, Kind: kind, Platform: platform, Err: ErrNotSupported}
+}
+
+func errShortBuffer(kind string, want, got int) error {
+	return &Error{Op: "parse", Kind: kind, Platform: platform, Want: want, Got: got, Err: ErrBufferTooShort}
+}
@@ -0,0 +1,39 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcpinfo_test
+
+import (
+	"errors"
+	"runtime"
+	"testing"
+
+	"github.com/mikioh/tcpinfo"
+)
+
+func TestErrors(t *testing.T) {
+	err := tcpinfo.ParseInto(make([]byte, 1), new(tcpinfo.Info))
+	switch runtime.GOOS {
+	case "darwin", "freebsd", "linux", "netbsd":
+		if !errors.Is(err, tcpinfo.ErrBufferTooShort) {
+			t.Fatalf("got %v; want %v", err, tcpinfo.ErrBufferTooShort)
+		}
+		var e *tcpinfo.Error
+		if !errors.As(err, &e) {
+			t.Fatalf("got %T; want %T", err, e)
+		}
+		if e.Op != "parse" || e.Kind == "" || e.Platform != runtime.GOOS+"/"+runtime.GOARCH || e.Want <= e.Got || e.Got != 1 {
+			t.Fatalf("got %+v", e)
+		}
+	default:
+		if !errors.Is(err, tcpinfo.ErrNotSupported) {
+			t.Fatalf("got %v; want %v", err, tcpinfo.ErrNotSupported)
+		}
+	}
+	if runtime.GOOS != "linux" {
+		if _, err := tcpinfo.ParseCCAlgorithmInfo("vegas", nil); !errors.Is(err, tcpinfo.ErrNotSupported) {
+			t.Fatalf("got %v; want %v", err, tcpinfo.ErrNotSupported)
+		}
+	}
+}
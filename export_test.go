@@ -0,0 +1,19 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcpinfo
+
+import "net"
+
+// UpgradeJSONWith exposes the upgrade of JSON objects with the
+// migrations given by the test.
+var UpgradeJSONWith = upgradeJSON
+
+// Enqueue hands s on c over to the delivery of m, as the sampler of c
+// does.
+func (m *Monitor) Enqueue(c net.Conn, s *Sample) {
+	if v, ok := m.conns.Load(c); ok {
+		v.(*monitorEntry).enqueue(c, s)
+	}
+}
@@ -0,0 +1,113 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package expvartcpinfo publishes aggregated TCP connection
+// information under expvar.
+//
+// Example:
+//
+//	p := expvartcpinfo.Publish("tcpinfo")
+//	ln = &tcpinfo.Listener{Listener: ln, Interval: 5 * time.Second, Func: p.Observe}
+//
+// The statistics then show up in /debug/vars as:
+//
+//	"tcpinfo": {"conns": 2, "rtt_p50": 1200, "rtt_p90": 4500, ...}
+package expvartcpinfo
+
+import (
+	"expvar"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/mikioh/tcpinfo"
+)
+
+// A Stats represents aggregated statistics published by a publisher.
+type Stats struct {
+	Conns              uint64 `json:"conns"`               // # of open connections
+	ClosedConns        uint64 `json:"closed_conns"`        // # of closed connections
+	RTTP50             int64  `json:"rtt_p50"`             // median round-trip time of open connections in microseconds
+	RTTP90             int64  `json:"rtt_p90"`             // 90th percentile round-trip time of open connections in microseconds
+	RTTP99             int64  `json:"rtt_p99"`             // 99th percentile round-trip time of open connections in microseconds
+	RetransTotal       uint64 `json:"retrans_total"`       // total # of retransmitted segments, or bytes on Darwin
+	BytesSentTotal     uint64 `json:"bytes_sent_total"`    // total # of bytes sent
+	BytesReceivedTotal uint64 `json:"bytes_rcvd_total"`    // total # of bytes received
+	DeliveryRateTotal  uint64 `json:"delivery_rate_total"` // sum of delivery rates of open connections in bytes per second
+}
+
+// A Publisher aggregates connection information samples and publishes
+// the statistics under expvar.
+type Publisher struct {
+	mu     sync.Mutex
+	latest map[net.Conn]*tcpinfo.Info
+	closed Stats // totals of closed connections
+}
+
+// Publish returns a new publisher that publishes statistics under
+// the name with expvar.
+// Like expvar.Publish, it panics if the name is already registered.
+func Publish(name string) *Publisher {
+	p := &Publisher{latest: make(map[net.Conn]*tcpinfo.Info)}
+	expvar.Publish(name, expvar.Func(func() interface{} { return p.Stats() }))
+	return p
+}
+
+// Observe records the sample s on the connection c.
+// It can be used as a tcpinfo.SampleFunc.
+func (p *Publisher) Observe(c net.Conn, s *tcpinfo.Sample) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if s.Info != nil {
+		p.latest[c] = s.Info
+	}
+	if !s.Final {
+		return
+	}
+	if i := p.latest[c]; i != nil {
+		accumulate(&p.closed, i.Stats())
+	}
+	p.closed.ClosedConns++
+	delete(p.latest, c)
+}
+
+// Stats returns the current statistics.
+func (p *Publisher) Stats() Stats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	st := p.closed
+	st.Conns = uint64(len(p.latest))
+	rtts := make([]time.Duration, 0, len(p.latest))
+	for _, i := range p.latest {
+		ds := i.Stats()
+		accumulate(&st, ds)
+		st.DeliveryRateTotal += ds.DeliveryRate
+		rtts = append(rtts, i.RTT)
+	}
+	sort.Slice(rtts, func(i, j int) bool { return rtts[i] < rtts[j] })
+	st.RTTP50 = percentile(rtts, 50)
+	st.RTTP90 = percentile(rtts, 90)
+	st.RTTP99 = percentile(rtts, 99)
+	return st
+}
+
+func accumulate(st *Stats, ds *tcpinfo.DerivedStats) {
+	st.RetransTotal += ds.RetransSegs + ds.RetransBytes
+	st.BytesSentTotal += ds.BytesSent
+	st.BytesReceivedTotal += ds.BytesReceived
+}
+
+// percentile returns the p-th percentile of sorted durations in
+// microseconds using the nearest-rank method.
+func percentile(sorted []time.Duration, p int) int64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	n := (p*len(sorted) + 99) / 100
+	if n < 1 {
+		n = 1
+	}
+	return int64(sorted[n-1] / time.Microsecond)
+}
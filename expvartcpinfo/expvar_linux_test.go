@@ -0,0 +1,52 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package expvartcpinfo_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mikioh/tcpinfo"
+	"github.com/mikioh/tcpinfo/expvartcpinfo"
+	"github.com/mikioh/tcpinfo/tcpinfotest"
+)
+
+func TestPublisherTotals(t *testing.T) {
+	p := expvartcpinfo.Publish("tcpinfo_totals_test")
+	info := func(retrans, sent, rcvd, rate uint64) *tcpinfo.Info {
+		return tcpinfotest.NewInfo().Sys(func(si *tcpinfo.SysInfo) {
+			si.TotalRetransSegs = uint(retrans)
+			si.ThruBytesAcked = sent
+			si.ThruBytesReceived = rcvd
+			si.DeliveryRate = rate
+		}).Build()
+	}
+	c1 := tcpinfotest.NewConn("127.0.0.1:1", "127.0.0.1:2")
+	defer c1.Close()
+	c2 := tcpinfotest.NewConn("127.0.0.1:3", "127.0.0.1:4")
+	defer c2.Close()
+
+	p.Observe(c1, &tcpinfo.Sample{Time: time.Now(), Info: info(1, 100, 200, 1000)})
+	p.Observe(c2, &tcpinfo.Sample{Time: time.Now(), Info: info(2, 300, 400, 2000)})
+	want := expvartcpinfo.Stats{Conns: 2, RTTP50: 10000, RTTP90: 10000, RTTP99: 10000, RetransTotal: 3, BytesSentTotal: 400, BytesReceivedTotal: 600, DeliveryRateTotal: 3000}
+	if st := p.Stats(); st != want {
+		t.Fatalf("got %+v; want %+v", st, want)
+	}
+
+	// The totals of a closed connection are those of its last
+	// sample carrying information, and its delivery rate is no
+	// longer counted.
+	p.Observe(c1, &tcpinfo.Sample{Time: time.Now(), Info: info(5, 500, 600, 1000)})
+	p.Observe(c1, &tcpinfo.Sample{Time: time.Now(), Err: tcpinfo.ErrConnClosed, Final: true})
+	want = expvartcpinfo.Stats{Conns: 1, ClosedConns: 1, RTTP50: 10000, RTTP90: 10000, RTTP99: 10000, RetransTotal: 7, BytesSentTotal: 800, BytesReceivedTotal: 1000, DeliveryRateTotal: 2000}
+	if st := p.Stats(); st != want {
+		t.Fatalf("got %+v; want %+v", st, want)
+	}
+	p.Observe(c2, &tcpinfo.Sample{Time: time.Now(), Info: info(2, 300, 400, 2000), Final: true})
+	want = expvartcpinfo.Stats{ClosedConns: 2, RetransTotal: 7, BytesSentTotal: 800, BytesReceivedTotal: 1000}
+	if st := p.Stats(); st != want {
+		t.Fatalf("got %+v; want %+v", st, want)
+	}
+}
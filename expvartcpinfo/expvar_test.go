@@ -0,0 +1,70 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package expvartcpinfo_test
+
+import (
+	"encoding/json"
+	"expvar"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/mikioh/tcpinfo"
+	"github.com/mikioh/tcpinfo/expvartcpinfo"
+	"github.com/mikioh/tcpinfo/tcpinfotest"
+)
+
+func TestPublisher(t *testing.T) {
+	p := expvartcpinfo.Publish("tcpinfo_test")
+	if st := p.Stats(); st != (expvartcpinfo.Stats{}) {
+		t.Fatalf("got %+v; want zero", st)
+	}
+
+	var cs []net.Conn
+	for j := 1; j <= 10; j++ {
+		c := tcpinfotest.NewConn("127.0.0.1:1", "127.0.0.1:2")
+		defer c.Close()
+		cs = append(cs, c)
+		i := tcpinfotest.NewInfo().RTT(time.Duration(j)*time.Millisecond, 0).Build()
+		p.Observe(c, &tcpinfo.Sample{Time: time.Now(), Info: i})
+	}
+	// A later sample replaces the earlier one of the same
+	// connection.
+	p.Observe(cs[0], &tcpinfo.Sample{Time: time.Now(), Info: tcpinfotest.NewInfo().RTT(20*time.Millisecond, 0).Build()})
+	// A sample without information leaves the latest one in place.
+	p.Observe(cs[1], &tcpinfo.Sample{Time: time.Now(), Err: tcpinfo.ErrConnClosed})
+	st := p.Stats()
+	if st.Conns != 10 || st.ClosedConns != 0 {
+		t.Fatalf("got %d open, %d closed conns; want 10, 0", st.Conns, st.ClosedConns)
+	}
+	// The RTTs are 2, 3, ..., 10 and 20 milliseconds.
+	if st.RTTP50 != 6000 || st.RTTP90 != 10000 || st.RTTP99 != 20000 {
+		t.Fatalf("got p50=%d, p90=%d, p99=%d; want 6000, 10000, 20000", st.RTTP50, st.RTTP90, st.RTTP99)
+	}
+
+	for _, c := range cs[:5] {
+		p.Observe(c, &tcpinfo.Sample{Time: time.Now(), Final: true})
+	}
+	// A final sample of an unseen connection is counted as closed.
+	c := tcpinfotest.NewConn("127.0.0.1:1", "127.0.0.1:2")
+	defer c.Close()
+	p.Observe(c, &tcpinfo.Sample{Time: time.Now(), Err: tcpinfo.ErrConnClosed, Final: true})
+	st = p.Stats()
+	if st.Conns != 5 || st.ClosedConns != 6 {
+		t.Fatalf("got %d open, %d closed conns; want 5, 6", st.Conns, st.ClosedConns)
+	}
+	// The RTTs are 6, 7, ..., 10 milliseconds.
+	if st.RTTP50 != 8000 || st.RTTP90 != 10000 || st.RTTP99 != 10000 {
+		t.Fatalf("got p50=%d, p90=%d, p99=%d; want 8000, 10000, 10000", st.RTTP50, st.RTTP90, st.RTTP99)
+	}
+
+	var got expvartcpinfo.Stats
+	if err := json.Unmarshal([]byte(expvar.Get("tcpinfo_test").String()), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got != st {
+		t.Fatalf("got %+v; want %+v", got, st)
+	}
+}
@@ -0,0 +1,168 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcpinfo
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A Compressor represents a compression format of files.
+//
+// Formats other than gzip, such as zstd, can be plugged in by
+// providing a constructor of writers, for example the NewWriter
+// function of a zstd package.
+type Compressor struct {
+	Ext       string                                    // file name extension, such as ".gz"
+	NewWriter func(w io.Writer) (io.WriteCloser, error) // constructor of compressing writers
+}
+
+// Gzip is the gzip compression format.
+var Gzip = &Compressor{
+	Ext:       ".gz",
+	NewWriter: func(w io.Writer) (io.WriteCloser, error) { return gzip.NewWriter(w), nil },
+}
+
+// FileSinkOpts represents options for a file sink.
+type FileSinkOpts struct {
+	MaxSize  int64         // size of uncompressed data in bytes after which a file is rotated; zero means no limit
+	MaxAge   time.Duration // age after which a file is rotated; zero means no limit
+	MaxFiles int           // # of files kept, deleting the oldest files written by the sink; zero means no limit
+	Compress *Compressor   // compression format; nil means no compression
+}
+
+// A FileSink is a sink writing samples as newline-delimited JSON to
+// files with rotation.
+//
+// Files are named after the path with the time when writing the file
+// started, such as capture-20160102T150405.000Z.jsonl for the path
+// capture.jsonl, followed by the extension of compression format.
+// A file is compressed as it is written, which lets a file be read
+// up to the last completed block if the process is terminated.
+type FileSink struct {
+	path string
+	opts FileSinkOpts
+
+	mu    sync.Mutex
+	f     *os.File
+	w     io.Writer
+	cw    io.WriteCloser // compressing writer; nil when not compressed
+	size  int64
+	start time.Time
+	files []string
+}
+
+// NewFileSink returns a new file sink writing to files named after
+// path.
+// The first file is created when the first sample is written.
+func NewFileSink(path string, opts FileSinkOpts) *FileSink {
+	return &FileSink{path: path, opts: opts}
+}
+
+// Write implements the Write method of Sink interface.
+func (fs *FileSink) Write(id ConnID, s *Sample) error {
+	b, err := marshalJSONRecord(id, s)
+	if err != nil {
+		return err
+	}
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	now := time.Now()
+	if fs.f != nil && (fs.opts.MaxSize > 0 && fs.size+int64(len(b)) > fs.opts.MaxSize && fs.size > 0 || fs.opts.MaxAge > 0 && now.Sub(fs.start) >= fs.opts.MaxAge) {
+		if err := fs.close(); err != nil {
+			return err
+		}
+	}
+	if fs.f == nil {
+		if err := fs.open(now); err != nil {
+			return err
+		}
+	}
+	n, err := fs.w.Write(b)
+	fs.size += int64(n)
+	return err
+}
+
+// Rotate closes the current file, if any.
+// The next file is created when the next sample is written.
+func (fs *FileSink) Rotate() error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	return fs.close()
+}
+
+// Close closes the current file, if any.
+func (fs *FileSink) Close() error {
+	return fs.Rotate()
+}
+
+// Files returns the names of files written by the sink and not
+// deleted, oldest first.
+func (fs *FileSink) Files() []string {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	return append([]string(nil), fs.files...)
+}
+
+func (fs *FileSink) open(now time.Time) error {
+	ext := filepath.Ext(fs.path)
+	base := strings.TrimSuffix(fs.path, ext) + "-" + now.UTC().Format("20060102T150405.000Z")
+	if fs.opts.Compress != nil {
+		ext += fs.opts.Compress.Ext
+	}
+	var f *os.File
+	var err error
+	for seq := 0; ; seq++ {
+		name := base + ext
+		if seq > 0 {
+			name = base + "-" + strconv.Itoa(seq) + ext
+		}
+		f, err = os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if !os.IsExist(err) {
+			break
+		}
+	}
+	if err != nil {
+		return err
+	}
+	fs.f, fs.w, fs.cw, fs.size, fs.start = f, f, nil, 0, now
+	if fs.opts.Compress != nil {
+		cw, err := fs.opts.Compress.NewWriter(f)
+		if err != nil {
+			f.Close()
+			os.Remove(f.Name())
+			fs.f = nil
+			return err
+		}
+		fs.w, fs.cw = cw, cw
+	}
+	fs.files = append(fs.files, f.Name())
+	for fs.opts.MaxFiles > 0 && len(fs.files) > fs.opts.MaxFiles {
+		os.Remove(fs.files[0])
+		fs.files = fs.files[1:]
+	}
+	return nil
+}
+
+func (fs *FileSink) close() error {
+	if fs.f == nil {
+		return nil
+	}
+	var err error
+	if fs.cw != nil {
+		err = fs.cw.Close()
+	}
+	if cerr := fs.f.Close(); err == nil {
+		err = cerr
+	}
+	fs.f, fs.w, fs.cw = nil, nil, nil
+	return err
+}
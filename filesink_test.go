@@ -0,0 +1,90 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcpinfo_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mikioh/tcpinfo"
+	"github.com/mikioh/tcpinfo/tcpinfotest"
+)
+
+func TestFileSink(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tcpinfo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	id := tcpinfo.ConnID{Local: "192.0.2.1:443", Remote: "192.0.2.2:50000"}
+	s := &tcpinfo.Sample{Time: time.Now(), Info: tcpinfotest.NewInfo().Build()}
+
+	for _, compress := range []*tcpinfo.Compressor{nil, tcpinfo.Gzip} {
+		path := filepath.Join(dir, "capture.jsonl")
+		fs := tcpinfo.NewFileSink(path, tcpinfo.FileSinkOpts{MaxSize: 1, MaxFiles: 2, Compress: compress})
+		for j := 0; j < 3; j++ {
+			if err := fs.Write(id, s); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := fs.Close(); err != nil {
+			t.Fatal(err)
+		}
+		files := fs.Files()
+		if len(files) != 2 {
+			t.Fatalf("got %v; want 2 files", files)
+		}
+		ext := ".jsonl"
+		if compress != nil {
+			ext += ".gz"
+		}
+		for _, name := range files {
+			if !strings.HasPrefix(filepath.Base(name), "capture-") || !strings.HasSuffix(name, ext) {
+				t.Fatalf("got %s", name)
+			}
+			b, err := ioutil.ReadFile(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if compress != nil {
+				zr, err := gzip.NewReader(bytes.NewReader(b))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if b, err = ioutil.ReadAll(zr); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if bytes.Count(b, []byte("\n")) != 1 || !bytes.Contains(b, []byte(`"raddr":"192.0.2.2:50000"`)) {
+				t.Fatalf("got %s", b)
+			}
+		}
+		all, err := filepath.Glob(filepath.Join(dir, "capture-*"+ext))
+		if err != nil || len(all) != 2 {
+			t.Fatalf("got %v, %v; want oldest file deleted", all, err)
+		}
+		for _, name := range all {
+			os.Remove(name)
+		}
+	}
+
+	fs := tcpinfo.NewFileSink(filepath.Join(dir, "aged.jsonl"), tcpinfo.FileSinkOpts{MaxAge: time.Millisecond})
+	defer fs.Close()
+	for j := 0; j < 2; j++ {
+		if err := fs.Write(id, s); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(2 * time.Millisecond)
+	}
+	if files := fs.Files(); len(files) != 2 {
+		t.Fatalf("got %v; want 2 files", files)
+	}
+}
@@ -0,0 +1,72 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcpinfo
+
+import "strconv"
+
+// Fingerprint returns a compact summary of the capabilities
+// negotiated on the connection, such as
+// "mss=1448,wscale=7/9,sack,tmstamps,ecn,tfo,cc=cubic".
+//
+// The summary consists of the maximum segment size for sender, the
+// window scales of the local and remote ends, and the options and
+// congestion control algorithm reported in Options, in that order.
+// The algorithm is reported only when i is retrieved with
+// FieldSockOpts.
+// Connections sharing a summary share the behavior of the network
+// on option negotiation, which helps to group connections by the
+// middleboxes on their paths.
+func (i *Info) Fingerprint() string {
+	b := append([]byte("mss="), strconv.FormatUint(uint64(i.SenderMSS), 10)...)
+	var sndWS, rcvWS WindowScale = -1, -1
+	var sack, ts, ecn, tfo bool
+	var cc CCAlgorithm
+	for _, opt := range i.Options {
+		switch opt := opt.(type) {
+		case WindowScale:
+			sndWS = opt
+		case SACKPermitted:
+			sack = bool(opt)
+		case Timestamps:
+			ts = bool(opt)
+		case ECN:
+			ecn = bool(opt)
+		case FastOpen:
+			tfo = bool(opt)
+		case CCAlgorithm:
+			cc = opt
+		}
+	}
+	for _, opt := range i.PeerOptions {
+		if opt, ok := opt.(WindowScale); ok {
+			rcvWS = opt
+		}
+	}
+	if sndWS >= 0 {
+		b = append(b, ",wscale="...)
+		b = strconv.AppendInt(b, int64(sndWS), 10)
+		b = append(b, '/')
+		b = strconv.AppendInt(b, int64(rcvWS), 10)
+	}
+	for _, f := range [...]struct {
+		kind OptionKind
+		on   bool
+	}{
+		{KindSACKPermitted, sack},
+		{KindTimestamps, ts},
+		{KindECN, ecn},
+		{KindFastOpen, tfo},
+	} {
+		if f.on {
+			b = append(b, ',')
+			b = append(b, f.kind.String()...)
+		}
+	}
+	if cc != "" {
+		b = append(b, ",cc="...)
+		b = append(b, cc...)
+	}
+	return string(b)
+}
@@ -0,0 +1,39 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcpinfo_test
+
+import (
+	"testing"
+
+	"github.com/mikioh/tcpinfo"
+)
+
+func TestFingerprint(t *testing.T) {
+	for _, tt := range []struct {
+		i    tcpinfo.Info
+		want string
+	}{
+		{tcpinfo.Info{SenderMSS: 536}, "mss=536"},
+		{
+			tcpinfo.Info{
+				SenderMSS:   1448,
+				Options:     []tcpinfo.Option{tcpinfo.WindowScale(7), tcpinfo.SACKPermitted(true), tcpinfo.Timestamps(true), tcpinfo.ECN(true), tcpinfo.FastOpen(true), tcpinfo.NoDelay(true), tcpinfo.CCAlgorithm("bbr")},
+				PeerOptions: []tcpinfo.Option{tcpinfo.WindowScale(9), tcpinfo.SACKPermitted(true), tcpinfo.Timestamps(true)},
+			},
+			"mss=1448,wscale=7/9,sack,tmstamps,ecn,tfo,cc=bbr",
+		},
+		{
+			tcpinfo.Info{
+				SenderMSS: 1380,
+				Options:   []tcpinfo.Option{tcpinfo.SACKPermitted(true), tcpinfo.CCAlgorithm("cubic")},
+			},
+			"mss=1380,sack,cc=cubic",
+		},
+	} {
+		if got := tt.i.Fingerprint(); got != tt.want {
+			t.Fatalf("got %q; want %q", got, tt.want)
+		}
+	}
+}
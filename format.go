@@ -0,0 +1,128 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcpinfo
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"text/template"
+	"time"
+)
+
+// A Formatter renders samples of connection information with a text
+// template.
+//
+// The template is executed with a value that embeds Info and has the
+// Time and Final fields of Sample, so that {{.RTT}},
+// {{.CongestionControl.SenderWindowSegs}}, {{.Stats.RetransSegs}} or
+// {{.Time}} can be written directly.
+//
+// In addition to the predefined functions of text/template, the
+// following functions are available:
+//
+//	ms       duration in milliseconds, such as "1.234"
+//	us       duration in microseconds as an integer
+//	rate     rate in bytes per second in bits per second with a unit, such as "12.3Mbps"
+//	bytes    size in bytes with a binary unit, such as "1.5MiB"
+//	percent  ratio of two numbers as a percentage, such as "12.5%"
+type Formatter struct {
+	t *template.Template
+}
+
+var formatFuncs = template.FuncMap{
+	"ms": func(d time.Duration) string {
+		return strconv.FormatFloat(float64(d)/float64(time.Millisecond), 'f', 3, 64)
+	},
+	"us":      func(d time.Duration) int64 { return int64(d / time.Microsecond) },
+	"rate":    formatRate,
+	"bytes":   formatBytes,
+	"percent": formatPercent,
+}
+
+// NewFormatter returns a new formatter with the template text.
+func NewFormatter(text string) (*Formatter, error) {
+	t, err := template.New("tcpinfo").Funcs(formatFuncs).Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	return &Formatter{t: t}, nil
+}
+
+type formatData struct {
+	*Info
+	Time  time.Time
+	Final bool
+}
+
+// Format renders the sample s to w.
+func (f *Formatter) Format(w io.Writer, s *Sample) error {
+	if s.Info == nil {
+		return errors.New("no connection information")
+	}
+	return f.t.Execute(w, &formatData{Info: s.Info, Time: s.Time, Final: s.Final})
+}
+
+func formatRate(v interface{}) (string, error) {
+	bps, err := toFloat(v)
+	if err != nil {
+		return "", err
+	}
+	bps *= 8
+	for _, u := range []string{"bps", "Kbps", "Mbps", "Gbps"} {
+		if bps < 1000 || u == "Gbps" {
+			return strconv.FormatFloat(bps, 'f', 1, 64) + u, nil
+		}
+		bps /= 1000
+	}
+	panic("unreachable")
+}
+
+func formatBytes(v interface{}) (string, error) {
+	n, err := toFloat(v)
+	if err != nil {
+		return "", err
+	}
+	if n < 1024 {
+		return strconv.FormatFloat(n, 'f', 0, 64) + "B", nil
+	}
+	for _, u := range []string{"KiB", "MiB", "GiB", "TiB"} {
+		n /= 1024
+		if n < 1024 || u == "TiB" {
+			return strconv.FormatFloat(n, 'f', 1, 64) + u, nil
+		}
+	}
+	panic("unreachable")
+}
+
+func formatPercent(part, whole interface{}) (string, error) {
+	p, err := toFloat(part)
+	if err != nil {
+		return "", err
+	}
+	w, err := toFloat(whole)
+	if err != nil {
+		return "", err
+	}
+	if w == 0 {
+		return "0.0%", nil
+	}
+	return strconv.FormatFloat(100*p/w, 'f', 1, 64) + "%", nil
+}
+
+func toFloat(v interface{}) (float64, error) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return float64(rv.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), nil
+	}
+	return 0, fmt.Errorf("not a number: %v", v)
+}
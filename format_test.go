@@ -0,0 +1,43 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcpinfo_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/mikioh/tcpinfo"
+)
+
+func TestFormatter(t *testing.T) {
+	s := &tcpinfo.Sample{
+		Info: &tcpinfo.Info{
+			State:             tcpinfo.Established,
+			RTT:               1234 * time.Microsecond,
+			CongestionControl: &tcpinfo.CongestionControl{SenderWindowSegs: 10},
+		},
+	}
+	for _, tt := range []struct {
+		text, want string
+	}{
+		{"{{.State}} {{.RTT}} {{.CongestionControl.SenderWindowSegs}}", "established 1.234ms 10"},
+		{"{{ms .RTT}} {{us .RTT}}", "1.234 1234"},
+		{"{{rate 1500000}} {{bytes 1572864}} {{percent 1 8}}", "12.0Mbps 1.5MiB 12.5%"},
+		{"{{.Stats.RetransSegs}} {{.Final}}", "0 false"},
+	} {
+		f, err := tcpinfo.NewFormatter(tt.text)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var b bytes.Buffer
+		if err := f.Format(&b, s); err != nil {
+			t.Fatal(err)
+		}
+		if b.String() != tt.want {
+			t.Fatalf("got %q; want %q", b.String(), tt.want)
+		}
+	}
+}
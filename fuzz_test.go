@@ -0,0 +1,119 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build go1.18
+
+package tcpinfo_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/mikioh/tcpinfo"
+	"github.com/mikioh/tcpopt"
+)
+
+// fuzzSeeds returns buffers of the lengths of the structures returned
+// by the supported kernel versions and their neighbours.
+func fuzzSeeds() [][]byte {
+	var bs [][]byte
+	for _, n := range []int{0, 1, 8, 0x67, 0x68, 0x78, 0x88, 0x90, 0xa0, 0xa8, 0xb0, 0xcc, 0xec, 0x100} {
+		b := make([]byte, n)
+		for j := range b {
+			b[j] = byte(j*7 + 1)
+		}
+		bs = append(bs, b)
+	}
+	return bs
+}
+
+func FuzzParse(f *testing.F) {
+	for _, b := range fuzzSeeds() {
+		f.Add(b, uint8(0), uint8(tcpinfo.ParseDefault), uint8(0))
+		f.Add(b, uint8(1), uint8(tcpinfo.ParseLenient), uint8(tcpinfo.FieldSys))
+		f.Add(b, uint8(3), uint8(tcpinfo.ParseStrict), uint8(tcpinfo.FieldOptions))
+	}
+	f.Fuzz(func(t *testing.T, b []byte, off, mode, fields uint8) {
+		// Misalign the buffer on purpose; captured buffers come
+		// from anywhere.
+		ub := make([]byte, int(off%8)+len(b))[off%8:]
+		copy(ub, b)
+
+		i, err := tcpinfo.Parse(ub)
+		if err == nil && i.KernelStructSize != len(ub) {
+			t.Fatalf("got %d; want %d", i.KernelStructSize, len(ub))
+		}
+		if err == nil && !bytes.Equal(ub, b) {
+			t.Fatal("input modified")
+		}
+
+		p := tcpinfo.Parser{Mode: tcpinfo.ParseMode(mode % 3), Fields: tcpinfo.FieldMask(fields) & tcpinfo.FieldAll}
+		var fresh, reused tcpinfo.Info
+		ferr := p.Parse(ub, &fresh)
+		tcpinfo.ParseInto(fuzzSeeds()[9], &reused)
+		rerr := p.Parse(ub, &reused)
+		if (ferr == nil) != (rerr == nil) {
+			t.Fatalf("got %v; want %v", rerr, ferr)
+		}
+		if ferr != nil {
+			return
+		}
+		fb, err := json.Marshal(&fresh)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rb, err := json.Marshal(&reused)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(fb, rb) {
+			t.Fatalf("got %s; want %s", rb, fb)
+		}
+		fresh.Stats()
+	})
+}
+
+func FuzzParseCCAlgorithmInfo(f *testing.F) {
+	for _, name := range []string{"vegas", "dctcp", "bbr", "bbr2", "cubic"} {
+		for _, b := range fuzzSeeds()[:4] {
+			f.Add(name, b, uint8(1))
+		}
+		f.Add(name, make([]byte, 16), uint8(0))
+	}
+	f.Fuzz(func(t *testing.T, name string, b []byte, off uint8) {
+		ub := make([]byte, int(off%8)+len(b))[off%8:]
+		copy(ub, b)
+		ccai, err := tcpinfo.ParseCCAlgorithmInfo(name, ub)
+		if err != nil {
+			return
+		}
+		if err := tcpinfo.ParseCCAlgorithmInfoInto(ub, ccai); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := json.Marshal(ccai); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func FuzzParseOptions(f *testing.F) {
+	var mi tcpinfo.MemInfo
+	var cci tcpinfo.CCInfo
+	var cca tcpinfo.CCAlgorithm
+	for _, b := range fuzzSeeds()[:5] {
+		f.Add(mi.Level(), mi.Name(), b)
+		f.Add(cci.Level(), cci.Name(), b)
+		f.Add(cca.Level(), cca.Name(), b)
+	}
+	f.Fuzz(func(t *testing.T, level, name int, b []byte) {
+		o, err := tcpopt.Parse(level, name, b)
+		if err != nil {
+			return
+		}
+		if _, err := o.Marshal(); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
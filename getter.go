@@ -0,0 +1,29 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcpinfo
+
+import "net"
+
+// A Getter retrieves connection information on connections.
+//
+// SyscallGetter retrieves connection information via the socket of
+// connection.
+// The Conn type in package sockdiag retrieves it via the Linux
+// sock_diag netlink interface, and package tcpinfotest provides a
+// fake for testing.
+type Getter interface {
+	Get(c net.Conn) (*Info, error)
+}
+
+// The GetterFunc type is an adapter to allow the use of an ordinary
+// function as Getter.
+type GetterFunc func(c net.Conn) (*Info, error)
+
+// Get implements the Get method of Getter interface.
+func (f GetterFunc) Get(c net.Conn) (*Info, error) { return f(c) }
+
+// SyscallGetter is the getter that retrieves connection information
+// as Get does.
+var SyscallGetter Getter = GetterFunc(Get)
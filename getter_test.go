@@ -0,0 +1,53 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcpinfo_test
+
+import (
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mikioh/tcpinfo"
+	"github.com/mikioh/tcpinfo/tcpinfotest"
+)
+
+func TestMonitorWithGetter(t *testing.T) {
+	c := tcpinfotest.NewConn("192.0.2.1:443", "192.0.2.2:50000")
+	g := tcpinfotest.NewGetter()
+	g.Set(c, tcpinfotest.NewInfo().RTT(20*time.Millisecond, time.Millisecond).Build())
+
+	var mu sync.Mutex
+	var n int
+	m := tcpinfo.NewMonitorWithGetter(g, time.Millisecond, func(_ net.Conn, s *tcpinfo.Sample) {
+		mu.Lock()
+		n++
+		mu.Unlock()
+	})
+	m.Add(c)
+	for m.Latest(c) == nil {
+		time.Sleep(time.Millisecond)
+	}
+	fs := m.Remove(c)
+	if fs.Err != nil || fs.Info.RTT != 20*time.Millisecond || fs.MinRTT != 20*time.Millisecond {
+		t.Fatalf("got %+v", fs)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if n < 2 || g.Calls(c) != n {
+		t.Fatalf("got %d samples, %d calls", n, g.Calls(c))
+	}
+}
+
+func TestSamplerWithGetterFunc(t *testing.T) {
+	c := tcpinfotest.NewConn("192.0.2.1:443", "192.0.2.2:50000")
+	g := tcpinfo.GetterFunc(func(net.Conn) (*tcpinfo.Info, error) {
+		return nil, tcpinfo.ErrNotSupported
+	})
+	fs := tcpinfo.NewSamplerWithGetter(g, c, 0, nil).Stop()
+	if fs.Err != tcpinfo.ErrNotSupported || fs.Info != nil {
+		t.Fatalf("got %+v", fs)
+	}
+}
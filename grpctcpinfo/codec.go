@@ -0,0 +1,181 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package grpctcpinfo
+
+import (
+	"fmt"
+
+	"github.com/mikioh/tcpinfo"
+	"github.com/mikioh/tcpinfo/tcpinfopb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/encoding"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// contentSubtype is the content-subtype of messages of the collector
+// service, see collector.proto.
+const contentSubtype = "tcpinfo"
+
+const streamMethod = "/tcpinfo.collector.Collector/Stream"
+
+var streamDesc = grpc.StreamDesc{StreamName: "Stream", ServerStreams: true, ClientStreams: true}
+
+func init() {
+	encoding.RegisterCodec(codec{})
+}
+
+// A message represents a message encoded by codec.
+type message interface {
+	marshal() []byte
+	unmarshal(b []byte) error
+}
+
+// A codec implements encoding.Codec for the messages of the
+// collector service in the protocol buffers wire format.
+type codec struct{}
+
+func (codec) Marshal(v interface{}) ([]byte, error) {
+	m, ok := v.(message)
+	if !ok {
+		return nil, fmt.Errorf("unexpected message type %T", v)
+	}
+	return m.marshal(), nil
+}
+
+func (codec) Unmarshal(b []byte, v interface{}) error {
+	m, ok := v.(message)
+	if !ok {
+		return fmt.Errorf("unexpected message type %T", v)
+	}
+	return m.unmarshal(b)
+}
+
+func (codec) Name() string { return contentSubtype }
+
+// A record represents a sample on a connection in a batch.
+type record struct {
+	id     tcpinfo.ConnID
+	sample []byte // sample encoded by package tcpinfopb
+}
+
+// A batch represents a batch of samples.
+type batch struct {
+	seq     uint64
+	source  string
+	records []record
+}
+
+func (b *batch) marshal() []byte {
+	var m []byte
+	if b.seq != 0 {
+		m = protowire.AppendTag(m, 1, protowire.VarintType)
+		m = protowire.AppendVarint(m, b.seq)
+	}
+	if b.source != "" {
+		m = protowire.AppendTag(m, 2, protowire.BytesType)
+		m = protowire.AppendString(m, b.source)
+	}
+	for _, r := range b.records {
+		var rm []byte
+		rm = protowire.AppendTag(rm, 1, protowire.BytesType)
+		rm = protowire.AppendString(rm, r.id.Local)
+		rm = protowire.AppendTag(rm, 2, protowire.BytesType)
+		rm = protowire.AppendString(rm, r.id.Remote)
+		rm = protowire.AppendTag(rm, 3, protowire.BytesType)
+		rm = protowire.AppendBytes(rm, r.sample)
+		m = protowire.AppendTag(m, 3, protowire.BytesType)
+		m = protowire.AppendBytes(m, rm)
+	}
+	return m
+}
+
+func (b *batch) unmarshal(m []byte) error {
+	*b = batch{}
+	return consume(m, func(num protowire.Number, v uint64, f []byte) error {
+		switch num {
+		case 1:
+			b.seq = v
+		case 2:
+			b.source = string(f)
+		case 3:
+			var r record
+			if err := consume(f, func(num protowire.Number, _ uint64, f []byte) error {
+				switch num {
+				case 1:
+					r.id.Local = string(f)
+				case 2:
+					r.id.Remote = string(f)
+				case 3:
+					r.sample = append([]byte(nil), f...)
+				}
+				return nil
+			}); err != nil {
+				return err
+			}
+			b.records = append(b.records, r)
+		}
+		return nil
+	})
+}
+
+// An ack represents an acknowledgment of batches.
+type ack struct {
+	seq uint64
+}
+
+func (a *ack) marshal() []byte {
+	var m []byte
+	m = protowire.AppendTag(m, 1, protowire.VarintType)
+	return protowire.AppendVarint(m, a.seq)
+}
+
+func (a *ack) unmarshal(m []byte) error {
+	*a = ack{}
+	return consume(m, func(num protowire.Number, v uint64, _ []byte) error {
+		if num == 1 {
+			a.seq = v
+		}
+		return nil
+	})
+}
+
+// consume calls fn for each varint or length-delimited field in b.
+// Fields of other wire types are skipped.
+func consume(b []byte, fn func(num protowire.Number, v uint64, m []byte) error) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+		var v uint64
+		var m []byte
+		switch typ {
+		case protowire.VarintType:
+			v, n = protowire.ConsumeVarint(b)
+		case protowire.BytesType:
+			m, n = protowire.ConsumeBytes(b)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, b)
+			if n < 0 {
+				return protowire.ParseError(n)
+			}
+			b = b[n:]
+			continue
+		}
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+		if err := fn(num, v, m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func decodeSample(r record) (*tcpinfopb.Sample, error) {
+	return tcpinfopb.Unmarshal(r.sample)
+}
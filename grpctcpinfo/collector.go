@@ -0,0 +1,63 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package grpctcpinfo
+
+import (
+	"github.com/mikioh/tcpinfo"
+	"github.com/mikioh/tcpinfo/tcpinfopb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// A CollectorFunc receives a sample on the connection id streamed
+// from the source.
+// The sample carries the derived statistics in place of the
+// platform-specific information, see package tcpinfopb.
+//
+// A batch of samples is acknowledged when the function returns nil
+// for all the samples in the batch; otherwise the stream fails and
+// the client sends the batch again, which may deliver samples more
+// than once.
+type CollectorFunc func(source string, id tcpinfo.ConnID, s *tcpinfopb.Sample) error
+
+// RegisterCollector registers the collector service receiving
+// samples streamed by StreamSinks with srv.
+// The function fn is invoked concurrently for samples on different
+// streams.
+func RegisterCollector(srv *grpc.Server, fn CollectorFunc) {
+	srv.RegisterService(&grpc.ServiceDesc{
+		ServiceName: "tcpinfo.collector.Collector",
+		HandlerType: (*interface{})(nil),
+		Streams: []grpc.StreamDesc{{
+			StreamName:    "Stream",
+			Handler:       func(_ interface{}, st grpc.ServerStream) error { return serveStream(st, fn) },
+			ServerStreams: true,
+			ClientStreams: true,
+		}},
+		Metadata: "collector.proto",
+	}, nil)
+}
+
+func serveStream(st grpc.ServerStream, fn CollectorFunc) error {
+	for {
+		var b batch
+		if err := st.RecvMsg(&b); err != nil {
+			return err
+		}
+		for _, r := range b.records {
+			s, err := decodeSample(r)
+			if err != nil {
+				return status.Errorf(codes.InvalidArgument, "batch %d: %v", b.seq, err)
+			}
+			if err := fn(b.source, r.id, s); err != nil {
+				return status.Errorf(codes.Unavailable, "batch %d: %v", b.seq, err)
+			}
+		}
+		if err := st.SendMsg(&ack{seq: b.seq}); err != nil {
+			return err
+		}
+	}
+}
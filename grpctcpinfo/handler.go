@@ -0,0 +1,221 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package grpctcpinfo implements a gRPC stats handler taking samples
+// of TCP connection information.
+//
+// The handler takes a sample when a connection begins and ends, and
+// when an RPC begins and ends on the connection.
+// As gRPC does not expose connections to stats handlers, connections
+// are identified by their local and remote addresses and connection
+// information is retrieved via a getter looking up connections by
+// addresses, such as a sock_diag connection.
+//
+// Example:
+//
+//	c, err := sockdiag.Dial()
+//	if err != nil {
+//		// error handling
+//	}
+//	col := promtcpinfo.NewCollector(promtcpinfo.Opts{})
+//	h := &grpctcpinfo.Handler{Getter: c, Func: col.Observe}
+//	srv := grpc.NewServer(grpc.StatsHandler(h))
+//
+// The package also provides a stream sink, which streams samples to
+// a collector service in batches, and a reference implementation of
+// the collector service.
+//
+// Example:
+//
+//	sk := grpctcpinfo.NewStreamSink(cc, grpctcpinfo.StreamSinkOpts{Source: "relay-1"})
+//	defer sk.Close()
+//	grpctcpinfo.RegisterCollector(srv, func(source string, id tcpinfo.ConnID, s *tcpinfopb.Sample) error {
+//		// store the sample
+//		return nil
+//	})
+package grpctcpinfo
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/mikioh/tcpinfo"
+	"google.golang.org/grpc/stats"
+)
+
+var _ stats.Handler = &Handler{}
+
+// An RPC represents connection information on the connection behind
+// an RPC.
+//
+// The connection may carry concurrent RPCs; the changes between
+// samples are those of the connection, not of the RPC.
+type RPC struct {
+	Method string          // full method name
+	Client bool            // whether the RPC is on the client side
+	Conn   net.Conn        // connection carrying the RPC; nil when unknown
+	Start  *tcpinfo.Sample // sample taken when the RPC began; on the client side, when the header was sent
+	End    *tcpinfo.Sample // sample taken when the RPC ended
+	Delta  *tcpinfo.Delta  // changes between Start and End
+	Err    error           // error of the RPC
+}
+
+// A Handler implements stats.Handler of gRPC.
+type Handler struct {
+	Getter  tcpinfo.Getter                    // getter looking up connections by addresses, such as *sockdiag.Conn
+	Func    tcpinfo.SampleFunc                // callback function receiving samples, such as the Observe method of metrics bridges; may be nil
+	RPCFunc func(ctx context.Context, r *RPC) // callback function invoked when an RPC ends; may be nil
+
+	mu    sync.Mutex
+	conns map[string]*addrConn
+}
+
+type connKey struct{}
+
+type rpcKey struct{}
+
+// An rpcState represents the state of an RPC in progress.
+type rpcState struct {
+	mu  sync.Mutex
+	rpc RPC
+}
+
+// TagConn implements the TagConn method of stats.Handler interface.
+func (h *Handler) TagConn(ctx context.Context, info *stats.ConnTagInfo) context.Context {
+	return context.WithValue(ctx, connKey{}, h.conn(info.LocalAddr, info.RemoteAddr, true))
+}
+
+// HandleConn implements the HandleConn method of stats.Handler
+// interface.
+func (h *Handler) HandleConn(ctx context.Context, s stats.ConnStats) {
+	c, _ := ctx.Value(connKey{}).(*addrConn)
+	if c == nil {
+		return
+	}
+	switch s.(type) {
+	case *stats.ConnBegin:
+		h.sample(c, false)
+	case *stats.ConnEnd:
+		h.sample(c, true)
+		h.mu.Lock()
+		if h.conns[c.key()] == c {
+			delete(h.conns, c.key())
+		}
+		h.mu.Unlock()
+	}
+}
+
+// TagRPC implements the TagRPC method of stats.Handler interface.
+func (h *Handler) TagRPC(ctx context.Context, info *stats.RPCTagInfo) context.Context {
+	return context.WithValue(ctx, rpcKey{}, &rpcState{rpc: RPC{Method: info.FullMethodName}})
+}
+
+// HandleRPC implements the HandleRPC method of stats.Handler
+// interface.
+func (h *Handler) HandleRPC(ctx context.Context, s stats.RPCStats) {
+	st, _ := ctx.Value(rpcKey{}).(*rpcState)
+	if st == nil {
+		return
+	}
+	st.mu.Lock()
+	r := &st.rpc
+	switch s := s.(type) {
+	case *stats.Begin:
+		r.Client = s.Client
+		if c, _ := ctx.Value(connKey{}).(*addrConn); c != nil && r.Start == nil {
+			r.Conn, r.Start = c, h.sample(c, false)
+		}
+	case *stats.OutHeader:
+		if s.Client && r.Start == nil {
+			c := h.conn(s.LocalAddr, s.RemoteAddr, false)
+			r.Conn, r.Start = c, h.sample(c, false)
+		}
+	case *stats.InHeader:
+		if !s.Client && r.Start == nil {
+			c := h.conn(s.LocalAddr, s.RemoteAddr, false)
+			r.Conn, r.Start = c, h.sample(c, false)
+		}
+	case *stats.End:
+		r.Client, r.Err = s.Client, s.Error
+		if r.Conn != nil {
+			r.End = h.sample(r.Conn, false)
+			r.Delta = tcpinfo.Diff(r.Start, r.End)
+		}
+		rpc := *r
+		st.mu.Unlock()
+		if h.RPCFunc != nil {
+			h.RPCFunc(ctx, &rpc)
+		}
+		return
+	}
+	st.mu.Unlock()
+}
+
+// conn returns the connection identified by laddr and raddr.
+// The connection is registered when reg is true, so that samples on
+// a connection are delivered with the same value.
+func (h *Handler) conn(laddr, raddr net.Addr, reg bool) *addrConn {
+	c := &addrConn{laddr: laddr, raddr: raddr}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if rc := h.conns[c.key()]; rc != nil {
+		return rc
+	}
+	if reg {
+		if h.conns == nil {
+			h.conns = make(map[string]*addrConn)
+		}
+		h.conns[c.key()] = c
+	}
+	return c
+}
+
+func (h *Handler) sample(c net.Conn, final bool) *tcpinfo.Sample {
+	s := &tcpinfo.Sample{Final: final}
+	s.Stamp()
+	if h.Getter == nil {
+		s.Err = errNoGetter
+	} else {
+		s.Info, s.Err = h.Getter.Get(c)
+	}
+	if h.Func != nil {
+		h.Func(c, s)
+	}
+	return s
+}
+
+var (
+	errNoGetter = errors.New("no getter")
+	errAddrConn = errors.New("connection identified by addresses only")
+)
+
+// An addrConn represents a connection identified by its local and
+// remote addresses.
+type addrConn struct {
+	laddr net.Addr
+	raddr net.Addr
+}
+
+func (c *addrConn) key() string {
+	return addrString(c.laddr) + " " + addrString(c.raddr)
+}
+
+func addrString(a net.Addr) string {
+	if a == nil {
+		return ""
+	}
+	return a.String()
+}
+
+func (c *addrConn) Read([]byte) (int, error)         { return 0, errAddrConn }
+func (c *addrConn) Write([]byte) (int, error)        { return 0, errAddrConn }
+func (c *addrConn) Close() error                     { return nil }
+func (c *addrConn) LocalAddr() net.Addr              { return c.laddr }
+func (c *addrConn) RemoteAddr() net.Addr             { return c.raddr }
+func (c *addrConn) SetDeadline(time.Time) error      { return errAddrConn }
+func (c *addrConn) SetReadDeadline(time.Time) error  { return errAddrConn }
+func (c *addrConn) SetWriteDeadline(time.Time) error { return errAddrConn }
@@ -0,0 +1,103 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package grpctcpinfo_test
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mikioh/tcpinfo"
+	"github.com/mikioh/tcpinfo/grpctcpinfo"
+	"github.com/mikioh/tcpinfo/tcpinfotest"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// A recorder records the samples and RPCs delivered by a handler.
+type recorder struct {
+	mu      sync.Mutex
+	samples map[net.Conn][]*tcpinfo.Sample
+	rpcs    []*grpctcpinfo.RPC
+}
+
+func newHandler(rec *recorder) *grpctcpinfo.Handler {
+	var mu sync.Mutex
+	var n time.Duration
+	return &grpctcpinfo.Handler{
+		Getter: tcpinfo.GetterFunc(func(c net.Conn) (*tcpinfo.Info, error) {
+			if _, ok := c.LocalAddr().(*net.TCPAddr); !ok {
+				return nil, tcpinfo.ErrNotSupported
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			n++
+			return tcpinfotest.NewInfo().RTT(n*time.Millisecond, 0).Build(), nil
+		}),
+		Func: func(c net.Conn, s *tcpinfo.Sample) {
+			rec.mu.Lock()
+			rec.samples[c] = append(rec.samples[c], s)
+			rec.mu.Unlock()
+		},
+		RPCFunc: func(_ context.Context, r *grpctcpinfo.RPC) {
+			rec.mu.Lock()
+			rec.rpcs = append(rec.rpcs, r)
+			rec.mu.Unlock()
+		},
+	}
+}
+
+func TestHandler(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srec := &recorder{samples: make(map[net.Conn][]*tcpinfo.Sample)}
+	srv := grpc.NewServer(grpc.StatsHandler(newHandler(srec)))
+	healthpb.RegisterHealthServer(srv, health.NewServer())
+	go srv.Serve(ln)
+
+	crec := &recorder{samples: make(map[net.Conn][]*tcpinfo.Sample)}
+	cc, err := grpc.NewClient(ln.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()), grpc.WithStatsHandler(newHandler(crec)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for j := 0; j < 2; j++ {
+		if _, err := healthpb.NewHealthClient(cc).Check(context.Background(), &healthpb.HealthCheckRequest{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cc.Close()
+	srv.GracefulStop()
+
+	for _, rec := range []*recorder{srec, crec} {
+		rec.mu.Lock()
+		if len(rec.rpcs) != 2 {
+			t.Fatalf("got %d rpcs; want 2", len(rec.rpcs))
+		}
+		for _, r := range rec.rpcs {
+			if r.Method != "/grpc.health.v1.Health/Check" || r.Client != (rec == crec) || r.Err != nil {
+				t.Fatalf("got %+v", r)
+			}
+			if r.Conn == nil || r.Start == nil || r.End == nil || r.Start.Err != nil || r.End.Err != nil || r.End.Info.RTT <= r.Start.Info.RTT || r.Delta == nil {
+				t.Fatalf("got %+v", r)
+			}
+		}
+		if rec == srec {
+			if len(rec.samples) != 1 {
+				t.Fatalf("got samples on %d connections; want 1", len(rec.samples))
+			}
+			ss := rec.samples[rec.rpcs[0].Conn]
+			if len(ss) != 6 || ss[0].Final || !ss[len(ss)-1].Final {
+				t.Fatalf("got %d samples, first final %v, last final %v", len(ss), ss[0].Final, ss[len(ss)-1].Final)
+			}
+		}
+		rec.mu.Unlock()
+	}
+}
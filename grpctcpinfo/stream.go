@@ -0,0 +1,275 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package grpctcpinfo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mikioh/tcpinfo"
+	"github.com/mikioh/tcpinfo/tcpinfopb"
+	"google.golang.org/grpc"
+)
+
+// StreamSinkOpts represents options for a stream sink.
+type StreamSinkOpts struct {
+	Source        string        // name of source, such as host name
+	BatchSize     int           // maximum # of samples in a batch; defaults to 100
+	FlushInterval time.Duration // maximum delay of a partial batch; defaults to 1s
+	QueueSize     int           // # of samples queued before Write fails with ErrSinkFull; defaults to 10000
+	MaxInFlight   int           // maximum # of unacknowledged batches; defaults to 8
+	MinBackoff    time.Duration // initial delay of retry; defaults to 100ms
+	MaxBackoff    time.Duration // maximum delay of retry; defaults to 30s
+	CloseTimeout  time.Duration // maximum wait for acknowledgments on Close; defaults to 10s
+}
+
+// A StreamSink is a sink streaming samples to a collector service,
+// see RegisterCollector.
+//
+// Samples are sent in batches over a stream, which is re-established
+// with exponential backoff on failure, and unacknowledged batches are
+// sent again.
+// When the collector falls behind, samples are queued up to the
+// queue size and then rejected by Write with tcpinfo.ErrSinkFull.
+type StreamSink struct {
+	cc   grpc.ClientConnInterface
+	opts StreamSinkOpts
+
+	mu      sync.RWMutex // serializes Write against Close
+	closed  bool
+	recs    chan tcpinfo.SinkRecord
+	done    chan struct{}
+	stopped chan struct{}
+	once    sync.Once
+	err     error // error on Close
+
+	dropped uint64 // accessed atomically
+}
+
+var errClosed = errors.New("sink closed")
+
+// NewStreamSink returns a new stream sink sending samples to the
+// collector service on cc.
+func NewStreamSink(cc grpc.ClientConnInterface, opts StreamSinkOpts) *StreamSink {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 100
+	}
+	if opts.FlushInterval <= 0 {
+		opts.FlushInterval = time.Second
+	}
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = 10000
+	}
+	if opts.MaxInFlight <= 0 {
+		opts.MaxInFlight = 8
+	}
+	if opts.MinBackoff <= 0 {
+		opts.MinBackoff = 100 * time.Millisecond
+	}
+	if opts.MaxBackoff <= 0 {
+		opts.MaxBackoff = 30 * time.Second
+	}
+	if opts.CloseTimeout <= 0 {
+		opts.CloseTimeout = 10 * time.Second
+	}
+	s := &StreamSink{
+		cc:      cc,
+		opts:    opts,
+		recs:    make(chan tcpinfo.SinkRecord, opts.QueueSize),
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	go s.run()
+	return s
+}
+
+// Write implements the Write method of tcpinfo.Sink interface.
+// It queues the sample without blocking.
+func (s *StreamSink) Write(id tcpinfo.ConnID, smp *tcpinfo.Sample) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.closed {
+		return errClosed
+	}
+	select {
+	case s.recs <- tcpinfo.SinkRecord{ID: id, Sample: smp}:
+		return nil
+	default:
+		atomic.AddUint64(&s.dropped, 1)
+		return tcpinfo.ErrSinkFull
+	}
+}
+
+// Dropped returns the # of samples rejected by Write because the
+// queue was full, and of samples failing to be encoded.
+func (s *StreamSink) Dropped() uint64 { return atomic.LoadUint64(&s.dropped) }
+
+// Close sends the queued samples and waits for their acknowledgments
+// up to the close timeout.
+// It returns an error when some samples are not acknowledged.
+func (s *StreamSink) Close() error {
+	s.once.Do(func() {
+		s.mu.Lock()
+		s.closed = true
+		s.mu.Unlock()
+		close(s.done)
+		<-s.stopped
+	})
+	return s.err
+}
+
+// A streamEvent represents an event on a stream.
+type streamEvent struct {
+	gen uint64 // generation of stream
+	seq uint64 // acknowledged sequence number
+	err error  // error on stream
+}
+
+func (s *StreamSink) run() {
+	defer close(s.stopped)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var (
+		seq      uint64
+		cur      []record
+		pending  []*batch // unacknowledged batches in order
+		st       grpc.ClientStream
+		stCancel context.CancelFunc
+		gen      uint64
+		backoff  = s.opts.MinBackoff
+		retry    <-chan time.Time
+		closing  <-chan time.Time
+		events   = make(chan streamEvent, 1)
+	)
+	done := s.done
+	retry = time.After(0)
+	flush := time.NewTicker(s.opts.FlushInterval)
+	defer flush.Stop()
+
+	reset := func() {
+		if st != nil {
+			st.CloseSend()
+			stCancel()
+			st, stCancel = nil, nil
+		}
+		gen++
+	}
+	fail := func() {
+		reset()
+		retry = time.After(backoff)
+		if backoff *= 2; backoff > s.opts.MaxBackoff {
+			backoff = s.opts.MaxBackoff
+		}
+	}
+	send := func(b *batch) {
+		if st == nil {
+			return
+		}
+		if err := st.SendMsg(b); err != nil {
+			fail()
+		}
+	}
+	seal := func() {
+		if len(cur) == 0 {
+			return
+		}
+		seq++
+		b := &batch{seq: seq, source: s.opts.Source, records: cur}
+		cur = nil
+		pending = append(pending, b)
+		send(b)
+	}
+	add := func(r tcpinfo.SinkRecord) {
+		b, err := tcpinfopb.Marshal(r.Sample)
+		if err != nil {
+			atomic.AddUint64(&s.dropped, 1)
+			return
+		}
+		cur = append(cur, record{id: r.ID, sample: b})
+		if len(cur) >= s.opts.BatchSize {
+			seal()
+		}
+	}
+
+	for {
+		recs := s.recs
+		if len(pending) >= s.opts.MaxInFlight {
+			recs = nil
+		}
+		if closing != nil && len(pending) == 0 && len(cur) == 0 && len(s.recs) == 0 {
+			reset()
+			return
+		}
+		select {
+		case r := <-recs:
+			add(r)
+			if closing != nil && len(s.recs) == 0 {
+				seal()
+			}
+		case <-flush.C:
+			seal()
+		case <-done:
+			closing = time.After(s.opts.CloseTimeout)
+			done = nil
+			for n := len(s.recs); n > 0 && len(pending) < s.opts.MaxInFlight; n-- {
+				add(<-s.recs)
+			}
+			seal()
+		case <-closing:
+			n := len(cur) + len(s.recs)
+			for _, b := range pending {
+				n += len(b.records)
+			}
+			s.err = fmt.Errorf("%d samples not acknowledged", n)
+			reset()
+			return
+		case <-retry:
+			retry = nil
+			sctx, scancel := context.WithCancel(ctx)
+			cs, err := s.cc.NewStream(sctx, &streamDesc, streamMethod, grpc.CallContentSubtype(contentSubtype))
+			if err != nil {
+				scancel()
+				fail()
+				continue
+			}
+			st, stCancel = cs, scancel
+			go recvAcks(cs, gen, events, s.stopped)
+			for _, b := range pending {
+				send(b)
+			}
+		case ev := <-events:
+			if ev.gen != gen {
+				continue
+			}
+			if ev.err != nil {
+				fail()
+				continue
+			}
+			backoff = s.opts.MinBackoff
+			for len(pending) > 0 && pending[0].seq <= ev.seq {
+				pending = pending[1:]
+			}
+		}
+	}
+}
+
+func recvAcks(st grpc.ClientStream, gen uint64, events chan<- streamEvent, stopped <-chan struct{}) {
+	for {
+		var a ack
+		err := st.RecvMsg(&a)
+		select {
+		case events <- streamEvent{gen: gen, seq: a.seq, err: err}:
+		case <-stopped:
+			return
+		}
+		if err != nil {
+			return
+		}
+	}
+}
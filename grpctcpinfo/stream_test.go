@@ -0,0 +1,163 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package grpctcpinfo_test
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mikioh/tcpinfo"
+	"github.com/mikioh/tcpinfo/grpctcpinfo"
+	"github.com/mikioh/tcpinfo/tcpinfopb"
+	"github.com/mikioh/tcpinfo/tcpinfotest"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+func TestStreamSink(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var mu sync.Mutex
+	var got []time.Duration
+	var fails int
+	srv := grpc.NewServer()
+	grpctcpinfo.RegisterCollector(srv, func(source string, id tcpinfo.ConnID, s *tcpinfopb.Sample) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if source != "relay-1" || id.Remote != "192.0.2.2:50000" || s.Info == nil || s.Stats == nil {
+			t.Errorf("got %s, %v, %+v", source, id, s)
+		}
+		if len(got) == 5 && fails == 0 {
+			fails++
+			return errors.New("temporarily unavailable")
+		}
+		got = append(got, s.Info.RTT)
+		return nil
+	})
+	go srv.Serve(ln)
+	defer srv.Stop()
+
+	cc, err := grpc.NewClient(ln.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cc.Close()
+	sk := grpctcpinfo.NewStreamSink(cc, grpctcpinfo.StreamSinkOpts{Source: "relay-1", BatchSize: 5, FlushInterval: 10 * time.Millisecond, MinBackoff: time.Millisecond})
+	id := tcpinfo.ConnID{Local: "192.0.2.1:443", Remote: "192.0.2.2:50000"}
+	for j := 1; j <= 12; j++ {
+		if err := sk.Write(id, &tcpinfo.Sample{Time: time.Now(), Info: tcpinfotest.NewInfo().RTT(time.Duration(j)*time.Millisecond, 0).Build()}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := sk.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := sk.Write(id, &tcpinfo.Sample{}); err == nil {
+		t.Fatal("got nil; want an error after close")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if fails != 1 || len(got) < 12 {
+		t.Fatalf("got %d samples with %d failures; want 12 with 1 failure", len(got), fails)
+	}
+	seen := make(map[time.Duration]bool)
+	for _, rtt := range got {
+		seen[rtt] = true
+	}
+	for j := 1; j <= 12; j++ {
+		if !seen[time.Duration(j)*time.Millisecond] {
+			t.Fatalf("sample #%d not received: %v", j, got)
+		}
+	}
+}
+
+func TestStreamSinkConcurrentClose(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var mu sync.Mutex
+	var got int
+	srv := grpc.NewServer()
+	grpctcpinfo.RegisterCollector(srv, func(string, tcpinfo.ConnID, *tcpinfopb.Sample) error {
+		mu.Lock()
+		got++
+		mu.Unlock()
+		return nil
+	})
+	go srv.Serve(ln)
+	defer srv.Stop()
+
+	cc, err := grpc.NewClient(ln.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cc.Close()
+	sk := grpctcpinfo.NewStreamSink(cc, grpctcpinfo.StreamSinkOpts{FlushInterval: time.Millisecond})
+	id := tcpinfo.ConnID{Local: "192.0.2.1:443", Remote: "192.0.2.2:50000"}
+	var wg sync.WaitGroup
+	var accepted int64
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				err := sk.Write(id, &tcpinfo.Sample{Time: time.Now()})
+				if err == nil {
+					atomic.AddInt64(&accepted, 1)
+					continue
+				}
+				if err != tcpinfo.ErrSinkFull {
+					return
+				}
+			}
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	if err := sk.Close(); err != nil {
+		t.Fatal(err)
+	}
+	wg.Wait()
+	mu.Lock()
+	defer mu.Unlock()
+	if n := atomic.LoadInt64(&accepted); int64(got) < n {
+		t.Fatalf("got %d samples; want %d accepted by Write", got, n)
+	}
+}
+
+func TestStreamSinkBackpressure(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+	cc, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cc.Close()
+	sk := grpctcpinfo.NewStreamSink(cc, grpctcpinfo.StreamSinkOpts{BatchSize: 1, QueueSize: 2, MaxInFlight: 1, CloseTimeout: 10 * time.Millisecond})
+	id := tcpinfo.ConnID{Local: "192.0.2.1:443", Remote: "192.0.2.2:50000"}
+	var full int
+	for j := 0; j < 10; j++ {
+		if err := sk.Write(id, &tcpinfo.Sample{Time: time.Now()}); err == tcpinfo.ErrSinkFull {
+			full++
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if full == 0 || sk.Dropped() != uint64(full) {
+		t.Fatalf("got %d rejected, %d dropped; want some rejected", full, sk.Dropped())
+	}
+	if err := sk.Close(); err == nil {
+		t.Fatal("got nil; want an error for unacknowledged samples")
+	}
+}
@@ -0,0 +1,75 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcpinfo
+
+import (
+	"net"
+	"syscall"
+)
+
+// A Handle represents a connection bound for repeated retrieval of
+// connection information.
+//
+// The raw network connection is resolved once by Bind, which saves
+// the type assertion, lookup and allocation on each retrieval;
+// GetInto and GetFields on a handle allocate nothing once the memory
+// of the Info is in place.
+type Handle struct {
+	c  net.Conn
+	rc syscall.RawConn
+}
+
+// Bind returns a new handle bound to c.
+// The connection must implement syscall.Conn.
+func Bind(c net.Conn) (*Handle, error) {
+	rc, err := rawConn(c)
+	if err != nil {
+		return nil, err
+	}
+	return &Handle{c: c, rc: rc}, nil
+}
+
+// Conn returns the bound connection.
+func (h *Handle) Conn() net.Conn { return h.c }
+
+// Get returns connection information on the bound connection.
+func (h *Handle) Get() (*Info, error) {
+	i := new(Info)
+	if err := h.GetInto(i); err != nil {
+		return nil, err
+	}
+	return i, nil
+}
+
+// GetInto is like Get but fills in i, reusing its memory as
+// ParseInto does.
+func (h *Handle) GetInto(i *Info) error {
+	return h.GetFields(i, FieldAll)
+}
+
+// GetFields is like GetInto but fills in only the groups of fields
+// in m, as GetFields does.
+func (h *Handle) GetFields(i *Info, m FieldMask) error {
+	return getInto(h.rc, i, m)
+}
+
+// Sample takes a sample of connection information on the bound
+// connection.
+func (h *Handle) Sample() *Sample {
+	return h.sample(FieldAll)
+}
+
+// sample takes a sample of the groups of fields in m.
+func (h *Handle) sample(m FieldMask) *Sample {
+	s := &Sample{}
+	s.Stamp()
+	i := new(Info)
+	if err := h.GetFields(i, m); err != nil {
+		s.Err = err
+		return s
+	}
+	s.Info = i
+	return s
+}
@@ -0,0 +1,115 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcpinfo_test
+
+import (
+	"net"
+	"runtime"
+	"testing"
+
+	"github.com/mikioh/tcpinfo"
+)
+
+func TestHandle(t *testing.T) {
+	switch runtime.GOOS {
+	case "darwin", "freebsd", "linux", "netbsd":
+	default:
+		t.Skipf("not supported on %s/%s", runtime.GOOS, runtime.GOARCH)
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	c, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	h, err := tcpinfo.Bind(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.Conn() != c {
+		t.Fatalf("got %v; want %v", h.Conn(), c)
+	}
+	smp := h.Sample()
+	if smp.Err != nil {
+		t.Fatal(smp.Err)
+	}
+	if smp.Info.State != tcpinfo.Established || smp.Time.IsZero() {
+		t.Fatalf("got %+v", smp)
+	}
+	var i tcpinfo.Info
+	if err := h.GetFields(&i, tcpinfo.FieldFlowControl); err != nil {
+		t.Fatal(err)
+	}
+	if i.State != tcpinfo.Established || i.FlowControl == nil || i.Queue != nil || i.Sys != nil {
+		t.Fatalf("got %+v; want only state, durations and FlowControl", i)
+	}
+	c.Close()
+	if _, err := h.Get(); err == nil {
+		t.Fatal("got nil; want an error")
+	}
+
+	pc, _ := net.Pipe()
+	defer pc.Close()
+	if _, err := tcpinfo.Bind(pc); err == nil {
+		t.Fatal("got nil; want an error")
+	}
+}
+
+func TestHandleGetIntoAllocs(t *testing.T) {
+	switch runtime.GOOS {
+	case "darwin", "freebsd", "linux", "netbsd":
+	default:
+		t.Skipf("not supported on %s/%s", runtime.GOOS, runtime.GOARCH)
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	c, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	h, err := tcpinfo.Bind(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var i tcpinfo.Info
+	if err := h.GetInto(&i); err != nil {
+		t.Fatal(err)
+	}
+	if n := testing.AllocsPerRun(100, func() {
+		if err := h.GetInto(&i); err != nil {
+			t.Fatal(err)
+		}
+	}); n != 0 {
+		t.Fatalf("got %v allocs; want 0", n)
+	}
+}
+
+func BenchmarkHandleGetInto(b *testing.B) {
+	c, ac := benchmarkConns(b)
+	defer c.Close()
+	defer ac.Close()
+	h, err := tcpinfo.Bind(c)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	var ti tcpinfo.Info
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := h.GetInto(&ti); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcpinfo
+
+import "math/bits"
+
+// histogramBits is the # of bits of sub-buckets in a histogram.
+// Values below 1<<histogramBits are recorded exactly, and others with
+// a relative error below 1/(1<<(histogramBits-1)).
+const histogramBits = 7
+
+// A Histogram represents a high dynamic range histogram of
+// non-negative integer values.
+//
+// Values are recorded in log-linear buckets, which bounds the
+// relative error of reported values to less than 1.6%.
+type Histogram struct {
+	counts []uint64
+	n      uint64
+	sum    uint64
+	min    uint64
+	max    uint64
+}
+
+func histogramIndex(v uint64) int {
+	if v < 1<<histogramBits {
+		return int(v)
+	}
+	e := bits.Len64(v) - histogramBits
+	return e<<(histogramBits-1) + int(v>>uint(e))
+}
+
+// histogramValue returns the highest value in the bucket b.
+func histogramValue(b int) uint64 {
+	const h = 1 << (histogramBits - 1)
+	if b < 1<<histogramBits {
+		return uint64(b)
+	}
+	e := b/h - 1
+	m := uint64(b - e*h)
+	return (m+1)<<uint(e) - 1
+}
+
+// Record records the value v.
+func (h *Histogram) Record(v uint64) {
+	b := histogramIndex(v)
+	if b >= len(h.counts) {
+		counts := make([]uint64, b+1)
+		copy(counts, h.counts)
+		h.counts = counts
+	}
+	h.counts[b]++
+	if h.n == 0 || v < h.min {
+		h.min = v
+	}
+	if v > h.max {
+		h.max = v
+	}
+	h.n++
+	h.sum += v
+}
+
+// Merge adds the values recorded in g to h.
+func (h *Histogram) Merge(g *Histogram) {
+	if g.n == 0 {
+		return
+	}
+	if len(g.counts) > len(h.counts) {
+		counts := make([]uint64, len(g.counts))
+		copy(counts, h.counts)
+		h.counts = counts
+	}
+	for b, n := range g.counts {
+		h.counts[b] += n
+	}
+	if h.n == 0 || g.min < h.min {
+		h.min = g.min
+	}
+	if g.max > h.max {
+		h.max = g.max
+	}
+	h.n += g.n
+	h.sum += g.sum
+}
+
+// Count returns the # of recorded values.
+func (h *Histogram) Count() uint64 { return h.n }
+
+// Min returns the smallest recorded value.
+func (h *Histogram) Min() uint64 { return h.min }
+
+// Max returns the largest recorded value.
+func (h *Histogram) Max() uint64 { return h.max }
+
+// Mean returns the mean of recorded values.
+func (h *Histogram) Mean() float64 {
+	if h.n == 0 {
+		return 0
+	}
+	return float64(h.sum) / float64(h.n)
+}
+
+// Quantile returns the value at the quantile q, such as 0.99 for the
+// 99th percentile.
+// It returns zero when no value is recorded.
+func (h *Histogram) Quantile(q float64) uint64 {
+	if h.n == 0 {
+		return 0
+	}
+	rank := uint64(q*float64(h.n) + 0.5)
+	if rank < 1 {
+		rank = 1
+	}
+	var n uint64
+	for b, c := range h.counts {
+		n += c
+		if n >= rank {
+			v := histogramValue(b)
+			if v > h.max {
+				v = h.max
+			}
+			if v < h.min {
+				v = h.min
+			}
+			return v
+		}
+	}
+	return h.max
+}
+
+func (h *Histogram) clone() *Histogram {
+	g := *h
+	g.counts = append([]uint64(nil), h.counts...)
+	return &g
+}
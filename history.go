@@ -0,0 +1,54 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcpinfo
+
+import "sync"
+
+// A History represents a time series of samples of connection
+// information on a connection.
+// It is safe for concurrent use by multiple goroutines.
+type History struct {
+	max int
+
+	mu      sync.Mutex
+	samples []*Sample
+}
+
+// NewHistory returns a new history that keeps the latest max
+// samples.
+// A non-positive max means no limit.
+func NewHistory(max int) *History {
+	return &History{max: max}
+}
+
+// Add appends the sample s.
+// Samples must be added in time order.
+func (h *History) Add(s *Sample) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.max > 0 && len(h.samples) >= h.max {
+		n := copy(h.samples, h.samples[len(h.samples)-h.max+1:])
+		for j := n; j < len(h.samples); j++ {
+			h.samples[j] = nil
+		}
+		h.samples = h.samples[:n]
+	}
+	h.samples = append(h.samples, s)
+}
+
+// Samples returns a snapshot of the samples in time order.
+// The samples are shared and must not be modified.
+func (h *History) Samples() []*Sample {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]*Sample(nil), h.samples...)
+}
+
+// Len returns the # of samples.
+func (h *History) Len() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.samples)
+}
@@ -0,0 +1,61 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcpinfo_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mikioh/tcpinfo"
+	"github.com/mikioh/tcpinfo/tcpinfotest"
+)
+
+func TestHistory(t *testing.T) {
+	h := tcpinfo.NewHistory(3)
+	start := time.Unix(0, 0)
+	for j := 0; j < 5; j++ {
+		h.Add(&tcpinfo.Sample{Time: start.Add(time.Duration(j) * time.Second)})
+	}
+	if ss := h.Samples(); h.Len() != 3 || !ss[0].Time.Equal(start.Add(2*time.Second)) || !ss[2].Time.Equal(start.Add(4*time.Second)) {
+		t.Fatalf("got %d samples from %v", h.Len(), ss[0].Time)
+	}
+}
+
+func TestHistoryDownsample(t *testing.T) {
+	h := tcpinfo.NewHistory(0)
+	start := time.Unix(60, 0)
+	for j := 0; j < 150; j++ {
+		s := &tcpinfo.Sample{Time: start.Add(time.Duration(j) * time.Second)}
+		if j == 70 {
+			s.Err = tcpinfo.ErrNotSupported
+		} else {
+			s.Info = tcpinfotest.NewInfo().RTT(time.Duration(j%60+1)*time.Millisecond, time.Millisecond).Build()
+		}
+		h.Add(s)
+	}
+	bs := h.Downsample(time.Minute)
+	if len(bs) != 3 {
+		t.Fatalf("got %d buckets; want 3", len(bs))
+	}
+	b := bs[0]
+	if !b.Start.Equal(start) || b.Interval != time.Minute || b.Samples != 60 || b.Errors != 0 {
+		t.Fatalf("got %+v", b)
+	}
+	if b.RTT != (tcpinfo.DurationStats{Min: time.Millisecond, Mean: 30500 * time.Microsecond, Max: 60 * time.Millisecond}) {
+		t.Fatalf("got %+v", b.RTT)
+	}
+	if b.SenderWindow != (tcpinfo.ValueStats{Min: 10, Mean: 10, Max: 10}) {
+		t.Fatalf("got %+v", b.SenderWindow)
+	}
+	if b.Delta == nil || b.Delta.Duration != 59*time.Second {
+		t.Fatalf("got %+v", b.Delta)
+	}
+	if b := bs[1]; b.Samples != 59 || b.Errors != 1 || b.Delta == nil || b.Delta.Duration != time.Minute {
+		t.Fatalf("got %+v", b)
+	}
+	if b := bs[2]; b.Samples != 30 || b.Delta.Duration != 30*time.Second {
+		t.Fatalf("got %+v", b)
+	}
+}
@@ -0,0 +1,80 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcpinfo
+
+import "time"
+
+// A HostStats represents a snapshot of TCP counters of the host, or
+// of the network namespace on Linux.
+//
+// Unlike connection information, the counters cover all connections,
+// including those already closed, such as for telling whether
+// retransmission timeouts are specific to a connection.
+type HostStats struct {
+	Time            time.Time         `json:"time"`               // time when the snapshot was taken
+	Duration        time.Duration     `json:"duration,omitempty"` // time elapsed since the previous snapshot; set by Sub only
+	ActiveOpens     uint64            `json:"active_opens"`       // # of connection attempts
+	PassiveOpens    uint64            `json:"passive_opens"`      // # of connections accepted
+	AttemptFails    uint64            `json:"attempt_fails"`      // # of connection attempts failed
+	EstabResets     uint64            `json:"estab_resets"`       // # of established connections reset or dropped
+	InSegs          uint64            `json:"in_segs"`            // # of segments received [Linux only]
+	OutSegs         uint64            `json:"out_segs"`           // # of segments sent [Darwin and Linux]
+	RetransSegs     uint64            `json:"retrans_segs"`       // # of segments retransmitted [Darwin and Linux]
+	InErrs          uint64            `json:"in_errs"`            // # of segments received in error [Linux only]
+	OutRsts         uint64            `json:"out_rsts"`           // # of resets sent [Linux only]
+	Timeouts        uint64            `json:"timeouts"`           // # of retransmission timeouts
+	LostRetransmit  uint64            `json:"lost_retransmit"`    // # of retransmissions lost [Linux only]
+	FastRetrans     uint64            `json:"fast_retrans"`       // # of fast retransmissions [Linux only]
+	ListenOverflows uint64            `json:"listen_overflows"`   // # of completed handshakes with full accept queues [Linux only]
+	ListenDrops     uint64            `json:"listen_drops"`       // # of connection requests dropped by listeners [Linux only]
+	SYNCookiesSent  uint64            `json:"syn_cookies_sent"`   // # of SYN cookies sent [Linux only]
+	Counters        map[string]uint64 `json:"counters,omitempty"` // all counters keyed by name such as "TcpExt.TCPTimeouts" [Linux only]
+}
+
+// GetHostStats returns a snapshot of TCP counters of the host.
+//
+// On Linux, the counters are read from /proc/net/snmp and
+// /proc/net/netstat, and on other platforms from the
+// net.inet.tcp.stats sysctl.
+func GetHostStats() (*HostStats, error) {
+	hs, err := getHostStats()
+	if err != nil {
+		return nil, err
+	}
+	hs.Time = time.Now()
+	return hs, nil
+}
+
+// Sub returns the changes of counters from the previous snapshot
+// prev to hs.
+// A counter that went backwards is treated as restarted from zero.
+func (hs *HostStats) Sub(prev *HostStats) *HostStats {
+	d := &HostStats{
+		Time:            hs.Time,
+		Duration:        hs.Time.Sub(prev.Time),
+		ActiveOpens:     sub(hs.ActiveOpens, prev.ActiveOpens),
+		PassiveOpens:    sub(hs.PassiveOpens, prev.PassiveOpens),
+		AttemptFails:    sub(hs.AttemptFails, prev.AttemptFails),
+		EstabResets:     sub(hs.EstabResets, prev.EstabResets),
+		InSegs:          sub(hs.InSegs, prev.InSegs),
+		OutSegs:         sub(hs.OutSegs, prev.OutSegs),
+		RetransSegs:     sub(hs.RetransSegs, prev.RetransSegs),
+		InErrs:          sub(hs.InErrs, prev.InErrs),
+		OutRsts:         sub(hs.OutRsts, prev.OutRsts),
+		Timeouts:        sub(hs.Timeouts, prev.Timeouts),
+		LostRetransmit:  sub(hs.LostRetransmit, prev.LostRetransmit),
+		FastRetrans:     sub(hs.FastRetrans, prev.FastRetrans),
+		ListenOverflows: sub(hs.ListenOverflows, prev.ListenOverflows),
+		ListenDrops:     sub(hs.ListenDrops, prev.ListenDrops),
+		SYNCookiesSent:  sub(hs.SYNCookiesSent, prev.SYNCookiesSent),
+	}
+	if hs.Counters != nil {
+		d.Counters = make(map[string]uint64, len(hs.Counters))
+		for k, v := range hs.Counters {
+			d.Counters[k] = sub(v, prev.Counters[k])
+		}
+	}
+	return d
+}
@@ -0,0 +1,43 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcpinfo_test
+
+import (
+	"net"
+	"testing"
+
+	"github.com/mikioh/tcpinfo"
+)
+
+func TestGetHostStats(t *testing.T) {
+	prev, err := tcpinfo.GetHostStats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	c, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	cur, err := tcpinfo.GetHostStats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := cur.Counters["TcpExt.ListenOverflows"]; !ok {
+		t.Fatalf("got %v; want TcpExt counters", cur.Counters)
+	}
+	d := cur.Sub(prev)
+	if d.ActiveOpens == 0 || d.PassiveOpens == 0 || d.Counters["Tcp.ActiveOpens"] != d.ActiveOpens {
+		t.Fatalf("got %+v; want connections opened", d)
+	}
+	if d.Duration <= 0 {
+		t.Fatalf("got %v; want greater than zero", d.Duration)
+	}
+}
@@ -0,0 +1,135 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package httptcpinfo provides net/http integration of TCP
+// connection information.
+//
+// Handler serves the connections tracked by a monitor, like
+// /debug/pprof does for profiles:
+//
+//	m := tcpinfo.NewMonitor(5*time.Second, nil)
+//	http.Handle("/debug/tcpinfo", httptcpinfo.Handler(m))
+//
+// The page lists connections with their latest connection
+// information as an HTML table, or as JSON when requested with
+// ?format=json or an Accept header of application/json.
+//
+// ServerHooks attaches samplers to client connections of an HTTP
+// server and lets handlers look up connection information on the
+// connection serving a request.
+// Transport takes samples of connection information behind client
+// requests.
+package httptcpinfo
+
+import (
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mikioh/tcpinfo"
+)
+
+// A Conn represents a connection served by the handler.
+type Conn struct {
+	Local  string                `json:"laddr"`
+	Remote string                `json:"raddr"`
+	Time   time.Time             `json:"time"` // time when the latest sample was taken
+	Info   *tcpinfo.Info         `json:"info"` // latest connection information; nil when not sampled yet
+	Stats  *tcpinfo.DerivedStats `json:"stats,omitempty"`
+	Err    string                `json:"error,omitempty"` // error on latest retrieval
+}
+
+// Conns returns the connections tracked by m with their latest
+// samples, sorted by local and remote addresses.
+func Conns(m *tcpinfo.Monitor) []Conn {
+	cs := m.Conns()
+	conns := make([]Conn, 0, len(cs))
+	for _, c := range cs {
+		conn := Conn{Local: addr(c.LocalAddr()), Remote: addr(c.RemoteAddr())}
+		if s := m.Latest(c); s != nil {
+			conn.Time = s.Time
+			if s.Err != nil {
+				conn.Err = s.Err.Error()
+			}
+			if s.Info != nil {
+				conn.Info, conn.Stats = s.Info, s.Info.Stats()
+			}
+		}
+		conns = append(conns, conn)
+	}
+	sort.Slice(conns, func(i, j int) bool {
+		if conns[i].Local != conns[j].Local {
+			return conns[i].Local < conns[j].Local
+		}
+		return conns[i].Remote < conns[j].Remote
+	})
+	return conns
+}
+
+func addr(a interface{ String() string }) string {
+	if a == nil {
+		return ""
+	}
+	return a.String()
+}
+
+// Handler returns an HTTP handler that serves the connections
+// tracked by m.
+func Handler(m *tcpinfo.Monitor) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conns := Conns(m)
+		if wantJSON(r) {
+			w.Header().Set("Content-Type", "application/json")
+			enc := json.NewEncoder(w)
+			enc.SetIndent("", "\t")
+			if err := enc.Encode(conns); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+			}
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err := page.Execute(w, conns); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
+
+func wantJSON(r *http.Request) bool {
+	switch r.FormValue("format") {
+	case "json":
+		return true
+	case "html":
+		return false
+	}
+	return strings.Contains(r.Header.Get("Accept"), "application/json")
+}
+
+var page = template.Must(template.New("page").Funcs(template.FuncMap{
+	"ms": func(d time.Duration) string {
+		return strconv.FormatFloat(float64(d)/float64(time.Millisecond), 'f', 3, 64)
+	},
+}).Parse(`<html>
+<head>
+<title>tcpinfo</title>
+<style>
+table { border-collapse: collapse; }
+td, th { padding: 0 0.5em; text-align: right; font-family: monospace; }
+</style>
+</head>
+<body>
+<p>{{len .}} connections (<a href="?format=json">json</a>)</p>
+<table>
+<tr><th>local</th><th>remote</th><th>state</th><th>rtt (ms)</th><th>rttvar (ms)</th><th>rto (ms)</th><th>cwnd</th><th>retrans</th><th>sent</th><th>rcvd</th><th>delivery rate (B/s)</th><th>sampled</th><th>error</th></tr>
+{{range .}}<tr><td>{{.Local}}</td><td>{{.Remote}}</td>
+{{- with .Info}}<td>{{.State}}</td><td>{{ms .RTT}}</td><td>{{ms .RTTVar}}</td><td>{{ms .RTO}}</td><td>{{with .CongestionControl}}{{.SenderWindowSegs}}{{end}}</td>{{else}}<td></td><td></td><td></td><td></td><td></td>{{end}}
+{{- with .Stats}}<td>{{.RetransSegs}}</td><td>{{.BytesSent}}</td><td>{{.BytesReceived}}</td><td>{{.DeliveryRate}}</td>{{else}}<td></td><td></td><td></td><td></td>{{end}}
+<td>{{if not .Time.IsZero}}{{.Time.Format "15:04:05.000"}}{{end}}</td><td>{{.Err}}</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
@@ -0,0 +1,83 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package httptcpinfo_test
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mikioh/tcpinfo"
+	"github.com/mikioh/tcpinfo/httptcpinfo"
+	"github.com/mikioh/tcpinfo/tcpinfotest"
+)
+
+func TestHandler(t *testing.T) {
+	c1 := tcpinfotest.NewConn("192.0.2.1:443", "192.0.2.2:50000")
+	c2 := tcpinfotest.NewConn("192.0.2.1:443", "192.0.2.3:50000")
+	g := tcpinfotest.NewGetter()
+	g.Set(c1, tcpinfotest.NewInfo().RTT(20*time.Millisecond, time.Millisecond).Build())
+	g.Script(c2, tcpinfotest.Step{Err: errors.New("gone")})
+	m := tcpinfo.NewMonitorWithGetter(g, time.Millisecond, nil)
+	defer m.Close()
+	m.Add(c2)
+	m.Add(c1)
+	for m.Latest(c1) == nil || m.Latest(c2) == nil {
+		time.Sleep(time.Millisecond)
+	}
+	h := httptcpinfo.Handler(m)
+
+	for _, tt := range []struct {
+		url, accept string
+	}{
+		{"/debug/tcpinfo?format=json", ""},
+		{"/debug/tcpinfo", "application/json"},
+	} {
+		r := httptest.NewRequest("GET", tt.url, nil)
+		r.Header.Set("Accept", tt.accept)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+			t.Fatalf("%s: got %s; want application/json", tt.url, ct)
+		}
+		var conns []struct {
+			Local  string `json:"laddr"`
+			Remote string `json:"raddr"`
+			Info   *struct {
+				State string `json:"state"`
+				RTT   int64  `json:"rtt"`
+			} `json:"info"`
+			Err string `json:"error"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &conns); err != nil {
+			t.Fatal(err)
+		}
+		if len(conns) != 2 || conns[0].Remote != "192.0.2.2:50000" || conns[1].Remote != "192.0.2.3:50000" {
+			t.Fatalf("got %+v", conns)
+		}
+		if conns[0].Info == nil || conns[0].Info.State != "established" || conns[0].Info.RTT != int64(20*time.Millisecond) || conns[0].Err != "" {
+			t.Fatalf("got %+v", conns[0])
+		}
+		if conns[1].Info != nil || conns[1].Err != "gone" {
+			t.Fatalf("got %+v", conns[1])
+		}
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/debug/tcpinfo", nil))
+	b, err := ioutil.ReadAll(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"2 connections", "<td>192.0.2.2:50000</td><td>established</td><td>20.000</td>", "<td>gone</td>"} {
+		if !strings.Contains(string(b), s) {
+			t.Fatalf("got %s; want to contain %q", b, s)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package httptcpinfo
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+
+	"github.com/mikioh/tcpinfo"
+)
+
+// A ServerHooks attaches samplers to client connections of an HTTP
+// server through its ConnContext and ConnState hooks, and makes
+// connection information available to handlers via request contexts.
+//
+// Example:
+//
+//	m := tcpinfo.NewMonitor(time.Second, nil)
+//	hooks := &httptcpinfo.ServerHooks{Monitor: m}
+//	srv := &http.Server{Handler: h}
+//	hooks.Install(srv)
+//
+// and in a handler:
+//
+//	if i, err := hooks.Info(r.Context()); err == nil && i.RTT > 100*time.Millisecond {
+//		log.Printf("slow request %s: rtt=%v retrans=%d", r.URL, i.RTT, i.Stats().RetransSegs)
+//	}
+type ServerHooks struct {
+	Monitor   *tcpinfo.Monitor  // monitor tracking client connections
+	Getter    tcpinfo.Getter    // getter used by Info; nil means tcpinfo.SyscallGetter
+	FinalFunc tcpinfo.FinalFunc // callback function receiving final statistics; may be nil
+}
+
+type connKey struct{}
+
+var errNoConn = errors.New("no connection in context")
+
+// Install sets the ConnContext and ConnState hooks of srv, chaining
+// the hooks already set.
+func (h *ServerHooks) Install(srv *http.Server) {
+	cctx, cst := srv.ConnContext, srv.ConnState
+	srv.ConnContext = func(ctx context.Context, c net.Conn) context.Context {
+		if cctx != nil {
+			ctx = cctx(ctx, c)
+		}
+		return h.ConnContext(ctx, c)
+	}
+	srv.ConnState = func(c net.Conn, st http.ConnState) {
+		h.ConnState(c, st)
+		if cst != nil {
+			cst(c, st)
+		}
+	}
+}
+
+// ConnContext returns a copy of ctx carrying c.
+// It can be used as the ConnContext hook of http.Server.
+func (h *ServerHooks) ConnContext(ctx context.Context, c net.Conn) context.Context {
+	return context.WithValue(ctx, connKey{}, netConn(c))
+}
+
+// ConnState starts tracking c on a new connection and stops tracking
+// it when the connection is hijacked or closed.
+// It can be used as the ConnState hook of http.Server.
+//
+// As the server closes a connection before reporting StateClosed,
+// the final statistics of a closed connection are built from the
+// latest sample.
+func (h *ServerHooks) ConnState(c net.Conn, st http.ConnState) {
+	c = netConn(c)
+	switch st {
+	case http.StateNew:
+		h.Monitor.Add(c)
+	case http.StateHijacked, http.StateClosed:
+		latest := h.Monitor.Latest(c)
+		fs := h.Monitor.Remove(c)
+		if fs == nil {
+			return
+		}
+		if st == http.StateClosed && fs.Err != nil && latest != nil && latest.Info != nil {
+			fs.Info, fs.Err, fs.Stats = latest.Info, nil, latest.Info.Stats()
+		}
+		if h.FinalFunc != nil {
+			h.FinalFunc(c, fs)
+		}
+	}
+}
+
+// ConnFromContext returns the client connection carried by ctx.
+// It returns nil when ctx carries no connection.
+func ConnFromContext(ctx context.Context) net.Conn {
+	c, _ := ctx.Value(connKey{}).(net.Conn)
+	return c
+}
+
+// Info returns the current connection information on the client
+// connection serving the request with ctx.
+func (h *ServerHooks) Info(ctx context.Context) (*tcpinfo.Info, error) {
+	c := ConnFromContext(ctx)
+	if c == nil {
+		return nil, errNoConn
+	}
+	g := h.Getter
+	if g == nil {
+		g = tcpinfo.SyscallGetter
+	}
+	return g.Get(c)
+}
+
+// Latest returns the latest sample on the client connection serving
+// the request with ctx.
+// It returns nil when the connection is not tracked or no sample is
+// taken yet.
+func (h *ServerHooks) Latest(ctx context.Context) *tcpinfo.Sample {
+	c := ConnFromContext(ctx)
+	if c == nil {
+		return nil
+	}
+	return h.Monitor.Latest(c)
+}
+
+// netConn returns the underlying connection of a TLS connection, so
+// that a connection is identified by the same value before and after
+// the handshake.
+func netConn(c net.Conn) net.Conn {
+	if nc, ok := c.(interface{ NetConn() net.Conn }); ok {
+		return nc.NetConn()
+	}
+	return c
+}
@@ -0,0 +1,95 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package httptcpinfo_test
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mikioh/tcpinfo"
+	"github.com/mikioh/tcpinfo/httptcpinfo"
+	"github.com/mikioh/tcpinfo/tcpinfotest"
+)
+
+func TestServerHooks(t *testing.T) {
+	g := tcpinfo.GetterFunc(func(net.Conn) (*tcpinfo.Info, error) {
+		return tcpinfotest.NewInfo().RTT(30*time.Millisecond, time.Millisecond).Build(), nil
+	})
+	final := make(chan *tcpinfo.FinalStats, 1)
+	var mu sync.Mutex
+	var states []http.ConnState
+	hooks := &httptcpinfo.ServerHooks{
+		Monitor:   tcpinfo.NewMonitorWithGetter(g, time.Millisecond, nil),
+		Getter:    g,
+		FinalFunc: func(_ net.Conn, fs *tcpinfo.FinalStats) { final <- fs },
+	}
+	defer hooks.Monitor.Close()
+
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if httptcpinfo.ConnFromContext(r.Context()) == nil {
+			http.Error(w, "no connection", http.StatusInternalServerError)
+			return
+		}
+		i, err := hooks.Info(r.Context())
+		if err != nil || i.RTT != 30*time.Millisecond {
+			http.Error(w, "unexpected connection information", http.StatusInternalServerError)
+			return
+		}
+		for hooks.Latest(r.Context()) == nil {
+			time.Sleep(time.Millisecond)
+		}
+	}))
+	ts.Config.ConnState = func(_ net.Conn, st http.ConnState) {
+		mu.Lock()
+		states = append(states, st)
+		mu.Unlock()
+	}
+	hooks.Install(ts.Config)
+	ts.Start()
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("got %s: %s", resp.Status, b)
+	}
+	if n := len(hooks.Monitor.Conns()); n != 1 {
+		t.Fatalf("got %d tracked connections; want 1", n)
+	}
+	http.DefaultClient.CloseIdleConnections()
+	select {
+	case fs := <-final:
+		if fs.Err != nil || fs.Info == nil || fs.Info.RTT != 30*time.Millisecond {
+			t.Fatalf("got %+v", fs)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for final statistics")
+	}
+	if len(hooks.Monitor.Conns()) != 0 {
+		t.Fatal("connection still tracked")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(states) == 0 || states[0] != http.StateNew {
+		t.Fatalf("got %v; want chained hook invoked", states)
+	}
+
+	if _, err := hooks.Info(context.Background()); err == nil {
+		t.Fatal("got nil; want an error")
+	}
+	if s := hooks.Latest(context.Background()); s != nil {
+		t.Fatalf("got %+v; want nil", s)
+	}
+}
@@ -0,0 +1,141 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package httptcpinfo
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+
+	"github.com/mikioh/tcpinfo"
+)
+
+// A Trace represents connection information on the connection behind
+// an HTTP request.
+//
+// Connections shared by concurrent requests, such as HTTP/2
+// connections, carry the traffic of all the requests; the changes
+// between samples are those of the connection, not of the request.
+type Trace struct {
+	Reused bool            // whether the connection was used for previous requests
+	Start  *tcpinfo.Sample // sample taken when the connection was obtained
+	End    *tcpinfo.Sample // sample taken when the response body was read or closed; nil until then
+	Delta  *tcpinfo.Delta  // changes between Start and End; nil until End is taken
+}
+
+// A Transport wraps http.RoundTripper and takes samples of connection
+// information at the start and the completion of every request.
+//
+// Example:
+//
+//	c := &http.Client{Transport: &httptcpinfo.Transport{Func: func(r *http.Request, tr *httptcpinfo.Trace) {
+//		log.Printf("%s: rtt=%v retrans=%d", r.URL, tr.End.Info.RTT, tr.Delta.RetransSegs)
+//	}}}
+type Transport struct {
+	Base   http.RoundTripper                // underlying round tripper; nil means http.DefaultTransport
+	Getter tcpinfo.Getter                   // nil means tcpinfo.SyscallGetter
+	Func   func(r *http.Request, tr *Trace) // callback function invoked on completion; may be nil
+}
+
+// RoundTrip implements the RoundTrip method of http.RoundTripper
+// interface.
+//
+// The sample at completion is taken when the response body returns
+// io.EOF or is closed.
+func (t *Transport) RoundTrip(r *http.Request) (*http.Response, error) {
+	g := t.Getter
+	if g == nil {
+		g = tcpinfo.SyscallGetter
+	}
+	b := &body{t: t, g: g, r: r}
+	ctx := httptrace.WithClientTrace(r.Context(), &httptrace.ClientTrace{
+		GotConn: func(ci httptrace.GotConnInfo) {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+			b.c = netConn(ci.Conn)
+			b.tr.Reused = ci.Reused
+			b.tr.Start = sample(g, b.c)
+		},
+	})
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	resp, err := base.RoundTrip(r.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	b.ReadCloser = resp.Body
+	resp.Body = b
+	return resp, nil
+}
+
+// ResponseTrace returns connection information on the connection
+// behind resp, which must be returned by Transport.
+// It returns nil when resp is not returned by Transport or its body
+// is replaced.
+func ResponseTrace(resp *http.Response) *Trace {
+	b, ok := resp.Body.(*body)
+	if !ok {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	tr := b.tr
+	return &tr
+}
+
+// A body wraps the body of a response and takes the sample at
+// completion.
+type body struct {
+	io.ReadCloser
+	t *Transport
+	g tcpinfo.Getter
+	r *http.Request
+
+	mu   sync.Mutex
+	c    net.Conn // nil when no connection was obtained
+	tr   Trace
+	done bool
+}
+
+func (b *body) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err == io.EOF {
+		b.complete()
+	}
+	return n, err
+}
+
+func (b *body) Close() error {
+	b.complete()
+	return b.ReadCloser.Close()
+}
+
+func (b *body) complete() {
+	b.mu.Lock()
+	if b.done || b.c == nil {
+		b.done = true
+		b.mu.Unlock()
+		return
+	}
+	b.done = true
+	b.tr.End = sample(b.g, b.c)
+	b.tr.Delta = tcpinfo.Diff(b.tr.Start, b.tr.End)
+	tr := b.tr
+	b.mu.Unlock()
+	if b.t.Func != nil {
+		b.t.Func(b.r, &tr)
+	}
+}
+
+func sample(g tcpinfo.Getter, c net.Conn) *tcpinfo.Sample {
+	s := &tcpinfo.Sample{}
+	s.Stamp()
+	s.Info, s.Err = g.Get(c)
+	return s
+}
@@ -0,0 +1,81 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package httptcpinfo_test
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mikioh/tcpinfo"
+	"github.com/mikioh/tcpinfo/httptcpinfo"
+	"github.com/mikioh/tcpinfo/tcpinfotest"
+)
+
+func TestTransport(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello"))
+	}))
+	defer ts.Close()
+
+	var mu sync.Mutex
+	var n time.Duration
+	g := tcpinfo.GetterFunc(func(net.Conn) (*tcpinfo.Info, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		n++
+		return tcpinfotest.NewInfo().RTT(n*time.Millisecond, 0).Build(), nil
+	})
+	var traces []*httptcpinfo.Trace
+	tr := &httptcpinfo.Transport{
+		Base:   &http.Transport{},
+		Getter: g,
+		Func: func(_ *http.Request, tr *httptcpinfo.Trace) {
+			mu.Lock()
+			traces = append(traces, tr)
+			mu.Unlock()
+		},
+	}
+	c := &http.Client{Transport: tr}
+	for j := 0; j < 2; j++ {
+		resp, err := c.Get(ts.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tr := httptcpinfo.ResponseTrace(resp); tr == nil || tr.Start == nil || tr.End != nil {
+			t.Fatalf("got %+v; want a trace without completion", tr)
+		}
+		if _, err := ioutil.ReadAll(resp.Body); err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		tr := httptcpinfo.ResponseTrace(resp)
+		if tr == nil || tr.Reused != (j > 0) || tr.End == nil || tr.Delta == nil || tr.Delta.Duration < 0 {
+			t.Fatalf("#%d: got %+v", j, tr)
+		}
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(traces) != 2 {
+		t.Fatalf("got %d traces; want 2", len(traces))
+	}
+	if tr := traces[0]; tr.Start.Info.RTT != time.Millisecond || tr.End.Info.RTT != 2*time.Millisecond {
+		t.Fatalf("got %v, %v; want samples at start and completion", tr.Start.Info.RTT, tr.End.Info.RTT)
+	}
+}
+
+func TestTransportError(t *testing.T) {
+	c := &http.Client{Transport: &httptcpinfo.Transport{Base: &http.Transport{}}, Timeout: time.Second}
+	if _, err := c.Get("http://127.0.0.1:1"); err == nil {
+		t.Fatal("got nil; want an error")
+	}
+	if tr := httptcpinfo.ResponseTrace(&http.Response{Body: http.NoBody}); tr != nil {
+		t.Fatalf("got %+v; want nil", tr)
+	}
+}
@@ -0,0 +1,396 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcpinfo
+
+import (
+	"encoding"
+	"encoding/json"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A DurationFormat represents a format of durations in JSON encoding.
+type DurationFormat int
+
+const (
+	DurationNanoseconds  DurationFormat = iota // integer in nanoseconds
+	DurationMicroseconds                       // integer in microseconds
+	DurationMilliseconds                       // floating-point number in milliseconds
+	DurationString                             // string such as "3.2ms"
+)
+
+func (f DurationFormat) value(d time.Duration) interface{} {
+	switch f {
+	case DurationMicroseconds:
+		return int64(d / time.Microsecond)
+	case DurationMilliseconds:
+		return float64(d) / float64(time.Millisecond)
+	case DurationString:
+		return d.String()
+	default:
+		return int64(d)
+	}
+}
+
+// suffix returns the key suffix for flat encoding.
+func (f DurationFormat) suffix() string {
+	switch f {
+	case DurationMicroseconds:
+		return "_us"
+	case DurationMilliseconds:
+		return "_ms"
+	case DurationString:
+		return ""
+	default:
+		return "_ns"
+	}
+}
+
+// A JSONMarshaler represents a configurable JSON encoder of
+// connection information.
+//
+// The zero value encodes the same as Info.MarshalJSON.
+type JSONMarshaler struct {
+	Durations DurationFormat // format of durations
+	Flat      bool           // whether to encode as a flat object, see Info.MarshalJSONFlat
+}
+
+// Marshal returns the JSON encoding of connection information.
+func (m *JSONMarshaler) Marshal(i *Info) ([]byte, error) {
+	e := jsonEncoder{b: make([]byte, 0, 512), durations: m.Durations, info: i}
+	if m.Flat {
+		e.flat(i)
+	} else {
+		e.nested(i)
+	}
+	if e.err != nil {
+		return nil, e.err
+	}
+	return e.b, nil
+}
+
+// A jsonEncoder appends the JSON encoding of connection information
+// to b without building intermediate values.
+type jsonEncoder struct {
+	b         []byte
+	durations DurationFormat
+	info      *Info // information whose absent fields are skipped
+	err       error
+}
+
+func (e *jsonEncoder) nested(i *Info) {
+	e.b = append(e.b, '{')
+	e.key("", "state", "")
+	e.b = appendJSONString(e.b, i.State.String())
+	if len(i.Options) > 0 {
+		e.key("", "opts", "")
+		e.options(i.Options)
+	}
+	if len(i.PeerOptions) > 0 {
+		e.key("", "peer_opts", "")
+		e.options(i.PeerOptions)
+	}
+	e.key("", "snd_mss", "")
+	e.b = strconv.AppendUint(e.b, uint64(i.SenderMSS), 10)
+	e.key("", "rcv_mss", "")
+	e.b = strconv.AppendUint(e.b, uint64(i.ReceiverMSS), 10)
+	e.times(i, "")
+	if i.FlowControl != nil {
+		e.key("", "flow_ctl", "")
+		e.object(reflect.ValueOf(i.FlowControl).Elem(), DurationNanoseconds)
+	}
+	if i.CongestionControl != nil {
+		e.key("", "cong_ctl", "")
+		e.object(reflect.ValueOf(i.CongestionControl).Elem(), DurationNanoseconds)
+	}
+	if i.Queue != nil {
+		e.key("", "queue", "")
+		e.object(reflect.ValueOf(i.Queue).Elem(), DurationNanoseconds)
+	}
+	if i.Sys != nil {
+		e.key("", "sys", "")
+		e.object(reflect.ValueOf(i.Sys).Elem(), e.durations)
+	}
+	e.b = append(e.b, '}')
+}
+
+func (e *jsonEncoder) flat(i *Info) {
+	suffix := e.durations.suffix()
+	e.b = append(e.b, '{')
+	e.key("", "state", "")
+	e.b = appendJSONString(e.b, i.State.String())
+	for j, opt := range i.Options {
+		if !shadowed(i.Options, j) {
+			e.flattenOption("opt_", suffix, opt)
+		}
+	}
+	for j, opt := range i.PeerOptions {
+		if !shadowed(i.PeerOptions, j) {
+			e.flattenOption("peer_opt_", suffix, opt)
+		}
+	}
+	e.key("", "snd_mss", "")
+	e.b = strconv.AppendUint(e.b, uint64(i.SenderMSS), 10)
+	e.key("", "rcv_mss", "")
+	e.b = strconv.AppendUint(e.b, uint64(i.ReceiverMSS), 10)
+	e.times(i, suffix)
+	if i.FlowControl != nil {
+		e.flatten("", suffix, reflect.ValueOf(i.FlowControl).Elem())
+	}
+	if i.CongestionControl != nil {
+		e.flatten("", suffix, reflect.ValueOf(i.CongestionControl).Elem())
+	}
+	if i.Queue != nil {
+		e.flatten("", suffix, reflect.ValueOf(i.Queue).Elem())
+	}
+	if i.Sys != nil {
+		e.flatten("sys_", suffix, reflect.ValueOf(i.Sys).Elem())
+	}
+	e.b = append(e.b, '}')
+}
+
+func (e *jsonEncoder) times(i *Info, suffix string) {
+	for _, d := range [...]struct {
+		key string
+		d   time.Duration
+	}{
+		{"rtt", i.RTT},
+		{"rttvar", i.RTTVar},
+		{"rto", i.RTO},
+		{"ato", i.ATO},
+		{"last_data_sent", i.LastDataSent},
+		{"last_data_rcvd", i.LastDataReceived},
+		{"last_ack_rcvd", i.LastAckReceived},
+	} {
+		if e.info.absent(d.key) {
+			continue
+		}
+		e.key("", d.key, suffix)
+		e.duration(d.d, e.durations)
+	}
+}
+
+// key appends the member name prefix+name+suffix, preceded by a
+// separator unless it is the first member of an object.
+func (e *jsonEncoder) key(prefix, name, suffix string) {
+	if e.b[len(e.b)-1] != '{' {
+		e.b = append(e.b, ',')
+	}
+	e.b = append(e.b, '"')
+	e.b = append(e.b, prefix...)
+	e.b = append(e.b, name...)
+	e.b = append(e.b, suffix...)
+	e.b = append(e.b, '"', ':')
+}
+
+func (e *jsonEncoder) duration(d time.Duration, f DurationFormat) {
+	switch f {
+	case DurationMicroseconds:
+		e.b = strconv.AppendInt(e.b, int64(d/time.Microsecond), 10)
+	case DurationMilliseconds:
+		e.b = appendJSONFloat(e.b, float64(d)/float64(time.Millisecond))
+	case DurationString:
+		e.b = appendJSONString(e.b, d.String())
+	default:
+		e.b = strconv.AppendInt(e.b, int64(d), 10)
+	}
+}
+
+// options appends opts as an object keyed by option kind.
+func (e *jsonEncoder) options(opts []Option) {
+	e.b = append(e.b, '{')
+	for j, opt := range opts {
+		if shadowed(opts, j) {
+			continue
+		}
+		e.key("", opt.Kind().String(), "")
+		e.value(reflect.ValueOf(opt), e.durations)
+	}
+	e.b = append(e.b, '}')
+}
+
+// shadowed reports whether opts[j] is superseded by a later option
+// of the same kind.
+func shadowed(opts []Option, j int) bool {
+	for _, opt := range opts[j+1:] {
+		if opt.Kind() == opts[j].Kind() {
+			return true
+		}
+	}
+	return false
+}
+
+// flattenOption appends opt keyed by its kind with prefix.
+// The fields of structured options are appended individually.
+// The keys of durations are given suffix.
+func (e *jsonEncoder) flattenOption(prefix, suffix string, opt Option) {
+	v := reflect.ValueOf(opt)
+	if v.Kind() != reflect.Struct {
+		if isDuration(v.Type()) {
+			e.key(prefix, opt.Kind().String(), suffix)
+		} else {
+			e.key(prefix, opt.Kind().String(), "")
+		}
+		e.value(v, e.durations)
+		return
+	}
+	e.flatten(prefix+opt.Kind().String()+"_", suffix, v)
+}
+
+// object appends the struct v as an object.
+func (e *jsonEncoder) object(v reflect.Value, f DurationFormat) {
+	e.b = append(e.b, '{')
+	for _, jf := range jsonFieldsOf(v.Type()) {
+		if e.info.absent(jf.name) {
+			continue
+		}
+		e.key("", jf.name, "")
+		e.value(v.Field(jf.index), f)
+	}
+	e.b = append(e.b, '}')
+}
+
+// flatten appends the fields of the struct v keyed by their JSON
+// names with prefix.
+// The keys of durations are given suffix.
+func (e *jsonEncoder) flatten(prefix, suffix string, v reflect.Value) {
+	for _, jf := range jsonFieldsOf(v.Type()) {
+		if e.info.absent(jf.name) {
+			continue
+		}
+		fv := v.Field(jf.index)
+		if isDuration(fv.Type()) {
+			e.key(prefix, jf.name, suffix)
+		} else {
+			e.key(prefix, jf.name, "")
+		}
+		e.value(fv, e.durations)
+	}
+}
+
+var (
+	durationType      = reflect.TypeOf(time.Duration(0))
+	userTimeoutType   = reflect.TypeOf(UserTimeout(0))
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// isDuration reports whether t is encoded as a duration.
+func isDuration(t reflect.Type) bool {
+	return t == durationType || t == userTimeoutType
+}
+
+// value appends v, encoding durations in format f.
+func (e *jsonEncoder) value(v reflect.Value, f DurationFormat) {
+	t := v.Type()
+	switch {
+	case isDuration(t):
+		e.duration(time.Duration(v.Int()), f)
+		return
+	case t.Implements(jsonMarshalerType), t.Implements(textMarshalerType):
+		e.marshal(v)
+		return
+	}
+	switch v.Kind() {
+	case reflect.Bool:
+		e.b = strconv.AppendBool(e.b, v.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		e.b = strconv.AppendInt(e.b, v.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		e.b = strconv.AppendUint(e.b, v.Uint(), 10)
+	case reflect.String:
+		e.b = appendJSONString(e.b, v.String())
+	case reflect.Struct:
+		e.object(v, f)
+	default:
+		e.marshal(v)
+	}
+}
+
+// marshal appends v using the encoding/json package.
+func (e *jsonEncoder) marshal(v reflect.Value) {
+	b, err := json.Marshal(v.Interface())
+	if err != nil && e.err == nil {
+		e.err = err
+	}
+	e.b = append(e.b, b...)
+}
+
+// A jsonField represents an encodable field of a struct.
+type jsonField struct {
+	name  string // JSON name
+	index int    // field index
+}
+
+var jsonFields sync.Map // map[reflect.Type][]jsonField
+
+// jsonFieldsOf returns the encodable fields of the struct type t.
+func jsonFieldsOf(t reflect.Type) []jsonField {
+	if fs, ok := jsonFields.Load(t); ok {
+		return fs.([]jsonField)
+	}
+	var fs []jsonField
+	for j := 0; j < t.NumField(); j++ {
+		name := strings.Split(t.Field(j).Tag.Get("json"), ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+		fs = append(fs, jsonField{name: name, index: j})
+	}
+	jsonFields.Store(t, fs)
+	return fs
+}
+
+// appendJSONFloat appends f as encoding/json does.
+func appendJSONFloat(b []byte, f float64) []byte {
+	fmt := byte('f')
+	if abs := math.Abs(f); abs != 0 && (abs < 1e-6 || abs >= 1e21) {
+		fmt = 'e'
+	}
+	b = strconv.AppendFloat(b, f, fmt, -1, 64)
+	if fmt == 'e' {
+		// Clean up e-09 to e-9.
+		if n := len(b); n >= 4 && b[n-4] == 'e' && b[n-3] == '-' && b[n-2] == '0' {
+			b[n-2] = b[n-1]
+			b = b[:n-1]
+		}
+	}
+	return b
+}
+
+// appendJSONString appends s as a quoted JSON string, escaping ASCII
+// characters as encoding/json does.
+func appendJSONString(b []byte, s string) []byte {
+	const hex = "0123456789abcdef"
+	b = append(b, '"')
+	for j := 0; j < len(s); j++ {
+		c := s[j]
+		switch {
+		case c == '"' || c == '\\':
+			b = append(b, '\\', c)
+		case c < 0x20 || c == '<' || c == '>' || c == '&':
+			b = append(b, '\\', 'u', '0', '0', hex[c>>4], hex[c&0xf])
+		default:
+			b = append(b, c)
+		}
+	}
+	return append(b, '"')
+}
+
+// MarshalJSONFlat returns the JSON encoding of connection information
+// as a flat object without nested objects.
+//
+// Keys are in snake case, options are prefixed with "opt_" or
+// "peer_opt_", platform-specific information is prefixed with "sys_"
+// and durations are numbers in microseconds with a "_us" suffix on the
+// key.
+func (i *Info) MarshalJSONFlat() ([]byte, error) {
+	m := JSONMarshaler{Durations: DurationMicroseconds, Flat: true}
+	return m.Marshal(i)
+}
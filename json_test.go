@@ -0,0 +1,180 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcpinfo_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/mikioh/tcpinfo"
+)
+
+func TestMarshalJSONFlat(t *testing.T) {
+	i := &tcpinfo.Info{
+		State:             tcpinfo.Established,
+		Options:           []tcpinfo.Option{tcpinfo.WindowScale(7)},
+		PeerOptions:       []tcpinfo.Option{tcpinfo.SACKPermitted(true), tcpinfo.Authentication{SendKeyID: 1, RecvKeyID: 2}},
+		RTT:               1500 * time.Microsecond,
+		CongestionControl: &tcpinfo.CongestionControl{SenderWindowSegs: 10},
+	}
+	b, err := i.MarshalJSONFlat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var m map[string]interface{}
+	if err := json.Unmarshal(b, &m); err != nil {
+		t.Fatal(err)
+	}
+	for k, want := range map[string]interface{}{
+		"state":                 "established",
+		"opt_wscale":            float64(7),
+		"peer_opt_sack":         true,
+		"peer_opt_ao_snd_keyid": float64(1),
+		"peer_opt_ao_rcv_keyid": float64(2),
+		"rtt_us":                float64(1500),
+		"snd_cwnd_segs":         float64(10),
+	} {
+		if m[k] != want {
+			t.Fatalf("got %v for %s; want %v", m[k], k, want)
+		}
+	}
+	for _, v := range m {
+		switch v.(type) {
+		case map[string]interface{}, []interface{}:
+			t.Fatalf("got nested value %v", v)
+		}
+	}
+}
+
+func TestJSONMarshalerDurations(t *testing.T) {
+	i := &tcpinfo.Info{RTT: 3200 * time.Microsecond}
+	for _, tt := range []struct {
+		m    tcpinfo.JSONMarshaler
+		key  string
+		want interface{}
+	}{
+		{tcpinfo.JSONMarshaler{}, "rtt", float64(3200000)},
+		{tcpinfo.JSONMarshaler{Durations: tcpinfo.DurationMilliseconds}, "rtt", 3.2},
+		{tcpinfo.JSONMarshaler{Durations: tcpinfo.DurationString}, "rtt", "3.2ms"},
+		{tcpinfo.JSONMarshaler{Durations: tcpinfo.DurationMilliseconds, Flat: true}, "rtt_ms", 3.2},
+	} {
+		b, err := tt.m.Marshal(i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var m map[string]interface{}
+		if err := json.Unmarshal(b, &m); err != nil {
+			t.Fatal(err)
+		}
+		if m[tt.key] != tt.want {
+			t.Fatalf("got %v for %s; want %v", m[tt.key], tt.key, tt.want)
+		}
+	}
+}
+
+func TestJSONMarshalerOptionDurations(t *testing.T) {
+	i := &tcpinfo.Info{
+		Options: []tcpinfo.Option{
+			tcpinfo.UserTimeout(30 * time.Second),
+			tcpinfo.KeepAlive{Idle: 15 * time.Second, Interval: 1500 * time.Millisecond, Count: 9},
+		},
+	}
+	for _, tt := range []struct {
+		m                    tcpinfo.JSONMarshaler
+		ut, idle, intvl      string
+		wantUT, wantIdle     interface{}
+		wantIntvl, wantCount interface{}
+	}{
+		{tcpinfo.JSONMarshaler{}, "user_timeout", "idle", "intvl", float64(30e9), float64(15e9), float64(15e8), float64(9)},
+		{tcpinfo.JSONMarshaler{Durations: tcpinfo.DurationMicroseconds}, "user_timeout", "idle", "intvl", float64(30e6), float64(15e6), float64(15e5), float64(9)},
+		{tcpinfo.JSONMarshaler{Durations: tcpinfo.DurationMilliseconds}, "user_timeout", "idle", "intvl", float64(30000), float64(15000), float64(1500), float64(9)},
+		{tcpinfo.JSONMarshaler{Durations: tcpinfo.DurationString}, "user_timeout", "idle", "intvl", "30s", "15s", "1.5s", float64(9)},
+		{tcpinfo.JSONMarshaler{Flat: true}, "opt_user_timeout_ns", "opt_keepalive_idle_ns", "opt_keepalive_intvl_ns", float64(30e9), float64(15e9), float64(15e8), float64(9)},
+		{tcpinfo.JSONMarshaler{Durations: tcpinfo.DurationMicroseconds, Flat: true}, "opt_user_timeout_us", "opt_keepalive_idle_us", "opt_keepalive_intvl_us", float64(30e6), float64(15e6), float64(15e5), float64(9)},
+		{tcpinfo.JSONMarshaler{Durations: tcpinfo.DurationMilliseconds, Flat: true}, "opt_user_timeout_ms", "opt_keepalive_idle_ms", "opt_keepalive_intvl_ms", float64(30000), float64(15000), float64(1500), float64(9)},
+		{tcpinfo.JSONMarshaler{Durations: tcpinfo.DurationString, Flat: true}, "opt_user_timeout", "opt_keepalive_idle", "opt_keepalive_intvl", "30s", "15s", "1.5s", float64(9)},
+	} {
+		b, err := tt.m.Marshal(i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got map[string]interface{}
+		if err := json.Unmarshal(b, &got); err != nil {
+			t.Fatalf("%v: %s", err, b)
+		}
+		ut, ka, cnt := got, got, "opt_keepalive_cnt"
+		if !tt.m.Flat {
+			ut = got["opts"].(map[string]interface{})
+			ka, cnt = ut["keepalive"].(map[string]interface{}), "cnt"
+		}
+		if ut[tt.ut] != tt.wantUT || ka[tt.idle] != tt.wantIdle || ka[tt.intvl] != tt.wantIntvl || ka[cnt] != tt.wantCount {
+			t.Fatalf("%+v: got %s", tt.m, b)
+		}
+	}
+}
+
+func TestMarshalJSONNested(t *testing.T) {
+	i := &tcpinfo.Info{
+		State:             tcpinfo.Established,
+		Options:           []tcpinfo.Option{tcpinfo.WindowScale(7), tcpinfo.MaxSegSize(1460), tcpinfo.WindowScale(8)},
+		PeerOptions:       []tcpinfo.Option{tcpinfo.Authentication{SendKeyID: 1, RecvKeyID: 2, Required: true}},
+		RTT:               3200 * time.Microsecond,
+		FlowControl:       &tcpinfo.FlowControl{ReceiverWindow: 65535},
+		CongestionControl: &tcpinfo.CongestionControl{SenderWindowSegs: 10},
+		Queue:             &tcpinfo.Queue{Receive: 1, Send: 2},
+		Sys:               &tcpinfo.SysInfo{},
+	}
+	for _, m := range []tcpinfo.JSONMarshaler{
+		{},
+		{Durations: tcpinfo.DurationMicroseconds},
+		{Durations: tcpinfo.DurationMilliseconds},
+		{Durations: tcpinfo.DurationString},
+	} {
+		b, err := m.Marshal(i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got map[string]interface{}
+		if err := json.Unmarshal(b, &got); err != nil {
+			t.Fatalf("%v: %s", err, b)
+		}
+		opts := got["opts"].(map[string]interface{})
+		if len(opts) != 2 || opts["wscale"] != float64(8) || opts["mss"] != float64(1460) {
+			t.Fatalf("got %v; want {mss:1460 wscale:8}", opts)
+		}
+		ao := got["peer_opts"].(map[string]interface{})["ao"].(map[string]interface{})
+		if ao["snd_keyid"] != float64(1) || ao["rcv_keyid"] != float64(2) || ao["required"] != true {
+			t.Fatalf("got %v", ao)
+		}
+		if v := got["flow_ctl"].(map[string]interface{})["rcv_wnd"]; v != float64(65535) {
+			t.Fatalf("got %v; want 65535", v)
+		}
+		if v := got["queue"].(map[string]interface{})["snd_queue"]; v != float64(2) {
+			t.Fatalf("got %v; want 2", v)
+		}
+		if _, ok := got["sys"].(map[string]interface{}); !ok {
+			t.Fatalf("got %v; want object", got["sys"])
+		}
+	}
+}
+
+func BenchmarkMarshalJSON(b *testing.B) {
+	i := &tcpinfo.Info{
+		State:             tcpinfo.Established,
+		Options:           []tcpinfo.Option{tcpinfo.WindowScale(7), tcpinfo.SACKPermitted(true)},
+		PeerOptions:       []tcpinfo.Option{tcpinfo.WindowScale(7), tcpinfo.SACKPermitted(true)},
+		RTT:               3200 * time.Microsecond,
+		FlowControl:       &tcpinfo.FlowControl{},
+		CongestionControl: &tcpinfo.CongestionControl{},
+		Sys:               &tcpinfo.SysInfo{},
+	}
+	b.ReportAllocs()
+	for j := 0; j < b.N; j++ {
+		if _, err := i.MarshalJSON(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
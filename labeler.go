@@ -0,0 +1,78 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcpinfo
+
+import "net"
+
+// A Label represents a label of samples, such as the name of the
+// service or workload behind the remote address.
+type Label struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// A Labeler returns the labels of the sample s on c.
+//
+// Labelers let exporters carry labels meaningful to the deployment,
+// such as the names of Kubernetes pods and services, without the
+// package knowing about them.
+type Labeler interface {
+	Labels(c net.Conn, s *Sample) []Label
+}
+
+// A LabelerFunc is an adapter to allow the use of an ordinary
+// function as a labeler.
+type LabelerFunc func(c net.Conn, s *Sample) []Label
+
+// Labels implements the Labels method of Labeler interface.
+func (f LabelerFunc) Labels(c net.Conn, s *Sample) []Label { return f(c, s) }
+
+// A Labelers represents a sequence of labelers, the labels of which
+// are concatenated.
+type Labelers []Labeler
+
+// Labels implements the Labels method of Labeler interface.
+func (ls Labelers) Labels(c net.Conn, s *Sample) []Label {
+	var lbs []Label
+	for _, l := range ls {
+		lbs = append(lbs, l.Labels(c, s)...)
+	}
+	return lbs
+}
+
+// RemoteLabeler returns a labeler that labels samples with the labels
+// returned by fn for the remote IP address of connection, such as
+// from a cache of the pods and services of a cluster.
+func RemoteLabeler(fn func(ip net.IP) []Label) Labeler {
+	return LabelerFunc(func(c net.Conn, _ *Sample) []Label {
+		a, ok := c.RemoteAddr().(*net.TCPAddr)
+		if !ok {
+			return nil
+		}
+		return fn(a.IP)
+	})
+}
+
+// WithLabels returns a SampleFunc that attaches the labels returned
+// by l to each sample and invokes fn with it.
+func WithLabels(l Labeler, fn SampleFunc) SampleFunc {
+	return func(c net.Conn, s *Sample) {
+		s.Labels = append(s.Labels, l.Labels(c, s)...)
+		if fn != nil {
+			fn(c, s)
+		}
+	}
+}
+
+// Label returns the value of the label name of s.
+// It returns the empty string when s has no such label.
+func (s *Sample) Label(name string) string {
+	for _, lb := range s.Labels {
+		if lb.Name == name {
+			return lb.Value
+		}
+	}
+	return ""
+}
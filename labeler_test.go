@@ -0,0 +1,39 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcpinfo_test
+
+import (
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/mikioh/tcpinfo"
+	"github.com/mikioh/tcpinfo/tcpinfotest"
+)
+
+func TestWithLabels(t *testing.T) {
+	pods := map[string]string{"10.0.0.7": "web-0"}
+	l := tcpinfo.Labelers{
+		tcpinfo.RemoteLabeler(func(ip net.IP) []tcpinfo.Label {
+			return []tcpinfo.Label{{Name: "pod", Value: pods[ip.String()]}}
+		}),
+		tcpinfo.LabelerFunc(func(net.Conn, *tcpinfo.Sample) []tcpinfo.Label {
+			return []tcpinfo.Label{{Name: "namespace", Value: "default"}}
+		}),
+	}
+	var got *tcpinfo.Sample
+	fn := tcpinfo.WithLabels(l, func(_ net.Conn, s *tcpinfo.Sample) { got = s })
+	fn(tcpinfotest.NewConn("10.0.0.1:443", "10.0.0.7:50000"), &tcpinfo.Sample{})
+	want := []tcpinfo.Label{{Name: "pod", Value: "web-0"}, {Name: "namespace", Value: "default"}}
+	if !reflect.DeepEqual(got.Labels, want) {
+		t.Fatalf("got %v; want %v", got.Labels, want)
+	}
+	if v := got.Label("pod"); v != "web-0" {
+		t.Fatalf("got %q; want web-0", v)
+	}
+	if v := got.Label("node"); v != "" {
+		t.Fatalf("got %q; want empty", v)
+	}
+}
@@ -0,0 +1,174 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcpinfo
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	measurementEscaper = strings.NewReplacer(",", `\,`, " ", `\ `)
+	tagEscaper         = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `)
+	stringEscaper      = strings.NewReplacer(`"`, `\"`, `\`, `\\`)
+)
+
+// MarshalLineProtocol returns the InfluxDB line protocol encoding of
+// the sample with the measurement name and tags.
+//
+// Counters and sizes are encoded as integers, durations as floats in
+// milliseconds with a "_ms" suffix on the field key, and the
+// timestamp in nanoseconds.
+// The timestamp is omitted when the sample time is zero.
+func (s *Sample) MarshalLineProtocol(measurement string, tags map[string]string) ([]byte, error) {
+	if measurement == "" {
+		return nil, errors.New("empty measurement")
+	}
+	if s.Info == nil {
+		return nil, errors.New("no connection information")
+	}
+	b := []byte(measurementEscaper.Replace(measurement))
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if tags[k] == "" {
+			continue
+		}
+		b = append(b, ',')
+		b = append(b, tagEscaper.Replace(k)...)
+		b = append(b, '=')
+		b = append(b, tagEscaper.Replace(tags[k])...)
+	}
+
+	i := s.Info
+	lp := lineProtocolFields{b: append(b, ' ')}
+	lp.str("state", i.State.String())
+	lp.options("opt_", i.Options)
+	lp.options("peer_opt_", i.PeerOptions)
+	lp.int("snd_mss", uint64(i.SenderMSS))
+	lp.int("rcv_mss", uint64(i.ReceiverMSS))
+	lp.duration("rtt", i.RTT)
+	lp.duration("rttvar", i.RTTVar)
+	lp.duration("rto", i.RTO)
+	lp.duration("ato", i.ATO)
+	lp.duration("last_data_sent", i.LastDataSent)
+	lp.duration("last_data_rcvd", i.LastDataReceived)
+	lp.duration("last_ack_rcvd", i.LastAckReceived)
+	if fc := i.FlowControl; fc != nil {
+		lp.int("rcv_wnd", uint64(fc.ReceiverWindow))
+	}
+	if cc := i.CongestionControl; cc != nil {
+		lp.int("snd_ssthresh", uint64(cc.SenderSSThreshold))
+		lp.int("rcv_ssthresh", uint64(cc.ReceiverSSThreshold))
+		lp.int("snd_cwnd_bytes", uint64(cc.SenderWindowBytes))
+		lp.int("snd_cwnd_segs", uint64(cc.SenderWindowSegs))
+	}
+	if q := i.Queue; q != nil {
+		lp.int("rcv_queue", uint64(q.Receive))
+		lp.int("snd_queue", uint64(q.Send))
+	}
+	ds := i.Stats()
+	lp.duration("min_rtt", ds.MinRTT)
+	lp.int("retrans_segs", ds.RetransSegs)
+	lp.int("retrans_bytes", ds.RetransBytes)
+	lp.int("segs_sent", ds.SegsSent)
+	lp.int("segs_rcvd", ds.SegsReceived)
+	lp.int("bytes_sent", ds.BytesSent)
+	lp.int("bytes_rcvd", ds.BytesReceived)
+	lp.int("delivery_rate", ds.DeliveryRate)
+	b = lp.b
+	if !s.Time.IsZero() {
+		b = append(b, ' ')
+		b = strconv.AppendInt(b, s.Time.UnixNano(), 10)
+	}
+	return b, nil
+}
+
+type lineProtocolFields struct {
+	b []byte
+	n int
+}
+
+func (lp *lineProtocolFields) key(k string) {
+	if lp.n > 0 {
+		lp.b = append(lp.b, ',')
+	}
+	lp.n++
+	lp.b = append(lp.b, tagEscaper.Replace(k)...)
+	lp.b = append(lp.b, '=')
+}
+
+func (lp *lineProtocolFields) str(k, v string) {
+	lp.key(k)
+	lp.b = append(lp.b, '"')
+	lp.b = append(lp.b, stringEscaper.Replace(v)...)
+	lp.b = append(lp.b, '"')
+}
+
+func (lp *lineProtocolFields) int(k string, v uint64) {
+	lp.key(k)
+	lp.b = strconv.AppendUint(lp.b, v, 10)
+	lp.b = append(lp.b, 'i')
+}
+
+func (lp *lineProtocolFields) duration(k string, d time.Duration) {
+	lp.key(k + "_ms")
+	lp.b = strconv.AppendFloat(lp.b, float64(d)/float64(time.Millisecond), 'f', -1, 64)
+}
+
+func (lp *lineProtocolFields) options(prefix string, opts []Option) {
+	for _, opt := range opts {
+		switch opt := opt.(type) {
+		case MaxSegSize:
+			lp.int(prefix+opt.Kind().String(), uint64(opt))
+		case WindowScale:
+			lp.int(prefix+opt.Kind().String(), uint64(opt))
+		case SACKPermitted:
+			lp.key(prefix + opt.Kind().String())
+			lp.b = strconv.AppendBool(lp.b, bool(opt))
+		case Timestamps:
+			lp.key(prefix + opt.Kind().String())
+			lp.b = strconv.AppendBool(lp.b, bool(opt))
+		case MD5Signature:
+			lp.key(prefix + opt.Kind().String())
+			lp.b = strconv.AppendBool(lp.b, bool(opt))
+		case NoDelay:
+			lp.key(prefix + opt.Kind().String())
+			lp.b = strconv.AppendBool(lp.b, bool(opt))
+		case QuickAck:
+			lp.key(prefix + opt.Kind().String())
+			lp.b = strconv.AppendBool(lp.b, bool(opt))
+		case FastOpen:
+			lp.key(prefix + opt.Kind().String())
+			lp.b = strconv.AppendBool(lp.b, bool(opt))
+		case ECN:
+			lp.key(prefix + opt.Kind().String())
+			lp.b = strconv.AppendBool(lp.b, bool(opt))
+		case CCAlgorithm:
+			lp.str(prefix+opt.Kind().String(), string(opt))
+		case UserTimeout:
+			lp.duration(prefix+opt.Kind().String(), time.Duration(opt))
+		case KeepAlive:
+			lp.duration(prefix+opt.Kind().String()+"_idle", opt.Idle)
+			lp.duration(prefix+opt.Kind().String()+"_intvl", opt.Interval)
+			lp.int(prefix+opt.Kind().String()+"_cnt", uint64(opt.Count))
+		case Authentication:
+			lp.key(prefix + opt.Kind().String() + "_required")
+			lp.b = strconv.AppendBool(lp.b, opt.Required)
+			if opt.SendKeyID >= 0 {
+				lp.int(prefix+opt.Kind().String()+"_snd_keyid", uint64(opt.SendKeyID))
+			}
+			if opt.RecvKeyID >= 0 {
+				lp.int(prefix+opt.Kind().String()+"_rcv_keyid", uint64(opt.RecvKeyID))
+			}
+		}
+	}
+}
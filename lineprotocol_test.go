@@ -0,0 +1,42 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcpinfo_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mikioh/tcpinfo"
+)
+
+func TestMarshalLineProtocol(t *testing.T) {
+	s := &tcpinfo.Sample{
+		Time: time.Unix(1, 5),
+		Info: &tcpinfo.Info{
+			State:   tcpinfo.Established,
+			Options: []tcpinfo.Option{tcpinfo.WindowScale(7), tcpinfo.SACKPermitted(true)},
+			RTT:     1500 * time.Microsecond,
+			Queue:   &tcpinfo.Queue{Receive: 100, Send: 4096},
+		},
+	}
+	b, err := s.MarshalLineProtocol("tcp conn", map[string]string{"relay": "r 1", "az": "x=y", "empty": ""})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := string(b)
+	for _, want := range []string{
+		`tcp\ conn,az=x\=y,relay=r\ 1 state="established",opt_wscale=7i,opt_sack=true,snd_mss=0i,rcv_mss=0i,rtt_ms=1.5,`,
+		",rcv_queue=100i,snd_queue=4096i,",
+		",delivery_rate=0i 1000000005",
+	} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("got %s; want to contain %s", got, want)
+		}
+	}
+	if _, err := (&tcpinfo.Sample{}).MarshalLineProtocol("tcp", nil); err == nil {
+		t.Fatal("got nil; want an error")
+	}
+}
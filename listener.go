@@ -0,0 +1,66 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcpinfo
+
+import (
+	"errors"
+	"net"
+	"syscall"
+)
+
+// A ListenerInfo represents information on a listening TCP socket.
+//
+// Only supported on Linux.
+type ListenerInfo struct {
+	AcceptQueue uint            `json:"accept_queue"`       // # of established connections waiting to be accepted
+	Backlog     uint            `json:"backlog"`            // maximum # of connections waiting to be accepted
+	SYNQueue    uint            `json:"syn_queue"`          // # of connection requests waiting for completion of handshake on the port
+	Counters    *ListenCounters `json:"counters,omitempty"` // counters of the network namespace; nil when not available
+}
+
+// Overflowing reports whether the accept queue is full, in which case
+// the kernel drops or ignores completed handshakes until the
+// application accepts connections.
+func (li *ListenerInfo) Overflowing() bool {
+	return li.AcceptQueue > li.Backlog
+}
+
+// A ListenCounters represents counters of listening TCP sockets in
+// a network namespace.
+// The counters are shared by all listeners.
+type ListenCounters struct {
+	Overflows     uint64 `json:"overflows"`       // # of completed handshakes with full accept queues
+	Drops         uint64 `json:"drops"`           // # of connection requests dropped by listeners for any reason, including overflows
+	SYNQueueDrops uint64 `json:"syn_queue_drops"` // # of connection requests dropped with full SYN queues
+	SYNCookies    uint64 `json:"syn_cookies"`     // # of SYN cookies sent
+}
+
+// GetListener returns information on the listening socket of ln.
+//
+// The listener must implement syscall.Conn.
+// The SYN queue is counted from the connection requests listed in
+// /proc/net/tcp and /proc/net/tcp6, which may take a while on hosts
+// with many sockets.
+// Only supported on Linux.
+func GetListener(ln net.Listener) (*ListenerInfo, error) {
+	sc, ok := ln.(syscall.Conn)
+	if !ok {
+		return nil, errors.New("not a syscall.Conn")
+	}
+	rc, err := sc.SyscallConn()
+	if err != nil {
+		return nil, err
+	}
+	laddr, _ := ln.Addr().(*net.TCPAddr)
+	var li *ListenerInfo
+	if err := controlRaw(rc, func(s uintptr) error {
+		var err error
+		li, err = getListener(s, laddr)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+	return li, nil
+}
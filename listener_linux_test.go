@@ -0,0 +1,40 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcpinfo_test
+
+import (
+	"net"
+	"testing"
+
+	"github.com/mikioh/tcpinfo"
+)
+
+func TestGetListener(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	var cs []net.Conn
+	for j := 0; j < 3; j++ {
+		c, err := net.Dial("tcp", ln.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer c.Close()
+		cs = append(cs, c)
+	}
+	li, err := tcpinfo.GetListener(ln)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if li.AcceptQueue != uint(len(cs)) || li.Backlog == 0 || li.Overflowing() {
+		t.Fatalf("got %+v; want %d connections waiting", li, len(cs))
+	}
+	if li.Counters == nil {
+		t.Fatalf("got %+v; want counters", li)
+	}
+}
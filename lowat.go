@@ -0,0 +1,100 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcpinfo
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/mikioh/tcpopt"
+)
+
+// A LowWatermark keeps the send queue of a connection shallow for
+// latency-sensitive senders, such as streaming events over proxies,
+// by combining TCP_NOTSENT_LOWAT with readings of unsent data.
+//
+// A sender waits for WriteReady before producing the next data, so
+// that the data is fresh when written instead of waiting behind
+// stale data in the send queue.
+//
+// Setting TCP_NOTSENT_LOWAT is only supported on Darwin and Linux,
+// and the # of unsent bytes is only available on Linux; elsewhere
+// the # of unsent or unacknowledged bytes is used instead.
+type LowWatermark struct {
+	h     *Handle
+	lowat int
+
+	mu sync.Mutex
+	i  Info
+}
+
+// NewLowWatermark returns a new low watermark of n bytes of unsent
+// data on c, and sets TCP_NOTSENT_LOWAT to n on c when supported.
+// The connection must implement syscall.Conn.
+func NewLowWatermark(c net.Conn, n int) (*LowWatermark, error) {
+	h, err := Bind(c)
+	if err != nil {
+		return nil, err
+	}
+	if o := tcpopt.NotSentLowWMK(n); o.Name() != 0 {
+		if err := set(c, o); err != nil {
+			return nil, err
+		}
+	}
+	return &LowWatermark{h: h, lowat: n}, nil
+}
+
+// NotSent returns the # of bytes not sent yet on the connection.
+func (lw *LowWatermark) NotSent() (int, error) {
+	lw.mu.Lock()
+	defer lw.mu.Unlock()
+	if err := lw.h.GetFields(&lw.i, FieldQueue|FieldSys); err != nil {
+		return 0, err
+	}
+	if n, ok := notSentBytes(&lw.i); ok {
+		return int(n), nil
+	}
+	if lw.i.Queue == nil {
+		return 0, errNotSupported("get", "unsent bytes")
+	}
+	return int(lw.i.Queue.Send), nil
+}
+
+// Ready reports whether the # of unsent bytes is at or below the
+// watermark.
+func (lw *LowWatermark) Ready() (bool, error) {
+	n, err := lw.NotSent()
+	if err != nil {
+		return false, err
+	}
+	return n <= lw.lowat, nil
+}
+
+// WriteReady returns a channel that receives nil once the # of
+// unsent bytes falls to or below the watermark, polling every d, or
+// an error on retrieval or cancelation of ctx.
+func (lw *LowWatermark) WriteReady(ctx context.Context, d time.Duration) <-chan error {
+	ch := make(chan error, 1)
+	go func() {
+		t := time.NewTicker(d)
+		defer t.Stop()
+		for {
+			ok, err := lw.Ready()
+			if err != nil || ok {
+				ch <- err
+				return
+			}
+			select {
+			case <-ctx.Done():
+				ch <- ctx.Err()
+				return
+			case <-t.C:
+			}
+		}
+	}()
+	return ch
+}
@@ -0,0 +1,79 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcpinfo_test
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/mikioh/tcpinfo"
+)
+
+func TestLowWatermark(t *testing.T) {
+	switch runtime.GOOS {
+	case "darwin", "freebsd", "linux", "netbsd":
+	default:
+		t.Skipf("%s/%s", runtime.GOOS, runtime.GOARCH)
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		c, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		accepted <- c
+	}()
+	c, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	peer := <-accepted
+	defer peer.Close()
+
+	lw, err := tcpinfo.NewLowWatermark(c, 16<<10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := lw.Ready(); err != nil || !ok {
+		t.Fatalf("got %v, %v; want true, nil", ok, err)
+	}
+
+	// Fill the send queue while the peer does not read.
+	c.SetWriteDeadline(time.Now().Add(100 * time.Millisecond))
+	b := make([]byte, 64<<10)
+	for {
+		if _, err := c.Write(b); err != nil {
+			break
+		}
+	}
+	c.SetWriteDeadline(time.Time{})
+	if ok, err := lw.Ready(); err != nil || ok {
+		t.Fatalf("got %v, %v; want false, nil", ok, err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := <-lw.WriteReady(ctx, time.Millisecond); err != context.DeadlineExceeded {
+		t.Fatalf("got %v; want %v", err, context.DeadlineExceeded)
+	}
+
+	go io.Copy(ioutil.Discard, peer)
+	ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := <-lw.WriteReady(ctx, time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,80 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcpinfo
+
+import (
+	"net"
+	"unsafe"
+
+	"github.com/mikioh/tcpopt"
+)
+
+var _ tcpopt.Option = &MemInfo{}
+
+// A MemInfo represents socket memory information.
+//
+// Only supported on Linux.
+type MemInfo struct {
+	RmemAlloc  uint `json:"rmem_alloc"`  // memory allocated for receive queue in bytes
+	RcvBuf     uint `json:"rcvbuf"`      // receive buffer size in bytes
+	WmemAlloc  uint `json:"wmem_alloc"`  // memory allocated for send queue in bytes
+	SndBuf     uint `json:"sndbuf"`      // send buffer size in bytes
+	FwdAlloc   uint `json:"fwd_alloc"`   // memory reserved in advance in bytes
+	WmemQueued uint `json:"wmem_queued"` // memory queued for transmission in bytes
+	OptMem     uint `json:"optmem"`      // memory used for socket options in bytes
+	Backlog    uint `json:"backlog"`     // backlog queue length in bytes
+	Drops      uint `json:"drops"`       // # of dropped packets
+}
+
+// Level implements the Level method of tcpopt.Option interface.
+func (mi *MemInfo) Level() int { return options[soMemInfo].level }
+
+// Name implements the Name method of tcpopt.Option interface.
+func (mi *MemInfo) Name() int { return options[soMemInfo].name }
+
+// Marshal implements the Marshal method of tcpopt.Option interface.
+func (mi *MemInfo) Marshal() ([]byte, error) {
+	var vs [sizeofMemInfoVars]uint32
+	for i, v := range []uint{mi.RmemAlloc, mi.RcvBuf, mi.WmemAlloc, mi.SndBuf, mi.FwdAlloc, mi.WmemQueued, mi.OptMem, mi.Backlog, mi.Drops} {
+		vs[i] = uint32(v)
+	}
+	return (*[sizeofMemInfoVars * 4]byte)(unsafe.Pointer(&vs))[:], nil
+}
+
+// sizeofMemInfoVars is the number of SK_MEMINFO variables known to
+// this package.
+const sizeofMemInfoVars = 9
+
+func parseMemInfo(b []byte) (tcpopt.Option, error) {
+	if len(b) < 4*4 {
+		return nil, errShortBuffer("meminfo", 4*4, len(b))
+	}
+	var vs [sizeofMemInfoVars]uint32
+	copy((*[sizeofMemInfoVars * 4]byte)(unsafe.Pointer(&vs))[:], b)
+	return &MemInfo{
+		RmemAlloc:  uint(vs[0]),
+		RcvBuf:     uint(vs[1]),
+		WmemAlloc:  uint(vs[2]),
+		SndBuf:     uint(vs[3]),
+		FwdAlloc:   uint(vs[4]),
+		WmemQueued: uint(vs[5]),
+		OptMem:     uint(vs[6]),
+		Backlog:    uint(vs[7]),
+		Drops:      uint(vs[8]),
+	}, nil
+}
+
+// GetMemInfo returns socket memory information on c.
+//
+// The connection must implement syscall.Conn.
+// Only supported on Linux.
+func GetMemInfo(c net.Conn) (*MemInfo, error) {
+	var b [sizeofMemInfoVars * 4]byte
+	o, err := get(c, soMemInfo, b[:])
+	if err != nil {
+		return nil, err
+	}
+	return o.(*MemInfo), nil
+}
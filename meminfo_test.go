@@ -0,0 +1,49 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcpinfo_test
+
+import (
+	"net"
+	"runtime"
+	"testing"
+
+	"github.com/mikioh/tcpinfo"
+	"github.com/mikioh/tcpopt"
+)
+
+func TestGetMemInfo(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skipf("%s/%s", runtime.GOOS, runtime.GOARCH)
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	c, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	mi, err := tcpinfo.GetMemInfo(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mi.SndBuf == 0 || mi.RcvBuf == 0 {
+		t.Fatalf("got %+v; want non-zero buffer sizes", mi)
+	}
+	b, err := mi.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	o, err := tcpopt.Parse(mi.Level(), mi.Name(), b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *o.(*tcpinfo.MemInfo) != *mi {
+		t.Fatalf("got %+v; want %+v", o, mi)
+	}
+}
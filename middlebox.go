@@ -0,0 +1,154 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcpinfo
+
+import "fmt"
+
+// A Middlebox represents heuristics flagging likely interference of
+// middleboxes, such as firewalls and NATs, with connections.
+//
+// The heuristics cannot tell a middlebox from a peer lacking support
+// for an option; findings are signs, not proof.
+type Middlebox struct {
+	// PathMSS is the maximum segment size without options expected
+	// on the path, such as 1460 for Ethernet over IPv4.
+	// Zero means the path MTU less the lengths of IPv6 and TCP
+	// headers where the platform reports the path MTU, and no check
+	// elsewhere.
+	PathMSS MaxSegSize
+
+	// Requested is the options requested by the local end.
+	// Nil means window scale, SACK permitted and timestamps options,
+	// which the supported platforms request by default.
+	Requested []Option
+}
+
+// A Finding represents a sign of middlebox interference.
+type Finding struct {
+	Name   string // name of heuristic; "mss_clamped", "wscale_stripped", "sack_stripped", "tmstamps_stripped", "wscale_invalid" or "wscale_changed"
+	Detail string // evidence of the finding
+}
+
+func (f *Finding) String() string { return f.Name + ": " + f.Detail }
+
+var defaultRequested = []Option{WindowScale(0), SACKPermitted(true), Timestamps(true)}
+
+// maxWindowScale is the maximum shift count of window scale option
+// defined in RFC 7323.
+const maxWindowScale = 14
+
+// Check returns the signs of middlebox interference on connection
+// information i.
+//
+// Requested options missing from the negotiated options are reported
+// as stripped, window scales beyond 14 as invalid, and a maximum
+// segment size below that of the path as clamped.
+func (mb *Middlebox) Check(i *Info) []Finding {
+	var fs []Finding
+	ts := false
+	if i.Valid("opts") {
+		got := make(map[OptionKind]bool)
+		for _, opt := range i.Options {
+			switch opt := opt.(type) {
+			case WindowScale:
+				got[KindWindowScale] = true
+			case SACKPermitted:
+				got[KindSACKPermitted] = bool(opt)
+			case Timestamps:
+				got[KindTimestamps] = bool(opt)
+			}
+		}
+		ts = got[KindTimestamps]
+		requested := mb.Requested
+		if requested == nil {
+			requested = defaultRequested
+		}
+		for _, opt := range requested {
+			switch opt := opt.(type) {
+			case SACKPermitted:
+				if !opt {
+					continue
+				}
+			case Timestamps:
+				if !opt {
+					continue
+				}
+			case WindowScale:
+			default:
+				continue
+			}
+			if !got[opt.Kind()] {
+				fs = append(fs, Finding{Name: opt.Kind().String() + "_stripped", Detail: fmt.Sprintf("%s option requested but not negotiated", opt.Kind())})
+			}
+		}
+		for _, o := range []struct {
+			side string
+			opts []Option
+		}{{"local", i.Options}, {"peer", i.PeerOptions}} {
+			for _, opt := range o.opts {
+				if ws, ok := opt.(WindowScale); ok && (ws < 0 || ws > maxWindowScale) {
+					fs = append(fs, Finding{Name: "wscale_invalid", Detail: fmt.Sprintf("%s window scale %d > %d", o.side, ws, maxWindowScale)})
+				}
+			}
+		}
+	}
+	norm := mb.PathMSS
+	if norm == 0 {
+		if mtu, ok := pathMTU(i); ok && mtu > 60 {
+			norm = MaxSegSize(mtu - 60)
+		}
+	}
+	if mss := i.SenderMSS; norm > 0 && mss > 0 {
+		// The maximum segment size for sender excludes the
+		// timestamps option.
+		if ts {
+			mss += 12
+		}
+		if mss < norm {
+			fs = append(fs, Finding{Name: "mss_clamped", Detail: fmt.Sprintf("mss %d < %d", mss, norm)})
+		}
+	}
+	return fs
+}
+
+// CheckHistory returns the signs of middlebox interference on the
+// samples in h.
+//
+// In addition to the findings of Check on the last sample, window
+// scales changing between samples, which stay fixed for the lifetime
+// of a connection, are reported as changed.
+func (mb *Middlebox) CheckHistory(h *History) []Finding {
+	var (
+		fs       []Finding
+		last     *Info
+		snd, rcv WindowScale = -1, -1
+	)
+	for _, s := range h.Samples() {
+		if s.Info == nil || !s.Info.Valid("opts") {
+			continue
+		}
+		last = s.Info
+		for _, o := range []struct {
+			side string
+			opts []Option
+			ws   *WindowScale
+		}{{"local", s.Info.Options, &snd}, {"peer", s.Info.PeerOptions, &rcv}} {
+			for _, opt := range o.opts {
+				ws, ok := opt.(WindowScale)
+				if !ok {
+					continue
+				}
+				if *o.ws >= 0 && ws != *o.ws {
+					fs = append(fs, Finding{Name: "wscale_changed", Detail: fmt.Sprintf("%s window scale %d -> %d at %v", o.side, *o.ws, ws, s.Time)})
+				}
+				*o.ws = ws
+			}
+		}
+	}
+	if last == nil {
+		return nil
+	}
+	return append(mb.Check(last), fs...)
+}
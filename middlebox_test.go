@@ -0,0 +1,59 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcpinfo_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/mikioh/tcpinfo"
+)
+
+func TestMiddlebox(t *testing.T) {
+	mb := &tcpinfo.Middlebox{PathMSS: 1460}
+	for _, tt := range []struct {
+		i    *tcpinfo.Info
+		want []string
+	}{
+		{
+			&tcpinfo.Info{
+				SenderMSS:   1448,
+				Options:     []tcpinfo.Option{tcpinfo.WindowScale(7), tcpinfo.SACKPermitted(true), tcpinfo.Timestamps(true)},
+				PeerOptions: []tcpinfo.Option{tcpinfo.WindowScale(9), tcpinfo.SACKPermitted(true), tcpinfo.Timestamps(true)},
+			},
+			nil,
+		},
+		{
+			&tcpinfo.Info{
+				SenderMSS:   1360,
+				Options:     []tcpinfo.Option{tcpinfo.WindowScale(7)},
+				PeerOptions: []tcpinfo.Option{tcpinfo.WindowScale(15)},
+			},
+			[]string{"sack_stripped", "tmstamps_stripped", "wscale_invalid", "mss_clamped"},
+		},
+	} {
+		var names []string
+		for _, f := range mb.Check(tt.i) {
+			names = append(names, f.Name)
+		}
+		if !reflect.DeepEqual(names, tt.want) {
+			t.Fatalf("got %v; want %v", names, tt.want)
+		}
+	}
+
+	h := tcpinfo.NewHistory(0)
+	for j, ws := range []tcpinfo.WindowScale{7, 7, 2} {
+		h.Add(&tcpinfo.Sample{Time: time.Unix(int64(j), 0), Info: &tcpinfo.Info{
+			SenderMSS:   1448,
+			Options:     []tcpinfo.Option{tcpinfo.WindowScale(7), tcpinfo.SACKPermitted(true), tcpinfo.Timestamps(true)},
+			PeerOptions: []tcpinfo.Option{ws, tcpinfo.SACKPermitted(true), tcpinfo.Timestamps(true)},
+		}})
+	}
+	fs := mb.CheckHistory(h)
+	if len(fs) != 1 || fs[0].Name != "wscale_changed" {
+		t.Fatalf("got %v", fs)
+	}
+}
@@ -0,0 +1,267 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcpinfo
+
+import (
+	"net"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// A Monitor takes samples of connection information on multiple
+// connections periodically.
+//
+// Samplers never run the callback function themselves; they hand
+// samples over to lock-free delivery queues, sharded by connection,
+// each of which is drained by its own goroutine invoking the callback
+// function.
+// Samples on a connection are delivered in order, and a sampler waits
+// for delivery only while the queue of its shard is full.
+// Each tracked connection keeps its latest sample to itself, and
+// lookups of tracked connections take no locks.
+//
+// A connection closed during sampling is untracked automatically
+// after its tombstone, a final sample failing with ErrConnClosed, is
+// delivered.
+type Monitor struct {
+	opts SamplerOpts
+	fn   SampleFunc
+
+	mu    sync.Mutex   // serializes Add, Remove and AddRule
+	conns sync.Map     // map[net.Conn]*monitorEntry
+	addrs sync.Map     // map[connAddrs]net.Conn
+	rules atomic.Value // []*ruleBinding
+	ds    *deliveryShards
+}
+
+// A monitorEntry represents a tracked connection.
+type monitorEntry struct {
+	m      *Monitor
+	s      *Sampler
+	q      *deliveryQueue
+	wg     sync.WaitGroup // samples being delivered
+	latest atomic.Value   // *Sample
+
+	// The following fields are accessed only by the delivery
+	// goroutine of the shard.
+	prev   *Sample
+	states map[*ruleBinding]*ruleState
+}
+
+// NewMonitor returns a new monitor that takes a sample of connection
+// information on each tracked connection every d and invokes fn with
+// it.
+// The callback function fn may be nil.
+// It must not call Remove or Close on the monitor, and must not
+// modify the connection information of samples, which is shared with
+// the samplers.
+func NewMonitor(d time.Duration, fn SampleFunc) *Monitor {
+	return NewMonitorWithGetter(nil, d, fn)
+}
+
+// NewMonitorWithGetter is like NewMonitor but retrieves connection
+// information from g.
+// A nil g means retrieval via the sockets of connections, as
+// NewMonitor does.
+func NewMonitorWithGetter(g Getter, d time.Duration, fn SampleFunc) *Monitor {
+	return NewMonitorWithOpts(SamplerOpts{Getter: g, Interval: d}, fn)
+}
+
+// NewMonitorWithOpts is like NewMonitor but takes samples on each
+// tracked connection as specified by opts.
+// Jitter in opts is recommended when tracking many connections.
+func NewMonitorWithOpts(opts SamplerOpts, fn SampleFunc) *Monitor {
+	return &Monitor{opts: opts, fn: fn}
+}
+
+// Add starts tracking c.
+// It does nothing when c is already tracked.
+func (m *Monitor) Add(c net.Conn) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.conns.Load(c); ok {
+		return
+	}
+	if m.ds == nil {
+		m.ds = startDelivery(runtime.GOMAXPROCS(0))
+	}
+	e := &monitorEntry{m: m, q: m.ds.assign()}
+	e.s = NewSamplerWithOpts(c, m.opts, e.enqueue)
+	m.conns.Store(c, e)
+	if k, ok := addrsOf(c.LocalAddr(), c.RemoteAddr()); ok {
+		m.addrs.Store(k, c)
+	}
+}
+
+// untrack deletes c when it is tracked with e.
+// It must be called with m.mu held.
+func (m *Monitor) untrack(c net.Conn, e *monitorEntry) {
+	if v, ok := m.conns.Load(c); !ok || v != e {
+		return
+	}
+	m.conns.Delete(c)
+	if k, ok := addrsOf(c.LocalAddr(), c.RemoteAddr()); ok {
+		if v, ok := m.addrs.Load(k); ok && v == c {
+			m.addrs.Delete(k)
+		}
+	}
+}
+
+// enqueue hands s over to the delivery goroutine of the shard.
+// It is called by the sampler.
+func (e *monitorEntry) enqueue(c net.Conn, s *Sample) {
+	e.wg.Add(1)
+	e.q.enqueue(delivery{e: e, c: c, s: s})
+}
+
+// deliver evaluates the rules against s and invokes the callback
+// function with it.
+// It is called by the delivery goroutine of the shard.
+func (e *monitorEntry) deliver(c net.Conn, s *Sample) {
+	defer e.wg.Done()
+	if rbs, _ := e.m.rules.Load().([]*ruleBinding); len(rbs) > 0 && !s.Final {
+		if e.states == nil {
+			e.states = make(map[*ruleBinding]*ruleState)
+		}
+		for _, rb := range rbs {
+			st := e.states[rb]
+			if st == nil {
+				st = new(ruleState)
+				e.states[rb] = st
+			}
+			rb.eval(c, st, e.prev, s)
+		}
+		e.prev = s
+	}
+	if s.Final {
+		// Untrack the connection closed during sampling, which
+		// has the sampler stopped with the tombstone.
+		e.m.mu.Lock()
+		e.m.untrack(c, e)
+		e.m.mu.Unlock()
+	}
+	if e.m.fn != nil {
+		e.m.fn(c, s)
+	}
+	e.latest.Store(s) // published after the callback annotates s
+}
+
+// AddRule attaches the rule r to the monitor and invokes fn with
+// each breach of r on tracked connections.
+// A breach is reported once until the condition of r stops holding.
+func (m *Monitor) AddRule(r *Rule, fn BreachFunc) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	rbs, _ := m.rules.Load().([]*ruleBinding)
+	m.rules.Store(append(rbs[:len(rbs):len(rbs)], &ruleBinding{r: r, fn: fn}))
+}
+
+// Remove stops tracking c, takes the final sample and returns a
+// summary of connection information built from it.
+// The final sample has been delivered when Remove returns.
+// It must be called before the connection is closed.
+// It returns nil when c is not tracked.
+func (m *Monitor) Remove(c net.Conn) *FinalStats {
+	m.mu.Lock()
+	v, ok := m.conns.Load(c)
+	if ok {
+		m.untrack(c, v.(*monitorEntry))
+	}
+	m.mu.Unlock()
+	if !ok {
+		return nil
+	}
+	e := v.(*monitorEntry)
+	fs := e.s.Stop()
+	e.wg.Wait()
+	return fs
+}
+
+// Conns returns the tracked connections.
+func (m *Monitor) Conns() []net.Conn {
+	var cs []net.Conn
+	m.conns.Range(func(c, _ interface{}) bool {
+		cs = append(cs, c.(net.Conn))
+		return true
+	})
+	return cs
+}
+
+// Lookup returns the tracked connection between the local address
+// laddr and the remote address raddr.
+// It returns nil when no such TCP connection is tracked.
+func (m *Monitor) Lookup(laddr, raddr net.Addr) net.Conn {
+	k, ok := addrsOf(laddr, raddr)
+	if !ok {
+		return nil
+	}
+	v, ok := m.addrs.Load(k)
+	if !ok {
+		return nil
+	}
+	return v.(net.Conn)
+}
+
+// A connAddrs represents the address 4-tuple of a TCP connection.
+type connAddrs struct {
+	lip, rip     [16]byte
+	lport, rport int
+}
+
+func addrsOf(laddr, raddr net.Addr) (connAddrs, bool) {
+	var k connAddrs
+	la, ok := laddr.(*net.TCPAddr)
+	if !ok || la == nil || la.IP.To16() == nil {
+		return k, false
+	}
+	ra, ok := raddr.(*net.TCPAddr)
+	if !ok || ra == nil || ra.IP.To16() == nil {
+		return k, false
+	}
+	copy(k.lip[:], la.IP.To16())
+	copy(k.rip[:], ra.IP.To16())
+	k.lport, k.rport = la.Port, ra.Port
+	return k, true
+}
+
+// Latest returns a copy of the latest sample on c.
+// It returns nil when c is not tracked or no sample is taken yet.
+func (m *Monitor) Latest(c net.Conn) *Sample {
+	e, ok := m.conns.Load(c)
+	if !ok {
+		return nil
+	}
+	s, _ := e.(*monitorEntry).latest.Load().(*Sample)
+	return s.Clone()
+}
+
+// Boost boosts the sampler of c as Sampler.Boost does.
+// It does nothing when c is not tracked.
+func (m *Monitor) Boost(c net.Conn) {
+	if e, ok := m.conns.Load(c); ok {
+		e.(*monitorEntry).s.Boost()
+	}
+}
+
+// Close stops tracking all the connections and the delivery of
+// samples.
+func (m *Monitor) Close() {
+	for _, c := range m.Conns() {
+		m.Remove(c)
+	}
+	m.mu.Lock()
+	ds := m.ds
+	if len(m.Conns()) == 0 {
+		m.ds = nil
+	} else {
+		ds = nil // connections added meanwhile
+	}
+	m.mu.Unlock()
+	if ds != nil {
+		ds.stop()
+	}
+}
@@ -0,0 +1,284 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcpinfo_test
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mikioh/tcpinfo"
+	"github.com/mikioh/tcpinfo/tcpinfotest"
+)
+
+func TestMonitor(t *testing.T) {
+	switch runtime.GOOS {
+	case "darwin", "freebsd", "linux", "netbsd":
+	default:
+		t.Skipf("not supported on %s/%s", runtime.GOOS, runtime.GOARCH)
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	c, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	var mu sync.Mutex
+	var n int
+	m := tcpinfo.NewMonitor(10*time.Millisecond, func(_ net.Conn, s *tcpinfo.Sample) {
+		mu.Lock()
+		n++
+		mu.Unlock()
+	})
+	defer m.Close()
+	m.Add(c)
+	m.Add(c)
+	if cs := m.Conns(); len(cs) != 1 || cs[0] != c {
+		t.Fatalf("got %v; want [%v]", cs, c)
+	}
+	time.Sleep(50 * time.Millisecond)
+	s := m.Latest(c)
+	if s == nil || s.Err != nil || s.Info == nil {
+		t.Fatalf("got %+v; want a valid sample", s)
+	}
+	fs := m.Remove(c)
+	if fs == nil || fs.Err != nil {
+		t.Fatalf("got %+v; want valid final stats", fs)
+	}
+	if m.Remove(c) != nil {
+		t.Fatal("got non-nil; want nil for untracked connection")
+	}
+	if len(m.Conns()) != 0 || m.Latest(c) != nil {
+		t.Fatal("connection still tracked")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if n < 2 {
+		t.Fatalf("got %d samples; want at least 2", n)
+	}
+}
+
+func TestMonitorConnClosed(t *testing.T) {
+	switch runtime.GOOS {
+	case "darwin", "freebsd", "linux", "netbsd":
+	default:
+		t.Skipf("not supported on %s/%s", runtime.GOOS, runtime.GOARCH)
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	c, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var mu sync.Mutex
+	var errs, finals int
+	var tomb *tcpinfo.Sample
+	m := tcpinfo.NewMonitor(5*time.Millisecond, func(_ net.Conn, s *tcpinfo.Sample) {
+		mu.Lock()
+		defer mu.Unlock()
+		if s.Err != nil {
+			errs++
+		}
+		if s.Final {
+			finals++
+			tomb = s
+		}
+	})
+	defer m.Close()
+	m.Add(c)
+	time.Sleep(20 * time.Millisecond)
+	c.Close()
+	time.Sleep(50 * time.Millisecond)
+	if len(m.Conns()) != 0 {
+		t.Fatal("connection still tracked")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if errs != 1 || finals != 1 || !errors.Is(tomb.Err, tcpinfo.ErrConnClosed) {
+		t.Fatalf("got %d errors, %d finals, %v; want a single tombstone with %v", errs, finals, tomb, tcpinfo.ErrConnClosed)
+	}
+	var e *tcpinfo.ConnClosedError
+	if !errors.As(tomb.Err, &e) || e.Reset() {
+		t.Fatalf("got %#v; want locally closed", tomb.Err)
+	}
+}
+
+func TestMonitorDelivery(t *testing.T) {
+	g := tcpinfotest.NewGetter()
+	cs := make([]net.Conn, 64)
+	for i := range cs {
+		cs[i] = tcpinfotest.NewConn("192.0.2.1:443", fmt.Sprintf("192.0.2.2:%d", 50000+i))
+		g.Set(cs[i], tcpinfotest.NewInfo().RTT(20*time.Millisecond, time.Millisecond).Build())
+	}
+
+	var mu sync.Mutex
+	last := make(map[net.Conn]*tcpinfo.Sample)
+	m := tcpinfo.NewMonitorWithGetter(g, time.Millisecond, func(c net.Conn, s *tcpinfo.Sample) {
+		mu.Lock()
+		defer mu.Unlock()
+		if prev := last[c]; prev != nil && (prev.Final || s.Time.Before(prev.Time)) {
+			t.Errorf("%v: got %+v after %+v", c.RemoteAddr(), s, prev)
+		}
+		last[c] = s
+	})
+	defer m.Close()
+	for _, c := range cs {
+		m.Add(c)
+	}
+	time.Sleep(20 * time.Millisecond)
+	for _, c := range cs {
+		m.Remove(c)
+		mu.Lock()
+		s := last[c]
+		mu.Unlock()
+		if s == nil || !s.Final {
+			t.Fatalf("%v: got %+v; want the final sample delivered", c.RemoteAddr(), s)
+		}
+	}
+}
+
+func TestMonitorLookup(t *testing.T) {
+	g := tcpinfotest.NewGetter()
+	c1 := tcpinfotest.NewConn("192.0.2.1:443", "192.0.2.2:50000")
+	c2 := tcpinfotest.NewConn("[2001:db8::1]:443", "[2001:db8::2]:50000")
+	m := tcpinfo.NewMonitorWithGetter(g, time.Hour, nil)
+	defer m.Close()
+	m.Add(c1)
+	m.Add(c2)
+
+	for _, tt := range []struct {
+		laddr, raddr string
+		c            net.Conn
+	}{
+		{"192.0.2.1:443", "192.0.2.2:50000", c1},
+		{"[::ffff:192.0.2.1]:443", "[::ffff:192.0.2.2]:50000", c1},
+		{"[2001:db8::1]:443", "[2001:db8::2]:50000", c2},
+		{"192.0.2.2:50000", "192.0.2.1:443", nil},
+		{"192.0.2.1:443", "192.0.2.2:50001", nil},
+	} {
+		laddr, _ := net.ResolveTCPAddr("tcp", tt.laddr)
+		raddr, _ := net.ResolveTCPAddr("tcp", tt.raddr)
+		if c := m.Lookup(laddr, raddr); c != tt.c {
+			t.Fatalf("%s, %s: got %v; want %v", tt.laddr, tt.raddr, c, tt.c)
+		}
+	}
+	m.Remove(c1)
+	if c := m.Lookup(c1.LocalAddr(), c1.RemoteAddr()); c != nil {
+		t.Fatalf("got %v; want nil for untracked connection", c)
+	}
+}
+
+// BenchmarkMonitorDelivery measures the delivery of samples handed
+// over by many samplers concurrently.
+func BenchmarkMonitorDelivery(b *testing.B) {
+	for _, n := range []int{16, 1024} {
+		b.Run(fmt.Sprintf("conns=%d", n), func(b *testing.B) {
+			benchmarkMonitorDelivery(b, n)
+		})
+	}
+}
+
+func benchmarkMonitorDelivery(b *testing.B, n int) {
+	g := tcpinfotest.NewGetter()
+	cs := make([]net.Conn, n)
+	for i := range cs {
+		cs[i] = tcpinfotest.NewConn("192.0.2.1:443", fmt.Sprintf("192.0.2.2:%d", 10000+i))
+		g.Set(cs[i], tcpinfotest.NewInfo().Build())
+	}
+
+	smp := &tcpinfo.Sample{Info: tcpinfotest.NewInfo().Build()}
+	var delivered int64
+	m := tcpinfo.NewMonitorWithGetter(g, time.Hour, func(_ net.Conn, s *tcpinfo.Sample) {
+		if s == smp {
+			atomic.AddInt64(&delivered, 1)
+		}
+	})
+	defer m.Close()
+	for _, c := range cs {
+		m.Add(c)
+	}
+	var next uint32
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := int(atomic.AddUint32(&next, 1))
+		for ; pb.Next(); i++ {
+			m.Enqueue(cs[i%len(cs)], smp)
+		}
+	})
+	for atomic.LoadInt64(&delivered) < int64(b.N) {
+		runtime.Gosched()
+	}
+	b.StopTimer()
+}
+
+// BenchmarkMonitorLatest measures lookups of the latest samples while
+// many connections deliver samples concurrently.
+func BenchmarkMonitorLatest(b *testing.B) {
+	for _, n := range []int{16, 256} {
+		b.Run(fmt.Sprintf("conns=%d", n), func(b *testing.B) {
+			benchmarkMonitorLatest(b, n)
+		})
+	}
+}
+
+func benchmarkMonitorLatest(b *testing.B, n int) {
+	switch runtime.GOOS {
+	case "darwin", "freebsd", "linux", "netbsd":
+	default:
+		b.Skipf("not supported on %s/%s", runtime.GOOS, runtime.GOARCH)
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer ln.Close()
+	cs := make([]net.Conn, n)
+	for i := range cs {
+		c, err := net.Dial("tcp", ln.Addr().String())
+		if err != nil {
+			b.Fatal(err)
+		}
+		defer c.Close()
+		ac, err := ln.Accept()
+		if err != nil {
+			b.Fatal(err)
+		}
+		defer ac.Close()
+		cs[i] = c
+	}
+
+	var samples int64
+	m := tcpinfo.NewMonitor(time.Millisecond, func(net.Conn, *tcpinfo.Sample) {
+		atomic.AddInt64(&samples, 1)
+	})
+	for _, c := range cs {
+		m.Add(c)
+	}
+	b.ResetTimer()
+	start := time.Now()
+	b.RunParallel(func(pb *testing.PB) {
+		for i := 0; pb.Next(); i++ {
+			m.Latest(cs[i%len(cs)])
+		}
+	})
+	b.StopTimer()
+	b.ReportMetric(float64(atomic.LoadInt64(&samples))/time.Since(start).Seconds(), "samples/s")
+	m.Close()
+}
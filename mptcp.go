@@ -0,0 +1,52 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcpinfo
+
+import "net"
+
+// An MPTCPInfo represents Multipath TCP connection information.
+//
+// Only supported on Linux.
+type MPTCPInfo struct {
+	Subflows           uint    `json:"subflows"`               // # of subflows
+	SubflowsMax        uint    `json:"subflows_max"`           // maximum # of subflows
+	AddAddrSignal      uint    `json:"add_addr_signal"`        // # of addresses announced to peer
+	AddAddrSignalMax   uint    `json:"add_addr_signal_max"`    // maximum # of addresses announced to peer
+	AddAddrAccepted    uint    `json:"add_addr_accepted"`      // # of addresses accepted from peer
+	AddAddrAcceptedMax uint    `json:"add_addr_accepted_max"`  // maximum # of addresses accepted from peer
+	LocalAddrUsed      uint    `json:"local_addr_used"`        // # of local addresses used
+	LocalAddrMax       uint    `json:"local_addr_max"`         // maximum # of local addresses used
+	Flags              uint    `json:"flags"`                  // connection flags
+	Token              uint32  `json:"token"`                  // local connection token
+	WriteSeq           uint64  `json:"write_seq"`              // next data sequence number to be written
+	SenderUnacked      uint64  `json:"snd_una"`                // oldest unacknowledged data sequence number
+	ReceiverNext       uint64  `json:"rcv_nxt"`                // next data sequence number expected
+	ChecksumEnabled    bool    `json:"csum_enabled"`           // whether DSS checksum is enabled
+	Retransmits        uint    `json:"retransmits"`            // # of retransmissions at connection level
+	BytesRetrans       uint64  `json:"bytes_retrans"`          // # of bytes retransmitted at connection level
+	BytesSent          uint64  `json:"bytes_sent"`             // # of bytes sent at connection level
+	BytesReceived      uint64  `json:"bytes_rcvd"`             // # of bytes received at connection level
+	BytesAcked         uint64  `json:"bytes_acked"`            // # of bytes acknowledged at connection level
+	SubflowInfo        []*Info `json:"subflow_info,omitempty"` // connection information of each subflow
+}
+
+// GetMPTCP returns Multipath TCP connection information on c,
+// including connection information of each subflow.
+//
+// The connection must implement syscall.Conn and be a Multipath TCP
+// connection.
+// Only supported on Linux.
+func GetMPTCP(c net.Conn) (*MPTCPInfo, error) {
+	var mi *MPTCPInfo
+	err := control(c, func(s uintptr) error {
+		var err error
+		mi, err = getMPTCP(s)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return mi, nil
+}
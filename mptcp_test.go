@@ -0,0 +1,52 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build go1.21
+
+package tcpinfo_test
+
+import (
+	"context"
+	"net"
+	"runtime"
+	"testing"
+
+	"github.com/mikioh/tcpinfo"
+)
+
+func TestGetMPTCP(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skipf("not supported on %s/%s", runtime.GOOS, runtime.GOARCH)
+	}
+	var lc net.ListenConfig
+	lc.SetMultipathTCP(true)
+	ln, err := lc.Listen(context.Background(), "tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	var d net.Dialer
+	d.SetMultipathTCP(true)
+	c, err := d.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if ok, _ := c.(*net.TCPConn).MultipathTCP(); !ok {
+		t.Skip("multipath tcp not available")
+	}
+
+	mi, err := tcpinfo.GetMPTCP(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mi.SubflowInfo) == 0 {
+		t.Fatalf("got %+v; want at least one subflow", mi)
+	}
+	for _, i := range mi.SubflowInfo {
+		if i.State != tcpinfo.Established {
+			t.Fatalf("got %v; want %v", i.State, tcpinfo.Established)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcpinfo
+
+import (
+	"errors"
+	"net"
+)
+
+var errNoInterface = errors.New("no interface for local address")
+
+// An Offload represents segmentation offload state of the network
+// interface carrying a connection.
+//
+// Offloads hand the network device packets larger than the maximum
+// segment size, and misconfigured ones make the host rather than the
+// path the bottleneck of throughput.
+//
+// Only supported on Linux.
+type Offload struct {
+	Interface  string `json:"interface"`    // name of network interface
+	TSO        bool   `json:"tso"`          // whether TCP segmentation offload is enabled
+	GSO        bool   `json:"gso"`          // whether generic segmentation offload is enabled
+	GRO        bool   `json:"gro"`          // whether generic receive offload is enabled
+	GSOMaxSize uint   `json:"gso_max_size"` // maximum size of a segmentation offload packet in bytes
+	GSOMaxSegs uint   `json:"gso_max_segs"` // maximum # of segments in a segmentation offload packet
+	Segs       uint   `json:"segs"`         // # of segments per segmentation offload packet the kernel sizes from the pacing rate of connection; zero when unknown
+}
+
+// Active reports whether segmentation offload appears active for the
+// connection.
+// Packets carry up to Segs segments, which stays at the minimum on
+// connections with low pacing rates.
+func (o *Offload) Active() bool {
+	return o.TSO || o.GSO
+}
+
+// minTSOSegs is the minimum # of segments per segmentation offload
+// packet that the kernel sizes.
+const minTSOSegs = 2
+
+// GetOffload returns segmentation offload state of the network
+// interface carrying c.
+//
+// The interface is looked up by the local address of c, and the
+// connection must implement syscall.Conn.
+// Only supported on Linux.
+func GetOffload(c net.Conn) (*Offload, error) {
+	ifi, err := interfaceByAddr(c.LocalAddr())
+	if err != nil {
+		return nil, err
+	}
+	i, err := Get(c)
+	if err != nil {
+		return nil, err
+	}
+	var o *Offload
+	if err := control(c, func(s uintptr) error {
+		var err error
+		o, err = getOffload(s, ifi.Name, ifi.Index, i)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+// interfaceByAddr returns the network interface having the IP
+// address of addr.
+func interfaceByAddr(addr net.Addr) (*net.Interface, error) {
+	a, ok := addr.(*net.TCPAddr)
+	if !ok {
+		return nil, &Error{Op: "get", Kind: "offload", Platform: platform, Err: errNoInterface}
+	}
+	ift, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+	for _, ifi := range ift {
+		ifat, err := ifi.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, ifa := range ifat {
+			if ipn, ok := ifa.(*net.IPNet); ok && ipn.IP.Equal(a.IP) {
+				return &ifi, nil
+			}
+		}
+	}
+	return nil, &Error{Op: "get", Kind: "offload", Platform: platform, Err: errNoInterface}
+}
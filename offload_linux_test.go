@@ -0,0 +1,39 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcpinfo_test
+
+import (
+	"net"
+	"testing"
+
+	"github.com/mikioh/tcpinfo"
+)
+
+func TestGetOffload(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	c, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	o, err := tcpinfo.GetOffload(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o.Interface == "" {
+		t.Fatalf("got %+v; want interface name", o)
+	}
+	if o.Segs != 0 && o.GSOMaxSegs != 0 && o.Segs > o.GSOMaxSegs {
+		t.Fatalf("got %d segments; want at most %d", o.Segs, o.GSOMaxSegs)
+	}
+	if o.Segs != 0 && o.Segs < 2 {
+		t.Fatalf("got %d segments; want at least 2", o.Segs)
+	}
+}
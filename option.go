@@ -34,7 +34,61 @@ type Info struct {
 	LastAckReceived   time.Duration      `json:"last_ack_rcvd"`       // since last ack received [Linux only]
 	FlowControl       *FlowControl       `json:"flow_ctl,omitempty"`  // flow control information
 	CongestionControl *CongestionControl `json:"cong_ctl,omitempty"`  // congestion control information
+	Queue             *Queue             `json:"queue,omitempty"`     // queue occupancy; nil when not available
 	Sys               *SysInfo           `json:"sys,omitempty"`       // platform-specific information
+
+	// KernelStructSize is the length of the structure returned by
+	// the kernel; zero means unknown.
+	// Fields of platform-specific information that the kernel
+	// does not report are absent and left zero.
+	KernelStructSize int `json:"-"`
+
+	// Truncated reports whether the structure returned by the
+	// kernel was cut short of any supported kernel version and
+	// parsed leniently.
+	Truncated bool `json:"-"`
+
+	// Raw is a copy of the structure returned by the kernel and
+	// RawMeta its annotation, retained when FieldRaw is requested,
+	// which allows unusual fields to be post-processed and the
+	// structure to be parsed again by ReparseRaw.
+	Raw     []byte   `json:"-"`
+	RawMeta *RawMeta `json:"-"`
+
+	fields FieldMask // groups of fields filled in by parsing
+}
+
+// Clone returns a deep copy of i.
+// Options are shared as they are never modified.
+func (i *Info) Clone() *Info {
+	if i == nil {
+		return nil
+	}
+	ni := *i
+	ni.Options = append([]Option(nil), i.Options...)
+	ni.PeerOptions = append([]Option(nil), i.PeerOptions...)
+	if i.FlowControl != nil {
+		fc := *i.FlowControl
+		ni.FlowControl = &fc
+	}
+	if i.CongestionControl != nil {
+		cc := *i.CongestionControl
+		ni.CongestionControl = &cc
+	}
+	if i.Queue != nil {
+		q := *i.Queue
+		ni.Queue = &q
+	}
+	if i.Sys != nil {
+		sys := *i.Sys
+		ni.Sys = &sys
+	}
+	ni.Raw = append([]byte(nil), i.Raw...)
+	if i.RawMeta != nil {
+		meta := *i.RawMeta
+		ni.RawMeta = &meta
+	}
+	return &ni
 }
 
 // A FlowControl represents flow control information.
@@ -59,41 +113,151 @@ func (i *Info) Name() int { return options[soInfo].name }
 // MarshalJSON implements the MarshalJSON method of json.Marshaler
 // interface.
 func (i *Info) MarshalJSON() ([]byte, error) {
-	raw := make(map[string]interface{})
-	raw["state"] = i.State.String()
-	if len(i.Options) > 0 {
-		opts := make(map[string]interface{})
-		for _, opt := range i.Options {
-			opts[opt.Kind().String()] = opt
-		}
-		raw["opts"] = opts
-	}
-	if len(i.PeerOptions) > 0 {
-		opts := make(map[string]interface{})
-		for _, opt := range i.PeerOptions {
-			opts[opt.Kind().String()] = opt
-		}
-		raw["peer_opts"] = opts
-	}
-	raw["snd_mss"] = i.SenderMSS
-	raw["rcv_mss"] = i.ReceiverMSS
-	raw["rtt"] = i.RTT
-	raw["rttvar"] = i.RTTVar
-	raw["rto"] = i.RTO
-	raw["ato"] = i.ATO
-	raw["last_data_sent"] = i.LastDataSent
-	raw["last_data_rcvd"] = i.LastDataReceived
-	raw["last_ack_rcvd"] = i.LastAckReceived
-	if i.FlowControl != nil {
-		raw["flow_ctl"] = i.FlowControl
+	var m JSONMarshaler
+	return m.Marshal(i)
+}
+
+// Parse parses b as connection information.
+//
+// Parse is safe for use on arbitrary input, such as structures
+// captured from other kernels; it neither panics nor reads beyond
+// b, and returns an error when b is too short to parse.
+// The same holds for ParseInto, ParseFields, Parser and
+// ParseCCAlgorithmInfo.
+//
+// Only supported on Darwin, FreeBSD, Linux and NetBSD.
+func Parse(b []byte) (*Info, error) {
+	i := new(Info)
+	if err := parseInfoInto(b, i, FieldAll, ParseDefault); err != nil {
+		return nil, err
 	}
-	if i.CongestionControl != nil {
-		raw["cong_ctl"] = i.CongestionControl
+	return i, nil
+}
+
+// ParseInto parses b as connection information into i.
+//
+// ParseInto reuses the memory held by i, including the option
+// slices and the structures pointed to by i, and does not allocate
+// once i has been filled in by a previous call.
+// The caller must not retain references into i across calls.
+//
+// Only supported on Darwin, FreeBSD, Linux and NetBSD.
+func ParseInto(b []byte, i *Info) error {
+	return parseInfoInto(b, i, FieldAll, ParseDefault)
+}
+
+// A FieldMask represents a set of groups of fields of connection
+// information.
+// The State, SenderMSS, ReceiverMSS and duration fields are always
+// filled in.
+type FieldMask uint
+
+const (
+	FieldOptions           FieldMask = 1 << iota // Options and PeerOptions
+	FieldFlowControl                             // FlowControl
+	FieldCongestionControl                       // CongestionControl
+	FieldQueue                                   // Queue
+	FieldSys                                     // Sys
+	FieldRaw                                     // Raw and RawMeta, retained only when requested explicitly
+	FieldSockOpts                                // options held in socket options, retrieved only when requested explicitly
+
+	FieldAll = FieldOptions | FieldFlowControl | FieldCongestionControl | FieldQueue | FieldSys
+)
+
+// ParseFields is like ParseInto but fills in only the groups of
+// fields in m, skipping the decoding of the others.
+// The fields not in m are left empty.
+//
+// Only supported on Darwin, FreeBSD, Linux and NetBSD.
+func ParseFields(b []byte, i *Info, m FieldMask) error {
+	if err := parseInfoInto(b, i, m, ParseDefault); err != nil {
+		return err
 	}
-	if i.Sys != nil {
-		raw["sys"] = i.Sys
+	retainRaw(b, i, m)
+	return nil
+}
+
+// A ParseMode represents how to treat structures of unexpected
+// length returned by the kernel.
+type ParseMode int
+
+const (
+	// ParseDefault accepts the structures of all the supported
+	// kernel versions and rejects shorter ones.
+	ParseDefault ParseMode = iota
+
+	// ParseStrict rejects structures of a length that no
+	// supported kernel version returns, such as truncated ones.
+	ParseStrict
+
+	// ParseLenient parses the longest valid prefix of a
+	// structure of any length and records truncation in the
+	// Truncated field of Info.
+	ParseLenient
+)
+
+// A Parser represents a configurable parser of connection
+// information.
+//
+// The zero value parses the same as ParseInto.
+type Parser struct {
+	Mode   ParseMode // treatment of structures of unexpected length
+	Fields FieldMask // groups of fields to fill in; zero means FieldAll
+}
+
+// Parse parses b as connection information into i, as ParseInto
+// does.
+//
+// Only supported on Darwin, FreeBSD, Linux and NetBSD.
+func (p *Parser) Parse(b []byte, i *Info) error {
+	m := p.Fields
+	if m == 0 {
+		m = FieldAll
 	}
-	return json.Marshal(&raw)
+	if err := parseInfoInto(b, i, m, p.Mode); err != nil {
+		return err
+	}
+	retainRaw(b, i, m)
+	return nil
+}
+
+func parseInfo(b []byte) (tcpopt.Option, error) {
+	i, err := Parse(b)
+	if err != nil {
+		return nil, err
+	}
+	return i, nil
+}
+
+// recycle clears i while retaining its allocated memory.
+// The structures for the groups of fields in m are allocated when
+// missing, and the others are released.
+func (i *Info) recycle(m FieldMask) {
+	opts, peerOpts := i.Options[:0], i.PeerOptions[:0]
+	fc, cc, sys := i.FlowControl, i.CongestionControl, i.Sys
+	switch {
+	case m&FieldFlowControl == 0:
+		fc = nil
+	case fc == nil:
+		fc = new(FlowControl)
+	}
+	switch {
+	case m&FieldCongestionControl == 0:
+		cc = nil
+	case cc == nil:
+		cc = new(CongestionControl)
+	}
+	switch {
+	case m&FieldSys == 0:
+		sys = nil
+	case sys == nil:
+		sys = new(SysInfo)
+	}
+	raw, meta := i.Raw[:0], i.RawMeta
+	if m&FieldRaw == 0 {
+		raw, meta = nil, nil
+	}
+	*i = Info{Options: opts, PeerOptions: peerOpts, FlowControl: fc, CongestionControl: cc, Sys: sys, Raw: raw, RawMeta: meta, fields: m}
 }
 
 // A CCInfo represents raw information of congestion control
@@ -134,6 +298,10 @@ func (cca CCAlgorithm) Marshal() ([]byte, error) {
 	return []byte(cca), nil
 }
 
+// Kind returns an option kind.
+// It allows the algorithm to be reported in Info.Options.
+func (cca CCAlgorithm) Kind() OptionKind { return KindCCAlgorithm }
+
 func parseCCAlgorithm(b []byte) (tcpopt.Option, error) { return CCAlgorithm(b), nil }
 
 // A CCAlgorithmInfo represents congestion control algorithm
@@ -204,3 +372,13 @@ func ParseCCAlgorithmInfo(name string, b []byte) (CCAlgorithmInfo, error) {
 	}
 	return ccai, nil
 }
+
+// ParseCCAlgorithmInfoInto parses congestion control algorithm
+// information into ccai without allocation.
+// The ccai must be a pointer to the structure of the algorithm in
+// use, such as *VegasInfo, *DCTCPInfo or *BBRInfo.
+//
+// Only supported on Linux.
+func ParseCCAlgorithmInfoInto(b []byte, ccai CCAlgorithmInfo) error {
+	return parseCCAlgorithmInfoInto(b, ccai)
+}
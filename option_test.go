@@ -5,6 +5,8 @@
 package tcpinfo_test
 
 import (
+	"encoding/json"
+	"reflect"
 	"runtime"
 	"testing"
 
@@ -65,3 +67,53 @@ func TestParseWithVariousBufferLengths(t *testing.T) {
 		}
 	}
 }
+
+func TestOptionKindText(t *testing.T) {
+	kinds := []tcpinfo.OptionKind{tcpinfo.KindMaxSegSize, tcpinfo.KindWindowScale, tcpinfo.KindSACKPermitted, tcpinfo.KindTimestamps, tcpinfo.KindECN, tcpinfo.KindCCAlgorithm, 253}
+	b, err := json.Marshal(kinds)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := string(b); s != `["mss","wscale","sack","tmstamps","ecn","cc","253"]` {
+		t.Fatalf("got %s", s)
+	}
+	var got []tcpinfo.OptionKind
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, kinds) {
+		t.Fatalf("got %v; want %v", got, kinds)
+	}
+	if k, err := tcpinfo.ParseOptionKind("timestamps"); err != nil || k != tcpinfo.KindTimestamps {
+		t.Fatalf("got %v, %v; want %v", k, err, tcpinfo.KindTimestamps)
+	}
+	if _, err := tcpinfo.ParseOptionKind("bogus"); err == nil {
+		t.Fatal("got nil; want an error")
+	}
+}
+
+func TestStateText(t *testing.T) {
+	for _, st := range []tcpinfo.State{tcpinfo.Established, tcpinfo.TimeWait, 42} {
+		b, err := json.Marshal(st)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got tcpinfo.State
+		if err := json.Unmarshal(b, &got); err != nil {
+			t.Fatal(err)
+		}
+		if got != st {
+			t.Fatalf("got %v; want %v", got, st)
+		}
+	}
+	if s := tcpinfo.State(42).String(); s != "invalid(42)" {
+		t.Fatalf("got %q; want invalid(42)", s)
+	}
+	var st tcpinfo.State
+	if err := json.Unmarshal([]byte("6"), &st); err != nil || st != tcpinfo.FinWait1 {
+		t.Fatalf("got %v, %v; want %v", st, err, tcpinfo.FinWait1)
+	}
+	if _, err := tcpinfo.ParseState("bogus"); err == nil {
+		t.Fatal("got nil; want an error")
+	}
+}
@@ -0,0 +1,18 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build linux,tcpinfo_unsafe
+
+package tcpinfo
+
+import "unsafe"
+
+// overlayInfo returns b as the kernel struct tcp_info without
+// decoding, or nil when b is too short or not suitably aligned.
+func overlayInfo(b []byte) *tcpInfo {
+	if len(b) < sizeofTCPInfo || uintptr(unsafe.Pointer(&b[0]))%unsafe.Alignof(tcpInfo{}) != 0 {
+		return nil
+	}
+	return (*tcpInfo)(unsafe.Pointer(&b[0]))
+}
@@ -0,0 +1,122 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcpinfo_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/mikioh/tcpinfo"
+)
+
+func TestParseIntoUnaligned(t *testing.T) {
+	b := make([]byte, 257)
+	for j := 0; j < 4; j++ {
+		b[68+j] = 0x01 // tcpi_rtt
+	}
+	for j := 0; j < 8; j++ {
+		b[104+j] = 0x02 // tcpi_pacing_rate
+	}
+	var aligned, unaligned tcpinfo.Info
+	if err := tcpinfo.ParseInto(b[:256], &aligned); err != nil {
+		t.Fatal(err)
+	}
+	copy(b[1:], b[:256])
+	if err := tcpinfo.ParseInto(b[1:], &unaligned); err != nil {
+		t.Fatal(err)
+	}
+	if aligned.RTT != 0x01010101*time.Microsecond || aligned.Sys.PacingRate != 0x0202020202020202 {
+		t.Fatalf("got %v, %#x; want %v, %#x", aligned.RTT, aligned.Sys.PacingRate, 0x01010101*time.Microsecond, uint64(0x0202020202020202))
+	}
+	if unaligned.RTT != aligned.RTT || *unaligned.Sys != *aligned.Sys {
+		t.Fatalf("got %+v; want %+v", unaligned.Sys, aligned.Sys)
+	}
+}
+
+func TestParseIntoShortKernelStruct(t *testing.T) {
+	b := make([]byte, 256)
+	for _, tt := range []struct {
+		n       int
+		present []string
+		absent  []string
+	}{
+		{104, []string{"total_retrans_segs"}, []string{"pacing_rate", "min_rtt", "delivery_rate"}},
+		{160, []string{"pacing_rate", "min_rtt"}, []string{"delivery_rate"}},
+		{168, []string{"pacing_rate", "min_rtt", "delivery_rate"}, nil},
+	} {
+		var i tcpinfo.Info
+		if err := tcpinfo.ParseInto(b[:tt.n], &i); err != nil {
+			t.Fatal(err)
+		}
+		if i.KernelStructSize != tt.n {
+			t.Fatalf("got %d; want %d", i.KernelStructSize, tt.n)
+		}
+		js, err := i.MarshalJSON()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var m struct {
+			Sys map[string]interface{} `json:"sys"`
+		}
+		if err := json.Unmarshal(js, &m); err != nil {
+			t.Fatal(err)
+		}
+		for _, k := range tt.present {
+			if _, ok := m.Sys[k]; !ok {
+				t.Fatalf("%d: %s is absent; want present", tt.n, k)
+			}
+		}
+		for _, k := range tt.absent {
+			if _, ok := m.Sys[k]; ok {
+				t.Fatalf("%d: %s is present; want absent", tt.n, k)
+			}
+		}
+	}
+	if err := tcpinfo.ParseInto(b[:103], new(tcpinfo.Info)); err == nil {
+		t.Fatal("got nil; want an error")
+	}
+}
+
+func TestValidShortKernelStruct(t *testing.T) {
+	var i tcpinfo.Info
+	if err := tcpinfo.ParseInto(make([]byte, 160), &i); err != nil {
+		t.Fatal(err)
+	}
+	if !i.Valid("min_rtt") || i.Valid("delivery_rate") {
+		t.Fatalf("got %v, %v; want true, false", i.Valid("min_rtt"), i.Valid("delivery_rate"))
+	}
+	ds := i.Stats()
+	if !ds.Valid("min_rtt") || ds.Valid("delivery_rate") {
+		t.Fatalf("got %v, %v; want true, false", ds.Valid("min_rtt"), ds.Valid("delivery_rate"))
+	}
+}
+
+func TestParserModesKernelStructSizes(t *testing.T) {
+	b := make([]byte, 256)
+	var i tcpinfo.Info
+	strict := tcpinfo.Parser{Mode: tcpinfo.ParseStrict}
+	for _, n := range []int{104, 120, 136, 144, 160, 168, 232} {
+		if err := strict.Parse(b[:n], &i); err != nil {
+			t.Fatalf("%d: %v", n, err)
+		}
+	}
+	for _, n := range []int{100, 150, 164} {
+		if err := strict.Parse(b[:n], &i); err == nil {
+			t.Fatalf("%d: got nil; want an error", n)
+		}
+	}
+	if err := tcpinfo.ParseInto(b[:150], &i); err != nil || i.Truncated {
+		t.Fatalf("got %v, %v; want nil, false", err, i.Truncated)
+	}
+
+	lenient := tcpinfo.Parser{Mode: tcpinfo.ParseLenient}
+	if err := lenient.Parse(b[:70], &i); err != nil {
+		t.Fatal(err)
+	}
+	if !i.Truncated || !i.Valid("rto") || !i.Valid("rcv_ssthresh") || i.Valid("rtt") || i.Valid("rcv_wnd") {
+		t.Fatalf("got %v, %v, %v, %v, %v; want true, true, true, false, false", i.Truncated, i.Valid("rto"), i.Valid("rcv_ssthresh"), i.Valid("rtt"), i.Valid("rcv_wnd"))
+	}
+}
@@ -0,0 +1,142 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcpinfo_test
+
+import (
+	"errors"
+	"runtime"
+	"testing"
+
+	"github.com/mikioh/tcpinfo"
+)
+
+func TestParseInto(t *testing.T) {
+	switch runtime.GOOS {
+	case "darwin", "freebsd", "linux", "netbsd":
+	default:
+		t.Skipf("not supported on %s/%s", runtime.GOOS, runtime.GOARCH)
+	}
+	b := make([]byte, 256)
+	i := &tcpinfo.Info{RTT: 1, Queue: &tcpinfo.Queue{}}
+	if err := tcpinfo.ParseInto(b, i); err != nil {
+		t.Fatal(err)
+	}
+	if i.RTT != 0 || i.Queue != nil || i.FlowControl == nil || i.CongestionControl == nil || i.Sys == nil {
+		t.Fatalf("got %+v; want reset and filled in", i)
+	}
+	fc, sys := i.FlowControl, i.Sys
+	if n := testing.AllocsPerRun(100, func() {
+		if err := tcpinfo.ParseInto(b, i); err != nil {
+			t.Fatal(err)
+		}
+	}); n != 0 {
+		t.Fatalf("got %v allocs; want 0", n)
+	}
+	if i.FlowControl != fc || i.Sys != sys {
+		t.Fatal("structures not reused")
+	}
+	if err := tcpinfo.ParseInto(b[:1], i); err == nil {
+		t.Fatal("got nil; want an error")
+	}
+
+	if runtime.GOOS != "linux" {
+		return
+	}
+	var vi tcpinfo.VegasInfo
+	if n := testing.AllocsPerRun(100, func() {
+		if err := tcpinfo.ParseCCAlgorithmInfoInto(b, &vi); err != nil {
+			t.Fatal(err)
+		}
+	}); n != 0 {
+		t.Fatalf("got %v allocs; want 0", n)
+	}
+}
+
+func BenchmarkParseInto(b *testing.B) {
+	switch runtime.GOOS {
+	case "darwin", "freebsd", "linux", "netbsd":
+	default:
+		b.Skipf("not supported on %s/%s", runtime.GOOS, runtime.GOARCH)
+	}
+	buf := make([]byte, 256)
+	var i tcpinfo.Info
+	b.ReportAllocs()
+	for j := 0; j < b.N; j++ {
+		if err := tcpinfo.ParseInto(buf, &i); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestParseFields(t *testing.T) {
+	switch runtime.GOOS {
+	case "darwin", "freebsd", "linux", "netbsd":
+	default:
+		t.Skipf("not supported on %s/%s", runtime.GOOS, runtime.GOARCH)
+	}
+	b := make([]byte, 256)
+	var i tcpinfo.Info
+	if err := tcpinfo.ParseInto(b, &i); err != nil {
+		t.Fatal(err)
+	}
+	if err := tcpinfo.ParseFields(b, &i, tcpinfo.FieldSys); err != nil {
+		t.Fatal(err)
+	}
+	if i.FlowControl != nil || i.CongestionControl != nil || i.Sys == nil {
+		t.Fatalf("got %+v; want only Sys", i)
+	}
+	if err := tcpinfo.ParseFields(b, &i, 0); err != nil {
+		t.Fatal(err)
+	}
+	if i.FlowControl != nil || i.CongestionControl != nil || i.Sys != nil || len(i.Options) != 0 {
+		t.Fatalf("got %+v; want no groups of fields", i)
+	}
+}
+
+func BenchmarkParseFields(b *testing.B) {
+	switch runtime.GOOS {
+	case "darwin", "freebsd", "linux", "netbsd":
+	default:
+		b.Skipf("not supported on %s/%s", runtime.GOOS, runtime.GOARCH)
+	}
+	buf := make([]byte, 256)
+	var i tcpinfo.Info
+	b.ReportAllocs()
+	for j := 0; j < b.N; j++ {
+		if err := tcpinfo.ParseFields(buf, &i, 0); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestParserModes(t *testing.T) {
+	switch runtime.GOOS {
+	case "darwin", "freebsd", "linux", "netbsd":
+	default:
+		t.Skipf("not supported on %s/%s", runtime.GOOS, runtime.GOARCH)
+	}
+	b := make([]byte, 256)
+	var i tcpinfo.Info
+	for _, mode := range []tcpinfo.ParseMode{tcpinfo.ParseDefault, tcpinfo.ParseStrict, tcpinfo.ParseLenient} {
+		p := tcpinfo.Parser{Mode: mode}
+		if err := p.Parse(b, &i); err != nil {
+			t.Fatal(err)
+		}
+		if i.Truncated {
+			t.Fatalf("%v: got truncated; want not truncated", mode)
+		}
+	}
+	p := tcpinfo.Parser{Mode: tcpinfo.ParseStrict}
+	if err := p.Parse(b[:20], &i); !errors.Is(err, tcpinfo.ErrBufferTooShort) {
+		t.Fatalf("got %v; want %v", err, tcpinfo.ErrBufferTooShort)
+	}
+	p = tcpinfo.Parser{Mode: tcpinfo.ParseLenient, Fields: tcpinfo.FieldSys}
+	if err := p.Parse(b[:20], &i); err != nil {
+		t.Fatal(err)
+	}
+	if !i.Truncated || i.KernelStructSize != 20 || i.Sys == nil || i.FlowControl != nil {
+		t.Fatalf("got %+v; want truncated Info with only Sys", i)
+	}
+}
@@ -0,0 +1,155 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcpinfo
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+)
+
+// A Prober measures the path to a target actively by transferring
+// data over a connection and sampling connection information
+// throughout the transfer.
+//
+// The target must accept and discard data, such as a discard service
+// or a sink of a load balancer health check.
+type Prober struct {
+	Dialer   *net.Dialer   // dialer; nil means the zero value of net.Dialer
+	Getter   Getter        // getter of connection information; nil means retrieval via the socket
+	Size     int64         // # of bytes transferred; defaults to 1MiB
+	Interval time.Duration // sampling interval; defaults to 10ms
+}
+
+// A ProbeReport represents the result of a probe.
+//
+// Loss is not available on platforms not providing the # of segments
+// sent and retransmitted, in which case LossValid is false.
+type ProbeReport struct {
+	Target    string        // address of target
+	Duration  time.Duration // time from the first write until all data was acknowledged
+	BytesSent int64         // # of bytes transferred
+	Rate      float64       // achieved sending rate in bytes per second
+	Loss      float64       // ratio of retransmitted segments to sent segments in percent
+	LossValid bool          // whether Loss is available
+	MinRTT    time.Duration // minimum round-trip time observed
+	MaxRTT    time.Duration // maximum round-trip time observed under load
+
+	// Bufferbloat is an estimate of queueing delay added by the
+	// path under load, as the increase of the round-trip time
+	// under load over the minimum.
+	Bufferbloat time.Duration
+
+	Samples []*Sample // samples taken during the probe
+}
+
+// Probe dials the target at address, transfers data and returns a
+// report.
+// The transfer completes when all data is acknowledged by the target
+// or, on platforms not providing queue occupancy, when all data is
+// written.
+func (p *Prober) Probe(ctx context.Context, address string) (*ProbeReport, error) {
+	size, d := p.Size, p.Interval
+	if size <= 0 {
+		size = 1 << 20
+	}
+	if d <= 0 {
+		d = 10 * time.Millisecond
+	}
+	var dialer net.Dialer
+	if p.Dialer != nil {
+		dialer = *p.Dialer
+	}
+	c, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+	if dl, ok := ctx.Deadline(); ok {
+		c.SetDeadline(dl)
+	}
+
+	var mu sync.Mutex
+	var samples []*Sample
+	s := NewSamplerWithGetter(p.Getter, c, d, func(_ net.Conn, smp *Sample) {
+		mu.Lock()
+		samples = append(samples, smp)
+		mu.Unlock()
+	})
+	start := time.Now()
+	b := make([]byte, 32<<10)
+	var n int64
+	for n < size {
+		if int64(len(b)) > size-n {
+			b = b[:size-n]
+		}
+		nn, err := c.Write(b)
+		n += int64(nn)
+		if err != nil {
+			s.Stop()
+			return nil, err
+		}
+	}
+	for !p.drained(c) {
+		select {
+		case <-ctx.Done():
+			s.Stop()
+			return nil, ctx.Err()
+		case <-time.After(d):
+		}
+	}
+	end := time.Now()
+	s.Stop()
+
+	mu.Lock()
+	defer mu.Unlock()
+	r := &ProbeReport{Target: address, Duration: end.Sub(start), BytesSent: n, Samples: samples}
+	if r.Duration > 0 {
+		r.Rate = float64(n) / r.Duration.Seconds()
+	}
+	var first, last *Sample
+	for _, smp := range samples {
+		i := smp.Info
+		if i == nil {
+			continue
+		}
+		if first == nil {
+			first = smp
+		}
+		last = smp
+		if i.RTT > 0 && (r.MinRTT == 0 || i.RTT < r.MinRTT) {
+			r.MinRTT = i.RTT
+		}
+		if i.RTT > r.MaxRTT {
+			r.MaxRTT = i.RTT
+		}
+		if ds := i.Stats(); ds.Valid("min_rtt") && ds.MinRTT > 0 && ds.MinRTT < r.MinRTT {
+			r.MinRTT = ds.MinRTT
+		}
+	}
+	if r.MaxRTT > r.MinRTT {
+		r.Bufferbloat = r.MaxRTT - r.MinRTT
+	}
+	if last != nil {
+		ds := last.Info.Stats()
+		if ds.Valid("retrans_segs") && ds.Valid("segs_sent") && ds.SegsSent > 0 {
+			r.Loss = float64(ds.RetransSegs) * 100 / float64(ds.SegsSent)
+			r.LossValid = true
+		}
+	}
+	return r, nil
+}
+
+// drained reports whether all data written on c is acknowledged, or
+// whether it cannot be told.
+func (p *Prober) drained(c net.Conn) bool {
+	if p.Getter != nil {
+		i, err := p.Getter.Get(c)
+		return err != nil || i.Queue == nil || i.Queue.Send == 0
+	}
+	q, err := GetQueue(c)
+	return err != nil || q.Send == 0
+}
@@ -0,0 +1,112 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcpinfo_test
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/mikioh/tcpinfo"
+)
+
+func TestProber(t *testing.T) {
+	switch runtime.GOOS {
+	case "darwin", "freebsd", "linux", "netbsd":
+	default:
+		t.Skipf("%s/%s", runtime.GOOS, runtime.GOARCH)
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		c, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		io.Copy(ioutil.Discard, c)
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	p := &tcpinfo.Prober{Size: 4 << 20, Interval: time.Millisecond}
+	r, err := p.Probe(ctx, ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.BytesSent != 4<<20 || r.Duration <= 0 || r.Rate <= 0 || len(r.Samples) == 0 {
+		t.Fatalf("got %+v", r)
+	}
+	if r.MinRTT <= 0 || r.MaxRTT < r.MinRTT || r.Bufferbloat != r.MaxRTT-r.MinRTT {
+		t.Fatalf("got %v, %v, %v", r.MinRTT, r.MaxRTT, r.Bufferbloat)
+	}
+	if runtime.GOOS == "linux" && !r.LossValid {
+		t.Fatal("got invalid loss; want valid")
+	}
+}
+
+func TestProberCampaign(t *testing.T) {
+	switch runtime.GOOS {
+	case "darwin", "freebsd", "linux", "netbsd":
+	default:
+		t.Skipf("%s/%s", runtime.GOOS, runtime.GOARCH)
+	}
+
+	var targets []string
+	for j := 0; j < 3; j++ {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer ln.Close()
+		go func() {
+			for {
+				c, err := ln.Accept()
+				if err != nil {
+					return
+				}
+				go func() {
+					defer c.Close()
+					io.Copy(ioutil.Discard, c)
+				}()
+			}
+		}()
+		targets = append(targets, ln.Addr().String())
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed := ln.Addr().String()
+	ln.Close()
+	targets = append([]string{closed}, targets...)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	p := &tcpinfo.Prober{Size: 1 << 20, Interval: time.Millisecond}
+	rs := p.Campaign(ctx, targets, 2)
+	if len(rs) != 4 {
+		t.Fatalf("got %d results; want 4", len(rs))
+	}
+	for j, r := range rs[:3] {
+		if r.Err != nil || r.Rank != j+1 || r.Report == nil {
+			t.Fatalf("#%d: got %+v", j, r)
+		}
+		if j > 0 && r.Report.Rate > rs[j-1].Report.Rate {
+			t.Fatalf("#%d: got rate %v above %v", j, r.Report.Rate, rs[j-1].Report.Rate)
+		}
+	}
+	if r := rs[3]; r.Err == nil || r.Rank != 0 || r.Target != closed {
+		t.Fatalf("got %+v", r)
+	}
+}
@@ -0,0 +1,306 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package promtcpinfo implements a Prometheus collector for TCP
+// connection information.
+//
+// Example:
+//
+//	c := promtcpinfo.NewCollector(promtcpinfo.Opts{})
+//	prometheus.MustRegister(c)
+//	ln = &tcpinfo.Listener{Listener: ln, Interval: 5 * time.Second, Func: c.Observe}
+package promtcpinfo
+
+import (
+	"net"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/mikioh/tcpinfo"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var _ prometheus.Collector = &Collector{}
+
+// Opts represents options for a collector.
+type Opts struct {
+	Namespace   string            // namespace of metrics; defaults to "tcpinfo"
+	Subsystem   string            // subsystem of metrics
+	ConstLabels prometheus.Labels // labels attached to all metrics
+
+	// LabelNames and LabelValues specify variable labels.
+	// LabelValues returns the label values for a connection in
+	// the order of LabelNames.
+	// When LabelValues is nil, the values are taken from the labels
+	// of the first sample on a connection by LabelNames, such as
+	// attached by tcpinfo.WithLabels.
+	// When both are nil and Aggregate is false, the local and
+	// remote addresses of connection are used.
+	// When Aggregate is false, connections sharing the same label
+	// values are published as one; their round-trip times are
+	// averaged and the other metrics are summed.
+	LabelNames  []string
+	LabelValues func(c net.Conn) []string
+
+	// Aggregate specifies whether metrics are aggregated across
+	// connections sharing the same label values instead of being
+	// published per connection.
+	Aggregate bool
+}
+
+// A Collector implements prometheus.Collector for connection
+// information samples.
+//
+// Samples are fed by Observe, which can be used as a
+// tcpinfo.SampleFunc.
+type Collector struct {
+	opts Opts
+
+	rtt          *prometheus.Desc
+	cwnd         *prometheus.Desc
+	retrans      *prometheus.Desc
+	deliveryRate *prometheus.Desc
+	conns        *prometheus.Desc
+
+	mu     sync.Mutex
+	latest map[net.Conn]*entry
+	closed map[string]*closedEntry // closed connection totals keyed by label values
+}
+
+type entry struct {
+	lvs  []string
+	info *tcpinfo.Info
+}
+
+type closedEntry struct {
+	lvs     []string
+	retrans uint64
+}
+
+// NewCollector returns a new collector.
+func NewCollector(opts Opts) *Collector {
+	if opts.Namespace == "" {
+		opts.Namespace = "tcpinfo"
+	}
+	if opts.LabelNames == nil && opts.LabelValues == nil && !opts.Aggregate {
+		opts.LabelNames = []string{"local_addr", "remote_addr"}
+		opts.LabelValues = func(c net.Conn) []string {
+			return []string{c.LocalAddr().String(), c.RemoteAddr().String()}
+		}
+	}
+	cwndUnit, retransUnit := "segments", "segments"
+	switch runtime.GOOS {
+	case "darwin":
+		cwndUnit, retransUnit = "bytes", "bytes"
+	case "freebsd":
+		cwndUnit = "bytes"
+	}
+	desc := func(name, help string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(opts.Namespace, opts.Subsystem, name), help, opts.LabelNames, opts.ConstLabels)
+	}
+	c := &Collector{
+		opts:         opts,
+		rtt:          desc("rtt_seconds", "Smoothed round-trip time."),
+		cwnd:         desc("snd_cwnd_"+cwndUnit, "Congestion window for sender."),
+		retrans:      desc("retransmitted_"+retransUnit+"_total", "Total retransmissions."),
+		deliveryRate: desc("delivery_rate_bytes", "Delivery rate in bytes per second."),
+		latest:       make(map[net.Conn]*entry),
+		closed:       make(map[string]*closedEntry),
+	}
+	if opts.Aggregate {
+		c.conns = desc("connections", "Number of connections.")
+	}
+	return c
+}
+
+// Observe records the sample s on the connection c.
+// The connection is forgotten when s is the final sample.
+func (c *Collector) Observe(conn net.Conn, s *tcpinfo.Sample) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e := c.latest[conn]
+	if e == nil {
+		e = &entry{}
+		if c.opts.LabelValues != nil {
+			e.lvs = c.opts.LabelValues(conn)
+		} else if len(c.opts.LabelNames) > 0 {
+			e.lvs = make([]string, len(c.opts.LabelNames))
+			for j, name := range c.opts.LabelNames {
+				e.lvs[j] = s.Label(name)
+			}
+		}
+		c.latest[conn] = e
+	}
+	if s.Info != nil {
+		e.info = s.Info
+	}
+	if !s.Final {
+		return
+	}
+	delete(c.latest, conn)
+	if !c.opts.Aggregate || e.info == nil {
+		return
+	}
+	k := strings.Join(e.lvs, "\x00")
+	ce := c.closed[k]
+	if ce == nil {
+		ce = &closedEntry{lvs: e.lvs}
+		c.closed[k] = ce
+	}
+	ce.retrans += retransmissions(e.info.Stats())
+}
+
+// Describe implements the Describe method of prometheus.Collector
+// interface.
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.rtt
+	ch <- c.cwnd
+	ch <- c.retrans
+	ch <- c.deliveryRate
+	if c.conns != nil {
+		ch <- c.conns
+	}
+}
+
+// Collect implements the Collect method of prometheus.Collector
+// interface.
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.opts.Aggregate {
+		c.collectAggregate(ch)
+		return
+	}
+	ms := make(map[string]*merged)
+	for _, e := range c.latest {
+		if e.info == nil {
+			continue
+		}
+		k := strings.Join(e.lvs, "\x00")
+		m := ms[k]
+		if m == nil {
+			m = &merged{lvs: e.lvs}
+			ms[k] = m
+		}
+		ds := e.info.Stats()
+		m.conns++
+		m.rtt += e.info.RTT.Seconds()
+		m.cwnd += uint64(senderWindow(e.info))
+		m.retrans += retransmissions(ds)
+		m.deliveryRate += ds.DeliveryRate
+	}
+	for _, m := range ms {
+		ch <- prometheus.MustNewConstMetric(c.rtt, prometheus.GaugeValue, m.rtt/float64(m.conns), m.lvs...)
+		ch <- prometheus.MustNewConstMetric(c.cwnd, prometheus.GaugeValue, float64(m.cwnd), m.lvs...)
+		ch <- prometheus.MustNewConstMetric(c.retrans, prometheus.CounterValue, float64(m.retrans), m.lvs...)
+		ch <- prometheus.MustNewConstMetric(c.deliveryRate, prometheus.GaugeValue, float64(m.deliveryRate), m.lvs...)
+	}
+}
+
+// A merged represents the metrics of connections sharing the same
+// label values in per-connection publishing.
+type merged struct {
+	lvs          []string
+	conns        int
+	rtt          float64
+	cwnd         uint64
+	retrans      uint64
+	deliveryRate uint64
+}
+
+var (
+	rttBuckets  = prometheus.ExponentialBuckets(0.0005, 2, 14)
+	cwndBuckets = prometheus.ExponentialBuckets(1, 2, 16)
+)
+
+type aggregate struct {
+	lvs          []string
+	conns        uint64
+	rtt          histogram
+	cwnd         histogram
+	retrans      uint64
+	deliveryRate uint64
+}
+
+func (c *Collector) collectAggregate(ch chan<- prometheus.Metric) {
+	aggs := make(map[string]*aggregate)
+	get := func(lvs []string) *aggregate {
+		k := strings.Join(lvs, "\x00")
+		a := aggs[k]
+		if a == nil {
+			a = &aggregate{lvs: lvs, rtt: newHistogram(rttBuckets), cwnd: newHistogram(cwndBuckets)}
+			aggs[k] = a
+		}
+		return a
+	}
+	for _, ce := range c.closed {
+		get(ce.lvs).retrans += ce.retrans
+	}
+	for _, e := range c.latest {
+		if e.info == nil {
+			continue
+		}
+		a := get(e.lvs)
+		ds := e.info.Stats()
+		a.conns++
+		a.rtt.observe(e.info.RTT.Seconds())
+		a.cwnd.observe(float64(senderWindow(e.info)))
+		a.retrans += retransmissions(ds)
+		a.deliveryRate += ds.DeliveryRate
+	}
+	for _, a := range aggs {
+		ch <- prometheus.MustNewConstMetric(c.conns, prometheus.GaugeValue, float64(a.conns), a.lvs...)
+		ch <- prometheus.MustNewConstHistogram(c.rtt, a.rtt.count, a.rtt.sum, a.rtt.buckets(), a.lvs...)
+		ch <- prometheus.MustNewConstHistogram(c.cwnd, a.cwnd.count, a.cwnd.sum, a.cwnd.buckets(), a.lvs...)
+		ch <- prometheus.MustNewConstMetric(c.retrans, prometheus.CounterValue, float64(a.retrans), a.lvs...)
+		ch <- prometheus.MustNewConstMetric(c.deliveryRate, prometheus.GaugeValue, float64(a.deliveryRate), a.lvs...)
+	}
+}
+
+type histogram struct {
+	bounds []float64
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+func newHistogram(bounds []float64) histogram {
+	return histogram{bounds: bounds, counts: make([]uint64, len(bounds))}
+}
+
+func (h *histogram) observe(v float64) {
+	for i, b := range h.bounds {
+		if v <= b {
+			h.counts[i]++
+		}
+	}
+	h.count++
+	h.sum += v
+}
+
+func (h *histogram) buckets() map[float64]uint64 {
+	m := make(map[float64]uint64, len(h.bounds))
+	for i, b := range h.bounds {
+		m[b] = h.counts[i]
+	}
+	return m
+}
+
+func senderWindow(i *tcpinfo.Info) uint {
+	if i.CongestionControl == nil {
+		return 0
+	}
+	if i.CongestionControl.SenderWindowSegs > 0 {
+		return i.CongestionControl.SenderWindowSegs
+	}
+	return i.CongestionControl.SenderWindowBytes
+}
+
+func retransmissions(ds *tcpinfo.DerivedStats) uint64 {
+	if ds.RetransBytes > 0 {
+		return ds.RetransBytes
+	}
+	return ds.RetransSegs
+}
@@ -0,0 +1,80 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package promtcpinfo_test
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/mikioh/tcpinfo"
+	"github.com/mikioh/tcpinfo/promtcpinfo"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestCollector(t *testing.T) {
+	c1, c2 := net.Pipe()
+	defer c1.Close()
+	defer c2.Close()
+	i := &tcpinfo.Info{RTT: 10 * time.Millisecond, CongestionControl: &tcpinfo.CongestionControl{SenderWindowSegs: 10}}
+
+	for _, tt := range []struct {
+		opts  promtcpinfo.Opts
+		names []string
+	}{
+		{promtcpinfo.Opts{}, []string{"tcpinfo_rtt_seconds", "tcpinfo_delivery_rate_bytes"}},
+		{promtcpinfo.Opts{Aggregate: true}, []string{"tcpinfo_connections", "tcpinfo_rtt_seconds", "tcpinfo_delivery_rate_bytes"}},
+	} {
+		c := promtcpinfo.NewCollector(tt.opts)
+		reg := prometheus.NewPedanticRegistry()
+		if err := reg.Register(c); err != nil {
+			t.Fatal(err)
+		}
+		c.Observe(c1, &tcpinfo.Sample{Time: time.Now(), Info: i})
+		c.Observe(c2, &tcpinfo.Sample{Time: time.Now(), Info: i})
+		c.Observe(c2, &tcpinfo.Sample{Time: time.Now(), Info: i, Final: true})
+		mfs, err := reg.Gather()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := make(map[string]int)
+		for _, mf := range mfs {
+			got[mf.GetName()] = len(mf.GetMetric())
+		}
+		for _, name := range tt.names {
+			if got[name] != 1 {
+				t.Fatalf("got %d metrics for %s; want 1", got[name], name)
+			}
+		}
+	}
+}
+
+func TestCollectorSameLabels(t *testing.T) {
+	c1, c2 := net.Pipe() // both ends have the same addresses
+	defer c1.Close()
+	defer c2.Close()
+
+	c := promtcpinfo.NewCollector(promtcpinfo.Opts{})
+	reg := prometheus.NewPedanticRegistry()
+	if err := reg.Register(c); err != nil {
+		t.Fatal(err)
+	}
+	c.Observe(c1, &tcpinfo.Sample{Time: time.Now(), Info: &tcpinfo.Info{RTT: 10 * time.Millisecond, CongestionControl: &tcpinfo.CongestionControl{SenderWindowSegs: 10}}})
+	c.Observe(c2, &tcpinfo.Sample{Time: time.Now(), Info: &tcpinfo.Info{RTT: 30 * time.Millisecond, CongestionControl: &tcpinfo.CongestionControl{SenderWindowSegs: 20}}})
+	mfs, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]float64)
+	for _, mf := range mfs {
+		if len(mf.GetMetric()) != 1 {
+			t.Fatalf("got %d metrics for %s; want 1", len(mf.GetMetric()), mf.GetName())
+		}
+		got[mf.GetName()] = mf.GetMetric()[0].GetGauge().GetValue()
+	}
+	if got["tcpinfo_rtt_seconds"] != 0.02 || got["tcpinfo_snd_cwnd_segments"] != 30 {
+		t.Fatalf("got %v; want an averaged rtt and a summed cwnd", got)
+	}
+}
@@ -0,0 +1,29 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcpinfo
+
+import "net"
+
+// A Queue represents queue occupancy of connection.
+//
+// It helps interpreting the timers since the last data sent or
+// received; for example, a long idle time with a non-empty send
+// queue means the connection is stalled rather than idle.
+type Queue struct {
+	Receive uint `json:"rcv_queue"` // unread data in receive queue in bytes
+	Send    uint `json:"snd_queue"` // unsent or unacknowledged data in send queue in bytes
+}
+
+// GetQueue returns queue occupancy of c.
+//
+// The connection must implement syscall.Conn.
+// Only supported on Darwin, FreeBSD, Linux and NetBSD.
+func GetQueue(c net.Conn) (*Queue, error) {
+	q := new(Queue)
+	if err := control(c, func(s uintptr) error { return getQueue(s, q) }); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
@@ -0,0 +1,119 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcpinfo_test
+
+import (
+	"net"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/mikioh/tcpinfo"
+)
+
+func TestGetQueue(t *testing.T) {
+	switch runtime.GOOS {
+	case "darwin", "freebsd", "linux", "netbsd":
+	default:
+		t.Skipf("not supported on %s/%s", runtime.GOOS, runtime.GOARCH)
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	c, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	ac, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ac.Close()
+
+	if _, err := c.Write(make([]byte, 100)); err != nil {
+		t.Fatal(err)
+	}
+	var q *tcpinfo.Queue
+	for i := 0; i < 100; i++ {
+		q, err = tcpinfo.GetQueue(ac)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if q.Receive == 100 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if q.Receive != 100 {
+		t.Fatalf("got %d; want 100", q.Receive)
+	}
+	i, err := tcpinfo.Get(ac)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if i.Queue == nil || *i.Queue != *q {
+		t.Fatalf("got %+v; want %+v", i.Queue, q)
+	}
+	iq := i.Queue
+	if err := tcpinfo.GetInto(ac, i); err != nil {
+		t.Fatal(err)
+	}
+	if i.Queue != iq {
+		t.Fatal("got a new queue; want the one reused")
+	}
+}
+
+func BenchmarkGet(b *testing.B) {
+	c, ac := benchmarkConns(b)
+	defer c.Close()
+	defer ac.Close()
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := tcpinfo.Get(c); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkGetInto(b *testing.B) {
+	c, ac := benchmarkConns(b)
+	defer c.Close()
+	defer ac.Close()
+
+	var ti tcpinfo.Info
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := tcpinfo.GetInto(c, &ti); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func benchmarkConns(b *testing.B) (net.Conn, net.Conn) {
+	switch runtime.GOOS {
+	case "darwin", "freebsd", "linux", "netbsd":
+	default:
+		b.Skipf("not supported on %s/%s", runtime.GOOS, runtime.GOARCH)
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer ln.Close()
+	c, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		b.Fatal(err)
+	}
+	ac, err := ln.Accept()
+	if err != nil {
+		c.Close()
+		b.Fatal(err)
+	}
+	return c, ac
+}
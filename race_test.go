@@ -0,0 +1,91 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcpinfo_test
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mikioh/tcpinfo"
+	"github.com/mikioh/tcpinfo/tcpinfotest"
+)
+
+// The tests in this file exercise the sharing of samples among
+// goroutines and are meant to be run with the race detector.
+
+func TestMonitorConcurrentLatest(t *testing.T) {
+	g := tcpinfotest.NewGetter()
+	var cs []net.Conn
+	for i := 0; i < 8; i++ {
+		c := tcpinfotest.NewConn("127.0.0.1:1", fmt.Sprintf("127.0.0.1:%d", 1000+i))
+		g.Set(c, tcpinfotest.NewInfo().RTT(time.Millisecond, 0).Build())
+		cs = append(cs, c)
+	}
+	l := tcpinfo.LabelerFunc(func(net.Conn, *tcpinfo.Sample) []tcpinfo.Label {
+		return []tcpinfo.Label{{Name: "pod", Value: "web-0"}}
+	})
+	h := tcpinfo.NewHistory(16)
+	m := tcpinfo.NewMonitorWithGetter(g, time.Millisecond, tcpinfo.WithLabels(l, func(_ net.Conn, s *tcpinfo.Sample) {
+		h.Add(s)
+	}))
+	for _, c := range cs {
+		m.Add(c)
+	}
+
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				for _, c := range cs {
+					if s := m.Latest(c); s != nil && s.Info != nil {
+						s.Info.RTT = 0
+						s.Info.CongestionControl.SenderWindowSegs = 0
+						s.Labels[0].Value = ""
+					}
+				}
+				for _, s := range h.Samples() {
+					if s.Info != nil && (s.Info.RTT != time.Millisecond || s.Label("pod") != "web-0") {
+						t.Errorf("got %+v; want unmodified sample", s)
+						return
+					}
+				}
+			}
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(stop)
+	wg.Wait()
+	m.Close()
+	if h.Len() == 0 {
+		t.Fatal("got no samples")
+	}
+}
+
+func TestSampleClone(t *testing.T) {
+	s := &tcpinfo.Sample{
+		Info:   tcpinfotest.NewInfo().Queue(1, 2).Build(),
+		Route:  &tcpinfo.Route{Interface: "eth0"},
+		Labels: []tcpinfo.Label{{Name: "pod", Value: "web-0"}},
+	}
+	ns := s.Clone()
+	ns.Info.Queue.Receive = 0
+	ns.Info.CongestionControl.SenderWindowSegs = 0
+	ns.Route.Interface = ""
+	ns.Labels[0].Value = ""
+	if s.Info.Queue.Receive != 1 || s.Info.CongestionControl.SenderWindowSegs != 10 || s.Route.Interface != "eth0" || s.Labels[0].Value != "web-0" {
+		t.Fatalf("got %+v; want unmodified original", s)
+	}
+}
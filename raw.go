@@ -0,0 +1,287 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcpinfo
+
+import (
+	"encoding/binary"
+	"errors"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	linuxStates = [12]State{Unknown, Established, SynSent, SynReceived, FinWait1, FinWait2, TimeWait, Closed, CloseWait, LastAck, Listen, Closing}
+	bsdStates   = [11]State{Closed, Listen, SynSent, SynReceived, Established, CloseWait, FinWait1, Closing, LastAck, FinWait2, TimeWait}
+)
+
+// A RawMeta represents the annotation of a structure of connection
+// information returned by the kernel, which is required to parse the
+// structure later.
+type RawMeta struct {
+	GOOS   string `json:"goos"`             // operating system, such as "linux"
+	GOARCH string `json:"goarch"`           // architecture, which determines byte order
+	Kernel string `json:"kernel,omitempty"` // release of the kernel, such as "4.19.0-8-amd64"
+	Kind   string `json:"kind"`             // kind of information, such as "tcp_info"
+	Size   int    `json:"size"`             // length of the structure in bytes
+}
+
+// retainRaw retains a copy of b and its annotation in i when FieldRaw
+// is in m.
+func retainRaw(b []byte, i *Info, m FieldMask) {
+	if m&FieldRaw == 0 {
+		return
+	}
+	i.Raw = append(i.Raw[:0], b...)
+	if i.RawMeta == nil {
+		i.RawMeta = new(RawMeta)
+	}
+	*i.RawMeta = RawMeta{GOOS: runtime.GOOS, GOARCH: runtime.GOARCH, Kernel: kernelRelease(), Kind: soKinds[soInfo], Size: len(b)}
+}
+
+var kernel struct {
+	once    sync.Once
+	release string
+}
+
+// kernelRelease returns the release of the running kernel, or an
+// empty string when unknown.
+func kernelRelease() string {
+	kernel.once.Do(func() { kernel.release = sysKernelRelease() })
+	return kernel.release
+}
+
+// ParseRaw parses b as connection information returned by the kernel
+// of the operating system goos, such as "linux" or "darwin".
+// The kernel is the release of the kernel, such as "4.9" or
+// "4.19.0-8-amd64", and may be empty when unknown.
+//
+// ParseRaw is intended for decoding samples collected on machines
+// other than the one running it.
+// When goos is the running operating system, b is parsed as
+// ParseInto does, including platform-specific information.
+// Otherwise b must be in little-endian byte order, only the fields
+// common to all platforms are filled in, and the Sys and
+// KernelStructSize fields are left empty.
+//
+// On Linux, a buffer shorter than the structure that the release
+// of the kernel is known to return is rejected.
+func ParseRaw(goos, kernel string, b []byte) (*Info, error) {
+	return parseRaw(goos, kernel, b, goos == runtime.GOOS)
+}
+
+// bigEndianArchs holds the architectures in big-endian byte order.
+var bigEndianArchs = map[string]bool{
+	"mips": true, "mips64": true, "ppc64": true, "s390x": true, "sparc64": true,
+}
+
+// ReparseRaw parses raw as connection information annotated by meta,
+// such as the Raw and RawMeta fields of Info retained and archived
+// earlier, with the field definitions of the running package.
+//
+// The structure is parsed as ParseInto does when it was returned by
+// the kernel of the same operating system and architecture as the
+// running one, and as ParseRaw does otherwise.
+// The Raw and RawMeta fields of the returned information hold copies
+// of raw and meta.
+// It returns an error when meta is nil.
+func ReparseRaw(meta *RawMeta, raw []byte) (*Info, error) {
+	if meta == nil {
+		return nil, errors.New("missing raw metadata")
+	}
+	if meta.Size > 0 {
+		if len(raw) < meta.Size {
+			return nil, &Error{Op: "parse", Kind: meta.Kind, Platform: meta.GOOS + "/" + meta.GOARCH, Want: meta.Size, Got: len(raw), Err: ErrBufferTooShort}
+		}
+		raw = raw[:meta.Size]
+	}
+	native := meta.GOOS == runtime.GOOS && meta.GOARCH == runtime.GOARCH
+	if !native && bigEndianArchs[meta.GOARCH] {
+		return nil, &Error{Op: "parse", Kind: meta.Kind, Platform: meta.GOOS + "/" + meta.GOARCH, Err: ErrNotSupported}
+	}
+	i, err := parseRaw(meta.GOOS, meta.Kernel, raw, native)
+	if err != nil {
+		return nil, err
+	}
+	i.Raw = append([]byte(nil), raw...)
+	m := *meta
+	i.RawMeta = &m
+	return i, nil
+}
+
+func parseRaw(goos, kernel string, b []byte, native bool) (*Info, error) {
+	p, ok := rawParsers[goos]
+	if !ok {
+		return nil, &Error{Op: "parse", Kind: "tcp_info", Platform: goos, Err: ErrNotSupported}
+	}
+	want := p.size
+	if goos == "linux" {
+		if n := linuxInfoLen(kernel); n > want {
+			want = n
+		}
+	}
+	if len(b) < want {
+		return nil, &Error{Op: "parse", Kind: p.kind, Platform: goos, Want: want, Got: len(b), Err: ErrBufferTooShort}
+	}
+	i := new(Info)
+	if native {
+		if err := parseInfoInto(b, i, FieldAll, ParseDefault); err != nil {
+			return nil, err
+		}
+		return i, nil
+	}
+	i.recycle(FieldOptions | FieldFlowControl | FieldCongestionControl)
+	p.decode(b, i)
+	return i, nil
+}
+
+// A rawParser represents a portable parser of connection information
+// returned by the kernel of an operating system.
+type rawParser struct {
+	kind   string // kind of information
+	size   int    // minimum length of structure in bytes
+	decode func(b []byte, i *Info)
+}
+
+var rawParsers = map[string]rawParser{
+	"darwin":  {"tcp_connection_info", 0x68, decodeRawDarwin},
+	"freebsd": {"tcp_info", 0xec, decodeRawFreeBSD},
+	"linux":   {"tcp_info", 0x68, decodeRawLinux},
+	"netbsd":  {"tcp_info", 0xec, decodeRawNetBSD},
+}
+
+// linuxInfoLens holds the lengths of struct tcp_info returned by the
+// Linux kernel, in descending order of the release that introduced
+// them.
+var linuxInfoLens = []struct {
+	major, minor int
+	n            int
+}{
+	{4, 9, 0xa8},
+	{4, 6, 0xa0},
+	{4, 2, 0x90},
+	{4, 1, 0x88},
+	{3, 15, 0x78},
+}
+
+// linuxInfoLen returns the length of struct tcp_info returned by the
+// Linux kernel of the release, or zero when the release is unknown.
+func linuxInfoLen(kernel string) int {
+	if j := strings.IndexFunc(kernel, func(r rune) bool { return r != '.' && (r < '0' || r > '9') }); j >= 0 {
+		kernel = kernel[:j]
+	}
+	vs := strings.SplitN(kernel, ".", 3)
+	if len(vs) < 2 {
+		return 0
+	}
+	major, err := strconv.Atoi(vs[0])
+	if err != nil {
+		return 0
+	}
+	minor, err := strconv.Atoi(vs[1])
+	if err != nil {
+		return 0
+	}
+	for _, l := range linuxInfoLens {
+		if major > l.major || major == l.major && minor >= l.minor {
+			return l.n
+		}
+	}
+	return 0x68
+}
+
+func decodeRawLinux(b []byte, i *Info) {
+	u32 := func(off int) uint32 { return binary.LittleEndian.Uint32(b[off:]) }
+	if int(b[0]) < len(linuxStates) {
+		i.State = linuxStates[b[0]]
+	}
+	appendRawOptions(i, uint32(b[5]), b[6]>>4, b[6]&0x0f)
+	if b[5]&0x20 != 0 {
+		i.Options = append(i.Options, FastOpen(true))
+		i.PeerOptions = append(i.PeerOptions, FastOpen(true))
+	}
+	i.SenderMSS = MaxSegSize(u32(16))
+	i.ReceiverMSS = MaxSegSize(u32(20))
+	i.RTT = time.Duration(u32(68)) * time.Microsecond
+	i.RTTVar = time.Duration(u32(72)) * time.Microsecond
+	i.RTO = time.Duration(u32(8)) * time.Microsecond
+	i.ATO = time.Duration(u32(12)) * time.Microsecond
+	i.LastDataSent = time.Duration(u32(44)) * time.Millisecond
+	i.LastDataReceived = time.Duration(u32(52)) * time.Millisecond
+	i.LastAckReceived = time.Duration(u32(56)) * time.Millisecond
+	i.FlowControl.ReceiverWindow = uint(u32(96))
+	i.CongestionControl.SenderSSThreshold = uint(u32(76))
+	i.CongestionControl.ReceiverSSThreshold = uint(u32(64))
+	i.CongestionControl.SenderWindowSegs = uint(u32(80))
+}
+
+func decodeRawBSD(b []byte, i *Info) {
+	u32 := func(off int) uint32 { return binary.LittleEndian.Uint32(b[off:]) }
+	if int(b[0]) < len(bsdStates) {
+		i.State = bsdStates[b[0]]
+	}
+	appendRawOptions(i, uint32(b[5]), b[6]>>4, b[6]&0x0f)
+	i.SenderMSS = MaxSegSize(u32(16))
+	i.ReceiverMSS = MaxSegSize(u32(20))
+	i.RTT = time.Duration(u32(68)) * time.Microsecond
+	i.RTTVar = time.Duration(u32(72)) * time.Microsecond
+	i.RTO = time.Duration(u32(8)) * time.Microsecond
+	i.ATO = time.Duration(u32(12)) * time.Microsecond
+	i.LastDataSent = time.Duration(u32(44)) * time.Microsecond
+	i.LastDataReceived = time.Duration(u32(52)) * time.Microsecond
+	i.LastAckReceived = time.Duration(u32(56)) * time.Microsecond
+	i.FlowControl.ReceiverWindow = uint(u32(96))
+	i.CongestionControl.SenderSSThreshold = uint(u32(76))
+	i.CongestionControl.ReceiverSSThreshold = uint(u32(64))
+}
+
+func decodeRawFreeBSD(b []byte, i *Info) {
+	decodeRawBSD(b, i)
+	i.CongestionControl.SenderWindowBytes = uint(binary.LittleEndian.Uint32(b[80:]))
+}
+
+func decodeRawNetBSD(b []byte, i *Info) {
+	decodeRawBSD(b, i)
+	i.CongestionControl.SenderWindowSegs = uint(binary.LittleEndian.Uint32(b[80:]))
+}
+
+func decodeRawDarwin(b []byte, i *Info) {
+	u32 := func(off int) uint32 { return binary.LittleEndian.Uint32(b[off:]) }
+	if int(b[0]) < len(bsdStates) {
+		i.State = bsdStates[b[0]]
+	}
+	appendRawOptions(i, u32(4), b[1], b[2])
+	i.SenderMSS = MaxSegSize(u32(16))
+	i.ReceiverMSS = MaxSegSize(u32(16))
+	i.RTT = time.Duration(u32(40)) * time.Millisecond
+	i.RTTVar = time.Duration(u32(48)) * time.Millisecond
+	i.RTO = time.Duration(u32(12)) * time.Millisecond
+	i.FlowControl.ReceiverWindow = uint(u32(36))
+	i.CongestionControl.SenderSSThreshold = uint(u32(20))
+	i.CongestionControl.SenderWindowBytes = uint(u32(24))
+}
+
+// appendRawOptions appends the options in opts, which are encoded in
+// the same way on all platforms, to i.
+func appendRawOptions(i *Info, opts uint32, sndWScale, rcvWScale byte) {
+	if opts&0x4 != 0 {
+		i.Options = append(i.Options, WindowScale(sndWScale))
+		i.PeerOptions = append(i.PeerOptions, WindowScale(rcvWScale))
+	}
+	if opts&0x2 != 0 {
+		i.Options = append(i.Options, SACKPermitted(true))
+		i.PeerOptions = append(i.PeerOptions, SACKPermitted(true))
+	}
+	if opts&0x1 != 0 {
+		i.Options = append(i.Options, Timestamps(true))
+		i.PeerOptions = append(i.PeerOptions, Timestamps(true))
+	}
+	if opts&0x8 != 0 {
+		i.Options = append(i.Options, ECN(true))
+		i.PeerOptions = append(i.PeerOptions, ECN(true))
+	}
+}
@@ -0,0 +1,154 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcpinfo_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/mikioh/tcpinfo"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+func TestParseRaw(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "raw", "*.bin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatal("no raw buffers")
+	}
+	for _, file := range files {
+		name := strings.TrimSuffix(filepath.Base(file), ".bin")
+		vs := strings.SplitN(name, "_", 2)
+		b, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		i, err := tcpinfo.ParseRaw(vs[0], vs[1], b)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		i.Sys, i.KernelStructSize = nil, 0
+		got, err := json.MarshalIndent(i, "", "\t")
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		golden := strings.TrimSuffix(file, ".bin") + ".json"
+		if *update {
+			if err := ioutil.WriteFile(golden, append(got, '\n'), 0644); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		want, err := ioutil.ReadFile(golden)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(append(got, '\n'), want) {
+			t.Fatalf("%s: got %s; want %s", name, got, want)
+		}
+	}
+}
+
+func TestParseRawErrors(t *testing.T) {
+	for _, tt := range []struct {
+		goos, kernel string
+		n            int
+		err          error
+	}{
+		{"plan9", "", 0x100, tcpinfo.ErrNotSupported},
+		{"linux", "", 0x67, tcpinfo.ErrBufferTooShort},
+		{"linux", "4.9.0-8-amd64", 0xa0, tcpinfo.ErrBufferTooShort},
+		{"darwin", "17.7.0", 0x60, tcpinfo.ErrBufferTooShort},
+		{"freebsd", "11.2", 0x68, tcpinfo.ErrBufferTooShort},
+	} {
+		if _, err := tcpinfo.ParseRaw(tt.goos, tt.kernel, make([]byte, tt.n)); !errors.Is(err, tt.err) {
+			t.Fatalf("%s %s: got %v; want %v", tt.goos, tt.kernel, err, tt.err)
+		}
+	}
+	for _, kernel := range []string{"", "unknown", "3.10.0-957.el7.x86_64", "4.6"} {
+		if _, err := tcpinfo.ParseRaw("linux", kernel, make([]byte, 0xa0)); err != nil {
+			t.Fatalf("%q: %v", kernel, err)
+		}
+	}
+}
+
+func TestRetainRaw(t *testing.T) {
+	switch runtime.GOOS {
+	case "darwin", "freebsd", "linux", "netbsd":
+	default:
+		t.Skipf("%s/%s", runtime.GOOS, runtime.GOARCH)
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	c, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	var i tcpinfo.Info
+	if err := tcpinfo.GetFields(c, &i, tcpinfo.FieldAll|tcpinfo.FieldRaw); err != nil {
+		t.Fatal(err)
+	}
+	if len(i.Raw) == 0 || i.RawMeta == nil || i.RawMeta.Size != len(i.Raw) || i.RawMeta.GOOS != runtime.GOOS || i.RawMeta.GOARCH != runtime.GOARCH {
+		t.Fatalf("got %d bytes, %+v", len(i.Raw), i.RawMeta)
+	}
+	ni, err := tcpinfo.ParseRaw(i.RawMeta.GOOS, i.RawMeta.Kernel, i.Raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ni.State != i.State || ni.RTT != i.RTT {
+		t.Fatalf("got %v, %v; want %v, %v", ni.State, ni.RTT, i.State, i.RTT)
+	}
+	if err := tcpinfo.GetInto(c, &i); err != nil {
+		t.Fatal(err)
+	}
+	if i.Raw != nil || i.RawMeta != nil {
+		t.Fatalf("got %d bytes, %+v; want none", len(i.Raw), i.RawMeta)
+	}
+}
+
+func TestReparseRaw(t *testing.T) {
+	b, err := ioutil.ReadFile(filepath.Join("testdata", "raw", "linux_6.18.44.bin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := tcpinfo.ParseRaw("linux", "6.18.44", b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	meta := &tcpinfo.RawMeta{GOOS: "linux", GOARCH: "amd64", Kernel: "6.18.44", Kind: "tcp_info", Size: len(b)}
+	i, err := tcpinfo.ReparseRaw(meta, append(b, 0xff))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if i.State != want.State || i.RTT != want.RTT || !bytes.Equal(i.Raw, b) || *i.RawMeta != *meta {
+		t.Fatalf("got %+v; want %+v", i, want)
+	}
+	if _, err := tcpinfo.ReparseRaw(meta, b[:len(b)-1]); !errors.Is(err, tcpinfo.ErrBufferTooShort) {
+		t.Fatalf("got %v; want %v", err, tcpinfo.ErrBufferTooShort)
+	}
+	meta.GOARCH = "s390x"
+	if _, err := tcpinfo.ReparseRaw(meta, b); !errors.Is(err, tcpinfo.ErrNotSupported) {
+		t.Fatalf("got %v; want %v", err, tcpinfo.ErrNotSupported)
+	}
+	if _, err := tcpinfo.ReparseRaw(nil, b); err == nil {
+		t.Fatal("got nil; want an error for missing metadata")
+	}
+}
@@ -0,0 +1,63 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcpinfo
+
+// A Reconciliation represents byte accounting on a connection
+// reconciled between the application and the kernel.
+type Reconciliation struct {
+	Written        uint64  `json:"written"`          // # of bytes written by the application
+	Read           uint64  `json:"read"`             // # of bytes read by the application
+	KernelSent     uint64  `json:"kernel_sent"`      // # of bytes the kernel took for sending, including those waiting in the send queue
+	KernelReceived uint64  `json:"kernel_rcvd"`      // # of bytes the kernel delivered for reading, excluding those waiting in the receive queue
+	SendOverhead   float64 `json:"snd_overhead"`     // ratio of bytes sent beyond those written to those written, such as of TLS framing
+	RecvOverhead   float64 `json:"rcv_overhead"`     // ratio of bytes received beyond those read to those read
+	RetransBytes   uint64  `json:"retrans_bytes"`    // # of bytes retransmitted; estimated from the # of segments and the maximum segment size when the platform counts segments only
+	RetransTax     float64 `json:"retrans_tax"`      // ratio of bytes retransmitted to bytes sent
+	Estimated      bool    `json:"retrans_estimate"` // whether RetransBytes is estimated
+}
+
+// Reconcile compares the # of bytes written and read by the
+// application on the connection, as counted by the caller, with the
+// byte counters of the kernel in i, to quantify the overhead of
+// protocols layered on the connection, such as TLS, and the tax of
+// retransmission.
+//
+// Bytes written through such protocols are counted before encoding,
+// and bytes read after decoding.
+// Queue, when filled in, accounts for bytes in the socket buffers.
+// It returns nil when the platform does not report the # of bytes
+// sent and received.
+func Reconcile(i *Info, written, read uint64) *Reconciliation {
+	ds := i.Stats()
+	if !ds.Valid("bytes_sent") || !ds.Valid("bytes_rcvd") {
+		return nil
+	}
+	r := &Reconciliation{Written: written, Read: read, KernelSent: ds.BytesSent, KernelReceived: ds.BytesReceived}
+	if i.Queue != nil {
+		r.KernelSent += uint64(i.Queue.Send)
+		if n := uint64(i.Queue.Receive); n < r.KernelReceived {
+			r.KernelReceived -= n
+		} else {
+			r.KernelReceived = 0
+		}
+	}
+	if written > 0 {
+		r.SendOverhead = (float64(r.KernelSent) - float64(written)) / float64(written)
+	}
+	if read > 0 {
+		r.RecvOverhead = (float64(r.KernelReceived) - float64(read)) / float64(read)
+	}
+	switch {
+	case ds.Valid("retrans_bytes"):
+		r.RetransBytes = ds.RetransBytes
+	case ds.Valid("retrans_segs"):
+		r.RetransBytes = ds.RetransSegs * uint64(i.SenderMSS)
+		r.Estimated = true
+	}
+	if r.KernelSent > 0 {
+		r.RetransTax = float64(r.RetransBytes) / float64(r.KernelSent)
+	}
+	return r
+}
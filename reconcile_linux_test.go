@@ -0,0 +1,31 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcpinfo_test
+
+import (
+	"testing"
+
+	"github.com/mikioh/tcpinfo"
+	"github.com/mikioh/tcpinfo/tcpinfotest"
+)
+
+func TestReconcile(t *testing.T) {
+	i := tcpinfotest.NewInfo().MSS(1000, 1000).Queue(1000, 4000).Sys(func(si *tcpinfo.SysInfo) {
+		si.ThruBytesAcked, si.ThruBytesReceived, si.TotalRetransSegs = 106000, 53000, 11
+	}).Build()
+	r := tcpinfo.Reconcile(i, 100000, 50000)
+	if r == nil {
+		t.Fatal("got nil")
+	}
+	if r.KernelSent != 110000 || r.KernelReceived != 52000 || r.RetransBytes != 11000 || !r.Estimated {
+		t.Fatalf("got %+v", r)
+	}
+	if r.SendOverhead != 0.1 || r.RecvOverhead != 0.04 || r.RetransTax != 0.1 {
+		t.Fatalf("got %+v", r)
+	}
+	if r := tcpinfo.Reconcile(&tcpinfo.Info{}, 1, 1); r != nil {
+		t.Fatalf("got %+v; want nil", r)
+	}
+}
@@ -0,0 +1,132 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcpinfo
+
+import (
+	"errors"
+	"sync"
+)
+
+// KindPrivate is the first option kind available to RegisterOption.
+// Kinds from KindPrivate on are never defined by this package.
+const KindPrivate OptionKind = 0x1000
+
+// An OptionSpec represents a binding for a socket option of a kind
+// not defined by this package, such as a vendor-specific extension.
+type OptionSpec struct {
+	Kind     OptionKind // option kind, equal or greater than KindPrivate
+	KindName string     // name of kind, used as the key of option in encodings
+	Level    int        // option level
+	Name     int        // option name
+	Size     int        // maximum length of option value in bytes, up to MaxOptionSize; zero means 4
+
+	// Parse parses the option value b retrieved from the socket.
+	// The memory of b is reused after Parse returns and must not be
+	// retained.
+	Parse func(b []byte) (Option, error)
+}
+
+// MaxOptionSize is the maximum length of option value retrieved for
+// a registered option.
+const MaxOptionSize = 256
+
+// optionBufs holds buffers for retrieving registered options.
+var optionBufs = sync.Pool{
+	New: func() interface{} { return new([MaxOptionSize]byte) },
+}
+
+var privateOptions struct {
+	sync.RWMutex
+	specs []OptionSpec
+	kinds map[OptionKind]string
+}
+
+// RegisterOption registers the socket option specified by spec.
+//
+// The option is retrieved with connection information by Get and
+// its variants, and appended to the Options field when available on
+// the socket.
+// It is typically called from init functions.
+func RegisterOption(spec OptionSpec) error {
+	if spec.Kind < KindPrivate {
+		return errors.New("option kind out of private range")
+	}
+	if spec.KindName == "" || spec.Parse == nil {
+		return errors.New("missing kind name or parser")
+	}
+	if spec.Size > MaxOptionSize {
+		return errors.New("option size out of range")
+	}
+	if spec.Size <= 0 {
+		spec.Size = 4
+	}
+	if _, ok := optionKindAliases[spec.KindName]; ok {
+		return errors.New("option kind name " + spec.KindName + " already in use")
+	}
+	for _, name := range optionKinds {
+		if name == spec.KindName {
+			return errors.New("option kind name " + spec.KindName + " already in use")
+		}
+	}
+	privateOptions.Lock()
+	defer privateOptions.Unlock()
+	if _, ok := privateOptions.kinds[spec.Kind]; ok {
+		return errors.New("option kind " + spec.KindName + " already registered")
+	}
+	for _, name := range privateOptions.kinds {
+		if name == spec.KindName {
+			return errors.New("option kind name " + spec.KindName + " already in use")
+		}
+	}
+	if privateOptions.kinds == nil {
+		privateOptions.kinds = make(map[OptionKind]string)
+	}
+	privateOptions.kinds[spec.Kind] = spec.KindName
+	privateOptions.specs = append(privateOptions.specs, spec)
+	return nil
+}
+
+// privateKindName returns the name of the registered kind k.
+func privateKindName(k OptionKind) (string, bool) {
+	privateOptions.RLock()
+	defer privateOptions.RUnlock()
+	s, ok := privateOptions.kinds[k]
+	return s, ok
+}
+
+// privateKind returns the registered kind named s.
+func privateKind(s string) (OptionKind, bool) {
+	privateOptions.RLock()
+	defer privateOptions.RUnlock()
+	for k, name := range privateOptions.kinds {
+		if name == s {
+			return k, true
+		}
+	}
+	return 0, false
+}
+
+// appendPrivateOptions appends the registered options available on s
+// to opts.
+func appendPrivateOptions(opts []Option, s uintptr) []Option {
+	privateOptions.RLock()
+	specs := privateOptions.specs
+	privateOptions.RUnlock()
+	if len(specs) == 0 {
+		return opts
+	}
+	b := optionBufs.Get().(*[MaxOptionSize]byte)
+	defer optionBufs.Put(b)
+	for _, spec := range specs {
+		n, err := getsockopt(s, spec.Level, spec.Name, b[:spec.Size])
+		if err != nil {
+			continue
+		}
+		if opt, err := spec.Parse(b[:n]); err == nil && opt != nil {
+			opts = append(opts, opt)
+		}
+	}
+	return opts
+}
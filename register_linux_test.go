@@ -0,0 +1,90 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcpinfo_test
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"net"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/mikioh/tcpinfo"
+)
+
+type rcvBuf int
+
+const kindRcvBuf = tcpinfo.KindPrivate + 1
+
+func (rcvBuf) Kind() tcpinfo.OptionKind { return kindRcvBuf }
+
+func TestRegisterOption(t *testing.T) {
+	spec := tcpinfo.OptionSpec{
+		Kind:     kindRcvBuf,
+		KindName: "rcvbuf",
+		Level:    syscall.SOL_SOCKET,
+		Name:     syscall.SO_RCVBUF,
+		Parse: func(b []byte) (tcpinfo.Option, error) {
+			return rcvBuf(binary.LittleEndian.Uint32(b)), nil
+		},
+	}
+	if err := tcpinfo.RegisterOption(spec); err != nil {
+		t.Fatal(err)
+	}
+	if err := tcpinfo.RegisterOption(spec); err == nil {
+		t.Fatal("got nil; want an error for duplicate kind")
+	}
+	spec.Kind = tcpinfo.KindECN
+	if err := tcpinfo.RegisterOption(spec); err == nil {
+		t.Fatal("got nil; want an error for kind out of range")
+	}
+	for _, name := range []string{"rcvbuf", "mss", "timestamps"} {
+		dup := spec
+		dup.Kind, dup.KindName = kindRcvBuf+1, name
+		if err := tcpinfo.RegisterOption(dup); err == nil {
+			t.Fatalf("got nil; want an error for duplicate name %s", name)
+		}
+	}
+	large := spec
+	large.Kind, large.KindName, large.Size = kindRcvBuf+1, "large", tcpinfo.MaxOptionSize+1
+	if err := tcpinfo.RegisterOption(large); err == nil {
+		t.Fatal("got nil; want an error for size out of range")
+	}
+	if s := kindRcvBuf.String(); s != "rcvbuf" {
+		t.Fatalf("got %q; want rcvbuf", s)
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	c, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	i, err := tcpinfo.Get(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var opt tcpinfo.Option
+	for _, o := range i.Options {
+		if o.Kind() == kindRcvBuf {
+			opt = o
+		}
+	}
+	if opt == nil || opt.(rcvBuf) <= 0 {
+		t.Fatalf("got %v; want positive rcvbuf", i.Options)
+	}
+	b, err := json.Marshal(i)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), `"rcvbuf":`) {
+		t.Fatalf("got %s; want rcvbuf option", b)
+	}
+}
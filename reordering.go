@@ -0,0 +1,37 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcpinfo
+
+// Reordering returns the current reordering estimate of the kernel,
+// the # of segments by which segments may be reordered on the path
+// before they are considered lost.
+// The estimate starts at net.ipv4.tcp_reordering, 3 by default, and
+// grows when the kernel detects reordering, such as on paths spread
+// over multiple links by ECMP.
+// The second return value reports whether the estimate is available.
+//
+// Only supported on Linux.
+func (i *Info) Reordering() (uint, bool) { return reordering(i) }
+
+// MaxReordering returns the maximum reordering estimate of the
+// samples.
+// The second return value reports whether any sample carries the
+// estimate.
+func (h *History) MaxReordering() (uint, bool) {
+	var max uint
+	var ok bool
+	for _, s := range h.Samples() {
+		if s.Info == nil {
+			continue
+		}
+		if n, valid := s.Info.Reordering(); valid {
+			if !ok || n > max {
+				max = n
+			}
+			ok = true
+		}
+	}
+	return max, ok
+}
@@ -0,0 +1,32 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcpinfo_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mikioh/tcpinfo"
+	"github.com/mikioh/tcpinfo/tcpinfotest"
+)
+
+func TestMaxReordering(t *testing.T) {
+	h := tcpinfo.NewHistory(0)
+	if n, ok := h.MaxReordering(); ok {
+		t.Fatalf("got %v, %v; want none", n, ok)
+	}
+	h.Add(&tcpinfo.Sample{Time: time.Unix(0, 0), Err: tcpinfo.ErrNotSupported})
+	for j, n := range []uint{3, 12, 5} {
+		h.Add(&tcpinfo.Sample{Time: time.Unix(int64(j+1), 0), Info: tcpinfotest.NewInfo().Sys(func(si *tcpinfo.SysInfo) { si.ReorderedSegs = n }).Build()})
+	}
+	if n, ok := h.MaxReordering(); !ok || n != 12 {
+		t.Fatalf("got %v, %v; want 12, true", n, ok)
+	}
+
+	i := &tcpinfo.Info{KernelStructSize: 0x40, Sys: &tcpinfo.SysInfo{ReorderedSegs: 3}}
+	if n, ok := i.Reordering(); ok {
+		t.Fatalf("got %v, %v; want none", n, ok)
+	}
+}
@@ -0,0 +1,198 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcpinfo
+
+import (
+	"net"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// A RollupKey returns the key of a destination for the remote
+// address of a connection.
+type RollupKey func(raddr net.Addr) string
+
+var (
+	// RemoteIPKey keys destinations by remote IP address.
+	RemoteIPKey RollupKey = func(raddr net.Addr) string {
+		if a, ok := raddr.(*net.TCPAddr); ok {
+			return a.IP.String()
+		}
+		return addrString(raddr)
+	}
+
+	// RemotePortKey keys destinations by remote port.
+	RemotePortKey RollupKey = func(raddr net.Addr) string {
+		if a, ok := raddr.(*net.TCPAddr); ok {
+			return strconv.Itoa(a.Port)
+		}
+		return addrString(raddr)
+	}
+)
+
+// RemotePrefixKey returns a key of destinations by the prefix of
+// remote IP address, with the prefix length v4 for IPv4 and v6 for
+// IPv6 addresses, such as "192.0.2.0/24".
+func RemotePrefixKey(v4, v6 int) RollupKey {
+	return func(raddr net.Addr) string {
+		a, ok := raddr.(*net.TCPAddr)
+		if !ok {
+			return addrString(raddr)
+		}
+		ip, bits := a.IP.To4(), 32
+		n := v4
+		if ip == nil {
+			ip, bits, n = a.IP.To16(), 128, v6
+		}
+		if ip == nil {
+			return addrString(raddr)
+		}
+		ipn := net.IPNet{IP: ip.Mask(net.CIDRMask(n, bits)), Mask: net.CIDRMask(n, bits)}
+		return ipn.String()
+	}
+}
+
+func addrString(a net.Addr) string {
+	if a == nil {
+		return ""
+	}
+	return a.String()
+}
+
+// A DestinationStats represents statistics of connections to a
+// destination.
+//
+// Totals are accumulated from changes between consecutive samples on
+// each connection; the smoothed values are exponentially weighted
+// moving averages of the changes with a gain of 1/8.
+type DestinationStats struct {
+	Key           string        // key of destination
+	Conns         int           // # of connections sampled
+	Duration      time.Duration // total time elapsed between samples across connections
+	SegsSent      uint64        // total # of segments sent [Darwin and Linux]
+	RetransSegs   uint64        // total # of segments retransmitted [FreeBSD, Linux and NetBSD]
+	RetransBytes  uint64        // total # of bytes retransmitted [Darwin only]
+	BytesSent     uint64        // total # of bytes sent
+	BytesReceived uint64        // total # of bytes received
+
+	SmoothedLoss        float64 // smoothed loss rate
+	SmoothedSendRate    float64 // smoothed sending rate in bytes per second
+	SmoothedReceiveRate float64 // smoothed receiving rate in bytes per second
+
+	samples int // # of changes accumulated
+}
+
+// LossRate returns the ratio of retransmitted segments to sent
+// segments, or of retransmitted bytes to sent bytes on Darwin.
+func (ds *DestinationStats) LossRate() float64 {
+	return lossRate(ds.RetransSegs, ds.SegsSent, ds.RetransBytes, ds.BytesSent)
+}
+
+func lossRate(retransSegs, segsSent, retransBytes, bytesSent uint64) float64 {
+	switch {
+	case segsSent > 0:
+		return float64(retransSegs) / float64(segsSent)
+	case bytesSent > 0:
+		return float64(retransBytes) / float64(bytesSent)
+	}
+	return 0
+}
+
+// SendRate returns the average sending rate per connection in bytes
+// per second.
+func (ds *DestinationStats) SendRate() float64 {
+	if ds.Duration <= 0 {
+		return 0
+	}
+	return float64(ds.BytesSent) / ds.Duration.Seconds()
+}
+
+// ReceiveRate returns the average receiving rate per connection in
+// bytes per second.
+func (ds *DestinationStats) ReceiveRate() float64 {
+	if ds.Duration <= 0 {
+		return 0
+	}
+	return float64(ds.BytesReceived) / ds.Duration.Seconds()
+}
+
+func (ds *DestinationStats) add(d *Delta) {
+	ds.Duration += d.Duration
+	ds.SegsSent += d.SegsSent
+	ds.RetransSegs += d.RetransSegs
+	ds.RetransBytes += d.RetransBytes
+	ds.BytesSent += d.BytesSent
+	ds.BytesReceived += d.BytesReceived
+	loss := lossRate(d.RetransSegs, d.SegsSent, d.RetransBytes, d.BytesSent)
+	if ds.samples == 0 {
+		ds.SmoothedLoss, ds.SmoothedSendRate, ds.SmoothedReceiveRate = loss, d.SendRate(), d.ReceiveRate()
+	} else {
+		ds.SmoothedLoss += (loss - ds.SmoothedLoss) / 8
+		ds.SmoothedSendRate += (d.SendRate() - ds.SmoothedSendRate) / 8
+		ds.SmoothedReceiveRate += (d.ReceiveRate() - ds.SmoothedReceiveRate) / 8
+	}
+	ds.samples++
+}
+
+// A Rollup groups changes of connection information by destination.
+//
+// Samples are fed by Observe, which can be used as a SampleFunc.
+// The final samples of connections must be fed to release the
+// resources held for the connections.
+type Rollup struct {
+	key RollupKey
+
+	mu    sync.Mutex
+	prev  map[net.Conn]*Sample
+	dests map[string]*DestinationStats
+}
+
+// NewRollup returns a new rollup that groups connections by the
+// key.
+func NewRollup(key RollupKey) *Rollup {
+	return &Rollup{key: key, prev: make(map[net.Conn]*Sample), dests: make(map[string]*DestinationStats)}
+}
+
+// Observe records the sample s on the connection c.
+func (r *Rollup) Observe(c net.Conn, s *Sample) {
+	k := r.key(c.RemoteAddr())
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	prev, ok := r.prev[c]
+	ds := r.dests[k]
+	if ds == nil {
+		ds = &DestinationStats{Key: k}
+		r.dests[k] = ds
+	}
+	if !ok {
+		ds.Conns++
+	}
+	if d := Diff(prev, s); d != nil && d.Duration > 0 {
+		ds.add(d)
+	}
+	switch {
+	case s.Final:
+		delete(r.prev, c)
+	case s.Info != nil:
+		r.prev[c] = s
+	case !ok:
+		r.prev[c] = nil
+	}
+}
+
+// Snapshot returns copies of the statistics of destinations, sorted
+// by key.
+func (r *Rollup) Snapshot() []DestinationStats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	dss := make([]DestinationStats, 0, len(r.dests))
+	for _, ds := range r.dests {
+		dss = append(dss, *ds)
+	}
+	sort.Slice(dss, func(i, j int) bool { return dss[i].Key < dss[j].Key })
+	return dss
+}
@@ -0,0 +1,65 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcpinfo_test
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/mikioh/tcpinfo"
+	"github.com/mikioh/tcpinfo/tcpinfotest"
+)
+
+func TestRollup(t *testing.T) {
+	sample := func(at time.Duration, out, retrans uint, acked uint64, final bool) *tcpinfo.Sample {
+		return &tcpinfo.Sample{
+			Time: time.Unix(0, 0).Add(at),
+			Info: tcpinfotest.NewInfo().Sys(func(si *tcpinfo.SysInfo) {
+				si.SegsOut, si.TotalRetransSegs, si.ThruBytesAcked = out, retrans, acked
+			}).Build(),
+			Final: final,
+		}
+	}
+	c1 := tcpinfotest.NewConn("192.0.2.1:50001", "198.51.100.1:443")
+	c2 := tcpinfotest.NewConn("192.0.2.1:50002", "198.51.100.2:443")
+	c3 := tcpinfotest.NewConn("192.0.2.1:50003", "203.0.113.1:443")
+	r := tcpinfo.NewRollup(tcpinfo.RemotePrefixKey(24, 64))
+	for _, o := range []struct {
+		c net.Conn
+		s *tcpinfo.Sample
+	}{
+		{c1, sample(0, 0, 0, 0, false)},
+		{c2, sample(0, 100, 0, 0, false)},
+		{c1, sample(time.Second, 100, 10, 100000, false)},
+		{c2, sample(time.Second, 200, 0, 300000, false)},
+		{c3, &tcpinfo.Sample{Err: tcpinfo.ErrNotSupported}},
+		{c1, sample(2*time.Second, 200, 10, 200000, true)},
+	} {
+		r.Observe(o.c, o.s)
+	}
+	dss := r.Snapshot()
+	if len(dss) != 2 || dss[0].Key != "198.51.100.0/24" || dss[1].Key != "203.0.113.0/24" {
+		t.Fatalf("got %+v", dss)
+	}
+	ds := dss[0]
+	if ds.Conns != 2 || ds.Duration != 3*time.Second || ds.SegsSent != 300 || ds.RetransSegs != 10 || ds.BytesSent != 500000 {
+		t.Fatalf("got %+v", ds)
+	}
+	if ds.LossRate() != 10.0/300 || ds.SendRate() != 500000.0/3 {
+		t.Fatalf("got %v, %v", ds.LossRate(), ds.SendRate())
+	}
+	if want := 0.1 + (0-0.1)/8 + (0-(0.1+(0-0.1)/8))/8; ds.SmoothedLoss != want {
+		t.Fatalf("got %v; want %v", ds.SmoothedLoss, want)
+	}
+	if dss[1].Conns != 1 || dss[1].Duration != 0 {
+		t.Fatalf("got %+v", dss[1])
+	}
+
+	r.Observe(c1, sample(3*time.Second, 300, 10, 300000, false))
+	if ds := r.Snapshot()[0]; ds.Conns != 3 || ds.Duration != 3*time.Second {
+		t.Fatalf("got %+v; want a new connection after the final sample", ds)
+	}
+}
@@ -0,0 +1,31 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcpinfo_test
+
+import (
+	"net"
+	"testing"
+
+	"github.com/mikioh/tcpinfo"
+)
+
+func TestRollupKey(t *testing.T) {
+	for _, tt := range []struct {
+		key   tcpinfo.RollupKey
+		raddr net.Addr
+		want  string
+	}{
+		{tcpinfo.RemoteIPKey, &net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 443}, "192.0.2.1"},
+		{tcpinfo.RemotePortKey, &net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 443}, "443"},
+		{tcpinfo.RemotePrefixKey(24, 48), &net.TCPAddr{IP: net.ParseIP("192.0.2.129"), Port: 443}, "192.0.2.0/24"},
+		{tcpinfo.RemotePrefixKey(24, 48), &net.TCPAddr{IP: net.ParseIP("2001:db8:1:2::1"), Port: 443}, "2001:db8:1::/48"},
+		{tcpinfo.RemoteIPKey, &net.UnixAddr{Name: "/tmp/sock", Net: "unix"}, "/tmp/sock"},
+		{tcpinfo.RemoteIPKey, nil, ""},
+	} {
+		if got := tt.key(tt.raddr); got != tt.want {
+			t.Fatalf("%v: got %s; want %s", tt.raddr, got, tt.want)
+		}
+	}
+}
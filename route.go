@@ -0,0 +1,118 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcpinfo
+
+import (
+	"net"
+	"sync"
+	"time"
+)
+
+// A Route represents the route carrying a connection.
+//
+// Only supported on Linux.
+type Route struct {
+	Interface string `json:"interface"`         // name of egress interface
+	Index     int    `json:"index"`             // index of egress interface
+	Source    net.IP `json:"src,omitempty"`     // preferred source address
+	Gateway   net.IP `json:"gateway,omitempty"` // next hop; nil when the destination is on link
+	Table     int    `json:"table"`             // routing table
+	MTU       int    `json:"mtu"`               // maximum transmission unit of the route, or of the egress interface when the route has none
+}
+
+// GetRoute looks up the route from the local address to the remote
+// address of c in the routing tables of the kernel.
+//
+// Only supported on Linux.
+func GetRoute(c net.Conn) (*Route, error) {
+	laddr, _ := c.LocalAddr().(*net.TCPAddr)
+	raddr, _ := c.RemoteAddr().(*net.TCPAddr)
+	if laddr == nil || raddr == nil {
+		return nil, errNotSupported("get", "route")
+	}
+	return getRoute(laddr.IP, raddr.IP)
+}
+
+// A RouteResolver resolves and caches routes of connections.
+//
+// The Attach method wraps a SampleFunc to attach routes to samples,
+// and the resolver is a Labeler that can be passed to WithLabels or
+// NewAggregator for per-interface aggregation.
+type RouteResolver struct {
+	TTL time.Duration // duration for which a route is cached; zero means the lifetime of connection
+
+	mu     sync.Mutex
+	routes map[net.Conn]*routeEntry
+}
+
+type routeEntry struct {
+	rt      *Route
+	err     error
+	expires time.Time
+}
+
+// Resolve returns the route of c.
+func (r *RouteResolver) Resolve(c net.Conn) (*Route, error) {
+	now := time.Now()
+	r.mu.Lock()
+	if e := r.routes[c]; e != nil && (e.expires.IsZero() || now.Before(e.expires)) {
+		r.mu.Unlock()
+		return e.rt, e.err
+	}
+	r.mu.Unlock()
+	e := &routeEntry{}
+	e.rt, e.err = GetRoute(c)
+	if r.TTL > 0 {
+		e.expires = now.Add(r.TTL)
+	}
+	r.mu.Lock()
+	if r.routes == nil {
+		r.routes = make(map[net.Conn]*routeEntry)
+	}
+	r.routes[c] = e
+	r.mu.Unlock()
+	return e.rt, e.err
+}
+
+// Forget releases the route cached for c, such as when the
+// connection is closed.
+func (r *RouteResolver) Forget(c net.Conn) {
+	r.mu.Lock()
+	delete(r.routes, c)
+	r.mu.Unlock()
+}
+
+// Labels implements the Labels method of Labeler interface.
+// It returns the name of egress interface of c as the label
+// "interface", taken from the route attached to s when available.
+// The value is empty when the route is not resolved.
+func (r *RouteResolver) Labels(c net.Conn, s *Sample) []Label {
+	var rt *Route
+	if s != nil {
+		rt = s.Route
+	}
+	if rt == nil {
+		rt, _ = r.Resolve(c)
+	}
+	if rt == nil {
+		return []Label{{Name: "interface"}}
+	}
+	return []Label{{Name: "interface", Value: rt.Interface}}
+}
+
+// Attach returns a SampleFunc that attaches the route of connection
+// to each sample and invokes fn with it.
+// The route is forgotten with the final sample.
+func (r *RouteResolver) Attach(fn SampleFunc) SampleFunc {
+	return func(c net.Conn, s *Sample) {
+		s.Route, _ = r.Resolve(c)
+		if s.Final {
+			r.Forget(c)
+		}
+		if fn != nil {
+			fn(c, s)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcpinfo_test
+
+import (
+	"net"
+	"testing"
+
+	"github.com/mikioh/tcpinfo"
+)
+
+func TestRouteResolver(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	c, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	var r tcpinfo.RouteResolver
+	rt, err := r.Resolve(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lo, err := net.InterfaceByIndex(rt.Index)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rt.Interface != lo.Name || lo.Flags&net.FlagLoopback == 0 || rt.MTU <= 0 {
+		t.Fatalf("got %+v; want loopback route", rt)
+	}
+	if lbs := r.Labels(c, nil); len(lbs) != 1 || lbs[0] != (tcpinfo.Label{Name: "interface", Value: rt.Interface}) {
+		t.Fatalf("got %v; want [{interface %s}]", lbs, rt.Interface)
+	}
+	if lbs := r.Labels(c, &tcpinfo.Sample{Route: &tcpinfo.Route{Interface: "eth0"}}); len(lbs) != 1 || lbs[0].Value != "eth0" {
+		t.Fatalf("got %v; want attached route", lbs)
+	}
+
+	var got *tcpinfo.Sample
+	fn := r.Attach(func(_ net.Conn, s *tcpinfo.Sample) { got = s })
+	fn(c, &tcpinfo.Sample{Final: true})
+	if got == nil || got.Route != rt {
+		t.Fatalf("got %+v; want route %+v", got, rt)
+	}
+	if rt2, err := r.Resolve(c); err != nil || rt2 == rt {
+		t.Fatalf("got %+v, %v; want route resolved again", rt2, err)
+	}
+}
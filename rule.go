@@ -0,0 +1,96 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcpinfo
+
+import (
+	"fmt"
+	"net"
+	"time"
+)
+
+// A Rule represents a threshold rule on connection information, such
+// as a service level objective.
+type Rule struct {
+	Name string        // name for reporting
+	For  time.Duration // duration for which the condition must hold before breach
+
+	// Cond reports whether the current sample cur violates the
+	// rule.
+	// The previous sample prev is nil for the first sample on a
+	// connection.
+	Cond func(prev, cur *Sample) bool
+}
+
+// RTTAbove returns a rule breached when the round-trip time stays
+// above x for d.
+func RTTAbove(x, d time.Duration) *Rule {
+	return &Rule{
+		Name: fmt.Sprintf("rtt > %v for %v", x, d),
+		For:  d,
+		Cond: func(_, cur *Sample) bool { return cur.Info != nil && cur.Info.RTT > x },
+	}
+}
+
+// RetransRateAbove returns a rule breached when the ratio of
+// retransmitted segments to sent segments between samples stays
+// above pct percent for d.
+// The rule works on platforms providing the # of segments sent and
+// retransmitted.
+func RetransRateAbove(pct float64, d time.Duration) *Rule {
+	return &Rule{
+		Name: fmt.Sprintf("retrans rate > %v%% for %v", pct, d),
+		For:  d,
+		Cond: func(prev, cur *Sample) bool {
+			dl := Diff(prev, cur)
+			if dl == nil || dl.RetransSegs == 0 {
+				return false
+			}
+			return dl.SegsSent == 0 || float64(dl.RetransSegs)*100 > pct*float64(dl.SegsSent)
+		},
+	}
+}
+
+// A Breach represents a breach of a rule.
+type Breach struct {
+	Rule   *Rule     // breached rule
+	Since  time.Time // time of the first violating sample
+	Window []*Sample // violating samples since Since
+}
+
+// A BreachFunc receives a breach of a rule on c.
+type BreachFunc func(c net.Conn, b *Breach)
+
+// A ruleBinding represents a rule attached to a monitor.
+type ruleBinding struct {
+	r  *Rule
+	fn BreachFunc
+}
+
+// A ruleState represents the state of a rule on a connection.
+type ruleState struct {
+	window   []*Sample
+	breached bool
+}
+
+// eval evaluates the rule with the sample cur and reports a breach
+// once when the condition holds for the duration of the rule.
+func (rb *ruleBinding) eval(c net.Conn, st *ruleState, prev, cur *Sample) {
+	if !rb.r.Cond(prev, cur) {
+		st.window, st.breached = nil, false
+		return
+	}
+	if st.breached {
+		return
+	}
+	st.window = append(st.window, cur)
+	since := st.window[0].Time
+	if cur.Time.Sub(since) < rb.r.For {
+		return
+	}
+	st.breached = true
+	b := &Breach{Rule: rb.r, Since: since, Window: st.window}
+	st.window = nil
+	rb.fn(c, b)
+}
@@ -0,0 +1,62 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcpinfo_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mikioh/tcpinfo"
+	"github.com/mikioh/tcpinfo/tcpinfotest"
+)
+
+func TestRetransRateAbove(t *testing.T) {
+	sample := func(out, retrans uint) *tcpinfo.Sample {
+		return &tcpinfo.Sample{Info: tcpinfotest.NewInfo().Sys(func(si *tcpinfo.SysInfo) {
+			si.SegsOut, si.TotalRetransSegs = out, retrans
+		}).Build()}
+	}
+	r := tcpinfo.RetransRateAbove(5, time.Second)
+	for _, tt := range []struct {
+		prev, cur *tcpinfo.Sample
+		want      bool
+	}{
+		{sample(100, 0), sample(200, 5), false},
+		{sample(100, 0), sample(200, 6), true},
+		{sample(100, 3), sample(100, 4), true},
+		{sample(100, 3), sample(200, 3), false},
+	} {
+		if got := r.Cond(tt.prev, tt.cur); got != tt.want {
+			t.Fatalf("got %v; want %v", got, tt.want)
+		}
+	}
+}
+
+func TestTailLossAhead(t *testing.T) {
+	info := func(unacked, sacked uint, lastAck time.Duration) *tcpinfo.Info {
+		i := tcpinfotest.NewInfo().RTT(100*time.Millisecond, 0).Sys(func(si *tcpinfo.SysInfo) {
+			si.UnackedSegs, si.SackedSegs = unacked, sacked
+		}).Build()
+		i.LastAckReceived = lastAck
+		return i
+	}
+	r := tcpinfo.TailLossAhead(3)
+	for _, tt := range []struct {
+		i    *tcpinfo.Info
+		want bool
+	}{
+		{info(10, 0, 400*time.Millisecond), true},
+		{info(10, 0, 200*time.Millisecond), false},
+		{info(10, 2, 400*time.Millisecond), false},
+		{info(0, 0, 400*time.Millisecond), false},
+	} {
+		if got := r.Cond(nil, &tcpinfo.Sample{Info: tt.i}); got != tt.want {
+			t.Fatalf("%+v: got %v; want %v", tt.i.Sys, got, tt.want)
+		}
+	}
+	if i := info(10, 0, 300*time.Millisecond); !i.TailLossRisk(0) {
+		t.Fatalf("got false for %v without ack; want true", i.LastAckReceived)
+	}
+}
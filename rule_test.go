@@ -0,0 +1,72 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcpinfo_test
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/mikioh/tcpinfo"
+	"github.com/mikioh/tcpinfo/tcpinfotest"
+)
+
+func TestMonitorRule(t *testing.T) {
+	c := tcpinfotest.NewConn("192.0.2.1:443", "192.0.2.2:50000")
+	g := tcpinfotest.NewGetter()
+	low := tcpinfotest.Step{Info: tcpinfotest.NewInfo().RTT(10*time.Millisecond, 0).Build()}
+	high := tcpinfotest.Step{Info: tcpinfotest.NewInfo().RTT(200*time.Millisecond, 0).Build()}
+	var steps []tcpinfotest.Step
+	for _, n := range []struct {
+		st tcpinfotest.Step
+		n  int
+	}{{low, 2}, {high, 10}, {low, 2}, {high, 1}} {
+		for j := 0; j < n.n; j++ {
+			steps = append(steps, n.st)
+		}
+	}
+	g.Script(c, steps...)
+
+	breaches := make(chan *tcpinfo.Breach, 10)
+	m := tcpinfo.NewMonitorWithGetter(g, time.Millisecond, nil)
+	defer m.Close()
+	r := tcpinfo.RTTAbove(100*time.Millisecond, 3*time.Millisecond)
+	m.AddRule(r, func(bc net.Conn, b *tcpinfo.Breach) {
+		if bc != c {
+			t.Errorf("got %v; want %v", bc, c)
+		}
+		breaches <- b
+	})
+	m.Add(c)
+	for j := 0; j < 2; j++ {
+		select {
+		case b := <-breaches:
+			if b.Rule != r || len(b.Window) < 2 || !b.Since.Equal(b.Window[0].Time) {
+				t.Fatalf("got %+v", b)
+			}
+			if d := b.Window[len(b.Window)-1].Time.Sub(b.Since); d < r.For {
+				t.Fatalf("got window of %v; want at least %v", d, r.For)
+			}
+			for _, s := range b.Window {
+				if s.Info.RTT != 200*time.Millisecond {
+					t.Fatalf("got %v in window", s.Info.RTT)
+				}
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for breach #%d", j+1)
+		}
+	}
+	m.Remove(c)
+	select {
+	case b := <-breaches:
+		t.Fatalf("got %+v; want no more breaches", b)
+	default:
+	}
+
+	rr := tcpinfo.RetransRateAbove(1, time.Second)
+	if rr.Cond(nil, &tcpinfo.Sample{Info: low.Info}) {
+		t.Fatal("got violated; want not violated without previous sample")
+	}
+}
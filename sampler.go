@@ -0,0 +1,472 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcpinfo
+
+import (
+	"errors"
+	"math/rand"
+	"net"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/mikioh/tcpopt"
+)
+
+// Get returns connection information on c.
+// The Queue field is filled in when queue occupancy is available.
+//
+// The connection must implement syscall.Conn.
+// Only supported on Darwin, FreeBSD, Linux and NetBSD.
+func Get(c net.Conn) (*Info, error) {
+	i := new(Info)
+	if err := GetInto(c, i); err != nil {
+		return nil, err
+	}
+	return i, nil
+}
+
+// GetInto is like Get but fills in i, reusing its memory as
+// ParseInto does.
+// The buffer used for retrieval is taken from a pool and never
+// referenced by i.
+//
+// GetInto resolves the raw network connection of c on each call,
+// which allocates; Handle resolves it once and retrieves without
+// allocation.
+func GetInto(c net.Conn, i *Info) error {
+	return GetFields(c, i, FieldAll)
+}
+
+// GetFields is like GetInto but fills in only the groups of fields
+// in m, as ParseFields does.
+// The queue occupancy is not retrieved unless FieldQueue is in m.
+//
+// The options held in socket options, such as NoDelay, CCAlgorithm,
+// UserTimeout and KeepAlive, take a retrieval each and are retrieved
+// only when both FieldOptions and FieldSockOpts are in m; the
+// authentication options are appended to the Options and PeerOptions
+// fields when in use.
+// Likewise, the platform-specific information held in socket options
+// is retrieved only when both FieldSys and FieldSockOpts are in m.
+func GetFields(c net.Conn, i *Info, m FieldMask) error {
+	rc, err := rawConn(c)
+	if err != nil {
+		return err
+	}
+	return getInto(rc, i, m)
+}
+
+// A retriever retrieves connection information from a socket.
+// It holds the retrieval buffer and the control function bound once
+// to it, so that a retrieval allocates nothing but the information.
+type retriever struct {
+	b   [sizeofInfoBuf]byte
+	fn  func(s uintptr) // control method bound to the retriever
+	i   *Info
+	m   FieldMask
+	err error
+}
+
+// retrievers holds retrievers for retrieving connection information.
+var retrievers = sync.Pool{
+	New: func() interface{} {
+		r := new(retriever)
+		r.fn = r.control
+		return r
+	},
+}
+
+// getInto retrieves the groups of fields in m of connection
+// information on rc into i, all within a single control of the
+// underlying socket.
+func getInto(rc syscall.RawConn, i *Info, m FieldMask) error {
+	if options[soInfo].name == 0 {
+		return errNotSupported("get", soKinds[soInfo])
+	}
+	r := retrievers.Get().(*retriever)
+	r.i, r.m, r.err = i, m, nil
+	err := rc.Control(r.fn)
+	if err == nil {
+		err = r.err
+	}
+	r.i, r.err = nil, nil
+	retrievers.Put(r)
+	return connClosed(err)
+}
+
+func (r *retriever) control(s uintptr) {
+	r.err = r.get(s)
+}
+
+func (r *retriever) get(s uintptr) error {
+	i, m, q := r.i, r.m, r.i.Queue
+	n, err := getsockopt(s, options[soInfo].level, options[soInfo].name, r.b[:])
+	if err != nil {
+		return err
+	}
+	if err := parseInfoInto(r.b[:n], i, m, ParseDefault); err != nil {
+		return err
+	}
+	retainRaw(r.b[:n], i, m)
+	if m&FieldQueue != 0 {
+		if q == nil {
+			q = new(Queue)
+		}
+		if getQueue(s, q) == nil {
+			i.Queue = q
+		}
+	}
+	if m&FieldSys != 0 && m&FieldSockOpts != 0 && i.Sys != nil {
+		getSysOptions(s, i.Sys)
+	}
+	if m&FieldOptions == 0 {
+		return nil
+	}
+	if m&FieldSockOpts != 0 {
+		if opts := getAuthOptions(s); len(opts) > 0 {
+			i.Options = append(i.Options, opts...)
+			i.PeerOptions = append(i.PeerOptions, opts...)
+		}
+		i.Options = appendSocketOptions(i.Options, s)
+	}
+	i.Options = appendPrivateOptions(i.Options, s)
+	return nil
+}
+
+func get(c net.Conn, so int, b []byte) (tcpopt.Option, error) {
+	n, err := getRaw(c, so, b)
+	if err != nil {
+		return nil, err
+	}
+	return options[so].parseFn(b[:n])
+}
+
+// getRaw reads the socket option so on c into b and returns the
+// number of bytes read.
+func getRaw(c net.Conn, so int, b []byte) (int, error) {
+	if options[so].name == 0 {
+		return 0, errNotSupported("get", soKinds[so])
+	}
+	var n int
+	err := control(c, func(s uintptr) error {
+		var err error
+		n, err = getsockopt(s, options[so].level, options[so].name, b)
+		return err
+	})
+	return n, err
+}
+
+// set sets the socket option o on c.
+func set(c net.Conn, o tcpopt.Option) error {
+	b, err := o.Marshal()
+	if err != nil {
+		return err
+	}
+	return control(c, func(s uintptr) error {
+		return setsockopt(s, o.Level(), o.Name(), b)
+	})
+}
+
+// control invokes fn on the underlying socket of c.
+func control(c net.Conn, fn func(s uintptr) error) error {
+	rc, err := rawConn(c)
+	if err != nil {
+		return err
+	}
+	return controlRaw(rc, fn)
+}
+
+// rawConn returns the raw network connection of c.
+func rawConn(c net.Conn) (syscall.RawConn, error) {
+	sc, ok := c.(syscall.Conn)
+	if !ok {
+		return nil, errors.New("not a syscall.Conn")
+	}
+	return sc.SyscallConn()
+}
+
+// controlRaw invokes fn on the socket of rc.
+func controlRaw(rc syscall.RawConn, fn func(s uintptr) error) error {
+	var operr error
+	if err := rc.Control(func(s uintptr) { operr = fn(s) }); err != nil {
+		return err
+	}
+	return operr
+}
+
+// A Sample represents a sample of connection information.
+type Sample struct {
+	Time   time.Time     // wall-clock time when the sample was taken
+	Mono   time.Duration // monotonic time when the sample was taken; zero when unknown
+	Info   *Info         // connection information; nil when Err is not nil
+	Err    error         // error on retrieval
+	Final  bool          // whether the sample is the last one for the connection
+	Route  *Route        // route carrying the connection; nil unless attached by a route resolver
+	Labels []Label       // labels of the sample; nil unless attached by a labeler
+}
+
+// Clone returns a deep copy of s.
+func (s *Sample) Clone() *Sample {
+	if s == nil {
+		return nil
+	}
+	ns := *s
+	ns.Info = s.Info.Clone()
+	if s.Route != nil {
+		rt := *s.Route
+		ns.Route = &rt
+	}
+	ns.Labels = append([]Label(nil), s.Labels...)
+	return &ns
+}
+
+// A SampleFunc receives samples of connection information on c.
+type SampleFunc func(c net.Conn, s *Sample)
+
+// A Sampler takes samples of connection information periodically.
+//
+// When a retrieval fails with ErrConnClosed, the sample is delivered
+// as the final sample, a tombstone of the connection, and the sampler
+// stops.
+type Sampler struct {
+	c     net.Conn
+	g     Getter
+	h     *Handle
+	herr  error // error on binding c
+	fn    SampleFunc
+	start time.Time
+	once  sync.Once
+	stop  chan struct{}
+	done  chan struct{}
+
+	fields  FieldMask
+	maxMult int // maximum # of intervals between samples in adaptive mode
+	active  func(prev, cur *Sample) bool
+	boost   int32 // accessed atomically; non-zero when a boost is requested
+
+	mu     sync.Mutex
+	minRTT time.Duration
+	fs     *FinalStats
+}
+
+// NewSampler returns a new sampler that takes a sample of connection
+// information on c every d and invokes fn with it.
+// When d is not positive, only the final sample is taken.
+// The callback function fn may be nil.
+func NewSampler(c net.Conn, d time.Duration, fn SampleFunc) *Sampler {
+	return NewSamplerWithOpts(c, SamplerOpts{Interval: d}, fn)
+}
+
+// NewSamplerWithGetter is like NewSampler but retrieves connection
+// information on c from g.
+// A nil g means retrieval via the socket of c, as NewSampler does.
+func NewSamplerWithGetter(g Getter, c net.Conn, d time.Duration, fn SampleFunc) *Sampler {
+	return NewSamplerWithOpts(c, SamplerOpts{Getter: g, Interval: d}, fn)
+}
+
+// SamplerOpts represents options for a sampler.
+type SamplerOpts struct {
+	Getter   Getter        // source of connection information; nil means the socket of connection
+	Interval time.Duration // sampling interval; zero means final sample only
+	Fields   FieldMask     // groups of fields retrieved from the socket, such as FieldAll|FieldRaw; zero means FieldAll
+
+	// Align specifies whether ticks are aligned to multiples of
+	// Interval in wall-clock time, such as at every :00 second for
+	// an interval of one minute.
+	Align bool
+
+	// Jitter is the upper bound of a random offset added to the
+	// ticks of each sampler, which spreads the retrievals of many
+	// samplers sharing the same interval.
+	// The offset is chosen once per sampler and the ticks are kept
+	// Interval apart.
+	Jitter time.Duration
+
+	// MaxInterval enables adaptive sampling when it is longer than
+	// Interval.
+	// In adaptive mode the interval doubles on each sample showing
+	// no activity, up to MaxInterval, and falls back to Interval
+	// as soon as a sample shows activity or Boost is called.
+	// The intervals are multiples of Interval, which keeps the
+	// alignment of ticks.
+	MaxInterval time.Duration
+
+	// Active reports whether the sample cur shows activity since
+	// the sample prev in adaptive mode.
+	// A nil Active means any traffic, retransmission or change of
+	// state.
+	Active func(prev, cur *Sample) bool
+}
+
+// NewSamplerWithOpts is like NewSampler but takes samples as
+// specified by opts.
+func NewSamplerWithOpts(c net.Conn, opts SamplerOpts, fn SampleFunc) *Sampler {
+	now := currentClock().Now()
+	s := &Sampler{c: c, g: opts.Getter, fn: fn, start: now, stop: make(chan struct{}), done: make(chan struct{})}
+	if s.g == nil {
+		s.h, s.herr = Bind(c)
+	}
+	if s.fields = opts.Fields; s.fields == 0 {
+		s.fields = FieldAll
+	}
+	d := opts.Interval
+	if d <= 0 {
+		close(s.done)
+		return s
+	}
+	first := d
+	if opts.Align {
+		first = d - time.Duration(now.UnixNano()%int64(d))
+	}
+	if opts.Jitter > 0 {
+		first += time.Duration(rand.Int63n(int64(opts.Jitter)))
+	}
+	s.maxMult = 1
+	if opts.MaxInterval > d {
+		s.maxMult = int(opts.MaxInterval / d)
+		s.active = opts.Active
+		if s.active == nil {
+			s.active = active
+		}
+	}
+	go s.run(first, d)
+	return s
+}
+
+func (s *Sampler) run(first, d time.Duration) {
+	defer close(s.done)
+	ft := time.NewTimer(first)
+	var prev *Sample
+	select {
+	case <-s.stop:
+		ft.Stop()
+		return
+	case <-ft.C:
+		if prev = s.sample(false); prev.Final {
+			s.finish(prev)
+			return
+		}
+	}
+	t := time.NewTicker(d)
+	defer t.Stop()
+	mult, n := 1, 0
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-t.C:
+		}
+		if atomic.SwapInt32(&s.boost, 0) != 0 {
+			mult = 1
+		}
+		if n++; n < mult {
+			continue
+		}
+		cur := s.sample(false)
+		if cur.Final {
+			s.finish(cur)
+			return
+		}
+		switch {
+		case s.maxMult == 1:
+		case s.active(prev, cur):
+			mult = 1
+		case mult < s.maxMult:
+			if mult *= 2; mult > s.maxMult {
+				mult = s.maxMult
+			}
+		}
+		prev, n = cur, 0
+	}
+}
+
+// Boost makes the sampler in adaptive mode take the next sample at
+// the next tick of Interval and fall back to Interval, such as when
+// an anomaly is detected.
+// It does nothing unless the sampler is in adaptive mode.
+func (s *Sampler) Boost() {
+	atomic.StoreInt32(&s.boost, 1)
+}
+
+// active reports whether cur shows any traffic, retransmission or
+// change of state since prev.
+func active(prev, cur *Sample) bool {
+	if cur.Info == nil {
+		return false
+	}
+	if prev == nil || prev.Info == nil || prev.Info.State != cur.Info.State {
+		return true
+	}
+	d := Diff(prev, cur)
+	return d.SegsSent > 0 || d.SegsReceived > 0 || d.BytesSent > 0 || d.BytesReceived > 0 || d.RetransSegs > 0 || d.RetransBytes > 0
+}
+
+func (s *Sampler) sample(final bool) *Sample {
+	var smp *Sample
+	switch {
+	case s.g != nil:
+		smp = &Sample{}
+		smp.Stamp()
+		smp.Info, smp.Err = s.g.Get(s.c)
+	case s.herr != nil:
+		smp = &Sample{Err: s.herr}
+		smp.Stamp()
+	default:
+		smp = s.h.sample(s.fields)
+	}
+	smp.Final = final || errors.Is(smp.Err, ErrConnClosed)
+	i := smp.Info
+	if i != nil && i.RTT > 0 {
+		s.mu.Lock()
+		if s.minRTT == 0 || i.RTT < s.minRTT {
+			s.minRTT = i.RTT
+		}
+		s.mu.Unlock()
+	}
+	if s.fn != nil {
+		s.fn(s.c, smp)
+	}
+	return smp
+}
+
+// Stop stops the sampler, takes the final sample and invokes the
+// callback function with it.
+// It must be called before the connection is closed.
+//
+// Stop returns a summary of connection information built from the
+// final sample.
+// No sample is taken when the sampler already stopped on a closed
+// connection.
+func (s *Sampler) Stop() *FinalStats {
+	s.once.Do(func() {
+		close(s.stop)
+		<-s.done
+		s.mu.Lock()
+		fs := s.fs
+		s.mu.Unlock()
+		if fs == nil {
+			s.finish(s.sample(true))
+		}
+	})
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.fs
+}
+
+// finish builds the summary of connection information from the final
+// sample smp.
+func (s *Sampler) finish(smp *Sample) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fs = &FinalStats{Info: smp.Info, Err: smp.Err, Duration: smp.Time.Sub(s.start), MinRTT: s.minRTT}
+	if smp.Info != nil {
+		s.fs.Stats = smp.Info.Stats()
+		if s.fs.Stats.MinRTT > 0 && (s.fs.MinRTT == 0 || s.fs.Stats.MinRTT < s.fs.MinRTT) {
+			s.fs.MinRTT = s.fs.Stats.MinRTT
+		}
+	}
+}
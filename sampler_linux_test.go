@@ -0,0 +1,31 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcpinfo_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mikioh/tcpinfo"
+	"github.com/mikioh/tcpinfo/tcpinfotest"
+)
+
+func TestSamplerKernelMinRTT(t *testing.T) {
+	c := tcpinfotest.NewConn("192.0.2.1:443", "192.0.2.2:50000")
+	g := tcpinfotest.NewGetter()
+	for _, tt := range []struct {
+		rtt, kernel, want time.Duration
+	}{
+		{0, 5 * time.Millisecond, 5 * time.Millisecond},
+		{20 * time.Millisecond, 5 * time.Millisecond, 5 * time.Millisecond},
+		{20 * time.Millisecond, 0, 20 * time.Millisecond},
+	} {
+		g.Set(c, tcpinfotest.NewInfo().RTT(tt.rtt, 0).Sys(func(si *tcpinfo.SysInfo) { si.MinRTT = tt.kernel }).Build())
+		fs := tcpinfo.NewSamplerWithGetter(g, c, 0, nil).Stop() // final sample only
+		if fs.Err != nil || fs.MinRTT != tt.want {
+			t.Fatalf("rtt %v, kernel min_rtt %v: got %+v; want %v", tt.rtt, tt.kernel, fs, tt.want)
+		}
+	}
+}
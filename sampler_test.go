@@ -0,0 +1,60 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcpinfo_test
+
+import (
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mikioh/tcpinfo"
+	"github.com/mikioh/tcpinfo/tcpinfotest"
+)
+
+func TestSamplerAlign(t *testing.T) {
+	const d = 100 * time.Millisecond
+	g := tcpinfotest.NewGetter()
+	c := tcpinfotest.NewConn("127.0.0.1:1", "127.0.0.1:2")
+	g.Set(c, tcpinfotest.NewInfo().Build())
+	ch := make(chan *tcpinfo.Sample, 1)
+	s := tcpinfo.NewSamplerWithOpts(c, tcpinfo.SamplerOpts{Getter: g, Interval: d, Align: true, Jitter: time.Millisecond}, func(_ net.Conn, smp *tcpinfo.Sample) {
+		select {
+		case ch <- smp:
+		default:
+		}
+	})
+	smp := <-ch
+	s.Stop()
+	if off := smp.Time.Sub(smp.Time.Truncate(d)); off > d/2 {
+		t.Fatalf("got %v; want less than %v past the boundary", off, d/2)
+	}
+}
+
+func TestSamplerAdaptive(t *testing.T) {
+	const d = 10 * time.Millisecond
+	g := tcpinfotest.NewGetter()
+	c := tcpinfotest.NewConn("127.0.0.1:1", "127.0.0.1:2")
+	g.Set(c, tcpinfotest.NewInfo().State(tcpinfo.Established).Build())
+	var mu sync.Mutex
+	var ts []time.Time
+	s := tcpinfo.NewSamplerWithOpts(c, tcpinfo.SamplerOpts{Getter: g, Interval: d, MaxInterval: 8 * d}, func(_ net.Conn, smp *tcpinfo.Sample) {
+		mu.Lock()
+		ts = append(ts, smp.Time)
+		mu.Unlock()
+	})
+	time.Sleep(40 * d)
+	s.Stop()
+	mu.Lock()
+	defer mu.Unlock()
+	// A quiescent connection is sampled at 1, 2, 4 and then every 8
+	// intervals.
+	if len(ts) < 4 || len(ts) > 12 {
+		t.Fatalf("got %d samples; want between 4 and 12", len(ts))
+	}
+	if gap := ts[len(ts)-2].Sub(ts[len(ts)-3]); gap < 6*d {
+		t.Fatalf("got %v; want at least %v", gap, 6*d)
+	}
+}
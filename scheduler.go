@@ -0,0 +1,245 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcpinfo
+
+import (
+	"net"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// SchedulerOpts represents options for a scheduler.
+type SchedulerOpts struct {
+	Getter   Getter        // source of connection information; nil means the sockets of connections
+	Interval time.Duration // desired sampling interval of each connection; defaults to 1s
+	Budget   int           // maximum # of retrievals per second across all connections; zero means no limit
+
+	// Weight returns the weight of a connection, which scales its
+	// priority when the budget does not cover all the connections.
+	// It is called once when a connection is added.
+	// A nil Weight means the same weight 1 for all connections.
+	Weight func(c net.Conn) float64
+
+	// ShedFunc receives a report on each round of sampling in which
+	// connections due for sampling are deferred to stay within the
+	// budget.
+	ShedFunc func(r *ShedReport)
+}
+
+// A ShedReport represents a round of sampling shedding load.
+type ShedReport struct {
+	Time     time.Time
+	Due      int // # of connections due for sampling
+	Sampled  int // # of connections sampled
+	Deferred int // # of connections deferred to later rounds
+}
+
+// A Scheduler takes samples of connection information on multiple
+// connections within a global budget of retrievals per second.
+//
+// Connections due for sampling are sampled in the order of priority,
+// which grows with the weight, recent activity and the time since
+// the latest sample of each connection, so that no connection is
+// starved when the budget is short.
+// The final samples taken by Remove are not counted against the
+// budget.
+// A connection closed during sampling is untracked with its tombstone
+// delivered as the final sample, as Monitor does.
+type Scheduler struct {
+	opts SchedulerOpts
+	fn   SampleFunc
+	tick time.Duration
+
+	mu    sync.Mutex
+	conns map[net.Conn]*schedEntry
+	stop  chan struct{}
+	done  chan struct{}
+	once  sync.Once
+}
+
+// A schedEntry represents a connection tracked by a scheduler.
+type schedEntry struct {
+	weight float64
+	latest atomic.Value // *Sample
+
+	mu       sync.Mutex // serializes sampling and removal
+	s        *Sampler
+	prev     *Sample
+	last     time.Time // time of the latest sample
+	activity float64   // decaying count of samples showing activity
+	removed  bool
+}
+
+const maxSchedTick = 100 * time.Millisecond
+
+// NewScheduler returns a new scheduler that takes samples on tracked
+// connections as specified by opts and invokes fn with them.
+// The callback function fn may be nil.
+func NewScheduler(opts SchedulerOpts, fn SampleFunc) *Scheduler {
+	if opts.Interval <= 0 {
+		opts.Interval = time.Second
+	}
+	s := &Scheduler{opts: opts, fn: fn, tick: opts.Interval, conns: make(map[net.Conn]*schedEntry), stop: make(chan struct{}), done: make(chan struct{})}
+	if s.tick > maxSchedTick {
+		s.tick = maxSchedTick
+	}
+	go s.run()
+	return s
+}
+
+// Add starts tracking c.
+// It does nothing when c is already tracked.
+func (s *Scheduler) Add(c net.Conn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.conns[c]; ok {
+		return
+	}
+	e := &schedEntry{weight: 1}
+	e.s = NewSamplerWithOpts(c, SamplerOpts{Getter: s.opts.Getter}, s.fn)
+	if s.opts.Weight != nil {
+		if w := s.opts.Weight(c); w > 0 {
+			e.weight = w
+		}
+	}
+	s.conns[c] = e
+}
+
+// Remove stops tracking c, takes the final sample and returns a
+// summary of connection information built from it.
+// It must be called before the connection is closed.
+// It returns nil when c is not tracked.
+func (s *Scheduler) Remove(c net.Conn) *FinalStats {
+	s.mu.Lock()
+	e := s.conns[c]
+	delete(s.conns, c)
+	s.mu.Unlock()
+	if e == nil {
+		return nil
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.removed = true
+	return e.s.Stop()
+}
+
+// Latest returns a copy of the latest sample on c.
+// It returns nil when c is not tracked or no sample is taken yet.
+func (s *Scheduler) Latest(c net.Conn) *Sample {
+	s.mu.Lock()
+	e := s.conns[c]
+	s.mu.Unlock()
+	if e == nil {
+		return nil
+	}
+	smp, _ := e.latest.Load().(*Sample)
+	return smp.Clone()
+}
+
+// Close stops the scheduler and tracking all the connections.
+func (s *Scheduler) Close() {
+	s.once.Do(func() {
+		close(s.stop)
+		<-s.done
+	})
+	s.mu.Lock()
+	cs := make([]net.Conn, 0, len(s.conns))
+	for c := range s.conns {
+		cs = append(cs, c)
+	}
+	s.mu.Unlock()
+	for _, c := range cs {
+		s.Remove(c)
+	}
+}
+
+func (s *Scheduler) run() {
+	defer close(s.done)
+	t := time.NewTicker(s.tick)
+	defer t.Stop()
+	var tokens float64
+	for {
+		select {
+		case <-s.stop:
+			return
+		case now := <-t.C:
+			tokens = s.round(now, tokens)
+		}
+	}
+}
+
+// round samples the connections due at now within tokens plus the
+// budget of a tick and returns the remaining tokens.
+func (s *Scheduler) round(now time.Time, tokens float64) float64 {
+	type candidate struct {
+		e    *schedEntry
+		prio float64
+	}
+	var due []candidate
+	s.mu.Lock()
+	for _, e := range s.conns {
+		e.mu.Lock()
+		age := s.opts.Interval
+		if !e.last.IsZero() {
+			age = now.Sub(e.last)
+		}
+		if age >= s.opts.Interval-s.tick/2 {
+			due = append(due, candidate{e: e, prio: e.weight * (1 + e.activity) * float64(age) / float64(s.opts.Interval)})
+		}
+		e.mu.Unlock()
+	}
+	s.mu.Unlock()
+	n := len(due)
+	if s.opts.Budget > 0 {
+		per := float64(s.opts.Budget) * s.tick.Seconds()
+		tokens += per
+		if n > int(tokens) {
+			n = int(tokens)
+		}
+		sort.Slice(due, func(i, j int) bool { return due[i].prio > due[j].prio })
+	}
+	for _, cand := range due[:n] {
+		if cand.e.sample(now) {
+			s.mu.Lock()
+			if s.conns[cand.e.s.c] == cand.e {
+				delete(s.conns, cand.e.s.c)
+			}
+			s.mu.Unlock()
+		}
+	}
+	if s.opts.Budget > 0 {
+		if tokens -= float64(n); n == len(due) && tokens > 1 {
+			tokens = 1 // no bursts after idle rounds
+		}
+	}
+	if n < len(due) && s.opts.ShedFunc != nil {
+		s.opts.ShedFunc(&ShedReport{Time: now, Due: len(due), Sampled: n, Deferred: len(due) - n})
+	}
+	return tokens
+}
+
+// sample takes a sample and reports whether the connection is closed,
+// when the sample is the tombstone of connection.
+func (e *schedEntry) sample(now time.Time) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.removed {
+		return false
+	}
+	smp := e.s.sample(false)
+	if smp.Final {
+		e.removed = true
+		e.s.finish(smp)
+	}
+	e.activity /= 2
+	if active(e.prev, smp) {
+		e.activity++
+	}
+	e.prev, e.last = smp, now
+	e.latest.Store(smp)
+	return smp.Final
+}
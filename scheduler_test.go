@@ -0,0 +1,73 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcpinfo_test
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mikioh/tcpinfo"
+	"github.com/mikioh/tcpinfo/tcpinfotest"
+)
+
+func TestScheduler(t *testing.T) {
+	g := tcpinfotest.NewGetter()
+	var cs []net.Conn
+	for i := 0; i < 20; i++ {
+		c := tcpinfotest.NewConn("127.0.0.1:1", fmt.Sprintf("127.0.0.1:%d", 1000+i))
+		g.Set(c, tcpinfotest.NewInfo().Build())
+		cs = append(cs, c)
+	}
+	vip := cs[0]
+
+	var mu sync.Mutex
+	var shed int
+	s := tcpinfo.NewScheduler(tcpinfo.SchedulerOpts{
+		Getter:   g,
+		Interval: 10 * time.Millisecond,
+		Budget:   500,
+		Weight: func(c net.Conn) float64 {
+			if c == vip {
+				return 100
+			}
+			return 1
+		},
+		ShedFunc: func(r *tcpinfo.ShedReport) {
+			mu.Lock()
+			shed++
+			mu.Unlock()
+			if r.Sampled+r.Deferred != r.Due {
+				t.Errorf("got %+v; want consistent report", r)
+			}
+		},
+	}, nil)
+	for _, c := range cs {
+		s.Add(c)
+	}
+	time.Sleep(200 * time.Millisecond)
+	s.Close()
+
+	var total int
+	for _, c := range cs {
+		total += g.Calls(c)
+	}
+	if total > 150 {
+		t.Fatalf("got %d retrievals; want at most 150 within the budget", total)
+	}
+	if n := g.Calls(vip); n < 10 || n <= total/len(cs) {
+		t.Fatalf("got %d retrievals on weighted connection; want more than average %d", n, total/len(cs))
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if shed == 0 {
+		t.Fatal("got no shed reports; want some")
+	}
+	if s.Latest(vip) != nil {
+		t.Fatal("got non-nil; want nil for untracked connection")
+	}
+}
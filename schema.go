@@ -0,0 +1,66 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcpinfo
+
+import (
+	"encoding/json"
+	"errors"
+)
+
+// SchemaVersion is the version of the schema of serialized samples.
+// It is embedded in the lines written by JSONSink and FileSink as
+// "v", and in the protocol buffers encoding of package tcpinfopb.
+//
+// The versions are:
+//
+//	1: initial schema; serialized samples without version are of this version
+const SchemaVersion = 1
+
+// ErrUnknownSchema is returned when a serialized sample is of a newer
+// schema version than SchemaVersion.
+var ErrUnknownSchema = errors.New("unknown schema version")
+
+// jsonMigrations holds the functions upgrading a JSON object of
+// version n+1 to version n+2 at index n.
+var jsonMigrations [SchemaVersion - 1]func(map[string]json.RawMessage) error
+
+// UpgradeJSON upgrades a JSON object of a serialized sample, such as
+// a line written by JSONSink, to the schema version SchemaVersion.
+//
+// Statistics not available in the original version are null in the
+// upgraded object.
+// The object b is returned as is when it is of the current version.
+func UpgradeJSON(b []byte) ([]byte, error) {
+	return upgradeJSON(b, jsonMigrations[:])
+}
+
+// upgradeJSON upgrades the JSON object b with migrations, the last
+// version of which is len(migrations)+1.
+func upgradeJSON(b []byte, migrations []func(map[string]json.RawMessage) error) ([]byte, error) {
+	version := len(migrations) + 1
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal(b, &obj); err != nil {
+		return nil, err
+	}
+	v := 1
+	if vb, ok := obj["v"]; ok {
+		if err := json.Unmarshal(vb, &v); err != nil {
+			return nil, err
+		}
+	}
+	if v < 1 || v > version {
+		return nil, ErrUnknownSchema
+	}
+	if v == version {
+		return b, nil
+	}
+	for ; v < version; v++ {
+		if err := migrations[v-1](obj); err != nil {
+			return nil, err
+		}
+	}
+	obj["v"], _ = json.Marshal(version)
+	return json.Marshal(obj)
+}
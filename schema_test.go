@@ -0,0 +1,69 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcpinfo_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/mikioh/tcpinfo"
+)
+
+func TestUpgradeJSON(t *testing.T) {
+	var buf bytes.Buffer
+	sk := tcpinfo.NewJSONSink(&buf)
+	if err := sk.Write(tcpinfo.ConnID{Local: "192.0.2.1:443", Remote: "192.0.2.2:50000"}, &tcpinfo.Sample{Time: time.Unix(1, 0), Info: &tcpinfo.Info{RTT: time.Millisecond}}); err != nil {
+		t.Fatal(err)
+	}
+	line := bytes.TrimSpace(buf.Bytes())
+	b, err := tcpinfo.UpgradeJSON(line)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b, line) {
+		t.Fatalf("got %s; want %s", b, line)
+	}
+
+	old := []byte(`{"time":"1970-01-01T00:00:01Z","laddr":"192.0.2.1:443","stats":{"min_rtt":1000,"rexmits":0}}`)
+	if b, err := tcpinfo.UpgradeJSON(old); err != nil || !bytes.Equal(b, old) {
+		t.Fatalf("got %s, %v; want %s", b, err, old)
+	}
+
+	// The migration from version 1 to a hypothetical version 2 is
+	// defined only here.
+	migrations := []func(map[string]json.RawMessage) error{
+		func(obj map[string]json.RawMessage) error {
+			obj["added"] = json.RawMessage("null")
+			return nil
+		},
+	}
+	b, err = tcpinfo.UpgradeJSONWith(old, migrations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rec struct {
+		Version int                        `json:"v"`
+		Local   string                     `json:"laddr"`
+		Added   json.RawMessage            `json:"added"`
+		Stats   map[string]json.RawMessage `json:"stats"`
+	}
+	if err := json.Unmarshal(b, &rec); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Version != 2 || rec.Local != "192.0.2.1:443" || string(rec.Added) != "null" || string(rec.Stats["min_rtt"]) != "1000" {
+		t.Fatalf("got %s", b)
+	}
+	if _, err := tcpinfo.UpgradeJSONWith([]byte(`{"v":3}`), migrations); err != tcpinfo.ErrUnknownSchema {
+		t.Fatalf("got %v; want %v", err, tcpinfo.ErrUnknownSchema)
+	}
+
+	for _, s := range []string{`{"v":2}`, `{"v":0}`} {
+		if _, err := tcpinfo.UpgradeJSON([]byte(s)); err != tcpinfo.ErrUnknownSchema {
+			t.Fatalf("%s: got %v; want %v", s, err, tcpinfo.ErrUnknownSchema)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcpinfo
+
+import (
+	"net"
+	"sort"
+	"sync"
+)
+
+// A SelectionPolicy scores connection information for selection.
+// A lower score is better.
+// It returns false when the connection cannot be scored.
+type SelectionPolicy func(i *Info) (score float64, ok bool)
+
+var (
+	// ByRTT prefers connections with the smallest round-trip time.
+	ByRTT SelectionPolicy = func(i *Info) (float64, bool) {
+		return float64(i.RTT), i.RTT > 0
+	}
+
+	// ByDeliveryRate prefers connections with the highest delivery
+	// rate.
+	ByDeliveryRate SelectionPolicy = func(i *Info) (float64, bool) {
+		ds := i.Stats()
+		return -float64(ds.DeliveryRate), ds.Valid("delivery_rate")
+	}
+
+	// ByLoss prefers connections with the smallest ratio of
+	// retransmitted segments to sent segments.
+	ByLoss SelectionPolicy = func(i *Info) (float64, bool) {
+		ds := i.Stats()
+		if !ds.Valid("retrans_segs") || !ds.Valid("segs_sent") {
+			return 0, false
+		}
+		if ds.SegsSent == 0 {
+			return 0, true
+		}
+		return float64(ds.RetransSegs) / float64(ds.SegsSent), true
+	}
+)
+
+// A Selector ranks a set of connections, such as connections to
+// upstream servers, by the latest samples taken by a monitor.
+//
+// Only established connections scored by the policy are candidates;
+// connections without samples, failing retrieval or in other states
+// are never selected.
+type Selector struct {
+	m *Monitor
+	p SelectionPolicy
+
+	mu    sync.Mutex
+	conns []net.Conn
+}
+
+// NewSelector returns a new selector that ranks connections tracked
+// by m with the policy p.
+func NewSelector(m *Monitor, p SelectionPolicy) *Selector {
+	return &Selector{m: m, p: p}
+}
+
+// Add adds c to the set of connections and starts tracking it with
+// the monitor.
+// It does nothing when c is already in the set.
+func (s *Selector) Add(c net.Conn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, cc := range s.conns {
+		if cc == c {
+			return
+		}
+	}
+	s.conns = append(s.conns, c)
+	s.m.Add(c)
+}
+
+// Remove removes c from the set of connections and stops tracking it
+// as Monitor.Remove does.
+func (s *Selector) Remove(c net.Conn) *FinalStats {
+	s.mu.Lock()
+	for j, cc := range s.conns {
+		if cc == c {
+			s.conns = append(s.conns[:j], s.conns[j+1:]...)
+			break
+		}
+	}
+	s.mu.Unlock()
+	return s.m.Remove(c)
+}
+
+// Rank returns the candidate connections, best first.
+// Ties are broken by the order of addition.
+func (s *Selector) Rank() []net.Conn {
+	s.mu.Lock()
+	conns := make([]net.Conn, len(s.conns))
+	copy(conns, s.conns)
+	s.mu.Unlock()
+	type candidate struct {
+		c     net.Conn
+		score float64
+	}
+	cands := make([]candidate, 0, len(conns))
+	for _, c := range conns {
+		smp := s.m.Latest(c)
+		if smp == nil || smp.Info == nil || smp.Info.State != Established {
+			continue
+		}
+		if score, ok := s.p(smp.Info); ok {
+			cands = append(cands, candidate{c: c, score: score})
+		}
+	}
+	sort.SliceStable(cands, func(i, j int) bool { return cands[i].score < cands[j].score })
+	ranked := make([]net.Conn, len(cands))
+	for j := range cands {
+		ranked[j] = cands[j].c
+	}
+	return ranked
+}
+
+// Best returns the best candidate connection.
+// It returns nil when there is no candidate.
+func (s *Selector) Best() net.Conn {
+	if cs := s.Rank(); len(cs) > 0 {
+		return cs[0]
+	}
+	return nil
+}
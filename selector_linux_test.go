@@ -0,0 +1,44 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcpinfo_test
+
+import (
+	"testing"
+
+	"github.com/mikioh/tcpinfo"
+	"github.com/mikioh/tcpinfo/tcpinfotest"
+)
+
+func TestSelectionPolicies(t *testing.T) {
+	fast := tcpinfotest.NewInfo().Sys(func(si *tcpinfo.SysInfo) {
+		si.DeliveryRate, si.SegsOut, si.TotalRetransSegs = 1e6, 1000, 50
+	}).Build()
+	slow := tcpinfotest.NewInfo().Sys(func(si *tcpinfo.SysInfo) {
+		si.DeliveryRate, si.SegsOut, si.TotalRetransSegs = 1e5, 1000, 1
+	}).Build()
+	for _, tt := range []struct {
+		p    tcpinfo.SelectionPolicy
+		best *tcpinfo.Info
+	}{
+		{tcpinfo.ByDeliveryRate, fast},
+		{tcpinfo.ByLoss, slow},
+	} {
+		a, aok := tt.p(fast)
+		b, bok := tt.p(slow)
+		if !aok || !bok {
+			t.Fatalf("got %v, %v; want scored", aok, bok)
+		}
+		best := fast
+		if b < a {
+			best = slow
+		}
+		if best != tt.best {
+			t.Fatalf("got scores %v, %v", a, b)
+		}
+	}
+	if _, ok := tcpinfo.ByLoss(&tcpinfo.Info{}); ok {
+		t.Fatal("got scored; want not scored without platform-specific information")
+	}
+}
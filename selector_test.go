@@ -0,0 +1,55 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcpinfo_test
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/mikioh/tcpinfo"
+	"github.com/mikioh/tcpinfo/tcpinfotest"
+)
+
+func TestSelector(t *testing.T) {
+	c1 := tcpinfotest.NewConn("192.0.2.1:50001", "192.0.2.11:443")
+	c2 := tcpinfotest.NewConn("192.0.2.1:50002", "192.0.2.12:443")
+	c3 := tcpinfotest.NewConn("192.0.2.1:50003", "192.0.2.13:443")
+	c4 := tcpinfotest.NewConn("192.0.2.1:50004", "192.0.2.14:443")
+	g := tcpinfotest.NewGetter()
+	g.Set(c1, tcpinfotest.NewInfo().RTT(30*time.Millisecond, 0).Build())
+	g.Set(c2, tcpinfotest.NewInfo().RTT(10*time.Millisecond, 0).Build())
+	g.Set(c3, tcpinfotest.NewInfo().RTT(5*time.Millisecond, 0).State(tcpinfo.CloseWait).Build())
+	g.Script(c4, tcpinfotest.Step{Err: errors.New("gone")})
+
+	m := tcpinfo.NewMonitorWithGetter(g, time.Millisecond, nil)
+	defer m.Close()
+	s := tcpinfo.NewSelector(m, tcpinfo.ByRTT)
+	if c := s.Best(); c != nil {
+		t.Fatalf("got %v; want nil", c)
+	}
+	for _, c := range []net.Conn{c1, c2, c3, c4, c1} {
+		s.Add(c)
+	}
+	for _, c := range []net.Conn{c1, c2, c3, c4} {
+		for m.Latest(c) == nil {
+			time.Sleep(time.Millisecond)
+		}
+	}
+	if cs := s.Rank(); len(cs) != 2 || cs[0] != c2 || cs[1] != c1 {
+		t.Fatalf("got %v; want [%v %v]", cs, c2, c1)
+	}
+	if fs := s.Remove(c2); fs == nil {
+		t.Fatal("got nil; want final stats")
+	}
+	if c := s.Best(); c != c1 {
+		t.Fatalf("got %v; want %v", c, c1)
+	}
+	if len(m.Conns()) != 3 {
+		t.Fatalf("got %d tracked connections; want 3", len(m.Conns()))
+	}
+
+}
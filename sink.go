@@ -0,0 +1,237 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcpinfo
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// A ConnID identifies a connection by its local and remote
+// addresses.
+type ConnID struct {
+	Local  string `json:"laddr"`
+	Remote string `json:"raddr"`
+}
+
+// ConnIDOf returns the identifier of c.
+func ConnIDOf(c net.Conn) ConnID {
+	return ConnID{Local: addrString(c.LocalAddr()), Remote: addrString(c.RemoteAddr())}
+}
+
+func (id ConnID) String() string { return id.Local + "->" + id.Remote }
+
+// A Sink represents a destination of samples of connection
+// information.
+type Sink interface {
+	// Write writes the sample s on the connection id.
+	Write(id ConnID, s *Sample) error
+}
+
+// A SinkFunc is an adapter to allow the use of ordinary functions as
+// sinks.
+type SinkFunc func(id ConnID, s *Sample) error
+
+// Write implements the Write method of Sink interface.
+func (fn SinkFunc) Write(id ConnID, s *Sample) error { return fn(id, s) }
+
+// MultiSink returns a sink that writes samples to all the sinks.
+// It writes to all the sinks even when some of them fail, and returns
+// the first error.
+func MultiSink(sinks ...Sink) Sink {
+	return SinkFunc(func(id ConnID, s *Sample) error {
+		var first error
+		for _, sk := range sinks {
+			if err := sk.Write(id, s); err != nil && first == nil {
+				first = err
+			}
+		}
+		return first
+	})
+}
+
+// SinkSampleFunc returns a SampleFunc that writes samples to sk, for
+// use with Sampler, Monitor and others taking a SampleFunc.
+// The function errfn, which may be nil, receives errors on writing.
+func SinkSampleFunc(sk Sink, errfn func(id ConnID, err error)) SampleFunc {
+	return func(c net.Conn, s *Sample) {
+		id := ConnIDOf(c)
+		if err := sk.Write(id, s); err != nil && errfn != nil {
+			errfn(id, err)
+		}
+	}
+}
+
+// A SinkRecord represents a sample written to a sink.
+type SinkRecord struct {
+	ID     ConnID
+	Sample *Sample
+}
+
+// A RingSink is an in-memory sink keeping the latest samples.
+type RingSink struct {
+	mu   sync.Mutex
+	recs []SinkRecord
+	next int
+	full bool
+}
+
+// NewRingSink returns a new ring sink that keeps the latest n
+// samples.
+func NewRingSink(n int) *RingSink {
+	return &RingSink{recs: make([]SinkRecord, n)}
+}
+
+// Write implements the Write method of Sink interface.
+func (r *RingSink) Write(id ConnID, s *Sample) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.recs) == 0 {
+		return nil
+	}
+	r.recs[r.next] = SinkRecord{ID: id, Sample: s}
+	r.next++
+	if r.next == len(r.recs) {
+		r.next, r.full = 0, true
+	}
+	return nil
+}
+
+// Records returns the kept samples, oldest first.
+func (r *RingSink) Records() []SinkRecord {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.full {
+		return append([]SinkRecord(nil), r.recs[:r.next]...)
+	}
+	return append(append([]SinkRecord(nil), r.recs[r.next:]...), r.recs[:r.next]...)
+}
+
+// A ChanSink is a sink sending samples to a channel.
+//
+// A sample is dropped with ErrSinkFull when the channel is not ready
+// to receive it.
+type ChanSink chan<- SinkRecord
+
+// Write implements the Write method of Sink interface.
+func (ch ChanSink) Write(id ConnID, s *Sample) error {
+	select {
+	case ch <- SinkRecord{ID: id, Sample: s}:
+		return nil
+	default:
+		return ErrSinkFull
+	}
+}
+
+// ErrSinkFull is returned when a sink cannot take a sample without
+// blocking.
+var ErrSinkFull = errors.New("sink full")
+
+// A JSONSink is a sink writing samples as newline-delimited JSON.
+//
+// Each line is an object of the schema version, the time, local and
+// remote addresses, the final flag, the error, connection information
+// and derived statistics of a sample.
+type JSONSink struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewJSONSink returns a new JSON sink writing to w.
+func NewJSONSink(w io.Writer) *JSONSink {
+	return &JSONSink{w: w}
+}
+
+// A jsonRecord represents a line written by JSONSink.
+type jsonRecord struct {
+	Version int           `json:"v"`
+	Time    time.Time     `json:"time"`
+	Local   string        `json:"laddr"`
+	Remote  string        `json:"raddr"`
+	Final   bool          `json:"final,omitempty"`
+	Err     string        `json:"error,omitempty"`
+	Info    *Info         `json:"info,omitempty"`
+	Stats   *DerivedStats `json:"stats,omitempty"`
+}
+
+// Write implements the Write method of Sink interface.
+func (js *JSONSink) Write(id ConnID, s *Sample) error {
+	b, err := marshalJSONRecord(id, s)
+	if err != nil {
+		return err
+	}
+	js.mu.Lock()
+	defer js.mu.Unlock()
+	_, err = js.w.Write(b)
+	return err
+}
+
+// marshalJSONRecord returns a line of newline-delimited JSON for the
+// sample s on the connection id.
+func marshalJSONRecord(id ConnID, s *Sample) ([]byte, error) {
+	rec := jsonRecord{Version: SchemaVersion, Time: s.Time, Local: id.Local, Remote: id.Remote, Final: s.Final, Info: s.Info}
+	if s.Err != nil {
+		rec.Err = s.Err.Error()
+	}
+	if s.Info != nil {
+		rec.Stats = s.Info.Stats()
+	}
+	b, err := json.Marshal(&rec)
+	if err != nil {
+		return nil, err
+	}
+	return append(b, '\n'), nil
+}
+
+// ObserverSink returns a sink that feeds samples to fn, such as the
+// Observe method of metrics bridges.
+//
+// The function fn receives a connection value standing for each
+// connection identifier, which only implements the LocalAddr and
+// RemoteAddr methods meaningfully; the value is stable until the
+// final sample on the connection.
+func ObserverSink(fn SampleFunc) Sink {
+	var mu sync.Mutex
+	conns := make(map[ConnID]*idConn)
+	return SinkFunc(func(id ConnID, s *Sample) error {
+		mu.Lock()
+		c := conns[id]
+		if c == nil {
+			c = &idConn{id: id}
+			conns[id] = c
+		}
+		if s.Final {
+			delete(conns, id)
+		}
+		mu.Unlock()
+		fn(c, s)
+		return nil
+	})
+}
+
+// An idConn represents a connection identified by a ConnID.
+type idConn struct {
+	id ConnID
+}
+
+// An idAddr represents an address of a connection identified by a
+// ConnID.
+type idAddr string
+
+func (a idAddr) Network() string { return "tcp" }
+func (a idAddr) String() string  { return string(a) }
+
+func (c *idConn) Read([]byte) (int, error)         { return 0, ErrNotSupported }
+func (c *idConn) Write([]byte) (int, error)        { return 0, ErrNotSupported }
+func (c *idConn) Close() error                     { return nil }
+func (c *idConn) LocalAddr() net.Addr              { return idAddr(c.id.Local) }
+func (c *idConn) RemoteAddr() net.Addr             { return idAddr(c.id.Remote) }
+func (c *idConn) SetDeadline(time.Time) error      { return ErrNotSupported }
+func (c *idConn) SetReadDeadline(time.Time) error  { return ErrNotSupported }
+func (c *idConn) SetWriteDeadline(time.Time) error { return ErrNotSupported }
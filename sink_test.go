@@ -0,0 +1,77 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcpinfo_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/mikioh/tcpinfo"
+	"github.com/mikioh/tcpinfo/tcpinfotest"
+)
+
+func TestSinks(t *testing.T) {
+	c := tcpinfotest.NewConn("192.0.2.1:443", "192.0.2.2:50000")
+	id := tcpinfo.ConnIDOf(c)
+	if id.Local != "192.0.2.1:443" || id.Remote != "192.0.2.2:50000" || id.String() != "192.0.2.1:443->192.0.2.2:50000" {
+		t.Fatalf("got %v", id)
+	}
+
+	ring := tcpinfo.NewRingSink(2)
+	ch := make(chan tcpinfo.SinkRecord, 1)
+	var js bytes.Buffer
+	var observed []net.Conn
+	obs := tcpinfo.ObserverSink(func(c net.Conn, s *tcpinfo.Sample) { observed = append(observed, c) })
+	var errs []error
+	fn := tcpinfo.SinkSampleFunc(tcpinfo.MultiSink(ring, tcpinfo.ChanSink(ch), tcpinfo.NewJSONSink(&js), obs), func(eid tcpinfo.ConnID, err error) {
+		if eid != id {
+			t.Errorf("got %v; want %v", eid, id)
+		}
+		errs = append(errs, err)
+	})
+	start := time.Unix(1, 0).UTC()
+	for j := 0; j < 3; j++ {
+		s := &tcpinfo.Sample{Time: start.Add(time.Duration(j) * time.Second), Info: tcpinfotest.NewInfo().Build(), Final: j == 2}
+		if j == 1 {
+			s.Info, s.Err = nil, errors.New("gone")
+		}
+		fn(c, s)
+	}
+
+	if recs := ring.Records(); len(recs) != 2 || recs[0].ID != id || !recs[0].Sample.Time.Equal(start.Add(time.Second)) || !recs[1].Sample.Final {
+		t.Fatalf("got %+v", recs)
+	}
+	if rec := <-ch; !rec.Sample.Time.Equal(start) {
+		t.Fatalf("got %+v", rec)
+	}
+	if len(errs) != 2 || errs[0] != tcpinfo.ErrSinkFull {
+		t.Fatalf("got %v; want 2 ErrSinkFull", errs)
+	}
+	if len(observed) != 3 || observed[0] != observed[2] || observed[0].RemoteAddr().String() != id.Remote {
+		t.Fatalf("got %v", observed)
+	}
+
+	dec := json.NewDecoder(&js)
+	for j := 0; j < 3; j++ {
+		var rec struct {
+			Time  time.Time       `json:"time"`
+			Local string          `json:"laddr"`
+			Final bool            `json:"final"`
+			Err   string          `json:"error"`
+			Info  json.RawMessage `json:"info"`
+			Stats json.RawMessage `json:"stats"`
+		}
+		if err := dec.Decode(&rec); err != nil {
+			t.Fatal(err)
+		}
+		if !rec.Time.Equal(start.Add(time.Duration(j)*time.Second)) || rec.Local != id.Local || rec.Final != (j == 2) || (rec.Err != "") != (j == 1) || (rec.Info == nil) != (j == 1) {
+			t.Fatalf("#%d: got %+v", j, rec)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build go1.21
+
+package tcpinfo
+
+import (
+	"log/slog"
+	"reflect"
+	"strings"
+	"time"
+)
+
+var (
+	_ slog.LogValuer = &Info{}
+	_ slog.LogValuer = &DerivedStats{}
+	_ slog.LogValuer = &Delta{}
+	_ slog.LogValuer = &FinalStats{}
+)
+
+// LogValue implements the LogValue method of slog.LogValuer
+// interface.
+func (i *Info) LogValue() slog.Value {
+	attrs := []slog.Attr{slog.String("state", i.State.String())}
+	if len(i.Options) > 0 {
+		attrs = append(attrs, slog.Attr{Key: "opts", Value: optionsValue(i.Options)})
+	}
+	if len(i.PeerOptions) > 0 {
+		attrs = append(attrs, slog.Attr{Key: "peer_opts", Value: optionsValue(i.PeerOptions)})
+	}
+	attrs = append(attrs,
+		slog.Any("snd_mss", i.SenderMSS),
+		slog.Any("rcv_mss", i.ReceiverMSS),
+		slog.Duration("rtt", i.RTT),
+		slog.Duration("rttvar", i.RTTVar),
+		slog.Duration("rto", i.RTO),
+		slog.Duration("ato", i.ATO),
+		slog.Duration("last_data_sent", i.LastDataSent),
+		slog.Duration("last_data_rcvd", i.LastDataReceived),
+		slog.Duration("last_ack_rcvd", i.LastAckReceived),
+	)
+	if i.FlowControl != nil {
+		attrs = append(attrs, slog.Attr{Key: "flow_ctl", Value: structValue(i.FlowControl)})
+	}
+	if i.CongestionControl != nil {
+		attrs = append(attrs, slog.Attr{Key: "cong_ctl", Value: structValue(i.CongestionControl)})
+	}
+	if i.Queue != nil {
+		attrs = append(attrs, slog.Attr{Key: "queue", Value: structValue(i.Queue)})
+	}
+	if i.Sys != nil {
+		attrs = append(attrs, slog.Attr{Key: "sys", Value: structValue(i.Sys)})
+	}
+	return slog.GroupValue(attrs...)
+}
+
+// LogValue implements the LogValue method of slog.LogValuer
+// interface.
+func (ds *DerivedStats) LogValue() slog.Value { return structValue(ds) }
+
+// LogValue implements the LogValue method of slog.LogValuer
+// interface.
+func (d *Delta) LogValue() slog.Value { return structValue(d) }
+
+// LogValue implements the LogValue method of slog.LogValuer
+// interface.
+func (fs *FinalStats) LogValue() slog.Value {
+	var attrs []slog.Attr
+	if fs.Info != nil {
+		attrs = append(attrs, slog.Attr{Key: "info", Value: fs.Info.LogValue()})
+	}
+	if fs.Err != nil {
+		attrs = append(attrs, slog.String("err", fs.Err.Error()))
+	}
+	attrs = append(attrs,
+		slog.Duration("duration", fs.Duration),
+		slog.Duration("min_rtt", fs.MinRTT),
+	)
+	if fs.Stats != nil {
+		attrs = append(attrs, slog.Attr{Key: "stats", Value: fs.Stats.LogValue()})
+	}
+	return slog.GroupValue(attrs...)
+}
+
+func optionsValue(opts []Option) slog.Value {
+	attrs := make([]slog.Attr, 0, len(opts))
+	for _, opt := range opts {
+		attrs = append(attrs, slog.Any(opt.Kind().String(), opt))
+	}
+	return slog.GroupValue(attrs...)
+}
+
+// structValue returns a group value of the exported fields of the
+// struct pointed to by p, keyed by their JSON names.
+func structValue(p interface{}) slog.Value {
+	v := reflect.ValueOf(p).Elem()
+	t := v.Type()
+	attrs := make([]slog.Attr, 0, t.NumField())
+	for j := 0; j < t.NumField(); j++ {
+		f := t.Field(j)
+		key := strings.Split(f.Tag.Get("json"), ",")[0]
+		if key == "" || key == "-" {
+			continue
+		}
+		fv := v.Field(j)
+		switch {
+		case fv.Type() == durationType:
+			attrs = append(attrs, slog.Duration(key, time.Duration(fv.Int())))
+		case fv.Kind() == reflect.Bool:
+			attrs = append(attrs, slog.Bool(key, fv.Bool()))
+		case fv.CanUint():
+			attrs = append(attrs, slog.Uint64(key, fv.Uint()))
+		case fv.CanInt():
+			attrs = append(attrs, slog.Int64(key, fv.Int()))
+		default:
+			attrs = append(attrs, slog.Any(key, fv.Interface()))
+		}
+	}
+	return slog.GroupValue(attrs...)
+}
@@ -0,0 +1,56 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build go1.21
+
+package tcpinfo_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/mikioh/tcpinfo"
+)
+
+func TestInfoLogValue(t *testing.T) {
+	i := &tcpinfo.Info{
+		State:             tcpinfo.Established,
+		Options:           []tcpinfo.Option{tcpinfo.WindowScale(7), tcpinfo.SACKPermitted(true)},
+		RTT:               2 * time.Millisecond,
+		CongestionControl: &tcpinfo.CongestionControl{SenderWindowSegs: 10},
+		Queue:             &tcpinfo.Queue{Send: 4096},
+	}
+	var buf bytes.Buffer
+	slog.New(slog.NewJSONHandler(&buf, nil)).Info("conn stats", "tcp", i)
+	var m struct {
+		TCP struct {
+			State   string                 `json:"state"`
+			Opts    map[string]interface{} `json:"opts"`
+			RTT     int64                  `json:"rtt"`
+			CongCtl map[string]interface{} `json:"cong_ctl"`
+			Queue   map[string]interface{} `json:"queue"`
+		} `json:"tcp"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &m); err != nil {
+		t.Fatal(err)
+	}
+	if m.TCP.State != "established" {
+		t.Fatalf("got %q; want established", m.TCP.State)
+	}
+	if m.TCP.RTT != int64(2*time.Millisecond) {
+		t.Fatalf("got %v; want %v", m.TCP.RTT, int64(2*time.Millisecond))
+	}
+	if m.TCP.Opts["wscale"] != float64(7) || m.TCP.Opts["sack"] != true {
+		t.Fatalf("got %v", m.TCP.Opts)
+	}
+	if m.TCP.CongCtl["snd_cwnd_segs"] != float64(10) {
+		t.Fatalf("got %v", m.TCP.CongCtl)
+	}
+	if m.TCP.Queue["snd_queue"] != float64(4096) || m.TCP.Queue["rcv_queue"] != float64(0) {
+		t.Fatalf("got %v", m.TCP.Queue)
+	}
+}
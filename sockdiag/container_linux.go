@@ -0,0 +1,100 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sockdiag
+
+import (
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var errAmbiguousContainer = errors.New("ambiguous container ID")
+
+// containerIDPattern matches the ID of container in cgroup paths,
+// such as:
+//
+//	/docker/<id>
+//	/system.slice/docker-<id>.scope
+//	/kubepods.slice/.../cri-containerd-<id>.scope
+var containerIDPattern = regexp.MustCompile(`[0-9a-f]{64}`)
+
+// FindContainer returns the container with the ID id, which may be
+// a unique prefix of the full ID, such as the short ID shown by
+// Docker.
+//
+// The container is found by scanning the cgroups of processes under
+// /proc, so no connection to the container runtime is required.
+func FindContainer(id string) (*Container, error) {
+	if id == "" {
+		return nil, errNotFound
+	}
+	pids, err := ioutil.ReadDir(procDir)
+	if err != nil {
+		return nil, err
+	}
+	var c *Container
+	for _, fi := range pids {
+		pid, err := strconv.Atoi(fi.Name())
+		if err != nil {
+			continue
+		}
+		b, err := ioutil.ReadFile(filepath.Join(procDir, fi.Name(), "cgroup"))
+		if err != nil {
+			continue
+		}
+		for _, full := range containerIDPattern.FindAllString(string(b), -1) {
+			if !strings.HasPrefix(full, id) {
+				continue
+			}
+			if c != nil && c.ID != full {
+				return nil, errAmbiguousContainer
+			}
+			if c == nil || pid < c.PID {
+				c = &Container{ID: full, PID: pid}
+			}
+			break
+		}
+	}
+	if c == nil {
+		return nil, errNotFound
+	}
+	c.NetNS = filepath.Join(procDir, strconv.Itoa(c.PID), "ns", "net")
+	return c, nil
+}
+
+// ListContainerConnections returns information on all TCP sockets in
+// the network namespace of the container with the ID id that match
+// the filter f, labeled with the container.
+// A nil filter matches all TCP sockets.
+//
+// When the network namespace cannot be entered, it falls back to
+// parsing /proc/<pid>/net/tcp and /proc/<pid>/net/tcp6 of a process
+// in the container, which gives no Info.
+func ListContainerConnections(id string, f *Filter) ([]ConnInfo, error) {
+	ctr, err := FindContainer(id)
+	if err != nil {
+		return nil, err
+	}
+	var cis []ConnInfo
+	if c, err := DialNetNS(ctr.NetNS); err == nil {
+		cis, err = c.ListConnections(f)
+		c.Close()
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		cis, err = listProcConnections(filepath.Join(procDir, strconv.Itoa(ctr.PID), "net"), f)
+		if err != nil {
+			return nil, err
+		}
+	}
+	for j := range cis {
+		cis[j].Container = ctr
+	}
+	return cis, nil
+}
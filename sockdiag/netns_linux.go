@@ -0,0 +1,132 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sockdiag
+
+import (
+	"os"
+	"runtime"
+	"strconv"
+	"syscall"
+)
+
+// DialNetNS is like Dial but opens the connection in the network
+// namespace referred to by the file path, such as /proc/<pid>/ns/net
+// or /run/netns/<name>.
+//
+// The connection stays in the namespace once opened, and inspects
+// the TCP sockets of the namespace.
+// It requires the CAP_SYS_ADMIN capability.
+func DialNetNS(path string) (*Conn, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return DialNetNSFd(int(f.Fd()))
+}
+
+// DialNetNSFd is like DialNetNS but takes a file descriptor referring
+// to the network namespace.
+func DialNetNSFd(fd int) (*Conn, error) {
+	var c *Conn
+	err := inNetNS(fd, func() error {
+		var err error
+		c, err = Dial()
+		return err
+	})
+	if err != nil {
+		if c != nil {
+			c.Close()
+		}
+		return nil, err
+	}
+	return c, nil
+}
+
+// SubscribeNetNS is like Subscribe but subscribes to notifications in
+// the network namespace referred to by the file path.
+func SubscribeNetNS(path string) (*Subscription, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var s *Subscription
+	err = inNetNS(int(f.Fd()), func() error {
+		var err error
+		s, err = Subscribe()
+		return err
+	})
+	if err != nil {
+		if s != nil {
+			s.Close()
+		}
+		return nil, err
+	}
+	return s, nil
+}
+
+// ListProcConnectionsNetNS is like ListProcConnections but lists the
+// TCP sockets in the network namespace referred to by the file path.
+func ListProcConnectionsNetNS(path string, f *Filter) ([]ConnInfo, error) {
+	nf, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer nf.Close()
+	var cis []ConnInfo
+	err = inNetNS(int(nf.Fd()), func() error {
+		var err error
+		cis, err = listProcConnections("/proc/self/task/"+strconv.Itoa(syscall.Gettid())+"/net", f)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return cis, nil
+}
+
+// inNetNS invokes fn on a thread switched to the network namespace
+// referred to by fd.
+//
+// The switch is made on a dedicated goroutine locked to its thread.
+// When the thread cannot switch back to the original namespace, it
+// is left locked so that the runtime discards it instead of
+// scheduling other goroutines on it.
+// The error on switching back is returned even when fn succeeds, in
+// which case the caller must release what fn created.
+func inNetNS(fd int, fn func() error) error {
+	ch := make(chan error, 1)
+	go func() {
+		runtime.LockOSThread()
+		cur, err := os.Open("/proc/self/task/" + strconv.Itoa(syscall.Gettid()) + "/ns/net")
+		if err != nil {
+			runtime.UnlockOSThread()
+			ch <- err
+			return
+		}
+		defer cur.Close()
+		if err := setns(fd); err != nil {
+			runtime.UnlockOSThread()
+			ch <- err
+			return
+		}
+		err = fn()
+		if rerr := setns(int(cur.Fd())); rerr != nil {
+			ch <- rerr
+			return
+		}
+		runtime.UnlockOSThread()
+		ch <- err
+	}()
+	return <-ch
+}
+
+func setns(fd int) error {
+	if _, _, errno := syscall.RawSyscall(sysSETNS, uintptr(fd), syscall.CLONE_NEWNET, 0); errno != 0 {
+		return os.NewSyscallError("setns", errno)
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sockdiag
+
+import (
+	"bufio"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+const (
+	procDir   = "/proc"
+	cgroupDir = "/sys/fs/cgroup"
+)
+
+// Attribute fills in the processes holding the sockets of cis and
+// the cgroup paths of the sockets, such as for telling which service
+// owns a socket.
+//
+// Processes are found by scanning the file descriptors of all
+// processes under /proc, which requires privileges to inspect
+// processes of other users; those not permitted are skipped.
+// The cgroup path of a socket is resolved from its cgroup ID when
+// reported, and from the cgroup of the first process otherwise.
+func Attribute(cis []ConnInfo) error {
+	if len(cis) == 0 {
+		return nil
+	}
+	want := make(map[uint32][]int, len(cis))
+	for j := range cis {
+		if cis[j].Inode != 0 {
+			want[cis[j].Inode] = append(want[cis[j].Inode], j)
+		}
+	}
+	pids, err := ioutil.ReadDir(procDir)
+	if err != nil {
+		return err
+	}
+	for _, fi := range pids {
+		pid, err := strconv.Atoi(fi.Name())
+		if err != nil {
+			continue
+		}
+		var p *Process
+		for _, ino := range socketInodes(pid) {
+			js, ok := want[ino]
+			if !ok {
+				continue
+			}
+			if p == nil {
+				p = readProcess(pid)
+			}
+			for _, j := range js {
+				cis[j].Processes = append(cis[j].Processes, *p)
+			}
+		}
+	}
+	var cgroups map[uint64]string
+	for j := range cis {
+		ci := &cis[j]
+		if ci.CgroupID != 0 {
+			if cgroups == nil {
+				cgroups = cgroupPaths()
+			}
+			if path, ok := cgroups[ci.CgroupID]; ok {
+				ci.Cgroup = path
+				continue
+			}
+		}
+		if len(ci.Processes) > 0 {
+			ci.Cgroup = ci.Processes[0].Cgroup
+		}
+	}
+	return nil
+}
+
+// socketInodes returns the inode numbers of the sockets held by the
+// process pid.
+func socketInodes(pid int) []uint32 {
+	dir := filepath.Join(procDir, strconv.Itoa(pid), "fd")
+	f, err := os.Open(dir)
+	if err != nil {
+		return nil
+	}
+	names, err := f.Readdirnames(-1)
+	f.Close()
+	if err != nil {
+		return nil
+	}
+	var inos []uint32
+	for _, name := range names {
+		// The link of a socket reads such as "socket:[12345]".
+		s, err := os.Readlink(filepath.Join(dir, name))
+		if err != nil || !strings.HasPrefix(s, "socket:[") || !strings.HasSuffix(s, "]") {
+			continue
+		}
+		ino, err := strconv.ParseUint(s[len("socket:["):len(s)-1], 10, 32)
+		if err != nil {
+			continue
+		}
+		inos = append(inos, uint32(ino))
+	}
+	return inos
+}
+
+func readProcess(pid int) *Process {
+	dir := filepath.Join(procDir, strconv.Itoa(pid))
+	p := &Process{PID: pid}
+	if b, err := ioutil.ReadFile(filepath.Join(dir, "comm")); err == nil {
+		p.Name = strings.TrimSpace(string(b))
+	}
+	if f, err := os.Open(filepath.Join(dir, "cgroup")); err == nil {
+		p.Cgroup = parseProcCgroup(f)
+		f.Close()
+	}
+	return p
+}
+
+// parseProcCgroup returns the cgroup v2 path in the content of
+// /proc/<pid>/cgroup, which has the line such as:
+//
+//	0::/system.slice/nginx.service
+func parseProcCgroup(r io.Reader) string {
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		if path := strings.TrimPrefix(s.Text(), "0::"); path != s.Text() {
+			return path
+		}
+	}
+	return ""
+}
+
+// cgroupPaths returns the paths of cgroup v2 hierarchy keyed by
+// cgroup ID, which is the inode number of cgroup directory.
+func cgroupPaths() map[uint64]string {
+	m := make(map[uint64]string)
+	filepath.Walk(cgroupDir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil || !fi.IsDir() {
+			return nil
+		}
+		st, ok := fi.Sys().(*syscall.Stat_t)
+		if !ok {
+			return nil
+		}
+		rel := strings.TrimPrefix(path, cgroupDir)
+		if rel == "" {
+			rel = "/"
+		}
+		m[uint64(st.Ino)] = rel
+		return nil
+	})
+	return m
+}
@@ -0,0 +1,180 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sockdiag
+
+import (
+	"bufio"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// userHZ is the unit of clock ticks reported in /proc/net/tcp.
+const userHZ = 100
+
+// ListProcConnections returns information on all TCP sockets on the
+// host that match the filter f by parsing /proc/net/tcp and
+// /proc/net/tcp6.
+// A nil filter matches all TCP sockets.
+//
+// It is useful in environments where the sock_diag interface is not
+// available, such as restricted containers and old kernels.
+// The returned information has no Info, CCAlgo, CgroupID and
+// MemInfo.
+func ListProcConnections(f *Filter) ([]ConnInfo, error) {
+	return listProcConnections("/proc/net", f)
+}
+
+// listProcConnections is like ListProcConnections but parses the
+// files in dir.
+func listProcConnections(dir string, f *Filter) ([]ConnInfo, error) {
+	files := []struct {
+		family int
+		name   string
+	}{
+		{syscall.AF_INET, dir + "/tcp"},
+		{syscall.AF_INET6, dir + "/tcp6"},
+	}
+	var cis []ConnInfo
+	for _, file := range files {
+		if f != nil && f.Family != 0 && f.Family != file.family {
+			continue
+		}
+		r, err := os.Open(file.name)
+		if os.IsNotExist(err) && file.family == syscall.AF_INET6 {
+			continue // IPv6 disabled
+		}
+		if err != nil {
+			return nil, err
+		}
+		err = parseProcNet(r, func(ci *ConnInfo) {
+			if f.match(ci) {
+				cis = append(cis, *ci)
+			}
+		})
+		r.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return cis, nil
+}
+
+var errMalformedProcNet = errors.New("malformed /proc/net/tcp entry")
+
+func parseProcNet(r io.Reader, fn func(*ConnInfo)) error {
+	s := bufio.NewScanner(r)
+	s.Scan() // header
+	for s.Scan() {
+		ci, err := parseProcNetEntry(strings.Fields(s.Text()))
+		if err != nil {
+			return err
+		}
+		fn(ci)
+	}
+	return s.Err()
+}
+
+// parseProcNetEntry parses the fields of an entry such as:
+//
+//	0: 0100007F:0277 00000000:0000 0A 00000000:00000000 00:00000000 00000000 0 0 12345 ...
+func parseProcNetEntry(fs []string) (*ConnInfo, error) {
+	if len(fs) < 10 {
+		return nil, errMalformedProcNet
+	}
+	var ci ConnInfo
+	var err error
+	if ci.LocalAddr, err = parseProcNetAddr(fs[1]); err != nil {
+		return nil, err
+	}
+	if ci.RemoteAddr, err = parseProcNetAddr(fs[2]); err != nil {
+		return nil, err
+	}
+	st, err := strconv.ParseUint(fs[3], 16, 8)
+	if err != nil {
+		return nil, errMalformedProcNet
+	}
+	if int(st) < len(sysStates) {
+		ci.State = sysStates[st]
+	}
+	tx, rx, ok := splitPair(fs[4])
+	if !ok {
+		return nil, errMalformedProcNet
+	}
+	ci.SendQueue, ci.RecvQueue = uint(tx), uint(rx)
+	tr, when, ok := splitPair(fs[5])
+	if !ok {
+		return nil, errMalformedProcNet
+	}
+	ci.Timer = Timer(tr)
+	ci.TimerExpires = time.Duration(when) * time.Second / userHZ
+	retrans, err := strconv.ParseUint(fs[6], 16, 32)
+	if err != nil {
+		return nil, errMalformedProcNet
+	}
+	ci.Retransmits = uint(retrans)
+	uid, err := strconv.ParseUint(fs[7], 10, 32)
+	if err != nil {
+		return nil, errMalformedProcNet
+	}
+	ci.UID = uint32(uid)
+	ino, err := strconv.ParseUint(fs[9], 10, 32)
+	if err != nil {
+		return nil, errMalformedProcNet
+	}
+	ci.Inode = uint32(ino)
+	return &ci, nil
+}
+
+func splitPair(s string) (uint64, uint64, bool) {
+	i := strings.IndexByte(s, ':')
+	if i < 0 {
+		return 0, 0, false
+	}
+	a, err := strconv.ParseUint(s[:i], 16, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	b, err := strconv.ParseUint(s[i+1:], 16, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	return a, b, true
+}
+
+// parseProcNetAddr parses an address such as "0100007F:0277".
+// The address consists of 32-bit words in host byte order.
+func parseProcNetAddr(s string) (*net.TCPAddr, error) {
+	i := strings.IndexByte(s, ':')
+	if i < 0 {
+		return nil, errMalformedProcNet
+	}
+	b, err := hex.DecodeString(s[:i])
+	if err != nil || len(b) != net.IPv4len && len(b) != net.IPv6len {
+		return nil, errMalformedProcNet
+	}
+	port, err := strconv.ParseUint(s[i+1:], 16, 16)
+	if err != nil {
+		return nil, errMalformedProcNet
+	}
+	ip := make(net.IP, len(b))
+	for j := 0; j < len(b); j += 4 {
+		nativeEndian.PutUint32(ip[j:j+4], beUint32(b[j:j+4]))
+	}
+	if len(ip) == net.IPv4len {
+		ip = ip.To16()
+	}
+	return &net.TCPAddr{IP: ip, Port: int(port)}, nil
+}
+
+func beUint32(b []byte) uint32 {
+	return uint32(b[0])<<24 | uint32(b[1])<<16 | uint32(b[2])<<8 | uint32(b[3])
+}
@@ -0,0 +1,212 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package sockdiag implements retrieval of TCP connection
+// information via the Linux sock_diag netlink interface.
+//
+// Unlike the tcpinfo package, which requires a file descriptor of
+// the socket, the sock_diag interface allows inspection of any TCP
+// socket on the host, such as sockets owned by other processes.
+//
+// Example:
+//
+//	c, err := sockdiag.Dial()
+//	if err != nil {
+//		// error handling
+//	}
+//	defer c.Close()
+//	ci, err := c.Lookup(laddr, raddr)
+//	if err != nil {
+//		// error handling
+//	}
+//	fmt.Println(ci.State, ci.Info.RTT)
+//
+// Only supported on Linux.
+package sockdiag
+
+import (
+	"errors"
+	"net"
+	"time"
+
+	"github.com/mikioh/tcpinfo"
+)
+
+var _ tcpinfo.Getter = &Conn{}
+
+var (
+	errNotFound     = errors.New("socket not found")
+	errOpNoSupport  = tcpinfo.ErrNotSupported
+	errInvalidAddr  = errors.New("invalid address")
+	errShortMessage = errors.New("short message")
+)
+
+// A ConnInfo represents information on a TCP socket.
+type ConnInfo struct {
+	LocalAddr  *net.TCPAddr     // local address
+	RemoteAddr *net.TCPAddr     // remote address
+	UID        uint32           // user ID of socket owner
+	Inode      uint32           // inode number of socket
+	State      tcpinfo.State    // connection state
+	Info       *tcpinfo.Info    // connection information; nil when not reported
+	CCAlgo     string           // name of congestion control algorithm; empty when not reported
+	CgroupID   uint64           // cgroup v2 ID of socket; zero when not reported
+	MemInfo    *tcpinfo.MemInfo // socket memory information; nil when not reported
+	Cgroup     string           // cgroup v2 path of socket; empty unless attributed
+	Processes  []Process        // processes holding socket; nil unless attributed
+	Container  *Container       // container owning network namespace of socket; nil unless listed by container
+
+	RecvQueue    uint          // receive queue length in bytes, or accept backlog for listening sockets
+	SendQueue    uint          // send queue length in bytes
+	Timer        Timer         // active timer
+	TimerExpires time.Duration // until active timer expires
+	Retransmits  uint          // # of unrecovered retransmission timeouts or keepalive probes
+}
+
+// A Process represents a process holding a socket.
+type Process struct {
+	PID    int    // process ID
+	Name   string // command name
+	Cgroup string // cgroup v2 path of process
+}
+
+// A Container represents a container, such as of Docker or
+// containerd.
+type Container struct {
+	ID    string // full ID of container
+	PID   int    // process ID of a process in container
+	NetNS string // path to network namespace of container
+}
+
+// A Timer represents a kind of socket timer.
+type Timer int
+
+const (
+	TimerOff        Timer = iota // no timer is pending
+	TimerRetransmit              // retransmission timer
+	TimerKeepalive               // keepalive timer
+	TimerTimeWait                // TIME_WAIT timer
+	TimerProbe                   // zero window probe timer
+)
+
+var timers = map[Timer]string{
+	TimerOff:        "off",
+	TimerRetransmit: "on",
+	TimerKeepalive:  "keepalive",
+	TimerTimeWait:   "timewait",
+	TimerProbe:      "persist",
+}
+
+func (t Timer) String() string {
+	s, ok := timers[t]
+	if !ok {
+		return "<nil>"
+	}
+	return s
+}
+
+// A Filter represents a filter for connection enumeration.
+// The zero value matches all TCP sockets.
+//
+// The states and the address and port conditions are evaluated by
+// the kernel, which avoids dumping every socket on the host.
+type Filter struct {
+	Family      int             // address family, syscall.AF_INET or syscall.AF_INET6; zero means both
+	States      []tcpinfo.State // connection states; empty means all
+	LocalPort   int             // local port number; zero means any
+	RemotePort  int             // remote port number; zero means any
+	LocalPorts  PortRange       // range of local port numbers; zero means any
+	RemotePorts PortRange       // range of remote port numbers; zero means any
+	LocalNet    *net.IPNet      // network of local address; nil means any
+	RemoteNet   *net.IPNet      // network of remote address; nil means any
+	CgroupID    uint64          // cgroup v2 ID; zero means any
+}
+
+// A PortRange represents an inclusive range of port numbers.
+// A zero Max means up to the highest port number.
+type PortRange struct {
+	Min int
+	Max int
+}
+
+func (pr PortRange) any() bool { return pr.Min == 0 && pr.Max == 0 }
+
+func (pr PortRange) bounds() (int, int) {
+	if pr.Max == 0 {
+		return pr.Min, 0xffff
+	}
+	return pr.Min, pr.Max
+}
+
+func (pr PortRange) contains(p int) bool {
+	min, max := pr.bounds()
+	return pr.any() || min <= p && p <= max
+}
+
+func (f *Filter) match(ci *ConnInfo) bool {
+	if f == nil {
+		return true
+	}
+	if len(f.States) > 0 {
+		var ok bool
+		for _, st := range f.States {
+			if st == ci.State {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return false
+		}
+	}
+	if f.LocalPort != 0 && ci.LocalAddr.Port != f.LocalPort {
+		return false
+	}
+	if f.RemotePort != 0 && ci.RemoteAddr.Port != f.RemotePort {
+		return false
+	}
+	if !f.LocalPorts.contains(ci.LocalAddr.Port) || !f.RemotePorts.contains(ci.RemoteAddr.Port) {
+		return false
+	}
+	if f.LocalNet != nil && !f.LocalNet.Contains(ci.LocalAddr.IP) {
+		return false
+	}
+	if f.RemoteNet != nil && !f.RemoteNet.Contains(ci.RemoteAddr.IP) {
+		return false
+	}
+	if f.CgroupID != 0 && ci.CgroupID != f.CgroupID {
+		return false
+	}
+	return true
+}
+
+// ListConnections returns information on all TCP sockets on the
+// host that match the filter f.
+// A nil filter matches all TCP sockets.
+//
+// When the sock_diag interface is not available, it falls back to
+// ListProcConnections.
+func ListConnections(f *Filter) ([]ConnInfo, error) {
+	c, err := Dial()
+	if err != nil {
+		if cis, err := ListProcConnections(f); err == nil {
+			return cis, nil
+		}
+		return nil, err
+	}
+	defer c.Close()
+	return c.ListConnections(f)
+}
+
+// GetBatch returns connection information on each of cs.
+// It falls back to tcpinfo.GetBatch when the sock_diag interface is
+// not available.
+func GetBatch(cs []net.Conn) ([]*tcpinfo.Info, []error) {
+	c, err := Dial()
+	if err != nil {
+		return tcpinfo.GetBatch(cs)
+	}
+	defer c.Close()
+	return c.GetBatch(cs)
+}
@@ -0,0 +1,384 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sockdiag_test
+
+import (
+	"net"
+	"os"
+	"runtime"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/mikioh/tcpinfo"
+	"github.com/mikioh/tcpinfo/sockdiag"
+)
+
+func TestLookup(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skipf("%s/%s", runtime.GOOS, runtime.GOARCH)
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	c, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	dc, err := sockdiag.Dial()
+	if err != nil {
+		t.Skip(err)
+	}
+	defer dc.Close()
+	ci, err := dc.Lookup(c.LocalAddr().(*net.TCPAddr), c.RemoteAddr().(*net.TCPAddr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ci.State != tcpinfo.Established || ci.Info == nil || ci.MemInfo == nil || ci.Inode == 0 {
+		t.Fatalf("got %+v", ci)
+	}
+	if ci.LocalAddr.String() != c.LocalAddr().String() || ci.RemoteAddr.String() != c.RemoteAddr().String() {
+		t.Fatalf("got %v, %v; want %v, %v", ci.LocalAddr, ci.RemoteAddr, c.LocalAddr(), c.RemoteAddr())
+	}
+	ci2, err := dc.LookupInode(ci.Inode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ci2.LocalAddr.String() != ci.LocalAddr.String() {
+		t.Fatalf("got %v; want %v", ci2.LocalAddr, ci.LocalAddr)
+	}
+	if _, err := dc.LookupInode(0xffffffff); err == nil {
+		t.Fatal("got nil; want an error")
+	}
+}
+
+func TestConnGet(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skipf("%s/%s", runtime.GOOS, runtime.GOARCH)
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	c, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	dc, err := sockdiag.Dial()
+	if err != nil {
+		t.Skip(err)
+	}
+	defer dc.Close()
+	var g tcpinfo.Getter = dc
+	i, err := g.Get(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if i.State != tcpinfo.Established || i.Queue == nil {
+		t.Fatalf("got %+v", i)
+	}
+	pc, _ := net.Pipe()
+	defer pc.Close()
+	if _, err := g.Get(pc); err == nil {
+		t.Fatal("got nil; want an error")
+	}
+}
+
+func TestListConnections(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skipf("%s/%s", runtime.GOOS, runtime.GOARCH)
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	c, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	port := ln.Addr().(*net.TCPAddr).Port
+
+	for _, tt := range []struct {
+		f *sockdiag.Filter
+		n int
+	}{
+		{&sockdiag.Filter{Family: syscall.AF_INET, LocalPort: port}, 2},
+		{&sockdiag.Filter{Family: syscall.AF_INET, LocalPort: port, States: []tcpinfo.State{tcpinfo.Listen}}, 1},
+		{&sockdiag.Filter{Family: syscall.AF_INET, RemotePort: port, States: []tcpinfo.State{tcpinfo.Established}}, 1},
+		{&sockdiag.Filter{Family: syscall.AF_INET6, LocalPort: port}, 0},
+		{&sockdiag.Filter{Family: syscall.AF_INET, LocalPorts: sockdiag.PortRange{Min: port, Max: port}, LocalNet: mustCIDR("127.0.0.0/8")}, 2},
+		{&sockdiag.Filter{Family: syscall.AF_INET, LocalPorts: sockdiag.PortRange{Min: port}, RemoteNet: mustCIDR("192.0.2.0/24")}, 0},
+		{&sockdiag.Filter{RemotePorts: sockdiag.PortRange{Min: port, Max: port}, RemoteNet: mustCIDR("127.0.0.1/32")}, 1},
+	} {
+		cis, err := sockdiag.ListConnections(tt.f)
+		if err != nil {
+			t.Skip(err)
+		}
+		if len(cis) != tt.n {
+			t.Fatalf("%+v: got %d; want %d", tt.f, len(cis), tt.n)
+		}
+	}
+}
+
+func mustCIDR(s string) *net.IPNet {
+	_, n, err := net.ParseCIDR(s)
+	if err != nil {
+		panic(err)
+	}
+	return n
+}
+
+func TestAttribute(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skipf("%s/%s", runtime.GOOS, runtime.GOARCH)
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	cis, err := sockdiag.ListConnections(&sockdiag.Filter{Family: syscall.AF_INET, LocalPort: ln.Addr().(*net.TCPAddr).Port})
+	if err != nil {
+		t.Skip(err)
+	}
+	if err := sockdiag.Attribute(cis); err != nil {
+		t.Fatal(err)
+	}
+	if len(cis) != 1 || len(cis[0].Processes) != 1 {
+		t.Fatalf("got %+v; want a socket held by a process", cis)
+	}
+	if p := cis[0].Processes[0]; p.PID != os.Getpid() || p.Name == "" {
+		t.Fatalf("got %+v; want pid %d", p, os.Getpid())
+	}
+}
+
+func TestDialNetNS(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skipf("%s/%s", runtime.GOOS, runtime.GOARCH)
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	c, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	dc, err := sockdiag.DialNetNS("/proc/self/ns/net")
+	if err != nil {
+		t.Skip(err)
+	}
+	defer dc.Close()
+	if _, err := dc.Lookup(c.LocalAddr().(*net.TCPAddr), c.RemoteAddr().(*net.TCPAddr)); err != nil {
+		t.Fatal(err)
+	}
+	cis, err := sockdiag.ListProcConnectionsNetNS("/proc/self/ns/net", &sockdiag.Filter{LocalPort: ln.Addr().(*net.TCPAddr).Port})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cis) != 2 {
+		t.Fatalf("got %d; want 2", len(cis))
+	}
+}
+
+func TestFindContainer(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skipf("%s/%s", runtime.GOOS, runtime.GOARCH)
+	}
+	if _, err := sockdiag.FindContainer("ffffffffffffffff"); err == nil {
+		t.Fatal("got nil; want an error")
+	}
+	if _, err := sockdiag.ListContainerConnections("", nil); err == nil {
+		t.Fatal("got nil; want an error")
+	}
+}
+
+func TestSubscribeAndDestroy(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skipf("%s/%s", runtime.GOOS, runtime.GOARCH)
+	}
+	sub, err := sockdiag.Subscribe()
+	if err != nil {
+		t.Skip(err)
+	}
+	defer sub.Close()
+	dc, err := sockdiag.Dial()
+	if err != nil {
+		t.Skip(err)
+	}
+	defer dc.Close()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	c, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	laddr := c.LocalAddr().(*net.TCPAddr)
+	if err := dc.Destroy(laddr, c.RemoteAddr().(*net.TCPAddr)); err != nil {
+		t.Skip(err)
+	}
+	c.SetReadDeadline(time.Now().Add(3 * time.Second))
+	if _, err := c.Read(make([]byte, 1)); err == nil {
+		t.Fatal("got nil; want an error")
+	}
+	c.Close()
+	time.AfterFunc(3*time.Second, func() { sub.Close() })
+	for {
+		ci, err := sub.Receive()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ci.LocalAddr.Port == laddr.Port {
+			if ci.Info == nil {
+				t.Fatal("got nil; want final connection information")
+			}
+			break
+		}
+	}
+}
+
+func TestListProcConnections(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skipf("%s/%s", runtime.GOOS, runtime.GOARCH)
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	c, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	port := ln.Addr().(*net.TCPAddr).Port
+
+	cis, err := sockdiag.ListProcConnections(&sockdiag.Filter{Family: syscall.AF_INET, RemotePort: port})
+	if err != nil {
+		t.Skip(err)
+	}
+	if len(cis) != 1 {
+		t.Fatalf("got %d; want 1", len(cis))
+	}
+	ci := cis[0]
+	if ci.State != tcpinfo.Established || ci.Inode == 0 {
+		t.Fatalf("got %+v", ci)
+	}
+	if ci.LocalAddr.String() != c.LocalAddr().String() || ci.RemoteAddr.String() != c.RemoteAddr().String() {
+		t.Fatalf("got %v, %v; want %v, %v", ci.LocalAddr, ci.RemoteAddr, c.LocalAddr(), c.RemoteAddr())
+	}
+	cis, err = sockdiag.ListProcConnections(&sockdiag.Filter{LocalPort: port, States: []tcpinfo.State{tcpinfo.Listen}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cis) != 1 || cis[0].State != tcpinfo.Listen {
+		t.Fatalf("got %+v; want one listener", cis)
+	}
+}
+
+func TestGetBatch(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skipf("%s/%s", runtime.GOOS, runtime.GOARCH)
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	c, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	ac, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ac.Close()
+	pc, _ := net.Pipe()
+	defer pc.Close()
+
+	dc, err := sockdiag.Dial()
+	if err != nil {
+		t.Skip(err)
+	}
+	defer dc.Close()
+	is, errs := dc.GetBatch([]net.Conn{c, pc, ac})
+	if len(is) != 3 || len(errs) != 3 {
+		t.Fatalf("got %d, %d; want 3, 3", len(is), len(errs))
+	}
+	for _, j := range []int{0, 2} {
+		if errs[j] != nil {
+			t.Fatal(errs[j])
+		}
+		if is[j].State != tcpinfo.Established || is[j].Queue == nil {
+			t.Fatalf("got %+v", is[j])
+		}
+	}
+	if is[1] != nil || errs[1] == nil {
+		t.Fatalf("got %v, %v; want nil, an error", is[1], errs[1])
+	}
+}
+
+func TestGetBatchDuplicateConns(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skipf("%s/%s", runtime.GOOS, runtime.GOARCH)
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	c, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	ac, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ac.Close()
+
+	dc, err := sockdiag.Dial()
+	if err != nil {
+		t.Skip(err)
+	}
+	defer dc.Close()
+	cs := []net.Conn{c, ac, c, c}
+	is, errs := dc.GetBatch(cs)
+	if len(is) != len(cs) || len(errs) != len(cs) {
+		t.Fatalf("got %d, %d; want %d, %d", len(is), len(errs), len(cs), len(cs))
+	}
+	for j := range cs {
+		if (is[j] == nil) == (errs[j] == nil) {
+			t.Fatalf("#%d: got %v, %v; want either set", j, is[j], errs[j])
+		}
+		if errs[j] != nil {
+			t.Fatalf("#%d: %v", j, errs[j])
+		}
+		if is[j].State != tcpinfo.Established {
+			t.Fatalf("#%d: got %+v", j, is[j])
+		}
+	}
+}
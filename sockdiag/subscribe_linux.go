@@ -0,0 +1,74 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sockdiag
+
+import (
+	"os"
+	"syscall"
+)
+
+// A Subscription represents a subscription to TCP socket destroy
+// notifications.
+type Subscription struct {
+	f   *os.File
+	b   []byte
+	cis []*ConnInfo
+}
+
+// Subscribe returns a new subscription to destroy notifications of
+// IPv4 and IPv6 TCP sockets on the host.
+//
+// Each notification carries the final connection information of
+// the socket.
+// It requires the CAP_NET_ADMIN capability.
+func Subscribe() (*Subscription, error) {
+	s, err := syscall.Socket(syscall.AF_NETLINK, syscall.SOCK_RAW|syscall.SOCK_CLOEXEC|syscall.SOCK_NONBLOCK, sysNETLINK_SOCK_DIAG)
+	if err != nil {
+		return nil, os.NewSyscallError("socket", err)
+	}
+	sa := syscall.SockaddrNetlink{
+		Family: syscall.AF_NETLINK,
+		Groups: 1<<(sysSKNLGRP_INET_TCP_DESTROY-1) | 1<<(sysSKNLGRP_INET6_TCP_DESTROY-1),
+	}
+	if err := syscall.Bind(s, &sa); err != nil {
+		syscall.Close(s)
+		return nil, os.NewSyscallError("bind", err)
+	}
+	return &Subscription{f: os.NewFile(uintptr(s), "sockdiag"), b: make([]byte, os.Getpagesize()*8)}, nil
+}
+
+// Receive waits for and returns the information on the next
+// destroyed TCP socket.
+func (s *Subscription) Receive() (*ConnInfo, error) {
+	for len(s.cis) == 0 {
+		n, err := s.f.Read(s.b)
+		if err != nil {
+			return nil, err
+		}
+		msgs, err := syscall.ParseNetlinkMessage(s.b[:n])
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range msgs {
+			if m.Header.Type != sysSOCK_DIAG_BY_FAMILY {
+				continue
+			}
+			ci, err := parseConnInfo(m.Data)
+			if err != nil {
+				return nil, err
+			}
+			s.cis = append(s.cis, ci)
+		}
+	}
+	ci := s.cis[0]
+	s.cis = s.cis[1:]
+	return ci, nil
+}
+
+// Close closes the subscription.
+// Any blocked Receive will be unblocked and return an error.
+func (s *Subscription) Close() error {
+	return s.f.Close()
+}
@@ -0,0 +1,26 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build !linux
+
+package sockdiag
+
+// A Subscription represents a subscription to TCP socket destroy
+// notifications.
+type Subscription struct{}
+
+// Subscribe returns a new subscription to destroy notifications of
+// IPv4 and IPv6 TCP sockets on the host.
+func Subscribe() (*Subscription, error) { return nil, errOpNoSupport }
+
+// Receive waits for and returns the information on the next
+// destroyed TCP socket.
+func (s *Subscription) Receive() (*ConnInfo, error) { return nil, errOpNoSupport }
+
+// Close closes the subscription.
+func (s *Subscription) Close() error { return errOpNoSupport }
+
+// SubscribeNetNS is like Subscribe but subscribes to notifications in
+// the network namespace referred to by the file path.
+func SubscribeNetNS(path string) (*Subscription, error) { return nil, errOpNoSupport }
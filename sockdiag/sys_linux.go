@@ -0,0 +1,608 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sockdiag
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"github.com/mikioh/tcpinfo"
+	"github.com/mikioh/tcpopt"
+)
+
+const (
+	sysNETLINK_SOCK_DIAG   = 0x4
+	sysSOCK_DIAG_BY_FAMILY = 0x14
+	sysSOCK_DESTROY        = 0x15
+
+	sysSKNLGRP_INET_TCP_DESTROY  = 0x1
+	sysSKNLGRP_INET6_TCP_DESTROY = 0x3
+
+	sysINET_DIAG_NOCOOKIE = 0xffffffff
+
+	sysINET_DIAG_REQ_BYTECODE = 0x1
+
+	sysINET_DIAG_BC_S_GE   = 0x2
+	sysINET_DIAG_BC_S_LE   = 0x3
+	sysINET_DIAG_BC_D_GE   = 0x4
+	sysINET_DIAG_BC_D_LE   = 0x5
+	sysINET_DIAG_BC_S_COND = 0x7
+	sysINET_DIAG_BC_D_COND = 0x8
+
+	sysINET_DIAG_MEMINFO   = 0x1
+	sysINET_DIAG_INFO      = 0x2
+	sysINET_DIAG_CONG      = 0x4
+	sysINET_DIAG_SKMEMINFO = 0x7
+	sysINET_DIAG_MD5SIG    = 0x12
+	sysINET_DIAG_CGROUP_ID = 0x15
+
+	sysTCP_INFO = 0xb
+
+	sysSOL_SOCKET = 0x1
+	sysSO_MEMINFO = 0x37
+
+	sizeofInetDiagSockID = 0x30
+	sizeofInetDiagReqV2  = 0x38
+	sizeofInetDiagMsg    = 0x48
+
+	allStates = 0xfff
+	diagExts  = 1<<(sysINET_DIAG_INFO-1) | 1<<(sysINET_DIAG_CONG-1) | 1<<(sysINET_DIAG_SKMEMINFO-1)
+)
+
+type inetDiagSockID struct {
+	Sport  [2]byte
+	Dport  [2]byte
+	Src    [16]byte
+	Dst    [16]byte
+	If     uint32
+	Cookie [2]uint32
+}
+
+type inetDiagReqV2 struct {
+	Family   uint8
+	Protocol uint8
+	Ext      uint8
+	Pad      uint8
+	States   uint32
+	ID       inetDiagSockID
+}
+
+type inetDiagMsg struct {
+	Family  uint8
+	State   uint8
+	Timer   uint8
+	Retrans uint8
+	ID      inetDiagSockID
+	Expires uint32
+	Rqueue  uint32
+	Wqueue  uint32
+	UID     uint32
+	Inode   uint32
+}
+
+var sysStates = [12]tcpinfo.State{tcpinfo.Unknown, tcpinfo.Established, tcpinfo.SynSent, tcpinfo.SynReceived, tcpinfo.FinWait1, tcpinfo.FinWait2, tcpinfo.TimeWait, tcpinfo.Closed, tcpinfo.CloseWait, tcpinfo.LastAck, tcpinfo.Listen, tcpinfo.Closing}
+
+// A Conn represents a sock_diag netlink connection.
+type Conn struct {
+	mu  sync.Mutex
+	s   int
+	seq uint32
+}
+
+// Dial opens a sock_diag netlink connection.
+func Dial() (*Conn, error) {
+	s, err := syscall.Socket(syscall.AF_NETLINK, syscall.SOCK_RAW|syscall.SOCK_CLOEXEC, sysNETLINK_SOCK_DIAG)
+	if err != nil {
+		return nil, os.NewSyscallError("socket", err)
+	}
+	if err := syscall.Bind(s, &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK}); err != nil {
+		syscall.Close(s)
+		return nil, os.NewSyscallError("bind", err)
+	}
+	return &Conn{s: s}, nil
+}
+
+// Close closes the connection.
+func (c *Conn) Close() error {
+	return syscall.Close(c.s)
+}
+
+// Lookup returns information on the TCP socket identified by the
+// local address laddr and the remote address raddr.
+func (c *Conn) Lookup(laddr, raddr *net.TCPAddr) (*ConnInfo, error) {
+	family, id, err := sockID(laddr, raddr)
+	if err != nil {
+		return nil, err
+	}
+	req := inetDiagReqV2{
+		Family:   uint8(family),
+		Protocol: syscall.IPPROTO_TCP,
+		Ext:      diagExts,
+		States:   allStates,
+		ID:       id,
+	}
+	var ci *ConnInfo
+	err = c.query(sysSOCK_DIAG_BY_FAMILY, &req, nil, syscall.NLM_F_REQUEST, func(ci0 *ConnInfo) bool {
+		ci = ci0
+		return false
+	})
+	if err == syscall.ENOENT || err == nil && ci == nil {
+		return nil, errNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return ci, nil
+}
+
+// Get returns connection information on nc, which must be a TCP
+// connection, by looking up its local and remote addresses.
+// The Queue field is filled in unless nc is listening.
+func (c *Conn) Get(nc net.Conn) (*tcpinfo.Info, error) {
+	laddr, _ := nc.LocalAddr().(*net.TCPAddr)
+	raddr, _ := nc.RemoteAddr().(*net.TCPAddr)
+	ci, err := c.Lookup(laddr, raddr)
+	if err != nil {
+		return nil, err
+	}
+	if ci.Info == nil {
+		return nil, errNotFound
+	}
+	if ci.State != tcpinfo.Listen {
+		ci.Info.Queue = &tcpinfo.Queue{Receive: ci.RecvQueue, Send: ci.SendQueue}
+	}
+	return ci.Info, nil
+}
+
+// Destroy forcibly closes the TCP socket identified by the local
+// address laddr and the remote address raddr.
+//
+// It requires the CAP_NET_ADMIN capability and a kernel built with
+// CONFIG_INET_DIAG_DESTROY.
+func (c *Conn) Destroy(laddr, raddr *net.TCPAddr) error {
+	family, id, err := sockID(laddr, raddr)
+	if err != nil {
+		return err
+	}
+	req := inetDiagReqV2{
+		Family:   uint8(family),
+		Protocol: syscall.IPPROTO_TCP,
+		States:   allStates,
+		ID:       id,
+	}
+	err = c.query(sysSOCK_DESTROY, &req, nil, syscall.NLM_F_REQUEST|syscall.NLM_F_ACK, func(*ConnInfo) bool { return false })
+	if err == syscall.ENOENT {
+		return errNotFound
+	}
+	return err
+}
+
+// LookupInode returns information on the TCP socket identified by
+// the inode number ino.
+func (c *Conn) LookupInode(ino uint32) (*ConnInfo, error) {
+	var ci *ConnInfo
+	for _, family := range []int{syscall.AF_INET, syscall.AF_INET6} {
+		err := c.dump(family, allStates, nil, func(ci0 *ConnInfo) bool {
+			if ci0.Inode == ino {
+				ci = ci0
+				return false
+			}
+			return true
+		})
+		if err != nil {
+			return nil, err
+		}
+		if ci != nil {
+			return ci, nil
+		}
+	}
+	return nil, errNotFound
+}
+
+// ListConnections returns information on all TCP sockets on the
+// host that match the filter f.
+// A nil filter matches all TCP sockets.
+func (c *Conn) ListConnections(f *Filter) ([]ConnInfo, error) {
+	families := []int{syscall.AF_INET, syscall.AF_INET6}
+	states := uint32(allStates)
+	if f != nil {
+		if f.Family != 0 {
+			families = []int{f.Family}
+		}
+		if len(f.States) > 0 {
+			states = stateMask(f.States)
+		}
+	}
+	var cis []ConnInfo
+	for _, family := range families {
+		fn := func(ci *ConnInfo) bool {
+			if f.match(ci) {
+				cis = append(cis, *ci)
+			}
+			return true
+		}
+		err := c.dump(family, states, f.bytecode(), fn)
+		if err == syscall.EINVAL {
+			// The kernel may not know some of the bytecode
+			// operations; the filter is applied here instead.
+			err = c.dump(family, states, nil, fn)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return cis, nil
+}
+
+// GetBatch returns connection information on each of cs, which
+// must be TCP connections implementing syscall.Conn, with a single
+// dump per address family.
+// The returned slices have the same length as cs; for each
+// connection either the information or the error on retrieval is
+// set.
+// A connection appearing more than once in cs gets the same
+// information at each position.
+//
+// GetBatch looks up the inode number of the socket underlying each
+// connection with a system call, so a batch costs a system call per
+// connection in addition to the dumps.
+func (c *Conn) GetBatch(cs []net.Conn) ([]*tcpinfo.Info, []error) {
+	is := make([]*tcpinfo.Info, len(cs))
+	errs := make([]error, len(cs))
+	inos := make(map[uint32][]int, len(cs))
+	for j, tc := range cs {
+		ino, err := inode(tc)
+		if err != nil {
+			errs[j] = err
+			continue
+		}
+		inos[ino] = append(inos[ino], j)
+	}
+	left := len(inos)
+	for _, family := range []int{syscall.AF_INET, syscall.AF_INET6} {
+		if left == 0 {
+			break
+		}
+		err := c.dump(family, allStates, nil, func(ci *ConnInfo) bool {
+			js, ok := inos[ci.Inode]
+			if !ok || ci.Info == nil {
+				return true
+			}
+			if ci.State != tcpinfo.Listen {
+				ci.Info.Queue = &tcpinfo.Queue{Receive: ci.RecvQueue, Send: ci.SendQueue}
+			}
+			for _, j := range js {
+				is[j] = ci.Info
+			}
+			delete(inos, ci.Inode)
+			left--
+			return left > 0
+		})
+		if err != nil {
+			for _, js := range inos {
+				for _, j := range js {
+					errs[j] = err
+				}
+			}
+			return is, errs
+		}
+	}
+	for _, js := range inos {
+		for _, j := range js {
+			errs[j] = errNotFound
+		}
+	}
+	return is, errs
+}
+
+// inode returns the inode number of the socket underlying c.
+func inode(c net.Conn) (uint32, error) {
+	sc, ok := c.(syscall.Conn)
+	if !ok {
+		return 0, errors.New("not a syscall.Conn")
+	}
+	rc, err := sc.SyscallConn()
+	if err != nil {
+		return 0, err
+	}
+	var st syscall.Stat_t
+	var operr error
+	if err := rc.Control(func(s uintptr) { operr = syscall.Fstat(int(s), &st) }); err != nil {
+		return 0, err
+	}
+	if operr != nil {
+		return 0, os.NewSyscallError("fstat", operr)
+	}
+	return uint32(st.Ino), nil
+}
+
+func stateMask(states []tcpinfo.State) uint32 {
+	var mask uint32
+	for _, st := range states {
+		for i, sst := range sysStates {
+			if i > 0 && sst == st {
+				mask |= 1 << uint(i)
+			}
+		}
+	}
+	return mask
+}
+
+// dump calls fn for each TCP socket of the address family in the
+// states that the bytecode bc accepts, until fn returns false.
+// A nil bc accepts all sockets.
+func (c *Conn) dump(family int, states uint32, bc []byte, fn func(*ConnInfo) bool) error {
+	req := inetDiagReqV2{
+		Family:   uint8(family),
+		Protocol: syscall.IPPROTO_TCP,
+		Ext:      diagExts,
+		States:   states,
+		ID:       inetDiagSockID{Cookie: [2]uint32{sysINET_DIAG_NOCOOKIE, sysINET_DIAG_NOCOOKIE}},
+	}
+	return c.query(sysSOCK_DIAG_BY_FAMILY, &req, bc, syscall.NLM_F_REQUEST|syscall.NLM_F_DUMP, fn)
+}
+
+func (c *Conn) query(typ uint16, req *inetDiagReqV2, bc []byte, flags uint16, fn func(*ConnInfo) bool) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.seq++
+	l := syscall.NLMSG_HDRLEN + sizeofInetDiagReqV2
+	if len(bc) > 0 {
+		l += syscall.SizeofRtAttr + len(bc)
+	}
+	b := make([]byte, l)
+	h := (*syscall.NlMsghdr)(unsafe.Pointer(&b[0]))
+	h.Len = uint32(len(b))
+	h.Type = typ
+	h.Flags = flags
+	h.Seq = c.seq
+	*(*inetDiagReqV2)(unsafe.Pointer(&b[syscall.NLMSG_HDRLEN])) = *req
+	if len(bc) > 0 {
+		a := b[syscall.NLMSG_HDRLEN+sizeofInetDiagReqV2:]
+		nativeEndian.PutUint16(a[0:2], uint16(syscall.SizeofRtAttr+len(bc)))
+		nativeEndian.PutUint16(a[2:4], sysINET_DIAG_REQ_BYTECODE)
+		copy(a[syscall.SizeofRtAttr:], bc)
+	}
+	if err := syscall.Sendto(c.s, b, 0, &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK}); err != nil {
+		return os.NewSyscallError("sendto", err)
+	}
+	rb := make([]byte, os.Getpagesize()*8)
+	more := true
+	for {
+		n, _, err := syscall.Recvfrom(c.s, rb, 0)
+		if err != nil {
+			return os.NewSyscallError("recvfrom", err)
+		}
+		msgs, err := syscall.ParseNetlinkMessage(rb[:n])
+		if err != nil {
+			return err
+		}
+		for _, m := range msgs {
+			if m.Header.Seq != c.seq {
+				continue
+			}
+			switch m.Header.Type {
+			case syscall.NLMSG_DONE:
+				return nil
+			case syscall.NLMSG_ERROR:
+				if len(m.Data) < 4 {
+					return errShortMessage
+				}
+				if errno := -int32(nativeEndian.Uint32(m.Data)); errno != 0 {
+					return syscall.Errno(errno)
+				}
+				return nil
+			case sysSOCK_DIAG_BY_FAMILY:
+				if !more {
+					continue
+				}
+				ci, err := parseConnInfo(m.Data)
+				if err != nil {
+					return err
+				}
+				more = fn(ci)
+			}
+		}
+		if flags&syscall.NLM_F_DUMP == 0 {
+			return nil
+		}
+	}
+}
+
+func parseConnInfo(b []byte) (*ConnInfo, error) {
+	if len(b) < sizeofInetDiagMsg {
+		return nil, errShortMessage
+	}
+	m := (*inetDiagMsg)(unsafe.Pointer(&b[0]))
+	ci := &ConnInfo{
+		UID:          m.UID,
+		Inode:        m.Inode,
+		RecvQueue:    uint(m.Rqueue),
+		SendQueue:    uint(m.Wqueue),
+		Timer:        Timer(m.Timer),
+		TimerExpires: time.Duration(m.Expires) * time.Millisecond,
+		Retransmits:  uint(m.Retrans),
+	}
+	if int(m.State) < len(sysStates) {
+		ci.State = sysStates[m.State]
+	}
+	ci.LocalAddr, ci.RemoteAddr = tcpAddrs(int(m.Family), &m.ID)
+	var md5sig bool
+	attrs := b[nlmsgAlign(sizeofInetDiagMsg):]
+	for len(attrs) >= syscall.SizeofRtAttr {
+		l := int(nativeEndian.Uint16(attrs[0:2]))
+		typ := nativeEndian.Uint16(attrs[2:4])
+		if l < syscall.SizeofRtAttr || l > len(attrs) {
+			return nil, errShortMessage
+		}
+		data := attrs[syscall.SizeofRtAttr:l]
+		switch typ {
+		case sysINET_DIAG_INFO:
+			o, err := tcpopt.Parse(syscall.IPPROTO_TCP, sysTCP_INFO, data)
+			if err == nil {
+				ci.Info, _ = o.(*tcpinfo.Info)
+			}
+		case sysINET_DIAG_CONG:
+			ci.CCAlgo = strings.TrimRight(string(data), "\x00")
+		case sysINET_DIAG_SKMEMINFO:
+			o, err := tcpopt.Parse(sysSOL_SOCKET, sysSO_MEMINFO, data)
+			if err == nil {
+				ci.MemInfo, _ = o.(*tcpinfo.MemInfo)
+			}
+		case sysINET_DIAG_MD5SIG:
+			md5sig = len(data) > 0
+		case sysINET_DIAG_CGROUP_ID:
+			if len(data) >= 8 {
+				ci.CgroupID = nativeEndian.Uint64(data)
+			}
+		}
+		if nlmsgAlign(l) >= len(attrs) {
+			break
+		}
+		attrs = attrs[nlmsgAlign(l):]
+	}
+	if md5sig && ci.Info != nil {
+		ci.Info.Options = append(ci.Info.Options, tcpinfo.MD5Signature(true))
+		ci.Info.PeerOptions = append(ci.Info.PeerOptions, tcpinfo.MD5Signature(true))
+	}
+	return ci, nil
+}
+
+// bytecode compiles the address and port conditions of f into
+// inet_diag bytecode.
+// It returns nil when f has no such conditions.
+//
+// The bytecode is a sequence of operations, each of which jumps to
+// the next one when its condition holds, and beyond the end, which
+// rejects the socket, otherwise.
+func (f *Filter) bytecode() []byte {
+	if f == nil {
+		return nil
+	}
+	var ops [][]byte
+	for _, cond := range []struct {
+		ge, le int
+		pr     PortRange
+	}{
+		{sysINET_DIAG_BC_S_GE, sysINET_DIAG_BC_S_LE, PortRange{f.LocalPort, f.LocalPort}},
+		{sysINET_DIAG_BC_S_GE, sysINET_DIAG_BC_S_LE, f.LocalPorts},
+		{sysINET_DIAG_BC_D_GE, sysINET_DIAG_BC_D_LE, PortRange{f.RemotePort, f.RemotePort}},
+		{sysINET_DIAG_BC_D_GE, sysINET_DIAG_BC_D_LE, f.RemotePorts},
+	} {
+		if cond.pr.any() {
+			continue
+		}
+		min, max := cond.pr.bounds()
+		ops = append(ops, portOp(cond.ge, min), portOp(cond.le, max))
+	}
+	for _, cond := range []struct {
+		code int
+		n    *net.IPNet
+	}{
+		{sysINET_DIAG_BC_S_COND, f.LocalNet},
+		{sysINET_DIAG_BC_D_COND, f.RemoteNet},
+	} {
+		if cond.n != nil {
+			ops = append(ops, hostCondOp(cond.code, cond.n))
+		}
+	}
+	if len(ops) == 0 {
+		return nil
+	}
+	var l int
+	for _, op := range ops {
+		l += len(op)
+	}
+	b := make([]byte, 0, l)
+	for _, op := range ops {
+		op[1] = byte(len(op))
+		nativeEndian.PutUint16(op[2:4], uint16(l-len(b)+4))
+		b = append(b, op...)
+	}
+	return b
+}
+
+// portOp returns an operation comparing the port with p, which is
+// carried in the second half of the operation.
+func portOp(code, p int) []byte {
+	op := make([]byte, 8)
+	op[0] = byte(code)
+	nativeEndian.PutUint16(op[6:8], uint16(p))
+	return op
+}
+
+// hostCondOp returns an operation matching the address with n,
+// which is carried in struct inet_diag_hostcond following the
+// operation.
+func hostCondOp(code int, n *net.IPNet) []byte {
+	family, ip := syscall.AF_INET6, n.IP.To16()
+	if ip4 := n.IP.To4(); ip4 != nil && len(n.Mask) == net.IPv4len {
+		family, ip = syscall.AF_INET, ip4
+	}
+	ones, _ := n.Mask.Size()
+	op := make([]byte, 4+8+len(ip))
+	op[0] = byte(code)
+	op[4] = byte(family)
+	op[5] = byte(ones)
+	nativeEndian.PutUint32(op[8:12], 0xffffffff) // any port
+	copy(op[12:], ip)
+	return op
+}
+
+func nlmsgAlign(l int) int { return (l + syscall.NLMSG_ALIGNTO - 1) &^ (syscall.NLMSG_ALIGNTO - 1) }
+
+func sockID(laddr, raddr *net.TCPAddr) (int, inetDiagSockID, error) {
+	var id inetDiagSockID
+	if laddr == nil || raddr == nil {
+		return 0, id, errInvalidAddr
+	}
+	family := syscall.AF_INET6
+	if laddr.IP.To4() != nil && raddr.IP.To4() != nil {
+		family = syscall.AF_INET
+		copy(id.Src[:], laddr.IP.To4())
+		copy(id.Dst[:], raddr.IP.To4())
+	} else {
+		if laddr.IP.To16() == nil || raddr.IP.To16() == nil {
+			return 0, id, errInvalidAddr
+		}
+		copy(id.Src[:], laddr.IP.To16())
+		copy(id.Dst[:], raddr.IP.To16())
+	}
+	binary.BigEndian.PutUint16(id.Sport[:], uint16(laddr.Port))
+	binary.BigEndian.PutUint16(id.Dport[:], uint16(raddr.Port))
+	id.Cookie = [2]uint32{sysINET_DIAG_NOCOOKIE, sysINET_DIAG_NOCOOKIE}
+	return family, id, nil
+}
+
+func tcpAddrs(family int, id *inetDiagSockID) (*net.TCPAddr, *net.TCPAddr) {
+	laddr := &net.TCPAddr{Port: int(binary.BigEndian.Uint16(id.Sport[:]))}
+	raddr := &net.TCPAddr{Port: int(binary.BigEndian.Uint16(id.Dport[:]))}
+	if family == syscall.AF_INET {
+		laddr.IP = net.IPv4(id.Src[0], id.Src[1], id.Src[2], id.Src[3])
+		raddr.IP = net.IPv4(id.Dst[0], id.Dst[1], id.Dst[2], id.Dst[3])
+	} else {
+		laddr.IP = append(net.IP(nil), id.Src[:]...)
+		raddr.IP = append(net.IP(nil), id.Dst[:]...)
+	}
+	return laddr, raddr
+}
+
+var nativeEndian binary.ByteOrder
+
+func init() {
+	i := uint32(1)
+	if *(*byte)(unsafe.Pointer(&i)) == 1 {
+		nativeEndian = binary.LittleEndian
+	} else {
+		nativeEndian = binary.BigEndian
+	}
+}
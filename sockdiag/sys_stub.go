@@ -0,0 +1,99 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build !linux
+
+package sockdiag
+
+import (
+	"net"
+
+	"github.com/mikioh/tcpinfo"
+)
+
+// A Conn represents a sock_diag netlink connection.
+type Conn struct{}
+
+// Dial opens a sock_diag netlink connection.
+func Dial() (*Conn, error) { return nil, errOpNoSupport }
+
+// Close closes the connection.
+func (c *Conn) Close() error { return errOpNoSupport }
+
+// Lookup returns information on the TCP socket identified by the
+// local address laddr and the remote address raddr.
+func (c *Conn) Lookup(laddr, raddr *net.TCPAddr) (*ConnInfo, error) {
+	return nil, errOpNoSupport
+}
+
+// LookupInode returns information on the TCP socket identified by
+// the inode number ino.
+func (c *Conn) LookupInode(ino uint32) (*ConnInfo, error) {
+	return nil, errOpNoSupport
+}
+
+// ListConnections returns information on all TCP sockets on the
+// host that match the filter f.
+// A nil filter matches all TCP sockets.
+func (c *Conn) ListConnections(f *Filter) ([]ConnInfo, error) {
+	return nil, errOpNoSupport
+}
+
+// GetBatch returns connection information on each of cs with a
+// single dump per address family.
+func (c *Conn) GetBatch(cs []net.Conn) ([]*tcpinfo.Info, []error) {
+	errs := make([]error, len(cs))
+	for j := range errs {
+		errs[j] = errOpNoSupport
+	}
+	return make([]*tcpinfo.Info, len(cs)), errs
+}
+
+// Get returns connection information on nc, which must be a TCP
+// connection, by looking up its local and remote addresses.
+func (c *Conn) Get(nc net.Conn) (*tcpinfo.Info, error) {
+	return nil, errOpNoSupport
+}
+
+// Destroy forcibly closes the TCP socket identified by the local
+// address laddr and the remote address raddr.
+func (c *Conn) Destroy(laddr, raddr *net.TCPAddr) error {
+	return errOpNoSupport
+}
+
+// ListProcConnections returns information on all TCP sockets on the
+// host that match the filter f by parsing /proc/net/tcp and
+// /proc/net/tcp6.
+func ListProcConnections(f *Filter) ([]ConnInfo, error) {
+	return nil, errOpNoSupport
+}
+
+// Attribute fills in the processes holding the sockets of cis and
+// the cgroup paths of the sockets.
+func Attribute(cis []ConnInfo) error { return errOpNoSupport }
+
+// DialNetNS is like Dial but opens the connection in the network
+// namespace referred to by the file path.
+func DialNetNS(path string) (*Conn, error) { return nil, errOpNoSupport }
+
+// DialNetNSFd is like DialNetNS but takes a file descriptor referring
+// to the network namespace.
+func DialNetNSFd(fd int) (*Conn, error) { return nil, errOpNoSupport }
+
+// ListProcConnectionsNetNS is like ListProcConnections but lists the
+// TCP sockets in the network namespace referred to by the file path.
+func ListProcConnectionsNetNS(path string, f *Filter) ([]ConnInfo, error) {
+	return nil, errOpNoSupport
+}
+
+// FindContainer returns the container with the ID id, which may be
+// a unique prefix of the full ID.
+func FindContainer(id string) (*Container, error) { return nil, errOpNoSupport }
+
+// ListContainerConnections returns information on all TCP sockets in
+// the network namespace of the container with the ID id that match
+// the filter f, labeled with the container.
+func ListContainerConnections(id string, f *Filter) ([]ConnInfo, error) {
+	return nil, errOpNoSupport
+}
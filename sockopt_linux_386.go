@@ -0,0 +1,53 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcpinfo
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	sysSETSOCKOPT = 0xe
+	sysGETSOCKOPT = 0xf
+)
+
+func getsockopt(s uintptr, level, name int, b []byte) (int, error) {
+	n, err := getsockoptRaw(s, level, name, b)
+	if err != nil {
+		return 0, os.NewSyscallError("getsockopt", err)
+	}
+	return n, nil
+}
+
+// getsockoptRaw is like getsockopt but returns the bare errno, which
+// saves an allocation on the error paths expected by callers.
+func getsockoptRaw(s uintptr, level, name int, b []byte) (int, error) {
+	l := uint32(len(b))
+	args := [5]uintptr{s, uintptr(level), uintptr(name), uintptr(unsafe.Pointer(&b[0])), uintptr(unsafe.Pointer(&l))}
+	_, _, errno := syscall.Syscall(syscall.SYS_SOCKETCALL, sysGETSOCKOPT, uintptr(unsafe.Pointer(&args)), 0)
+	if errno != 0 {
+		return 0, errno
+	}
+	return int(l), nil
+}
+
+func setsockopt(s uintptr, level, name int, b []byte) error {
+	args := [5]uintptr{s, uintptr(level), uintptr(name), uintptr(unsafe.Pointer(&b[0])), uintptr(len(b))}
+	_, _, errno := syscall.Syscall(syscall.SYS_SOCKETCALL, sysSETSOCKOPT, uintptr(unsafe.Pointer(&args)), 0)
+	if errno != 0 {
+		return os.NewSyscallError("setsockopt", errno)
+	}
+	return nil
+}
+
+func ioctl(s uintptr, req uint, v *int32) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, s, uintptr(req), uintptr(unsafe.Pointer(v)))
+	if errno != 0 {
+		return os.NewSyscallError("ioctl", errno)
+	}
+	return nil
+}
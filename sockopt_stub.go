@@ -0,0 +1,23 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build !darwin,!freebsd,!linux,!netbsd
+
+package tcpinfo
+
+func getsockopt(s uintptr, level, name int, b []byte) (int, error) {
+	return 0, errNotSupported("getsockopt", "socket option")
+}
+
+func getsockoptRaw(s uintptr, level, name int, b []byte) (int, error) {
+	return getsockopt(s, level, name, b)
+}
+
+func setsockopt(s uintptr, level, name int, b []byte) error {
+	return errNotSupported("setsockopt", "socket option")
+}
+
+func ioctl(s uintptr, req uint, v *int32) error {
+	return errNotSupported("ioctl", "socket")
+}
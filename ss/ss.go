@@ -0,0 +1,424 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package ss implements a parser of the output of ss, the socket
+// statistics utility of iproute2.
+//
+// The parser reads the output of "ss -ti" or "ss -tin", with or
+// without the header line and filters such as "state established",
+// and its JSON counterpart, and builds connection information from
+// it.
+// This allows captures taken with standard tools in the field to be
+// analyzed with the tcpinfo package, such as tcpinfo.Diff.
+//
+// The platform-specific information is filled in only on Linux, where
+// ss runs; the statistics derived from it are not available on other
+// platforms.
+package ss
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mikioh/tcpinfo"
+)
+
+var errMalformedLine = errors.New("malformed line")
+
+// A Conn represents a connection in the output of ss.
+type Conn struct {
+	LocalAddr  string        // local address in the form of "host:port"
+	RemoteAddr string        // remote address in the form of "host:port"
+	CCAlgo     string        // congestion control algorithm; empty when not shown
+	Info       *tcpinfo.Info // connection information
+}
+
+var states = map[string]tcpinfo.State{
+	"UNCONN":     tcpinfo.Closed,
+	"CLOSE":      tcpinfo.Closed,
+	"LISTEN":     tcpinfo.Listen,
+	"SYN-SENT":   tcpinfo.SynSent,
+	"SYN-RECV":   tcpinfo.SynReceived,
+	"ESTAB":      tcpinfo.Established,
+	"FIN-WAIT-1": tcpinfo.FinWait1,
+	"FIN-WAIT-2": tcpinfo.FinWait2,
+	"CLOSE-WAIT": tcpinfo.CloseWait,
+	"LAST-ACK":   tcpinfo.LastAck,
+	"CLOSING":    tcpinfo.Closing,
+	"TIME-WAIT":  tcpinfo.TimeWait,
+}
+
+// flags holds the names of information shown without value.
+var flags = map[string]bool{
+	"ts":          true,
+	"sack":        true,
+	"ecn":         true,
+	"ecnseen":     true,
+	"fastopen":    true,
+	"app_limited": true,
+	"orphaned":    true,
+}
+
+// rateKeys holds the names of information shown with value separated
+// by a space.
+var rateKeys = map[string]bool{
+	"send":          true,
+	"pacing_rate":   true,
+	"delivery_rate": true,
+}
+
+// Parse parses the text output of "ss -ti" read from r.
+func Parse(r io.Reader) ([]Conn, error) {
+	var (
+		cs  []Conn
+		cur *entry
+		n   int
+	)
+	flush := func() error {
+		if cur == nil {
+			return nil
+		}
+		c, err := cur.conn()
+		if err != nil {
+			return fmt.Errorf("line %d: %v", cur.line, err)
+		}
+		cs = append(cs, *c)
+		cur = nil
+		return nil
+	}
+	sc := bufio.NewScanner(r)
+	sc.Buffer(nil, 1<<20)
+	for sc.Scan() {
+		n++
+		line := sc.Text()
+		fs := strings.Fields(line)
+		if len(fs) == 0 {
+			continue
+		}
+		if line[0] == ' ' || line[0] == '\t' {
+			if cur == nil {
+				return nil, fmt.Errorf("line %d: %v", n, errMalformedLine)
+			}
+			cur.parseDetails(fs)
+			continue
+		}
+		if err := flush(); err != nil {
+			return nil, err
+		}
+		if fs[0] == "Netid" || fs[0] == "State" || fs[0] == "Recv-Q" {
+			continue
+		}
+		if fs[0] == "tcp" || fs[0] == "mptcp" {
+			fs = fs[1:]
+		}
+		e := &entry{line: n, kv: make(map[string]string)}
+		if len(fs) > 0 {
+			// The state column is omitted when filtered by
+			// state.
+			if st, ok := states[fs[0]]; ok {
+				e.state = st
+				fs = fs[1:]
+			} else if _, err := strconv.ParseUint(fs[0], 10, 64); err != nil {
+				return nil, fmt.Errorf("line %d: unknown state %s", n, fs[0])
+			}
+		}
+		if len(fs) < 4 {
+			return nil, fmt.Errorf("line %d: %v", n, errMalformedLine)
+		}
+		e.kv["recv_q"], e.kv["send_q"] = fs[0], fs[1]
+		e.laddr, e.raddr = fs[2], fs[3]
+		e.parseDetails(fs[4:])
+		cur = e
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	return cs, nil
+}
+
+// ParseJSON parses the JSON output of ss read from r.
+//
+// The output is either an array of objects or a stream of objects,
+// each representing a connection.
+// The object holds the "state", "local", "peer", "recv_q", "send_q"
+// and "cc" members, and the members of connection information named
+// as in the text output, such as "rtt" and "bytes_acked".
+// Information shown without value in the text output, such as "sack",
+// is represented by a boolean.
+func ParseJSON(r io.Reader) ([]Conn, error) {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	var objs []map[string]interface{}
+	for {
+		var v interface{}
+		err := dec.Decode(&v)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch v := v.(type) {
+		case []interface{}:
+			for _, o := range v {
+				obj, ok := o.(map[string]interface{})
+				if !ok {
+					return nil, errors.New("not an object")
+				}
+				objs = append(objs, obj)
+			}
+		case map[string]interface{}:
+			objs = append(objs, v)
+		default:
+			return nil, errors.New("not an object")
+		}
+	}
+	cs := make([]Conn, 0, len(objs))
+	for j, obj := range objs {
+		e := &entry{kv: make(map[string]string)}
+		for k, v := range obj {
+			var s string
+			switch v := v.(type) {
+			case string:
+				s = v
+			case json.Number:
+				s = v.String()
+			case bool:
+				if !v {
+					continue
+				}
+			default:
+				continue
+			}
+			switch k {
+			case "state":
+				st, ok := states[strings.ToUpper(s)]
+				if !ok {
+					return nil, fmt.Errorf("object %d: unknown state %s", j, s)
+				}
+				e.state = st
+			case "local":
+				e.laddr = s
+			case "peer":
+				e.raddr = s
+			case "cc":
+				e.cc = s
+			default:
+				e.kv[k] = s
+			}
+		}
+		c, err := e.conn()
+		if err != nil {
+			return nil, fmt.Errorf("object %d: %v", j, err)
+		}
+		cs = append(cs, *c)
+	}
+	return cs, nil
+}
+
+// An entry represents a connection being parsed.
+type entry struct {
+	line         int // line # in the text output
+	state        tcpinfo.State
+	laddr, raddr string
+	cc           string
+	kv           map[string]string // values by name; empty for flags
+}
+
+func (e *entry) parseDetails(fs []string) {
+	for j := 0; j < len(fs); j++ {
+		f := fs[j]
+		if rateKeys[f] && j+1 < len(fs) {
+			e.kv[f] = fs[j+1]
+			j++
+			continue
+		}
+		if k := strings.IndexByte(f, ':'); k > 0 {
+			e.kv[f[:k]] = f[k+1:]
+			continue
+		}
+		if flags[f] {
+			e.kv[f] = ""
+			continue
+		}
+		if e.cc == "" && isName(f) {
+			e.cc = f
+		}
+	}
+}
+
+// isName reports whether s looks like the name of congestion control
+// algorithm.
+func isName(s string) bool {
+	for j := 0; j < len(s); j++ {
+		switch c := s[j]; {
+		case 'a' <= c && c <= 'z', '0' <= c && c <= '9' && j > 0, c == '_' || c == '-':
+		default:
+			return false
+		}
+	}
+	return len(s) > 0
+}
+
+func (e *entry) conn() (*Conn, error) {
+	c := &Conn{LocalAddr: e.laddr, RemoteAddr: e.raddr, CCAlgo: e.cc}
+	i := &tcpinfo.Info{State: e.state}
+	p := parser{kv: e.kv}
+	if p.has("recv_q") || p.has("send_q") {
+		i.Queue = &tcpinfo.Queue{Receive: uint(p.uint("recv_q")), Send: uint(p.uint("send_q"))}
+	}
+	if v, ok := e.kv["wscale"]; ok {
+		ws := strings.SplitN(v, ",", 2)
+		if len(ws) != 2 {
+			return nil, fmt.Errorf("malformed wscale: %s", v)
+		}
+		i.PeerOptions = append(i.PeerOptions, tcpinfo.WindowScale(p.parseUint("wscale", ws[0])))
+		i.Options = append(i.Options, tcpinfo.WindowScale(p.parseUint("wscale", ws[1])))
+	}
+	if p.has("sack") {
+		i.Options = append(i.Options, tcpinfo.SACKPermitted(true))
+		i.PeerOptions = append(i.PeerOptions, tcpinfo.SACKPermitted(true))
+	}
+	if p.has("ts") {
+		i.Options = append(i.Options, tcpinfo.Timestamps(true))
+		i.PeerOptions = append(i.PeerOptions, tcpinfo.Timestamps(true))
+	}
+	if p.has("ecn") {
+		i.Options = append(i.Options, tcpinfo.ECN(true))
+		i.PeerOptions = append(i.PeerOptions, tcpinfo.ECN(true))
+	}
+	if p.has("fastopen") {
+		i.Options = append(i.Options, tcpinfo.FastOpen(true))
+		i.PeerOptions = append(i.PeerOptions, tcpinfo.FastOpen(true))
+	}
+	i.SenderMSS = tcpinfo.MaxSegSize(p.uint("mss"))
+	i.ReceiverMSS = tcpinfo.MaxSegSize(p.uint("rcvmss"))
+	if v, ok := e.kv["rtt"]; ok {
+		rtt := strings.SplitN(v, "/", 2)
+		i.RTT = p.parseMillis("rtt", rtt[0])
+		if len(rtt) == 2 {
+			i.RTTVar = p.parseMillis("rtt", rtt[1])
+		}
+	}
+	if p.has("rttvar") {
+		i.RTTVar = p.millis("rttvar")
+	}
+	i.RTO = p.millis("rto")
+	i.ATO = p.millis("ato")
+	i.LastDataSent = p.millis("lastsnd")
+	i.LastDataReceived = p.millis("lastrcv")
+	i.LastAckReceived = p.millis("lastack")
+	if p.has("rcv_space") {
+		i.FlowControl = &tcpinfo.FlowControl{ReceiverWindow: uint(p.uint("rcv_space"))}
+	}
+	if p.has("cwnd") || p.has("ssthresh") || p.has("rcv_ssthresh") {
+		i.CongestionControl = &tcpinfo.CongestionControl{
+			SenderSSThreshold:   uint(p.uint("ssthresh")),
+			ReceiverSSThreshold: uint(p.uint("rcv_ssthresh")),
+			SenderWindowSegs:    uint(p.uint("cwnd")),
+		}
+	}
+	i.Sys = newSysInfo(&p)
+	if p.err != nil {
+		return nil, p.err
+	}
+	c.Info = i
+	return c, nil
+}
+
+// A parser parses values of information, keeping the first error.
+type parser struct {
+	kv  map[string]string
+	err error
+}
+
+func (p *parser) has(k string) bool {
+	_, ok := p.kv[k]
+	return ok
+}
+
+func (p *parser) uint(k string) uint64 {
+	v, ok := p.kv[k]
+	if !ok {
+		return 0
+	}
+	return p.parseUint(k, v)
+}
+
+func (p *parser) parseUint(k, v string) uint64 {
+	n, err := strconv.ParseUint(v, 10, 64)
+	if err != nil && p.err == nil {
+		p.err = fmt.Errorf("malformed %s: %s", k, v)
+	}
+	return n
+}
+
+// millis returns the value of k in milliseconds as a duration.
+func (p *parser) millis(k string) time.Duration {
+	v, ok := p.kv[k]
+	if !ok {
+		return 0
+	}
+	return p.parseMillis(k, v)
+}
+
+func (p *parser) parseMillis(k, v string) time.Duration {
+	f, err := strconv.ParseFloat(strings.TrimSuffix(v, "ms"), 64)
+	if err != nil && p.err == nil {
+		p.err = fmt.Errorf("malformed %s: %s", k, v)
+	}
+	return time.Duration(f * float64(time.Millisecond))
+}
+
+// slash returns the values of k in the form of "x/y".
+func (p *parser) slash(k string) (uint64, uint64) {
+	v, ok := p.kv[k]
+	if !ok {
+		return 0, 0
+	}
+	xy := strings.SplitN(v, "/", 2)
+	if len(xy) != 2 {
+		return 0, p.parseUint(k, v)
+	}
+	return p.parseUint(k, xy[0]), p.parseUint(k, xy[1])
+}
+
+// rate returns the value of k in bits per second as bytes per
+// second.
+// The value such as "742.6Mbps" may be followed by the maximum, such
+// as "1485.1Mbps/3.0Gbps", which is ignored.
+func (p *parser) rate(k string) uint64 {
+	v, ok := p.kv[k]
+	if !ok {
+		return 0
+	}
+	s := v
+	if j := strings.IndexByte(s, '/'); j >= 0 {
+		s = s[:j]
+	}
+	s = strings.TrimSuffix(s, "bps")
+	mul := 1.0
+	switch {
+	case strings.HasSuffix(s, "K"):
+		mul, s = 1e3, s[:len(s)-1]
+	case strings.HasSuffix(s, "M"):
+		mul, s = 1e6, s[:len(s)-1]
+	case strings.HasSuffix(s, "G"):
+		mul, s = 1e9, s[:len(s)-1]
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil && p.err == nil {
+		p.err = fmt.Errorf("malformed %s: %s", k, v)
+	}
+	return uint64(f * mul / 8)
+}
@@ -0,0 +1,40 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ss_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mikioh/tcpinfo"
+	"github.com/mikioh/tcpinfo/ss"
+)
+
+func TestParseSysInfo(t *testing.T) {
+	cs, err := ss.Parse(strings.NewReader(ssOutput))
+	if err != nil {
+		t.Fatal(err)
+	}
+	si := cs[0].Info.Sys
+	if si.PathMTU != 1500 || si.AdvertisedMSS != 1448 || si.UnackedSegs != 1 || si.RetransSegs != 0 || si.TotalRetransSegs != 2 || si.SegsOut != 211 || si.DataSegsIn != 88 {
+		t.Fatalf("got %+v", si)
+	}
+	if si.MinRTT != 1500*time.Microsecond || si.ReceiverRTT != 5250*time.Microsecond || si.PacingRate != 66200000/8 || si.DeliveryRate != 3000000 {
+		t.Fatalf("got %v, %v, %d, %d", si.MinRTT, si.ReceiverRTT, si.PacingRate, si.DeliveryRate)
+	}
+
+	const later = `ESTAB 0 0 192.0.2.1:22 192.0.2.2:50000
+	 cubic rtt:3.5/1.25 bytes_acked:148177 bytes_received:9921 segs_out:311 segs_in:300 retrans:0/5
+`
+	cs2, err := ss.Parse(strings.NewReader(later))
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := tcpinfo.Diff(&tcpinfo.Sample{Time: time.Unix(0, 0), Info: cs[0].Info}, &tcpinfo.Sample{Time: time.Unix(1, 0), Info: cs2[0].Info})
+	if d.Duration != time.Second || d.BytesSent != 100000 || d.BytesReceived != 600 || d.SegsSent != 100 || d.RetransSegs != 3 {
+		t.Fatalf("got %+v", d)
+	}
+}
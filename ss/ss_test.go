@@ -0,0 +1,100 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ss_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mikioh/tcpinfo"
+	"github.com/mikioh/tcpinfo/ss"
+)
+
+const ssOutput = `State      Recv-Q Send-Q        Local Address:Port          Peer Address:Port   Process
+ESTAB      0      36              192.0.2.1:22               192.0.2.2:50000
+	 cubic wscale:7,9 rto:204 rtt:3.5/1.25 ato:40 mss:1448 pmtu:1500 rcvmss:1392 advmss:1448 cwnd:10 ssthresh:20 bytes_sent:48213 bytes_retrans:2896 bytes_acked:48177 bytes_received:9321 segs_out:211 segs_in:240 data_segs_out:201 data_segs_in:88 send 33.1Mbps lastsnd:4 lastrcv:8 lastack:4 pacing_rate 66.2Mbps delivery_rate 24Mbps delivered:200 app_limited busy:312ms unacked:1 retrans:0/2 rcv_rtt:5.25 rcv_space:14480 rcv_ssthresh:64088 minrtt:1.5
+LISTEN     0      128                 0.0.0.0:22                 0.0.0.0:*
+	 cubic rto:1000 mss:536 cwnd:10 lastsnd:100 lastrcv:100 lastack:100
+`
+
+func TestParse(t *testing.T) {
+	cs, err := ss.Parse(strings.NewReader(ssOutput))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cs) != 2 {
+		t.Fatalf("got %d connections; want 2", len(cs))
+	}
+	c := cs[0]
+	if c.LocalAddr != "192.0.2.1:22" || c.RemoteAddr != "192.0.2.2:50000" || c.CCAlgo != "cubic" {
+		t.Fatalf("got %+v", c)
+	}
+	i := c.Info
+	if i.State != tcpinfo.Established || i.SenderMSS != 1448 || i.ReceiverMSS != 1392 {
+		t.Fatalf("got %+v", i)
+	}
+	if i.RTT != 3500*time.Microsecond || i.RTTVar != 1250*time.Microsecond || i.RTO != 204*time.Millisecond || i.ATO != 40*time.Millisecond || i.LastDataReceived != 8*time.Millisecond {
+		t.Fatalf("got %v, %v, %v, %v, %v", i.RTT, i.RTTVar, i.RTO, i.ATO, i.LastDataReceived)
+	}
+	if !reflect.DeepEqual(i.Options, []tcpinfo.Option{tcpinfo.WindowScale(9)}) || !reflect.DeepEqual(i.PeerOptions, []tcpinfo.Option{tcpinfo.WindowScale(7)}) {
+		t.Fatalf("got %v, %v", i.Options, i.PeerOptions)
+	}
+	if *i.Queue != (tcpinfo.Queue{Send: 36}) || *i.FlowControl != (tcpinfo.FlowControl{ReceiverWindow: 14480}) || *i.CongestionControl != (tcpinfo.CongestionControl{SenderSSThreshold: 20, ReceiverSSThreshold: 64088, SenderWindowSegs: 10}) {
+		t.Fatalf("got %+v, %+v, %+v", i.Queue, i.FlowControl, i.CongestionControl)
+	}
+	if cs[1].Info.State != tcpinfo.Listen || cs[1].RemoteAddr != "0.0.0.0:*" || cs[1].Info.Queue.Send != 128 {
+		t.Fatalf("got %+v", cs[1])
+	}
+}
+
+func TestParseWithoutState(t *testing.T) {
+	const s = `Recv-Q Send-Q Local Address:Port Peer Address:Port
+0      0      [2001:db8::1]:443  [2001:db8::2]:50000
+	 bbr wscale:7,7 rtt:10/5 mss:1428 cwnd:32 bbr:(bw:24Mbps,mrtt:9.5,pacing_gain:1,cwnd_gain:2) sack ts
+`
+	cs, err := ss.Parse(strings.NewReader(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cs) != 1 || cs[0].CCAlgo != "bbr" || cs[0].LocalAddr != "[2001:db8::1]:443" || cs[0].Info.State != tcpinfo.Unknown || cs[0].Info.CongestionControl.SenderWindowSegs != 32 || len(cs[0].Info.Options) != 3 {
+		t.Fatalf("got %+v, %+v", cs, cs[0].Info)
+	}
+
+	for _, s := range []string{
+		"\t cubic rtt:1/1\n",
+		"ESTAB 0 0 192.0.2.1:22\n",
+		"CLOSED-WAITING 0 0 192.0.2.1:22 192.0.2.2:50000\n",
+		"ESTAB 0 0 192.0.2.1:22 192.0.2.2:50000\n\t cubic rtt:fast\n",
+	} {
+		if _, err := ss.Parse(strings.NewReader(s)); err == nil {
+			t.Fatalf("%q: got nil; want an error", s)
+		}
+	}
+}
+
+func TestParseJSON(t *testing.T) {
+	const s = `[
+	{"state": "ESTAB", "recv_q": 0, "send_q": 36, "local": "192.0.2.1:22", "peer": "192.0.2.2:50000", "cc": "cubic", "wscale": "7,9", "rtt": 3.5, "rttvar": 1.25, "rto": 204, "mss": 1448, "cwnd": 10, "sack": true, "ts": false, "bytes_acked": 48177}
+]`
+	cs, err := ss.ParseJSON(strings.NewReader(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cs) != 1 {
+		t.Fatalf("got %d connections; want 1", len(cs))
+	}
+	i := cs[0].Info
+	if cs[0].CCAlgo != "cubic" || i.State != tcpinfo.Established || i.RTT != 3500*time.Microsecond || i.RTTVar != 1250*time.Microsecond || i.Queue.Send != 36 || i.CongestionControl.SenderWindowSegs != 10 {
+		t.Fatalf("got %+v, %+v", cs[0], i)
+	}
+	if !reflect.DeepEqual(i.Options, []tcpinfo.Option{tcpinfo.WindowScale(9), tcpinfo.SACKPermitted(true)}) {
+		t.Fatalf("got %v", i.Options)
+	}
+	if _, err := ss.ParseJSON(strings.NewReader(`{"state": "ESTAB", "rto": "slow"}`)); err == nil {
+		t.Fatal("got nil; want an error")
+	}
+}
@@ -0,0 +1,35 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ss
+
+import "github.com/mikioh/tcpinfo"
+
+func newSysInfo(p *parser) *tcpinfo.SysInfo {
+	retrans, totalRetrans := p.slash("retrans")
+	return &tcpinfo.SysInfo{
+		PathMTU:           uint(p.uint("pmtu")),
+		AdvertisedMSS:     tcpinfo.MaxSegSize(p.uint("advmss")),
+		Backoffs:          uint(p.uint("backoff")),
+		UnackedSegs:       uint(p.uint("unacked")),
+		SackedSegs:        uint(p.uint("sacked")),
+		LostSegs:          uint(p.uint("lost")),
+		RetransSegs:       uint(retrans),
+		ForwardAckSegs:    uint(p.uint("fackets")),
+		ReorderedSegs:     uint(p.uint("reordering")),
+		ReceiverRTT:       p.millis("rcv_rtt"),
+		TotalRetransSegs:  uint(totalRetrans),
+		PacingRate:        p.rate("pacing_rate"),
+		ThruBytesAcked:    p.uint("bytes_acked"),
+		ThruBytesReceived: p.uint("bytes_received"),
+		SegsOut:           uint(p.uint("segs_out")),
+		SegsIn:            uint(p.uint("segs_in")),
+		NotSentBytes:      uint(p.uint("notsent")),
+		MinRTT:            p.millis("minrtt"),
+		DataSegsOut:       uint(p.uint("data_segs_out")),
+		DataSegsIn:        uint(p.uint("data_segs_in")),
+		DeliveryRate:      p.rate("delivery_rate"),
+		SenderWindow:      uint(p.uint("snd_wnd")),
+	}
+}
@@ -0,0 +1,85 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcpinfo
+
+import (
+	"net"
+	"time"
+)
+
+// A DerivedStats represents platform-independent statistics derived
+// from connection information.
+//
+// A zero value may mean that the statistic is not available on the
+// platform; use the Valid method to tell.
+type DerivedStats struct {
+	MinRTT        time.Duration `json:"min_rtt"`       // minimum round-trip time [Linux only]
+	RetransSegs   uint64        `json:"retrans_segs"`  // # of retransmitted segments [FreeBSD, Linux and NetBSD]
+	RetransBytes  uint64        `json:"retrans_bytes"` // # of retransmitted bytes [Darwin only]
+	SegsSent      uint64        `json:"segs_sent"`     // # of segments sent [Darwin and Linux]
+	SegsReceived  uint64        `json:"segs_rcvd"`     // # of segments received [Darwin and Linux]
+	BytesSent     uint64        `json:"bytes_sent"`    // # of bytes sent; # of bytes acked on Linux [Darwin and Linux]
+	BytesReceived uint64        `json:"bytes_rcvd"`    // # of bytes received [Darwin and Linux]
+	DeliveryRate  uint64        `json:"delivery_rate"` // delivery rate in bytes per second [Linux only]
+	Retransmits   uint64        `json:"rexmits"`       // # of consecutive unrecovered retransmissions on timeout [Linux only]
+	Backoffs      uint64        `json:"backoffs"`      // exponent of current retransmission timeout backoff [Linux only]
+
+	absent uint // bits of statistics not available, in the order of derivedNames
+}
+
+// derivedNames holds the JSON names of the statistics of
+// DerivedStats.
+var derivedNames = [...]string{"min_rtt", "retrans_segs", "retrans_bytes", "segs_sent", "segs_rcvd", "bytes_sent", "bytes_rcvd", "delivery_rate", "rexmits", "backoffs"}
+
+// Stats returns statistics derived from connection information.
+func (i *Info) Stats() *DerivedStats {
+	ds := &DerivedStats{}
+	if i.Sys != nil {
+		i.Sys.derive(ds)
+	}
+	for j, name := range derivedNames {
+		if src := derivedSources[name]; i.Sys == nil || src == "" || i.absent(src) {
+			ds.absent |= 1 << uint(j)
+		}
+	}
+	return ds
+}
+
+// Valid reports whether the statistic with the JSON name, such as
+// "retrans_segs", is available.
+// Statistics built by other than Info.Stats are considered
+// available.
+func (ds *DerivedStats) Valid(name string) bool {
+	for j, n := range derivedNames {
+		if n == name {
+			return ds.absent&(1<<uint(j)) == 0
+		}
+	}
+	return false
+}
+
+// Invalidate marks the statistic with the JSON name not available,
+// such as when decoding statistics from a source lacking it.
+func (ds *DerivedStats) Invalidate(name string) {
+	for j, n := range derivedNames {
+		if n == name {
+			ds.absent |= 1 << uint(j)
+		}
+	}
+}
+
+// A FinalStats represents a summary of connection information
+// captured when the connection is closed.
+type FinalStats struct {
+	Info     *Info         `json:"info"`     // last connection information
+	Err      error         `json:"-"`        // error on retrieval of last connection information
+	Duration time.Duration `json:"duration"` // duration since the sampler was started
+	MinRTT   time.Duration `json:"min_rtt"`  // minimum round-trip time observed
+	Stats    *DerivedStats `json:"stats"`    // statistics derived from last connection information
+}
+
+// A FinalFunc receives a summary of connection information on c when
+// c is closed.
+type FinalFunc func(c net.Conn, fs *FinalStats)
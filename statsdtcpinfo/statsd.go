@@ -0,0 +1,159 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package statsdtcpinfo implements a statsd emitter for TCP
+// connection information.
+//
+// Example:
+//
+//	w, err := net.Dial("udp", "127.0.0.1:8125")
+//	if err != nil {
+//		// error handling
+//	}
+//	e := statsdtcpinfo.NewEmitter(w)
+//	e.Prefix = "myapp.tcp."
+//	ln = &tcpinfo.Listener{Listener: ln, Interval: 10 * time.Second, Func: e.Observe}
+package statsdtcpinfo
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mikioh/tcpinfo"
+)
+
+// An Emitter converts samples of connection information into statsd
+// metrics.
+//
+// Gauges are emitted from each sample and counters from the delta
+// between consecutive samples on the same connection.
+// The exported fields must not be modified after the first call to
+// Observe.
+type Emitter struct {
+	Prefix     string  // prefix of metric names; defaults to "tcpinfo."
+	SampleRate float64 // sampling rate of counters in range (0, 1]; zero means 1
+
+	// Tags returns DogStatsD tags in the form of "key:value" for a
+	// connection.
+	// The labels of samples, such as attached by
+	// tcpinfo.WithLabels, are attached as tags in addition.
+	Tags func(c net.Conn) []string
+
+	mu   sync.Mutex
+	w    io.Writer
+	rand *rand.Rand
+	prev map[net.Conn]*tcpinfo.Sample
+}
+
+// NewEmitter returns a new emitter that writes metrics to w.
+// Typically w is a UDP connection to a statsd server.
+func NewEmitter(w io.Writer) *Emitter {
+	return &Emitter{
+		w:    w,
+		rand: rand.New(rand.NewSource(time.Now().UnixNano())),
+		prev: make(map[net.Conn]*tcpinfo.Sample),
+	}
+}
+
+// Observe emits metrics from the sample s on the connection c.
+// It can be used as a tcpinfo.SampleFunc.
+//
+// Errors on writing are ignored as statsd is a fire-and-forget
+// protocol.
+func (e *Emitter) Observe(c net.Conn, s *tcpinfo.Sample) {
+	if s.Info == nil {
+		if s.Final {
+			e.mu.Lock()
+			delete(e.prev, c)
+			e.mu.Unlock()
+		}
+		return
+	}
+	var tags []string
+	if e.Tags != nil {
+		tags = e.Tags(c)
+	}
+	tags = tags[:len(tags):len(tags)]
+	for _, lb := range s.Labels {
+		tags = append(tags, lb.Name+":"+lb.Value)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	var b bytes.Buffer
+	e.gauge(&b, "rtt", float64(s.Info.RTT)/float64(time.Millisecond), "ms", tags)
+	if cc := s.Info.CongestionControl; cc != nil {
+		if cc.SenderWindowSegs > 0 {
+			e.gauge(&b, "snd_cwnd_segs", float64(cc.SenderWindowSegs), "g", tags)
+		}
+		if cc.SenderWindowBytes > 0 {
+			e.gauge(&b, "snd_cwnd_bytes", float64(cc.SenderWindowBytes), "g", tags)
+		}
+	}
+	if ds := s.Info.Stats(); ds.DeliveryRate > 0 {
+		e.gauge(&b, "delivery_rate", float64(ds.DeliveryRate), "g", tags)
+	}
+	if d := tcpinfo.Diff(e.prev[c], s); d != nil {
+		e.count(&b, "retrans_segs", d.RetransSegs, tags)
+		e.count(&b, "retrans_bytes", d.RetransBytes, tags)
+		e.count(&b, "segs_sent", d.SegsSent, tags)
+		e.count(&b, "segs_rcvd", d.SegsReceived, tags)
+		e.count(&b, "bytes_sent", d.BytesSent, tags)
+		e.count(&b, "bytes_rcvd", d.BytesReceived, tags)
+	}
+	if s.Final {
+		delete(e.prev, c)
+	} else {
+		e.prev[c] = s
+	}
+	if b.Len() > 0 {
+		e.w.Write(bytes.TrimSuffix(b.Bytes(), []byte("\n")))
+	}
+}
+
+func (e *Emitter) gauge(b *bytes.Buffer, name string, v float64, typ string, tags []string) {
+	e.metric(b, name, strconv.FormatFloat(v, 'f', -1, 64), typ, 1, tags)
+}
+
+func (e *Emitter) count(b *bytes.Buffer, name string, v uint64, tags []string) {
+	if v == 0 {
+		return
+	}
+	rate := e.SampleRate
+	if rate <= 0 || rate > 1 {
+		rate = 1
+	}
+	if rate < 1 && e.rand.Float64() >= rate {
+		return
+	}
+	e.metric(b, name, strconv.FormatUint(v, 10), "c", rate, tags)
+}
+
+func (e *Emitter) metric(b *bytes.Buffer, name, v, typ string, rate float64, tags []string) {
+	prefix := e.Prefix
+	if prefix == "" {
+		prefix = "tcpinfo."
+	}
+	b.WriteString(prefix)
+	b.WriteString(name)
+	b.WriteByte(':')
+	b.WriteString(v)
+	b.WriteByte('|')
+	b.WriteString(typ)
+	if rate < 1 {
+		b.WriteString("|@")
+		b.WriteString(strconv.FormatFloat(rate, 'f', -1, 64))
+	}
+	if len(tags) > 0 {
+		b.WriteString("|#")
+		b.WriteString(strings.Join(tags, ","))
+	}
+	b.WriteByte('\n')
+}
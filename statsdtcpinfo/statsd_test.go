@@ -0,0 +1,43 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package statsdtcpinfo_test
+
+import (
+	"bytes"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mikioh/tcpinfo"
+	"github.com/mikioh/tcpinfo/statsdtcpinfo"
+)
+
+func TestEmitter(t *testing.T) {
+	c1, c2 := net.Pipe()
+	defer c1.Close()
+	defer c2.Close()
+
+	var b bytes.Buffer
+	e := statsdtcpinfo.NewEmitter(&b)
+	e.Prefix = "app."
+	e.Tags = func(net.Conn) []string { return []string{"relay:r1"} }
+	now := time.Now()
+	e.Observe(c1, &tcpinfo.Sample{Time: now, Info: &tcpinfo.Info{RTT: 1500 * time.Microsecond}})
+	if got, want := b.String(), "app.rtt:1.5|ms|#relay:r1"; got != want {
+		t.Fatalf("got %q; want %q", got, want)
+	}
+	b.Reset()
+	e.Observe(c1, &tcpinfo.Sample{Time: now.Add(time.Second), Info: &tcpinfo.Info{RTT: time.Millisecond, CongestionControl: &tcpinfo.CongestionControl{SenderWindowSegs: 10}}, Final: true})
+	lines := strings.Split(b.String(), "\n")
+	if len(lines) != 2 || lines[1] != "app.snd_cwnd_segs:10|g|#relay:r1" {
+		t.Fatalf("got %q", lines)
+	}
+	b.Reset()
+	e.Observe(c2, &tcpinfo.Sample{Time: now, Info: &tcpinfo.Info{RTT: time.Millisecond}, Labels: []tcpinfo.Label{{Name: "pod", Value: "web-0"}}})
+	if got, want := b.String(), "app.rtt:1|ms|#relay:r1,pod:web-0"; got != want {
+		t.Fatalf("got %q; want %q", got, want)
+	}
+}
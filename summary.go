@@ -0,0 +1,100 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcpinfo
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// A DurationPercentiles represents percentiles of durations.
+type DurationPercentiles struct {
+	P50 time.Duration `json:"p50"`
+	P90 time.Duration `json:"p90"`
+	P99 time.Duration `json:"p99"`
+	Max time.Duration `json:"max"`
+}
+
+// A ValuePercentiles represents percentiles of values.
+type ValuePercentiles struct {
+	P50 float64 `json:"p50"`
+	P90 float64 `json:"p90"`
+	P99 float64 `json:"p99"`
+	Max float64 `json:"max"`
+}
+
+// A Summary represents a summary of samples of connection
+// information over a window, such as for periodic reporting.
+type Summary struct {
+	Start        time.Time           `json:"start"`         // time of the first sample
+	End          time.Time           `json:"end"`           // time of the last sample
+	Samples      int                 `json:"samples"`       // # of samples with connection information
+	Errors       int                 `json:"errors"`        // # of samples failing retrieval
+	RTT          DurationPercentiles `json:"rtt"`           // round-trip time
+	DeliveryRate *ValuePercentiles   `json:"delivery_rate"` // delivery rate in bytes per second; nil when not available [Linux only]
+	Delta        *Delta              `json:"delta"`         // totals of counters between the first and last samples; nil when unknown
+}
+
+// Summary returns a summary of the samples.
+//
+// Percentiles are of the nearest rank.
+func (h *History) Summary() *Summary {
+	sm := &Summary{}
+	var (
+		rtts        []time.Duration
+		rates       []float64
+		first, last *Sample
+	)
+	for j, s := range h.Samples() {
+		if j == 0 {
+			sm.Start = s.Time
+		}
+		sm.End = s.Time
+		if s.Info == nil {
+			sm.Errors++
+			continue
+		}
+		sm.Samples++
+		rtts = append(rtts, s.Info.RTT)
+		if ds := s.Info.Stats(); ds.Valid("delivery_rate") {
+			rates = append(rates, float64(ds.DeliveryRate))
+		}
+		if first == nil {
+			first = s
+		}
+		last = s
+	}
+	if len(rtts) > 0 {
+		sort.Slice(rtts, func(i, j int) bool { return rtts[i] < rtts[j] })
+		sm.RTT = DurationPercentiles{
+			P50: rtts[rank(len(rtts), 0.5)],
+			P90: rtts[rank(len(rtts), 0.9)],
+			P99: rtts[rank(len(rtts), 0.99)],
+			Max: rtts[len(rtts)-1],
+		}
+	}
+	if len(rates) > 0 {
+		sort.Float64s(rates)
+		sm.DeliveryRate = &ValuePercentiles{
+			P50: rates[rank(len(rates), 0.5)],
+			P90: rates[rank(len(rates), 0.9)],
+			P99: rates[rank(len(rates), 0.99)],
+			Max: rates[len(rates)-1],
+		}
+	}
+	sm.Delta = Diff(first, last)
+	return sm
+}
+
+// rank returns the index of the nearest rank of the quantile q in n
+// sorted values.
+func rank(n int, q float64) int {
+	k := int(math.Ceil(q*float64(n))) - 1
+	if k < 0 {
+		return 0
+	}
+	return k
+}
@@ -0,0 +1,42 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcpinfo_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mikioh/tcpinfo"
+	"github.com/mikioh/tcpinfo/tcpinfotest"
+)
+
+func TestHistorySummary(t *testing.T) {
+	h := tcpinfo.NewHistory(0)
+	start := time.Unix(0, 0)
+	for j := 1; j <= 100; j++ {
+		n := uint64(j)
+		h.Add(&tcpinfo.Sample{Time: start.Add(time.Duration(j) * time.Second), Info: tcpinfotest.NewInfo().RTT(time.Duration(j)*time.Millisecond, 0).Sys(func(si *tcpinfo.SysInfo) {
+			si.DeliveryRate, si.ThruBytesAcked, si.TotalRetransSegs = n*1000, n*1460, uint(n/10)
+		}).Build()})
+	}
+	h.Add(&tcpinfo.Sample{Time: start.Add(101 * time.Second), Err: tcpinfo.ErrNotSupported})
+	sm := h.Summary()
+	if sm.Samples != 100 || sm.Errors != 1 || !sm.Start.Equal(start.Add(time.Second)) || !sm.End.Equal(start.Add(101*time.Second)) {
+		t.Fatalf("got %+v", sm)
+	}
+	if want := (tcpinfo.DurationPercentiles{P50: 50 * time.Millisecond, P90: 90 * time.Millisecond, P99: 99 * time.Millisecond, Max: 100 * time.Millisecond}); sm.RTT != want {
+		t.Fatalf("got %+v; want %+v", sm.RTT, want)
+	}
+	if want := (tcpinfo.ValuePercentiles{P50: 50000, P90: 90000, P99: 99000, Max: 100000}); sm.DeliveryRate == nil || *sm.DeliveryRate != want {
+		t.Fatalf("got %+v; want %+v", sm.DeliveryRate, want)
+	}
+	if sm.Delta == nil || sm.Delta.BytesSent != 99*1460 || sm.Delta.RetransSegs != 10 || sm.Delta.Duration != 99*time.Second {
+		t.Fatalf("got %+v", sm.Delta)
+	}
+
+	if sm := tcpinfo.NewHistory(0).Summary(); sm.Samples != 0 || sm.Delta != nil || sm.DeliveryRate != nil {
+		t.Fatalf("got %+v", sm)
+	}
+}
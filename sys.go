@@ -4,7 +4,13 @@
 
 package tcpinfo
 
-import "github.com/mikioh/tcpopt"
+import (
+	"sync"
+	"time"
+	"unsafe"
+
+	"github.com/mikioh/tcpopt"
+)
 
 func init() {
 	for _, o := range options {
@@ -23,12 +29,116 @@ const (
 	soInfo = iota
 	soCCInfo
 	soCCAlgo
+	soMemInfo
 	soMax
 )
 
+// soKinds holds the kinds of information of the socket options.
+var soKinds = [soMax]string{
+	soInfo:    "tcp_info",
+	soCCInfo:  "tcp_cc_info",
+	soCCAlgo:  "tcp_congestion",
+	soMemInfo: "meminfo",
+}
+
 // An option represents a binding for socket option.
 type option struct {
 	level   int // option level
 	name    int // option name, must be equal or greater than 1
 	parseFn func([]byte) (tcpopt.Option, error)
 }
+
+// getsockoptInt returns the value of the integer socket option.
+// The error returned is the bare errno, as of getsockoptRaw.
+func getsockoptInt(s uintptr, level, name int) (int, error) {
+	var b [4]byte
+	if _, err := getsockoptRaw(s, level, name, b[:]); err != nil {
+		return 0, err
+	}
+	return int(*(*int32)(unsafe.Pointer(&b[0]))), nil
+}
+
+// sockOpts interns the values of socket options, which seldom vary
+// across connections, so that retrieving them does not allocate.
+var sockOpts = optionCache{
+	cc:   make(map[string]Option),
+	vals: make(map[optionKey]Option),
+}
+
+// maxCachedOptions is the maximum number of values per map held in an
+// optionCache.
+const maxCachedOptions = 64
+
+// An optionCache holds boxed option values.
+type optionCache struct {
+	sync.Mutex
+	cc   map[string]Option
+	vals map[optionKey]Option
+}
+
+type optionKey struct {
+	kind OptionKind
+	v    [3]int64
+}
+
+func (c *optionCache) ccAlgorithm(b []byte) Option {
+	c.Lock()
+	defer c.Unlock()
+	if opt, ok := c.cc[string(b)]; ok {
+		return opt
+	}
+	opt := CCAlgorithm(b)
+	if len(c.cc) < maxCachedOptions {
+		c.cc[string(opt)] = opt
+	}
+	return opt
+}
+
+func (c *optionCache) userTimeout(d time.Duration) Option {
+	k := optionKey{kind: KindUserTimeout, v: [3]int64{int64(d)}}
+	if opt, ok := c.lookup(k); ok {
+		return opt
+	}
+	return c.store(k, UserTimeout(d))
+}
+
+func (c *optionCache) keepAlive(ka KeepAlive) Option {
+	k := optionKey{kind: KindKeepAlive, v: [3]int64{int64(ka.Idle), int64(ka.Interval), int64(ka.Count)}}
+	if opt, ok := c.lookup(k); ok {
+		return opt
+	}
+	return c.store(k, ka)
+}
+
+func (c *optionCache) lookup(k optionKey) (Option, bool) {
+	c.Lock()
+	opt, ok := c.vals[k]
+	c.Unlock()
+	return opt, ok
+}
+
+func (c *optionCache) store(k optionKey, opt Option) Option {
+	c.Lock()
+	if len(c.vals) < maxCachedOptions {
+		c.vals[k] = opt
+	}
+	c.Unlock()
+	return opt
+}
+
+// getKeepAlive returns the keepalive option on s.
+// The idle time, interval and count are read from the socket options
+// idle, intvl and cnt at level IPPROTO_TCP, in seconds.
+func getKeepAlive(s uintptr, idle, intvl, cnt int) KeepAlive {
+	var ka KeepAlive
+	if v, err := getsockoptInt(s, ianaProtocolTCP, idle); err == nil {
+		ka.Idle = time.Duration(v) * time.Second
+	}
+	if v, err := getsockoptInt(s, ianaProtocolTCP, intvl); err == nil {
+		ka.Interval = time.Duration(v) * time.Second
+	}
+	if v, err := getsockoptInt(s, ianaProtocolTCP, cnt); err == nil {
+		ka.Count = v
+	}
+	return ka
+}
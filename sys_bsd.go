@@ -7,14 +7,16 @@
 package tcpinfo
 
 import (
-	"errors"
+	"net"
+	"os"
 	"runtime"
+	"syscall"
 	"time"
 	"unsafe"
-
-	"github.com/mikioh/tcpopt"
 )
 
+const sizeofInfoBuf = sizeofTCPInfo
+
 var options = [soMax]option{
 	soInfo: {ianaProtocolTCP, sysTCP_INFO, parseInfo},
 }
@@ -34,25 +36,64 @@ type SysInfo struct {
 	Offloading        bool `json:"offloading"`      // TCP offload processing
 }
 
-var sysStates = [11]State{Closed, Listen, SynSent, SynReceived, Established, CloseWait, FinWait1, Closing, LastAck, FinWait2, TimeWait}
+func (si *SysInfo) derive(ds *DerivedStats) {
+	ds.RetransSegs = uint64(si.RetransSegs)
+}
+
+func notSentBytes(i *Info) (uint, bool) { return 0, false }
+
+func reordering(i *Info) (uint, bool) { return 0, false }
+
+func tailLossRisk(i *Info) bool { return false }
+
+func pathMTU(i *Info) (uint, bool) { return 0, false }
 
-func parseInfo(b []byte) (tcpopt.Option, error) {
-	if len(b) < sizeofTCPInfo {
-		return nil, errors.New("short buffer")
+func zeroWindow(i *Info) *ZeroWindow {
+	zw := &ZeroWindow{Advertised: i.Sys.ZeroWindowUpdates}
+	wnd := i.Sys.SenderWindowBytes
+	if runtime.GOOS == "netbsd" {
+		wnd = i.Sys.SenderWindowSegs
 	}
-	ti := (*tcpInfo)(unsafe.Pointer(&b[0]))
-	i := &Info{State: sysStates[ti.State]}
-	if ti.Options&sysTCPI_OPT_WSCALE != 0 {
-		i.Options = append(i.Options, WindowScale(ti.Pad_cgo_0[0]>>4))
-		i.PeerOptions = append(i.PeerOptions, WindowScale(ti.Pad_cgo_0[0]&0x0f))
+	zw.Persist = wnd == 0 && i.pending(0) > 0
+	return zw
+}
+
+var sysStates = bsdStates
+
+func parseInfoInto(b []byte, i *Info, m FieldMask, mode ParseMode) error {
+	var ti *tcpInfo
+	switch {
+	case len(b) >= sizeofTCPInfo && uintptr(unsafe.Pointer(&b[0]))%unsafe.Alignof(tcpInfo{}) == 0:
+		ti = (*tcpInfo)(unsafe.Pointer(&b[0]))
+	case len(b) >= sizeofTCPInfo || mode == ParseLenient:
+		ti = new(tcpInfo)
+		copy((*[sizeofTCPInfo]byte)(unsafe.Pointer(ti))[:], b)
+	default:
+		return errShortBuffer("tcp_info", sizeofTCPInfo, len(b))
 	}
-	if ti.Options&sysTCPI_OPT_SACK != 0 {
-		i.Options = append(i.Options, SACKPermitted(true))
-		i.PeerOptions = append(i.PeerOptions, SACKPermitted(true))
+	i.recycle(m)
+	i.KernelStructSize = len(b)
+	i.Truncated = len(b) < sizeofTCPInfo
+	if int(ti.State) < len(sysStates) {
+		i.State = sysStates[ti.State]
 	}
-	if ti.Options&sysTCPI_OPT_TIMESTAMPS != 0 {
-		i.Options = append(i.Options, Timestamps(true))
-		i.PeerOptions = append(i.PeerOptions, Timestamps(true))
+	if m&FieldOptions != 0 {
+		if ti.Options&sysTCPI_OPT_WSCALE != 0 {
+			i.Options = append(i.Options, WindowScale(ti.Pad_cgo_0[0]>>4))
+			i.PeerOptions = append(i.PeerOptions, WindowScale(ti.Pad_cgo_0[0]&0x0f))
+		}
+		if ti.Options&sysTCPI_OPT_SACK != 0 {
+			i.Options = append(i.Options, SACKPermitted(true))
+			i.PeerOptions = append(i.PeerOptions, SACKPermitted(true))
+		}
+		if ti.Options&sysTCPI_OPT_TIMESTAMPS != 0 {
+			i.Options = append(i.Options, Timestamps(true))
+			i.PeerOptions = append(i.PeerOptions, Timestamps(true))
+		}
+		if ti.Options&sysTCPI_OPT_ECN != 0 {
+			i.Options = append(i.Options, ECN(true))
+			i.PeerOptions = append(i.PeerOptions, ECN(true))
+		}
 	}
 	i.SenderMSS = MaxSegSize(ti.Snd_mss)
 	i.ReceiverMSS = MaxSegSize(ti.Rcv_mss)
@@ -63,34 +104,160 @@ func parseInfo(b []byte) (tcpopt.Option, error) {
 	i.LastDataSent = time.Duration(ti.X__tcpi_last_data_sent) * time.Microsecond
 	i.LastDataReceived = time.Duration(ti.Last_data_recv) * time.Microsecond
 	i.LastAckReceived = time.Duration(ti.X__tcpi_last_ack_recv) * time.Microsecond
-	i.FlowControl = &FlowControl{
-		ReceiverWindow: uint(ti.Rcv_space),
+	if i.FlowControl != nil {
+		*i.FlowControl = FlowControl{
+			ReceiverWindow: uint(ti.Rcv_space),
+		}
 	}
-	i.CongestionControl = &CongestionControl{
-		SenderSSThreshold:   uint(ti.Snd_ssthresh),
-		ReceiverSSThreshold: uint(ti.X__tcpi_rcv_ssthresh),
+	if i.CongestionControl != nil {
+		*i.CongestionControl = CongestionControl{
+			SenderSSThreshold:   uint(ti.Snd_ssthresh),
+			ReceiverSSThreshold: uint(ti.X__tcpi_rcv_ssthresh),
+		}
 	}
-	i.Sys = &SysInfo{
-		NextEgressSeq:     uint(ti.Snd_nxt),
-		NextIngressSeq:    uint(ti.Rcv_nxt),
-		RetransSegs:       uint(ti.Snd_rexmitpack),
-		OutOfOrderSegs:    uint(ti.Rcv_ooopack),
-		ZeroWindowUpdates: uint(ti.Snd_zerowin),
-	}
-	if ti.Options&sysTCPI_OPT_TOE != 0 {
-		i.Sys.Offloading = true
+	if i.Sys != nil {
+		*i.Sys = SysInfo{
+			NextEgressSeq:     uint(ti.Snd_nxt),
+			NextIngressSeq:    uint(ti.Rcv_nxt),
+			RetransSegs:       uint(ti.Snd_rexmitpack),
+			OutOfOrderSegs:    uint(ti.Rcv_ooopack),
+			ZeroWindowUpdates: uint(ti.Snd_zerowin),
+		}
+		if ti.Options&sysTCPI_OPT_TOE != 0 {
+			i.Sys.Offloading = true
+		}
 	}
 	switch runtime.GOOS {
 	case "freebsd":
-		i.CongestionControl.SenderWindowBytes = uint(ti.Snd_cwnd)
-		i.Sys.SenderWindowBytes = uint(ti.Snd_wnd)
+		if i.CongestionControl != nil {
+			i.CongestionControl.SenderWindowBytes = uint(ti.Snd_cwnd)
+		}
+		if i.Sys != nil {
+			i.Sys.SenderWindowBytes = uint(ti.Snd_wnd)
+		}
 	case "netbsd":
-		i.CongestionControl.SenderWindowSegs = uint(ti.Snd_cwnd)
-		i.Sys.SenderWindowSegs = uint(ti.Snd_wnd)
+		if i.CongestionControl != nil {
+			i.CongestionControl.SenderWindowSegs = uint(ti.Snd_cwnd)
+		}
+		if i.Sys != nil {
+			i.Sys.SenderWindowSegs = uint(ti.Snd_wnd)
+		}
 	}
-	return i, nil
+	return nil
+}
+
+func sysInfoLen(name string) int { return 0 }
+
+func sysSockOpt(name string) bool { return false }
+
+func sysUnreported(name string) bool {
+	switch name {
+	case "ato", "last_data_sent", "last_ack_rcvd", "rcv_ssthresh":
+		return true
+	case "last_data_rcvd", "snd_cwnd_bytes", "snd_wnd_bytes":
+		return runtime.GOOS == "netbsd"
+	case "snd_cwnd_segs", "snd_wnd_segs":
+		return runtime.GOOS == "freebsd"
+	}
+	return false
+}
+
+// derivedSources maps the statistics of DerivedStats to the fields of
+// SysInfo they derive from, by JSON name.
+var derivedSources = map[string]string{
+	"retrans_segs": "retrans_segs",
 }
 
 func parseCCAlgorithmInfo(name string, b []byte) (CCAlgorithmInfo, error) {
-	return nil, errors.New("operation not supported")
+	return nil, errNotSupported("parse", name)
+}
+
+func parseCCAlgorithmInfoInto(b []byte, ccai CCAlgorithmInfo) error {
+	return errNotSupported("parse", "cc_algorithm_info")
+}
+
+func getQueue(s uintptr, q *Queue) error {
+	var rcv, snd int32
+	if err := ioctl(s, sysFIONREAD, &rcv); err != nil {
+		return err
+	}
+	if err := ioctl(s, sysFIONWRITE, &snd); err != nil {
+		return err
+	}
+	*q = Queue{Receive: uint(rcv), Send: uint(snd)}
+	return nil
+}
+
+func getMPTCP(s uintptr) (*MPTCPInfo, error) {
+	return nil, errNotSupported("get", "mptcp_info")
+}
+
+func getOffload(s uintptr, name string, index int, i *Info) (*Offload, error) {
+	return nil, errNotSupported("get", "offload")
+}
+
+func getRoute(src, dst net.IP) (*Route, error) {
+	return nil, errNotSupported("get", "route")
+}
+
+func getListener(s uintptr, laddr *net.TCPAddr) (*ListenerInfo, error) {
+	return nil, errNotSupported("get", "listener_info")
+}
+
+func getHostStats() (*HostStats, error) {
+	s, err := syscall.Sysctl("net.inet.tcp.stats")
+	if err != nil {
+		return nil, os.NewSyscallError("sysctl", err)
+	}
+	// Both platforms lay out the leading counters of struct
+	// tcpstat as 64-bit integers, but NetBSD has no counter of
+	// drops for too small maximum segment sizes.
+	rexmttimeo := 11
+	if runtime.GOOS == "netbsd" {
+		rexmttimeo = 10
+	}
+	var vs [12]uint64
+	if len(s) < 8*(rexmttimeo+1) {
+		return nil, errShortBuffer("host_stats", 8*(rexmttimeo+1), len(s))
+	}
+	copy((*[len(vs) * 8]byte)(unsafe.Pointer(&vs))[:], s)
+	return &HostStats{
+		ActiveOpens:  vs[0], // tcps_connattempt
+		PassiveOpens: vs[1], // tcps_accepts
+		EstabResets:  vs[3], // tcps_drops
+		AttemptFails: vs[4], // tcps_conndrops
+		Timeouts:     vs[rexmttimeo],
+	}, nil
+}
+
+func sysRetries() int { return 0 }
+
+func getSysOptions(s uintptr, si *SysInfo) {}
+
+func getAuthOptions(s uintptr) []Option {
+	var b [4]byte
+	if _, err := getsockoptRaw(s, ianaProtocolTCP, sysTCP_MD5SIG, b[:]); err != nil {
+		return nil
+	}
+	if *(*int32)(unsafe.Pointer(&b[0])) == 0 {
+		return nil
+	}
+	return []Option{MD5Signature(true)}
+}
+
+// appendSocketOptions appends the socket options affecting latency
+// and the detection of dead peers to opts.
+func appendSocketOptions(opts []Option, s uintptr) []Option {
+	if v, err := getsockoptInt(s, ianaProtocolTCP, sysTCP_NODELAY); err == nil {
+		opts = append(opts, NoDelay(v != 0))
+	}
+	if v, err := getsockoptInt(s, sysSOL_SOCKET, sysSO_KEEPALIVE); err == nil && v != 0 {
+		opts = append(opts, sockOpts.keepAlive(getKeepAlive(s, sysTCP_KEEPIDLE, sysTCP_KEEPINTVL, sysTCP_KEEPCNT)))
+	}
+	return opts
+}
+
+func sysKernelRelease() string {
+	s, _ := syscall.Sysctl("kern.osrelease")
+	return s
 }
@@ -5,13 +5,15 @@
 package tcpinfo
 
 import (
-	"errors"
+	"net"
+	"os"
+	"syscall"
 	"time"
 	"unsafe"
-
-	"github.com/mikioh/tcpopt"
 )
 
+const sizeofInfoBuf = sizeofTCPConnectionInfo
+
 var options = [soMax]option{
 	soInfo: {ianaProtocolTCP, sysTCP_CONNECTION_INFO, parseInfo},
 }
@@ -56,53 +58,196 @@ type SysInfo struct {
 	OutOfOrderBytesReceived uint64        `json:"ooo_bytes_rcvd"` // # of our-of-order bytes received
 }
 
-var sysStates = [11]State{Closed, Listen, SynSent, SynReceived, Established, CloseWait, FinWait1, Closing, LastAck, FinWait2, TimeWait}
+func (si *SysInfo) derive(ds *DerivedStats) {
+	ds.RetransBytes = si.RetransBytes
+	ds.SegsSent = si.SegsSent
+	ds.SegsReceived = si.SegsReceived
+	ds.BytesSent = si.BytesSent
+	ds.BytesReceived = si.BytesReceived
+}
 
-func parseInfo(b []byte) (tcpopt.Option, error) {
-	if len(b) < sizeofTCPConnectionInfo {
-		return nil, errors.New("short buffer")
-	}
-	tci := (*tcpConnectionInfo)(unsafe.Pointer(&b[0]))
-	i := &Info{State: sysStates[tci.State]}
-	if tci.Options&sysTCPCI_OPT_WSCALE != 0 {
-		i.Options = append(i.Options, WindowScale(tci.Snd_wscale))
-		i.PeerOptions = append(i.PeerOptions, WindowScale(tci.Rcv_wscale))
+func notSentBytes(i *Info) (uint, bool) { return 0, false }
+
+func reordering(i *Info) (uint, bool) { return 0, false }
+
+func tailLossRisk(i *Info) bool { return false }
+
+func pathMTU(i *Info) (uint, bool) { return 0, false }
+
+func zeroWindow(i *Info) *ZeroWindow {
+	return &ZeroWindow{Persist: i.Sys.SenderWindow == 0 && i.pending(i.Sys.SenderInUse) > 0}
+}
+
+var sysStates = bsdStates
+
+func parseInfoInto(b []byte, i *Info, m FieldMask, mode ParseMode) error {
+	var tci *tcpConnectionInfo
+	switch {
+	case len(b) >= sizeofTCPConnectionInfo && uintptr(unsafe.Pointer(&b[0]))%unsafe.Alignof(tcpConnectionInfo{}) == 0:
+		tci = (*tcpConnectionInfo)(unsafe.Pointer(&b[0]))
+	case len(b) >= sizeofTCPConnectionInfo || mode == ParseLenient:
+		tci = new(tcpConnectionInfo)
+		copy((*[sizeofTCPConnectionInfo]byte)(unsafe.Pointer(tci))[:], b)
+	default:
+		return errShortBuffer("tcp_connection_info", sizeofTCPConnectionInfo, len(b))
 	}
-	if tci.Options&sysTCPCI_OPT_SACK != 0 {
-		i.Options = append(i.Options, SACKPermitted(true))
-		i.PeerOptions = append(i.PeerOptions, SACKPermitted(true))
+	i.recycle(m)
+	i.KernelStructSize = len(b)
+	i.Truncated = len(b) < sizeofTCPConnectionInfo
+	if int(tci.State) < len(sysStates) {
+		i.State = sysStates[tci.State]
 	}
-	if tci.Options&sysTCPCI_OPT_TIMESTAMPS != 0 {
-		i.Options = append(i.Options, Timestamps(true))
-		i.PeerOptions = append(i.PeerOptions, Timestamps(true))
+	if m&FieldOptions != 0 {
+		if tci.Options&sysTCPCI_OPT_WSCALE != 0 {
+			i.Options = append(i.Options, WindowScale(tci.Snd_wscale))
+			i.PeerOptions = append(i.PeerOptions, WindowScale(tci.Rcv_wscale))
+		}
+		if tci.Options&sysTCPCI_OPT_SACK != 0 {
+			i.Options = append(i.Options, SACKPermitted(true))
+			i.PeerOptions = append(i.PeerOptions, SACKPermitted(true))
+		}
+		if tci.Options&sysTCPCI_OPT_TIMESTAMPS != 0 {
+			i.Options = append(i.Options, Timestamps(true))
+			i.PeerOptions = append(i.PeerOptions, Timestamps(true))
+		}
+		if tci.Options&sysTCPCI_OPT_ECN != 0 {
+			i.Options = append(i.Options, ECN(true))
+			i.PeerOptions = append(i.PeerOptions, ECN(true))
+		}
 	}
 	i.SenderMSS = MaxSegSize(tci.Maxseg)
 	i.ReceiverMSS = MaxSegSize(tci.Maxseg)
 	i.RTT = time.Duration(tci.Rttcur) * time.Millisecond
 	i.RTTVar = time.Duration(tci.Rttvar) * time.Millisecond
 	i.RTO = time.Duration(tci.Rto) * time.Millisecond
-	i.FlowControl = &FlowControl{
-		ReceiverWindow: uint(tci.Rcv_wnd),
+	if i.FlowControl != nil {
+		*i.FlowControl = FlowControl{
+			ReceiverWindow: uint(tci.Rcv_wnd),
+		}
 	}
-	i.CongestionControl = &CongestionControl{
-		SenderSSThreshold: uint(tci.Snd_ssthresh),
-		SenderWindowBytes: uint(tci.Snd_cwnd),
+	if i.CongestionControl != nil {
+		*i.CongestionControl = CongestionControl{
+			SenderSSThreshold: uint(tci.Snd_ssthresh),
+			SenderWindowBytes: uint(tci.Snd_cwnd),
+		}
 	}
-	i.Sys = &SysInfo{
-		Flags:                   SysFlags(tci.Flags),
-		SenderWindow:            uint(tci.Snd_wnd),
-		SenderInUse:             uint(tci.Snd_sbbytes),
-		SRTT:                    time.Duration(tci.Srtt) * time.Millisecond,
-		SegsSent:                uint64(tci.Txpackets),
-		BytesSent:               uint64(tci.Txbytes),
-		RetransBytes:            uint64(tci.Txretransmitbytes),
-		SegsReceived:            uint64(tci.Rxpackets),
-		BytesReceived:           uint64(tci.Rxbytes),
-		OutOfOrderBytesReceived: uint64(tci.Rxoutoforderbytes),
+	if i.Sys != nil {
+		*i.Sys = SysInfo{
+			Flags:                   SysFlags(tci.Flags),
+			SenderWindow:            uint(tci.Snd_wnd),
+			SenderInUse:             uint(tci.Snd_sbbytes),
+			SRTT:                    time.Duration(tci.Srtt) * time.Millisecond,
+			SegsSent:                uint64(tci.Txpackets),
+			BytesSent:               uint64(tci.Txbytes),
+			RetransBytes:            uint64(tci.Txretransmitbytes),
+			SegsReceived:            uint64(tci.Rxpackets),
+			BytesReceived:           uint64(tci.Rxbytes),
+			OutOfOrderBytesReceived: uint64(tci.Rxoutoforderbytes),
+		}
 	}
-	return i, nil
+	return nil
+}
+
+func sysInfoLen(name string) int { return 0 }
+
+func sysSockOpt(name string) bool { return false }
+
+func sysUnreported(name string) bool {
+	switch name {
+	case "ato", "last_data_sent", "last_data_rcvd", "last_ack_rcvd", "rcv_ssthresh", "snd_cwnd_segs":
+		return true
+	}
+	return false
+}
+
+// derivedSources maps the statistics of DerivedStats to the fields of
+// SysInfo they derive from, by JSON name.
+var derivedSources = map[string]string{
+	"retrans_bytes": "retrans_bytes",
+	"segs_sent":     "segs_sent",
+	"segs_rcvd":     "segs_rcvd",
+	"bytes_sent":    "bytes_sent",
+	"bytes_rcvd":    "bytes_rcvd",
 }
 
 func parseCCAlgorithmInfo(name string, b []byte) (CCAlgorithmInfo, error) {
-	return nil, errors.New("operation not supported")
+	return nil, errNotSupported("parse", name)
+}
+
+func parseCCAlgorithmInfoInto(b []byte, ccai CCAlgorithmInfo) error {
+	return errNotSupported("parse", "cc_algorithm_info")
+}
+
+func getQueue(s uintptr, q *Queue) error {
+	var rcv int32
+	if err := ioctl(s, sysFIONREAD, &rcv); err != nil {
+		return err
+	}
+	var b [4]byte
+	if _, err := getsockopt(s, sysSOL_SOCKET, sysSO_NWRITE, b[:]); err != nil {
+		return err
+	}
+	*q = Queue{Receive: uint(rcv), Send: uint(*(*int32)(unsafe.Pointer(&b[0])))}
+	return nil
+}
+
+func getMPTCP(s uintptr) (*MPTCPInfo, error) {
+	return nil, errNotSupported("get", "mptcp_info")
+}
+
+func getOffload(s uintptr, name string, index int, i *Info) (*Offload, error) {
+	return nil, errNotSupported("get", "offload")
+}
+
+func getRoute(src, dst net.IP) (*Route, error) {
+	return nil, errNotSupported("get", "route")
+}
+
+func getListener(s uintptr, laddr *net.TCPAddr) (*ListenerInfo, error) {
+	return nil, errNotSupported("get", "listener_info")
+}
+
+func getHostStats() (*HostStats, error) {
+	s, err := syscall.Sysctl("net.inet.tcp.stats")
+	if err != nil {
+		return nil, os.NewSyscallError("sysctl", err)
+	}
+	// The counters of struct tcpstat are 32-bit integers.
+	var vs [19]uint32
+	if len(s) < len(vs)*4 {
+		return nil, errShortBuffer("host_stats", len(vs)*4, len(s))
+	}
+	copy((*[len(vs) * 4]byte)(unsafe.Pointer(&vs))[:], s)
+	return &HostStats{
+		ActiveOpens:  uint64(vs[0]),  // tcps_connattempt
+		PassiveOpens: uint64(vs[1]),  // tcps_accepts
+		EstabResets:  uint64(vs[3]),  // tcps_drops
+		AttemptFails: uint64(vs[4]),  // tcps_conndrops
+		Timeouts:     uint64(vs[10]), // tcps_rexmttimeo
+		OutSegs:      uint64(vs[15]), // tcps_sndtotal
+		RetransSegs:  uint64(vs[18]), // tcps_sndrexmitpack
+	}, nil
+}
+
+func sysRetries() int { return 0 }
+
+func getSysOptions(s uintptr, si *SysInfo) {}
+
+func getAuthOptions(s uintptr) []Option { return nil }
+
+// appendSocketOptions appends the socket options affecting latency
+// and the detection of dead peers to opts.
+func appendSocketOptions(opts []Option, s uintptr) []Option {
+	if v, err := getsockoptInt(s, ianaProtocolTCP, sysTCP_NODELAY); err == nil {
+		opts = append(opts, NoDelay(v != 0))
+	}
+	if v, err := getsockoptInt(s, sysSOL_SOCKET, sysSO_KEEPALIVE); err == nil && v != 0 {
+		opts = append(opts, sockOpts.keepAlive(getKeepAlive(s, sysTCP_KEEPALIVE, sysTCP_KEEPINTVL, sysTCP_KEEPCNT)))
+	}
+	return opts
+}
+
+func sysKernelRelease() string {
+	s, _ := syscall.Sysctl("kern.osrelease")
+	return s
 }
@@ -5,18 +5,29 @@
 package tcpinfo
 
 import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
 	"errors"
+	"io/ioutil"
+	"net"
+	"os"
+	"strconv"
 	"strings"
+	"syscall"
 	"time"
 	"unsafe"
-
-	"github.com/mikioh/tcpopt"
 )
 
+// sizeofInfoBuf leaves room for the fields appended to struct
+// tcp_info by newer kernels.
+const sizeofInfoBuf = 256
+
 var options = [soMax]option{
-	soInfo:   {ianaProtocolTCP, sysTCP_INFO, parseInfo},
-	soCCInfo: {ianaProtocolTCP, sysTCP_CC_INFO, parseCCInfo},
-	soCCAlgo: {ianaProtocolTCP, sysTCP_CONGESTION, parseCCAlgorithm},
+	soInfo:    {ianaProtocolTCP, sysTCP_INFO, parseInfo},
+	soCCInfo:  {ianaProtocolTCP, sysTCP_CC_INFO, parseCCInfo},
+	soCCAlgo:  {ianaProtocolTCP, sysTCP_CONGESTION, parseCCAlgorithm},
+	soMemInfo: {sysSOL_SOCKET, sysSO_MEMINFO, parseMemInfo},
 }
 
 // Marshal implements the Marshal method of tcpopt.Option interface.
@@ -74,27 +85,228 @@ type SysInfo struct {
 	MinRTT                  time.Duration `json:"min_rtt"`            // current measured minimum RTT; zero means not available
 	DataSegsOut             uint          `json:"data_segs_out"`      // # of segments sent containing a positive length data segment
 	DataSegsIn              uint          `json:"data_segs_in"`       // # of segments received containing a positive length data segment
+	DeliveryRate            uint64        `json:"delivery_rate"`      // delivery rate in bytes per second; zero means not available
+	SenderWindow            uint          `json:"snd_wnd"`            // peer's advertised receive window in bytes
+	ZeroCopy                bool          `json:"zerocopy"`           // whether SO_ZEROCOPY is enabled for sending with MSG_ZEROCOPY, retrieved with FieldSockOpts
+	Timestamping            uint          `json:"timestamping"`       // flags of SO_TIMESTAMPING; zero means timestamping is disabled, retrieved with FieldSockOpts
+}
+
+// TXTimestamping reports whether timestamping of transmitted data is
+// enabled with SO_TIMESTAMPING.
+//
+// Linux reports no completion counters of MSG_ZEROCOPY or of
+// timestamps; they are delivered on the error queue of the socket.
+func (si *SysInfo) TXTimestamping() bool {
+	return si.Timestamping&(sysSOF_TIMESTAMPING_TX_HARDWARE|sysSOF_TIMESTAMPING_TX_SOFTWARE|sysSOF_TIMESTAMPING_TX_SCHED|sysSOF_TIMESTAMPING_TX_ACK) != 0
+}
+
+func (si *SysInfo) derive(ds *DerivedStats) {
+	ds.MinRTT = si.MinRTT
+	ds.RetransSegs = uint64(si.TotalRetransSegs)
+	ds.SegsSent = uint64(si.SegsOut)
+	ds.SegsReceived = uint64(si.SegsIn)
+	ds.BytesSent = si.ThruBytesAcked
+	ds.BytesReceived = si.ThruBytesReceived
+	ds.DeliveryRate = si.DeliveryRate
+	ds.Retransmits = uint64(si.Retransmissions)
+	ds.Backoffs = uint64(si.Backoffs)
+}
+
+// notSentBytes returns the # of bytes not sent yet.
+func notSentBytes(i *Info) (uint, bool) {
+	if i.Sys == nil || i.absent("not_sent_bytes") {
+		return 0, false
+	}
+	return i.Sys.NotSentBytes, true
+}
+
+// reordering returns the reordering estimate.
+func reordering(i *Info) (uint, bool) {
+	if i.Sys == nil || i.absent("reord_segs") {
+		return 0, false
+	}
+	return i.Sys.ReorderedSegs, true
+}
+
+// pathMTU returns the path MTU.
+func pathMTU(i *Info) (uint, bool) {
+	if i.Sys == nil || i.absent("path_mtu") {
+		return 0, false
+	}
+	return i.Sys.PathMTU, true
+}
+
+// tailLossRisk reports whether data is outstanding without selective
+// acknowledgments, lost segments or loss recovery in progress.
+func tailLossRisk(i *Info) bool {
+	if i.Sys == nil || i.absent("unacked_segs") {
+		return false
+	}
+	return i.Sys.UnackedSegs > 0 && i.Sys.SackedSegs == 0 && i.Sys.LostSegs == 0 && i.Sys.CAState == CAOpen
+}
+
+func zeroWindow(i *Info) *ZeroWindow {
+	zw := &ZeroWindow{}
+	// Window probes are sent when data waits to be sent and none is
+	// in flight, whereas keepalive probes are sent when no data is
+	// queued at all.
+	unsent := i.Sys.UnackedSegs == 0 && (i.Sys.NotSentBytes > 0 || i.Queue != nil && i.Queue.Send > 0)
+	if unsent {
+		zw.Probes = i.Sys.WindowOrKeepAliveProbes
+	}
+	if i.absent("snd_wnd") {
+		zw.Persist = zw.Probes > 0
+	} else {
+		zw.Persist = i.Sys.SenderWindow == 0 && unsent
+	}
+	return zw
 }
 
-var sysStates = [12]State{Unknown, Established, SynSent, SynReceived, FinWait1, FinWait2, TimeWait, Closed, CloseWait, LastAck, Listen, Closing}
+// Linux 4.9 and above append tcpi_delivery_rate to struct tcp_info.
+const sizeofTCPInfoDeliveryRate = sizeofTCPInfo + 8
 
-func parseInfo(b []byte) (tcpopt.Option, error) {
-	if len(b) < sizeofTCPInfo {
-		return nil, errors.New("short buffer")
+// Linux 5.4 and above report tcpi_snd_wnd at the offset.
+const offsetofTCPInfoSndWnd = 0xe4
+
+// Linux 3.10, the oldest supported, ends struct tcp_info at
+// tcpi_total_retrans.
+const sizeofTCPInfoMin = 0x68
+
+// sizesofTCPInfo holds the lengths of struct tcp_info returned by
+// the supported kernel versions, up to the fields known to the
+// package.
+var sizesofTCPInfo = [...]int{
+	sizeofTCPInfoMin,          // Linux 3.10
+	0x78,                      // Linux 3.15
+	0x88,                      // Linux 4.1
+	0x90,                      // Linux 4.2
+	sizeofTCPInfo,             // Linux 4.6
+	sizeofTCPInfoDeliveryRate, // Linux 4.9
+}
+
+// wantSizeofTCPInfo returns the shortest length of struct tcp_info
+// returned by the supported kernel versions that is not shorter than
+// n, or n when no such length exists.
+func wantSizeofTCPInfo(n int) int {
+	for _, l := range sizesofTCPInfo {
+		if n <= l {
+			return l
+		}
+	}
+	return n
+}
+
+// sysInfoLens holds the lengths of struct tcp_info required for the
+// fields, keyed by JSON name.
+var sysInfoLens = map[string]int{
+	"state":              0x01,
+	"ca_state":           0x02,
+	"rexmits":            0x03,
+	"wnd_ka_probes":      0x04,
+	"backoffs":           0x05,
+	"opts":               0x07,
+	"peer_opts":          0x07,
+	"rto":                0x0c,
+	"ato":                0x10,
+	"snd_mss":            0x14,
+	"rcv_mss":            0x18,
+	"unacked_segs":       0x1c,
+	"sacked_segs":        0x20,
+	"lost_segs":          0x24,
+	"retrans_segs":       0x28,
+	"fack_segs":          0x2c,
+	"last_data_sent":     0x30,
+	"last_data_rcvd":     0x38,
+	"last_ack_rcvd":      0x3c,
+	"path_mtu":           0x40,
+	"rcv_ssthresh":       0x44,
+	"rtt":                0x48,
+	"rttvar":             0x4c,
+	"snd_ssthresh":       0x50,
+	"snd_cwnd_segs":      0x54,
+	"adv_mss":            0x58,
+	"reord_segs":         0x5c,
+	"rcv_rtt":            0x60,
+	"rcv_wnd":            0x64,
+	"total_retrans_segs": 0x68,
+	"pacing_rate":        0x70, // Linux 3.15
+	"thru_bytes_acked":   0x80, // Linux 4.1
+	"thru_bytes_rcvd":    0x88, // Linux 4.1
+	"segs_out":           0x8c, // Linux 4.2
+	"segs_in":            0x90, // Linux 4.2
+	"not_sent_bytes":     0x94, // Linux 4.6
+	"min_rtt":            0x98, // Linux 4.6
+	"data_segs_in":       0x9c, // Linux 4.6
+	"data_segs_out":      0xa0, // Linux 4.6
+	"delivery_rate":      sizeofTCPInfoDeliveryRate,
+	"snd_wnd":            offsetofTCPInfoSndWnd + 4, // Linux 5.4
+}
+
+func sysInfoLen(name string) int { return sysInfoLens[name] }
+
+func sysUnreported(name string) bool { return name == "snd_cwnd_bytes" }
+
+func sysSockOpt(name string) bool { return name == "zerocopy" || name == "timestamping" }
+
+// derivedSources maps the statistics of DerivedStats to the fields of
+// SysInfo they derive from, by JSON name.
+var derivedSources = map[string]string{
+	"min_rtt":       "min_rtt",
+	"retrans_segs":  "total_retrans_segs",
+	"segs_sent":     "segs_out",
+	"segs_rcvd":     "segs_in",
+	"bytes_sent":    "thru_bytes_acked",
+	"bytes_rcvd":    "thru_bytes_rcvd",
+	"delivery_rate": "delivery_rate",
+	"rexmits":       "rexmits",
+	"backoffs":      "backoffs",
+}
+
+var sysStates = linuxStates
+
+func parseInfoInto(b []byte, i *Info, m FieldMask, mode ParseMode) error {
+	var truncated bool
+	switch want := wantSizeofTCPInfo(len(b)); {
+	case mode == ParseLenient:
+		truncated = want != len(b)
+	case mode == ParseStrict && want != len(b):
+		return errShortBuffer("tcp_info", want, len(b))
+	case len(b) < sizeofTCPInfoMin:
+		return errShortBuffer("tcp_info", sizeofTCPInfoMin, len(b))
 	}
-	ti := (*tcpInfo)(unsafe.Pointer(&b[0]))
-	i := &Info{State: sysStates[ti.State]}
-	if ti.Options&sysTCPI_OPT_WSCALE != 0 {
-		i.Options = append(i.Options, WindowScale(ti.Pad_cgo_0[0]>>4))
-		i.PeerOptions = append(i.PeerOptions, WindowScale(ti.Pad_cgo_0[0]&0x0f))
+	var tmp tcpInfo
+	ti := overlayInfo(b)
+	if ti == nil {
+		decodeInfo(&tmp, b, m&FieldSys != 0)
+		ti = &tmp
 	}
-	if ti.Options&sysTCPI_OPT_SACK != 0 {
-		i.Options = append(i.Options, SACKPermitted(true))
-		i.PeerOptions = append(i.PeerOptions, SACKPermitted(true))
+	i.recycle(m)
+	i.KernelStructSize = len(b)
+	i.Truncated = truncated
+	if int(ti.State) < len(sysStates) {
+		i.State = sysStates[ti.State]
 	}
-	if ti.Options&sysTCPI_OPT_TIMESTAMPS != 0 {
-		i.Options = append(i.Options, Timestamps(true))
-		i.PeerOptions = append(i.PeerOptions, Timestamps(true))
+	if m&FieldOptions != 0 {
+		if ti.Options&sysTCPI_OPT_WSCALE != 0 {
+			i.Options = append(i.Options, WindowScale(ti.Pad_cgo_0[0]>>4))
+			i.PeerOptions = append(i.PeerOptions, WindowScale(ti.Pad_cgo_0[0]&0x0f))
+		}
+		if ti.Options&sysTCPI_OPT_SACK != 0 {
+			i.Options = append(i.Options, SACKPermitted(true))
+			i.PeerOptions = append(i.PeerOptions, SACKPermitted(true))
+		}
+		if ti.Options&sysTCPI_OPT_TIMESTAMPS != 0 {
+			i.Options = append(i.Options, Timestamps(true))
+			i.PeerOptions = append(i.PeerOptions, Timestamps(true))
+		}
+		if ti.Options&sysTCPI_OPT_ECN != 0 {
+			i.Options = append(i.Options, ECN(true))
+			i.PeerOptions = append(i.PeerOptions, ECN(true))
+		}
+		if ti.Options&sysTCPI_OPT_SYN_DATA != 0 {
+			i.Options = append(i.Options, FastOpen(true))
+			i.PeerOptions = append(i.PeerOptions, FastOpen(true))
+		}
 	}
 	i.SenderMSS = MaxSegSize(ti.Snd_mss)
 	i.ReceiverMSS = MaxSegSize(ti.Rcv_mss)
@@ -105,81 +317,654 @@ func parseInfo(b []byte) (tcpopt.Option, error) {
 	i.LastDataSent = time.Duration(ti.Last_data_sent) * time.Millisecond
 	i.LastDataReceived = time.Duration(ti.Last_data_recv) * time.Millisecond
 	i.LastAckReceived = time.Duration(ti.Last_ack_recv) * time.Millisecond
-	i.FlowControl = &FlowControl{
-		ReceiverWindow: uint(ti.Rcv_space),
-	}
-	i.CongestionControl = &CongestionControl{
-		SenderSSThreshold:   uint(ti.Snd_ssthresh),
-		ReceiverSSThreshold: uint(ti.Rcv_ssthresh),
-		SenderWindowSegs:    uint(ti.Snd_cwnd),
-	}
-	i.Sys = &SysInfo{
-		PathMTU:                 uint(ti.Pmtu),
-		AdvertisedMSS:           MaxSegSize(ti.Advmss),
-		CAState:                 CAState(ti.Ca_state),
-		Retransmissions:         uint(ti.Retransmits),
-		Backoffs:                uint(ti.Backoff),
-		WindowOrKeepAliveProbes: uint(ti.Probes),
-		UnackedSegs:             uint(ti.Unacked),
-		SackedSegs:              uint(ti.Sacked),
-		LostSegs:                uint(ti.Lost),
-		RetransSegs:             uint(ti.Retrans),
-		ForwardAckSegs:          uint(ti.Fackets),
-		ReorderedSegs:           uint(ti.Reordering),
-		ReceiverRTT:             time.Duration(ti.Rcv_rtt) * time.Microsecond,
-		TotalRetransSegs:        uint(ti.Total_retrans),
-		PacingRate:              uint64(ti.Pacing_rate),
-		ThruBytesAcked:          uint64(ti.Bytes_acked),
-		ThruBytesReceived:       uint64(ti.Bytes_received),
-		SegsIn:                  uint(ti.Segs_in),
-		SegsOut:                 uint(ti.Segs_out),
-		NotSentBytes:            uint(ti.Notsent_bytes),
-		MinRTT:                  time.Duration(ti.Min_rtt) * time.Microsecond,
-		DataSegsIn:              uint(ti.Data_segs_in),
-		DataSegsOut:             uint(ti.Data_segs_out),
-	}
-	return i, nil
+	if i.FlowControl != nil {
+		*i.FlowControl = FlowControl{
+			ReceiverWindow: uint(ti.Rcv_space),
+		}
+	}
+	if i.CongestionControl != nil {
+		*i.CongestionControl = CongestionControl{
+			SenderSSThreshold:   uint(ti.Snd_ssthresh),
+			ReceiverSSThreshold: uint(ti.Rcv_ssthresh),
+			SenderWindowSegs:    uint(ti.Snd_cwnd),
+		}
+	}
+	if i.Sys != nil {
+		*i.Sys = SysInfo{
+			PathMTU:                 uint(ti.Pmtu),
+			AdvertisedMSS:           MaxSegSize(ti.Advmss),
+			CAState:                 CAState(ti.Ca_state),
+			Retransmissions:         uint(ti.Retransmits),
+			Backoffs:                uint(ti.Backoff),
+			WindowOrKeepAliveProbes: uint(ti.Probes),
+			UnackedSegs:             uint(ti.Unacked),
+			SackedSegs:              uint(ti.Sacked),
+			LostSegs:                uint(ti.Lost),
+			RetransSegs:             uint(ti.Retrans),
+			ForwardAckSegs:          uint(ti.Fackets),
+			ReorderedSegs:           uint(ti.Reordering),
+			ReceiverRTT:             time.Duration(ti.Rcv_rtt) * time.Microsecond,
+			TotalRetransSegs:        uint(ti.Total_retrans),
+			PacingRate:              uint64(ti.Pacing_rate),
+			ThruBytesAcked:          uint64(ti.Bytes_acked),
+			ThruBytesReceived:       uint64(ti.Bytes_received),
+			SegsIn:                  uint(ti.Segs_in),
+			SegsOut:                 uint(ti.Segs_out),
+			NotSentBytes:            uint(ti.Notsent_bytes),
+			MinRTT:                  time.Duration(ti.Min_rtt) * time.Microsecond,
+			DataSegsIn:              uint(ti.Data_segs_in),
+			DataSegsOut:             uint(ti.Data_segs_out),
+		}
+		if len(b) >= sizeofTCPInfoDeliveryRate {
+			i.Sys.DeliveryRate = nativeEndian.Uint64(b[sizeofTCPInfo:])
+		}
+		if len(b) >= offsetofTCPInfoSndWnd+4 {
+			i.Sys.SenderWindow = uint(nativeEndian.Uint32(b[offsetofTCPInfoSndWnd:]))
+		}
+	}
+	return nil
 }
 
-func parseCCAlgorithmInfo(name string, b []byte) (CCAlgorithmInfo, error) {
-	if strings.HasPrefix(name, "dctcp") {
-		if len(b) < sizeofTCPDCTCPInfo {
-			return nil, errors.New("short buffer")
+// decodeInfo decodes b into ti field by field.
+// It works on any buffer regardless of its alignment, and leaves the
+// fields beyond the length of b zero.
+// The fields only used for platform-specific information are
+// decoded when sys is true.
+func decodeInfo(ti *tcpInfo, b []byte, sys bool) {
+	var h [8]byte
+	copy(h[:], b)
+	ti.State = h[0]
+	ti.Ca_state = h[1]
+	ti.Retransmits = h[2]
+	ti.Probes = h[3]
+	ti.Backoff = h[4]
+	ti.Options = h[5]
+	ti.Pad_cgo_0 = [2]byte{h[6], h[7]}
+	for j, p := range [...]*uint32{
+		&ti.Rto, &ti.Ato, &ti.Snd_mss, &ti.Rcv_mss,
+		&ti.Unacked, &ti.Sacked, &ti.Lost, &ti.Retrans, &ti.Fackets,
+		&ti.Last_data_sent, &ti.Last_ack_sent, &ti.Last_data_recv, &ti.Last_ack_recv,
+		&ti.Pmtu, &ti.Rcv_ssthresh, &ti.Rtt, &ti.Rttvar, &ti.Snd_ssthresh, &ti.Snd_cwnd,
+		&ti.Advmss, &ti.Reordering, &ti.Rcv_rtt, &ti.Rcv_space, &ti.Total_retrans,
+	} {
+		if len(b) < 12+4*j {
+			return
+		}
+		*p = nativeEndian.Uint32(b[8+4*j:])
+	}
+	if !sys {
+		return
+	}
+	for j, p := range [...]*uint64{
+		&ti.Pacing_rate, &ti.Max_pacing_rate, &ti.Bytes_acked, &ti.Bytes_received,
+	} {
+		if len(b) < 112+8*j {
+			return
 		}
-		pt := unsafe.Pointer(&b[0])
-		sdi := (*tcpDCTCPInfo)(pt)
-		di := &DCTCPInfo{Alpha: uint(sdi.Alpha)}
-		if sdi.Enabled != 0 {
-			di.Enabled = true
+		*p = nativeEndian.Uint64(b[104+8*j:])
+	}
+	for j, p := range [...]*uint32{
+		&ti.Segs_out, &ti.Segs_in, &ti.Notsent_bytes, &ti.Min_rtt,
+		&ti.Data_segs_in, &ti.Data_segs_out,
+	} {
+		if len(b) < 140+4*j {
+			return
 		}
-		return di, nil
+		*p = nativeEndian.Uint32(b[136+4*j:])
+	}
+}
+
+func parseCCAlgorithmInfo(name string, b []byte) (CCAlgorithmInfo, error) {
+	var ccai CCAlgorithmInfo
+	switch {
+	case strings.HasPrefix(name, "dctcp"):
+		ccai = new(DCTCPInfo)
+	case strings.HasPrefix(name, "bbr"):
+		ccai = new(BBRInfo)
+	default:
+		ccai = new(VegasInfo)
 	}
-	if strings.HasPrefix(name, "bbr") {
+	if err := parseCCAlgorithmInfoInto(b, ccai); err != nil {
+		return nil, err
+	}
+	return ccai, nil
+}
+
+func parseCCAlgorithmInfoInto(b []byte, ccai CCAlgorithmInfo) error {
+	switch ccai := ccai.(type) {
+	case *DCTCPInfo:
+		if len(b) < sizeofTCPDCTCPInfo {
+			return errShortBuffer("tcp_dctcp_info", sizeofTCPDCTCPInfo, len(b))
+		}
+		var sdi tcpDCTCPInfo
+		copy((*[sizeofTCPDCTCPInfo]byte)(unsafe.Pointer(&sdi))[:], b)
+		*ccai = DCTCPInfo{Enabled: sdi.Enabled != 0, Alpha: uint(sdi.Alpha)}
+	case *BBRInfo:
 		if len(b) < sizeofTCPBBRInfo {
-			return nil, errors.New("short buffer")
+			return errShortBuffer("tcp_bbr_info", sizeofTCPBBRInfo, len(b))
 		}
-		pt := unsafe.Pointer(&b[0])
-		sdi := (*tcpBBRInfo)(pt)
-		di := &BBRInfo{
+		var sdi tcpBBRInfo
+		copy((*[sizeofTCPBBRInfo]byte)(unsafe.Pointer(&sdi))[:], b)
+		*ccai = BBRInfo{
 			EstBandwidth: uint(sdi.BandwidthHi)<<8 + uint(sdi.BandwidthLo),
 			MinRTT:       uint(sdi.MinRTT),
 			PacingGain:   uint(sdi.PacingGain),
 			CWNDGain:     uint(sdi.CWNDGain),
 		}
-		return di, nil
+	case *VegasInfo:
+		if len(b) < sizeofTCPVegasInfo {
+			return errShortBuffer("tcpvegas_info", sizeofTCPVegasInfo, len(b))
+		}
+		var svi tcpVegasInfo
+		copy((*[sizeofTCPVegasInfo]byte)(unsafe.Pointer(&svi))[:], b)
+		*ccai = VegasInfo{
+			Enabled:    svi.Enabled != 0,
+			RoundTrips: uint(svi.Rttcnt),
+			RTT:        time.Duration(svi.Rtt) * time.Microsecond,
+			MinRTT:     time.Duration(svi.Minrtt) * time.Microsecond,
+		}
+	default:
+		return errors.New("unknown congestion control algorithm information")
+	}
+	return nil
+}
+
+func sysRetries() int {
+	b, err := ioutil.ReadFile("/proc/sys/net/ipv4/tcp_retries2")
+	if err != nil {
+		return 0
+	}
+	n, _ := strconv.Atoi(strings.TrimSpace(string(b)))
+	return n
+}
+
+func getQueue(s uintptr, q *Queue) error {
+	var rcv, snd int32
+	if err := ioctl(s, sysSIOCINQ, &rcv); err != nil {
+		return err
+	}
+	if err := ioctl(s, sysSIOCOUTQ, &snd); err != nil {
+		return err
+	}
+	*q = Queue{Receive: uint(rcv), Send: uint(snd)}
+	return nil
+}
+
+// An ifreq represents struct ifreq carrying a pointer to data, such
+// as of the SIOCETHTOOL request.
+type ifreq struct {
+	Name [16]byte
+	Data unsafe.Pointer
+	_    [16]byte
+}
+
+func getOffload(s uintptr, name string, index int, i *Info) (*Offload, error) {
+	o := &Offload{Interface: name}
+	for _, f := range []struct {
+		cmd uint32
+		v   *bool
+	}{
+		{sysETHTOOL_GTSO, &o.TSO},
+		{sysETHTOOL_GGSO, &o.GSO},
+		{sysETHTOOL_GGRO, &o.GRO},
+	} {
+		ev := ethtoolValue{Cmd: f.cmd}
+		var ifr ifreq
+		copy(ifr.Name[:len(ifr.Name)-1], name)
+		ifr.Data = unsafe.Pointer(&ev)
+		if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, s, sysSIOCETHTOOL, uintptr(unsafe.Pointer(&ifr))); errno != 0 {
+			if errno == syscall.EOPNOTSUPP {
+				continue
+			}
+			return nil, os.NewSyscallError("ioctl", errno)
+		}
+		*f.v = ev.Data != 0
+	}
+	o.GSOMaxSize, o.GSOMaxSegs = gsoMax(index)
+	// The kernel sizes packets to carry about a millisecond worth
+	// of data at the pacing rate, within the bounds.
+	if i.Sys != nil && !i.absent("pacing_rate") && i.Sys.PacingRate > 0 && i.Sys.PacingRate != ^uint64(0) && i.SenderMSS > 0 {
+		n := i.Sys.PacingRate >> 10
+		if o.GSOMaxSize > 0 && n > uint64(o.GSOMaxSize) {
+			n = uint64(o.GSOMaxSize)
+		}
+		segs := uint(n) / uint(i.SenderMSS)
+		if segs < minTSOSegs {
+			segs = minTSOSegs
+		}
+		if o.GSOMaxSegs > 0 && segs > o.GSOMaxSegs {
+			segs = o.GSOMaxSegs
+		}
+		o.Segs = segs
+	}
+	return o, nil
+}
+
+func getRoute(src, dst net.IP) (*Route, error) {
+	family := syscall.AF_INET6
+	if dst.To4() != nil && src.To4() != nil {
+		family, src, dst = syscall.AF_INET, src.To4(), dst.To4()
+	} else {
+		src, dst = src.To16(), dst.To16()
+	}
+	b := make([]byte, syscall.NLMSG_HDRLEN+syscall.SizeofRtMsg)
+	nh := (*syscall.NlMsghdr)(unsafe.Pointer(&b[0]))
+	nh.Type = syscall.RTM_GETROUTE
+	nh.Flags = syscall.NLM_F_REQUEST
+	nh.Seq = 1
+	rtm := (*syscall.RtMsg)(unsafe.Pointer(&b[syscall.NLMSG_HDRLEN]))
+	rtm.Family = uint8(family)
+	rtm.Dst_len = uint8(8 * len(dst))
+	rtm.Src_len = uint8(8 * len(src))
+	rtm.Flags = sysRTM_F_LOOKUP_TABLE
+	b = appendRouteAttr(b, syscall.RTA_DST, dst)
+	b = appendRouteAttr(b, syscall.RTA_SRC, src)
+	(*syscall.NlMsghdr)(unsafe.Pointer(&b[0])).Len = uint32(len(b))
+
+	s, err := syscall.Socket(syscall.AF_NETLINK, syscall.SOCK_RAW|syscall.SOCK_CLOEXEC, syscall.NETLINK_ROUTE)
+	if err != nil {
+		return nil, os.NewSyscallError("socket", err)
+	}
+	defer syscall.Close(s)
+	sa := &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK}
+	if err := syscall.Sendto(s, b, 0, sa); err != nil {
+		return nil, os.NewSyscallError("sendto", err)
+	}
+	b = make([]byte, os.Getpagesize())
+	n, _, err := syscall.Recvfrom(s, b, 0)
+	if err != nil {
+		return nil, os.NewSyscallError("recvfrom", err)
+	}
+	msgs, err := syscall.ParseNetlinkMessage(b[:n])
+	if err != nil {
+		return nil, os.NewSyscallError("netlink", err)
+	}
+	for _, m := range msgs {
+		switch m.Header.Type {
+		case syscall.NLMSG_ERROR:
+			if len(m.Data) >= 4 {
+				if errno := -int32(nativeEndian.Uint32(m.Data)); errno != 0 {
+					return nil, os.NewSyscallError("netlink", syscall.Errno(errno))
+				}
+			}
+		case syscall.RTM_NEWROUTE:
+			return parseRoute(&m)
+		}
+	}
+	return nil, errNotSupported("get", "route")
+}
+
+func appendRouteAttr(b []byte, typ uint16, v []byte) []byte {
+	var h [syscall.SizeofRtAttr]byte
+	nativeEndian.PutUint16(h[0:2], uint16(syscall.SizeofRtAttr+len(v)))
+	nativeEndian.PutUint16(h[2:4], typ)
+	b = append(b, h[:]...)
+	b = append(b, v...)
+	for len(b)%syscall.RTA_ALIGNTO != 0 {
+		b = append(b, 0)
+	}
+	return b
+}
+
+func parseRoute(m *syscall.NetlinkMessage) (*Route, error) {
+	attrs, err := syscall.ParseNetlinkRouteAttr(m)
+	if err != nil {
+		return nil, os.NewSyscallError("netlink", err)
+	}
+	rt := &Route{Table: int((*syscall.RtMsg)(unsafe.Pointer(&m.Data[0])).Table)}
+	for _, a := range attrs {
+		switch a.Attr.Type {
+		case syscall.RTA_OIF:
+			if len(a.Value) >= 4 {
+				rt.Index = int(nativeEndian.Uint32(a.Value))
+			}
+		case syscall.RTA_GATEWAY:
+			rt.Gateway = net.IP(a.Value)
+		case syscall.RTA_PREFSRC:
+			rt.Source = net.IP(a.Value)
+		case syscall.RTA_TABLE:
+			if len(a.Value) >= 4 {
+				rt.Table = int(nativeEndian.Uint32(a.Value))
+			}
+		case syscall.RTA_METRICS:
+			// The metrics are nested attributes.
+			for b := a.Value; len(b) >= syscall.SizeofRtAttr; {
+				l := int(nativeEndian.Uint16(b[0:2]))
+				if l < syscall.SizeofRtAttr || l > len(b) {
+					break
+				}
+				if nativeEndian.Uint16(b[2:4]) == syscall.RTAX_MTU && l >= syscall.SizeofRtAttr+4 {
+					rt.MTU = int(nativeEndian.Uint32(b[syscall.SizeofRtAttr:]))
+				}
+				l = (l + syscall.RTA_ALIGNTO - 1) &^ (syscall.RTA_ALIGNTO - 1)
+				if l > len(b) {
+					break
+				}
+				b = b[l:]
+			}
+		}
+	}
+	if rt.Index > 0 {
+		if ifi, err := net.InterfaceByIndex(rt.Index); err == nil {
+			rt.Interface = ifi.Name
+			if rt.MTU == 0 {
+				rt.MTU = ifi.MTU
+			}
+		}
+	}
+	return rt, nil
+}
+
+func getListener(s uintptr, laddr *net.TCPAddr) (*ListenerInfo, error) {
+	var ti tcpInfo
+	if _, err := getsockopt(s, ianaProtocolTCP, sysTCP_INFO, (*[sizeofTCPInfo]byte)(unsafe.Pointer(&ti))[:]); err != nil {
+		return nil, err
+	}
+	// On listening sockets, the kernel reports the length and
+	// limit of the accept queue in place of the # of
+	// unacknowledged and selectively acknowledged segments.
+	li := &ListenerInfo{AcceptQueue: uint(ti.Unacked), Backlog: uint(ti.Sacked)}
+	if laddr != nil {
+		li.SYNQueue = synQueue(laddr.Port)
+	}
+	if m := readProcNetStat("/proc/net/netstat", "TcpExt"); m != nil {
+		li.Counters = &ListenCounters{
+			Overflows:     m["ListenOverflows"],
+			Drops:         m["ListenDrops"],
+			SYNQueueDrops: m["TCPReqQFullDrop"],
+			SYNCookies:    m["SyncookiesSent"],
+		}
+	}
+	return li, nil
+}
+
+func getHostStats() (*HostStats, error) {
+	tcp := readProcNetStat("/proc/net/snmp", "Tcp")
+	if tcp == nil {
+		return nil, errNotSupported("get", "host_stats")
+	}
+	ext := readProcNetStat("/proc/net/netstat", "TcpExt")
+	hs := &HostStats{
+		ActiveOpens:     tcp["ActiveOpens"],
+		PassiveOpens:    tcp["PassiveOpens"],
+		AttemptFails:    tcp["AttemptFails"],
+		EstabResets:     tcp["EstabResets"],
+		InSegs:          tcp["InSegs"],
+		OutSegs:         tcp["OutSegs"],
+		RetransSegs:     tcp["RetransSegs"],
+		InErrs:          tcp["InErrs"],
+		OutRsts:         tcp["OutRsts"],
+		Timeouts:        ext["TCPTimeouts"],
+		LostRetransmit:  ext["TCPLostRetransmit"],
+		FastRetrans:     ext["TCPFastRetrans"],
+		ListenOverflows: ext["ListenOverflows"],
+		ListenDrops:     ext["ListenDrops"],
+		SYNCookiesSent:  ext["SyncookiesSent"],
+		Counters:        make(map[string]uint64, len(tcp)+len(ext)),
+	}
+	for k, v := range tcp {
+		hs.Counters["Tcp."+k] = v
+	}
+	for k, v := range ext {
+		hs.Counters["TcpExt."+k] = v
+	}
+	return hs, nil
+}
+
+// synQueue returns the # of connection requests to the local port
+// listed in /proc/net/tcp and /proc/net/tcp6.
+func synQueue(port int) uint {
+	var n uint
+	for _, name := range []string{"/proc/net/tcp", "/proc/net/tcp6"} {
+		f, err := os.Open(name)
+		if err != nil {
+			continue
+		}
+		s := bufio.NewScanner(f)
+		s.Scan() // header
+		for s.Scan() {
+			// An entry is such as:
+			//	0: 0100007F:0277 0100007F:D431 03 ...
+			fs := strings.Fields(s.Text())
+			if len(fs) < 4 || fs[3] != "03" {
+				continue
+			}
+			i := strings.LastIndexByte(fs[1], ':')
+			if p, err := strconv.ParseUint(fs[1][i+1:], 16, 16); err == nil && int(p) == port {
+				n++
+			}
+		}
+		f.Close()
+	}
+	return n
+}
+
+// readProcNetStat returns the counters of the protocol in the file
+// name, such as /proc/net/netstat or /proc/net/snmp, which consists
+// of pairs of lines such as:
+//
+//	TcpExt: SyncookiesSent SyncookiesRecv ...
+//	TcpExt: 0 0 ...
+//
+// It returns nil when the counters are not available.
+func readProcNetStat(name, proto string) map[string]uint64 {
+	b, err := ioutil.ReadFile(name)
+	if err != nil {
+		return nil
 	}
-	if len(b) < sizeofTCPVegasInfo {
-		return nil, errors.New("short buffer")
+	var keys []string
+	for _, l := range strings.Split(string(b), "\n") {
+		fs := strings.Fields(l)
+		if len(fs) == 0 || fs[0] != proto+":" {
+			continue
+		}
+		if keys == nil {
+			keys = fs[1:]
+			continue
+		}
+		m := make(map[string]uint64, len(keys))
+		for j, k := range keys {
+			if j+1 < len(fs) {
+				// Some counters, such as Tcp MaxConn,
+				// may be negative.
+				v, _ := strconv.ParseInt(fs[j+1], 10, 64)
+				if v < 0 {
+					continue
+				}
+				m[k] = uint64(v)
+			}
+		}
+		return m
+	}
+	return nil
+}
+
+// gsoMax returns the maximum size and # of segments of a
+// segmentation offload packet of the network interface index.
+func gsoMax(index int) (size, segs uint) {
+	b, err := syscall.NetlinkRIB(syscall.RTM_GETLINK, syscall.AF_UNSPEC)
+	if err != nil {
+		return 0, 0
 	}
-	pt := unsafe.Pointer(&b[0])
-	svi := (*tcpVegasInfo)(pt)
-	vi := &VegasInfo{
-		RoundTrips: uint(svi.Rttcnt),
-		RTT:        time.Duration(svi.Rtt) * time.Microsecond,
-		MinRTT:     time.Duration(svi.Minrtt) * time.Microsecond,
+	msgs, err := syscall.ParseNetlinkMessage(b)
+	if err != nil {
+		return 0, 0
 	}
-	if svi.Enabled != 0 {
-		vi.Enabled = true
+	for _, m := range msgs {
+		if m.Header.Type != syscall.RTM_NEWLINK || len(m.Data) < syscall.SizeofIfInfomsg {
+			continue
+		}
+		ifim := (*syscall.IfInfomsg)(unsafe.Pointer(&m.Data[0]))
+		if int(ifim.Index) != index {
+			continue
+		}
+		attrs, err := syscall.ParseNetlinkRouteAttr(&m)
+		if err != nil {
+			return 0, 0
+		}
+		for _, a := range attrs {
+			if len(a.Value) < 4 {
+				continue
+			}
+			switch a.Attr.Type {
+			case sysIFLA_GSO_MAX_SIZE:
+				size = uint(nativeEndian.Uint32(a.Value))
+			case sysIFLA_GSO_MAX_SEGS:
+				segs = uint(nativeEndian.Uint32(a.Value))
+			}
+		}
+		return size, segs
+	}
+	return 0, 0
+}
+
+// sizeofSubflowInfo is the size of each subflow entry requested
+// from the kernel; large enough for the newer fields of tcp_info.
+const sizeofSubflowInfo = 256
+
+func getMPTCP(s uintptr) (*MPTCPInfo, error) {
+	var b [sizeofMPTCPInfo]byte
+	n, err := getsockopt(s, sysSOL_MPTCP, sysMPTCP_INFO, b[:])
+	if err != nil {
+		return nil, err
+	}
+	var smi mptcpInfo
+	copy((*[sizeofMPTCPInfo]byte)(unsafe.Pointer(&smi))[:], b[:n])
+	mi := &MPTCPInfo{
+		Subflows:           uint(smi.Subflows),
+		SubflowsMax:        uint(smi.Subflows_max),
+		AddAddrSignal:      uint(smi.Add_addr_signal),
+		AddAddrSignalMax:   uint(smi.Add_addr_signal_max),
+		AddAddrAccepted:    uint(smi.Add_addr_accepted),
+		AddAddrAcceptedMax: uint(smi.Add_addr_accepted_max),
+		LocalAddrUsed:      uint(smi.Local_addr_used),
+		LocalAddrMax:       uint(smi.Local_addr_max),
+		Flags:              uint(smi.Flags),
+		Token:              smi.Token,
+		WriteSeq:           smi.Write_seq,
+		SenderUnacked:      smi.Snd_una,
+		ReceiverNext:       smi.Rcv_nxt,
+		ChecksumEnabled:    smi.Csum_enabled != 0,
+		Retransmits:        uint(smi.Retransmits),
+		BytesRetrans:       smi.Bytes_retrans,
+		BytesSent:          smi.Bytes_sent,
+		BytesReceived:      smi.Bytes_received,
+		BytesAcked:         smi.Bytes_acked,
+	}
+	mi.SubflowInfo, err = getMPTCPSubflows(s, int(smi.Subflows)+1)
+	if err != nil {
+		return nil, err
+	}
+	return mi, nil
+}
+
+func getMPTCPSubflows(s uintptr, max int) ([]*Info, error) {
+	for {
+		b := make([]byte, sizeofMPTCPSubflowData+max*sizeofSubflowInfo)
+		sd := (*mptcpSubflowData)(unsafe.Pointer(&b[0]))
+		sd.Size_subflow_data = sizeofMPTCPSubflowData
+		sd.Size_user = sizeofSubflowInfo
+		if _, err := getsockopt(s, sysSOL_MPTCP, sysMPTCP_TCPINFO, b); err != nil {
+			return nil, err
+		}
+		if int(sd.Num_subflows) > max {
+			max = int(sd.Num_subflows)
+			continue
+		}
+		l := int(sd.Size_kernel)
+		if l > sizeofSubflowInfo {
+			l = sizeofSubflowInfo
+		}
+		var is []*Info
+		for j := 0; j < int(sd.Num_subflows); j++ {
+			off := int(sd.Size_subflow_data) + j*sizeofSubflowInfo
+			o, err := parseInfo(b[off : off+l])
+			if err != nil {
+				return nil, err
+			}
+			is = append(is, o.(*Info))
+		}
+		return is, nil
+	}
+}
+
+// getAuthOptions returns the TCP authentication option in use.
+// The TCP MD5 signature option is not reported on the socket, see
+// the sockdiag package instead.
+func getAuthOptions(s uintptr) []Option {
+	var b [sizeofTCPAOInfoOpt]byte
+	if _, err := getsockoptRaw(s, ianaProtocolTCP, sysTCP_AO_INFO, b[:]); err != nil {
+		return nil
+	}
+	flags := *(*uint32)(unsafe.Pointer(&b[0]))
+	ao := Authentication{SendKeyID: -1, RecvKeyID: -1, Required: flags&0x4 != 0}
+	if flags&0x1 != 0 {
+		ao.SendKeyID = int(b[6])
+	}
+	if flags&0x2 != 0 {
+		ao.RecvKeyID = int(b[7])
+	}
+	return []Option{ao}
+}
+
+// getSysOptions fills in the platform-specific information held in
+// socket options.
+func getSysOptions(s uintptr, si *SysInfo) {
+	if v, err := getsockoptInt(s, sysSOL_SOCKET, sysSO_ZEROCOPY); err == nil {
+		si.ZeroCopy = v != 0
+	}
+	if v, err := getsockoptInt(s, sysSOL_SOCKET, sysSO_TIMESTAMPING); err == nil {
+		si.Timestamping = uint(v)
+	}
+}
+
+// appendSocketOptions appends the socket options affecting latency
+// and the detection of dead peers to opts.
+func appendSocketOptions(opts []Option, s uintptr) []Option {
+	if v, err := getsockoptInt(s, ianaProtocolTCP, sysTCP_NODELAY); err == nil {
+		opts = append(opts, NoDelay(v != 0))
+	}
+	if v, err := getsockoptInt(s, ianaProtocolTCP, sysTCP_QUICKACK); err == nil {
+		opts = append(opts, QuickAck(v != 0))
+	}
+	var b [16]byte
+	if n, err := getsockoptRaw(s, ianaProtocolTCP, sysTCP_CONGESTION, b[:]); err == nil {
+		if j := bytes.IndexByte(b[:n], 0); j >= 0 {
+			n = j
+		}
+		opts = append(opts, sockOpts.ccAlgorithm(b[:n]))
+	}
+	if v, err := getsockoptInt(s, ianaProtocolTCP, sysTCP_USER_TIMEOUT); err == nil {
+		opts = append(opts, sockOpts.userTimeout(time.Duration(v)*time.Millisecond))
+	}
+	if v, err := getsockoptInt(s, sysSOL_SOCKET, sysSO_KEEPALIVE); err == nil && v != 0 {
+		opts = append(opts, sockOpts.keepAlive(getKeepAlive(s, sysTCP_KEEPIDLE, sysTCP_KEEPINTVL, sysTCP_KEEPCNT)))
+	}
+	return opts
+}
+
+var nativeEndian binary.ByteOrder
+
+func init() {
+	i := uint32(1)
+	if *(*byte)(unsafe.Pointer(&i)) == 1 {
+		nativeEndian = binary.LittleEndian
+	} else {
+		nativeEndian = binary.BigEndian
+	}
+}
+
+func sysKernelRelease() string {
+	var u syscall.Utsname
+	if err := syscall.Uname(&u); err != nil {
+		return ""
+	}
+	b := make([]byte, 0, len(u.Release))
+	for _, c := range u.Release {
+		if c == 0 {
+			break
+		}
+		b = append(b, byte(c))
 	}
-	return vi, nil
+	return string(b)
 }
@@ -6,26 +6,82 @@
 
 package tcpinfo
 
-import (
-	"errors"
+import "net"
 
-	"github.com/mikioh/tcpopt"
-)
+const sizeofInfoBuf = 0
 
 var options [soMax]option
 
 // Marshal implements the Marshal method of tcpopt.Option interface.
 func (i *Info) Marshal() ([]byte, error) {
-	return nil, errors.New("operation not supported")
+	return nil, errNotSupported("marshal", "tcp_info")
 }
 
 // A SysInfo represents platform-specific information.
 type SysInfo struct{}
 
-func parseInfo(b []byte) (tcpopt.Option, error) {
-	return nil, errors.New("operation not supported")
+func (si *SysInfo) derive(ds *DerivedStats) {}
+
+func notSentBytes(i *Info) (uint, bool) { return 0, false }
+
+func reordering(i *Info) (uint, bool) { return 0, false }
+
+func tailLossRisk(i *Info) bool { return false }
+
+func pathMTU(i *Info) (uint, bool) { return 0, false }
+
+func zeroWindow(i *Info) *ZeroWindow { return nil }
+
+func parseInfoInto(b []byte, i *Info, m FieldMask, mode ParseMode) error {
+	return errNotSupported("parse", "tcp_info")
 }
 
+func sysInfoLen(name string) int { return 0 }
+
+func sysUnreported(name string) bool { return false }
+
+func sysSockOpt(name string) bool { return false }
+
+var derivedSources map[string]string
+
 func parseCCAlgorithmInfo(name string, b []byte) (CCAlgorithmInfo, error) {
-	return nil, errors.New("operation not supported")
+	return nil, errNotSupported("parse", "cc_algorithm_info")
+}
+
+func parseCCAlgorithmInfoInto(b []byte, ccai CCAlgorithmInfo) error {
+	return errNotSupported("parse", "cc_algorithm_info")
 }
+
+func getQueue(s uintptr, q *Queue) error {
+	return errNotSupported("get", "queue")
+}
+
+func getMPTCP(s uintptr) (*MPTCPInfo, error) {
+	return nil, errNotSupported("get", "mptcp_info")
+}
+
+func getOffload(s uintptr, name string, index int, i *Info) (*Offload, error) {
+	return nil, errNotSupported("get", "offload")
+}
+
+func getRoute(src, dst net.IP) (*Route, error) {
+	return nil, errNotSupported("get", "route")
+}
+
+func getListener(s uintptr, laddr *net.TCPAddr) (*ListenerInfo, error) {
+	return nil, errNotSupported("get", "listener_info")
+}
+
+func getHostStats() (*HostStats, error) {
+	return nil, errNotSupported("get", "host_stats")
+}
+
+func sysRetries() int { return 0 }
+
+func getSysOptions(s uintptr, si *SysInfo) {}
+
+func getAuthOptions(s uintptr) []Option { return nil }
+
+func appendSocketOptions(opts []Option, s uintptr) []Option { return opts }
+
+func sysKernelRelease() string { return "" }
@@ -0,0 +1,41 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcpinfo
+
+import "fmt"
+
+// TailLossRisk reports whether the connection shows signs of
+// incipient tail loss: data is outstanding, no acknowledgment has
+// arrived for more than k times the round-trip time, and no selective
+// acknowledgment or loss recovery is in progress, leaving the
+// retransmission timer as the only way to recover.
+// A non-positive k means 2, the time after which Linux sends a tail
+// loss probe.
+//
+// Only supported on Linux.
+func (i *Info) TailLossRisk(k float64) bool {
+	if k <= 0 {
+		k = 2
+	}
+	if i.RTT <= 0 || i.absent("last_ack_rcvd") || float64(i.LastAckReceived) <= k*float64(i.RTT) {
+		return false
+	}
+	return tailLossRisk(i)
+}
+
+// TailLossAhead returns a rule breached when the connection shows
+// signs of incipient tail loss as reported by Info.TailLossRisk with
+// k, such that monitors warn of the stall before the retransmission
+// timer expires.
+// A non-positive k means 2.
+func TailLossAhead(k float64) *Rule {
+	if k <= 0 {
+		k = 2
+	}
+	return &Rule{
+		Name: fmt.Sprintf("no ack for > %v rtt with data outstanding", k),
+		Cond: func(_, cur *Sample) bool { return cur.Info != nil && cur.Info.TailLossRisk(k) },
+	}
+}
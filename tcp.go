@@ -4,6 +4,13 @@
 
 package tcpinfo
 
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
 // A State represents a state of connection.
 type State int
 
@@ -40,36 +47,163 @@ var states = map[State]string{
 func (st State) String() string {
 	s, ok := states[st]
 	if !ok {
-		return "<nil>"
+		return "invalid(" + strconv.Itoa(int(st)) + ")"
 	}
 	return s
 }
 
+// ParseState parses s as the name of a connection state, as returned
+// by String.
+func ParseState(s string) (State, error) {
+	for st, name := range states {
+		if name == s {
+			return st, nil
+		}
+	}
+	if strings.HasPrefix(s, "invalid(") && strings.HasSuffix(s, ")") {
+		if n, err := strconv.Atoi(s[len("invalid(") : len(s)-1]); err == nil {
+			return State(n), nil
+		}
+	}
+	return 0, errors.New("unknown state: " + s)
+}
+
+// MarshalText implements the MarshalText method of
+// encoding.TextMarshaler interface.
+func (st State) MarshalText() ([]byte, error) { return []byte(st.String()), nil }
+
+// UnmarshalText implements the UnmarshalText method of
+// encoding.TextUnmarshaler interface.
+func (st *State) UnmarshalText(b []byte) error {
+	s, err := ParseState(string(b))
+	if err != nil {
+		return err
+	}
+	*st = s
+	return nil
+}
+
+// UnmarshalJSON implements the UnmarshalJSON method of
+// json.Unmarshaler interface.
+// It accepts a number as well as a string for compatibility.
+func (st *State) UnmarshalJSON(b []byte) error {
+	if string(b) == "null" {
+		return nil
+	}
+	if len(b) > 0 && b[0] != '"' {
+		n, err := strconv.Atoi(string(b))
+		if err != nil {
+			return errors.New("invalid state: " + string(b))
+		}
+		*st = State(n)
+		return nil
+	}
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return st.UnmarshalText([]byte(s))
+}
+
 // An OptionKind represents an option kind.
 type OptionKind int
 
 const (
-	KindMaxSegSize    OptionKind = 2
-	KindWindowScale   OptionKind = 3
-	KindSACKPermitted OptionKind = 4
-	KindTimestamps    OptionKind = 8
+	KindMaxSegSize     OptionKind = 2
+	KindWindowScale    OptionKind = 3
+	KindSACKPermitted  OptionKind = 4
+	KindTimestamps     OptionKind = 8
+	KindMD5Signature   OptionKind = 19
+	KindAuthentication OptionKind = 29
+	KindFastOpen       OptionKind = 34
+)
+
+// Kinds of socket options and negotiated capabilities reported along
+// with TCP options.
+// They are not carried in segments as options and lie outside the
+// range of TCP option kinds.
+const (
+	KindNoDelay OptionKind = 0x100 + iota
+	KindQuickAck
+	KindUserTimeout
+	KindKeepAlive
+	KindECN
+	KindCCAlgorithm
 )
 
 var optionKinds = map[OptionKind]string{
-	KindMaxSegSize:    "mss",
-	KindWindowScale:   "wscale",
-	KindSACKPermitted: "sack",
-	KindTimestamps:    "tmstamps",
+	KindMaxSegSize:     "mss",
+	KindWindowScale:    "wscale",
+	KindSACKPermitted:  "sack",
+	KindTimestamps:     "tmstamps",
+	KindMD5Signature:   "md5sig",
+	KindAuthentication: "ao",
+	KindFastOpen:       "tfo",
+	KindNoDelay:        "nodelay",
+	KindQuickAck:       "quickack",
+	KindUserTimeout:    "user_timeout",
+	KindKeepAlive:      "keepalive",
+	KindECN:            "ecn",
+	KindCCAlgorithm:    "cc",
 }
 
 func (k OptionKind) String() string {
 	s, ok := optionKinds[k]
 	if !ok {
-		return "<nil>"
+		if s, ok = privateKindName(k); !ok {
+			return "<nil>"
+		}
 	}
 	return s
 }
 
+// optionKindAliases holds the alternative names of option kinds
+// accepted by ParseOptionKind.
+var optionKindAliases = map[string]OptionKind{
+	"timestamps": KindTimestamps,
+}
+
+// ParseOptionKind parses s as the name of an option kind, as returned
+// by String, or as a decimal number.
+func ParseOptionKind(s string) (OptionKind, error) {
+	for k, name := range optionKinds {
+		if name == s {
+			return k, nil
+		}
+	}
+	if k, ok := optionKindAliases[s]; ok {
+		return k, nil
+	}
+	if k, ok := privateKind(s); ok {
+		return k, nil
+	}
+	if n, err := strconv.Atoi(s); err == nil && n >= 0 {
+		return OptionKind(n), nil
+	}
+	return 0, errors.New("unknown option kind: " + s)
+}
+
+// MarshalText implements the MarshalText method of
+// encoding.TextMarshaler interface.
+// A kind without name is marshaled as a decimal number.
+func (k OptionKind) MarshalText() ([]byte, error) {
+	if s := k.String(); s != "<nil>" {
+		return []byte(s), nil
+	}
+	return strconv.AppendInt(nil, int64(k), 10), nil
+}
+
+// UnmarshalText implements the UnmarshalText method of
+// encoding.TextUnmarshaler interface.
+func (k *OptionKind) UnmarshalText(b []byte) error {
+	kind, err := ParseOptionKind(string(b))
+	if err != nil {
+		return err
+	}
+	*k = kind
+	return nil
+}
+
 // An Option represents an option.
 type Option interface {
 	Kind() OptionKind
@@ -99,3 +233,72 @@ type Timestamps bool
 
 // Kind returns an option kind field.
 func (ts Timestamps) Kind() OptionKind { return KindTimestamps }
+
+// An MD5Signature reports whether a TCP MD5 signature option is
+// enabled.
+type MD5Signature bool
+
+// Kind returns an option kind field.
+func (ms MD5Signature) Kind() OptionKind { return KindMD5Signature }
+
+// An Authentication represents a TCP authentication option.
+type Authentication struct {
+	SendKeyID int  `json:"snd_keyid"` // key ID of current key used for sending; -1 when not set
+	RecvKeyID int  `json:"rcv_keyid"` // key ID of key requested to be used by peer; -1 when not set
+	Required  bool `json:"required"`  // whether segments without the option are rejected
+}
+
+// Kind returns an option kind field.
+func (ao Authentication) Kind() OptionKind { return KindAuthentication }
+
+// A FastOpen reports whether data was carried in the SYN segment with
+// a TCP Fast Open option and acknowledged.
+// Only Linux reports the option.
+type FastOpen bool
+
+// Kind returns an option kind field.
+func (fo FastOpen) Kind() OptionKind { return KindFastOpen }
+
+// A NoDelay reports whether the Nagle algorithm is disabled on the
+// socket with TCP_NODELAY.
+type NoDelay bool
+
+// Kind returns an option kind.
+func (nd NoDelay) Kind() OptionKind { return KindNoDelay }
+
+// A QuickAck reports whether the socket is in quick acknowledgment
+// mode, in which acknowledgments are sent immediately instead of
+// being delayed.
+// Only Linux reports the mode, which may be set with TCP_QUICKACK.
+type QuickAck bool
+
+// Kind returns an option kind.
+func (qa QuickAck) Kind() OptionKind { return KindQuickAck }
+
+// A UserTimeout represents the maximum time transmitted data may
+// remain unacknowledged before the connection is aborted, set with
+// TCP_USER_TIMEOUT.
+// Zero means the kernel default applies.
+// Only Linux reports the timeout.
+type UserTimeout time.Duration
+
+// Kind returns an option kind.
+func (ut UserTimeout) Kind() OptionKind { return KindUserTimeout }
+
+// A KeepAlive represents the keepalive probing enabled on the socket
+// with SO_KEEPALIVE.
+type KeepAlive struct {
+	Idle     time.Duration `json:"idle"`  // idle time before the first probe
+	Interval time.Duration `json:"intvl"` // interval between probes
+	Count    int           `json:"cnt"`   // # of unanswered probes before the connection is aborted
+}
+
+// Kind returns an option kind.
+func (ka KeepAlive) Kind() OptionKind { return KindKeepAlive }
+
+// An ECN reports whether explicit congestion notification is
+// negotiated on the connection.
+type ECN bool
+
+// Kind returns an option kind.
+func (e ECN) Kind() OptionKind { return KindECN }
@@ -0,0 +1,309 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package tcpinfocbor implements a compact CBOR encoding of TCP
+// connection information samples.
+//
+// Concise Binary Object Representation (CBOR) is defined in RFC 8949.
+//
+// A sample is encoded as a map with small unsigned integer keys.
+// Zero values are omitted, durations are in microseconds and the
+// sample time is in nanoseconds since the Unix epoch:
+//
+//	sample = {0: time, 1: info, 2: final, 3: stats, 4: err}
+//	info   = {0: state, 1: opts, 2: peer_opts, 3: snd_mss, 4: rcv_mss,
+//	          5: rtt, 6: rttvar, 7: rto, 8: ato, 9: last_data_sent,
+//	          10: last_data_rcvd, 11: last_ack_rcvd, 12: rcv_wnd,
+//	          13: snd_ssthresh, 14: rcv_ssthresh, 15: snd_cwnd_bytes,
+//	          16: snd_cwnd_segs, 17: queue}
+//	opts   = {option kind: value}
+//	ao     = {0: snd_keyid, 1: rcv_keyid, 2: required}
+//	keepalive = {0: idle, 1: intvl, 2: cnt}
+//	queue  = {0: rcv_queue, 1: snd_queue}
+//	stats  = {0: min_rtt, 1: retrans_segs, 2: retrans_bytes,
+//	          3: segs_sent, 4: segs_rcvd, 5: bytes_sent, 6: bytes_rcvd,
+//	          7: delivery_rate}
+package tcpinfocbor
+
+import (
+	"errors"
+	"time"
+
+	"github.com/mikioh/tcpinfo"
+)
+
+// A Sample represents a decoded sample.
+//
+// The platform-specific information Info.Sys is not encoded; the
+// statistics derived from it on the originating platform are carried
+// in Stats instead.
+type Sample struct {
+	tcpinfo.Sample
+	Stats *tcpinfo.DerivedStats
+}
+
+// Marshal returns the CBOR encoding of the sample s.
+func Marshal(s *tcpinfo.Sample) ([]byte, error) {
+	var m encMap
+	if !s.Time.IsZero() {
+		m.uint(0, uint64(s.Time.UnixNano()))
+	}
+	if s.Info != nil {
+		m.raw(1, marshalInfo(s.Info))
+	}
+	if s.Final {
+		m.bool(2, true)
+	}
+	if s.Info != nil {
+		m.raw(3, marshalStats(s.Info.Stats()))
+	}
+	if s.Err != nil {
+		m.text(4, s.Err.Error())
+	}
+	return m.bytes(), nil
+}
+
+// Unmarshal parses the CBOR encoding of a sample.
+func Unmarshal(b []byte) (*Sample, error) {
+	d := decoder{b: b}
+	v, err := d.value(0)
+	if err != nil {
+		return nil, err
+	}
+	if len(d.b) > 0 {
+		return nil, errors.New("trailing data")
+	}
+	m, ok := v.(map[uint64]interface{})
+	if !ok {
+		return nil, errors.New("not a map")
+	}
+	s := &Sample{}
+	if v, ok := m[0].(uint64); ok {
+		s.Time = time.Unix(0, int64(v))
+	}
+	if v, ok := m[1].(map[uint64]interface{}); ok {
+		s.Info = unmarshalInfo(v)
+	}
+	if v, ok := m[2].(bool); ok {
+		s.Final = v
+	}
+	if v, ok := m[3].(map[uint64]interface{}); ok {
+		s.Stats = unmarshalStats(v)
+	}
+	if v, ok := m[4].(string); ok {
+		s.Err = errors.New(v)
+	}
+	return s, nil
+}
+
+func marshalInfo(i *tcpinfo.Info) []byte {
+	var m encMap
+	m.uint(0, uint64(i.State))
+	if len(i.Options) > 0 {
+		m.raw(1, marshalOptions(i.Options))
+	}
+	if len(i.PeerOptions) > 0 {
+		m.raw(2, marshalOptions(i.PeerOptions))
+	}
+	m.uint(3, uint64(i.SenderMSS))
+	m.uint(4, uint64(i.ReceiverMSS))
+	m.duration(5, i.RTT)
+	m.duration(6, i.RTTVar)
+	m.duration(7, i.RTO)
+	m.duration(8, i.ATO)
+	m.duration(9, i.LastDataSent)
+	m.duration(10, i.LastDataReceived)
+	m.duration(11, i.LastAckReceived)
+	if fc := i.FlowControl; fc != nil {
+		m.uint(12, uint64(fc.ReceiverWindow))
+	}
+	if cc := i.CongestionControl; cc != nil {
+		m.uint(13, uint64(cc.SenderSSThreshold))
+		m.uint(14, uint64(cc.ReceiverSSThreshold))
+		m.uint(15, uint64(cc.SenderWindowBytes))
+		m.uint(16, uint64(cc.SenderWindowSegs))
+	}
+	if q := i.Queue; q != nil {
+		var qm encMap
+		qm.uint(0, uint64(q.Receive))
+		qm.uint(1, uint64(q.Send))
+		m.raw(17, qm.bytes())
+	}
+	return m.bytes()
+}
+
+func unmarshalInfo(m map[uint64]interface{}) *tcpinfo.Info {
+	u := func(k uint64) uint64 { v, _ := m[k].(uint64); return v }
+	d := func(k uint64) time.Duration { return time.Duration(u(k)) * time.Microsecond }
+	i := &tcpinfo.Info{
+		State:            tcpinfo.State(u(0)),
+		SenderMSS:        tcpinfo.MaxSegSize(u(3)),
+		ReceiverMSS:      tcpinfo.MaxSegSize(u(4)),
+		RTT:              d(5),
+		RTTVar:           d(6),
+		RTO:              d(7),
+		ATO:              d(8),
+		LastDataSent:     d(9),
+		LastDataReceived: d(10),
+		LastAckReceived:  d(11),
+	}
+	if v, ok := m[1].(map[uint64]interface{}); ok {
+		i.Options = unmarshalOptions(v)
+	}
+	if v, ok := m[2].(map[uint64]interface{}); ok {
+		i.PeerOptions = unmarshalOptions(v)
+	}
+	if _, ok := m[12]; ok {
+		i.FlowControl = &tcpinfo.FlowControl{ReceiverWindow: uint(u(12))}
+	}
+	for k := uint64(13); k <= 16; k++ {
+		if _, ok := m[k]; ok {
+			i.CongestionControl = &tcpinfo.CongestionControl{
+				SenderSSThreshold:   uint(u(13)),
+				ReceiverSSThreshold: uint(u(14)),
+				SenderWindowBytes:   uint(u(15)),
+				SenderWindowSegs:    uint(u(16)),
+			}
+			break
+		}
+	}
+	if v, ok := m[17].(map[uint64]interface{}); ok {
+		rcv, _ := v[0].(uint64)
+		snd, _ := v[1].(uint64)
+		i.Queue = &tcpinfo.Queue{Receive: uint(rcv), Send: uint(snd)}
+	}
+	return i
+}
+
+func marshalOptions(opts []tcpinfo.Option) []byte {
+	var m encMap
+	for _, opt := range opts {
+		switch opt := opt.(type) {
+		case tcpinfo.MaxSegSize:
+			m.forceUint(uint64(opt.Kind()), uint64(opt))
+		case tcpinfo.WindowScale:
+			m.forceUint(uint64(opt.Kind()), uint64(opt))
+		case tcpinfo.SACKPermitted:
+			m.bool(uint64(opt.Kind()), bool(opt))
+		case tcpinfo.Timestamps:
+			m.bool(uint64(opt.Kind()), bool(opt))
+		case tcpinfo.NoDelay:
+			m.bool(uint64(opt.Kind()), bool(opt))
+		case tcpinfo.QuickAck:
+			m.bool(uint64(opt.Kind()), bool(opt))
+		case tcpinfo.FastOpen:
+			m.bool(uint64(opt.Kind()), bool(opt))
+		case tcpinfo.ECN:
+			m.bool(uint64(opt.Kind()), bool(opt))
+		case tcpinfo.MD5Signature:
+			m.bool(uint64(opt.Kind()), bool(opt))
+		case tcpinfo.UserTimeout:
+			m.forceUint(uint64(opt.Kind()), uint64(time.Duration(opt)/time.Microsecond))
+		case tcpinfo.CCAlgorithm:
+			m.text(uint64(opt.Kind()), string(opt))
+		case tcpinfo.KeepAlive:
+			var ka encMap
+			ka.duration(0, opt.Idle)
+			ka.duration(1, opt.Interval)
+			ka.uint(2, uint64(opt.Count))
+			m.raw(uint64(opt.Kind()), ka.bytes())
+		case tcpinfo.Authentication:
+			// The key IDs not set are omitted.
+			var ao encMap
+			if opt.SendKeyID >= 0 {
+				ao.forceUint(0, uint64(opt.SendKeyID))
+			}
+			if opt.RecvKeyID >= 0 {
+				ao.forceUint(1, uint64(opt.RecvKeyID))
+			}
+			if opt.Required {
+				ao.bool(2, true)
+			}
+			m.raw(uint64(opt.Kind()), ao.bytes())
+		}
+	}
+	return m.bytes()
+}
+
+func unmarshalOptions(m map[uint64]interface{}) []tcpinfo.Option {
+	var opts []tcpinfo.Option
+	for _, kind := range []tcpinfo.OptionKind{tcpinfo.KindMaxSegSize, tcpinfo.KindWindowScale, tcpinfo.KindSACKPermitted, tcpinfo.KindTimestamps, tcpinfo.KindMD5Signature, tcpinfo.KindAuthentication, tcpinfo.KindFastOpen, tcpinfo.KindNoDelay, tcpinfo.KindQuickAck, tcpinfo.KindUserTimeout, tcpinfo.KindKeepAlive, tcpinfo.KindECN, tcpinfo.KindCCAlgorithm} {
+		v, ok := m[uint64(kind)]
+		if !ok {
+			continue
+		}
+		n, _ := v.(uint64)
+		b, _ := v.(bool)
+		s, _ := v.(string)
+		sub, _ := v.(map[uint64]interface{})
+		u := func(k uint64) (uint64, bool) { v, ok := sub[k].(uint64); return v, ok }
+		switch kind {
+		case tcpinfo.KindMaxSegSize:
+			opts = append(opts, tcpinfo.MaxSegSize(n))
+		case tcpinfo.KindWindowScale:
+			opts = append(opts, tcpinfo.WindowScale(n))
+		case tcpinfo.KindSACKPermitted:
+			opts = append(opts, tcpinfo.SACKPermitted(b))
+		case tcpinfo.KindTimestamps:
+			opts = append(opts, tcpinfo.Timestamps(b))
+		case tcpinfo.KindNoDelay:
+			opts = append(opts, tcpinfo.NoDelay(b))
+		case tcpinfo.KindQuickAck:
+			opts = append(opts, tcpinfo.QuickAck(b))
+		case tcpinfo.KindFastOpen:
+			opts = append(opts, tcpinfo.FastOpen(b))
+		case tcpinfo.KindECN:
+			opts = append(opts, tcpinfo.ECN(b))
+		case tcpinfo.KindMD5Signature:
+			opts = append(opts, tcpinfo.MD5Signature(b))
+		case tcpinfo.KindUserTimeout:
+			opts = append(opts, tcpinfo.UserTimeout(time.Duration(n)*time.Microsecond))
+		case tcpinfo.KindCCAlgorithm:
+			opts = append(opts, tcpinfo.CCAlgorithm(s))
+		case tcpinfo.KindKeepAlive:
+			idle, _ := u(0)
+			intvl, _ := u(1)
+			cnt, _ := u(2)
+			opts = append(opts, tcpinfo.KeepAlive{Idle: time.Duration(idle) * time.Microsecond, Interval: time.Duration(intvl) * time.Microsecond, Count: int(cnt)})
+		case tcpinfo.KindAuthentication:
+			ao := tcpinfo.Authentication{SendKeyID: -1, RecvKeyID: -1}
+			if id, ok := u(0); ok {
+				ao.SendKeyID = int(id)
+			}
+			if id, ok := u(1); ok {
+				ao.RecvKeyID = int(id)
+			}
+			ao.Required, _ = sub[2].(bool)
+			opts = append(opts, ao)
+		}
+	}
+	return opts
+}
+
+func marshalStats(ds *tcpinfo.DerivedStats) []byte {
+	var m encMap
+	m.duration(0, ds.MinRTT)
+	m.uint(1, ds.RetransSegs)
+	m.uint(2, ds.RetransBytes)
+	m.uint(3, ds.SegsSent)
+	m.uint(4, ds.SegsReceived)
+	m.uint(5, ds.BytesSent)
+	m.uint(6, ds.BytesReceived)
+	m.uint(7, ds.DeliveryRate)
+	return m.bytes()
+}
+
+func unmarshalStats(m map[uint64]interface{}) *tcpinfo.DerivedStats {
+	u := func(k uint64) uint64 { v, _ := m[k].(uint64); return v }
+	return &tcpinfo.DerivedStats{
+		MinRTT:        time.Duration(u(0)) * time.Microsecond,
+		RetransSegs:   u(1),
+		RetransBytes:  u(2),
+		SegsSent:      u(3),
+		SegsReceived:  u(4),
+		BytesSent:     u(5),
+		BytesReceived: u(6),
+		DeliveryRate:  u(7),
+	}
+}
@@ -0,0 +1,70 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcpinfocbor_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/mikioh/tcpinfo"
+	"github.com/mikioh/tcpinfo/tcpinfocbor"
+)
+
+func TestMarshalAndUnmarshal(t *testing.T) {
+	s := &tcpinfo.Sample{
+		Time: time.Unix(1, 5),
+		Info: &tcpinfo.Info{
+			State:             tcpinfo.Established,
+			Options:           []tcpinfo.Option{tcpinfo.WindowScale(7), tcpinfo.SACKPermitted(true)},
+			PeerOptions:       []tcpinfo.Option{tcpinfo.WindowScale(9), tcpinfo.SACKPermitted(true), tcpinfo.MD5Signature(true), tcpinfo.Authentication{SendKeyID: 0, RecvKeyID: -1, Required: true}},
+			SenderMSS:         1448,
+			ReceiverMSS:       536,
+			RTT:               1500 * time.Microsecond,
+			RTTVar:            750 * time.Microsecond,
+			RTO:               204 * time.Millisecond,
+			FlowControl:       &tcpinfo.FlowControl{ReceiverWindow: 65535},
+			CongestionControl: &tcpinfo.CongestionControl{SenderSSThreshold: 2147483647, SenderWindowSegs: 10},
+			Queue:             &tcpinfo.Queue{Receive: 100},
+		},
+	}
+	b, err := tcpinfocbor.Marshal(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	js, err := json.Marshal(s.Info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(b)*5 > len(js) {
+		t.Fatalf("got %d bytes; want less than a fifth of %d", len(b), len(js))
+	}
+	ss, err := tcpinfocbor.Unmarshal(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ss.Time.Equal(s.Time) || !reflect.DeepEqual(ss.Info, s.Info) {
+		t.Fatalf("got %+v; want %+v", ss.Info, s.Info)
+	}
+
+	s.Info.Options = append(s.Info.Options, tcpinfo.KeepAlive{Idle: time.Minute, Interval: 10 * time.Second, Count: 9}, tcpinfo.CCAlgorithm("bbr"))
+	b, err = tcpinfocbor.Marshal(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ss, err = tcpinfocbor.Unmarshal(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(ss.Info.Options, s.Info.Options) {
+		t.Fatalf("got %+v; want %+v", ss.Info.Options, s.Info.Options)
+	}
+	for i := range b {
+		if _, err := tcpinfocbor.Unmarshal(b[:i]); err == nil {
+			t.Fatalf("got nil for %d bytes; want an error", i)
+		}
+	}
+}
@@ -0,0 +1,203 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcpinfocbor
+
+import (
+	"errors"
+	"time"
+)
+
+const (
+	majorUint   = 0
+	majorNegint = 1
+	majorBytes  = 2
+	majorText   = 3
+	majorArray  = 4
+	majorMap    = 5
+	majorSimple = 7
+
+	simpleFalse = 20
+	simpleTrue  = 21
+	simpleNull  = 22
+
+	maxDepth = 8
+)
+
+func appendHead(b []byte, major byte, v uint64) []byte {
+	switch {
+	case v < 24:
+		return append(b, major<<5|byte(v))
+	case v <= 0xff:
+		return append(b, major<<5|24, byte(v))
+	case v <= 0xffff:
+		return append(b, major<<5|25, byte(v>>8), byte(v))
+	case v <= 0xffffffff:
+		return append(b, major<<5|26, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+	default:
+		return append(b, major<<5|27, byte(v>>56), byte(v>>48), byte(v>>40), byte(v>>32), byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+	}
+}
+
+// An encMap builds a definite-length map with unsigned integer keys.
+type encMap struct {
+	n int
+	b []byte
+}
+
+func (m *encMap) key(k uint64) {
+	m.n++
+	m.b = appendHead(m.b, majorUint, k)
+}
+
+func (m *encMap) uint(k, v uint64) {
+	if v != 0 {
+		m.forceUint(k, v)
+	}
+}
+
+func (m *encMap) forceUint(k, v uint64) {
+	m.key(k)
+	m.b = appendHead(m.b, majorUint, v)
+}
+
+func (m *encMap) duration(k uint64, d time.Duration) {
+	if d > 0 {
+		m.uint(k, uint64(d/time.Microsecond))
+	}
+}
+
+func (m *encMap) bool(k uint64, v bool) {
+	m.key(k)
+	if v {
+		m.b = append(m.b, majorSimple<<5|simpleTrue)
+	} else {
+		m.b = append(m.b, majorSimple<<5|simpleFalse)
+	}
+}
+
+func (m *encMap) text(k uint64, s string) {
+	m.key(k)
+	m.b = appendHead(m.b, majorText, uint64(len(s)))
+	m.b = append(m.b, s...)
+}
+
+func (m *encMap) raw(k uint64, v []byte) {
+	m.key(k)
+	m.b = append(m.b, v...)
+}
+
+func (m *encMap) bytes() []byte {
+	b := appendHead(make([]byte, 0, 9+len(m.b)), majorMap, uint64(m.n))
+	return append(b, m.b...)
+}
+
+var errShortBuffer = errors.New("short buffer")
+
+// A decoder decodes the subset of CBOR used by this package.
+// Maps with unsigned integer keys are decoded as
+// map[uint64]interface{}; other keys are skipped.
+type decoder struct {
+	b []byte
+}
+
+func (d *decoder) head() (byte, byte, uint64, error) {
+	if len(d.b) < 1 {
+		return 0, 0, 0, errShortBuffer
+	}
+	major, info := d.b[0]>>5, d.b[0]&0x1f
+	d.b = d.b[1:]
+	var n int
+	switch {
+	case info < 24:
+		return major, info, uint64(info), nil
+	case info == 24:
+		n = 1
+	case info == 25:
+		n = 2
+	case info == 26:
+		n = 4
+	case info == 27:
+		n = 8
+	default:
+		return 0, 0, 0, errors.New("unsupported additional information")
+	}
+	if len(d.b) < n {
+		return 0, 0, 0, errShortBuffer
+	}
+	var v uint64
+	for _, c := range d.b[:n] {
+		v = v<<8 | uint64(c)
+	}
+	d.b = d.b[n:]
+	return major, info, v, nil
+}
+
+func (d *decoder) value(depth int) (interface{}, error) {
+	if depth > maxDepth {
+		return nil, errors.New("nesting too deep")
+	}
+	major, info, v, err := d.head()
+	if err != nil {
+		return nil, err
+	}
+	switch major {
+	case majorUint:
+		return v, nil
+	case majorNegint:
+		return -1 - int64(v), nil
+	case majorBytes, majorText:
+		if uint64(len(d.b)) < v {
+			return nil, errShortBuffer
+		}
+		s := d.b[:v]
+		d.b = d.b[v:]
+		if major == majorText {
+			return string(s), nil
+		}
+		return append([]byte(nil), s...), nil
+	case majorArray:
+		if uint64(len(d.b)) < v {
+			return nil, errShortBuffer
+		}
+		a := make([]interface{}, 0, v)
+		for j := uint64(0); j < v; j++ {
+			e, err := d.value(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			a = append(a, e)
+		}
+		return a, nil
+	case majorMap:
+		if uint64(len(d.b)) < 2*v {
+			return nil, errShortBuffer
+		}
+		m := make(map[uint64]interface{}, v)
+		for j := uint64(0); j < v; j++ {
+			k, err := d.value(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			e, err := d.value(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			if k, ok := k.(uint64); ok {
+				m[k] = e
+			}
+		}
+		return m, nil
+	case majorSimple:
+		switch info {
+		case simpleFalse:
+			return false, nil
+		case simpleTrue:
+			return true, nil
+		case simpleNull:
+			return nil, nil
+		}
+	}
+	return nil, errors.New("unsupported data item")
+}
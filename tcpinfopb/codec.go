@@ -0,0 +1,481 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package tcpinfopb implements the protocol buffers encoding of TCP
+// connection information samples.
+//
+// The schema is defined in tcpinfo.proto.
+// The package is kept separate from the tcpinfo package so that only
+// its users depend on the protocol buffers runtime.
+package tcpinfopb
+
+import (
+	"errors"
+	"time"
+
+	"github.com/mikioh/tcpinfo"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// A Sample represents a decoded sample.
+//
+// The platform-specific information Info.Sys is not encoded; the
+// statistics derived from it on the originating platform are carried
+// in Stats instead.
+type Sample struct {
+	tcpinfo.Sample
+	Stats   *tcpinfo.DerivedStats
+	Version int // schema version of the encoding, see tcpinfo.SchemaVersion
+}
+
+// Marshal returns the protocol buffers encoding of the sample s in
+// the schema version tcpinfo.SchemaVersion.
+func Marshal(s *tcpinfo.Sample) ([]byte, error) {
+	var b []byte
+	b = appendVarint(b, 6, tcpinfo.SchemaVersion)
+	if !s.Time.IsZero() {
+		b = appendVarint(b, 1, uint64(s.Time.UnixNano()))
+	}
+	if s.Info != nil {
+		b = appendMessage(b, 2, marshalInfo(s.Info))
+		b = appendMessage(b, 4, marshalStats(s.Info.Stats()))
+	}
+	if s.Final {
+		b = appendVarint(b, 3, 1)
+	}
+	if s.Err != nil {
+		b = protowire.AppendTag(b, 5, protowire.BytesType)
+		b = protowire.AppendString(b, s.Err.Error())
+	}
+	return b, nil
+}
+
+// Unmarshal parses the protocol buffers encoding of a sample.
+// A sample without version is of schema version 1.
+func Unmarshal(b []byte) (*Sample, error) {
+	s := &Sample{Version: 1}
+	err := consume(b, func(num protowire.Number, v uint64, m []byte) error {
+		var err error
+		switch num {
+		case 1:
+			s.Time = time.Unix(0, int64(v))
+		case 2:
+			s.Info, err = unmarshalInfo(m)
+		case 3:
+			s.Final = v != 0
+		case 4:
+			s.Stats, err = unmarshalStats(m)
+		case 5:
+			s.Err = errors.New(string(m))
+		case 6:
+			s.Version = int(v)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if s.Version < 1 || s.Version > tcpinfo.SchemaVersion {
+		return nil, tcpinfo.ErrUnknownSchema
+	}
+	return s, nil
+}
+
+// MarshalDelta returns the protocol buffers encoding of the delta d.
+func MarshalDelta(d *tcpinfo.Delta) ([]byte, error) {
+	var b []byte
+	b = appendVarint(b, 1, uint64(d.Duration))
+	b = appendVarint(b, 2, d.RetransSegs)
+	b = appendVarint(b, 3, d.RetransBytes)
+	b = appendVarint(b, 4, d.SegsSent)
+	b = appendVarint(b, 5, d.SegsReceived)
+	b = appendVarint(b, 6, d.BytesSent)
+	b = appendVarint(b, 7, d.BytesReceived)
+	return b, nil
+}
+
+// UnmarshalDelta parses the protocol buffers encoding of a delta.
+func UnmarshalDelta(b []byte) (*tcpinfo.Delta, error) {
+	d := &tcpinfo.Delta{}
+	err := consume(b, func(num protowire.Number, v uint64, _ []byte) error {
+		switch num {
+		case 1:
+			d.Duration = time.Duration(v)
+		case 2:
+			d.RetransSegs = v
+		case 3:
+			d.RetransBytes = v
+		case 4:
+			d.SegsSent = v
+		case 5:
+			d.SegsReceived = v
+		case 6:
+			d.BytesSent = v
+		case 7:
+			d.BytesReceived = v
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+func marshalInfo(i *tcpinfo.Info) []byte {
+	var b []byte
+	b = appendVarint(b, 1, uint64(i.State))
+	for _, opt := range i.Options {
+		b = appendMessage(b, 2, marshalOption(opt))
+	}
+	for _, opt := range i.PeerOptions {
+		b = appendMessage(b, 3, marshalOption(opt))
+	}
+	b = appendVarint(b, 4, uint64(i.SenderMSS))
+	b = appendVarint(b, 5, uint64(i.ReceiverMSS))
+	b = appendVarint(b, 6, uint64(i.RTT))
+	b = appendVarint(b, 7, uint64(i.RTTVar))
+	b = appendVarint(b, 8, uint64(i.RTO))
+	b = appendVarint(b, 9, uint64(i.ATO))
+	b = appendVarint(b, 10, uint64(i.LastDataSent))
+	b = appendVarint(b, 11, uint64(i.LastDataReceived))
+	b = appendVarint(b, 12, uint64(i.LastAckReceived))
+	if fc := i.FlowControl; fc != nil {
+		b = appendMessage(b, 13, appendVarint(nil, 1, uint64(fc.ReceiverWindow)))
+	}
+	if cc := i.CongestionControl; cc != nil {
+		var m []byte
+		m = appendVarint(m, 1, uint64(cc.SenderSSThreshold))
+		m = appendVarint(m, 2, uint64(cc.ReceiverSSThreshold))
+		m = appendVarint(m, 3, uint64(cc.SenderWindowBytes))
+		m = appendVarint(m, 4, uint64(cc.SenderWindowSegs))
+		b = appendMessage(b, 14, m)
+	}
+	if q := i.Queue; q != nil {
+		var m []byte
+		m = appendVarint(m, 1, uint64(q.Receive))
+		m = appendVarint(m, 2, uint64(q.Send))
+		b = appendMessage(b, 15, m)
+	}
+	return b
+}
+
+func unmarshalInfo(b []byte) (*tcpinfo.Info, error) {
+	i := &tcpinfo.Info{}
+	err := consume(b, func(num protowire.Number, v uint64, m []byte) error {
+		switch num {
+		case 1:
+			i.State = tcpinfo.State(v)
+		case 2, 3:
+			opt, err := unmarshalOption(m)
+			if err != nil || opt == nil {
+				return err
+			}
+			if num == 2 {
+				i.Options = append(i.Options, opt)
+			} else {
+				i.PeerOptions = append(i.PeerOptions, opt)
+			}
+		case 4:
+			i.SenderMSS = tcpinfo.MaxSegSize(v)
+		case 5:
+			i.ReceiverMSS = tcpinfo.MaxSegSize(v)
+		case 6:
+			i.RTT = time.Duration(v)
+		case 7:
+			i.RTTVar = time.Duration(v)
+		case 8:
+			i.RTO = time.Duration(v)
+		case 9:
+			i.ATO = time.Duration(v)
+		case 10:
+			i.LastDataSent = time.Duration(v)
+		case 11:
+			i.LastDataReceived = time.Duration(v)
+		case 12:
+			i.LastAckReceived = time.Duration(v)
+		case 13:
+			i.FlowControl = &tcpinfo.FlowControl{}
+			return consume(m, func(num protowire.Number, v uint64, _ []byte) error {
+				if num == 1 {
+					i.FlowControl.ReceiverWindow = uint(v)
+				}
+				return nil
+			})
+		case 14:
+			cc := &tcpinfo.CongestionControl{}
+			i.CongestionControl = cc
+			return consume(m, func(num protowire.Number, v uint64, _ []byte) error {
+				switch num {
+				case 1:
+					cc.SenderSSThreshold = uint(v)
+				case 2:
+					cc.ReceiverSSThreshold = uint(v)
+				case 3:
+					cc.SenderWindowBytes = uint(v)
+				case 4:
+					cc.SenderWindowSegs = uint(v)
+				}
+				return nil
+			})
+		case 15:
+			q := &tcpinfo.Queue{}
+			i.Queue = q
+			return consume(m, func(num protowire.Number, v uint64, _ []byte) error {
+				switch num {
+				case 1:
+					q.Receive = uint(v)
+				case 2:
+					q.Send = uint(v)
+				}
+				return nil
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return i, nil
+}
+
+func marshalOption(opt tcpinfo.Option) []byte {
+	var v uint64
+	var m []byte
+	switch opt := opt.(type) {
+	case tcpinfo.MaxSegSize:
+		v = uint64(opt)
+	case tcpinfo.WindowScale:
+		v = uint64(opt)
+	case tcpinfo.SACKPermitted:
+		if opt {
+			v = 1
+		}
+	case tcpinfo.Timestamps:
+		if opt {
+			v = 1
+		}
+	case tcpinfo.NoDelay:
+		if opt {
+			v = 1
+		}
+	case tcpinfo.QuickAck:
+		if opt {
+			v = 1
+		}
+	case tcpinfo.FastOpen:
+		if opt {
+			v = 1
+		}
+	case tcpinfo.ECN:
+		if opt {
+			v = 1
+		}
+	case tcpinfo.MD5Signature:
+		if opt {
+			v = 1
+		}
+	case tcpinfo.UserTimeout:
+		v = uint64(opt)
+	case tcpinfo.CCAlgorithm:
+		m = protowire.AppendTag(m, 3, protowire.BytesType)
+		m = protowire.AppendString(m, string(opt))
+	case tcpinfo.KeepAlive:
+		var ka []byte
+		ka = appendVarint(ka, 1, uint64(opt.Idle))
+		ka = appendVarint(ka, 2, uint64(opt.Interval))
+		ka = appendVarint(ka, 3, uint64(opt.Count))
+		m = appendMessage(m, 4, ka)
+	case tcpinfo.Authentication:
+		var ao []byte
+		if opt.SendKeyID >= 0 {
+			ao = protowire.AppendTag(ao, 1, protowire.VarintType)
+			ao = protowire.AppendVarint(ao, uint64(opt.SendKeyID))
+		}
+		if opt.RecvKeyID >= 0 {
+			ao = protowire.AppendTag(ao, 2, protowire.VarintType)
+			ao = protowire.AppendVarint(ao, uint64(opt.RecvKeyID))
+		}
+		if opt.Required {
+			ao = appendVarint(ao, 3, 1)
+		}
+		m = appendMessage(m, 5, ao)
+	}
+	b := appendVarint(nil, 1, uint64(opt.Kind()))
+	b = appendVarint(b, 2, v)
+	return append(b, m...)
+}
+
+func unmarshalOption(b []byte) (tcpinfo.Option, error) {
+	var kind tcpinfo.OptionKind
+	var v uint64
+	var name string
+	ka := tcpinfo.KeepAlive{}
+	ao := tcpinfo.Authentication{SendKeyID: -1, RecvKeyID: -1}
+	err := consume(b, func(num protowire.Number, x uint64, m []byte) error {
+		switch num {
+		case 1:
+			kind = tcpinfo.OptionKind(x)
+		case 2:
+			v = x
+		case 3:
+			name = string(m)
+		case 4:
+			return consume(m, func(num protowire.Number, x uint64, _ []byte) error {
+				switch num {
+				case 1:
+					ka.Idle = time.Duration(x)
+				case 2:
+					ka.Interval = time.Duration(x)
+				case 3:
+					ka.Count = int(x)
+				}
+				return nil
+			})
+		case 5:
+			return consume(m, func(num protowire.Number, x uint64, _ []byte) error {
+				switch num {
+				case 1:
+					ao.SendKeyID = int(x)
+				case 2:
+					ao.RecvKeyID = int(x)
+				case 3:
+					ao.Required = x != 0
+				}
+				return nil
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	switch kind {
+	case tcpinfo.KindMaxSegSize:
+		return tcpinfo.MaxSegSize(v), nil
+	case tcpinfo.KindWindowScale:
+		return tcpinfo.WindowScale(v), nil
+	case tcpinfo.KindSACKPermitted:
+		return tcpinfo.SACKPermitted(v != 0), nil
+	case tcpinfo.KindTimestamps:
+		return tcpinfo.Timestamps(v != 0), nil
+	case tcpinfo.KindNoDelay:
+		return tcpinfo.NoDelay(v != 0), nil
+	case tcpinfo.KindQuickAck:
+		return tcpinfo.QuickAck(v != 0), nil
+	case tcpinfo.KindFastOpen:
+		return tcpinfo.FastOpen(v != 0), nil
+	case tcpinfo.KindECN:
+		return tcpinfo.ECN(v != 0), nil
+	case tcpinfo.KindMD5Signature:
+		return tcpinfo.MD5Signature(v != 0), nil
+	case tcpinfo.KindUserTimeout:
+		return tcpinfo.UserTimeout(v), nil
+	case tcpinfo.KindCCAlgorithm:
+		return tcpinfo.CCAlgorithm(name), nil
+	case tcpinfo.KindKeepAlive:
+		return ka, nil
+	case tcpinfo.KindAuthentication:
+		return ao, nil
+	}
+	return nil, nil
+}
+
+func marshalStats(ds *tcpinfo.DerivedStats) []byte {
+	var b []byte
+	b = appendVarint(b, 1, uint64(ds.MinRTT))
+	b = appendVarint(b, 2, ds.RetransSegs)
+	b = appendVarint(b, 3, ds.RetransBytes)
+	b = appendVarint(b, 4, ds.SegsSent)
+	b = appendVarint(b, 5, ds.SegsReceived)
+	b = appendVarint(b, 6, ds.BytesSent)
+	b = appendVarint(b, 7, ds.BytesReceived)
+	b = appendVarint(b, 8, ds.DeliveryRate)
+	b = appendVarint(b, 9, ds.Retransmits)
+	b = appendVarint(b, 10, ds.Backoffs)
+	return b
+}
+
+func unmarshalStats(b []byte) (*tcpinfo.DerivedStats, error) {
+	ds := &tcpinfo.DerivedStats{}
+	err := consume(b, func(num protowire.Number, v uint64, _ []byte) error {
+		switch num {
+		case 1:
+			ds.MinRTT = time.Duration(v)
+		case 2:
+			ds.RetransSegs = v
+		case 3:
+			ds.RetransBytes = v
+		case 4:
+			ds.SegsSent = v
+		case 5:
+			ds.SegsReceived = v
+		case 6:
+			ds.BytesSent = v
+		case 7:
+			ds.BytesReceived = v
+		case 8:
+			ds.DeliveryRate = v
+		case 9:
+			ds.Retransmits = v
+		case 10:
+			ds.Backoffs = v
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ds, nil
+}
+
+// appendVarint appends a varint field; zero values are omitted as in
+// proto3.
+func appendVarint(b []byte, num protowire.Number, v uint64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, v)
+}
+
+func appendMessage(b []byte, num protowire.Number, m []byte) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, m)
+}
+
+// consume calls fn for each varint or length-delimited field in b.
+// Fields of other wire types are skipped.
+func consume(b []byte, fn func(num protowire.Number, v uint64, m []byte) error) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+		var v uint64
+		var m []byte
+		switch typ {
+		case protowire.VarintType:
+			v, n = protowire.ConsumeVarint(b)
+		case protowire.BytesType:
+			m, n = protowire.ConsumeBytes(b)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, b)
+			if n < 0 {
+				return protowire.ParseError(n)
+			}
+			b = b[n:]
+			continue
+		}
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+		if err := fn(num, v, m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
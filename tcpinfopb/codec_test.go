@@ -0,0 +1,89 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcpinfopb_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/mikioh/tcpinfo"
+	"github.com/mikioh/tcpinfo/tcpinfopb"
+)
+
+func TestMarshalAndUnmarshal(t *testing.T) {
+	s := &tcpinfo.Sample{
+		Time: time.Unix(1, 5),
+		Info: &tcpinfo.Info{
+			State:             tcpinfo.Established,
+			Options:           []tcpinfo.Option{tcpinfo.WindowScale(7), tcpinfo.SACKPermitted(true), tcpinfo.NoDelay(true), tcpinfo.UserTimeout(30 * time.Second), tcpinfo.ECN(true), tcpinfo.KeepAlive{Idle: time.Minute, Interval: 10 * time.Second, Count: 9}, tcpinfo.CCAlgorithm("bbr")},
+			PeerOptions:       []tcpinfo.Option{tcpinfo.WindowScale(9), tcpinfo.MD5Signature(true), tcpinfo.Authentication{SendKeyID: 0, RecvKeyID: -1, Required: true}},
+			SenderMSS:         1448,
+			RTT:               1500 * time.Microsecond,
+			FlowControl:       &tcpinfo.FlowControl{ReceiverWindow: 65535},
+			CongestionControl: &tcpinfo.CongestionControl{SenderWindowSegs: 10},
+			Queue:             &tcpinfo.Queue{Send: 4096},
+		},
+		Final: true,
+	}
+	b, err := tcpinfopb.Marshal(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ss, err := tcpinfopb.Unmarshal(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ss.Time.Equal(s.Time) || !ss.Final || !reflect.DeepEqual(ss.Info, s.Info) {
+		t.Fatalf("got %+v; want %+v", ss.Info, s.Info)
+	}
+
+	d := &tcpinfo.Delta{Duration: time.Second, BytesSent: 1 << 20, RetransSegs: 3}
+	b, err = tcpinfopb.MarshalDelta(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dd, err := tcpinfopb.UnmarshalDelta(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *dd != *d {
+		t.Fatalf("got %+v; want %+v", dd, d)
+	}
+	if _, err := tcpinfopb.Unmarshal([]byte{0x12, 0x05}); err == nil {
+		t.Fatal("got nil; want an error")
+	}
+}
+
+func TestUnmarshalOlderSchema(t *testing.T) {
+	b, err := tcpinfopb.Marshal(&tcpinfo.Sample{Time: time.Unix(1, 0), Info: &tcpinfo.Info{RTT: time.Millisecond}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := tcpinfopb.Unmarshal(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Version != tcpinfo.SchemaVersion {
+		t.Fatalf("got %d; want %d", s.Version, tcpinfo.SchemaVersion)
+	}
+
+	// The version is encoded first as field 6; encodings lacking it
+	// are of version 1.
+	if b[0] != 6<<3 {
+		t.Fatalf("got %#x; want version field", b[0])
+	}
+	s, err = tcpinfopb.Unmarshal(b[2:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Version != 1 || s.Info.RTT != time.Millisecond || !s.Stats.Valid("rexmits") || !s.Stats.Valid("backoffs") || !s.Stats.Valid("min_rtt") {
+		t.Fatalf("got %+v, %+v", s, s.Stats)
+	}
+
+	if _, err := tcpinfopb.Unmarshal(append([]byte{6 << 3, tcpinfo.SchemaVersion + 1}, b[2:]...)); err != tcpinfo.ErrUnknownSchema {
+		t.Fatalf("got %v; want %v", err, tcpinfo.ErrUnknownSchema)
+	}
+}
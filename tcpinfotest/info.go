@@ -0,0 +1,101 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcpinfotest
+
+import (
+	"time"
+
+	"github.com/mikioh/tcpinfo"
+)
+
+// An InfoBuilder builds connection information.
+type InfoBuilder struct {
+	i tcpinfo.Info
+}
+
+// NewInfo returns a new builder of connection information on an
+// established connection with typical values.
+func NewInfo() *InfoBuilder {
+	return &InfoBuilder{i: tcpinfo.Info{
+		State:             tcpinfo.Established,
+		SenderMSS:         1448,
+		ReceiverMSS:       1448,
+		RTT:               10 * time.Millisecond,
+		RTTVar:            5 * time.Millisecond,
+		RTO:               210 * time.Millisecond,
+		FlowControl:       &tcpinfo.FlowControl{ReceiverWindow: 65535},
+		CongestionControl: &tcpinfo.CongestionControl{SenderSSThreshold: 1<<31 - 1, SenderWindowSegs: 10},
+		Sys:               new(tcpinfo.SysInfo),
+	}}
+}
+
+// State sets the connection state.
+func (b *InfoBuilder) State(st tcpinfo.State) *InfoBuilder {
+	b.i.State = st
+	return b
+}
+
+// MSS sets the maximum segment sizes for sender and receiver.
+func (b *InfoBuilder) MSS(snd, rcv tcpinfo.MaxSegSize) *InfoBuilder {
+	b.i.SenderMSS, b.i.ReceiverMSS = snd, rcv
+	return b
+}
+
+// RTT sets the round-trip time and its variation.
+func (b *InfoBuilder) RTT(rtt, rttvar time.Duration) *InfoBuilder {
+	b.i.RTT, b.i.RTTVar = rtt, rttvar
+	return b
+}
+
+// RTO sets the retransmission timeout.
+func (b *InfoBuilder) RTO(rto time.Duration) *InfoBuilder {
+	b.i.RTO = rto
+	return b
+}
+
+// Idle sets the durations since last data sent, last data received
+// and last ack received.
+func (b *InfoBuilder) Idle(sent, rcvd, ack time.Duration) *InfoBuilder {
+	b.i.LastDataSent, b.i.LastDataReceived, b.i.LastAckReceived = sent, rcvd, ack
+	return b
+}
+
+// Options sets the requesting options and the options requested
+// from peer.
+func (b *InfoBuilder) Options(opts, peerOpts []tcpinfo.Option) *InfoBuilder {
+	b.i.Options, b.i.PeerOptions = opts, peerOpts
+	return b
+}
+
+// ReceiverWindow sets the advertised receiver window in bytes.
+func (b *InfoBuilder) ReceiverWindow(n uint) *InfoBuilder {
+	b.i.FlowControl = &tcpinfo.FlowControl{ReceiverWindow: n}
+	return b
+}
+
+// CongestionControl sets the congestion control information.
+func (b *InfoBuilder) CongestionControl(cc tcpinfo.CongestionControl) *InfoBuilder {
+	b.i.CongestionControl = &cc
+	return b
+}
+
+// Queue sets the queue occupancy in bytes.
+func (b *InfoBuilder) Queue(rcv, snd uint) *InfoBuilder {
+	b.i.Queue = &tcpinfo.Queue{Receive: rcv, Send: snd}
+	return b
+}
+
+// Sys invokes fn to set the platform-specific information.
+func (b *InfoBuilder) Sys(fn func(si *tcpinfo.SysInfo)) *InfoBuilder {
+	if b.i.Sys == nil {
+		b.i.Sys = new(tcpinfo.SysInfo)
+	}
+	fn(b.i.Sys)
+	return b
+}
+
+// Build returns a new copy of the connection information built so
+// far.
+func (b *InfoBuilder) Build() *tcpinfo.Info { return clone(&b.i) }
@@ -0,0 +1,84 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcpinfotest
+
+import (
+	"net"
+	"time"
+
+	"github.com/mikioh/tcpinfo"
+)
+
+// A Sampler takes samples of connection information from a getter on
+// a virtual clock, as tcpinfo.Sampler does on the wall clock.
+//
+// Samples are taken only when the clock is advanced, which makes the
+// sequence of samples deterministic.
+type Sampler struct {
+	g      tcpinfo.Getter
+	c      net.Conn
+	d      time.Duration
+	fn     tcpinfo.SampleFunc
+	start  time.Time
+	now    time.Time
+	minRTT time.Duration
+	fs     *tcpinfo.FinalStats
+}
+
+// NewSampler returns a new sampler that takes a sample of connection
+// information on c from g every d of the virtual clock starting at
+// start, and invokes fn with it.
+// The callback function fn may be nil.
+func NewSampler(g tcpinfo.Getter, c net.Conn, start time.Time, d time.Duration, fn tcpinfo.SampleFunc) *Sampler {
+	return &Sampler{g: g, c: c, d: d, fn: fn, start: start, now: start}
+}
+
+// Now returns the current time of the virtual clock.
+func (s *Sampler) Now() time.Time { return s.now }
+
+// Advance advances the virtual clock n times by the sampling
+// interval, takes a sample each time and returns the samples.
+// It does nothing once the sampler is stopped.
+func (s *Sampler) Advance(n int) []*tcpinfo.Sample {
+	if s.fs != nil {
+		return nil
+	}
+	smps := make([]*tcpinfo.Sample, 0, n)
+	for k := 0; k < n; k++ {
+		s.now = s.now.Add(s.d)
+		smps = append(smps, s.sample(false))
+	}
+	return smps
+}
+
+func (s *Sampler) sample(final bool) *tcpinfo.Sample {
+	smp := &tcpinfo.Sample{Time: s.now, Final: final}
+	smp.Info, smp.Err = s.g.Get(s.c)
+	if smp.Info != nil && smp.Info.RTT > 0 && (s.minRTT == 0 || smp.Info.RTT < s.minRTT) {
+		s.minRTT = smp.Info.RTT
+	}
+	if s.fn != nil {
+		s.fn(s.c, smp)
+	}
+	return smp
+}
+
+// Stop takes the final sample at the current time of the virtual
+// clock, invokes the callback function with it and returns a summary
+// as tcpinfo.Sampler does.
+func (s *Sampler) Stop() *tcpinfo.FinalStats {
+	if s.fs != nil {
+		return s.fs
+	}
+	smp := s.sample(true)
+	s.fs = &tcpinfo.FinalStats{Info: smp.Info, Err: smp.Err, Duration: smp.Time.Sub(s.start), MinRTT: s.minRTT}
+	if smp.Info != nil {
+		s.fs.Stats = smp.Info.Stats()
+		if s.fs.Stats.MinRTT > 0 && (s.fs.MinRTT == 0 || s.fs.Stats.MinRTT < s.fs.MinRTT) {
+			s.fs.MinRTT = s.fs.Stats.MinRTT
+		}
+	}
+	return s.fs
+}
@@ -0,0 +1,202 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package tcpinfotest provides fakes of TCP connection information
+// retrieval for testing.
+//
+// A Getter returns scripted connection information instead of
+// querying sockets, an InfoBuilder builds connection information
+// values, and a Sampler takes samples on a virtual clock, which lets
+// applications unit-test their telemetry pipelines without real
+// sockets.
+//
+// Example:
+//
+//	c := tcpinfotest.NewConn("192.0.2.1:443", "192.0.2.2:50000")
+//	g := tcpinfotest.NewGetter()
+//	g.Script(c,
+//		tcpinfotest.Step{Info: tcpinfotest.NewInfo().RTT(10*time.Millisecond, time.Millisecond).Build()},
+//		tcpinfotest.Step{Info: tcpinfotest.NewInfo().State(tcpinfo.CloseWait).Build()},
+//	)
+//	s := tcpinfotest.NewSampler(g, c, time.Unix(0, 0), time.Second, fn)
+//	s.Advance(2)
+//	fs := s.Stop()
+package tcpinfotest
+
+import (
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/mikioh/tcpinfo"
+)
+
+var _ tcpinfo.Getter = &Getter{}
+
+// ErrUnknownConn is returned when no connection information is
+// scripted for the connection.
+var ErrUnknownConn = errors.New("unknown connection")
+
+// A Step represents a scripted result of retrieval.
+type Step struct {
+	Info *tcpinfo.Info // connection information; ignored when Err is not nil
+	Err  error         // error on retrieval
+}
+
+// A Getter is a fake of connection information retrieval.
+//
+// Each retrieval on a connection returns the next step scripted for
+// the connection, and the last step is repeated once the script is
+// exhausted.
+// The returned connection information is a copy and may be modified
+// by the caller.
+type Getter struct {
+	mu      sync.Mutex
+	scripts map[net.Conn]*script
+}
+
+type script struct {
+	steps []Step
+	calls int
+}
+
+// NewGetter returns a new getter without scripts.
+func NewGetter() *Getter {
+	return &Getter{scripts: make(map[net.Conn]*script)}
+}
+
+// Script appends steps to the script for c.
+func (g *Getter) Script(c net.Conn, steps ...Step) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	s := g.scripts[c]
+	if s == nil {
+		s = new(script)
+		g.scripts[c] = s
+	}
+	s.steps = append(s.steps, steps...)
+}
+
+// Set replaces the script for c with a single step returning i.
+func (g *Getter) Set(c net.Conn, i *tcpinfo.Info) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.scripts[c] = &script{steps: []Step{{Info: i}}}
+}
+
+// Get returns the next scripted connection information on c.
+func (g *Getter) Get(c net.Conn) (*tcpinfo.Info, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	s := g.scripts[c]
+	if s == nil || len(s.steps) == 0 {
+		return nil, ErrUnknownConn
+	}
+	st := s.steps[len(s.steps)-1]
+	if s.calls < len(s.steps) {
+		st = s.steps[s.calls]
+	}
+	s.calls++
+	if st.Err != nil {
+		return nil, st.Err
+	}
+	return clone(st.Info), nil
+}
+
+// Calls returns the number of retrievals on c.
+func (g *Getter) Calls(c net.Conn) int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if s := g.scripts[c]; s != nil {
+		return s.calls
+	}
+	return 0
+}
+
+// clone returns a deep copy of i.
+func clone(i *tcpinfo.Info) *tcpinfo.Info {
+	if i == nil {
+		return new(tcpinfo.Info)
+	}
+	return i.Clone()
+}
+
+// A Conn is a fake TCP connection without a socket.
+//
+// Reads return io.EOF, writes are discarded, and deadlines are
+// ignored.
+type Conn struct {
+	laddr, raddr net.Addr
+
+	mu     sync.Mutex
+	closed bool
+}
+
+// NewConn returns a new connection between the local address laddr
+// and the remote address raddr, both in the form of "host:port".
+// It panics when the addresses are malformed.
+func NewConn(laddr, raddr string) *Conn {
+	la, err := net.ResolveTCPAddr("tcp", laddr)
+	if err != nil {
+		panic(err)
+	}
+	ra, err := net.ResolveTCPAddr("tcp", raddr)
+	if err != nil {
+		panic(err)
+	}
+	return &Conn{laddr: la, raddr: ra}
+}
+
+// Read implements the Read method of net.Conn interface.
+func (c *Conn) Read(b []byte) (int, error) {
+	if c.isClosed() {
+		return 0, net.ErrClosed
+	}
+	return 0, io.EOF
+}
+
+// Write implements the Write method of net.Conn interface.
+func (c *Conn) Write(b []byte) (int, error) {
+	if c.isClosed() {
+		return 0, net.ErrClosed
+	}
+	return len(b), nil
+}
+
+// Close implements the Close method of net.Conn interface.
+func (c *Conn) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return net.ErrClosed
+	}
+	c.closed = true
+	return nil
+}
+
+func (c *Conn) isClosed() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.closed
+}
+
+// LocalAddr implements the LocalAddr method of net.Conn interface.
+func (c *Conn) LocalAddr() net.Addr { return c.laddr }
+
+// RemoteAddr implements the RemoteAddr method of net.Conn interface.
+func (c *Conn) RemoteAddr() net.Addr { return c.raddr }
+
+// SetDeadline implements the SetDeadline method of net.Conn
+// interface.
+func (c *Conn) SetDeadline(t time.Time) error { return nil }
+
+// SetReadDeadline implements the SetReadDeadline method of net.Conn
+// interface.
+func (c *Conn) SetReadDeadline(t time.Time) error { return nil }
+
+// SetWriteDeadline implements the SetWriteDeadline method of
+// net.Conn interface.
+func (c *Conn) SetWriteDeadline(t time.Time) error { return nil }
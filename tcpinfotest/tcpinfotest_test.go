@@ -0,0 +1,102 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcpinfotest_test
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/mikioh/tcpinfo"
+	"github.com/mikioh/tcpinfo/tcpinfotest"
+)
+
+func TestGetter(t *testing.T) {
+	c := tcpinfotest.NewConn("192.0.2.1:443", "192.0.2.2:50000")
+	g := tcpinfotest.NewGetter()
+	if _, err := g.Get(c); err != tcpinfotest.ErrUnknownConn {
+		t.Fatalf("got %v; want %v", err, tcpinfotest.ErrUnknownConn)
+	}
+	errReset := errors.New("connection reset")
+	g.Script(c,
+		tcpinfotest.Step{Info: tcpinfotest.NewInfo().Build()},
+		tcpinfotest.Step{Err: errReset},
+		tcpinfotest.Step{Info: tcpinfotest.NewInfo().State(tcpinfo.CloseWait).Build()},
+	)
+	for _, want := range []struct {
+		st  tcpinfo.State
+		err error
+	}{
+		{tcpinfo.Established, nil},
+		{tcpinfo.Unknown, errReset},
+		{tcpinfo.CloseWait, nil},
+		{tcpinfo.CloseWait, nil},
+	} {
+		i, err := g.Get(c)
+		if err != want.err {
+			t.Fatalf("got %v; want %v", err, want.err)
+		}
+		if err != nil {
+			continue
+		}
+		if i.State != want.st {
+			t.Fatalf("got %v; want %v", i.State, want.st)
+		}
+		i.FlowControl.ReceiverWindow = 0
+	}
+	if n := g.Calls(c); n != 4 {
+		t.Fatalf("got %d; want 4", n)
+	}
+	i, _ := g.Get(c)
+	if i.FlowControl.ReceiverWindow == 0 {
+		t.Fatal("scripted information modified by caller")
+	}
+}
+
+func TestSampler(t *testing.T) {
+	c := tcpinfotest.NewConn("[2001:db8::1]:443", "[2001:db8::2]:50000")
+	g := tcpinfotest.NewGetter()
+	b := tcpinfotest.NewInfo()
+	g.Script(c,
+		tcpinfotest.Step{Info: b.RTT(30*time.Millisecond, time.Millisecond).Build()},
+		tcpinfotest.Step{Info: b.RTT(20*time.Millisecond, time.Millisecond).Build()},
+		tcpinfotest.Step{Info: b.RTT(40*time.Millisecond, time.Millisecond).State(tcpinfo.CloseWait).Build()},
+	)
+	start := time.Unix(1500000000, 0)
+	var smps []*tcpinfo.Sample
+	s := tcpinfotest.NewSampler(g, c, start, time.Second, func(_ net.Conn, smp *tcpinfo.Sample) {
+		smps = append(smps, smp)
+	})
+	if got := s.Advance(2); len(got) != 2 || got[1].Time != start.Add(2*time.Second) {
+		t.Fatalf("got %v; want 2 samples", got)
+	}
+	fs := s.Stop()
+	if len(smps) != 3 || !smps[2].Final {
+		t.Fatalf("got %v; want 3 samples ending with final one", smps)
+	}
+	if fs.Duration != 2*time.Second || fs.MinRTT != 20*time.Millisecond || fs.Info.State != tcpinfo.CloseWait {
+		t.Fatalf("got %+v", fs)
+	}
+	if s.Stop() != fs || s.Advance(1) != nil {
+		t.Fatal("sampler not stopped")
+	}
+}
+
+func TestConn(t *testing.T) {
+	c := tcpinfotest.NewConn("127.0.0.1:1", "127.0.0.1:2")
+	if n, err := c.Write(make([]byte, 10)); n != 10 || err != nil {
+		t.Fatalf("got %d, %v; want 10, <nil>", n, err)
+	}
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Read(make([]byte, 1)); err == nil {
+		t.Fatal("read on closed connection succeeded")
+	}
+	if c.RemoteAddr().String() != "127.0.0.1:2" {
+		t.Fatalf("got %v; want 127.0.0.1:2", c.RemoteAddr())
+	}
+}
@@ -0,0 +1,277 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcpmetrics
+
+import (
+	"encoding/binary"
+	"net"
+	"os"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+const (
+	sysGENL_ID_CTRL          = 0x10
+	sysCTRL_CMD_GETFAMILY    = 0x3
+	sysCTRL_ATTR_FAMILY_ID   = 0x1
+	sysCTRL_ATTR_FAMILY_NAME = 0x2
+
+	sysTCP_METRICS_GENL_NAME    = "tcp_metrics"
+	sysTCP_METRICS_GENL_VERSION = 0x1
+	sysTCP_METRICS_CMD_GET      = 0x1
+
+	sysTCP_METRICS_ATTR_ADDR_IPV4  = 0x1
+	sysTCP_METRICS_ATTR_ADDR_IPV6  = 0x2
+	sysTCP_METRICS_ATTR_AGE        = 0x3
+	sysTCP_METRICS_ATTR_VALS       = 0x6
+	sysTCP_METRICS_ATTR_SADDR_IPV4 = 0xb
+	sysTCP_METRICS_ATTR_SADDR_IPV6 = 0xc
+
+	sysTCP_METRIC_RTT        = 0x0
+	sysTCP_METRIC_RTTVAR     = 0x1
+	sysTCP_METRIC_SSTHRESH   = 0x2
+	sysTCP_METRIC_CWND       = 0x3
+	sysTCP_METRIC_REORDERING = 0x4
+	sysTCP_METRIC_RTT_US     = 0x5
+	sysTCP_METRIC_RTTVAR_US  = 0x6
+
+	sizeofGenlMsghdr = 0x4
+)
+
+// List returns all entries of the TCP metrics cache.
+func List() ([]Metrics, error) {
+	c, err := dial()
+	if err != nil {
+		return nil, err
+	}
+	defer c.close()
+	var ms []Metrics
+	err = c.query(syscall.NLM_F_REQUEST|syscall.NLM_F_DUMP, nil, func(m *Metrics) {
+		ms = append(ms, *m)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ms, nil
+}
+
+// Lookup returns the TCP metrics saved for the destination address
+// dst.
+func Lookup(dst net.IP) (*Metrics, error) {
+	var attr []byte
+	if ip := dst.To4(); ip != nil {
+		attr = appendAttr(nil, sysTCP_METRICS_ATTR_ADDR_IPV4, ip)
+	} else if ip := dst.To16(); ip != nil {
+		attr = appendAttr(nil, sysTCP_METRICS_ATTR_ADDR_IPV6, ip)
+	} else {
+		return nil, errInvalidAddr
+	}
+	c, err := dial()
+	if err != nil {
+		return nil, err
+	}
+	defer c.close()
+	var m *Metrics
+	err = c.query(syscall.NLM_F_REQUEST, attr, func(m0 *Metrics) {
+		m = m0
+	})
+	if err == syscall.ESRCH || err == nil && m == nil {
+		return nil, errNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+type conn struct {
+	s      int
+	family uint16
+}
+
+func dial() (*conn, error) {
+	s, err := syscall.Socket(syscall.AF_NETLINK, syscall.SOCK_RAW|syscall.SOCK_CLOEXEC, syscall.NETLINK_GENERIC)
+	if err != nil {
+		return nil, os.NewSyscallError("socket", err)
+	}
+	if err := syscall.Bind(s, &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK}); err != nil {
+		syscall.Close(s)
+		return nil, os.NewSyscallError("bind", err)
+	}
+	c := &conn{s: s}
+	attr := appendAttr(nil, sysCTRL_ATTR_FAMILY_NAME, append([]byte(sysTCP_METRICS_GENL_NAME), 0))
+	err = c.roundTrip(sysGENL_ID_CTRL, sysCTRL_CMD_GETFAMILY, syscall.NLM_F_REQUEST, attr, func(b []byte) error {
+		return walkAttrs(b, func(typ uint16, data []byte) {
+			if typ == sysCTRL_ATTR_FAMILY_ID && len(data) >= 2 {
+				c.family = nativeEndian.Uint16(data)
+			}
+		})
+	})
+	if err == nil && c.family == 0 {
+		err = errOpNoSupport
+	}
+	if err != nil {
+		c.close()
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *conn) close() error {
+	return syscall.Close(c.s)
+}
+
+func (c *conn) query(flags uint16, attr []byte, fn func(*Metrics)) error {
+	return c.roundTrip(c.family, sysTCP_METRICS_CMD_GET, flags, attr, func(b []byte) error {
+		m, err := parseMetrics(b)
+		if err != nil {
+			return err
+		}
+		fn(m)
+		return nil
+	})
+}
+
+// roundTrip sends a generic netlink request and calls fn with the
+// attributes of each reply.
+func (c *conn) roundTrip(typ uint16, cmd uint8, flags uint16, attr []byte, fn func([]byte) error) error {
+	b := make([]byte, syscall.NLMSG_HDRLEN+sizeofGenlMsghdr+len(attr))
+	h := (*syscall.NlMsghdr)(unsafe.Pointer(&b[0]))
+	h.Len = uint32(len(b))
+	h.Type = typ
+	h.Flags = flags
+	h.Seq = 1
+	b[syscall.NLMSG_HDRLEN] = cmd
+	b[syscall.NLMSG_HDRLEN+1] = sysTCP_METRICS_GENL_VERSION
+	copy(b[syscall.NLMSG_HDRLEN+sizeofGenlMsghdr:], attr)
+	if err := syscall.Sendto(c.s, b, 0, &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK}); err != nil {
+		return os.NewSyscallError("sendto", err)
+	}
+	rb := make([]byte, os.Getpagesize()*8)
+	for {
+		n, _, err := syscall.Recvfrom(c.s, rb, 0)
+		if err != nil {
+			return os.NewSyscallError("recvfrom", err)
+		}
+		msgs, err := syscall.ParseNetlinkMessage(rb[:n])
+		if err != nil {
+			return err
+		}
+		for _, m := range msgs {
+			switch m.Header.Type {
+			case syscall.NLMSG_DONE:
+				return nil
+			case syscall.NLMSG_ERROR:
+				if len(m.Data) < 4 {
+					return errShortMessage
+				}
+				if errno := -int32(nativeEndian.Uint32(m.Data)); errno != 0 {
+					return syscall.Errno(errno)
+				}
+				return nil
+			case typ:
+				if len(m.Data) < sizeofGenlMsghdr {
+					return errShortMessage
+				}
+				if err := fn(m.Data[sizeofGenlMsghdr:]); err != nil {
+					return err
+				}
+			}
+		}
+		if flags&syscall.NLM_F_DUMP == 0 {
+			return nil
+		}
+	}
+}
+
+func parseMetrics(b []byte) (*Metrics, error) {
+	var m Metrics
+	var rttMS, rttVarMS, rttUS, rttVarUS time.Duration
+	err := walkAttrs(b, func(typ uint16, data []byte) {
+		switch typ {
+		case sysTCP_METRICS_ATTR_ADDR_IPV4, sysTCP_METRICS_ATTR_ADDR_IPV6:
+			m.Addr = append(net.IP(nil), data...)
+		case sysTCP_METRICS_ATTR_SADDR_IPV4, sysTCP_METRICS_ATTR_SADDR_IPV6:
+			m.SourceAddr = append(net.IP(nil), data...)
+		case sysTCP_METRICS_ATTR_AGE:
+			if len(data) >= 8 {
+				m.Age = time.Duration(nativeEndian.Uint64(data)) * time.Millisecond
+			}
+		case sysTCP_METRICS_ATTR_VALS:
+			walkAttrs(data, func(typ uint16, data []byte) {
+				if len(data) < 4 || typ == 0 {
+					return
+				}
+				v := nativeEndian.Uint32(data)
+				switch typ - 1 {
+				case sysTCP_METRIC_RTT:
+					rttMS = time.Duration(v) * time.Millisecond
+				case sysTCP_METRIC_RTTVAR:
+					rttVarMS = time.Duration(v) * time.Millisecond
+				case sysTCP_METRIC_SSTHRESH:
+					m.SSThreshold = uint(v)
+				case sysTCP_METRIC_CWND:
+					m.CWnd = uint(v)
+				case sysTCP_METRIC_REORDERING:
+					m.Reordering = uint(v)
+				case sysTCP_METRIC_RTT_US:
+					rttUS = time.Duration(v) * time.Microsecond
+				case sysTCP_METRIC_RTTVAR_US:
+					rttVarUS = time.Duration(v) * time.Microsecond
+				}
+			})
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	m.RTT, m.RTTVar = rttMS, rttVarMS
+	if rttUS > 0 {
+		m.RTT = rttUS
+	}
+	if rttVarUS > 0 {
+		m.RTTVar = rttVarUS
+	}
+	return &m, nil
+}
+
+func walkAttrs(b []byte, fn func(typ uint16, data []byte)) error {
+	for len(b) >= syscall.SizeofRtAttr {
+		l := int(nativeEndian.Uint16(b[0:2]))
+		if l < syscall.SizeofRtAttr || l > len(b) {
+			return errShortMessage
+		}
+		fn(nativeEndian.Uint16(b[2:4])&^syscall.NLA_F_NESTED, b[syscall.SizeofRtAttr:l])
+		if nlaAlign(l) >= len(b) {
+			break
+		}
+		b = b[nlaAlign(l):]
+	}
+	return nil
+}
+
+func appendAttr(b []byte, typ uint16, data []byte) []byte {
+	l := syscall.SizeofRtAttr + len(data)
+	var h [syscall.SizeofRtAttr]byte
+	nativeEndian.PutUint16(h[0:2], uint16(l))
+	nativeEndian.PutUint16(h[2:4], typ)
+	b = append(b, h[:]...)
+	b = append(b, data...)
+	return append(b, make([]byte, nlaAlign(l)-l)...)
+}
+
+func nlaAlign(l int) int { return (l + syscall.NLMSG_ALIGNTO - 1) &^ (syscall.NLMSG_ALIGNTO - 1) }
+
+var nativeEndian binary.ByteOrder
+
+func init() {
+	i := uint32(1)
+	if *(*byte)(unsafe.Pointer(&i)) == 1 {
+		nativeEndian = binary.LittleEndian
+	} else {
+		nativeEndian = binary.BigEndian
+	}
+}
@@ -0,0 +1,16 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build !linux
+
+package tcpmetrics
+
+import "net"
+
+// List returns all entries of the TCP metrics cache.
+func List() ([]Metrics, error) { return nil, errOpNoSupport }
+
+// Lookup returns the TCP metrics saved for the destination address
+// dst.
+func Lookup(dst net.IP) (*Metrics, error) { return nil, errOpNoSupport }
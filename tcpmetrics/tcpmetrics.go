@@ -0,0 +1,41 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package tcpmetrics implements a reader for the kernel's
+// per-destination TCP metrics cache.
+//
+// The kernel saves metrics such as round-trip time, slow start
+// threshold and congestion window of closed connections per
+// destination, and uses them to initialize new connections to the
+// same destination.
+// Applications can read the cache to learn what to expect from a
+// peer before connecting to it.
+//
+// Only supported on Linux.
+package tcpmetrics
+
+import (
+	"errors"
+	"net"
+	"time"
+)
+
+var (
+	errInvalidAddr  = errors.New("invalid address")
+	errNotFound     = errors.New("metrics not found")
+	errOpNoSupport  = errors.New("operation not supported")
+	errShortMessage = errors.New("short message")
+)
+
+// A Metrics represents TCP metrics saved for a destination.
+type Metrics struct {
+	Addr        net.IP        // destination address
+	SourceAddr  net.IP        // source address; nil when not reported
+	Age         time.Duration // since metrics were last updated
+	RTT         time.Duration // smoothed round-trip time
+	RTTVar      time.Duration // round-trip time variation
+	SSThreshold uint          // slow start threshold in # of segments
+	CWnd        uint          // congestion window in # of segments
+	Reordering  uint          // reordering metric in # of segments
+}
@@ -0,0 +1,38 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcpmetrics_test
+
+import (
+	"net"
+	"runtime"
+	"testing"
+
+	"github.com/mikioh/tcpinfo/tcpmetrics"
+)
+
+func TestListAndLookup(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skipf("%s/%s", runtime.GOOS, runtime.GOARCH)
+	}
+	ms, err := tcpmetrics.List()
+	if err != nil {
+		t.Skip(err)
+	}
+	for _, m := range ms {
+		if m.Addr == nil {
+			t.Fatalf("got %+v; want destination address", m)
+		}
+		m0, err := tcpmetrics.Lookup(m.Addr)
+		if err != nil {
+			continue // may have been evicted
+		}
+		if !m0.Addr.Equal(m.Addr) {
+			t.Fatalf("got %v; want %v", m0.Addr, m.Addr)
+		}
+	}
+	if _, err := tcpmetrics.Lookup(net.ParseIP("192.0.2.255")); err == nil {
+		t.Fatal("got nil; want an error")
+	}
+}
@@ -0,0 +1,214 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package tcppcap correlates packet captures of TCP connections with
+// samples of connection information.
+//
+// ReadPackets reads TCP segments from a capture file in the libpcap
+// format, such as one written by tcpdump -w, and Correlate aligns
+// the segments of a connection with samples taken on the connection
+// and annotates retransmissions, producing a merged timeline for
+// deep-dive debugging.
+//
+// The pcapng format is not supported; use editcap -F pcap to convert
+// captures.
+package tcppcap
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"time"
+)
+
+var (
+	errUnknownFormat   = errors.New("unknown capture format")
+	errUnknownLinkType = errors.New("unknown link type")
+	errMalformedRecord = errors.New("malformed record")
+)
+
+// A Flags represents TCP header flags.
+type Flags uint8
+
+const (
+	FIN Flags = 1 << iota
+	SYN
+	RST
+	PSH
+	ACK
+	URG
+	ECE
+	CWR
+)
+
+// A Packet represents a captured TCP segment.
+type Packet struct {
+	Time   time.Time    // time when the segment was captured
+	Src    *net.TCPAddr // source address
+	Dst    *net.TCPAddr // destination address
+	Seq    uint32       // sequence number
+	Ack    uint32       // acknowledgment number
+	Flags  Flags        // header flags
+	Window uint16       // window field, not scaled
+	Len    int          // length of payload in bytes
+}
+
+// Link types of the libpcap format.
+const (
+	linkTypeNull      = 0
+	linkTypeEthernet  = 1
+	linkTypeRaw       = 101
+	linkTypeLoop      = 108
+	linkTypeLinuxSLL  = 113
+	linkTypeIPv4      = 228
+	linkTypeIPv6      = 229
+	linkTypeLinuxSLL2 = 276
+)
+
+// ReadPackets reads TCP segments over IPv4 or IPv6 from a capture
+// file in the libpcap format read from r.
+// Other packets and fragments of IP datagrams are skipped.
+func ReadPackets(r io.Reader) ([]Packet, error) {
+	var hdr [24]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return nil, err
+	}
+	var (
+		bo    binary.ByteOrder
+		nanos bool
+	)
+	switch binary.LittleEndian.Uint32(hdr[:4]) {
+	case 0xa1b2c3d4:
+		bo = binary.LittleEndian
+	case 0xa1b23c4d:
+		bo, nanos = binary.LittleEndian, true
+	case 0xd4c3b2a1:
+		bo = binary.BigEndian
+	case 0x4d3cb2a1:
+		bo, nanos = binary.BigEndian, true
+	default:
+		return nil, errUnknownFormat
+	}
+	lt := bo.Uint32(hdr[20:24]) & 0x0fffffff
+	switch lt {
+	case linkTypeNull, linkTypeEthernet, linkTypeRaw, linkTypeLoop, linkTypeLinuxSLL, linkTypeIPv4, linkTypeIPv6, linkTypeLinuxSLL2:
+	default:
+		return nil, errUnknownLinkType
+	}
+	var pkts []Packet
+	var rec [16]byte
+	var b []byte
+	for {
+		if _, err := io.ReadFull(r, rec[:]); err != nil {
+			if err == io.EOF {
+				return pkts, nil
+			}
+			return nil, err
+		}
+		sec, frac := int64(bo.Uint32(rec[0:4])), int64(bo.Uint32(rec[4:8]))
+		if !nanos {
+			frac *= 1000
+		}
+		n := int(bo.Uint32(rec[8:12]))
+		if n > 1<<18 {
+			return nil, errMalformedRecord
+		}
+		if cap(b) < n {
+			b = make([]byte, n)
+		}
+		b = b[:n]
+		if _, err := io.ReadFull(r, b); err != nil {
+			return nil, err
+		}
+		ip, ok := network(lt, b)
+		if !ok {
+			continue
+		}
+		p := Packet{Time: time.Unix(sec, frac)}
+		if parseIP(ip, &p) {
+			pkts = append(pkts, p)
+		}
+	}
+}
+
+// network returns the network layer of the link-layer frame b.
+func network(lt uint32, b []byte) ([]byte, bool) {
+	switch lt {
+	case linkTypeNull, linkTypeLoop:
+		if len(b) < 4 {
+			return nil, false
+		}
+		return b[4:], true
+	case linkTypeEthernet:
+		if len(b) < 14 {
+			return nil, false
+		}
+		et, b := binary.BigEndian.Uint16(b[12:14]), b[14:]
+		for (et == 0x8100 || et == 0x88a8) && len(b) >= 4 {
+			et, b = binary.BigEndian.Uint16(b[2:4]), b[4:]
+		}
+		return b, et == 0x0800 || et == 0x86dd
+	case linkTypeLinuxSLL:
+		if len(b) < 16 {
+			return nil, false
+		}
+		return b[16:], true
+	case linkTypeLinuxSLL2:
+		if len(b) < 20 {
+			return nil, false
+		}
+		return b[20:], true
+	default:
+		return b, true
+	}
+}
+
+// parseIP parses the TCP segment in the IP datagram b into p.
+// The length of payload is taken from the IP header, as the captured
+// datagram may be truncated by the snapshot length.
+func parseIP(b []byte, p *Packet) bool {
+	if len(b) < 1 {
+		return false
+	}
+	var (
+		src, dst net.IP
+		n        int // length of segment
+	)
+	switch b[0] >> 4 {
+	case 4:
+		if len(b) < 20 {
+			return false
+		}
+		hl, tl := int(b[0]&0x0f)<<2, int(binary.BigEndian.Uint16(b[2:4]))
+		if b[9] != 6 || hl < 20 || tl < hl || len(b) < hl || binary.BigEndian.Uint16(b[6:8])&0x3fff != 0 {
+			return false
+		}
+		src, dst = net.IP(append([]byte(nil), b[12:16]...)), net.IP(append([]byte(nil), b[16:20]...))
+		b, n = b[hl:], tl-hl
+	case 6:
+		if len(b) < 40 || b[6] != 6 {
+			return false
+		}
+		src, dst = net.IP(append([]byte(nil), b[8:24]...)), net.IP(append([]byte(nil), b[24:40]...))
+		b, n = b[40:], int(binary.BigEndian.Uint16(b[4:6]))
+	default:
+		return false
+	}
+	if len(b) < 20 {
+		return false
+	}
+	off := int(b[12]>>4) << 2
+	if off < 20 || n < off {
+		return false
+	}
+	p.Src = &net.TCPAddr{IP: src, Port: int(binary.BigEndian.Uint16(b[0:2]))}
+	p.Dst = &net.TCPAddr{IP: dst, Port: int(binary.BigEndian.Uint16(b[2:4]))}
+	p.Seq = binary.BigEndian.Uint32(b[4:8])
+	p.Ack = binary.BigEndian.Uint32(b[8:12])
+	p.Flags = Flags(b[13])
+	p.Window = binary.BigEndian.Uint16(b[14:16])
+	p.Len = n - off
+	return true
+}
@@ -0,0 +1,125 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcppcap_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/mikioh/tcpinfo"
+	"github.com/mikioh/tcpinfo/tcppcap"
+)
+
+type segment struct {
+	at       time.Duration
+	src, dst string
+	seq      uint32
+	flags    tcppcap.Flags
+	n        int
+}
+
+// capture returns a capture file in the libpcap format of Ethernet
+// frames carrying segs.
+func capture(start time.Time, segs []segment) []byte {
+	var b bytes.Buffer
+	hdr := make([]byte, 24)
+	binary.LittleEndian.PutUint32(hdr[0:4], 0xa1b2c3d4)
+	binary.LittleEndian.PutUint16(hdr[4:6], 2)
+	binary.LittleEndian.PutUint16(hdr[6:8], 4)
+	binary.LittleEndian.PutUint32(hdr[16:20], 65535)
+	binary.LittleEndian.PutUint32(hdr[20:24], 1)
+	b.Write(hdr)
+	for _, s := range segs {
+		src, _ := net.ResolveTCPAddr("tcp", s.src)
+		dst, _ := net.ResolveTCPAddr("tcp", s.dst)
+		f := make([]byte, 14+20+20+s.n)
+		binary.BigEndian.PutUint16(f[12:14], 0x0800)
+		ip := f[14:]
+		ip[0] = 0x45
+		binary.BigEndian.PutUint16(ip[2:4], uint16(40+s.n))
+		ip[8], ip[9] = 64, 6
+		copy(ip[12:16], src.IP.To4())
+		copy(ip[16:20], dst.IP.To4())
+		tcp := ip[20:]
+		binary.BigEndian.PutUint16(tcp[0:2], uint16(src.Port))
+		binary.BigEndian.PutUint16(tcp[2:4], uint16(dst.Port))
+		binary.BigEndian.PutUint32(tcp[4:8], s.seq)
+		tcp[12] = 5 << 4
+		tcp[13] = byte(s.flags)
+		binary.BigEndian.PutUint16(tcp[14:16], 502)
+		t := start.Add(s.at)
+		rec := make([]byte, 16)
+		binary.LittleEndian.PutUint32(rec[0:4], uint32(t.Unix()))
+		binary.LittleEndian.PutUint32(rec[4:8], uint32(t.Nanosecond()/1000))
+		binary.LittleEndian.PutUint32(rec[8:12], uint32(len(f)))
+		binary.LittleEndian.PutUint32(rec[12:16], uint32(len(f)))
+		b.Write(rec)
+		b.Write(f)
+	}
+	return b.Bytes()
+}
+
+func TestCorrelate(t *testing.T) {
+	const laddr, raddr = "192.0.2.1:50000", "192.0.2.2:443"
+	start := time.Unix(1000, 0)
+	b := capture(start, []segment{
+		{0, laddr, raddr, 1000, tcppcap.SYN, 0},
+		{10 * time.Millisecond, raddr, laddr, 5000, tcppcap.SYN | tcppcap.ACK, 0},
+		{11 * time.Millisecond, laddr, raddr, 1001, tcppcap.ACK | tcppcap.PSH, 100},
+		{12 * time.Millisecond, laddr, raddr, 1101, tcppcap.ACK | tcppcap.PSH, 100},
+		{13 * time.Millisecond, "192.0.2.1:50001", raddr, 7, tcppcap.ACK, 10},
+		{250 * time.Millisecond, laddr, raddr, 1001, tcppcap.ACK | tcppcap.PSH, 100},
+		{260 * time.Millisecond, raddr, laddr, 5001, tcppcap.ACK, 0},
+	})
+	pkts, err := tcppcap.ReadPackets(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pkts) != 7 || pkts[2].Len != 100 || pkts[1].Flags != tcppcap.SYN|tcppcap.ACK || pkts[1].Window != 502 || !pkts[0].Time.Equal(start) {
+		t.Fatalf("got %+v", pkts)
+	}
+
+	// The clock of samples is ahead of the clock of capture by a
+	// second.
+	samples := []*tcpinfo.Sample{
+		{Time: start.Add(time.Second + 100*time.Millisecond), Info: &tcpinfo.Info{RTT: 10 * time.Millisecond}},
+		{Time: start.Add(time.Second + 300*time.Millisecond), Info: &tcpinfo.Info{RTT: 20 * time.Millisecond}},
+	}
+	id := tcpinfo.ConnID{Local: laddr, Remote: raddr}
+	evs := tcppcap.Correlate(id, pkts, samples, time.Second)
+	var kinds []tcppcap.EventKind
+	for _, ev := range evs {
+		kinds = append(kinds, ev.Kind)
+	}
+	want := []tcppcap.EventKind{tcppcap.EventPacket, tcppcap.EventPacket, tcppcap.EventPacket, tcppcap.EventPacket, tcppcap.EventSample, tcppcap.EventRetransmit, tcppcap.EventPacket, tcppcap.EventSample}
+	if len(kinds) != len(want) {
+		t.Fatalf("got %v; want %v", kinds, want)
+	}
+	for j := range want {
+		if kinds[j] != want[j] {
+			t.Fatalf("got %v; want %v", kinds, want)
+		}
+	}
+	if !evs[0].Outbound || evs[1].Outbound || !evs[5].Outbound || !evs[5].Time.Equal(start.Add(1250*time.Millisecond)) {
+		t.Fatalf("got %+v", evs)
+	}
+	if ev := evs[4]; ev.Delta != nil || ev.Retransmits != 0 {
+		t.Fatalf("got %+v", ev)
+	}
+	if ev := evs[7]; ev.Delta == nil || ev.Delta.Duration != 200*time.Millisecond || ev.Retransmits != 1 {
+		t.Fatalf("got %+v", ev)
+	}
+}
+
+func TestReadPacketsUnknownFormat(t *testing.T) {
+	b := make([]byte, 24)
+	binary.LittleEndian.PutUint32(b[0:4], 0x0a0d0d0a) // pcapng
+	if _, err := tcppcap.ReadPackets(bytes.NewReader(b)); err == nil {
+		t.Fatal("got nil; want an error")
+	}
+}
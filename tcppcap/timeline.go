@@ -0,0 +1,134 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcppcap
+
+import (
+	"sort"
+	"time"
+
+	"github.com/mikioh/tcpinfo"
+)
+
+// An EventKind represents a kind of event on a timeline.
+type EventKind int
+
+const (
+	_               EventKind = iota
+	EventPacket               // segment captured
+	EventRetransmit           // segment captured, carrying data sent before
+	EventSample               // sample of connection information
+)
+
+var eventKinds = map[EventKind]string{
+	EventPacket:     "packet",
+	EventRetransmit: "retransmit",
+	EventSample:     "sample",
+}
+
+func (k EventKind) String() string {
+	s, ok := eventKinds[k]
+	if !ok {
+		return "<nil>"
+	}
+	return s
+}
+
+// An Event represents an event on a timeline.
+type Event struct {
+	Time     time.Time       // time of event on the clock of samples
+	Kind     EventKind       // kind of event
+	Packet   *Packet         // captured segment; nil for EventSample
+	Outbound bool            // whether the segment was sent by the local endpoint
+	Sample   *tcpinfo.Sample // sample; nil unless EventSample
+
+	// Delta holds the changes since the previous sample; nil on
+	// the first sample.
+	// Retransmits holds the # of retransmitting segments sent by
+	// the local endpoint since the previous sample, as observed in
+	// the capture, which can be compared with the retransmissions
+	// counted by the kernel in Delta.
+	Delta       *tcpinfo.Delta // [EventSample only]
+	Retransmits int            // [EventSample only]
+}
+
+// A flow represents the state of a direction of connection.
+type flow struct {
+	started bool
+	next    uint32 // sequence number next to the highest seen
+}
+
+// retransmit reports whether p carries data sent before, and updates
+// the state.
+func (f *flow) retransmit(p *Packet) bool {
+	n := uint32(p.Len)
+	if p.Flags&SYN != 0 {
+		n++
+	}
+	if p.Flags&FIN != 0 {
+		n++
+	}
+	if n == 0 {
+		return false
+	}
+	end := p.Seq + n
+	if !f.started {
+		f.started, f.next = true, end
+		return false
+	}
+	rexmit := int32(p.Seq-f.next) < 0
+	if int32(end-f.next) > 0 {
+		f.next = end
+	}
+	return rexmit
+}
+
+// Correlate returns a timeline merging the segments of the
+// connection id in pkts and the samples taken on the connection, in
+// order of time.
+//
+// The offset is added to the times of segments to align the clock of
+// capture with the clock of samples.
+// The segments and samples must be in order of time.
+func Correlate(id tcpinfo.ConnID, pkts []Packet, samples []*tcpinfo.Sample, offset time.Duration) []Event {
+	var out, in flow
+	evs := make([]Event, 0, len(pkts)+len(samples))
+	for j := range pkts {
+		p := &pkts[j]
+		var f *flow
+		switch {
+		case p.Src.String() == id.Local && p.Dst.String() == id.Remote:
+			f = &out
+		case p.Src.String() == id.Remote && p.Dst.String() == id.Local:
+			f = &in
+		default:
+			continue
+		}
+		ev := Event{Time: p.Time.Add(offset), Kind: EventPacket, Packet: p, Outbound: f == &out}
+		if f.retransmit(p) {
+			ev.Kind = EventRetransmit
+		}
+		evs = append(evs, ev)
+	}
+	for _, s := range samples {
+		evs = append(evs, Event{Time: s.Time, Kind: EventSample, Sample: s})
+	}
+	sort.SliceStable(evs, func(i, j int) bool { return evs[i].Time.Before(evs[j].Time) })
+	var prev *tcpinfo.Sample
+	var rexmits int
+	for j := range evs {
+		ev := &evs[j]
+		switch {
+		case ev.Kind == EventRetransmit && ev.Outbound:
+			rexmits++
+		case ev.Kind == EventSample:
+			if prev != nil {
+				ev.Delta = tcpinfo.Diff(prev, ev.Sample)
+				ev.Retransmits = rexmits
+			}
+			prev, rexmits = ev.Sample, 0
+		}
+	}
+	return evs
+}
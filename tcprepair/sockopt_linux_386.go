@@ -0,0 +1,23 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcprepair
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+const sysGETSOCKOPT = 0xf
+
+func getsockopt(s uintptr, level, name int, b []byte) (int, error) {
+	l := uint32(len(b))
+	args := [5]uintptr{s, uintptr(level), uintptr(name), uintptr(unsafe.Pointer(&b[0])), uintptr(unsafe.Pointer(&l))}
+	_, _, errno := syscall.Syscall(syscall.SYS_SOCKETCALL, sysGETSOCKOPT, uintptr(unsafe.Pointer(&args)), 0)
+	if errno != 0 {
+		return 0, os.NewSyscallError("getsockopt", errno)
+	}
+	return int(l), nil
+}
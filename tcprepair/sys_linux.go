@@ -0,0 +1,117 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcprepair
+
+import (
+	"errors"
+	"net"
+	"os"
+	"syscall"
+	"unsafe"
+
+	"github.com/mikioh/tcpinfo"
+)
+
+const (
+	sysTCP_REPAIR        = 0x13
+	sysTCP_REPAIR_QUEUE  = 0x14
+	sysTCP_QUEUE_SEQ     = 0x15
+	sysTCP_REPAIR_WINDOW = 0x1d
+
+	sysTCP_REPAIR_ON        = 0x1
+	sysTCP_REPAIR_OFF       = 0x0
+	sysTCP_REPAIR_OFF_NO_WP = -0x1
+
+	sysTCP_RECV_QUEUE = 0x1
+	sysTCP_SEND_QUEUE = 0x2
+
+	sizeofTCPRepairWindow = 0x14
+)
+
+// Take takes a snapshot of connection state of c.
+//
+// The connection must implement syscall.Conn and be in the
+// established state.
+func Take(c net.Conn) (*Snapshot, error) {
+	sc, ok := c.(syscall.Conn)
+	if !ok {
+		return nil, errors.New("not a syscall.Conn")
+	}
+	rc, err := sc.SyscallConn()
+	if err != nil {
+		return nil, err
+	}
+	i, err := tcpinfo.Get(c)
+	if err != nil {
+		return nil, err
+	}
+	if i.State != tcpinfo.Established {
+		return nil, errors.New("connection not established")
+	}
+	ss := &Snapshot{Info: i}
+	var operr error
+	fn := func(s uintptr) {
+		operr = ss.take(int(s))
+	}
+	if err := rc.Control(fn); err != nil {
+		return nil, err
+	}
+	if operr != nil {
+		return nil, operr
+	}
+	return ss, nil
+}
+
+func (ss *Snapshot) take(s int) error {
+	if err := syscall.SetsockoptInt(s, syscall.IPPROTO_TCP, sysTCP_REPAIR, sysTCP_REPAIR_ON); err != nil {
+		return os.NewSyscallError("setsockopt", err)
+	}
+	defer func() {
+		if err := syscall.SetsockoptInt(s, syscall.IPPROTO_TCP, sysTCP_REPAIR, sysTCP_REPAIR_OFF_NO_WP); err != nil {
+			syscall.SetsockoptInt(s, syscall.IPPROTO_TCP, sysTCP_REPAIR, sysTCP_REPAIR_OFF)
+		}
+	}()
+	var err error
+	var rcvq, sndq int
+	if ss.Info.Queue != nil {
+		rcvq, sndq = int(ss.Info.Queue.Receive), int(ss.Info.Queue.Send)
+	}
+	if ss.RecvSeq, ss.RecvQueue, err = peekQueue(s, sysTCP_RECV_QUEUE, rcvq); err != nil {
+		return err
+	}
+	if ss.SendSeq, ss.SendQueue, err = peekQueue(s, sysTCP_SEND_QUEUE, sndq); err != nil {
+		return err
+	}
+	var b [sizeofTCPRepairWindow]byte
+	if n, err := getsockopt(uintptr(s), syscall.IPPROTO_TCP, sysTCP_REPAIR_WINDOW, b[:]); err == nil && n == sizeofTCPRepairWindow {
+		w := *(*Window)(unsafe.Pointer(&b[0]))
+		ss.Window = &w
+	}
+	return nil
+}
+
+// peekQueue returns the sequence number and queued data of the
+// queue q.
+func peekQueue(s, q, l int) (uint32, []byte, error) {
+	if err := syscall.SetsockoptInt(s, syscall.IPPROTO_TCP, sysTCP_REPAIR_QUEUE, q); err != nil {
+		return 0, nil, os.NewSyscallError("setsockopt", err)
+	}
+	seq, err := syscall.GetsockoptInt(s, syscall.IPPROTO_TCP, sysTCP_QUEUE_SEQ)
+	if err != nil {
+		return 0, nil, os.NewSyscallError("getsockopt", err)
+	}
+	if l <= 0 {
+		return uint32(seq), nil, nil
+	}
+	b := make([]byte, l)
+	n, _, err := syscall.Recvfrom(s, b, syscall.MSG_PEEK|syscall.MSG_DONTWAIT)
+	if err == syscall.EAGAIN {
+		n, err = 0, nil
+	}
+	if err != nil {
+		return 0, nil, os.NewSyscallError("recvfrom", err)
+	}
+	return uint32(seq), b[:n], nil
+}
@@ -0,0 +1,12 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build !linux
+
+package tcprepair
+
+import "net"
+
+// Take takes a snapshot of connection state of c.
+func Take(c net.Conn) (*Snapshot, error) { return nil, errOpNoSupport }
@@ -0,0 +1,41 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package tcprepair implements snapshots of TCP connection state
+// using the Linux TCP repair mode.
+//
+// A snapshot contains sequence numbers, window parameters and queued
+// data of a connection alongside connection information, which is
+// what connection migration and checkpointing tools need to restore
+// the connection elsewhere.
+//
+// Taking a snapshot requires the CAP_NET_ADMIN capability.
+// The connection is put into repair mode only while the snapshot is
+// taken, and transmission on the connection is suspended meanwhile.
+//
+// Only supported on Linux.
+package tcprepair
+
+import "github.com/mikioh/tcpinfo"
+
+var errOpNoSupport = tcpinfo.ErrNotSupported
+
+// A Window represents window parameters of connection.
+type Window struct {
+	SendWL1   uint32 `json:"snd_wl1"`    // sequence number used for last window update
+	SendWnd   uint32 `json:"snd_wnd"`    // send window in bytes
+	MaxWindow uint32 `json:"max_window"` // maximum window ever advertised by peer in bytes
+	RecvWnd   uint32 `json:"rcv_wnd"`    // current receive window in bytes
+	RecvWup   uint32 `json:"rcv_wup"`    // receive sequence number at last window update
+}
+
+// A Snapshot represents a snapshot of connection state.
+type Snapshot struct {
+	Info      *tcpinfo.Info `json:"info"`    // connection information including negotiated options
+	SendSeq   uint32        `json:"snd_seq"` // next sequence number to be sent
+	RecvSeq   uint32        `json:"rcv_seq"` // next sequence number expected to be received
+	Window    *Window       `json:"window,omitempty"`
+	SendQueue []byte        `json:"snd_queue,omitempty"` // unacknowledged and unsent data
+	RecvQueue []byte        `json:"rcv_queue,omitempty"` // received data not read by application
+}
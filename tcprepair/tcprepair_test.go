@@ -0,0 +1,74 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcprepair_test
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/mikioh/tcpinfo"
+	"github.com/mikioh/tcpinfo/tcprepair"
+)
+
+func TestTake(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skipf("%s/%s", runtime.GOOS, runtime.GOARCH)
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	c, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	ac, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ac.Close()
+
+	data := []byte("HELLO-R-U-THERE")
+	if _, err := c.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 100; i++ {
+		if q, err := tcpinfo.GetQueue(ac); err == nil && q.Receive == uint(len(data)) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	ss, err := tcprepair.Take(ac)
+	if err != nil {
+		t.Skip(err)
+	}
+	if !bytes.Equal(ss.RecvQueue, data) {
+		t.Fatalf("got %q; want %q", ss.RecvQueue, data)
+	}
+	if ss.Info == nil || ss.Window == nil {
+		t.Fatalf("got %+v; want connection information and window", ss)
+	}
+
+	// The connection must remain usable after the snapshot.
+	b := make([]byte, len(data))
+	if _, err := io.ReadFull(ac, b); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b, data) {
+		t.Fatalf("got %q; want %q", b, data)
+	}
+	if _, err := ac.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.ReadFull(c, b); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,107 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcptrace
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"syscall"
+
+	"github.com/mikioh/tcpinfo"
+)
+
+var tracefsDirs = []string{"/sys/kernel/tracing", "/sys/kernel/debug/tracing"}
+
+var instanceSeq uint32
+
+// A Tracer reads tracepoint events.
+type Tracer struct {
+	m     *tcpinfo.Monitor
+	dir   string
+	kinds []EventKind
+	f     *os.File
+	r     *bufio.Reader
+}
+
+// NewTracer enables the tracepoints of kinds on a new tracefs
+// instance and returns a tracer reading events from it.
+// When no kind is specified, all the kinds are enabled.
+//
+// Events on connections tracked by the monitor m are correlated to
+// the connections.
+// The monitor m may be nil.
+func NewTracer(m *tcpinfo.Monitor, kinds ...EventKind) (*Tracer, error) {
+	if len(kinds) == 0 {
+		kinds = []EventKind{EventRetransmit, EventProbe}
+	}
+	root := ""
+	for _, dir := range tracefsDirs {
+		if _, err := os.Stat(filepath.Join(dir, "instances")); err == nil {
+			root = dir
+			break
+		}
+	}
+	if root == "" {
+		return nil, errOpNoSupport
+	}
+	t := &Tracer{m: m, kinds: kinds}
+	t.dir = filepath.Join(root, "instances", fmt.Sprintf("tcpinfo-%d-%d", os.Getpid(), atomic.AddUint32(&instanceSeq, 1)))
+	if err := os.Mkdir(t.dir, 0700); err != nil {
+		return nil, err
+	}
+	for _, k := range kinds {
+		if err := t.enable(k, true); err != nil {
+			t.Close()
+			return nil, err
+		}
+	}
+	f, err := os.OpenFile(filepath.Join(t.dir, "trace_pipe"), os.O_RDONLY|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		t.Close()
+		return nil, err
+	}
+	t.f, t.r = f, bufio.NewReader(f)
+	return t, nil
+}
+
+func (t *Tracer) enable(k EventKind, on bool) error {
+	v := []byte("0")
+	if on {
+		v = []byte("1")
+	}
+	return ioutil.WriteFile(filepath.Join(t.dir, "events", "tcp", k.String(), "enable"), v, 0)
+}
+
+// Read waits for and returns the next event.
+func (t *Tracer) Read() (*Event, error) {
+	for {
+		line, err := t.r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		ev, err := ParseEvent(line)
+		if err != nil {
+			continue
+		}
+		correlate(t.m, ev)
+		return ev, nil
+	}
+}
+
+// Close disables the tracepoints and removes the tracefs instance.
+// Any blocked Read will be unblocked and return an error.
+func (t *Tracer) Close() error {
+	if t.f != nil {
+		t.f.Close()
+	}
+	for _, k := range t.kinds {
+		t.enable(k, false)
+	}
+	return os.Remove(t.dir)
+}
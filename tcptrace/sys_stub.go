@@ -0,0 +1,24 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build !linux
+
+package tcptrace
+
+import "github.com/mikioh/tcpinfo"
+
+// A Tracer reads tracepoint events.
+type Tracer struct{}
+
+// NewTracer enables the tracepoints of kinds on a new tracefs
+// instance and returns a tracer reading events from it.
+func NewTracer(m *tcpinfo.Monitor, kinds ...EventKind) (*Tracer, error) {
+	return nil, errOpNoSupport
+}
+
+// Read waits for and returns the next event.
+func (t *Tracer) Read() (*Event, error) { return nil, errOpNoSupport }
+
+// Close disables the tracepoints and removes the tracefs instance.
+func (t *Tracer) Close() error { return errOpNoSupport }
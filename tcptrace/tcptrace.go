@@ -0,0 +1,199 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package tcptrace implements a tracefs tracer for TCP tracepoint
+// events.
+//
+// A Tracer enables the tcp:tcp_retransmit_skb and tcp:tcp_probe
+// tracepoints on a dedicated tracefs instance, parses the text lines
+// of its trace_pipe file and correlates events to connections
+// tracked by a tcpinfo.Monitor.
+// This gives visibility into retransmissions that happen between
+// two samples, which periodic sampling misses.
+//
+// The tracer loads no eBPF programs; events are formatted as text by
+// the kernel and parsed here, which costs more per event than
+// reading them from a BPF ring buffer and is lossy when the
+// trace buffer overflows.
+// Other users of the tracing facility are not disturbed.
+// Only supported on Linux, and requires privileges to write to
+// tracefs.
+package tcptrace
+
+import (
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mikioh/tcpinfo"
+)
+
+var (
+	errMalformedEvent = errors.New("malformed event")
+	errOpNoSupport    = tcpinfo.ErrNotSupported
+)
+
+// An EventKind represents a kind of tracepoint event.
+type EventKind int
+
+const (
+	_               EventKind = iota
+	EventRetransmit           // tcp:tcp_retransmit_skb
+	EventProbe                // tcp:tcp_probe
+)
+
+var eventKinds = map[EventKind]string{
+	EventRetransmit: "tcp_retransmit_skb",
+	EventProbe:      "tcp_probe",
+}
+
+func (k EventKind) String() string {
+	s, ok := eventKinds[k]
+	if !ok {
+		return "<nil>"
+	}
+	return s
+}
+
+// An Event represents a tracepoint event.
+type Event struct {
+	Kind       EventKind         // kind of event
+	Timestamp  time.Duration     // time since boot
+	LocalAddr  *net.TCPAddr      // local address
+	RemoteAddr *net.TCPAddr      // remote address
+	Fields     map[string]string // all fields of event
+
+	State            tcpinfo.State // connection state [EventRetransmit only]
+	SenderWindowSegs uint          // congestion window for sender in # of segments [EventProbe only]
+	SSThreshold      uint          // slow start threshold for sender in # of segments [EventProbe only]
+	RTT              time.Duration // smoothed round-trip time [EventProbe only]
+
+	Conn net.Conn // correlated connection; nil when not tracked
+}
+
+var tcpStates = map[string]tcpinfo.State{
+	"TCP_ESTABLISHED": tcpinfo.Established,
+	"TCP_SYN_SENT":    tcpinfo.SynSent,
+	"TCP_SYN_RECV":    tcpinfo.SynReceived,
+	"TCP_FIN_WAIT1":   tcpinfo.FinWait1,
+	"TCP_FIN_WAIT2":   tcpinfo.FinWait2,
+	"TCP_TIME_WAIT":   tcpinfo.TimeWait,
+	"TCP_CLOSE":       tcpinfo.Closed,
+	"TCP_CLOSE_WAIT":  tcpinfo.CloseWait,
+	"TCP_LAST_ACK":    tcpinfo.LastAck,
+	"TCP_LISTEN":      tcpinfo.Listen,
+	"TCP_CLOSING":     tcpinfo.Closing,
+}
+
+// ParseEvent parses a line of the tracefs trace_pipe file such as:
+//
+//	<idle>-0 [001] ..s1. 1234.567890: tcp_retransmit_skb: skbaddr=... family=AF_INET sport=43210 dport=80 saddr=192.0.2.1 daddr=192.0.2.2 ... state=TCP_ESTABLISHED
+func ParseEvent(line string) (*Event, error) {
+	var ev Event
+	var name string
+	for k, s := range eventKinds {
+		i := strings.Index(line, " "+s+": ")
+		if i < 0 {
+			continue
+		}
+		ev.Kind, name = k, s
+		ts := line[:i]
+		if j := strings.LastIndexByte(ts, ' '); j >= 0 {
+			ts = ts[j+1:]
+		}
+		f, err := strconv.ParseFloat(strings.TrimSuffix(ts, ":"), 64)
+		if err != nil {
+			return nil, errMalformedEvent
+		}
+		ev.Timestamp = time.Duration(f * float64(time.Second))
+		line = line[i+len(name)+3:]
+		break
+	}
+	if name == "" {
+		return nil, errMalformedEvent
+	}
+	ev.Fields = make(map[string]string)
+	for _, f := range strings.Fields(line) {
+		if i := strings.IndexByte(f, '='); i > 0 {
+			ev.Fields[f[:i]] = f[i+1:]
+		}
+	}
+	var err error
+	switch ev.Kind {
+	case EventRetransmit:
+		err = ev.parseRetransmit()
+	case EventProbe:
+		err = ev.parseProbe()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &ev, nil
+}
+
+func (ev *Event) parseRetransmit() error {
+	saddr, daddr := ev.Fields["saddr"], ev.Fields["daddr"]
+	if ev.Fields["family"] == "AF_INET6" {
+		saddr, daddr = ev.Fields["saddrv6"], ev.Fields["daddrv6"]
+	}
+	sport, err := strconv.Atoi(ev.Fields["sport"])
+	if err != nil {
+		return errMalformedEvent
+	}
+	dport, err := strconv.Atoi(ev.Fields["dport"])
+	if err != nil {
+		return errMalformedEvent
+	}
+	ev.LocalAddr = &net.TCPAddr{IP: net.ParseIP(saddr), Port: sport}
+	ev.RemoteAddr = &net.TCPAddr{IP: net.ParseIP(daddr), Port: dport}
+	if ev.LocalAddr.IP == nil || ev.RemoteAddr.IP == nil {
+		return errMalformedEvent
+	}
+	ev.State = tcpStates[ev.Fields["state"]]
+	return nil
+}
+
+func (ev *Event) parseProbe() error {
+	var err error
+	if ev.LocalAddr, err = parseAddr(ev.Fields["src"]); err != nil {
+		return err
+	}
+	if ev.RemoteAddr, err = parseAddr(ev.Fields["dest"]); err != nil {
+		return err
+	}
+	if v, err := strconv.ParseUint(ev.Fields["snd_cwnd"], 10, 32); err == nil {
+		ev.SenderWindowSegs = uint(v)
+	}
+	if v, err := strconv.ParseUint(ev.Fields["ssthresh"], 10, 32); err == nil {
+		ev.SSThreshold = uint(v)
+	}
+	if v, err := strconv.ParseUint(ev.Fields["srtt"], 10, 32); err == nil {
+		ev.RTT = time.Duration(v) * time.Microsecond
+	}
+	return nil
+}
+
+func parseAddr(s string) (*net.TCPAddr, error) {
+	host, port, err := net.SplitHostPort(s)
+	if err != nil {
+		return nil, errMalformedEvent
+	}
+	ip := net.ParseIP(host)
+	p, err := strconv.Atoi(port)
+	if ip == nil || err != nil {
+		return nil, errMalformedEvent
+	}
+	return &net.TCPAddr{IP: ip, Port: p}, nil
+}
+
+// correlate looks up the connection of ev from the connections
+// tracked by m by the address 4-tuple.
+func correlate(m *tcpinfo.Monitor, ev *Event) {
+	if m == nil {
+		return
+	}
+	ev.Conn = m.Lookup(ev.LocalAddr, ev.RemoteAddr)
+}
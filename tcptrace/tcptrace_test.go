@@ -0,0 +1,68 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcptrace_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mikioh/tcpinfo"
+	"github.com/mikioh/tcpinfo/tcptrace"
+)
+
+func TestParseEvent(t *testing.T) {
+	for _, tt := range []struct {
+		line  string
+		kind  tcptrace.EventKind
+		laddr string
+		raddr string
+		state tcpinfo.State
+		cwnd  uint
+		rtt   time.Duration
+	}{
+		{
+			line:  "          <idle>-0       [001] ..s1.  1234.567890: tcp_retransmit_skb: skbaddr=00000000a1b2c3d4 skaddr=00000000e5f6a7b8 family=AF_INET sport=43210 dport=80 saddr=192.0.2.1 daddr=192.0.2.2 saddrv6=::ffff:192.0.2.1 daddrv6=::ffff:192.0.2.2 state=TCP_ESTABLISHED\n",
+			kind:  tcptrace.EventRetransmit,
+			laddr: "192.0.2.1:43210",
+			raddr: "192.0.2.2:80",
+			state: tcpinfo.Established,
+		},
+		{
+			line:  "curl-4242 [000] ..s1. 42.000001: tcp_retransmit_skb: skbaddr=0 skaddr=0 family=AF_INET6 sport=443 dport=50000 saddr=0.0.0.0 daddr=0.0.0.0 saddrv6=2001:db8::1 daddrv6=2001:db8::2 state=TCP_FIN_WAIT1",
+			kind:  tcptrace.EventRetransmit,
+			laddr: "[2001:db8::1]:443",
+			raddr: "[2001:db8::2]:50000",
+			state: tcpinfo.FinWait1,
+		},
+		{
+			line:  "<idle>-0 [002] ..s2. 99.5: tcp_probe: family=AF_INET src=192.0.2.1:43210 dest=192.0.2.2:80 mark=0x0 data_len=0 snd_nxt=0x1 snd_una=0x1 snd_cwnd=10 ssthresh=2147483647 snd_wnd=65483 srtt=1500 rcv_wnd=65536 sock_cookie=1",
+			kind:  tcptrace.EventProbe,
+			laddr: "192.0.2.1:43210",
+			raddr: "192.0.2.2:80",
+			cwnd:  10,
+			rtt:   1500 * time.Microsecond,
+		},
+	} {
+		ev, err := tcptrace.ParseEvent(tt.line)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ev.Kind != tt.kind || ev.LocalAddr.String() != tt.laddr || ev.RemoteAddr.String() != tt.raddr {
+			t.Fatalf("got %v, %v, %v; want %v, %v, %v", ev.Kind, ev.LocalAddr, ev.RemoteAddr, tt.kind, tt.laddr, tt.raddr)
+		}
+		if ev.State != tt.state || ev.SenderWindowSegs != tt.cwnd || ev.RTT != tt.rtt {
+			t.Fatalf("got %v, %d, %v; want %v, %d, %v", ev.State, ev.SenderWindowSegs, ev.RTT, tt.state, tt.cwnd, tt.rtt)
+		}
+	}
+	for _, line := range []string{
+		"",
+		"<idle>-0 [001] ..s1. 1.0: sched_switch: prev_comm=foo",
+		"<idle>-0 [001] ..s1. 1.0: tcp_probe: src=bogus dest=192.0.2.2:80",
+	} {
+		if _, err := tcptrace.ParseEvent(line); err == nil {
+			t.Fatalf("%q: got nil; want an error", line)
+		}
+	}
+}
@@ -0,0 +1,236 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcptstamp
+
+import (
+	"io"
+	"net"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"github.com/mikioh/tcpinfo"
+)
+
+const (
+	sysSO_TIMESTAMPING = 0x25
+
+	sysSOF_TIMESTAMPING_TX_SOFTWARE = 0x2
+	sysSOF_TIMESTAMPING_RX_SOFTWARE = 0x8
+	sysSOF_TIMESTAMPING_SOFTWARE    = 0x10
+	sysSOF_TIMESTAMPING_OPT_ID      = 0x80
+	sysSOF_TIMESTAMPING_TX_ACK      = 0x200
+	sysSOF_TIMESTAMPING_OPT_TSONLY  = 0x800
+
+	sysSCM_TSTAMP_SND = 0x0
+	sysSCM_TSTAMP_ACK = 0x2
+
+	sysSO_EE_ORIGIN_TIMESTAMPING = 0x4
+
+	sysIP_RECVERR   = 0xb
+	sysIPV6_RECVERR = 0x19
+
+	sysMSG_ERRQUEUE = 0x2000
+
+	// maxPending is the maximum # of writes waiting for
+	// acknowledgment, beyond which the writes are forgotten.
+	maxPending = 4096
+)
+
+const sysTimestamping = sysSOF_TIMESTAMPING_TX_SOFTWARE | sysSOF_TIMESTAMPING_RX_SOFTWARE | sysSOF_TIMESTAMPING_SOFTWARE | sysSOF_TIMESTAMPING_OPT_ID | sysSOF_TIMESTAMPING_TX_ACK | sysSOF_TIMESTAMPING_OPT_TSONLY
+
+// A sysSockExtendedErr represents struct sock_extended_err.
+type sysSockExtendedErr struct {
+	Errno  uint32
+	Origin uint8
+	Type   uint8
+	Code   uint8
+	Pad    uint8
+	Info   uint32
+	Data   uint32
+}
+
+// A Stamper measures round-trip times of bursts on a connection.
+//
+// The timestamps of writes are queued on the error queue of the
+// socket, and are drained when Sample is called.
+// Sample should therefore be called periodically, such as by a
+// sampler.
+type Stamper struct {
+	c  net.Conn
+	rc syscall.RawConn
+
+	mu     sync.Mutex
+	sent   map[uint32]time.Time
+	bursts []Burst
+}
+
+// Enable enables timestamping on c and returns a stamper for it.
+//
+// Writes made before Enable are not measured.
+func Enable(c net.Conn) (*Stamper, error) {
+	sc, ok := c.(syscall.Conn)
+	if !ok {
+		return nil, errOpNoSupport
+	}
+	rc, err := sc.SyscallConn()
+	if err != nil {
+		return nil, err
+	}
+	if err := setTimestamping(rc, sysTimestamping); err != nil {
+		return nil, err
+	}
+	return &Stamper{c: c, rc: rc, sent: make(map[uint32]time.Time)}, nil
+}
+
+// Read reads data from the connection into b, and returns the time
+// when the latest segment of the data was received.
+// The returned time is zero when the kernel provides no timestamp.
+func (st *Stamper) Read(b []byte) (int, time.Time, error) {
+	var (
+		n     int
+		ts    time.Time
+		operr error
+	)
+	oob := make([]byte, syscall.CmsgSpace(3*int(unsafe.Sizeof(syscall.Timespec{}))))
+	if err := st.rc.Read(func(s uintptr) bool {
+		var oobn int
+		n, oobn, _, _, operr = syscall.Recvmsg(int(s), b, oob, syscall.MSG_DONTWAIT)
+		if operr == syscall.EAGAIN {
+			return false
+		}
+		if operr == nil {
+			ts, _, _ = parseControlMessage(oob[:oobn])
+		}
+		return true
+	}); err != nil {
+		return 0, time.Time{}, err
+	}
+	if operr != nil {
+		return 0, time.Time{}, os.NewSyscallError("recvmsg", operr)
+	}
+	if n == 0 && len(b) > 0 {
+		return 0, time.Time{}, io.EOF
+	}
+	return n, ts, nil
+}
+
+// Sample returns connection information merged with the bursts
+// acknowledged since the previous call.
+func (st *Stamper) Sample() *Sample {
+	s := &Sample{}
+	if err := st.drain(); err != nil {
+		s.Err = err
+	}
+	st.mu.Lock()
+	s.Bursts, st.bursts = st.bursts, nil
+	st.mu.Unlock()
+	s.Time = time.Now()
+	i, err := tcpinfo.Get(st.c)
+	if err != nil {
+		s.Err = err
+	} else {
+		s.Info = i
+	}
+	return s
+}
+
+// Close disables timestamping on the connection.
+// It does not close the connection.
+func (st *Stamper) Close() error {
+	return setTimestamping(st.rc, 0)
+}
+
+// drain reads the timestamps queued on the error queue of the socket
+// and matches the transmit and acknowledgment timestamps of writes.
+func (st *Stamper) drain() error {
+	oob := make([]byte, 512)
+	var operr error
+	if err := st.rc.Control(func(s uintptr) {
+		for {
+			_, oobn, _, _, err := syscall.Recvmsg(int(s), nil, oob, sysMSG_ERRQUEUE|syscall.MSG_DONTWAIT)
+			if err != nil {
+				if err != syscall.EAGAIN {
+					operr = os.NewSyscallError("recvmsg", err)
+				}
+				return
+			}
+			ts, ee, ok := parseControlMessage(oob[:oobn])
+			if !ok || ee == nil || ee.Origin != sysSO_EE_ORIGIN_TIMESTAMPING {
+				continue
+			}
+			st.stamp(ee.Info, ee.Data, ts)
+		}
+	}); err != nil {
+		return err
+	}
+	return operr
+}
+
+// stamp records the timestamp ts of the type typ for the write
+// identified by id.
+func (st *Stamper) stamp(typ, id uint32, ts time.Time) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	switch typ {
+	case sysSCM_TSTAMP_SND:
+		if len(st.sent) >= maxPending {
+			st.sent = make(map[uint32]time.Time)
+		}
+		if _, ok := st.sent[id]; !ok {
+			st.sent[id] = ts
+		}
+	case sysSCM_TSTAMP_ACK:
+		sent, ok := st.sent[id]
+		if !ok {
+			return
+		}
+		delete(st.sent, id)
+		st.bursts = append(st.bursts, Burst{ID: id, Sent: sent, Acked: ts, RTT: ts.Sub(sent)})
+	}
+}
+
+// parseControlMessage returns the software timestamp and extended
+// error in the control messages b.
+func parseControlMessage(b []byte) (time.Time, *sysSockExtendedErr, bool) {
+	cms, err := syscall.ParseSocketControlMessage(b)
+	if err != nil {
+		return time.Time{}, nil, false
+	}
+	var (
+		ts time.Time
+		ee *sysSockExtendedErr
+	)
+	for _, cm := range cms {
+		switch {
+		case cm.Header.Level == syscall.SOL_SOCKET && cm.Header.Type == sysSO_TIMESTAMPING:
+			if len(cm.Data) < 3*int(unsafe.Sizeof(syscall.Timespec{})) {
+				continue
+			}
+			// The first of the three timestamps is the software
+			// one; the others are of hardware.
+			t := (*syscall.Timespec)(unsafe.Pointer(&cm.Data[0]))
+			ts = time.Unix(int64(t.Sec), int64(t.Nsec))
+		case cm.Header.Level == syscall.IPPROTO_IP && cm.Header.Type == sysIP_RECVERR, cm.Header.Level == syscall.IPPROTO_IPV6 && cm.Header.Type == sysIPV6_RECVERR:
+			if len(cm.Data) < int(unsafe.Sizeof(sysSockExtendedErr{})) {
+				continue
+			}
+			ee = (*sysSockExtendedErr)(unsafe.Pointer(&cm.Data[0]))
+		}
+	}
+	return ts, ee, !ts.IsZero()
+}
+
+func setTimestamping(rc syscall.RawConn, flags int) error {
+	var operr error
+	if err := rc.Control(func(s uintptr) {
+		operr = syscall.SetsockoptInt(int(s), syscall.SOL_SOCKET, sysSO_TIMESTAMPING, flags)
+	}); err != nil {
+		return err
+	}
+	return os.NewSyscallError("setsockopt", operr)
+}
@@ -0,0 +1,35 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build !linux
+
+package tcptstamp
+
+import (
+	"net"
+	"time"
+
+	"github.com/mikioh/tcpinfo"
+)
+
+// A Stamper measures round-trip times of bursts on a connection.
+type Stamper struct{}
+
+// Enable enables timestamping on c and returns a stamper for it.
+func Enable(c net.Conn) (*Stamper, error) { return nil, errOpNoSupport }
+
+// Read reads data from the connection into b, and returns the time
+// when the latest segment of the data was received.
+func (st *Stamper) Read(b []byte) (int, time.Time, error) {
+	return 0, time.Time{}, errOpNoSupport
+}
+
+// Sample returns connection information merged with the bursts
+// acknowledged since the previous call.
+func (st *Stamper) Sample() *Sample {
+	return &Sample{Sample: tcpinfo.Sample{Time: time.Now(), Err: errOpNoSupport}}
+}
+
+// Close disables timestamping on the connection.
+func (st *Stamper) Close() error { return errOpNoSupport }
@@ -0,0 +1,54 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package tcptstamp implements per-burst round-trip time measurement
+// of TCP connections using socket timestamping.
+//
+// A Stamper enables software transmit and receive timestamps on a
+// connection and matches the time each write leaves the host with
+// the time its last byte is acknowledged by the peer.
+// Unlike the smoothed round-trip time reported by the kernel, which
+// lags behind changes on the path, each burst gives a sample of its
+// own.
+// The measurements are merged with samples of connection
+// information.
+//
+// Only supported on Linux.
+package tcptstamp
+
+import (
+	"time"
+
+	"github.com/mikioh/tcpinfo"
+)
+
+var errOpNoSupport = tcpinfo.ErrNotSupported
+
+// A Burst represents a write on a connection and its
+// acknowledgment.
+type Burst struct {
+	ID    uint32        `json:"id"`    // byte offset of the last byte of write from the time timestamping was enabled
+	Sent  time.Time     `json:"sent"`  // time when the write was handed to the network device
+	Acked time.Time     `json:"acked"` // time when the last byte of write was acknowledged
+	RTT   time.Duration `json:"rtt"`   // time from Sent to Acked
+}
+
+// A Sample represents a sample of connection information merged
+// with the bursts acknowledged since the previous sample.
+type Sample struct {
+	tcpinfo.Sample
+	Bursts []Burst `json:"bursts"`
+}
+
+// MinRTT returns the minimum round-trip time of the bursts.
+// It returns zero when s has no burst.
+func (s *Sample) MinRTT() time.Duration {
+	var min time.Duration
+	for _, b := range s.Bursts {
+		if min == 0 || b.RTT < min {
+			min = b.RTT
+		}
+	}
+	return min
+}
@@ -0,0 +1,93 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcptstamp_test
+
+import (
+	"net"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/mikioh/tcpinfo/tcptstamp"
+)
+
+func TestStamper(t *testing.T) {
+	switch runtime.GOOS {
+	case "linux":
+	default:
+		t.Skipf("%s/%s", runtime.GOOS, runtime.GOARCH)
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	c, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	ac, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ac.Close()
+
+	st, err := tcptstamp.Enable(c)
+	if err != nil {
+		t.Skip(err)
+	}
+	defer st.Close()
+	ast, err := tcptstamp.Enable(ac)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ast.Close()
+
+	done := make(chan time.Time, 1)
+	go func() {
+		b := make([]byte, 64)
+		var first time.Time
+		for {
+			_, ts, err := ast.Read(b)
+			if err != nil {
+				done <- first
+				return
+			}
+			if first.IsZero() {
+				first = ts
+			}
+		}
+	}()
+	for n := 0; n < 3; n++ {
+		if _, err := c.Write([]byte("HELLO-R-U-THERE")); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	s := st.Sample()
+	if s.Err != nil {
+		t.Fatal(s.Err)
+	}
+	if s.Info == nil {
+		t.Fatal("got nil info")
+	}
+	if len(s.Bursts) == 0 {
+		t.Fatal("got no bursts")
+	}
+	for _, b := range s.Bursts {
+		if b.RTT <= 0 || b.RTT > time.Second {
+			t.Fatalf("got %+v", b)
+		}
+	}
+	if rtt := s.MinRTT(); rtt <= 0 {
+		t.Fatalf("got %v; want greater than zero", rtt)
+	}
+	c.Close()
+	if ts := <-done; ts.IsZero() {
+		t.Fatal("got no receive timestamp")
+	}
+}
@@ -0,0 +1,114 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcpinfo
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+)
+
+// A TraceEvent represents an event of the Chrome trace event format,
+// which is also read by Perfetto.
+type TraceEvent struct {
+	Name  string                 `json:"name"`
+	Cat   string                 `json:"cat,omitempty"`
+	Phase string                 `json:"ph"`          // "C" for counter, "i" for instant and "M" for metadata
+	Time  float64                `json:"ts"`          // timestamp in microseconds since the Unix epoch
+	PID   int                    `json:"pid"`         // process ID
+	TID   int                    `json:"tid"`         // thread ID
+	Scope string                 `json:"s,omitempty"` // scope of instant event
+	Args  map[string]interface{} `json:"args,omitempty"`
+}
+
+// TraceOpts represents options for trace event export.
+type TraceOpts struct {
+	Name string // name of track, such as a connection identifier; defaults to "tcp"
+	PID  int    // process ID of track
+	TID  int    // thread ID of track
+}
+
+func traceTime(t time.Time) float64 { return float64(t.UnixNano()) / 1e3 }
+
+func traceMillis(d time.Duration) float64 { return float64(d) / float64(time.Millisecond) }
+
+// TraceEvents returns the samples in h as trace events.
+//
+// Each sample with connection information becomes counter events of
+// the round-trip time and congestion window, and retransmissions,
+// state transitions and retrieval errors become instant events on
+// the track.
+// Timestamps are in microseconds since the Unix epoch, so that the
+// events line up with application spans recorded on the same clock
+// when the PID and TID options match those of the spans.
+func (h *History) TraceEvents(opts TraceOpts) []TraceEvent {
+	if opts.Name == "" {
+		opts.Name = "tcp"
+	}
+	evs := []TraceEvent{{Name: "thread_name", Phase: "M", PID: opts.PID, TID: opts.TID, Args: map[string]interface{}{"name": opts.Name}}}
+	ev := func(name, ph string, t time.Time, args map[string]interface{}) {
+		e := TraceEvent{Name: name, Cat: "tcp", Phase: ph, Time: traceTime(t), PID: opts.PID, TID: opts.TID, Args: args}
+		if ph == "C" {
+			e.Name = opts.Name + " " + name // counters are per process
+		}
+		if ph == "i" {
+			e.Scope = "t"
+		}
+		evs = append(evs, e)
+	}
+	var prev *Sample
+	for _, s := range h.Samples() {
+		if s.Err != nil {
+			ev("error", "i", s.Time, map[string]interface{}{"error": s.Err.Error()})
+			continue
+		}
+		i := s.Info
+		if i == nil {
+			continue
+		}
+		ds := i.Stats()
+		rtt := map[string]interface{}{"rtt_ms": traceMillis(i.RTT), "rttvar_ms": traceMillis(i.RTTVar)}
+		if ds.Valid("min_rtt") {
+			rtt["min_rtt_ms"] = traceMillis(ds.MinRTT)
+		}
+		ev("rtt", "C", s.Time, rtt)
+		if cc := i.CongestionControl; cc != nil {
+			cwnd := map[string]interface{}{"snd_ssthresh": cc.SenderSSThreshold}
+			if cc.SenderWindowSegs > 0 {
+				cwnd["snd_cwnd_segs"] = cc.SenderWindowSegs
+			}
+			if cc.SenderWindowBytes > 0 {
+				cwnd["snd_cwnd_bytes"] = cc.SenderWindowBytes
+			}
+			ev("cwnd", "C", s.Time, cwnd)
+		}
+		if prev != nil {
+			if prev.Info.State != i.State {
+				ev("state", "i", s.Time, map[string]interface{}{"from": prev.Info.State.String(), "to": i.State.String()})
+			}
+			if d := Diff(prev, s); d != nil && (d.RetransSegs > 0 || d.RetransBytes > 0) {
+				args := make(map[string]interface{})
+				if d.RetransSegs > 0 {
+					args["retrans_segs"] = d.RetransSegs
+				}
+				if d.RetransBytes > 0 {
+					args["retrans_bytes"] = d.RetransBytes
+				}
+				ev("retransmit", "i", s.Time, args)
+			}
+		}
+		prev = s
+	}
+	return evs
+}
+
+// WriteTrace writes the samples in h as a JSON object of the Chrome
+// trace event format to w, see TraceEvents.
+func (h *History) WriteTrace(w io.Writer, opts TraceOpts) error {
+	return json.NewEncoder(w).Encode(struct {
+		TraceEvents     []TraceEvent `json:"traceEvents"`
+		DisplayTimeUnit string       `json:"displayTimeUnit"`
+	}{h.TraceEvents(opts), "ms"})
+}
@@ -0,0 +1,30 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcpinfo_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mikioh/tcpinfo"
+	"github.com/mikioh/tcpinfo/tcpinfotest"
+)
+
+func TestHistoryTraceRetransmit(t *testing.T) {
+	h := tcpinfo.NewHistory(0)
+	for j, n := range []uint{1, 1, 4} {
+		n := n
+		h.Add(&tcpinfo.Sample{Time: time.Unix(int64(j), 0), Info: tcpinfotest.NewInfo().Sys(func(si *tcpinfo.SysInfo) { si.TotalRetransSegs = n }).Build()})
+	}
+	var rexmits []tcpinfo.TraceEvent
+	for _, ev := range h.TraceEvents(tcpinfo.TraceOpts{}) {
+		if ev.Name == "retransmit" {
+			rexmits = append(rexmits, ev)
+		}
+	}
+	if len(rexmits) != 1 || rexmits[0].Time != 2e6 || rexmits[0].Args["retrans_segs"] != uint64(3) {
+		t.Fatalf("got %+v", rexmits)
+	}
+}
@@ -0,0 +1,62 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcpinfo_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mikioh/tcpinfo"
+	"github.com/mikioh/tcpinfo/tcpinfotest"
+)
+
+func TestHistoryTrace(t *testing.T) {
+	h := tcpinfo.NewHistory(0)
+	t0 := time.Unix(1, 0)
+	h.Add(&tcpinfo.Sample{Time: t0, Info: tcpinfotest.NewInfo().RTT(10*time.Millisecond, time.Millisecond).Build()})
+	h.Add(&tcpinfo.Sample{Time: t0.Add(time.Second), Err: errors.New("bad file descriptor")})
+	h.Add(&tcpinfo.Sample{Time: t0.Add(2 * time.Second), Info: tcpinfotest.NewInfo().RTT(20*time.Millisecond, time.Millisecond).State(tcpinfo.CloseWait).Build()})
+
+	evs := h.TraceEvents(tcpinfo.TraceOpts{Name: "conn-1", PID: 7, TID: 42})
+	var names []string
+	for _, ev := range evs {
+		if ev.PID != 7 || ev.TID != 42 {
+			t.Fatalf("got %+v", ev)
+		}
+		names = append(names, ev.Phase+" "+ev.Name)
+	}
+	want := []string{"M thread_name", "C conn-1 rtt", "C conn-1 cwnd", "i error", "C conn-1 rtt", "C conn-1 cwnd", "i state"}
+	if len(names) != len(want) {
+		t.Fatalf("got %q; want %q", names, want)
+	}
+	for j := range want {
+		if names[j] != want[j] {
+			t.Fatalf("got %q; want %q", names, want)
+		}
+	}
+	if ev := evs[4]; ev.Time != 3e6 || ev.Args["rtt_ms"] != 20.0 {
+		t.Fatalf("got %+v", ev)
+	}
+	if ev := evs[6]; ev.Scope != "t" || ev.Args["from"] != "established" || ev.Args["to"] != "close-wait" {
+		t.Fatalf("got %+v", ev)
+	}
+
+	var buf bytes.Buffer
+	if err := h.WriteTrace(&buf, tcpinfo.TraceOpts{}); err != nil {
+		t.Fatal(err)
+	}
+	var trace struct {
+		TraceEvents []tcpinfo.TraceEvent `json:"traceEvents"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &trace); err != nil {
+		t.Fatal(err)
+	}
+	if len(trace.TraceEvents) != len(want) || trace.TraceEvents[1].Name != "tcp rtt" {
+		t.Fatalf("got %s", buf.Bytes())
+	}
+}
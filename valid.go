@@ -0,0 +1,86 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcpinfo
+
+import "reflect"
+
+// fieldGroups maps the JSON names of the fields of connection
+// information to the groups they belong to.
+// The fields always filled in belong to no group.
+var fieldGroups = func() map[string]FieldMask {
+	m := make(map[string]FieldMask)
+	for _, g := range []struct {
+		t reflect.Type
+		m FieldMask
+	}{
+		{reflect.TypeOf(Info{}), 0},
+		{reflect.TypeOf(FlowControl{}), FieldFlowControl},
+		{reflect.TypeOf(CongestionControl{}), FieldCongestionControl},
+		{reflect.TypeOf(Queue{}), FieldQueue},
+		{reflect.TypeOf(SysInfo{}), FieldSys},
+	} {
+		for _, jf := range jsonFieldsOf(g.t) {
+			m[jf.name] = g.m
+		}
+	}
+	m["opts"], m["peer_opts"] = FieldOptions, FieldOptions
+	delete(m, "flow_ctl")
+	delete(m, "cong_ctl")
+	delete(m, "queue")
+	delete(m, "sys")
+	return m
+}()
+
+// Valid reports whether the field with the JSON name, such as "rtt"
+// or "delivery_rate", holds a value reported by the kernel, which
+// distinguishes a reported zero from a field the kernel does not
+// report.
+//
+// A field is not valid when the platform or the running kernel does
+// not report it, or when its group of fields was not requested from
+// ParseFields or GetFields.
+// All fields are considered valid on connection information not
+// built by parsing, except for the fields of missing structures.
+func (i *Info) Valid(name string) bool {
+	g, ok := fieldGroups[name]
+	if !ok {
+		return false
+	}
+	switch g {
+	case FieldOptions:
+		if i.KernelStructSize > 0 && i.fields&FieldOptions == 0 {
+			return false
+		}
+	case FieldFlowControl:
+		if i.FlowControl == nil {
+			return false
+		}
+	case FieldCongestionControl:
+		if i.CongestionControl == nil {
+			return false
+		}
+	case FieldQueue:
+		return i.Queue != nil
+	case FieldSys:
+		if i.Sys == nil {
+			return false
+		}
+	}
+	return !i.absent(name)
+}
+
+// absent reports whether the field with the JSON name is not
+// reported by the platform or by the running kernel, of which the
+// structure returned is too short, or is held in a socket option not
+// retrieved.
+func (i *Info) absent(name string) bool {
+	if i.KernelStructSize == 0 {
+		return false
+	}
+	if sysSockOpt(name) && i.fields&FieldSockOpts == 0 {
+		return true
+	}
+	return sysUnreported(name) || i.KernelStructSize < sysInfoLen(name)
+}
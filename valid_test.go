@@ -0,0 +1,66 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcpinfo_test
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/mikioh/tcpinfo"
+)
+
+func TestValid(t *testing.T) {
+	i := &tcpinfo.Info{FlowControl: &tcpinfo.FlowControl{}}
+	for _, tt := range []struct {
+		name string
+		ok   bool
+	}{
+		{"rtt", true},
+		{"ato", true},
+		{"opts", true},
+		{"rcv_wnd", true},
+		{"snd_cwnd_segs", false},
+		{"rcv_queue", false},
+		{"no_such_field", false},
+	} {
+		if ok := i.Valid(tt.name); ok != tt.ok {
+			t.Fatalf("got %v for %s; want %v", ok, tt.name, tt.ok)
+		}
+	}
+	ds := i.Stats()
+	if ds.Valid("retrans_segs") || ds.Valid("no_such_stat") {
+		t.Fatal("got true; want false")
+	}
+	if !new(tcpinfo.DerivedStats).Valid("retrans_segs") {
+		t.Fatal("got false; want true")
+	}
+
+	switch runtime.GOOS {
+	case "darwin", "freebsd", "linux", "netbsd":
+	default:
+		return
+	}
+	b := make([]byte, 256)
+	if err := tcpinfo.ParseFields(b, i, tcpinfo.FieldSys); err != nil {
+		t.Fatal(err)
+	}
+	if !i.Valid("rtt") || i.Valid("opts") || i.Valid("rcv_wnd") {
+		t.Fatalf("got %v, %v, %v; want true, false, false", i.Valid("rtt"), i.Valid("opts"), i.Valid("rcv_wnd"))
+	}
+	if err := tcpinfo.ParseInto(b, i); err != nil {
+		t.Fatal(err)
+	}
+	ds = i.Stats()
+	switch runtime.GOOS {
+	case "linux":
+		if !i.Valid("ato") || !i.Valid("delivery_rate") || !ds.Valid("delivery_rate") || ds.Valid("retrans_bytes") {
+			t.Fatal("unexpected validity of Linux fields")
+		}
+	case "darwin":
+		if i.Valid("ato") || !ds.Valid("retrans_bytes") || ds.Valid("retrans_segs") {
+			t.Fatal("unexpected validity of Darwin fields")
+		}
+	}
+}
@@ -0,0 +1,97 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcpinfo
+
+import (
+	"net"
+	"sync"
+	"time"
+)
+
+// A StateEvent represents a transition of connection state.
+type StateEvent struct {
+	Time time.Time // time when the transition was observed
+	From State     // previous state; Unknown for the first event
+	To   State     // current state; Unknown when Err is not nil
+	Err  error     // error on retrieval
+}
+
+// A StateFunc receives transitions of connection state on c.
+type StateFunc func(c net.Conn, ev *StateEvent)
+
+// A StateWatcher watches transitions of connection state by taking
+// samples of connection information periodically.
+//
+// The first observed state and each subsequent change, including
+// the start and end of failing retrieval, are recorded as events.
+type StateWatcher struct {
+	s  *Sampler
+	fn StateFunc
+
+	mu     sync.Mutex
+	last   State
+	failed bool
+	evs    []StateEvent
+}
+
+// WatchState returns a new watcher that checks the state of c every
+// d and invokes fn with each transition.
+// When d is not positive, the state is checked only when the watcher
+// is stopped.
+// The callback function fn may be nil.
+func WatchState(c net.Conn, d time.Duration, fn StateFunc) *StateWatcher {
+	return WatchStateWithGetter(nil, c, d, fn)
+}
+
+// WatchStateWithGetter is like WatchState but retrieves connection
+// information on c from g, such as a sock_diag connection of package
+// sockdiag on Linux.
+// A nil g means retrieval via the socket of c, as WatchState does.
+func WatchStateWithGetter(g Getter, c net.Conn, d time.Duration, fn StateFunc) *StateWatcher {
+	w := &StateWatcher{fn: fn}
+	w.s = NewSamplerWithGetter(g, c, d, w.sample)
+	return w
+}
+
+func (w *StateWatcher) sample(c net.Conn, s *Sample) {
+	ev := StateEvent{Time: s.Time, Err: s.Err}
+	if s.Info != nil {
+		ev.To = s.Info.State
+	}
+	w.mu.Lock()
+	if len(w.evs) > 0 && ev.To == w.last && (ev.Err != nil) == w.failed {
+		w.mu.Unlock()
+		return
+	}
+	ev.From = w.last
+	w.last, w.failed = ev.To, ev.Err != nil
+	w.evs = append(w.evs, ev)
+	w.mu.Unlock()
+	if w.fn != nil {
+		w.fn(c, &ev)
+	}
+}
+
+// State returns the last observed state.
+func (w *StateWatcher) State() State {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.last
+}
+
+// Events returns the transitions observed so far.
+func (w *StateWatcher) Events() []StateEvent {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return append([]StateEvent(nil), w.evs...)
+}
+
+// Stop stops the watcher after checking the state a final time and
+// returns the transitions observed.
+// It must be called before the connection is closed.
+func (w *StateWatcher) Stop() []StateEvent {
+	w.s.Stop()
+	return w.Events()
+}
@@ -0,0 +1,84 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcpinfo_test
+
+import (
+	"errors"
+	"net"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/mikioh/tcpinfo"
+	"github.com/mikioh/tcpinfo/tcpinfotest"
+)
+
+func TestWatchStateWithGetter(t *testing.T) {
+	c := tcpinfotest.NewConn("192.0.2.1:443", "192.0.2.2:50000")
+	g := tcpinfotest.NewGetter()
+	established := tcpinfotest.Step{Info: tcpinfotest.NewInfo().Build()}
+	closeWait := tcpinfotest.Step{Info: tcpinfotest.NewInfo().State(tcpinfo.CloseWait).Build()}
+	lastAck := tcpinfotest.Step{Info: tcpinfotest.NewInfo().State(tcpinfo.LastAck).Build()}
+	errReset := errors.New("connection reset")
+	g.Script(c, established, established, closeWait, tcpinfotest.Step{Err: errReset}, closeWait, closeWait, lastAck)
+
+	var n int
+	w := tcpinfo.WatchStateWithGetter(g, c, time.Millisecond, func(_ net.Conn, ev *tcpinfo.StateEvent) { n++ })
+	for w.State() != tcpinfo.LastAck {
+		time.Sleep(time.Millisecond)
+	}
+	evs := w.Stop()
+	want := []tcpinfo.StateEvent{
+		{From: tcpinfo.Unknown, To: tcpinfo.Established},
+		{From: tcpinfo.Established, To: tcpinfo.CloseWait},
+		{From: tcpinfo.CloseWait, To: tcpinfo.Unknown, Err: errReset},
+		{From: tcpinfo.Unknown, To: tcpinfo.CloseWait},
+		{From: tcpinfo.CloseWait, To: tcpinfo.LastAck},
+	}
+	if len(evs) != len(want) || n != len(want) {
+		t.Fatalf("got %v; want %v", evs, want)
+	}
+	for k := range want {
+		if evs[k].From != want[k].From || evs[k].To != want[k].To || evs[k].Err != want[k].Err || evs[k].Time.IsZero() {
+			t.Fatalf("#%d: got %+v; want %+v", k, evs[k], want[k])
+		}
+	}
+}
+
+func TestWatchState(t *testing.T) {
+	switch runtime.GOOS {
+	case "darwin", "freebsd", "linux", "netbsd":
+	default:
+		t.Skipf("not supported on %s/%s", runtime.GOOS, runtime.GOARCH)
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	c, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ac, err := ln.Accept()
+	if err != nil {
+		c.Close()
+		t.Fatal(err)
+	}
+	defer ac.Close()
+
+	w := tcpinfo.WatchState(ac, time.Millisecond, nil)
+	for w.State() != tcpinfo.Established {
+		time.Sleep(time.Millisecond)
+	}
+	c.Close()
+	for i := 0; i < 1000 && w.State() != tcpinfo.CloseWait; i++ {
+		time.Sleep(time.Millisecond)
+	}
+	evs := w.Stop()
+	if len(evs) != 2 || evs[1].From != tcpinfo.Established || evs[1].To != tcpinfo.CloseWait {
+		t.Fatalf("got %v", evs)
+	}
+}
@@ -0,0 +1,23 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build freebsd netbsd
+
+package zaptcpinfo
+
+import (
+	"github.com/mikioh/tcpinfo"
+	"go.uber.org/zap/zapcore"
+)
+
+func marshalSysInfo(enc zapcore.ObjectEncoder, si *tcpinfo.SysInfo) {
+	enc.AddUint("snd_wnd_bytes", si.SenderWindowBytes)
+	enc.AddUint("snd_wnd_segs", si.SenderWindowSegs)
+	enc.AddUint("egress_seq", si.NextEgressSeq)
+	enc.AddUint("ingress_seq", si.NextIngressSeq)
+	enc.AddUint("retrans_segs", si.RetransSegs)
+	enc.AddUint("ooo_segs", si.OutOfOrderSegs)
+	enc.AddUint("zerownd_updates", si.ZeroWindowUpdates)
+	enc.AddBool("offloading", si.Offloading)
+}
@@ -0,0 +1,23 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zaptcpinfo
+
+import (
+	"github.com/mikioh/tcpinfo"
+	"go.uber.org/zap/zapcore"
+)
+
+func marshalSysInfo(enc zapcore.ObjectEncoder, si *tcpinfo.SysInfo) {
+	enc.AddString("flags", si.Flags.String())
+	enc.AddUint("snd_wnd", si.SenderWindow)
+	enc.AddUint("snd_inuse", si.SenderInUse)
+	enc.AddDuration("srtt", si.SRTT)
+	enc.AddUint64("segs_sent", si.SegsSent)
+	enc.AddUint64("bytes_sent", si.BytesSent)
+	enc.AddUint64("retrans_bytes", si.RetransBytes)
+	enc.AddUint64("segs_rcvd", si.SegsReceived)
+	enc.AddUint64("bytes_rcvd", si.BytesReceived)
+	enc.AddUint64("ooo_bytes_rcvd", si.OutOfOrderBytesReceived)
+}
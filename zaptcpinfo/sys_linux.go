@@ -0,0 +1,40 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zaptcpinfo
+
+import (
+	"github.com/mikioh/tcpinfo"
+	"go.uber.org/zap/zapcore"
+)
+
+func marshalSysInfo(enc zapcore.ObjectEncoder, si *tcpinfo.SysInfo) {
+	enc.AddUint("path_mtu", si.PathMTU)
+	enc.AddUint("adv_mss", uint(si.AdvertisedMSS))
+	enc.AddString("ca_state", si.CAState.String())
+	enc.AddUint("rexmits", si.Retransmissions)
+	enc.AddUint("backoffs", si.Backoffs)
+	enc.AddUint("wnd_ka_probes", si.WindowOrKeepAliveProbes)
+	enc.AddUint("unacked_segs", si.UnackedSegs)
+	enc.AddUint("sacked_segs", si.SackedSegs)
+	enc.AddUint("lost_segs", si.LostSegs)
+	enc.AddUint("retrans_segs", si.RetransSegs)
+	enc.AddUint("fack_segs", si.ForwardAckSegs)
+	enc.AddUint("reord_segs", si.ReorderedSegs)
+	enc.AddDuration("rcv_rtt", si.ReceiverRTT)
+	enc.AddUint("total_retrans_segs", si.TotalRetransSegs)
+	enc.AddUint64("pacing_rate", si.PacingRate)
+	enc.AddUint64("thru_bytes_acked", si.ThruBytesAcked)
+	enc.AddUint64("thru_bytes_rcvd", si.ThruBytesReceived)
+	enc.AddUint("segs_out", si.SegsOut)
+	enc.AddUint("segs_in", si.SegsIn)
+	enc.AddUint("not_sent_bytes", si.NotSentBytes)
+	enc.AddDuration("min_rtt", si.MinRTT)
+	enc.AddUint("data_segs_out", si.DataSegsOut)
+	enc.AddUint("data_segs_in", si.DataSegsIn)
+	enc.AddUint64("delivery_rate", si.DeliveryRate)
+	enc.AddUint("snd_wnd", si.SenderWindow)
+	enc.AddBool("zerocopy", si.ZeroCopy)
+	enc.AddUint("timestamping", si.Timestamping)
+}
@@ -0,0 +1,14 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build !darwin,!freebsd,!linux,!netbsd
+
+package zaptcpinfo
+
+import (
+	"github.com/mikioh/tcpinfo"
+	"go.uber.org/zap/zapcore"
+)
+
+func marshalSysInfo(enc zapcore.ObjectEncoder, si *tcpinfo.SysInfo) {}
@@ -0,0 +1,175 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package zaptcpinfo implements zapcore.ObjectMarshaler for TCP
+// connection information.
+//
+// Example:
+//
+//	logger.Info("conn stats", zaptcpinfo.Field("tcp", i))
+package zaptcpinfo
+
+import (
+	"time"
+
+	"github.com/mikioh/tcpinfo"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+var (
+	_ zapcore.ObjectMarshaler = &Info{}
+	_ zapcore.ObjectMarshaler = &DerivedStats{}
+)
+
+// Field returns a field that encodes connection information without
+// reflection.
+func Field(key string, i *tcpinfo.Info) zap.Field { return zap.Object(key, (*Info)(i)) }
+
+// An Info represents connection information encodable by zap.
+type Info tcpinfo.Info
+
+// MarshalLogObject implements the MarshalLogObject method of
+// zapcore.ObjectMarshaler interface.
+//
+// The fields not reported by the kernel are skipped, as
+// Info.MarshalJSON does.
+func (i *Info) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc = validEncoder{enc, (*tcpinfo.Info)(i)}
+	enc.AddString("state", i.State.String())
+	if len(i.Options) > 0 {
+		enc.AddObject("opts", options(i.Options))
+	}
+	if len(i.PeerOptions) > 0 {
+		enc.AddObject("peer_opts", options(i.PeerOptions))
+	}
+	enc.AddUint("snd_mss", uint(i.SenderMSS))
+	enc.AddUint("rcv_mss", uint(i.ReceiverMSS))
+	enc.AddDuration("rtt", i.RTT)
+	enc.AddDuration("rttvar", i.RTTVar)
+	enc.AddDuration("rto", i.RTO)
+	enc.AddDuration("ato", i.ATO)
+	enc.AddDuration("last_data_sent", i.LastDataSent)
+	enc.AddDuration("last_data_rcvd", i.LastDataReceived)
+	enc.AddDuration("last_ack_rcvd", i.LastAckReceived)
+	if fc := i.FlowControl; fc != nil {
+		enc.AddObject("flow_ctl", zapcore.ObjectMarshalerFunc(func(enc zapcore.ObjectEncoder) error {
+			enc = validEncoder{enc, (*tcpinfo.Info)(i)}
+			enc.AddUint("rcv_wnd", fc.ReceiverWindow)
+			return nil
+		}))
+	}
+	if cc := i.CongestionControl; cc != nil {
+		enc.AddObject("cong_ctl", zapcore.ObjectMarshalerFunc(func(enc zapcore.ObjectEncoder) error {
+			enc = validEncoder{enc, (*tcpinfo.Info)(i)}
+			enc.AddUint("snd_ssthresh", cc.SenderSSThreshold)
+			enc.AddUint("rcv_ssthresh", cc.ReceiverSSThreshold)
+			enc.AddUint("snd_cwnd_bytes", cc.SenderWindowBytes)
+			enc.AddUint("snd_cwnd_segs", cc.SenderWindowSegs)
+			return nil
+		}))
+	}
+	if q := i.Queue; q != nil {
+		enc.AddObject("queue", zapcore.ObjectMarshalerFunc(func(enc zapcore.ObjectEncoder) error {
+			enc.AddUint("rcv_queue", q.Receive)
+			enc.AddUint("snd_queue", q.Send)
+			return nil
+		}))
+	}
+	if si := i.Sys; si != nil {
+		enc.AddObject("sys", zapcore.ObjectMarshalerFunc(func(enc zapcore.ObjectEncoder) error {
+			marshalSysInfo(validEncoder{enc, (*tcpinfo.Info)(i)}, si)
+			return nil
+		}))
+	}
+	return nil
+}
+
+type options []tcpinfo.Option
+
+func (opts options) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	for _, opt := range opts {
+		switch opt := opt.(type) {
+		case tcpinfo.MaxSegSize:
+			enc.AddUint(opt.Kind().String(), uint(opt))
+		case tcpinfo.WindowScale:
+			enc.AddInt(opt.Kind().String(), int(opt))
+		case tcpinfo.SACKPermitted:
+			enc.AddBool(opt.Kind().String(), bool(opt))
+		case tcpinfo.Timestamps:
+			enc.AddBool(opt.Kind().String(), bool(opt))
+		default:
+			if err := enc.AddReflected(opt.Kind().String(), opt); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// A DerivedStats represents derived statistics encodable by zap.
+type DerivedStats tcpinfo.DerivedStats
+
+// MarshalLogObject implements the MarshalLogObject method of
+// zapcore.ObjectMarshaler interface.
+//
+// The statistics not available are skipped.
+func (ds *DerivedStats) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc = validEncoder{enc, (*tcpinfo.DerivedStats)(ds)}
+	enc.AddDuration("min_rtt", ds.MinRTT)
+	enc.AddUint64("retrans_segs", ds.RetransSegs)
+	enc.AddUint64("retrans_bytes", ds.RetransBytes)
+	enc.AddUint64("segs_sent", ds.SegsSent)
+	enc.AddUint64("segs_rcvd", ds.SegsReceived)
+	enc.AddUint64("bytes_sent", ds.BytesSent)
+	enc.AddUint64("bytes_rcvd", ds.BytesReceived)
+	enc.AddUint64("delivery_rate", ds.DeliveryRate)
+	enc.AddUint64("rexmits", ds.Retransmits)
+	enc.AddUint64("backoffs", ds.Backoffs)
+	return nil
+}
+
+// A validEncoder skips the fields that are not valid, keyed by JSON
+// name, such as the fields of connection information not reported by
+// the kernel.
+type validEncoder struct {
+	zapcore.ObjectEncoder
+	v interface{ Valid(name string) bool }
+}
+
+func (enc validEncoder) AddBool(key string, v bool) {
+	if enc.v.Valid(key) {
+		enc.ObjectEncoder.AddBool(key, v)
+	}
+}
+
+func (enc validEncoder) AddDuration(key string, v time.Duration) {
+	if enc.v.Valid(key) {
+		enc.ObjectEncoder.AddDuration(key, v)
+	}
+}
+
+func (enc validEncoder) AddInt(key string, v int) {
+	if enc.v.Valid(key) {
+		enc.ObjectEncoder.AddInt(key, v)
+	}
+}
+
+func (enc validEncoder) AddString(key string, v string) {
+	if enc.v.Valid(key) {
+		enc.ObjectEncoder.AddString(key, v)
+	}
+}
+
+func (enc validEncoder) AddUint(key string, v uint) {
+	if enc.v.Valid(key) {
+		enc.ObjectEncoder.AddUint(key, v)
+	}
+}
+
+func (enc validEncoder) AddUint64(key string, v uint64) {
+	if enc.v.Valid(key) {
+		enc.ObjectEncoder.AddUint64(key, v)
+	}
+}
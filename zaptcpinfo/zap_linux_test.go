@@ -0,0 +1,42 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zaptcpinfo_test
+
+import (
+	"testing"
+
+	"github.com/mikioh/tcpinfo"
+	"github.com/mikioh/tcpinfo/zaptcpinfo"
+	"go.uber.org/zap"
+)
+
+func TestFieldAbsent(t *testing.T) {
+	// Linux 3.10 ends struct tcp_info at tcpi_total_retrans.
+	b := make([]byte, 0x68)
+	b[0] = 1 // established
+	i, err := tcpinfo.Parse(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tcp := logged(t, zaptcpinfo.Field("tcp", i))["tcp"].(map[string]interface{})
+	sys := tcp["sys"].(map[string]interface{})
+	for _, name := range []string{"path_mtu", "total_retrans_segs"} {
+		if _, ok := sys[name]; !ok {
+			t.Errorf("got no %s; want it", name)
+		}
+	}
+	for _, name := range []string{"pacing_rate", "segs_out", "min_rtt", "delivery_rate", "snd_wnd"} {
+		if _, ok := sys[name]; ok {
+			t.Errorf("got %s; want none", name)
+		}
+	}
+	ds := logged(t, zap.Object("stats", (*zaptcpinfo.DerivedStats)(i.Stats())))["stats"].(map[string]interface{})
+	if _, ok := ds["segs_sent"]; ok {
+		t.Errorf("got segs_sent; want none")
+	}
+	if _, ok := ds["rexmits"]; !ok {
+		t.Errorf("got no rexmits; want it")
+	}
+}
@@ -0,0 +1,90 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zaptcpinfo_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mikioh/tcpinfo"
+	"github.com/mikioh/tcpinfo/zaptcpinfo"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// logged returns the context of the single entry logged with f.
+func logged(t *testing.T, f zap.Field) map[string]interface{} {
+	t.Helper()
+	core, logs := observer.New(zap.InfoLevel)
+	zap.New(core).Info("conn stats", f)
+	es := logs.AllUntimed()
+	if len(es) != 1 {
+		t.Fatalf("got %d entries; want 1", len(es))
+	}
+	return es[0].ContextMap()
+}
+
+func TestField(t *testing.T) {
+	i := &tcpinfo.Info{
+		State:             tcpinfo.Established,
+		Options:           []tcpinfo.Option{tcpinfo.MaxSegSize(1460), tcpinfo.WindowScale(7)},
+		PeerOptions:       []tcpinfo.Option{tcpinfo.SACKPermitted(true)},
+		SenderMSS:         1448,
+		RTT:               10 * time.Millisecond,
+		FlowControl:       &tcpinfo.FlowControl{ReceiverWindow: 65535},
+		CongestionControl: &tcpinfo.CongestionControl{SenderWindowSegs: 10},
+		Queue:             &tcpinfo.Queue{Send: 4096},
+	}
+	tcp, ok := logged(t, zaptcpinfo.Field("tcp", i))["tcp"].(map[string]interface{})
+	if !ok {
+		t.Fatal("got no object for tcp")
+	}
+	if tcp["state"] != "established" || tcp["snd_mss"] != uint(1448) || tcp["rtt"] != 10*time.Millisecond {
+		t.Fatalf("got %v", tcp)
+	}
+	if opts := tcp["opts"].(map[string]interface{}); opts["mss"] != uint(1460) || opts["wscale"] != 7 {
+		t.Fatalf("got %v for opts", opts)
+	}
+	if opts := tcp["peer_opts"].(map[string]interface{}); opts["sack"] != true {
+		t.Fatalf("got %v for peer_opts", opts)
+	}
+	if fc := tcp["flow_ctl"].(map[string]interface{}); fc["rcv_wnd"] != uint(65535) {
+		t.Fatalf("got %v for flow_ctl", fc)
+	}
+	if cc := tcp["cong_ctl"].(map[string]interface{}); cc["snd_cwnd_segs"] != uint(10) {
+		t.Fatalf("got %v for cong_ctl", cc)
+	}
+	if q := tcp["queue"].(map[string]interface{}); q["rcv_queue"] != uint(0) || q["snd_queue"] != uint(4096) {
+		t.Fatalf("got %v for queue", q)
+	}
+	if _, ok := tcp["sys"]; ok {
+		t.Fatalf("got %v for sys; want none", tcp["sys"])
+	}
+}
+
+func TestDerivedStats(t *testing.T) {
+	ds := &tcpinfo.DerivedStats{MinRTT: time.Millisecond, RetransSegs: 1, Retransmits: 2, Backoffs: 3}
+	ds.Invalidate("retrans_bytes")
+	ds.Invalidate("delivery_rate")
+	got := logged(t, zap.Object("stats", (*zaptcpinfo.DerivedStats)(ds)))["stats"].(map[string]interface{})
+	want := map[string]interface{}{
+		"min_rtt":      time.Millisecond,
+		"retrans_segs": uint64(1),
+		"segs_sent":    uint64(0),
+		"segs_rcvd":    uint64(0),
+		"bytes_sent":   uint64(0),
+		"bytes_rcvd":   uint64(0),
+		"rexmits":      uint64(2),
+		"backoffs":     uint64(3),
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Fatalf("got %v for %s; want %v", got[k], k, v)
+		}
+	}
+}
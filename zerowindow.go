@@ -0,0 +1,37 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcpinfo
+
+// A ZeroWindow represents the state of zero window on a connection.
+type ZeroWindow struct {
+	Persist    bool `json:"persist"`    // whether the connection is in persist state, the peer advertising a zero window while data waits to be sent
+	Probes     uint `json:"probes"`     // # of unanswered zero window probes sent in persist state [Linux only]
+	Advertised uint `json:"advertised"` // # of zero windows advertised to the peer [FreeBSD and NetBSD]
+}
+
+// ZeroWindow returns the state of zero window on the connection.
+// It returns nil when the platform does not report the state or the
+// platform-specific information is not filled in.
+//
+// Persist state is detected from the advertised window of the peer
+// and the data waiting to be sent, which is taken from Queue when
+// available.
+// On Linux before 5.4, which does not report the advertised window,
+// it is inferred from unanswered probes with data not sent yet.
+func (i *Info) ZeroWindow() *ZeroWindow {
+	if i.Sys == nil {
+		return nil
+	}
+	return zeroWindow(i)
+}
+
+// pending returns the # of bytes waiting to be sent, falling back to
+// n when Queue is not filled in.
+func (i *Info) pending(n uint) uint {
+	if i.Queue != nil {
+		return i.Queue.Send
+	}
+	return n
+}
@@ -0,0 +1,33 @@
+// Copyright 2016 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcpinfo_test
+
+import (
+	"testing"
+
+	"github.com/mikioh/tcpinfo"
+)
+
+func TestZeroWindow(t *testing.T) {
+	for _, tt := range []struct {
+		i    tcpinfo.Info
+		want tcpinfo.ZeroWindow
+	}{
+		{tcpinfo.Info{Sys: &tcpinfo.SysInfo{SenderWindow: 65535, NotSentBytes: 1000}}, tcpinfo.ZeroWindow{}},
+		{tcpinfo.Info{Sys: &tcpinfo.SysInfo{NotSentBytes: 1000, WindowOrKeepAliveProbes: 3}}, tcpinfo.ZeroWindow{Persist: true, Probes: 3}},
+		{tcpinfo.Info{Sys: &tcpinfo.SysInfo{WindowOrKeepAliveProbes: 3}}, tcpinfo.ZeroWindow{}},
+		{tcpinfo.Info{Sys: &tcpinfo.SysInfo{UnackedSegs: 1}, Queue: &tcpinfo.Queue{Send: 1000}}, tcpinfo.ZeroWindow{}},
+		{tcpinfo.Info{Sys: &tcpinfo.SysInfo{}, Queue: &tcpinfo.Queue{Send: 1000}}, tcpinfo.ZeroWindow{Persist: true}},
+		{tcpinfo.Info{KernelStructSize: 0xa8, Sys: &tcpinfo.SysInfo{NotSentBytes: 1000}}, tcpinfo.ZeroWindow{}},
+		{tcpinfo.Info{KernelStructSize: 0xa8, Sys: &tcpinfo.SysInfo{NotSentBytes: 1000, WindowOrKeepAliveProbes: 2}}, tcpinfo.ZeroWindow{Persist: true, Probes: 2}},
+	} {
+		if zw := tt.i.ZeroWindow(); *zw != tt.want {
+			t.Fatalf("%+v: got %+v; want %+v", tt.i.Sys, zw, tt.want)
+		}
+	}
+	if zw := (&tcpinfo.Info{}).ZeroWindow(); zw != nil {
+		t.Fatalf("got %+v; want nil", zw)
+	}
+}
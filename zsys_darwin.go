@@ -4,8 +4,17 @@
 package tcpinfo
 
 const (
+	sysTCP_NODELAY         = 0x1
+	sysTCP_KEEPALIVE       = 0x10
+	sysTCP_KEEPINTVL       = 0x101
+	sysTCP_KEEPCNT         = 0x102
 	sysTCP_CONNECTION_INFO = 0x106
 
+	sysSOL_SOCKET   = 0xffff
+	sysSO_KEEPALIVE = 0x8
+	sysSO_NWRITE    = 0x1024
+	sysFIONREAD     = 0x4004667f
+
 	sysTCPCI_OPT_TIMESTAMPS = 0x1
 	sysTCPCI_OPT_SACK       = 0x2
 	sysTCPCI_OPT_WSCALE     = 0x4
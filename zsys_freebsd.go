@@ -4,7 +4,18 @@
 package tcpinfo
 
 const (
-	sysTCP_INFO = 0x20
+	sysTCP_NODELAY   = 0x1
+	sysTCP_INFO      = 0x20
+	sysTCP_MD5SIG    = 0x10
+	sysTCP_KEEPIDLE  = 0x100
+	sysTCP_KEEPINTVL = 0x200
+	sysTCP_KEEPCNT   = 0x400
+
+	sysSOL_SOCKET   = 0xffff
+	sysSO_KEEPALIVE = 0x8
+
+	sysFIONREAD  = 0x4004667f
+	sysFIONWRITE = 0x40046677
 
 	sysTCPI_OPT_TIMESTAMPS = 0x1
 	sysTCPI_OPT_SACK       = 0x2
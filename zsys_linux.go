@@ -4,9 +4,44 @@
 package tcpinfo
 
 const (
-	sysTCP_INFO       = 0xb
-	sysTCP_CONGESTION = 0xd
-	sysTCP_CC_INFO    = 0x1a
+	sysTCP_NODELAY      = 0x1
+	sysTCP_KEEPIDLE     = 0x4
+	sysTCP_KEEPINTVL    = 0x5
+	sysTCP_KEEPCNT      = 0x6
+	sysTCP_INFO         = 0xb
+	sysTCP_QUICKACK     = 0xc
+	sysTCP_CONGESTION   = 0xd
+	sysTCP_USER_TIMEOUT = 0x12
+	sysTCP_CC_INFO      = 0x1a
+	sysTCP_AO_INFO      = 0x28
+
+	sysSOL_SOCKET      = 0x1
+	sysSO_KEEPALIVE    = 0x9
+	sysSO_TIMESTAMPING = 0x25
+	sysSO_MEMINFO      = 0x37
+	sysSO_ZEROCOPY     = 0x3c
+
+	sysSOF_TIMESTAMPING_TX_HARDWARE = 0x1
+	sysSOF_TIMESTAMPING_TX_SOFTWARE = 0x2
+	sysSOF_TIMESTAMPING_TX_SCHED    = 0x100
+	sysSOF_TIMESTAMPING_TX_ACK      = 0x200
+
+	sysSIOCINQ  = 0x541b
+	sysSIOCOUTQ = 0x5411
+
+	sysSIOCETHTOOL  = 0x8946
+	sysETHTOOL_GTSO = 0x1e
+	sysETHTOOL_GGSO = 0x23
+	sysETHTOOL_GGRO = 0x2b
+
+	sysIFLA_GSO_MAX_SEGS = 0x28
+	sysIFLA_GSO_MAX_SIZE = 0x29
+
+	sysRTM_F_LOOKUP_TABLE = 0x1000
+
+	sysSOL_MPTCP     = 0x11c
+	sysMPTCP_INFO    = 0x1
+	sysMPTCP_TCPINFO = 0x2
 
 	sysTCPI_OPT_TIMESTAMPS = 0x1
 	sysTCPI_OPT_SACK       = 0x2
@@ -26,6 +61,11 @@ const (
 	sizeofTCPVegasInfo = 0x10
 	sizeofTCPDCTCPInfo = 0x10
 	sizeofTCPBBRInfo   = 0x10
+
+	sizeofMPTCPInfo        = 0x50
+	sizeofMPTCPSubflowData = 0x10
+
+	sizeofTCPAOInfoOpt = 0x30
 )
 
 type tcpInfo struct {
@@ -96,3 +136,38 @@ type tcpBBRInfo struct {
 	PacingGain  uint32
 	CWNDGain    uint32
 }
+
+type mptcpInfo struct {
+	Subflows              uint8
+	Add_addr_signal       uint8
+	Add_addr_accepted     uint8
+	Subflows_max          uint8
+	Add_addr_signal_max   uint8
+	Add_addr_accepted_max uint8
+	Local_addr_used       uint8
+	Local_addr_max        uint8
+	Flags                 uint32
+	Token                 uint32
+	Write_seq             uint64
+	Snd_una               uint64
+	Rcv_nxt               uint64
+	Csum_enabled          uint8
+	Pad_cgo_0             [3]byte
+	Retransmits           uint32
+	Bytes_retrans         uint64
+	Bytes_sent            uint64
+	Bytes_received        uint64
+	Bytes_acked           uint64
+}
+
+type mptcpSubflowData struct {
+	Size_subflow_data uint32
+	Num_subflows      uint32
+	Size_kernel       uint32
+	Size_user         uint32
+}
+
+type ethtoolValue struct {
+	Cmd  uint32
+	Data uint32
+}